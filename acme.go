@@ -0,0 +1,115 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// ============================
+// Certificados ACME (Let's Encrypt)
+// ============================
+
+// ACMEConfig configura la obtención automática del certificado TLS para los puntos de venta con
+// un nombre DNS público
+type ACMEConfig struct {
+	Domains  []string
+	Email    string
+	CacheDir string
+	// DirectoryURL permite usar otra autoridad ACME (p. ej. el entorno de pruebas de Let's Encrypt)
+	DirectoryURL string
+	// HTTPPort es el puerto en el que se responde el desafío HTTP-01 (0 lo desactiva y solo se usa
+	// TLS-ALPN-01, que exige que el agente escuche en el puerto 443)
+	HTTPPort int
+}
+
+// LoadACMEConfig carga la configuración ACME desde variables de entorno
+func LoadACMEConfig() ACMEConfig {
+	return ACMEConfig{
+		Domains:      getEnvAsSlice("ACME_DOMAINS", ""),
+		Email:        getEnv("ACME_EMAIL", ""),
+		CacheDir:     getEnv("ACME_CACHE_DIR", "./acme"),
+		DirectoryURL: getEnv("ACME_DIRECTORY_URL", acme.LetsEncryptURL),
+		HTTPPort:     getEnvAsInt("ACME_HTTP_PORT", 80),
+	}
+}
+
+// Enabled indica si el agente debe obtener su certificado por ACME
+func (c ACMEConfig) Enabled() bool {
+	return len(c.Domains) > 0
+}
+
+// ACMEManager obtiene y renueva el certificado del servidor y atiende el desafío HTTP-01
+type ACMEManager struct {
+	manager   *autocert.Manager
+	challenge *http.Server
+}
+
+// NewACMEManager prepara el gestor de certificados con el proxy y los certificados de las descargas
+// (HTTP_PROXY, DOWNLOAD_CA_FILE). Los certificados se guardan en CacheDir y se renuevan solos 30
+// días antes de vencer mientras el agente está en ejecución.
+func NewACMEManager(cfg ACMEConfig, outbound OutboundConfig, port int, logger *Logger) (*ACMEManager, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if err := outbound.apply(transport); err != nil {
+		return nil, err
+	}
+	m := &ACMEManager{manager: &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		Cache:      autocert.DirCache(cfg.CacheDir),
+		HostPolicy: autocert.HostWhitelist(cfg.Domains...),
+		Email:      cfg.Email,
+		Client:     &acme.Client{DirectoryURL: cfg.DirectoryURL, HTTPClient: &http.Client{Transport: transport}},
+	}}
+
+	if cfg.HTTPPort > 0 {
+		m.challenge = &http.Server{
+			Addr:    ":" + strconv.Itoa(cfg.HTTPPort),
+			Handler: m.manager.HTTPHandler(httpsRedirect(port)),
+		}
+		listener, err := net.Listen("tcp", m.challenge.Addr)
+		if err != nil {
+			return nil, fmt.Errorf("no se pudo escuchar el desafío ACME en el puerto %d: %w", cfg.HTTPPort, err)
+		}
+		go func() {
+			defer recoverCrash()
+			if err := m.challenge.Serve(listener); err != nil && err != http.ErrServerClosed {
+				logger.Errorf("Servidor del desafío ACME detenido: %v", err)
+			}
+		}()
+	}
+	logger.Infof("Certificado TLS automático (ACME) para %v en %s", cfg.Domains, cfg.DirectoryURL)
+	return m, nil
+}
+
+// TLSConfig devuelve la configuración TLS que obtiene el certificado en el primer handshake
+func (m *ACMEManager) TLSConfig() *tls.Config {
+	return m.manager.TLSConfig()
+}
+
+// Close detiene el servidor del desafío HTTP-01
+func (m *ACMEManager) Close() error {
+	if m.challenge != nil {
+		return m.challenge.Close()
+	}
+	return nil
+}
+
+// httpsRedirect envía a HTTPS, en el puerto del agente, las solicitudes HTTP que no son del desafío
+func httpsRedirect(port int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+		target := "https://" + host
+		if port != 443 {
+			target += ":" + strconv.Itoa(port)
+		}
+		http.Redirect(w, r, target+r.URL.RequestURI(), http.StatusFound)
+	})
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// ============================
+// Autenticación de la API Administrativa
+// ============================
+
+// AdminAuth protege los endpoints administrativos con un token compartido.
+// Si no hay token configurado, la API administrativa queda deshabilitada.
+type AdminAuth struct {
+	Token  string
+	Logger *Logger
+}
+
+// Require envuelve un manejador exigiendo el token en "Authorization: Bearer" o "X-Admin-Token"
+func (a AdminAuth) Require(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if a.Token == "" {
+			WriteErrorJSON(w, http.StatusForbidden, "API administrativa deshabilitada (configure ADMIN_TOKEN)", nil)
+			return
+		}
+
+		token := r.Header.Get("X-Admin-Token")
+		if auth := r.Header.Get("Authorization"); token == "" && strings.HasPrefix(auth, "Bearer ") {
+			token = strings.TrimPrefix(auth, "Bearer ")
+		}
+
+		if subtle.ConstantTimeCompare([]byte(token), []byte(a.Token)) != 1 {
+			a.Logger.ForRequest(r).Warnf("Acceso administrativo rechazado desde %s a %s", r.RemoteAddr, r.URL.Path)
+			WriteErrorJSON(w, http.StatusUnauthorized, "Token administrativo inválido", nil)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// RequireMethods exige el token solo para los métodos indicados; el resto de la ruta queda libre
+func (a AdminAuth) RequireMethods(next http.HandlerFunc, methods ...string) http.HandlerFunc {
+	protected := a.Require(next)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if slices.Contains(methods, r.Method) {
+			protected(w, r)
+			return
+		}
+		next(w, r)
+	}
+}
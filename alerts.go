@@ -0,0 +1,454 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ============================
+// Alertas al Canal de Soporte (Slack, Telegram, correo)
+// ============================
+
+// Tipos de alerta
+const (
+	AlertPrinterOffline = "printer_offline"
+	// AlertPrinterOnline avisa que una impresora informada fuera de línea se recuperó; se envía con printer_offline
+	AlertPrinterOnline = "printer_online"
+	AlertJobFailed     = "job_failed"
+	AlertDiskLow       = "disk_low"
+	AlertTest          = "test"
+)
+
+// alertDiskCheckInterval es la frecuencia con que se revisa el espacio libre de la carpeta temporal
+const alertDiskCheckInterval = 5 * time.Minute
+
+// alertTitles es el asunto de cada tipo de alerta
+var alertTitles = map[string]string{
+	AlertPrinterOffline: "Impresora fuera de línea",
+	AlertPrinterOnline:  "Impresora en línea nuevamente",
+	AlertJobFailed:      "Falló una impresión",
+	AlertDiskLow:        "Poco espacio en disco",
+	AlertTest:           "Alerta de prueba",
+}
+
+// AlertConfig configura las alertas y sus canales
+type AlertConfig struct {
+	// Events son los tipos de alerta que se envían
+	Events          []string
+	CooldownMinutes int
+	DiskMinFreeMB   int
+	TimeoutSeconds  int
+
+	SlackWebhookURL  string
+	TelegramBotToken string
+	TelegramChatID   string
+	SMTPAddress      string
+	SMTPUser         string
+	SMTPPassword     string
+	EmailFrom        string
+	EmailTo          []string
+}
+
+// LoadAlertConfig carga la configuración de las alertas desde variables de entorno
+func LoadAlertConfig() AlertConfig {
+	return AlertConfig{
+		Events:           getEnvAsSlice("ALERT_EVENTS", "printer_offline,job_failed,disk_low"),
+		CooldownMinutes:  getEnvAsInt("ALERT_COOLDOWN_MINUTES", 30),
+		DiskMinFreeMB:    getEnvAsInt("ALERT_DISK_MIN_FREE_MB", 500),
+		TimeoutSeconds:   getEnvAsInt("ALERT_TIMEOUT_SECONDS", 10),
+		SlackWebhookURL:  getEnv("ALERT_SLACK_WEBHOOK_URL", ""),
+		TelegramBotToken: getEnv("ALERT_TELEGRAM_BOT_TOKEN", ""),
+		TelegramChatID:   getEnv("ALERT_TELEGRAM_CHAT_ID", ""),
+		SMTPAddress:      getEnv("ALERT_SMTP_ADDRESS", ""),
+		SMTPUser:         getEnv("ALERT_SMTP_USER", ""),
+		SMTPPassword:     getEnv("ALERT_SMTP_PASSWORD", ""),
+		EmailFrom:        getEnv("ALERT_EMAIL_FROM", ""),
+		EmailTo:          getEnvAsSlice("ALERT_EMAIL_TO", ""),
+	}
+}
+
+// Enabled indica si hay al menos un canal configurado
+func (c AlertConfig) Enabled() bool {
+	return c.SlackWebhookURL != "" || c.TelegramBotToken != "" || c.SMTPAddress != ""
+}
+
+// Alert es un aviso enviado a los canales configurados
+type Alert struct {
+	Type    string    `json:"type"`
+	Store   string    `json:"store"`
+	Printer string    `json:"printer,omitempty"`
+	JobID   string    `json:"job_id,omitempty"`
+	Message string    `json:"message"`
+	Time    time.Time `json:"time"`
+}
+
+// Title devuelve el asunto de la alerta con el punto de venta
+func (a Alert) Title() string {
+	return fmt.Sprintf("[%s] %s", a.Store, alertTitles[a.Type])
+}
+
+// Text devuelve el mensaje de la alerta en texto plano
+func (a Alert) Text() string {
+	return a.Title() + "\n" + a.Message
+}
+
+// AlertChannel envía las alertas a un medio (Slack, Telegram, correo)
+type AlertChannel interface {
+	Name() string
+	Send(ctx context.Context, alert Alert) error
+}
+
+// SlackChannel envía las alertas a un webhook entrante de Slack (o compatible, como Mattermost)
+type SlackChannel struct {
+	URL    string
+	Client *http.Client
+}
+
+// Name devuelve el nombre del canal
+func (c SlackChannel) Name() string { return "slack" }
+
+// Send publica la alerta en el canal de Slack
+func (c SlackChannel) Send(ctx context.Context, alert Alert) error {
+	return postAlertJSON(ctx, c.Client, c.URL, map[string]string{"text": alert.Text()})
+}
+
+// TelegramChannel envía las alertas a un chat o grupo de Telegram con un bot
+type TelegramChannel struct {
+	Token   string
+	ChatID  string
+	BaseURL string
+	Client  *http.Client
+}
+
+// Name devuelve el nombre del canal
+func (c TelegramChannel) Name() string { return "telegram" }
+
+// Send envía la alerta con sendMessage
+func (c TelegramChannel) Send(ctx context.Context, alert Alert) error {
+	return postAlertJSON(ctx, c.Client, c.BaseURL+"/bot"+c.Token+"/sendMessage",
+		map[string]string{"chat_id": c.ChatID, "text": alert.Text()})
+}
+
+// EmailChannel envía las alertas por correo con SMTP (STARTTLS si el servidor lo ofrece)
+type EmailChannel struct {
+	Address  string
+	User     string
+	Password string
+	From     string
+	To       []string
+}
+
+// Name devuelve el nombre del canal
+func (c EmailChannel) Name() string { return "email" }
+
+// Send envía la alerta por correo
+func (c EmailChannel) Send(ctx context.Context, alert Alert) error {
+	var auth smtp.Auth
+	if c.User != "" {
+		host, _, _ := net.SplitHostPort(c.Address)
+		auth = smtp.PlainAuth("", c.User, c.Password, host)
+	}
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", c.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(c.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", alert.Title()))
+	fmt.Fprintf(&msg, "Date: %s\r\n", alert.Time.Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\nContent-Transfer-Encoding: 8bit\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(alert.Message, "\n", "\r\n") + "\r\n")
+
+	// smtp.SendMail no acepta contexto: se ejecuta aparte y se abandona si vence la espera
+	result := make(chan error, 1)
+	go func() {
+		defer recoverCrash()
+		result <- smtp.SendMail(c.Address, auth, c.From, c.To, msg.Bytes())
+	}()
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("el servidor SMTP %s no respondió: %w", c.Address, ctx.Err())
+	}
+}
+
+// postAlertJSON envía el cuerpo JSON y verifica que la respuesta sea 2xx
+func postAlertJSON(ctx context.Context, client *http.Client, target string, body interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("User-Agent", "PrinterMatiasERP")
+	resp, err := client.Do(req)
+	if err != nil {
+		// La URL de Telegram lleva el token del bot: no se incluye en el error
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return urlErr.Err
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("el canal respondió con estado %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Alerter envía las alertas a todos los canales configurados. Una misma alerta (tipo e impresora)
+// no se repite hasta que pasa el período de silencio, para no inundar el canal de soporte.
+type Alerter struct {
+	Channels    []AlertChannel
+	Events      []string
+	Cooldown    time.Duration
+	Store       string
+	DiskMinFree uint64
+	Timeout     time.Duration
+	Logger      *Logger
+
+	mu      sync.Mutex
+	sent    map[string]time.Time
+	offline map[string]bool
+	done    chan struct{}
+}
+
+// NewAlerter crea el notificador con los canales configurados; sin canales devuelve nil
+func NewAlerter(cfg AlertConfig, outbound OutboundConfig, store string, logger *Logger) (*Alerter, error) {
+	if !cfg.Enabled() {
+		return nil, nil
+	}
+	if cfg.TimeoutSeconds <= 0 || cfg.CooldownMinutes < 0 {
+		return nil, fmt.Errorf("ALERT_TIMEOUT_SECONDS debe ser mayor que cero y ALERT_COOLDOWN_MINUTES no puede ser negativo")
+	}
+	for _, event := range cfg.Events {
+		if _, ok := alertTitles[event]; !ok || event == AlertTest || event == AlertPrinterOnline {
+			return nil, fmt.Errorf("ALERT_EVENTS inválido: %s (use printer_offline, job_failed o disk_low)", event)
+		}
+	}
+	transport := &http.Transport{}
+	if err := outbound.apply(transport); err != nil {
+		return nil, err
+	}
+	client := &http.Client{Transport: transport}
+
+	var channels []AlertChannel
+	if cfg.SlackWebhookURL != "" {
+		if err := ValidateWebhookURL(cfg.SlackWebhookURL); err != nil {
+			return nil, fmt.Errorf("ALERT_SLACK_WEBHOOK_URL: %w", err)
+		}
+		channels = append(channels, SlackChannel{URL: cfg.SlackWebhookURL, Client: client})
+	}
+	if cfg.TelegramBotToken != "" || cfg.TelegramChatID != "" {
+		if cfg.TelegramBotToken == "" || cfg.TelegramChatID == "" {
+			return nil, fmt.Errorf("ALERT_TELEGRAM_BOT_TOKEN y ALERT_TELEGRAM_CHAT_ID deben configurarse juntos")
+		}
+		channels = append(channels, TelegramChannel{Token: cfg.TelegramBotToken, ChatID: cfg.TelegramChatID,
+			BaseURL: "https://api.telegram.org", Client: client})
+	}
+	if cfg.SMTPAddress != "" {
+		if _, _, err := net.SplitHostPort(cfg.SMTPAddress); err != nil {
+			return nil, fmt.Errorf("ALERT_SMTP_ADDRESS debe ser servidor:puerto: %w", err)
+		}
+		if cfg.EmailFrom == "" || len(cfg.EmailTo) == 0 {
+			return nil, fmt.Errorf("ALERT_EMAIL_FROM y ALERT_EMAIL_TO son obligatorios con ALERT_SMTP_ADDRESS")
+		}
+		channels = append(channels, EmailChannel{Address: cfg.SMTPAddress, User: cfg.SMTPUser, Password: cfg.SMTPPassword,
+			From: cfg.EmailFrom, To: cfg.EmailTo})
+	}
+	return &Alerter{
+		Channels:    channels,
+		Events:      cfg.Events,
+		Cooldown:    time.Duration(cfg.CooldownMinutes) * time.Minute,
+		Store:       store,
+		DiskMinFree: uint64(max(cfg.DiskMinFreeMB, 0)) << 20,
+		Timeout:     time.Duration(cfg.TimeoutSeconds) * time.Second,
+		Logger:      logger,
+		sent:        make(map[string]time.Time),
+		offline:     make(map[string]bool),
+		done:        make(chan struct{}),
+	}, nil
+}
+
+// ChannelNames devuelve los canales configurados
+func (a *Alerter) ChannelNames() []string {
+	var names []string
+	for _, c := range a.Channels {
+		names = append(names, c.Name())
+	}
+	return names
+}
+
+// Start inicia la revisión periódica del espacio en disco
+func (a *Alerter) Start() {
+	if a == nil {
+		return
+	}
+	a.Logger.Infof("Alertas de %v por %v", a.Events, a.ChannelNames())
+	if a.enabled(AlertDiskLow) && a.DiskMinFree > 0 {
+		go a.watchDisk()
+	}
+}
+
+// Close detiene la revisión del espacio en disco
+func (a *Alerter) Close() error {
+	if a != nil {
+		close(a.done)
+	}
+	return nil
+}
+
+func (a *Alerter) enabled(alertType string) bool {
+	return slices.Contains(a.Events, alertType)
+}
+
+// PrinterOffline avisa que la impresora quedó fuera de línea
+func (a *Alerter) PrinterOffline(printer, detail string) {
+	if a == nil || !a.enabled(AlertPrinterOffline) {
+		return
+	}
+	a.mu.Lock()
+	a.offline[printer] = true
+	a.mu.Unlock()
+	message := fmt.Sprintf("La impresora '%s' está fuera de línea.", printer)
+	if detail != "" {
+		message += " Detalle: " + detail
+	}
+	a.send(Alert{Type: AlertPrinterOffline, Printer: printer, Message: message}, true)
+}
+
+// PrinterOnline avisa que la impresora se recuperó, solo si antes se avisó que estaba fuera de línea
+func (a *Alerter) PrinterOnline(printer string) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	alerted := a.offline[printer]
+	delete(a.offline, printer)
+	a.mu.Unlock()
+	if alerted {
+		a.send(Alert{Type: AlertPrinterOnline, Printer: printer, Message: fmt.Sprintf("La impresora '%s' volvió a estar en línea.", printer)}, false)
+	}
+}
+
+// JobFailed avisa que un trabajo falló después de agotar los reintentos
+func (a *Alerter) JobFailed(job *PrintJob) {
+	if a == nil || !a.enabled(AlertJobFailed) {
+		return
+	}
+	message := fmt.Sprintf("El trabajo %s (%s) falló en '%s'", job.ID, job.Kind, job.Printer)
+	if job.Attempts > 1 {
+		message += fmt.Sprintf(" después de %d intentos", job.Attempts)
+	}
+	message += fmt.Sprintf(".\nCódigo: %s\nError: %s", job.ErrorCode, job.Error)
+	a.send(Alert{Type: AlertJobFailed, Printer: job.Printer, JobID: job.ID, Message: message}, true)
+}
+
+// watchDisk revisa periódicamente el espacio libre de la carpeta de los archivos temporales
+func (a *Alerter) watchDisk() {
+	defer recoverCrash()
+	ticker := time.NewTicker(alertDiskCheckInterval)
+	defer ticker.Stop()
+	for {
+		a.checkDisk()
+		select {
+		case <-ticker.C:
+		case <-a.done:
+			return
+		}
+	}
+}
+
+// checkDisk avisa si la carpeta temporal tiene menos espacio libre que el mínimo configurado
+func (a *Alerter) checkDisk() {
+	dir := os.TempDir()
+	free, err := diskFreeBytes(dir)
+	if err != nil {
+		a.Logger.Warnf("No se pudo consultar el espacio libre de %s: %v", dir, err)
+		return
+	}
+	if free >= a.DiskMinFree {
+		return
+	}
+	a.send(Alert{Type: AlertDiskLow, Message: fmt.Sprintf("Quedan %d MB libres en %s, donde se guardan los documentos a imprimir (mínimo: %d MB).",
+		free>>20, dir, a.DiskMinFree>>20)}, true)
+}
+
+// send completa la alerta y la envía en segundo plano a todos los canales. Con throttled, una
+// alerta igual (tipo e impresora) enviada durante el período de silencio se descarta.
+func (a *Alerter) send(alert Alert, throttled bool) {
+	alert.Store, alert.Time = a.Store, time.Now()
+	key := alert.Type + "\x00" + alert.Printer
+	a.mu.Lock()
+	if last, ok := a.sent[key]; throttled && ok && time.Since(last) < a.Cooldown {
+		a.mu.Unlock()
+		a.Logger.Infof("Alerta %s de '%s' omitida: ya se envió a las %s", alert.Type, alert.Printer, last.Format("15:04"))
+		return
+	}
+	a.sent[key] = alert.Time
+	a.mu.Unlock()
+
+	go func() {
+		defer recoverCrash()
+		a.deliver(alert)
+	}()
+}
+
+// deliver envía la alerta a cada canal y devuelve el error de cada uno (nil si se entregó)
+func (a *Alerter) deliver(alert Alert) map[string]error {
+	results := make(map[string]error, len(a.Channels))
+	for _, channel := range a.Channels {
+		ctx, cancel := context.WithTimeout(context.Background(), a.Timeout)
+		err := channel.Send(ctx, alert)
+		cancel()
+		results[channel.Name()] = err
+		if err != nil {
+			a.Logger.Errorf("No se pudo enviar la alerta %s por %s: %v", alert.Type, channel.Name(), err)
+		} else {
+			a.Logger.Infof("Alerta %s enviada por %s", alert.Type, channel.Name())
+		}
+	}
+	return results
+}
+
+// TestHandler envía una alerta de prueba a todos los canales y devuelve el resultado de cada uno
+// (POST /admin/alerts/test)
+func (a *Alerter) TestHandler(w http.ResponseWriter, r *http.Request) {
+	logger := a.Logger.ForRequest(r)
+	logger.Info("Received request: /admin/alerts/test")
+
+	if r.Method != http.MethodPost {
+		WriteErrorJSON(w, http.StatusMethodNotAllowed, "Método HTTP no permitido", nil)
+		return
+	}
+	results := a.deliver(Alert{Type: AlertTest, Store: a.Store, Time: time.Now(),
+		Message: "Alerta de prueba de PrinterMatiasERP: el canal está configurado correctamente."})
+	channels := make(map[string]string, len(results))
+	failed := false
+	for name, err := range results {
+		channels[name] = "ok"
+		if err != nil {
+			channels[name], failed = err.Error(), true
+		}
+	}
+	status := http.StatusOK
+	if failed {
+		status = http.StatusBadGateway
+	}
+	WriteJSON(w, status, map[string]interface{}{"channels": channels})
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ============================
+// Alias de Impresoras
+// ============================
+
+// PrinterAliases asocia nombres lógicos estables (p. ej. "caja1", "cocina") con el nombre real
+// de la impresora en Windows, que puede cambiar al reinstalar el controlador
+type PrinterAliases map[string]string
+
+// NewPrinterAliases valida la tabla de alias; las claves no distinguen mayúsculas
+func NewPrinterAliases(entries map[string]string) (PrinterAliases, error) {
+	aliases := make(PrinterAliases, len(entries))
+	for alias, printer := range entries {
+		key := strings.ToLower(strings.TrimSpace(alias))
+		if key == "" || strings.TrimSpace(printer) == "" {
+			return nil, fmt.Errorf("alias de impresora inválido: '%s=%s'", alias, printer)
+		}
+		if _, dup := aliases[key]; dup {
+			return nil, fmt.Errorf("alias de impresora duplicado: %s", alias)
+		}
+		aliases[key] = strings.TrimSpace(printer)
+	}
+	return aliases, nil
+}
+
+// Resolve devuelve el nombre real de la impresora; los nombres que no son alias se devuelven igual
+func (a PrinterAliases) Resolve(name string) string {
+	if printer, ok := a[strings.ToLower(strings.TrimSpace(name))]; ok {
+		return printer
+	}
+	return name
+}
+
+// AliasesOf devuelve los alias definidos para la impresora real
+func (a PrinterAliases) AliasesOf(printer string) []string {
+	var names []string
+	for alias, target := range a {
+		if strings.EqualFold(target, printer) {
+			names = append(names, alias)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// AliasStatusChecker resuelve el alias antes de consultar el estado de la impresora
+type AliasStatusChecker struct {
+	Next    StatusChecker
+	Aliases PrinterAliases
+}
+
+// CheckStatus consulta el estado de la impresora real
+func (c AliasStatusChecker) CheckStatus(printer string) (*PrinterReadiness, error) {
+	return c.Next.CheckStatus(c.Aliases.Resolve(printer))
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ============================
+// Documentos Conservados para Reimpresión
+// ============================
+
+// jobIDPattern evita que un identificador manipulado escape del directorio de documentos
+var jobIDPattern = regexp.MustCompile(`^[0-9a-f]{16}$`)
+
+// ArtifactStore conserva en disco los documentos impresos durante la ventana de retención,
+// para reimprimirlos sin volver a descargarlos (p. ej. tras un atasco de papel).
+type ArtifactStore struct {
+	dir       string
+	retention time.Duration
+	logger    *Logger
+	done      chan struct{}
+}
+
+// NewArtifactStore crea el almacén y programa la depuración periódica de documentos vencidos
+func NewArtifactStore(dir string, retentionHours int, logger *Logger) (*ArtifactStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("error al crear el directorio de documentos '%s': %w", dir, err)
+	}
+	s := &ArtifactStore{
+		dir:       dir,
+		retention: time.Duration(retentionHours) * time.Hour,
+		logger:    logger,
+		done:      make(chan struct{}),
+	}
+	go s.pruneLoop()
+	return s, nil
+}
+
+// Path devuelve la ruta del documento conservado de un trabajo
+func (s *ArtifactStore) Path(jobID string) (string, error) {
+	if !jobIDPattern.MatchString(jobID) {
+		return "", fmt.Errorf("identificador de trabajo inválido: %s", jobID)
+	}
+	return filepath.Join(s.dir, jobID+".pdf"), nil
+}
+
+// Keep mueve el archivo temporal al almacén como documento del trabajo
+func (s *ArtifactStore) Keep(jobID, tempPath string) error {
+	dst, err := s.Path(jobID)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tempPath, dst); err == nil {
+		return nil
+	}
+
+	// El directorio temporal puede estar en otro volumen: copiar y eliminar
+	if err := copyFile(tempPath, dst); err != nil {
+		return err
+	}
+	return os.Remove(tempPath)
+}
+
+// Open devuelve la ruta del documento conservado si todavía está disponible
+func (s *ArtifactStore) Open(jobID string) (string, error) {
+	path, err := s.Path(jobID)
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(path)
+	if err != nil || time.Since(info.ModTime()) > s.retention {
+		return "", fmt.Errorf("el documento del trabajo %s ya no está disponible", jobID)
+	}
+	return path, nil
+}
+
+// Close detiene la depuración periódica
+func (s *ArtifactStore) Close() error {
+	close(s.done)
+	return nil
+}
+
+// pruneLoop elimina cada hora los documentos fuera de la ventana de retención
+func (s *ArtifactStore) pruneLoop() {
+	defer recoverCrash()
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		entries, err := os.ReadDir(s.dir)
+		if err != nil {
+			s.logger.Errorf("Error al depurar los documentos conservados: %v", err)
+		}
+		for _, e := range entries {
+			info, err := e.Info()
+			if err != nil || e.IsDir() || time.Since(info.ModTime()) <= s.retention {
+				continue
+			}
+			if err := os.Remove(filepath.Join(s.dir, e.Name())); err != nil {
+				s.logger.Errorf("Error al eliminar el documento %s: %v", e.Name(), err)
+			}
+		}
+		select {
+		case <-ticker.C:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	return out.Close()
+}
+
+// ReprintRequest es el cuerpo opcional de POST /jobs/{id}/reprint
+type ReprintRequest struct {
+	Printer    string `json:"printer"`
+	WebhookURL string `json:"webhook_url"`
+	PrintOptions
+}
+
+// ReprintHandler reenvía el documento de un trabajo anterior a la misma u otra impresora
+func (h Handlers) ReprintHandler(w http.ResponseWriter, r *http.Request) {
+	h.Logger = h.Logger.ForRequest(r)
+	h.Logger.Info("Received request: /jobs/{id}/reprint")
+
+	if r.Method != http.MethodPost {
+		WriteErrorJSON(w, http.StatusMethodNotAllowed, "Método HTTP no permitido", nil)
+		return
+	}
+
+	original, err := h.History.Get(r.PathValue("id"))
+	if err != nil {
+		WriteErrorJSON(w, http.StatusInternalServerError, "Error al consultar el historial", err)
+		return
+	}
+	if original == nil || original.Kind != JobKindPrint {
+		WriteErrorJSON(w, http.StatusNotFound, "Trabajo de impresión no encontrado", nil)
+		return
+	}
+
+	var req ReprintRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&req); err != nil {
+			WriteErrorJSON(w, http.StatusBadRequest, "Solicitud JSON inválida", err)
+			return
+		}
+	}
+	if req.Printer == "" {
+		req.Printer = original.Printer
+	}
+	// Sin opciones en la solicitud se repiten las del trabajo original
+	opts := req.PrintOptions
+	if opts == (PrintOptions{}) && original.Options != nil {
+		opts = *original.Options
+	}
+	if err := opts.Normalize(); err != nil {
+		WriteErrorJSON(w, http.StatusBadRequest, "Opciones de impresión inválidas", err)
+		return
+	}
+	if err := h.Jobs.Webhooks.CheckURL(req.WebhookURL); err != nil {
+		WriteErrorJSON(w, http.StatusBadRequest, "URL de webhook inválida", err)
+		return
+	}
+
+	// Una reimpresión reutiliza el documento del trabajo original
+	artifactID := original.ID
+	if id, ok := strings.CutPrefix(original.Source, "reprint:"); ok {
+		artifactID = id
+	}
+
+	job := NewPrintJob(JobKindPrint, req.Printer, "reprint:"+artifactID)
+	job.RequestID = RequestID(r)
+	job.ClientCN = ClientCommonName(r)
+	job.SHA256 = original.SHA256
+	job.WebhookURL = req.WebhookURL
+	job.Options = &opts
+
+	err = h.Jobs.RunHoldable(job, func() error {
+		if err := h.preflight(job); err != nil {
+			return err
+		}
+		return h.Service.ReprintDocument(artifactID, req.Printer, opts)
+	})
+	if errors.Is(err, ErrJobHeld) {
+		WriteJobHeldJSON(w, job)
+		return
+	}
+	if err != nil {
+		h.Logger.Errorf("Error al reimprimir: %v", err)
+		WriteJobErrorJSON(w, jobErrorStatus(err), job, "Error al reimprimir el documento", err)
+		return
+	}
+
+	WriteJobJSON(w, job, "Documento reenviado a la impresora exitosamente.")
+}
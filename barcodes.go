@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/code128"
+	"github.com/boombuler/barcode/code39"
+	"github.com/boombuler/barcode/ean"
+	"github.com/boombuler/barcode/qr"
+)
+
+// ============================
+// Códigos QR y de Barras como Imagen
+// ============================
+
+// Los modelos económicos y algunos clones de impresoras térmicas ignoran GS ( k y GS k, o solo
+// aceptan parte de las simbologías. Para esas impresoras el código se genera en el agente y se
+// envía como imagen de mapa de bits (GS v 0), que todas soportan.
+
+// Ancho imprimible en puntos (203 dpi) según el ancho del rollo
+var receiptDots = map[int]int{58: 384, 80: 576}
+
+// qrCorrectionLevels traduce el nivel de corrección de errores al del codificador
+var qrCorrectionLevels = map[string]qr.ErrorCorrectionLevel{"L": qr.L, "M": qr.M, "Q": qr.Q, "H": qr.H}
+
+// QRImage genera el código QR con módulos de moduleSize puntos, reduciéndolos si el código no entra
+// en maxDots (el ancho imprimible del rollo)
+func QRImage(data string, moduleSize int, correction string, maxDots int) (image.Image, error) {
+	code, err := qr.Encode(data, qrCorrectionLevels[correction], qr.Auto)
+	if err != nil {
+		return nil, fmt.Errorf("error al generar el código QR: %w", err)
+	}
+	modules := code.Bounds().Dx()
+	if modules*moduleSize > maxDots {
+		moduleSize = maxDots / modules
+	}
+	if moduleSize < 1 {
+		return nil, fmt.Errorf("el código QR (%d módulos) no entra en el ancho del papel (%d puntos)", modules, maxDots)
+	}
+	return barcode.Scale(code, modules*moduleSize, modules*moduleSize)
+}
+
+// BarcodeImage genera el código de barras con barras de 2 puntos de ancho (1 si no entra en maxDots)
+// y height puntos de alto. UPC-A se codifica como EAN-13 con un 0 inicial, que es equivalente.
+func BarcodeImage(symbology, data string, height, maxDots int) (image.Image, error) {
+	var code barcode.Barcode
+	var err error
+	switch symbology {
+	case "code128":
+		code, err = code128.Encode(data)
+	case "ean13", "ean8":
+		code, err = ean.Encode(data)
+	case "upca":
+		code, err = ean.Encode("0" + data)
+	case "code39":
+		code, err = code39.Encode(data, false, false)
+	default:
+		return nil, fmt.Errorf("simbología no soportada: %s", symbology)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error al generar el código de barras: %w", err)
+	}
+	modules := code.Bounds().Dx()
+	moduleWidth := 2
+	if modules*moduleWidth > maxDots {
+		moduleWidth = 1
+	}
+	if modules > maxDots {
+		return nil, fmt.Errorf("el código de barras (%d módulos) no entra en el ancho del papel (%d puntos)", modules, maxDots)
+	}
+	return barcode.Scale(code, modules*moduleWidth, height)
+}
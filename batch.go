@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// ============================
+// Impresión de Lotes de Documentos
+// ============================
+
+// maxBatchDocuments limita la cantidad de documentos de un lote
+const maxBatchDocuments = 50
+
+// BatchDocument es uno de los documentos de un lote: url o data (base64)
+type BatchDocument struct {
+	URL  string `json:"url"`
+	Data string `json:"data"`
+}
+
+// PrintBatchRequest es el cuerpo de POST /print-batch
+type PrintBatchRequest struct {
+	Documents  []BatchDocument `json:"documents"`
+	Printer    string          `json:"printer"`
+	WebhookURL string          `json:"webhook_url"`
+	PrintOptions
+}
+
+// mergePDFFiles une los documentos en el orden recibido en un PDF temporal; quien lo llama debe
+// eliminarlo
+func mergePDFFiles(paths []string) (string, error) {
+	out, err := os.CreateTemp("", "batch-*.pdf")
+	if err != nil {
+		return "", err
+	}
+	out.Close()
+	if err := api.MergeCreateFile(paths, out.Name(), false, model.NewDefaultConfiguration()); err != nil {
+		os.Remove(out.Name())
+		return "", fmt.Errorf("%w: no se pudieron unir los documentos: %w", ErrInvalidDocument, err)
+	}
+	return out.Name(), nil
+}
+
+// PrintBatchHandler une varios PDF en un único trabajo de impresión (POST /print-batch), para que los
+// reportes de cierre salgan juntos y en orden en lugar de intercalados con los trabajos de otras
+// cajas. Acepta JSON con documents (url o data cada uno) o multipart/form-data con varios campos file.
+func (h Handlers) PrintBatchHandler(w http.ResponseWriter, r *http.Request) {
+	h.Logger = h.Logger.ForRequest(r)
+	h.Logger.Info("Received request: /print-batch")
+
+	if r.Method != http.MethodPost {
+		h.Logger.Warnf("Método HTTP no permitido: %s", r.Method)
+		WriteErrorJSON(w, http.StatusMethodNotAllowed, "Método HTTP no permitido", nil)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, h.MaxUploadBytes*4/3+4096)
+	var req PrintBatchRequest
+	var uploads []*multipart.FileHeader
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		if err := r.ParseMultipartForm(multipartMemoryLimit); err != nil {
+			h.Logger.Warnf("Error al procesar el formulario multipart: %v", err)
+			WriteErrorJSON(w, http.StatusBadRequest, "Formulario multipart inválido o archivo demasiado grande", err)
+			return
+		}
+		defer r.MultipartForm.RemoveAll()
+		uploads = r.MultipartForm.File["file"]
+		req.Printer, req.WebhookURL = r.FormValue("printer"), r.FormValue("webhook_url")
+		opts, err := ParsePrintOptionsForm(r.FormValue)
+		if err != nil {
+			h.Logger.Warnf("Opciones de impresión inválidas: %v", err)
+			WriteErrorJSON(w, http.StatusBadRequest, "Opciones de impresión inválidas", err)
+			return
+		}
+		req.PrintOptions = opts
+	} else {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			h.Logger.Warnf("Error al decodificar JSON: %v", err)
+			WriteErrorJSON(w, http.StatusBadRequest, "Solicitud JSON inválida", err)
+			return
+		}
+		for i, doc := range req.Documents {
+			if (doc.URL == "") == (doc.Data == "") {
+				WriteErrorJSON(w, http.StatusBadRequest, "Cada documento debe indicar url o data", fmt.Errorf("documento %d", i+1))
+				return
+			}
+		}
+		if err := req.PrintOptions.Normalize(); err != nil {
+			h.Logger.Warnf("Opciones de impresión inválidas: %v", err)
+			WriteErrorJSON(w, http.StatusBadRequest, "Opciones de impresión inválidas", err)
+			return
+		}
+	}
+
+	count := len(req.Documents) + len(uploads)
+	if count == 0 || count > maxBatchDocuments {
+		h.Logger.Warnf("Cantidad de documentos inválida: %d", count)
+		WriteErrorJSON(w, http.StatusBadRequest, fmt.Sprintf("El lote debe tener entre 1 y %d documentos", maxBatchDocuments), nil)
+		return
+	}
+
+	printer, err := h.defaultPrinter(req.Printer)
+	if err != nil {
+		h.Logger.Warnf("Impresora no especificada: %v", err)
+		WriteErrorJSON(w, http.StatusBadRequest, "Impresora no especificada", err)
+		return
+	}
+
+	if err := h.Jobs.Webhooks.CheckURL(req.WebhookURL); err != nil {
+		h.Logger.Warnf("Webhook inválido: %v", err)
+		WriteErrorJSON(w, http.StatusBadRequest, "URL de webhook inválida", err)
+		return
+	}
+
+	// Los documentos se obtienen y verifican todos antes de crear el trabajo: si uno falla, no se
+	// imprime ninguno
+	paths := make([]string, 0, count)
+	defer func() {
+		for _, path := range paths {
+			os.Remove(path)
+		}
+	}()
+	for i := 0; i < count; i++ {
+		path, err := h.fetchBatchDocument(r, req, uploads, i)
+		if err != nil {
+			h.Logger.Errorf("Error al obtener el documento %d del lote: %v", i+1, err)
+			WriteErrorJSON(w, jobErrorStatus(err), fmt.Sprintf("Error al obtener el documento %d del lote", i+1), err)
+			return
+		}
+		paths = append(paths, path)
+		if _, err := inspectDocument(path, h.MaxUploadBytes); err != nil {
+			h.Logger.Warnf("Documento %d del lote inválido: %v", i+1, err)
+			WriteErrorJSON(w, jobErrorStatus(err), fmt.Sprintf("El documento %d del lote no es un PDF válido", i+1), err)
+			return
+		}
+	}
+
+	merged, err := mergePDFFiles(paths)
+	if err != nil {
+		h.Logger.Errorf("Error al unir el lote: %v", err)
+		WriteErrorJSON(w, jobErrorStatus(err), "Error al unir los documentos del lote", err)
+		return
+	}
+	defer os.Remove(merged)
+	h.Logger.Infof("Lote de %d documentos unido en %s", count, merged)
+
+	opts := req.PrintOptions
+	printer, group := h.groupPrinter(printer)
+	job := NewPrintJob(JobKindPrint, printer, fmt.Sprintf("batch:%d", count))
+	job.Group = group
+	job.RequestID = RequestID(r)
+	job.ClientCN = ClientCommonName(r)
+	job.WebhookURL = req.WebhookURL
+	job.Options = &opts
+	opts.JobID = job.ID
+	if f, err := os.Open(merged); err == nil {
+		job.SHA256 = documentSHA256(f)
+		f.Close()
+	}
+
+	// El PDF unido no sobrevive a la solicitud, por lo que estos trabajos no se retienen por falta de papel
+	err = h.Jobs.Run(job, func() error {
+		if err := h.preflight(job); err != nil {
+			return err
+		}
+		return h.Service.PrintPDFFromFile(merged, printer, opts)
+	})
+	if err != nil {
+		h.Logger.Errorf("Error al imprimir el lote: %v", err)
+		WriteJobErrorJSON(w, jobErrorStatus(err), job, "Error al imprimir el lote", err)
+		return
+	}
+
+	WriteJobJSON(w, job, fmt.Sprintf("Lote de %d documentos enviado a la impresora exitosamente.", count))
+}
+
+// fetchBatchDocument obtiene el documento i del lote (de documents o de los archivos subidos) en un
+// archivo temporal
+func (h Handlers) fetchBatchDocument(r *http.Request, req PrintBatchRequest, uploads []*multipart.FileHeader, i int) (string, error) {
+	if i < len(req.Documents) {
+		doc := req.Documents[i]
+		ctx, cancel := downloadContext(r, req.DownloadTimeout)
+		defer cancel()
+		return h.Service.FetchDocument(ctx, doc.URL, doc.Data)
+	}
+	file, err := uploads[i-len(req.Documents)].Open()
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	return saveTempFile(file)
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"os/exec"
+	"sync"
+)
+
+// ============================
+// Cancelación de Trabajos
+// ============================
+
+var (
+	// ErrJobCanceled indica que el trabajo se canceló con DELETE /jobs/{id}
+	ErrJobCanceled = errors.New("trabajo cancelado")
+	// ErrJobNotCancelable indica que el trabajo en curso no se puede interrumpir (p. ej. un envío RAW)
+	ErrJobNotCancelable = errors.New("el trabajo en curso no se puede cancelar")
+)
+
+// toolRun identifica el trabajo, la impresora y el documento de una ejecución de herramienta
+// externa, para poder detenerla y retirar el documento de la cola al cancelar el trabajo
+type toolRun struct {
+	JobID    string
+	Printer  string
+	Document string
+}
+
+// jobCancellations registra los procesos externos de cada trabajo y los trabajos cancelados
+var jobCancellations = struct {
+	sync.Mutex
+	processes map[string]*exec.Cmd
+	runs      map[string]toolRun
+	canceled  map[string]bool
+}{
+	processes: make(map[string]*exec.Cmd),
+	runs:      make(map[string]toolRun),
+	canceled:  make(map[string]bool),
+}
+
+// startJobProcess inicia el proceso y lo asocia al trabajo; si el trabajo ya fue cancelado
+// no se inicia y devuelve ErrJobCanceled
+func startJobProcess(run toolRun, cmd *exec.Cmd) error {
+	jobCancellations.Lock()
+	defer jobCancellations.Unlock()
+	if run.JobID != "" && jobCancellations.canceled[run.JobID] {
+		return ErrJobCanceled
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	if run.JobID != "" {
+		jobCancellations.processes[run.JobID] = cmd
+		jobCancellations.runs[run.JobID] = run
+	}
+	return nil
+}
+
+// endJobProcess desasocia el proceso terminado del trabajo
+func endJobProcess(run toolRun) {
+	jobCancellations.Lock()
+	defer jobCancellations.Unlock()
+	delete(jobCancellations.processes, run.JobID)
+	delete(jobCancellations.runs, run.JobID)
+}
+
+// isJobCanceled indica si se solicitó la cancelación del trabajo
+func isJobCanceled(jobID string) bool {
+	if jobID == "" {
+		return false
+	}
+	jobCancellations.Lock()
+	defer jobCancellations.Unlock()
+	return jobCancellations.canceled[jobID]
+}
+
+// clearJobCanceled olvida la cancelación de un trabajo terminado
+func clearJobCanceled(jobID string) {
+	jobCancellations.Lock()
+	defer jobCancellations.Unlock()
+	delete(jobCancellations.canceled, jobID)
+}
+
+// cancelJobProcess marca el trabajo como cancelado y termina su proceso externo, si lo tiene.
+// Devuelve la ejecución interrumpida para retirar su documento de la cola.
+func cancelJobProcess(jobID string) (toolRun, bool, error) {
+	jobCancellations.Lock()
+	defer jobCancellations.Unlock()
+	jobCancellations.canceled[jobID] = true
+	cmd, ok := jobCancellations.processes[jobID]
+	if !ok {
+		return toolRun{}, false, nil
+	}
+	return jobCancellations.runs[jobID], true, killProcessTree(cmd)
+}
+
+// Cancel cancela un trabajo: si está retenido o programado se quita de la cola; si se está ejecutando se termina
+// el proceso de impresión y se eliminan de la cola del sistema los documentos que alcanzó a enviar.
+// Devuelve el trabajo y si ya quedó cancelado (false si la cancelación está en curso).
+func (j *JobRunner) Cancel(jobID string) (*PrintJob, bool, error) {
+	if j.Holds != nil {
+		if job := j.Holds.Remove(jobID); job != nil {
+			j.jobLogger(job).Warnf("Trabajo retenido %s cancelado", jobID)
+			j.finish(job, ErrJobCanceled)
+			return job, true, nil
+		}
+	}
+	if j.Scheduler != nil {
+		if job := j.Scheduler.Remove(jobID); job != nil {
+			j.jobLogger(job).Warnf("Trabajo programado %s cancelado", jobID)
+			j.finish(job, ErrJobCanceled)
+			return job, true, nil
+		}
+	}
+
+	v, ok := runningJobs.Load(jobID)
+	if !ok {
+		return nil, false, nil
+	}
+	job := v.(*PrintJob)
+	logger := j.jobLogger(job)
+	if job.Kind != JobKindPrint {
+		return job, false, ErrJobNotCancelable
+	}
+
+	run, killed, err := cancelJobProcess(jobID)
+	if err != nil {
+		logger.Errorf("Error al terminar el proceso del trabajo %s: %v", jobID, err)
+	}
+	if !killed {
+		// El trabajo aún descarga o prepara el documento: se detiene antes de imprimir
+		logger.Warnf("Cancelación solicitada para el trabajo %s en '%s'", jobID, job.Printer)
+		return job, false, nil
+	}
+
+	logger.Warnf("Trabajo %s cancelado: se terminó el proceso de impresión en '%s'", jobID, run.Printer)
+	removed, err := removeSpooledDocument(run.Printer, run.Document)
+	if err != nil {
+		logger.Errorf("Error al retirar el documento del trabajo %s de la cola de '%s': %v", jobID, run.Printer, err)
+	} else if removed > 0 {
+		logger.Infof("Se retiraron %d documentos del trabajo %s de la cola de '%s'", removed, jobID, run.Printer)
+	}
+	return job, false, nil
+}
+
+// cancelJob atiende DELETE /jobs/{id}
+func (h Handlers) cancelJob(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	job, canceled, err := h.Jobs.Cancel(id)
+	if errors.Is(err, ErrJobNotCancelable) {
+		WriteJobErrorJSON(w, http.StatusConflict, job, "El trabajo no se puede cancelar", err)
+		return
+	}
+	if job == nil {
+		// No está en ejecución ni retenido: ya terminó o no existe
+		finished, err := h.History.Get(id)
+		if err != nil {
+			WriteErrorJSON(w, http.StatusInternalServerError, "Error al consultar el historial", err)
+			return
+		}
+		if finished == nil {
+			WriteErrorJSON(w, http.StatusNotFound, "Trabajo no encontrado", nil)
+			return
+		}
+		WriteJobErrorJSON(w, http.StatusConflict, finished, "El trabajo ya terminó", nil)
+		return
+	}
+
+	if canceled {
+		WriteJSON(w, http.StatusOK, map[string]interface{}{
+			"message": "Trabajo cancelado.",
+			"job_id":  job.ID,
+			"status":  job.Status,
+		})
+		return
+	}
+	WriteJSON(w, http.StatusAccepted, map[string]interface{}{
+		"message": "Cancelación en curso: el trabajo se detendrá y se retirará de la cola de impresión.",
+		"job_id":  job.ID,
+	})
+}
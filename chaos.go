@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// ============================
+// Modo Caos (inyección de fallas para QA)
+// ============================
+
+// ChaosConfig define las fallas artificiales que se inyectan cuando el modo caos está activo.
+// Nunca debe habilitarse en producción: está pensado para que los desarrolladores del ERP
+// prueben sus flujos de reintento y mensajes al usuario.
+type ChaosConfig struct {
+	Enabled           bool
+	DownloadLatencyMs int
+	PrintFailureRate  float64
+	OfflineRate       float64
+	OfflinePrinters   []string
+}
+
+// LoadChaosConfig carga la configuración del modo caos desde variables de entorno
+func LoadChaosConfig() ChaosConfig {
+	return ChaosConfig{
+		Enabled:           getEnvAsBool("CHAOS_MODE", false),
+		DownloadLatencyMs: getEnvAsInt("CHAOS_DOWNLOAD_LATENCY_MS", 0),
+		PrintFailureRate:  getEnvAsFloat("CHAOS_PRINT_FAILURE_RATE", 0),
+		OfflineRate:       getEnvAsFloat("CHAOS_OFFLINE_RATE", 0),
+		OfflinePrinters:   getEnvAsSlice("CHAOS_OFFLINE_PRINTERS", ""),
+	}
+}
+
+// isOffline determina si la impresora debe simularse fuera de línea
+func (c ChaosConfig) isOffline(printer string) bool {
+	for _, p := range c.OfflinePrinters {
+		if strings.EqualFold(p, printer) {
+			return true
+		}
+	}
+	return chance(c.OfflineRate)
+}
+
+// chance devuelve true con la probabilidad indicada (0.0 a 1.0)
+func chance(rate float64) bool {
+	return rate > 0 && rand.Float64() < rate
+}
+
+// NewChaosComponents envuelve los componentes reales con sus variantes de caos
+func NewChaosComponents(cfg ChaosConfig, pm PrinterManager, dp DocumentPrinter, dl Downloader, logger *Logger) (PrinterManager, DocumentPrinter, Downloader) {
+	return ChaosPrinterManager{Next: pm, Config: cfg, Logger: logger},
+		ChaosDocumentPrinter{Next: dp, Config: cfg, Logger: logger},
+		ChaosDownloader{Next: dl, Config: cfg, Logger: logger}
+}
+
+// ChaosPrinterManager simula impresoras fuera de línea
+type ChaosPrinterManager struct {
+	Next   PrinterManager
+	Config ChaosConfig
+	Logger *Logger
+}
+
+// ListPrinters delega en el PrinterManager real
+func (c ChaosPrinterManager) ListPrinters() ([]string, error) {
+	return c.Next.ListPrinters()
+}
+
+// PrinterExists falla como si la impresora estuviera fuera de línea según la configuración
+func (c ChaosPrinterManager) PrinterExists(name string) (bool, error) {
+	if c.Config.isOffline(name) {
+		c.Logger.Warnf("[CAOS] Simulando impresora '%s' fuera de línea", name)
+		return false, fmt.Errorf("la impresora '%s' está fuera de línea (simulado)", name)
+	}
+	return c.Next.PrinterExists(name)
+}
+
+// DefaultPrinter delega en el administrador real
+func (c ChaosPrinterManager) DefaultPrinter() (string, error) {
+	return c.Next.DefaultPrinter()
+}
+
+// GetPrinterStatus reporta fuera de línea las impresoras configuradas como tales
+func (c ChaosPrinterManager) GetPrinterStatus(name string) (*PrinterStatus, error) {
+	status, err := c.Next.GetPrinterStatus(name)
+	if err != nil || !c.Config.isOffline(name) {
+		return status, err
+	}
+	c.Logger.Warnf("[CAOS] Simulando impresora '%s' fuera de línea", name)
+	status.Online = false
+	status.Status = "Offline"
+	status.States = append([]string{"Offline"}, status.States...)
+	status.finish()
+	return status, nil
+}
+
+// ChaosDocumentPrinter simula fallas aleatorias de impresión
+type ChaosDocumentPrinter struct {
+	Next   DocumentPrinter
+	Config ChaosConfig
+	Logger *Logger
+}
+
+// PrintFile falla aleatoriamente según PrintFailureRate antes de delegar en la impresora real
+func (c ChaosDocumentPrinter) PrintFile(filePath, printer string, opts PrintOptions) error {
+	if chance(c.Config.PrintFailureRate) {
+		c.Logger.Warnf("[CAOS] Simulando falla de impresión en '%s'", printer)
+		return fmt.Errorf("falla de impresión simulada en '%s'", printer)
+	}
+	return c.Next.PrintFile(filePath, printer, opts)
+}
+
+// ChaosDownloader agrega latencia artificial a las descargas
+type ChaosDownloader struct {
+	Next   Downloader
+	Config ChaosConfig
+	Logger *Logger
+}
+
+// Download espera la latencia configurada antes de delegar en el descargador real
+func (c ChaosDownloader) Download(ctx context.Context, fileURL string) (string, error) {
+	if c.Config.DownloadLatencyMs > 0 {
+		c.Logger.Warnf("[CAOS] Agregando %dms de latencia a la descarga", c.Config.DownloadLatencyMs)
+		time.Sleep(time.Duration(c.Config.DownloadLatencyMs) * time.Millisecond)
+	}
+	return c.Next.Download(ctx, fileURL)
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ============================
+// Comandos Administrativos (CLI)
+// ============================
+
+const cliUsage = `Uso: PrinterMatiasERP.exe [--config <archivo>] [comando]
+
+Sin comando inicia el servidor de impresión. --config indica el archivo de configuración
+(por defecto, config.yaml junto al ejecutable).
+
+Comandos:
+  firewall add      Crea la regla de entrada del firewall para el puerto configurado
+  firewall remove   Elimina la regla de entrada del firewall
+  service install   Instala el agente como servicio de Windows (inicio automático y reinicio ante fallas)
+  service uninstall Detiene y elimina el servicio de Windows
+  service start     Inicia el servicio de Windows
+  service stop      Detiene el servicio de Windows
+  license activate <token>  Verifica y guarda la licencia que vincula el agente al punto de venta
+  license show      Muestra la licencia activa
+  profile list      Muestra los perfiles de configuración y el perfil activo
+  profile use <nombre>  Selecciona el perfil con el que iniciará el servidor (vacío para la configuración general)
+  tool hash <archivo>  Muestra el SHA-256 de un ejecutable para configurarlo en TOOL_HASHES
+  tls export-ca <archivo>  Genera (si falta) el certificado autofirmado y copia la CA local para importarla en los navegadores
+  tls trust         Instala la CA local como raíz de confianza del usuario (Windows) o del sistema (Linux)
+  version           Muestra la versión del agente
+`
+
+// runCLI ejecuta un comando administrativo y devuelve el código de salida del proceso
+func runCLI(cfg Config, args []string) int {
+	switch args[0] {
+	case "firewall":
+		return runFirewallCommand(cfg, args[1:])
+	case "service":
+		return runServiceCommand(cfg, args[1:])
+	case "license":
+		return runLicenseCommand(cfg, args[1:])
+	case "profile":
+		return runProfileCommand(cfg, args[1:])
+	case "tool":
+		return runToolCommand(args[1:])
+	case "tls":
+		return runTLSCommand(cfg, args[1:])
+	case "version":
+		fmt.Println(agentVersion)
+		return 0
+	case "help", "-h", "--help":
+		fmt.Print(cliUsage)
+		return 0
+	default:
+		fmt.Fprintf(os.Stderr, "Comando desconocido: %s\n\n%s", args[0], cliUsage)
+		return 2
+	}
+}
+
+func runFirewallCommand(cfg Config, args []string) int {
+	if len(args) != 1 {
+		fmt.Fprint(os.Stderr, cliUsage)
+		return 2
+	}
+
+	var err error
+	switch args[0] {
+	case "add":
+		err = AddFirewallRule(cfg.Port)
+	case "remove":
+		err = RemoveFirewallRule()
+	default:
+		fmt.Fprintf(os.Stderr, "Subcomando de firewall desconocido: %s\n\n%s", args[0], cliUsage)
+		return 2
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Regla de firewall '%s' actualizada (%s, puerto %d)\n", firewallRuleName, args[0], cfg.Port)
+	return 0
+}
+
+func runServiceCommand(cfg Config, args []string) int {
+	if len(args) != 1 {
+		fmt.Fprint(os.Stderr, cliUsage)
+		return 2
+	}
+
+	var err error
+	switch args[0] {
+	case "install":
+		err = InstallService(cfg)
+	case "uninstall":
+		err = UninstallService()
+	case "start":
+		err = StartService()
+	case "stop":
+		err = StopService()
+	default:
+		fmt.Fprintf(os.Stderr, "Subcomando de servicio desconocido: %s\n\n%s", args[0], cliUsage)
+		return 2
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Servicio '%s': %s completado\n", serviceName, args[0])
+	return 0
+}
+
+func runLicenseCommand(cfg Config, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, cliUsage)
+		return 2
+	}
+
+	licenses, err := NewLicenseManager(cfg.License, newAppLogger(cfg))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	var lic *License
+	switch {
+	case args[0] == "activate" && len(args) == 2:
+		lic, err = licenses.Activate(args[1])
+	case args[0] == "show" && len(args) == 1:
+		if err = licenses.Load(); err == nil && licenses.Enabled() {
+			lic, err = licenses.Current()
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "Subcomando de licencia desconocido: %s\n\n%s", strings.Join(args, " "), cliUsage)
+		return 2
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if lic == nil {
+		fmt.Println("Esta compilación no requiere licencia")
+		return 0
+	}
+	fmt.Printf("Licencia %s: cliente %s, punto de venta %s", lic.LicenseID, lic.CustomerID, lic.StoreID)
+	if !lic.ExpiresAt.IsZero() {
+		fmt.Printf(", vence %s", lic.ExpiresAt.Format("2006-01-02"))
+	}
+	fmt.Println()
+	return 0
+}
+
+func runProfileCommand(cfg Config, args []string) int {
+	switch {
+	case len(args) == 1 && args[0] == "list":
+		for _, p := range cfg.Profiles {
+			marker := " "
+			if strings.EqualFold(p, cfg.Profile) {
+				marker = "*"
+			}
+			fmt.Printf("%s %s\n", marker, p)
+		}
+		return 0
+	case len(args) >= 1 && len(args) <= 2 && args[0] == "use":
+		name := ""
+		if len(args) == 2 {
+			name = args[1]
+		}
+		if err := SaveActiveProfile(name, cfg.Profiles); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		fmt.Printf("Perfil '%s' seleccionado; reinicie el servidor o el servicio para aplicarlo\n", name)
+		return 0
+	default:
+		fmt.Fprint(os.Stderr, cliUsage)
+		return 2
+	}
+}
+
+func runToolCommand(args []string) int {
+	if len(args) != 2 || args[0] != "hash" {
+		fmt.Fprint(os.Stderr, cliUsage)
+		return 2
+	}
+	hash, err := FileSHA256(args[1])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	fmt.Printf("%s=%s\n", filepath.Base(args[1]), hash)
+	return 0
+}
+
+func runTLSCommand(cfg Config, args []string) int {
+	tls := SelfSignedTLS{Dir: cfg.TLSDir}
+	if _, err := tls.Ensure(selfSignedHosts(cfg)); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	var err error
+	switch {
+	case len(args) == 2 && args[0] == "export-ca":
+		var data []byte
+		if data, err = os.ReadFile(tls.CAPath()); err == nil {
+			err = os.WriteFile(args[1], data, 0o644)
+		}
+	case len(args) == 1 && args[0] == "trust":
+		err = TrustCA(tls.CAPath())
+	default:
+		fmt.Fprint(os.Stderr, cliUsage)
+		return 2
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	fmt.Printf("CA local (%s): %s completado\n", tls.CAPath(), args[0])
+	return 0
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// ============================
+// Interfaces COM (XPS, WMI)
+// ============================
+
+// Métodos de IUnknown, por su posición en la tabla virtual
+const (
+	comQueryInterface = 0
+	comRelease        = 2
+)
+
+// comObject es un puntero a una interfaz COM: su primer campo es la tabla virtual. El tamaño del
+// arreglo alcanza para la interfaz más larga que se usa (IWbemServices).
+type comObject struct {
+	vtbl *[32]uintptr
+}
+
+// call invoca el método de la tabla virtual con el objeto como primer argumento
+func (o *comObject) call(method int, args ...uintptr) uintptr {
+	r1, _, _ := syscall.SyscallN(o.vtbl[method], append([]uintptr{uintptr(unsafe.Pointer(o))}, args...)...)
+	return r1
+}
+
+// release libera la referencia al objeto
+func (o *comObject) release() {
+	if o != nil {
+		o.call(comRelease)
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// ============================
+// Concurrencia: Serialización por Impresora
+// ============================
+
+// PrinterLocks serializa los envíos a cada impresora: las térmicas mezclan los trabajos que
+// reciben a la vez. Impresoras distintas imprimen en paralelo.
+type PrinterLocks struct {
+	// Wait es la espera máxima por una impresora ocupada (0 espera indefinidamente)
+	Wait time.Duration
+
+	mu    sync.Mutex
+	slots map[string]chan struct{}
+}
+
+// NewPrinterLocks crea el registro de impresoras con la espera máxima indicada
+func NewPrinterLocks(waitSeconds int) *PrinterLocks {
+	return &PrinterLocks{
+		Wait:  time.Duration(waitSeconds) * time.Second,
+		slots: make(map[string]chan struct{}),
+	}
+}
+
+// slot devuelve el canal que actúa como candado de la impresora
+func (l *PrinterLocks) slot(printer string) chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s, ok := l.slots[printer]
+	if !ok {
+		s = make(chan struct{}, 1)
+		l.slots[printer] = s
+	}
+	return s
+}
+
+// Do ejecuta fn con la impresora reservada, esperando a que terminen los envíos anteriores
+func (l *PrinterLocks) Do(printer string, fn func() error) error {
+	s := l.slot(printer)
+	if l.Wait <= 0 {
+		s <- struct{}{}
+	} else {
+		timer := time.NewTimer(l.Wait)
+		select {
+		case s <- struct{}{}:
+			timer.Stop()
+		case <-timer.C:
+			return fmt.Errorf("la impresora '%s' sigue ocupada con otro trabajo después de %s", printer, l.Wait)
+		}
+	}
+	defer func() { <-s }()
+	return fn()
+}
+
+// SerializedDocumentPrinter envía los documentos de a uno por impresora
+type SerializedDocumentPrinter struct {
+	Next  DocumentPrinter
+	Locks *PrinterLocks
+}
+
+// PrintFile imprime el archivo cuando la impresora queda libre
+func (s SerializedDocumentPrinter) PrintFile(filePath, printer string, opts PrintOptions) error {
+	return s.Locks.Do(printer, func() error {
+		return s.Next.PrintFile(filePath, printer, opts)
+	})
+}
+
+// SerializedRawWriter envía los datos RAW de a uno por impresora, compartiendo el candado con los
+// documentos para que un recibo no se mezcle con un PDF en curso
+type SerializedRawWriter struct {
+	Next  RawWriter
+	Locks *PrinterLocks
+}
+
+// WriteRaw envía los datos cuando la impresora queda libre
+func (s SerializedRawWriter) WriteRaw(printer string, data []byte) error {
+	return s.Locks.Do(printer, func() error {
+		return s.Next.WriteRaw(printer, data)
+	})
+}
+
+// SerializedDrawerOpener abre el cajón cuando la impresora queda libre
+type SerializedDrawerOpener struct {
+	Next  DrawerOpener
+	Locks *PrinterLocks
+}
+
+// OpenDrawer abre el cajón cuando la impresora queda libre
+func (s SerializedDrawerOpener) OpenDrawer(printerName string, opts DrawerOptions) error {
+	return s.Locks.Do(printerName, func() error {
+		return s.Next.OpenDrawer(printerName, opts)
+	})
+}
+
+// processSlots limita la cantidad de herramientas externas (PDFtoPrinter, SumatraPDF, ...) en
+// ejecución simultánea en todo el agente; nil no limita
+var processSlots chan struct{}
+
+// setProcessLimit configura el límite de procesos externos simultáneos (0 o menos no limita)
+func setProcessLimit(limit int) {
+	if limit <= 0 {
+		processSlots = nil
+		return
+	}
+	processSlots = make(chan struct{}, limit)
+}
+
+// acquireProcessSlot espera un lugar para iniciar una herramienta externa y devuelve la función que lo libera
+func acquireProcessSlot() func() {
+	slots := processSlots
+	if slots == nil {
+		return func() {}
+	}
+	slots <- struct{}{}
+	return func() { <-slots }
+}
+
+// ErrToolTimeout indica que la herramienta externa superó JOB_TIMEOUT_SECONDS y se terminó
+var ErrToolTimeout = fmt.Errorf("la herramienta externa superó el tiempo máximo del trabajo: %w", context.DeadlineExceeded)
+
+// toolTimeout es el tiempo máximo de cada herramienta externa; 0 no limita
+var toolTimeout time.Duration
+
+// setToolTimeout configura el tiempo máximo de las herramientas externas (0 o menos no limita)
+func setToolTimeout(seconds int) {
+	toolTimeout = max(time.Duration(seconds)*time.Second, 0)
+}
+
+// toolCommand crea el comando de una herramienta externa limitado por JOB_TIMEOUT_SECONDS: al
+// vencer el plazo se termina el proceso junto con los que haya lanzado. Se debe llamar a cancel
+// después de Wait; timedOut indica si el proceso se terminó por el plazo.
+func toolCommand(name string, args ...string) (cmd *exec.Cmd, timedOut func() bool, cancel context.CancelFunc) {
+	ctx, cancel := context.Background(), context.CancelFunc(func() {})
+	if toolTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, toolTimeout)
+	}
+	cmd = exec.CommandContext(ctx, name, args...)
+	prepareProcessTree(cmd)
+	cmd.Cancel = func() error { return killProcessTree(cmd) }
+	// Los procesos hijos pueden conservar abiertas la salida y los errores del proceso terminado
+	cmd.WaitDelay = 5 * time.Second
+	timedOut = func() bool { return errors.Is(ctx.Err(), context.DeadlineExceeded) }
+	return cmd, timedOut, cancel
+}
+
+// toolTimeoutError informa la herramienta que superó el plazo; no se reintenta porque el documento
+// pudo haber llegado a la cola antes de que se colgara
+func toolTimeoutError(label string) error {
+	return permanent(fmt.Errorf("%s no terminó en %s: %w", label, toolTimeout, ErrToolTimeout))
+}
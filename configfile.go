@@ -0,0 +1,300 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ============================
+// Archivo de Configuración (config.yaml)
+// ============================
+
+// defaultConfigFileName se busca junto al ejecutable cuando no se indica --config ni CONFIG_FILE
+const defaultConfigFileName = "config.yaml"
+
+// configFlagPath es la ruta indicada con --config en la línea de comandos
+var configFlagPath string
+
+// configFile es el archivo cargado por LoadConfig; lookupEnv lo consulta cuando la variable
+// de entorno no está definida
+var configFile ConfigFile
+
+// ConfigFile contiene los valores del archivo con las mismas claves que las variables de entorno
+type ConfigFile struct {
+	Path     string
+	Values   map[string]string
+	Profiles map[string]map[string]string
+	Err      error
+}
+
+// PrinterSettings son las opciones por impresora de la sección printers
+type PrinterSettings struct {
+	Address     string   `yaml:"address"`
+	Engine      string   `yaml:"engine"`
+	StatusCheck bool     `yaml:"status_check"`
+	Aliases     []string `yaml:"aliases"`
+	Groups      []string `yaml:"groups"`
+	Network     bool     `yaml:"network"`
+	IPP         string   `yaml:"ipp"`
+	RasterCodes bool     `yaml:"raster_codes"`
+	Dialect     string   `yaml:"dialect"`
+	Type        string   `yaml:"type"`
+	Codepage    string   `yaml:"codepage"`
+	WidthMM     int      `yaml:"width_mm"`
+	DrawerKick  string   `yaml:"drawer_kick"`
+	Cut         string   `yaml:"cut"`
+	Copies      int      `yaml:"copies"`
+	CopyLabel   string   `yaml:"copy_label"`
+	Tray        string   `yaml:"tray"`
+	Serial      string   `yaml:"serial"`
+	DrawerPins  []int    `yaml:"drawer_pins"`
+	Kitchen     []string `yaml:"kitchen"`
+}
+
+// extractConfigFlag quita "--config <ruta>" o "--config=<ruta>" de los argumentos y guarda la ruta
+func extractConfigFlag(args []string) []string {
+	result := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--config" && i+1 < len(args):
+			configFlagPath = args[i+1]
+			i++
+		case strings.HasPrefix(arg, "--config="):
+			configFlagPath = strings.TrimPrefix(arg, "--config=")
+		default:
+			result = append(result, arg)
+		}
+	}
+	if configFlagPath != "" {
+		if abs, err := filepath.Abs(configFlagPath); err == nil {
+			configFlagPath = abs
+		}
+	}
+	return result
+}
+
+// configFilePath devuelve el archivo a cargar y si fue indicado explícitamente
+func configFilePath() (string, bool) {
+	if configFlagPath != "" {
+		return configFlagPath, true
+	}
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		return path, true
+	}
+	if exe, err := os.Executable(); err == nil {
+		return filepath.Join(filepath.Dir(exe), defaultConfigFileName), false
+	}
+	return defaultConfigFileName, false
+}
+
+// loadConfigFile lee el archivo de configuración. Las claves son los nombres de las variables de
+// entorno en minúsculas (port, pdf_printer_path, ...); además admite las secciones profiles
+// (valores por perfil) y printers (opciones por impresora). Si el archivo predeterminado no
+// existe no hay configuración de archivo; si se indicó una ruta, debe existir.
+func loadConfigFile() ConfigFile {
+	path, explicit := configFilePath()
+	cf := ConfigFile{Path: path, Values: map[string]string{}, Profiles: map[string]map[string]string{}}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if explicit || !os.IsNotExist(err) {
+			cf.Err = fmt.Errorf("error al leer el archivo de configuración '%s': %w", path, err)
+		}
+		cf.Path = ""
+		return cf
+	}
+
+	var raw map[string]interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		cf.Err = fmt.Errorf("archivo de configuración inválido '%s': %w", path, err)
+		return cf
+	}
+	var sections struct {
+		Profiles map[string]map[string]interface{} `yaml:"profiles"`
+		Printers map[string]PrinterSettings        `yaml:"printers"`
+	}
+	if err := yaml.Unmarshal(data, &sections); err != nil {
+		cf.Err = fmt.Errorf("secciones inválidas en '%s': %w", path, err)
+		return cf
+	}
+
+	for key, val := range raw {
+		if key == "profiles" || key == "printers" {
+			continue
+		}
+		cf.Values[configEnvKey(key)] = configValueString(val)
+	}
+	for profile, values := range sections.Profiles {
+		converted := make(map[string]string, len(values))
+		for key, val := range values {
+			converted[configEnvKey(key)] = configValueString(val)
+		}
+		cf.Profiles[strings.ToLower(profile)] = converted
+	}
+	cf.applyPrinters(sections.Printers)
+	return cf
+}
+
+// applyPrinters traduce la sección printers a las variables equivalentes (PRINTER_ADDRESSES,
+// NETWORK_PRINTERS, IPP_PRINTERS, PRINTER_ENGINES, STATUS_CHECK_PRINTERS, PRINTER_ALIASES,
+// PRINTER_GROUPS, KITCHEN_ROUTES y las del perfil de impresora), salvo que el archivo ya las defina
+func (cf ConfigFile) applyPrinters(printers map[string]PrinterSettings) {
+	names := make([]string, 0, len(printers))
+	for name := range printers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var addresses, network, ipp, engines, checked, aliases, raster, dialects []string
+	profiles := map[string][]string{}
+	groups := map[string][]string{}
+	var groupNames []string
+	for _, name := range names {
+		p := printers[name]
+		if p.Address != "" && p.Network {
+			network = append(network, name+"="+p.Address)
+		} else if p.Address != "" {
+			addresses = append(addresses, name+"="+p.Address)
+		}
+		if p.IPP != "" {
+			ipp = append(ipp, name+"="+p.IPP)
+		}
+		if p.Engine != "" {
+			engines = append(engines, name+"="+p.Engine)
+		}
+		if p.StatusCheck {
+			checked = append(checked, name)
+		}
+		if p.Dialect != "" {
+			dialects = append(dialects, name+"="+p.Dialect)
+		}
+		for key, value := range map[string]string{
+			"KITCHEN_ROUTES":         strings.Join(p.Kitchen, "|"),
+			"PRINTER_TYPES":          p.Type,
+			"PRINTER_CODEPAGES":      p.Codepage,
+			"RECEIPT_PRINTER_WIDTHS": intSetting(p.WidthMM),
+			"PRINTER_DRAWER_KICKS":   p.DrawerKick,
+			"PRINTER_CUTS":           p.Cut,
+			"PRINTER_COPIES":         intSetting(p.Copies),
+			"PRINTER_COPY_LABELS":    p.CopyLabel,
+			"PRINTER_TRAYS":          p.Tray,
+			"PRINTER_SERIAL_PORTS":   p.Serial,
+			"PRINTER_DRAWER_PINS":    intsSetting(p.DrawerPins),
+		} {
+			if value != "" {
+				profiles[key] = append(profiles[key], name+"="+value)
+			}
+		}
+		if p.RasterCodes {
+			raster = append(raster, name)
+		}
+		for _, alias := range p.Aliases {
+			aliases = append(aliases, alias+"="+name)
+		}
+		for _, group := range p.Groups {
+			if _, ok := groups[group]; !ok {
+				groupNames = append(groupNames, group)
+			}
+			groups[group] = append(groups[group], name)
+		}
+	}
+	var groupEntries []string
+	for _, group := range groupNames {
+		groupEntries = append(groupEntries, group+"="+strings.Join(groups[group], "|"))
+	}
+	setDefault := func(key string, values []string) {
+		if _, ok := cf.Values[key]; !ok && len(values) > 0 {
+			cf.Values[key] = strings.Join(values, ",")
+		}
+	}
+	setDefault("PRINTER_ADDRESSES", addresses)
+	setDefault("NETWORK_PRINTERS", network)
+	setDefault("IPP_PRINTERS", ipp)
+	setDefault("PRINTER_ENGINES", engines)
+	setDefault("STATUS_CHECK_PRINTERS", checked)
+	setDefault("PRINTER_ALIASES", aliases)
+	setDefault("RECEIPT_RASTER_CODES", raster)
+	setDefault("PRINTER_DIALECTS", dialects)
+	setDefault("PRINTER_GROUPS", groupEntries)
+	for key, values := range profiles {
+		setDefault(key, values)
+	}
+}
+
+// intSetting convierte un valor numérico de la sección printers; 0 significa que no se indicó
+func intSetting(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
+
+// intsSetting convierte una lista numérica de la sección printers al formato a|b de las variables
+func intsSetting(values []int) string {
+	parts := make([]string, len(values))
+	for i, n := range values {
+		parts[i] = strconv.Itoa(n)
+	}
+	return strings.Join(parts, "|")
+}
+
+// Lookup busca la clave en la sección del perfil y luego en los valores generales del archivo
+func (cf ConfigFile) Lookup(profile, key string) (string, bool) {
+	if profile != "" {
+		if val, ok := cf.Profiles[strings.ToLower(profile)][key]; ok {
+			return val, true
+		}
+	}
+	val, ok := cf.Values[key]
+	return val, ok
+}
+
+// ProfileNames devuelve los perfiles declarados en la sección profiles
+func (cf ConfigFile) ProfileNames() []string {
+	names := make([]string, 0, len(cf.Profiles))
+	for name := range cf.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// configEnvKey convierte una clave del archivo (p. ej. "pdf-printer_path") en el nombre de la variable
+func configEnvKey(key string) string {
+	return strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(key), "-", "_"))
+}
+
+// configValueString convierte un valor YAML al formato de las variables de entorno: las listas
+// se separan con comas y los mapas se escriben como "clave=valor"
+func configValueString(val interface{}) string {
+	switch v := val.(type) {
+	case nil:
+		return ""
+	case []interface{}:
+		parts := make([]string, 0, len(v))
+		for _, item := range v {
+			parts = append(parts, configValueString(item))
+		}
+		return strings.Join(parts, ",")
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		parts := make([]string, 0, len(v))
+		for _, k := range keys {
+			parts = append(parts, k+"="+configValueString(v[k]))
+		}
+		return strings.Join(parts, ",")
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ============================
+// Reportes de Fallas (panics y salidas anormales)
+// ============================
+
+// Límites del contenido de un reporte
+const (
+	crashLogTailLines = 200
+	crashLogTailBytes = 64 << 10
+	crashStackBytes   = 1 << 20
+)
+
+// crashStderrFile recibe la salida de errores del proceso (p. ej. "fatal error" del runtime)
+// cuando se ejecuta como servicio; se revisa en el siguiente inicio.
+const crashStderrFile = "stderr.log"
+
+// CrashReport es el diagnóstico guardado al fallar el proceso
+type CrashReport struct {
+	Time           time.Time   `json:"time"`
+	Reason         string      `json:"reason"`
+	Fatal          bool        `json:"fatal"`
+	Profile        string      `json:"profile,omitempty"`
+	GoVersion      string      `json:"go_version"`
+	Stack          string      `json:"stack"`
+	JobsInProgress []*PrintJob `json:"jobs_in_progress"`
+	LogTail        []string    `json:"log_tail"`
+}
+
+// CrashReporter escribe los reportes en el directorio de fallas y los envía en el siguiente inicio
+type CrashReporter struct {
+	Dir       string
+	LogFile   string
+	UploadURL string
+	Profile   string
+	// Outbound son el proxy y los certificados con los que se envían los reportes
+	Outbound OutboundConfig
+	Logger   *Logger
+}
+
+// crashReporter es el reportero del proceso; nil hasta que se llama a initCrashReporter
+var crashReporter *CrashReporter
+
+// runningJobs registra los trabajos en curso para incluirlos en los reportes
+var runningJobs sync.Map
+
+func trackJob(job *PrintJob)   { runningJobs.Store(job.ID, job) }
+func untrackJob(job *PrintJob) { runningJobs.Delete(job.ID) }
+
+// initCrashReporter prepara el directorio de fallas y procesa los reportes del inicio anterior
+func initCrashReporter(cfg Config, logger *Logger) {
+	r := &CrashReporter{
+		Dir:       cfg.CrashDir,
+		LogFile:   cfg.LogFile,
+		UploadURL: cfg.CrashReportURL,
+		Profile:   cfg.Profile,
+		Outbound:  cfg.Outbound,
+		Logger:    logger,
+	}
+	if err := os.MkdirAll(r.Dir, 0o755); err != nil {
+		logger.Errorf("No se pudo crear el directorio de reportes de fallas: %v", err)
+		return
+	}
+	crashReporter = r
+	r.collectStderr()
+	r.ReportPending()
+}
+
+// recoverCrash se usa con defer en las goroutines del agente: guarda el reporte y termina el proceso
+// con código distinto de cero para que el servicio se reinicie.
+func recoverCrash() {
+	if v := recover(); v != nil {
+		if crashReporter != nil {
+			crashReporter.Write(fmt.Sprintf("panic: %v", v), true)
+		}
+		fmt.Fprintf(os.Stderr, "panic: %v\n", v)
+		os.Exit(2)
+	}
+}
+
+// Write guarda un reporte con las pilas de todas las goroutines, los trabajos en curso y el final del log
+func (r *CrashReporter) Write(reason string, fatal bool) string {
+	stack := make([]byte, crashStackBytes)
+	stack = stack[:runtime.Stack(stack, true)]
+
+	report := CrashReport{
+		Time:           time.Now(),
+		Reason:         reason,
+		Fatal:          fatal,
+		Profile:        r.Profile,
+		GoVersion:      runtime.Version(),
+		Stack:          string(stack),
+		JobsInProgress: []*PrintJob{},
+		LogTail:        tailLines(r.LogFile, crashLogTailLines),
+	}
+	runningJobs.Range(func(_, v interface{}) bool {
+		report.JobsInProgress = append(report.JobsInProgress, v.(*PrintJob))
+		return true
+	})
+	return r.save(report)
+}
+
+func (r *CrashReporter) save(report CrashReport) string {
+	name := fmt.Sprintf("crash-%s.json", report.Time.Format("20060102-150405.000"))
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err == nil {
+		err = os.WriteFile(filepath.Join(r.Dir, name), data, 0o644)
+	}
+	if err != nil {
+		r.Logger.Errorf("No se pudo guardar el reporte de falla: %v", err)
+		return ""
+	}
+	r.Logger.Errorf("Reporte de falla guardado en %s: %s", filepath.Join(r.Dir, name), report.Reason)
+	return name
+}
+
+// collectStderr convierte en reporte la salida de errores de la ejecución anterior si contiene una falla
+func (r *CrashReporter) collectStderr() {
+	path := filepath.Join(r.Dir, crashStderrFile)
+	info, err := os.Stat(path)
+	if err != nil || info.Size() == 0 {
+		return
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	os.Truncate(path, 0)
+
+	text := string(data)
+	idx := strings.Index(text, "panic:")
+	if i := strings.Index(text, "fatal error:"); i >= 0 && (idx < 0 || i < idx) {
+		idx = i
+	}
+	if idx < 0 {
+		return
+	}
+	reason, _, _ := strings.Cut(text[idx:], "\n")
+	r.save(CrashReport{
+		Time:           info.ModTime(),
+		Reason:         reason,
+		Fatal:          true,
+		Profile:        r.Profile,
+		GoVersion:      runtime.Version(),
+		Stack:          text[idx:],
+		JobsInProgress: []*PrintJob{},
+		LogTail:        tailLines(r.LogFile, crashLogTailLines),
+	})
+}
+
+// Pending devuelve los reportes que aún no han sido enviados
+func (r *CrashReporter) Pending() []string {
+	names, _ := filepath.Glob(filepath.Join(r.Dir, "crash-*.json"))
+	for i := range names {
+		names[i] = filepath.Base(names[i])
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ReportPending avisa de los reportes del inicio anterior y los envía si hay CRASH_REPORT_URL
+func (r *CrashReporter) ReportPending() {
+	pending := r.Pending()
+	if len(pending) == 0 {
+		return
+	}
+	r.Logger.Warnf("Se encontraron %d reportes de falla de ejecuciones anteriores en %s", len(pending), r.Dir)
+	if r.UploadURL == "" {
+		return
+	}
+
+	go func() {
+		defer recoverCrash()
+		transport := &http.Transport{}
+		if err := r.Outbound.apply(transport); err != nil {
+			r.Logger.Errorf("No se pudieron enviar los reportes de falla: %v", err)
+			return
+		}
+		client := &http.Client{Timeout: 30 * time.Second, Transport: transport}
+		for _, name := range pending {
+			if err := r.upload(client, name); err != nil {
+				r.Logger.Errorf("No se pudo enviar el reporte de falla %s: %v", name, err)
+				return
+			}
+			r.Logger.Infof("Reporte de falla %s enviado", name)
+		}
+	}()
+}
+
+// upload envía el reporte y lo marca como enviado (.sent) para no repetirlo
+func (r *CrashReporter) upload(client *http.Client, name string) error {
+	path := filepath.Join(r.Dir, name)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Post(r.UploadURL, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("respuesta HTTP %d", resp.StatusCode)
+	}
+	return os.Rename(path, path+".sent")
+}
+
+// tailLines devuelve las últimas n líneas de un archivo de texto
+func tailLines(path string, n int) []string {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	if info, err := f.Stat(); err == nil && info.Size() > crashLogTailBytes {
+		f.Seek(-crashLogTailBytes, io.SeekEnd)
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil
+	}
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines
+}
+
+// CrashReportsHandler lista los reportes de falla (GET /admin/crash-reports)
+func (r *CrashReporter) CrashReportsHandler(w http.ResponseWriter, req *http.Request) {
+	r.Logger.ForRequest(req).Info("Received request: /admin/crash-reports")
+
+	if req.Method != http.MethodGet {
+		WriteErrorJSON(w, http.StatusMethodNotAllowed, "Método HTTP no permitido", nil)
+		return
+	}
+	names, _ := filepath.Glob(filepath.Join(r.Dir, "crash-*.json*"))
+	reports := make([]map[string]interface{}, 0, len(names))
+	for _, path := range names {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		name := filepath.Base(path)
+		reports = append(reports, map[string]interface{}{
+			"name": strings.TrimSuffix(name, ".sent"),
+			"time": info.ModTime(),
+			"sent": strings.HasSuffix(name, ".sent"),
+			"size": info.Size(),
+		})
+	}
+	WriteJSON(w, http.StatusOK, map[string]interface{}{"reports": reports})
+}
+
+// CrashReportHandler devuelve un reporte de falla (GET /admin/crash-reports/{name})
+func (r *CrashReporter) CrashReportHandler(w http.ResponseWriter, req *http.Request) {
+	r.Logger.ForRequest(req).Info("Received request: /admin/crash-reports/{name}")
+
+	if req.Method != http.MethodGet {
+		WriteErrorJSON(w, http.StatusMethodNotAllowed, "Método HTTP no permitido", nil)
+		return
+	}
+	name := filepath.Base(req.PathValue("name"))
+	if !strings.HasPrefix(name, "crash-") || !strings.HasSuffix(name, ".json") {
+		WriteErrorJSON(w, http.StatusBadRequest, "Nombre de reporte inválido", nil)
+		return
+	}
+	data, err := os.ReadFile(filepath.Join(r.Dir, name))
+	if os.IsNotExist(err) {
+		data, err = os.ReadFile(filepath.Join(r.Dir, name+".sent"))
+	}
+	if err != nil {
+		WriteErrorJSON(w, http.StatusNotFound, "Reporte no encontrado", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(data)
+}
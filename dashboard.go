@@ -0,0 +1,116 @@
+package main
+
+import (
+	_ "embed"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ============================
+// Panel Administrativo Web (/admin)
+// ============================
+
+// dashboardHTML es el panel para los encargados de tienda; los datos se piden a la API con el
+// ADMIN_TOKEN que el usuario ingresa en el navegador, por lo que la página en sí no es sensible
+//
+//go:embed dashboard.html
+var dashboardHTML []byte
+
+// Límites de las líneas del log que muestra el panel
+const (
+	defaultLogTailLines = 200
+	maxLogTailLines     = 5000
+)
+
+// DashboardHandlers sirve el panel y los datos que solo él usa (resumen y final del log)
+type DashboardHandlers struct {
+	History   JobHistory
+	LogFile   string
+	StoreName string
+	Backend   string
+	Logger    *Logger
+}
+
+// DashboardSummary es el resumen de las últimas 24 horas que muestra el panel
+type DashboardSummary struct {
+	AgentVersion string         `json:"agent_version"`
+	Store        string         `json:"store"`
+	Backend      string         `json:"backend"`
+	Since        time.Time      `json:"since"`
+	Stats        JobStats       `json:"stats"`
+	Failures     map[string]int `json:"failures_by_printer"`
+}
+
+// PageHandler devuelve la página del panel (GET /admin)
+func (h DashboardHandlers) PageHandler(w http.ResponseWriter, r *http.Request) {
+	h.Logger = h.Logger.ForRequest(r)
+	h.Logger.Info("Received request: /admin")
+	if r.Method != http.MethodGet {
+		WriteErrorJSON(w, http.StatusMethodNotAllowed, "Método HTTP no permitido", nil)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Write(dashboardHTML)
+}
+
+// SummaryHandler resume los trabajos y los errores por impresora de las últimas 24 horas
+// (GET /admin/summary)
+func (h DashboardHandlers) SummaryHandler(w http.ResponseWriter, r *http.Request) {
+	h.Logger = h.Logger.ForRequest(r)
+	h.Logger.Info("Received request: /admin/summary")
+	if r.Method != http.MethodGet {
+		WriteErrorJSON(w, http.StatusMethodNotAllowed, "Método HTTP no permitido", nil)
+		return
+	}
+
+	since := time.Now().Add(-24 * time.Hour)
+	stats, err := h.History.Stats(JobFilter{Since: since})
+	if err != nil {
+		WriteErrorJSON(w, http.StatusInternalServerError, "Error al consultar el historial", err)
+		return
+	}
+	failures, err := h.History.Stats(JobFilter{Since: since, Status: JobStatusFailed})
+	if err != nil {
+		WriteErrorJSON(w, http.StatusInternalServerError, "Error al consultar el historial", err)
+		return
+	}
+	WriteJSON(w, http.StatusOK, DashboardSummary{
+		AgentVersion: agentVersion,
+		Store:        h.StoreName,
+		Backend:      h.Backend,
+		Since:        since,
+		Stats:        stats,
+		Failures:     failures.ByPrinter,
+	})
+}
+
+// LogTailHandler devuelve las últimas líneas de app.log como texto (GET /admin/log?lines=200)
+func (h DashboardHandlers) LogTailHandler(w http.ResponseWriter, r *http.Request) {
+	h.Logger = h.Logger.ForRequest(r)
+	h.Logger.Info("Received request: /admin/log")
+	if r.Method != http.MethodGet {
+		WriteErrorJSON(w, http.StatusMethodNotAllowed, "Método HTTP no permitido", nil)
+		return
+	}
+
+	lines := defaultLogTailLines
+	if v := r.URL.Query().Get("lines"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			WriteErrorJSON(w, http.StatusBadRequest, "Parámetro lines inválido", err)
+			return
+		}
+		lines = min(n, maxLogTailLines)
+	}
+	tail, err := tailLogFile(h.LogFile, lines, nil)
+	if err != nil {
+		WriteErrorJSON(w, http.StatusInternalServerError, "Error al leer el log", err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(tail)
+}
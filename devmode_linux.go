@@ -0,0 +1,12 @@
+package main
+
+// withPrinterDevMode ejecuta fn directamente: en Linux no existe el DEVMODE de Windows y las
+// opciones se envían como atributos del trabajo (motor cups) o en la plantilla del motor personalizado
+func withPrinterDevMode(printer string, opts PrintOptions, fn func() error) error {
+	return fn()
+}
+
+// driverPaperSize no tiene equivalente en Linux: el papel se indica con paper_size
+func driverPaperSize(printer string) (string, error) {
+	return "", nil
+}
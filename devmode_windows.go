@@ -0,0 +1,414 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// ============================
+// Configuración del Controlador (DEVMODE)
+// ============================
+
+var (
+	procDocumentPropertiesW = modWinspool.NewProc("DocumentPropertiesW")
+	procSetPrinterW         = modWinspool.NewProc("SetPrinterW")
+	procDeviceCapabilitiesW = modWinspool.NewProc("DeviceCapabilitiesW")
+)
+
+// Modos de DocumentProperties
+const (
+	dmOutBuffer = 2
+	dmInBuffer  = 8
+)
+
+// Campos de DEVMODE.dmFields
+const (
+	dmFieldOrientation   = 0x00000001
+	dmFieldPaperSize     = 0x00000002
+	dmFieldScale         = 0x00000010
+	dmFieldDefaultSource = 0x00000200
+	dmFieldPrintQuality  = 0x00000400
+	dmFieldColor         = 0x00000800
+	dmFieldDuplex        = 0x00001000
+	dmFieldYResolution   = 0x00002000
+)
+
+// Consultas de DeviceCapabilities sobre las bandejas del controlador
+const (
+	dcBins        = 6
+	dcBinNames    = 12
+	binNameLength = 24 // caracteres de cada nombre de DC_BINNAMES
+)
+
+// Valores de DEVMODE
+const (
+	dmOrientPortrait  = 1
+	dmOrientLandscape = 2
+
+	dmDuplexSimplex    = 1
+	dmDuplexVertical   = 2 // borde largo
+	dmDuplexHorizontal = 3 // borde corto
+
+	dmColorMonochrome = 1
+	dmColorColor      = 2
+)
+
+// devMode refleja la parte pública de la estructura DEVMODEW (la porción del controlador va a continuación)
+type devMode struct {
+	DeviceName       [32]uint16
+	SpecVersion      uint16
+	DriverVersion    uint16
+	Size             uint16
+	DriverExtra      uint16
+	Fields           uint32
+	Orientation      int16
+	PaperSize        int16
+	PaperLength      int16
+	PaperWidth       int16
+	Scale            int16
+	Copies           int16
+	DefaultSource    int16
+	PrintQuality     int16
+	Color            int16
+	Duplex           int16
+	YResolution      int16
+	TTOption         int16
+	Collate          int16
+	FormName         [32]uint16
+	LogPixels        uint16
+	BitsPerPel       uint32
+	PelsWidth        uint32
+	PelsHeight       uint32
+	DisplayFlags     uint32
+	DisplayFrequency uint32
+	ICMMethod        uint32
+	ICMIntent        uint32
+	MediaType        uint32
+	DitherType       uint32
+	Reserved1        uint32
+	Reserved2        uint32
+	PanningWidth     uint32
+	PanningHeight    uint32
+}
+
+// printerInfo9 refleja PRINTER_INFO_9W (DEVMODE predeterminado del usuario actual)
+type printerInfo9 struct {
+	DevMode *devMode
+}
+
+// applyPrintOptions ajusta en el DEVMODE las opciones solicitadas; bin es la bandeja ya resuelta
+// con resolveTrayBin (0 conserva la del controlador)
+func applyPrintOptions(dm *devMode, opts PrintOptions, bin int16) {
+	switch opts.Orientation {
+	case OrientationPortrait:
+		dm.Orientation = dmOrientPortrait
+		dm.Fields |= dmFieldOrientation
+	case OrientationLandscape:
+		dm.Orientation = dmOrientLandscape
+		dm.Fields |= dmFieldOrientation
+	}
+
+	switch opts.Duplex {
+	case DuplexNone:
+		dm.Duplex = dmDuplexSimplex
+		dm.Fields |= dmFieldDuplex
+	case DuplexLongEdge:
+		dm.Duplex = dmDuplexVertical
+		dm.Fields |= dmFieldDuplex
+	case DuplexShortEdge:
+		dm.Duplex = dmDuplexHorizontal
+		dm.Fields |= dmFieldDuplex
+	}
+
+	if size, ok := paperSizes[opts.PaperSize]; ok {
+		dm.PaperSize = size
+		dm.PaperLength = 0
+		dm.PaperWidth = 0
+		dm.Fields |= dmFieldPaperSize
+	}
+
+	if bin != 0 {
+		dm.DefaultSource = bin
+		dm.Fields |= dmFieldDefaultSource
+	}
+
+	switch opts.Color {
+	case ColorColor:
+		dm.Color = dmColorColor
+		dm.Fields |= dmFieldColor
+	case ColorGrayscale:
+		dm.Color = dmColorMonochrome
+		dm.Fields |= dmFieldColor
+	}
+
+	// Con DM_YRESOLUTION el controlador leería PrintQuality como resolución horizontal en ppp en
+	// lugar de un valor DMRES_*
+	if level, ok := qualityLevels[opts.Quality]; ok {
+		dm.PrintQuality = level.Res
+		dm.Fields |= dmFieldPrintQuality
+		dm.Fields &^= dmFieldYResolution
+	}
+
+	// La escala personalizada la aplica el controlador; fit y shrink dependen del motor
+	if percent := opts.ScalePercent(); percent > 0 {
+		dm.Scale = int16(percent)
+		dm.Fields |= dmFieldScale
+	}
+}
+
+// driverBin es una bandeja de papel informada por el controlador
+type driverBin struct {
+	Code int16
+	Name string
+}
+
+// driverBins lista las bandejas del controlador con DeviceCapabilities (DC_BINS y DC_BINNAMES)
+func driverBins(printer string) ([]driverBin, error) {
+	info, err := getSpoolerPrinter(printer)
+	if err != nil {
+		return nil, err
+	}
+	device, err := windows.UTF16PtrFromString(printer)
+	if err != nil {
+		return nil, err
+	}
+	port, err := windows.UTF16PtrFromString(info.PortName)
+	if err != nil {
+		return nil, err
+	}
+	count, _, err := procDeviceCapabilitiesW.Call(uintptr(unsafe.Pointer(device)), uintptr(unsafe.Pointer(port)), dcBins, 0, 0)
+	if int32(count) <= 0 {
+		return nil, fmt.Errorf("DeviceCapabilities no informó las bandejas de '%s': %w", printer, err)
+	}
+	codes := make([]uint16, count)
+	procDeviceCapabilitiesW.Call(uintptr(unsafe.Pointer(device)), uintptr(unsafe.Pointer(port)), dcBins,
+		uintptr(unsafe.Pointer(&codes[0])), 0)
+	names := make([]uint16, int(count)*binNameLength)
+	procDeviceCapabilitiesW.Call(uintptr(unsafe.Pointer(device)), uintptr(unsafe.Pointer(port)), dcBinNames,
+		uintptr(unsafe.Pointer(&names[0])), 0)
+
+	bins := make([]driverBin, 0, count)
+	for i, code := range codes {
+		name := windows.UTF16ToString(names[i*binNameLength : (i+1)*binNameLength])
+		bins = append(bins, driverBin{Code: int16(code), Name: strings.TrimSpace(name)})
+	}
+	return bins, nil
+}
+
+// resolveTrayBin traduce la bandeja solicitada al código DMBIN_* del controlador: las genéricas
+// (auto, upper...) tienen código fijo, un número se usa como código y tray-N o un nombre se buscan
+// entre las bandejas del controlador ("Tray 2", "Bandeja 2")
+func resolveTrayBin(printer, tray string) (int16, error) {
+	if tray == "" {
+		return 0, nil
+	}
+	if t, ok := trayKeywords[tray]; ok {
+		return t.Bin, nil
+	}
+	if code, err := strconv.ParseInt(tray, 10, 16); err == nil && code > 0 {
+		return int16(code), nil
+	}
+	bins, err := driverBins(printer)
+	if err != nil {
+		return 0, err
+	}
+	compact := func(s string) string {
+		return strings.NewReplacer(" ", "", "-", "", "_", "").Replace(strings.ToLower(s))
+	}
+	want := []string{compact(tray)}
+	if m := trayNumberPattern.FindStringSubmatch(tray); m != nil {
+		want = append(want, "bandeja"+m[1], "cassette"+m[1])
+	}
+	names := make([]string, 0, len(bins))
+	for _, bin := range bins {
+		if slices.Contains(want, compact(bin.Name)) {
+			return bin.Code, nil
+		}
+		names = append(names, bin.Name)
+	}
+	return 0, permanent(fmt.Errorf("la impresora '%s' no tiene la bandeja '%s' (bandejas del controlador: %s)", printer, tray, strings.Join(names, ", ")))
+}
+
+// driverPaperSize devuelve el papel configurado en el controlador para el usuario, entre los tamaños
+// de paper_size (vacío si usa otro)
+func driverPaperSize(printer string) (string, error) {
+	h, err := openSpoolerPrinter(printer)
+	if err != nil {
+		return "", err
+	}
+	defer closeSpoolerPrinter(h)
+	base, err := getUserDevMode(h)
+	if err != nil {
+		return "", fmt.Errorf("error al leer la configuración de la impresora: %w", err)
+	}
+	var paper int16
+	if _, err := documentProperties(h, printer, base, func(dm *devMode) { paper = dm.PaperSize }); err != nil {
+		return "", fmt.Errorf("error al leer la configuración de la impresora: %w", err)
+	}
+	for name, size := range paperSizes {
+		if size == paper {
+			return name, nil
+		}
+	}
+	return "", nil
+}
+
+// withPrinterDevMode aplica temporalmente las opciones como DEVMODE predeterminado del usuario
+// (SetPrinter nivel 9), ejecuta fn y restaura la configuración anterior. Las herramientas externas
+// como PDFtoPrinter toman la configuración predeterminada del controlador, por lo que esta es la
+// forma de aplicar duplex, orientación, papel, bandeja, color, calidad y escala sin soporte en su línea
+// de comandos. Los trabajos de una misma impresora se serializan mientras dura el cambio: si no,
+// uno leería como "anterior" el DEVMODE temporal del otro y lo dejaría aplicado.
+func withPrinterDevMode(printer string, opts PrintOptions, fn func() error) (err error) {
+	if !opts.NeedsDevMode() {
+		return fn()
+	}
+	unlock := lockPrinterDevMode(printer)
+	defer unlock()
+
+	h, err := openSpoolerPrinter(printer)
+	if err != nil {
+		return err
+	}
+	defer closeSpoolerPrinter(h)
+
+	bin, err := resolveTrayBin(printer, opts.Tray)
+	if err != nil {
+		return err
+	}
+	previous, err := getUserDevMode(h)
+	if err != nil {
+		return fmt.Errorf("error al leer la configuración de la impresora: %w", err)
+	}
+
+	dm, err := documentProperties(h, printer, previous, func(dm *devMode) {
+		applyPrintOptions(dm, opts, bin)
+	})
+	if err != nil {
+		return fmt.Errorf("error al preparar la configuración de impresión: %w", err)
+	}
+
+	if err := setUserDevMode(h, dm); err != nil {
+		return fmt.Errorf("error al aplicar la configuración de impresión: %w", err)
+	}
+	defer func() {
+		restoreErr := setUserDevMode(h, previous)
+		if restoreErr == nil {
+			return
+		}
+		restoreErr = fmt.Errorf("error al restaurar la configuración de '%s': %w", printer, restoreErr)
+		if err != nil {
+			err = errors.Join(err, restoreErr)
+			return
+		}
+		// El documento ya se imprimió: fallar el trabajo provocaría un reintento y una copia de más
+		log.Printf("%v", restoreErr)
+		recordToolOutput(opts.JobID, "DEVMODE", restoreErr.Error())
+	}()
+
+	return fn()
+}
+
+// devModeLocks guarda un candado por impresora para withPrinterDevMode
+var devModeLocks = struct {
+	sync.Mutex
+	printers map[string]*sync.Mutex
+}{printers: make(map[string]*sync.Mutex)}
+
+// lockPrinterDevMode reserva la configuración predeterminada de la impresora y devuelve la función
+// que la libera
+func lockPrinterDevMode(printer string) func() {
+	devModeLocks.Lock()
+	mu, ok := devModeLocks.printers[printer]
+	if !ok {
+		mu = &sync.Mutex{}
+		devModeLocks.printers[printer] = mu
+	}
+	devModeLocks.Unlock()
+	mu.Lock()
+	return mu.Unlock
+}
+
+// getUserDevMode obtiene una copia del DEVMODE predeterminado del usuario (nil si no tiene uno propio)
+func getUserDevMode(h windows.Handle) ([]byte, error) {
+	var needed uint32
+	r1, _, err := procGetPrinterW.Call(uintptr(h), 9, 0, 0, uintptr(unsafe.Pointer(&needed)))
+	if r1 == 0 && err != windows.ERROR_INSUFFICIENT_BUFFER {
+		return nil, fmt.Errorf("GetPrinter nivel 9 falló: %w", err)
+	}
+	if needed == 0 {
+		return nil, nil
+	}
+
+	buf := make([]byte, needed)
+	r1, _, err = procGetPrinterW.Call(uintptr(h), 9,
+		uintptr(unsafe.Pointer(&buf[0])), uintptr(needed), uintptr(unsafe.Pointer(&needed)))
+	if r1 == 0 {
+		return nil, fmt.Errorf("GetPrinter nivel 9 falló: %w", err)
+	}
+
+	info := (*printerInfo9)(unsafe.Pointer(&buf[0]))
+	if info.DevMode == nil {
+		return nil, nil
+	}
+	size := int(info.DevMode.Size) + int(info.DevMode.DriverExtra)
+	return append([]byte(nil), unsafe.Slice((*byte)(unsafe.Pointer(info.DevMode)), size)...), nil
+}
+
+// setUserDevMode establece (o elimina si dm es nil) el DEVMODE predeterminado del usuario
+func setUserDevMode(h windows.Handle, dm []byte) error {
+	var info printerInfo9
+	if len(dm) > 0 {
+		info.DevMode = (*devMode)(unsafe.Pointer(&dm[0]))
+	}
+	r1, _, err := procSetPrinterW.Call(uintptr(h), 9, uintptr(unsafe.Pointer(&info)), 0)
+	if r1 == 0 {
+		return fmt.Errorf("SetPrinter nivel 9 falló: %w", err)
+	}
+	return nil
+}
+
+// documentProperties obtiene el DEVMODE de la impresora (partiendo de base si no es nil),
+// aplica modify y deja que el controlador valide y combine los cambios.
+func documentProperties(h windows.Handle, printer string, base []byte, modify func(*devMode)) ([]byte, error) {
+	namePtr, err := windows.UTF16PtrFromString(printer)
+	if err != nil {
+		return nil, err
+	}
+
+	size, _, err := procDocumentPropertiesW.Call(0, uintptr(h), uintptr(unsafe.Pointer(namePtr)), 0, 0, 0)
+	if int32(size) <= 0 {
+		return nil, fmt.Errorf("DocumentProperties falló: %w", err)
+	}
+
+	current := make([]byte, size)
+	var input uintptr
+	mode := uintptr(dmOutBuffer)
+	if len(base) > 0 {
+		input = uintptr(unsafe.Pointer(&base[0]))
+		mode |= dmInBuffer
+	}
+	r1, _, err := procDocumentPropertiesW.Call(0, uintptr(h), uintptr(unsafe.Pointer(namePtr)),
+		uintptr(unsafe.Pointer(&current[0])), input, mode)
+	if int32(r1) < 0 {
+		return nil, fmt.Errorf("DocumentProperties falló: %w", err)
+	}
+
+	modify((*devMode)(unsafe.Pointer(&current[0])))
+
+	merged := make([]byte, size)
+	r1, _, err = procDocumentPropertiesW.Call(0, uintptr(h), uintptr(unsafe.Pointer(namePtr)),
+		uintptr(unsafe.Pointer(&merged[0])), uintptr(unsafe.Pointer(&current[0])), dmInBuffer|dmOutBuffer)
+	if int32(r1) < 0 {
+		return nil, fmt.Errorf("DocumentProperties falló: %w", err)
+	}
+	return merged, nil
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+)
+
+// ============================
+// Diagnóstico del Proceso (pprof y /admin/runtime)
+// ============================
+
+// processStartedAt es la hora de inicio del proceso; no cambia al recargar la configuración
+var processStartedAt = time.Now()
+
+// agentTempPatterns son los nombres de los archivos temporales que crea el agente (documentos
+// descargados, ajustados, sellados, lotes, ...); los que quedan después de los trabajos indican
+// una fuga
+var agentTempPatterns = []string{
+	"[0-9]*.pdf", "batch-*.pdf", "image-*.pdf", "fitted-*.pdf", "stamped-*.pdf", "text-*.pdf", "drawer-*.ps1",
+}
+
+// RuntimeInfo es el estado del proceso que devuelve GET /admin/runtime
+type RuntimeInfo struct {
+	AgentVersion  string        `json:"agent_version"`
+	GoVersion     string        `json:"go_version"`
+	StartedAt     time.Time     `json:"started_at"`
+	UptimeSeconds int64         `json:"uptime_seconds"`
+	Goroutines    int           `json:"goroutines"`
+	CPUs          int           `json:"cpus"`
+	Memory        RuntimeMemory `json:"memory"`
+	// OpenHandles son los archivos y sockets abiertos (descriptores en Linux, handles en Windows)
+	OpenHandles int              `json:"open_handles,omitempty"`
+	TempFiles   RuntimeTempFiles `json:"temp_files"`
+	Queues      RuntimeQueues    `json:"queues"`
+	JobState    map[string]int   `json:"job_state"`
+}
+
+// RuntimeMemory resume las estadísticas de memoria del runtime de Go
+type RuntimeMemory struct {
+	HeapAllocBytes  uint64    `json:"heap_alloc_bytes"`
+	HeapInuseBytes  uint64    `json:"heap_inuse_bytes"`
+	HeapObjects     uint64    `json:"heap_objects"`
+	StackInuseBytes uint64    `json:"stack_inuse_bytes"`
+	SysBytes        uint64    `json:"sys_bytes"`
+	TotalAllocBytes uint64    `json:"total_alloc_bytes"`
+	NumGC           uint32    `json:"num_gc"`
+	LastGC          time.Time `json:"last_gc,omitempty"`
+	GCPauseTotalMs  int64     `json:"gc_pause_total_ms"`
+}
+
+// RuntimeTempFiles son los archivos temporales del agente que siguen en la carpeta temporal
+type RuntimeTempFiles struct {
+	Dir    string     `json:"dir"`
+	Count  int        `json:"count"`
+	Bytes  int64      `json:"bytes"`
+	Oldest *time.Time `json:"oldest,omitempty"`
+}
+
+// RuntimeQueues son los trabajos pendientes en cada etapa
+type RuntimeQueues struct {
+	Running           int            `json:"running"`
+	HeldByPrinter     map[string]int `json:"held_by_printer"`
+	Scheduled         int            `json:"scheduled"`
+	Spooled           int            `json:"spooled"`
+	ExternalProcesses int            `json:"external_processes"`
+	EventSubscribers  int            `json:"event_subscribers"`
+}
+
+// RuntimeHandlers expone el estado del proceso para diagnosticar el crecimiento de memoria en los
+// agentes que llevan meses en ejecución
+type RuntimeHandlers struct {
+	Jobs   *JobRunner
+	Events *EventBus
+	Logger *Logger
+}
+
+// RuntimeHandler devuelve memoria, goroutines, archivos temporales y colas (GET /admin/runtime).
+// Con gc=true se ejecuta antes el recolector para medir solo la memoria en uso.
+func (h RuntimeHandlers) RuntimeHandler(w http.ResponseWriter, r *http.Request) {
+	h.Logger = h.Logger.ForRequest(r)
+	h.Logger.Info("Received request: /admin/runtime")
+	if r.Method != http.MethodGet {
+		WriteErrorJSON(w, http.StatusMethodNotAllowed, "Método HTTP no permitido", nil)
+		return
+	}
+	if r.URL.Query().Get("gc") == "true" {
+		runtime.GC()
+	}
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	info := RuntimeInfo{
+		AgentVersion:  agentVersion,
+		GoVersion:     runtime.Version(),
+		StartedAt:     processStartedAt,
+		UptimeSeconds: int64(time.Since(processStartedAt).Seconds()),
+		Goroutines:    runtime.NumGoroutine(),
+		CPUs:          runtime.NumCPU(),
+		Memory: RuntimeMemory{
+			HeapAllocBytes:  mem.HeapAlloc,
+			HeapInuseBytes:  mem.HeapInuse,
+			HeapObjects:     mem.HeapObjects,
+			StackInuseBytes: mem.StackInuse,
+			SysBytes:        mem.Sys,
+			TotalAllocBytes: mem.TotalAlloc,
+			NumGC:           mem.NumGC,
+			GCPauseTotalMs:  int64(time.Duration(mem.PauseTotalNs) / time.Millisecond),
+		},
+		TempFiles: agentTempFiles(),
+		Queues:    h.queues(),
+		JobState:  jobStateSizes(),
+	}
+	if mem.LastGC > 0 {
+		info.Memory.LastGC = time.Unix(0, int64(mem.LastGC))
+	}
+	if n, err := openHandleCount(); err == nil {
+		info.OpenHandles = n
+	} else {
+		h.Logger.Warnf("No se pudo contar los archivos abiertos del proceso: %v", err)
+	}
+	WriteJSON(w, http.StatusOK, info)
+}
+
+// queues cuenta los trabajos en curso, retenidos, programados y en la cola del sistema
+func (h RuntimeHandlers) queues() RuntimeQueues {
+	q := RuntimeQueues{HeldByPrinter: map[string]int{}}
+	runningJobs.Range(func(_, _ interface{}) bool {
+		q.Running++
+		return true
+	})
+	if h.Jobs.Holds != nil {
+		q.HeldByPrinter = h.Jobs.Holds.Pending()
+	}
+	q.Scheduled = h.Jobs.Scheduler.Pending()
+	q.Spooled = h.Jobs.Spooler.Pending()
+	jobCancellations.Lock()
+	q.ExternalProcesses = len(jobCancellations.processes)
+	jobCancellations.Unlock()
+	if h.Events != nil {
+		q.EventSubscribers = h.Events.Subscribers()
+	}
+	return q
+}
+
+// jobStateSizes devuelve la cantidad de entradas de los registros por trabajo, que se vacían al
+// terminar cada trabajo; un valor que solo crece indica trabajos que no se cerraron
+func jobStateSizes() map[string]int {
+	sizes := map[string]int{}
+	jobToolOutputs.Lock()
+	sizes["tool_outputs"] = len(jobToolOutputs.outputs)
+	jobToolOutputs.Unlock()
+	jobPages.Lock()
+	sizes["pages"] = len(jobPages.pages)
+	jobPages.Unlock()
+	jobSpooledDocuments.Lock()
+	sizes["spooled_documents"] = len(jobSpooledDocuments.documents)
+	jobSpooledDocuments.Unlock()
+	jobCancellations.Lock()
+	sizes["cancellations"] = len(jobCancellations.canceled)
+	jobCancellations.Unlock()
+	jobSpans.Lock()
+	sizes["trace_spans"] = len(jobSpans.spans)
+	jobSpans.Unlock()
+	return sizes
+}
+
+// agentTempFiles cuenta los archivos temporales del agente en la carpeta temporal del sistema
+func agentTempFiles() RuntimeTempFiles {
+	files := RuntimeTempFiles{Dir: os.TempDir()}
+	entries, err := os.ReadDir(files.Dir)
+	if err != nil {
+		return files
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !isAgentTempFile(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files.Count++
+		files.Bytes += info.Size()
+		if modified := info.ModTime(); files.Oldest == nil || modified.Before(*files.Oldest) {
+			files.Oldest = &modified
+		}
+	}
+	return files
+}
+
+// isAgentTempFile indica si el nombre corresponde a un archivo temporal del agente
+func isAgentTempFile(name string) bool {
+	for _, pattern := range agentTempPatterns {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// registerPprof publica los perfiles de Go (/debug/pprof/) protegidos con el token administrativo
+func registerPprof(mux *routeMux, admin AdminAuth) {
+	mux.HandleFunc("/debug/pprof/", admin.Require(pprof.Index))
+	mux.HandleFunc("/debug/pprof/cmdline", admin.Require(pprof.Cmdline))
+	mux.HandleFunc("/debug/pprof/profile", admin.Require(pprof.Profile))
+	mux.HandleFunc("/debug/pprof/symbol", admin.Require(pprof.Symbol))
+	mux.HandleFunc("/debug/pprof/trace", admin.Require(pprof.Trace))
+}
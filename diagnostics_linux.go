@@ -0,0 +1,12 @@
+package main
+
+import "os"
+
+// openHandleCount cuenta los descriptores de archivo abiertos del proceso
+func openHandleCount() (int, error) {
+	entries, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		return 0, err
+	}
+	return len(entries), nil
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"fmt"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var procGetProcessHandleCount = windows.NewLazySystemDLL("kernel32.dll").NewProc("GetProcessHandleCount")
+
+// openHandleCount cuenta los handles abiertos del proceso (archivos, sockets, eventos, ...)
+func openHandleCount() (int, error) {
+	var count uint32
+	if r1, _, err := procGetProcessHandleCount.Call(uintptr(windows.CurrentProcess()), uintptr(unsafe.Pointer(&count))); r1 == 0 {
+		return 0, fmt.Errorf("GetProcessHandleCount falló: %w", err)
+	}
+	return int(count), nil
+}
@@ -0,0 +1,12 @@
+package main
+
+import "syscall"
+
+// diskFreeBytes devuelve el espacio disponible para el usuario en el sistema de archivos de path
+func diskFreeBytes(path string) (uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, err
+	}
+	return stat.Bavail * uint64(stat.Bsize), nil
+}
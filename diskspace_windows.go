@@ -0,0 +1,16 @@
+package main
+
+import "golang.org/x/sys/windows"
+
+// diskFreeBytes devuelve el espacio disponible para el usuario en la unidad de path
+func diskFreeBytes(path string) (uint64, error) {
+	dir, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+	var available, total, free uint64
+	if err := windows.GetDiskFreeSpaceEx(dir, &available, &total, &free); err != nil {
+		return 0, err
+	}
+	return available, nil
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// ============================
+// Visores de Cliente (Pole Display)
+// ============================
+
+// Errores de los visores
+var (
+	ErrDisplayNotFound = errors.New("visor no configurado")
+	ErrDisplayWrite    = errors.New("error al enviar al visor")
+)
+
+// displayWriteTimeout limita el envío a un visor: un visor desconectado no debe demorar la venta
+const displayWriteTimeout = 2 * time.Second
+
+// Comandos de los visores compatibles con ESC/POS (Epson DM-D y similares)
+const (
+	displayClear  = 0x0C // CLR: borra el visor y lleva el cursor al inicio
+	displayUnitUS = 0x1F
+)
+
+// DisplayConfig configura los visores de cliente conectados a puertos serie
+type DisplayConfig struct {
+	// Ports asigna cada visor a su puerto, por ejemplo "Caja-1=COM3:9600"
+	Ports   map[string]string
+	Columns int
+	Lines   int
+}
+
+// LoadDisplayConfig carga la configuración de los visores desde variables de entorno
+func LoadDisplayConfig() DisplayConfig {
+	return DisplayConfig{
+		Ports:   getEnvAsMap("DISPLAYS", ""),
+		Columns: getEnvAsInt("DISPLAY_COLUMNS", 20),
+		Lines:   getEnvAsInt("DISPLAY_LINES", 2),
+	}
+}
+
+// DisplayWriter envía los comandos a un visor
+type DisplayWriter interface {
+	WriteDisplay(name string, data []byte) error
+}
+
+// SerialDisplayWriter envía los comandos al puerto serie de cada visor
+type SerialDisplayWriter struct {
+	Ports   map[string]SerialPortConfig
+	Timeout time.Duration
+}
+
+// WriteDisplay abre el puerto del visor y envía los datos
+func (s SerialDisplayWriter) WriteDisplay(name string, data []byte) error {
+	port, ok := s.Ports[name]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrDisplayNotFound, name)
+	}
+	return WriteSerial(port, data, s.Timeout)
+}
+
+// DisplayStatus es un visor configurado y el texto que muestra
+type DisplayStatus struct {
+	Name      string     `json:"name"`
+	Port      string     `json:"port,omitempty"`
+	Lines     []string   `json:"lines"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+// DisplayManager muestra texto en los visores de cliente que los puntos de venta tienen junto a la
+// impresora. Recuerda lo último enviado a cada visor para consultarlo.
+type DisplayManager struct {
+	Writer  DisplayWriter
+	Ports   map[string]SerialPortConfig
+	Columns int
+	Lines   int
+	Logger  *Logger
+
+	mu      sync.Mutex
+	shown   map[string][]string
+	updated map[string]time.Time
+}
+
+// NewDisplayManager valida la configuración; sin visores devuelve nil
+func NewDisplayManager(cfg DisplayConfig, logger *Logger) (*DisplayManager, error) {
+	if len(cfg.Ports) == 0 {
+		return nil, nil
+	}
+	if cfg.Columns < 1 || cfg.Columns > 80 {
+		return nil, fmt.Errorf("DISPLAY_COLUMNS inválido: %d (rango 1-80)", cfg.Columns)
+	}
+	if cfg.Lines < 1 || cfg.Lines > 4 {
+		return nil, fmt.Errorf("DISPLAY_LINES inválido: %d (rango 1-4)", cfg.Lines)
+	}
+	ports := make(map[string]SerialPortConfig, len(cfg.Ports))
+	for name, spec := range cfg.Ports {
+		port, err := ParseSerialPort(spec)
+		if err != nil {
+			return nil, fmt.Errorf("DISPLAYS: visor '%s': %w", name, err)
+		}
+		ports[name] = port
+	}
+	return &DisplayManager{
+		Writer:  SerialDisplayWriter{Ports: ports, Timeout: displayWriteTimeout},
+		Ports:   ports,
+		Columns: cfg.Columns,
+		Lines:   cfg.Lines,
+		Logger:  logger,
+		shown:   make(map[string][]string),
+		updated: make(map[string]time.Time),
+	}, nil
+}
+
+// Names devuelve los visores ordenados por nombre
+func (m *DisplayManager) Names() []string {
+	names := make([]string, 0, len(m.Ports))
+	for name := range m.Ports {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// resolve devuelve el visor indicado; si se omite y hay uno solo, ese
+func (m *DisplayManager) resolve(name string) (string, error) {
+	if name == "" {
+		if len(m.Ports) != 1 {
+			return "", fmt.Errorf("indique el visor (%s)", strings.Join(m.Names(), ", "))
+		}
+		return m.Names()[0], nil
+	}
+	if _, ok := m.Ports[name]; !ok {
+		return "", fmt.Errorf("%w: %s", ErrDisplayNotFound, name)
+	}
+	return name, nil
+}
+
+// formatLine ajusta la línea al ancho del visor. Lo que sigue a una tabulación se alinea a la
+// derecha ("TOTAL\t$ 4.500"); lo que no entra se recorta, porque el visor no pasa a la línea siguiente.
+func (m *DisplayManager) formatLine(line string) string {
+	line = escposASCII(line)
+	left, right, found := strings.Cut(line, "\t")
+	if !found {
+		return truncateRunes(left, m.Columns)
+	}
+	right = truncateRunes(strings.TrimSpace(right), m.Columns)
+	left = truncateRunes(strings.TrimSpace(left), max(m.Columns-utf8.RuneCountInString(right)-1, 0))
+	gap := m.Columns - utf8.RuneCountInString(left) - utf8.RuneCountInString(right)
+	return left + strings.Repeat(" ", max(gap, 0)) + right
+}
+
+// truncateRunes recorta el texto a n caracteres
+func truncateRunes(text string, n int) string {
+	if utf8.RuneCountInString(text) <= n {
+		return text
+	}
+	return string([]rune(text)[:n])
+}
+
+// Show borra el visor y muestra las líneas, una por renglón
+func (m *DisplayManager) Show(name string, lines []string) (string, []string, error) {
+	name, err := m.resolve(name)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(lines) > m.Lines {
+		return "", nil, fmt.Errorf("el visor tiene %d líneas y se enviaron %d", m.Lines, len(lines))
+	}
+	shown := make([]string, len(lines))
+	data := []byte{escposESC, '@', displayClear}
+	for i, line := range lines {
+		shown[i] = m.formatLine(line)
+		// US $ x y: cursor al inicio del renglón i+1
+		data = append(data, displayUnitUS, '$', 1, byte(i+1))
+		data = append(data, shown[i]...)
+	}
+	if err := m.write(name, data, shown); err != nil {
+		return "", nil, err
+	}
+	return name, shown, nil
+}
+
+// Clear borra el visor
+func (m *DisplayManager) Clear(name string) (string, error) {
+	name, err := m.resolve(name)
+	if err != nil {
+		return "", err
+	}
+	return name, m.write(name, []byte{escposESC, '@', displayClear}, []string{})
+}
+
+// write envía los comandos al visor de a un envío por vez y recuerda el texto mostrado
+func (m *DisplayManager) write(name string, data []byte, shown []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.Writer.WriteDisplay(name, data); err != nil {
+		return fmt.Errorf("%w '%s': %w", ErrDisplayWrite, name, err)
+	}
+	m.shown[name] = shown
+	m.updated[name] = time.Now()
+	return nil
+}
+
+// Status devuelve los visores configurados con el texto que muestran
+func (m *DisplayManager) Status() []DisplayStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]DisplayStatus, 0, len(m.Ports))
+	for _, name := range m.Names() {
+		status := DisplayStatus{Name: name, Port: m.Ports[name].Port, Lines: []string{}}
+		if lines, ok := m.shown[name]; ok {
+			updated := m.updated[name]
+			status.Lines, status.UpdatedAt = lines, &updated
+		}
+		out = append(out, status)
+	}
+	return out
+}
+
+// DisplayRequest es el cuerpo de POST /display y POST /display/clear
+type DisplayRequest struct {
+	Display string   `json:"display,omitempty"`
+	Lines   []string `json:"lines,omitempty"`
+}
+
+// DisplayHandlers expone los visores de cliente por HTTP
+type DisplayHandlers struct {
+	Displays *DisplayManager
+	Logger   *Logger
+}
+
+// DisplayHandler muestra texto en un visor (POST /display) o lista los visores y lo que muestran
+// (GET /display)
+func (h DisplayHandlers) DisplayHandler(w http.ResponseWriter, r *http.Request) {
+	h.Logger = h.Logger.ForRequest(r)
+	h.Logger.Info("Received request: /display")
+
+	switch r.Method {
+	case http.MethodGet:
+		WriteJSON(w, http.StatusOK, map[string]interface{}{"displays": h.Displays.Status()})
+	case http.MethodPost:
+		var req DisplayRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			h.Logger.Warnf("Error al decodificar JSON: %v", err)
+			WriteErrorJSON(w, http.StatusBadRequest, "Solicitud JSON inválida", err)
+			return
+		}
+		if len(req.Lines) == 0 {
+			WriteErrorJSON(w, http.StatusBadRequest, "Indique las líneas a mostrar (lines)", nil)
+			return
+		}
+		name, lines, err := h.Displays.Show(req.Display, req.Lines)
+		if err != nil {
+			h.writeError(w, err)
+			return
+		}
+		WriteJSON(w, http.StatusOK, map[string]interface{}{"display": name, "lines": lines})
+	default:
+		WriteErrorJSON(w, http.StatusMethodNotAllowed, "Método HTTP no permitido", nil)
+	}
+}
+
+// ClearHandler borra un visor (POST /display/clear)
+func (h DisplayHandlers) ClearHandler(w http.ResponseWriter, r *http.Request) {
+	h.Logger = h.Logger.ForRequest(r)
+	h.Logger.Info("Received request: /display/clear")
+
+	if r.Method != http.MethodPost {
+		WriteErrorJSON(w, http.StatusMethodNotAllowed, "Método HTTP no permitido", nil)
+		return
+	}
+	var req DisplayRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			h.Logger.Warnf("Error al decodificar JSON: %v", err)
+			WriteErrorJSON(w, http.StatusBadRequest, "Solicitud JSON inválida", err)
+			return
+		}
+	}
+	name, err := h.Displays.Clear(req.Display)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]interface{}{"display": name, "message": "Visor borrado."})
+}
+
+// writeError responde los errores del visor con el código HTTP correspondiente
+func (h DisplayHandlers) writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrDisplayNotFound):
+		WriteErrorJSON(w, http.StatusNotFound, "El visor no existe", err)
+	case errors.Is(err, ErrDisplayWrite):
+		h.Logger.Errorf("%v", err)
+		WriteErrorJSON(w, http.StatusBadGateway, "No se pudo comunicar con el visor", err)
+	default:
+		WriteErrorJSON(w, http.StatusBadRequest, "Solicitud inválida para el visor", err)
+	}
+}
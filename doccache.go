@@ -0,0 +1,288 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ============================
+// Caché Local de Documentos
+// ============================
+
+// errNotModified indica que el servidor confirmó (304) que el documento en caché sigue vigente
+var errNotModified = errors.New("documento sin cambios")
+
+// cacheIndexFile guarda el índice de la caché para conservarla entre reinicios
+const cacheIndexFile = "index.json"
+
+// cacheValidators son los datos con los que el servidor indica si el documento cambió
+type cacheValidators struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+// empty indica que el servidor no envió ETag ni Last-Modified
+func (v cacheValidators) empty() bool {
+	return v.ETag == "" && v.LastModified == ""
+}
+
+// CacheEntry es un documento descargado, informado en GET /admin/cache
+type CacheEntry struct {
+	URL       string    `json:"url"`
+	SHA256    string    `json:"document_sha256"`
+	Size      int64     `json:"size"`
+	FetchedAt time.Time `json:"fetched_at"`
+	LastUsed  time.Time `json:"last_used"`
+	Hits      int       `json:"hits"`
+	cacheValidators
+}
+
+// DocumentCache conserva en disco los documentos descargados, identificados por URL y guardados
+// por el hash de su contenido (las URLs con el mismo documento comparten el archivo). Al superar el
+// tamaño máximo se eliminan los menos usados recientemente.
+type DocumentCache struct {
+	dir      string
+	maxBytes int64
+	maxAge   time.Duration
+	logger   *Logger
+
+	mu      sync.Mutex
+	entries map[string]*CacheEntry
+	hits    int
+	misses  int
+}
+
+// NewDocumentCache abre la caché del directorio, recuperando el índice guardado
+func NewDocumentCache(dir string, maxMB, maxAgeSeconds int, logger *Logger) (*DocumentCache, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("error al crear el directorio de caché '%s': %w", dir, err)
+	}
+	c := &DocumentCache{
+		dir:      dir,
+		maxBytes: int64(maxMB) << 20,
+		maxAge:   time.Duration(maxAgeSeconds) * time.Second,
+		logger:   logger,
+		entries:  make(map[string]*CacheEntry),
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, cacheIndexFile)); err == nil {
+		var entries []*CacheEntry
+		if err := json.Unmarshal(data, &entries); err != nil {
+			logger.Warnf("Índice de caché inválido, se descarta: %v", err)
+		}
+		for _, e := range entries {
+			if _, err := os.Stat(c.blobPath(e.SHA256)); err == nil {
+				c.entries[e.URL] = e
+			}
+		}
+	}
+	return c, nil
+}
+
+// blobPath devuelve la ruta del documento con el hash indicado
+func (c *DocumentCache) blobPath(sha string) string {
+	return filepath.Join(c.dir, sha+".pdf")
+}
+
+// Lookup devuelve los validadores del documento en caché y si puede usarse sin consultar al
+// servidor (descargado hace menos de DOCUMENT_CACHE_MAX_AGE_SECONDS)
+func (c *DocumentCache) Lookup(fileURL string) (cacheValidators, bool, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[fileURL]
+	if !ok {
+		c.misses++
+		return cacheValidators{}, false, false
+	}
+	return e.cacheValidators, c.maxAge > 0 && time.Since(e.FetchedAt) < c.maxAge, true
+}
+
+// Open copia el documento en caché a un archivo temporal (quien lo usa puede eliminarlo o moverlo)
+func (c *DocumentCache) Open(fileURL string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[fileURL]
+	if !ok {
+		return "", fmt.Errorf("el documento no está en caché")
+	}
+
+	tempFile, err := os.CreateTemp("", "*.pdf")
+	if err != nil {
+		return "", err
+	}
+	tempFile.Close()
+	if err := copyFile(c.blobPath(e.SHA256), tempFile.Name()); err != nil {
+		os.Remove(tempFile.Name())
+		return "", err
+	}
+	e.LastUsed = time.Now()
+	e.Hits++
+	c.hits++
+	c.saveLocked()
+	return tempFile.Name(), nil
+}
+
+// Store guarda una copia del documento descargado. Sin ETag ni Last-Modified solo se guarda si
+// DOCUMENT_CACHE_MAX_AGE_SECONDS permite reutilizarlo sin consultar al servidor.
+func (c *DocumentCache) Store(fileURL, filePath string, validators cacheValidators) {
+	if validators.empty() && c.maxAge <= 0 {
+		return
+	}
+	f, err := os.Open(filePath)
+	if err != nil {
+		return
+	}
+	sha := documentSHA256(f)
+	info, serr := f.Stat()
+	f.Close()
+	if sha == "" || serr != nil || info.Size() > c.maxBytes {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, err := os.Stat(c.blobPath(sha)); err != nil {
+		if err := copyFile(filePath, c.blobPath(sha)); err != nil {
+			c.logger.Errorf("Error al guardar el documento en caché: %v", err)
+			return
+		}
+	}
+	previous := c.entries[fileURL]
+	now := time.Now()
+	c.entries[fileURL] = &CacheEntry{
+		URL:             fileURL,
+		SHA256:          sha,
+		Size:            info.Size(),
+		FetchedAt:       now,
+		LastUsed:        now,
+		cacheValidators: validators,
+	}
+	// El documento de la URL cambió: se elimina la versión anterior si ninguna otra URL la usa
+	if previous != nil && previous.SHA256 != sha && !c.referencedLocked(previous.SHA256) {
+		os.Remove(c.blobPath(previous.SHA256))
+	}
+	c.evictLocked()
+	c.saveLocked()
+}
+
+// Refresh marca el documento como vigente tras una respuesta 304 del servidor
+func (c *DocumentCache) Refresh(fileURL string) {
+	c.mu.Lock()
+	if e, ok := c.entries[fileURL]; ok {
+		e.FetchedAt = time.Now()
+	}
+	c.mu.Unlock()
+}
+
+// evictLocked elimina los documentos menos usados hasta respetar el tamaño máximo
+func (c *DocumentCache) evictLocked() {
+	entries := c.sortedLocked()
+	total := c.sizeLocked()
+	for i := len(entries) - 1; i >= 0 && total > c.maxBytes; i-- {
+		e := &entries[i]
+		delete(c.entries, e.URL)
+		if !c.referencedLocked(e.SHA256) {
+			os.Remove(c.blobPath(e.SHA256))
+			total -= e.Size
+		}
+		c.logger.Infof("Documento quitado de la caché por espacio: %s", e.URL)
+	}
+}
+
+// sizeLocked suma el tamaño de los documentos guardados (los compartidos se cuentan una vez)
+func (c *DocumentCache) sizeLocked() int64 {
+	var total int64
+	seen := make(map[string]bool)
+	for _, e := range c.entries {
+		if !seen[e.SHA256] {
+			seen[e.SHA256] = true
+			total += e.Size
+		}
+	}
+	return total
+}
+
+// referencedLocked indica si alguna URL en caché usa el documento con el hash indicado
+func (c *DocumentCache) referencedLocked(sha string) bool {
+	for _, e := range c.entries {
+		if e.SHA256 == sha {
+			return true
+		}
+	}
+	return false
+}
+
+// sortedLocked devuelve una copia de las entradas, de la más a la menos usada recientemente
+func (c *DocumentCache) sortedLocked() []CacheEntry {
+	entries := make([]CacheEntry, 0, len(c.entries))
+	for _, e := range c.entries {
+		entries = append(entries, *e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].LastUsed.After(entries[j].LastUsed) })
+	return entries
+}
+
+// Purge elimina de la caché la URL indicada, o todos los documentos si url está vacía; devuelve
+// la cantidad de entradas eliminadas
+func (c *DocumentCache) Purge(fileURL string) int {
+	c.mu.Lock()
+	var removed []*CacheEntry
+	for u, e := range c.entries {
+		if fileURL == "" || u == fileURL {
+			removed = append(removed, e)
+			delete(c.entries, u)
+		}
+	}
+	for _, e := range removed {
+		if !c.referencedLocked(e.SHA256) {
+			os.Remove(c.blobPath(e.SHA256))
+		}
+	}
+	c.saveLocked()
+	c.mu.Unlock()
+	return len(removed)
+}
+
+// saveLocked escribe el índice en disco
+func (c *DocumentCache) saveLocked() {
+	data, err := json.Marshal(c.sortedLocked())
+	if err != nil {
+		return
+	}
+	if err := os.WriteFile(filepath.Join(c.dir, cacheIndexFile), data, 0o600); err != nil {
+		c.logger.Errorf("Error al guardar el índice de la caché: %v", err)
+	}
+}
+
+// CacheHandler atiende /admin/cache: GET lista los documentos y DELETE los elimina
+// (todos, o solo el de ?url=)
+func (c *DocumentCache) CacheHandler(w http.ResponseWriter, r *http.Request) {
+	c.logger.ForRequest(r).Info("Received request: /admin/cache")
+
+	switch r.Method {
+	case http.MethodGet:
+		c.mu.Lock()
+		resp := map[string]interface{}{
+			"documents": c.sortedLocked(),
+			"size":      c.sizeLocked(),
+			"max_size":  c.maxBytes,
+			"hits":      c.hits,
+			"misses":    c.misses,
+		}
+		c.mu.Unlock()
+		WriteJSON(w, http.StatusOK, resp)
+	case http.MethodDelete:
+		fileURL := r.URL.Query().Get("url")
+		removed := c.Purge(fileURL)
+		c.logger.ForRequest(r).Warnf("Caché de documentos vaciada desde %s: %d documentos", r.RemoteAddr, removed)
+		WriteJSON(w, http.StatusOK, map[string]interface{}{"removed": removed})
+	default:
+		WriteErrorJSON(w, http.StatusMethodNotAllowed, "Método HTTP no permitido", nil)
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ============================
+// Política de Descargas (SSRF)
+// ============================
+
+// ErrDownloadBlocked indica que la política de descargas rechazó la URL del documento
+var ErrDownloadBlocked = errors.New("descarga no permitida")
+
+// maxDownloadRedirects limita las redirecciones que sigue una descarga
+const maxDownloadRedirects = 10
+
+// DownloadPolicy restringe los servidores desde los que el agente descarga documentos, para que
+// un navegador comprometido en la red local no lo use como proxy hacia direcciones internas
+type DownloadPolicy struct {
+	// AllowedHosts son los servidores permitidos ("erp.miempresa.com", "*.miempresa.com" o una IP);
+	// vacío permite cualquier servidor público. Los servidores permitidos pueden tener IP privada.
+	AllowedHosts []string
+	// BlockPrivate rechaza las direcciones de loopback, privadas y link-local de los servidores no permitidos
+	BlockPrivate bool
+}
+
+// allowed indica si el servidor está en AllowedHosts
+func (p DownloadPolicy) allowed(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, pattern := range p.AllowedHosts {
+		pattern = strings.ToLower(pattern)
+		if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+			if strings.HasSuffix(host, "."+suffix) {
+				return true
+			}
+		} else if host == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckURL valida el esquema y el servidor de la URL (también en cada redirección)
+func (p DownloadPolicy) CheckURL(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%w: esquema no soportado: %s", ErrDownloadBlocked, u.Scheme)
+	}
+	if len(p.AllowedHosts) > 0 && !p.allowed(u.Hostname()) {
+		return fmt.Errorf("%w: el servidor '%s' no está en DOWNLOAD_ALLOWED_HOSTS", ErrDownloadBlocked, u.Hostname())
+	}
+	// Las IP se validan antes de conectar: a través de un proxy solo se ve la dirección del proxy
+	if ip := net.ParseIP(u.Hostname()); ip != nil && !p.allowed(u.Hostname()) {
+		return p.checkIP(ip)
+	}
+	return nil
+}
+
+// reservedNetworks son los rangos internos que net.IP no clasifica: "esta red" (0.0.0.0/8), el
+// NAT de operador (100.64.0.0/10) y la red de pruebas de rendimiento (198.18.0.0/15)
+var reservedNetworks = func() []*net.IPNet {
+	var nets []*net.IPNet
+	for _, cidr := range []string{"0.0.0.0/8", "100.64.0.0/10", "198.18.0.0/15"} {
+		_, n, _ := net.ParseCIDR(cidr)
+		nets = append(nets, n)
+	}
+	return nets
+}()
+
+// checkIP rechaza las direcciones internas cuando BlockPrivate está activo
+func (p DownloadPolicy) checkIP(ip net.IP) error {
+	if !p.BlockPrivate {
+		return nil
+	}
+	reserved := slices.ContainsFunc(reservedNetworks, func(n *net.IPNet) bool { return n.Contains(ip) })
+	if reserved || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsUnspecified() || ip.IsMulticast() {
+		return fmt.Errorf("%w: la dirección %s es interna (agregue el servidor a DOWNLOAD_ALLOWED_HOSTS)", ErrDownloadBlocked, ip)
+	}
+	return nil
+}
+
+// Client crea el cliente HTTP de descargas con el proxy y los certificados indicados. Las
+// direcciones se validan al conectar, después de resolver el nombre, para que un DNS que cambia de
+// respuesta no eluda la política; el proxy configurado siempre se permite.
+func (p DownloadPolicy) Client(timeout time.Duration, outbound OutboundConfig) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if err := outbound.apply(transport); err != nil {
+		return nil, err
+	}
+	proxies := outbound.proxyHosts()
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+		if !p.allowed(host) && !proxies[strings.ToLower(host)] {
+			dialer.Control = func(_, address string, _ syscall.RawConn) error {
+				ipText, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				return p.checkIP(net.ParseIP(ipText))
+			}
+		}
+		return dialer.DialContext(ctx, network, addr)
+	}
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxDownloadRedirects {
+				return fmt.Errorf("demasiadas redirecciones")
+			}
+			return p.CheckURL(req.URL)
+		},
+	}, nil
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ============================
+// Apertura de Cajón por ESC/POS
+// ============================
+
+// Métodos de apertura de cajón
+const (
+	DrawerMethodESCPOS = "escpos"
+	DrawerMethodScript = "script"
+)
+
+// Transportes para los comandos RAW
+const (
+	TransportSpooler = "spooler"
+	TransportTCP     = "tcp"
+)
+
+// ErrDrawerNotConfigured indica que la impresora no tiene el cajón pedido
+var ErrDrawerNotConfigured = errors.New("cajón no configurado")
+
+// DrawerOptions son las opciones opcionales de apertura del cajón; los valores en cero usan la configuración
+type DrawerOptions struct {
+	Drawer  int    `json:"drawer,omitempty"` // cajón 1 o 2; el perfil indica su conector
+	Pin     int    `json:"pin,omitempty"`
+	PulseMs int    `json:"pulse_ms,omitempty"`
+	Command []byte `json:"-"` // secuencia del perfil de la impresora; reemplaza a la definición activa
+	JobID   string `json:"-"` // trabajo en curso, para conservar la salida del script
+}
+
+// DrawerConfig define cómo se abre el cajón de efectivo
+type DrawerConfig struct {
+	Method      string
+	Transport   string
+	Pin         int
+	PulseMs     int
+	CommandsDir string
+	// OpenSignal es el nivel del sensor que indica el cajón abierto (low o high)
+	OpenSignal         string
+	OpenAlertSeconds   int
+	MonitorPrinters    []string
+	MonitorPollSeconds int
+}
+
+// LoadDrawerConfig carga la configuración del cajón desde variables de entorno
+func LoadDrawerConfig() DrawerConfig {
+	return DrawerConfig{
+		Method:             getEnv("DRAWER_METHOD", DrawerMethodESCPOS),
+		Transport:          getEnv("DRAWER_TRANSPORT", TransportSpooler),
+		Pin:                getEnvAsInt("DRAWER_PIN", DrawerPin2),
+		PulseMs:            getEnvAsInt("DRAWER_PULSE_MS", 100),
+		CommandsDir:        getEnv("DRAWER_COMMANDS_DIR", "./drawer_commands"),
+		OpenSignal:         getEnv("DRAWER_OPEN_SIGNAL", DrawerSignalLow),
+		OpenAlertSeconds:   getEnvAsInt("DRAWER_OPEN_ALERT_SECONDS", 60),
+		MonitorPrinters:    getEnvAsSlice("DRAWER_MONITOR_PRINTERS", ""),
+		MonitorPollSeconds: getEnvAsInt("DRAWER_MONITOR_POLL_SECONDS", 5),
+	}
+}
+
+// NewRawWriter crea el RawWriter correspondiente al transporte indicado
+func NewRawWriter(transport string, addresses map[string]string, docName string) (RawWriter, error) {
+	switch transport {
+	case TransportSpooler:
+		return SpoolerRawWriter{DocName: docName}, nil
+	case TransportTCP:
+		return TCPRawWriter{Addresses: addresses, Timeout: 5 * time.Second}, nil
+	default:
+		return nil, fmt.Errorf("transporte desconocido: %s (use spooler o tcp)", transport)
+	}
+}
+
+// ESCPOSDrawerOpener abre el cajón enviando el pulso ESC p directamente a la impresora,
+// sin depender de scripts de PowerShell ni de su política de ejecución.
+type ESCPOSDrawerOpener struct {
+	Writer         RawWriter
+	DefaultPin     int
+	DefaultPulseMs int
+	Commands       *DrawerCommandStore
+}
+
+// OpenDrawer envía el pulso de apertura al conector indicado
+func (e ESCPOSDrawerOpener) OpenDrawer(printerName string, opts DrawerOptions) error {
+	if len(opts.Command) > 0 {
+		return e.Writer.WriteRaw(printerName, opts.Command)
+	}
+	// Una definición ESC/POS personalizada activa reemplaza al pulso estándar salvo que la solicitud lo especifique
+	if e.Commands != nil && opts.Pin == 0 && opts.PulseMs == 0 {
+		if cmd, ok := e.Commands.ActiveESCPOS(); ok {
+			return e.Writer.WriteRaw(printerName, cmd)
+		}
+	}
+
+	pin, pulse := opts.Pin, opts.PulseMs
+	if pin == 0 {
+		pin = e.DefaultPin
+	}
+	if pulse == 0 {
+		pulse = e.DefaultPulseMs
+	}
+
+	cmd, err := DrawerKickCommand(pin, pulse, pulse)
+	if err != nil {
+		return err
+	}
+	return e.Writer.WriteRaw(printerName, cmd)
+}
@@ -0,0 +1,372 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// ============================
+// Gestión Versionada de Comandos de Cajón
+// ============================
+
+// Tipos de definición de comando de cajón
+const (
+	DrawerCommandESCPOS     = "escpos"     // secuencia de bytes, p. ej. \x1B\x70\x00\x32\x32
+	DrawerCommandPowerShell = "powershell" // script .ps1 que recibe -Printer
+)
+
+// Límites de tamaño de las definiciones
+const (
+	maxESCPOSCommandBytes  = 64
+	maxPowerShellScriptLen = 64 << 10
+)
+
+// DrawerCommandVersion describe una versión almacenada de la definición del comando
+type DrawerCommandVersion struct {
+	Version   int       `json:"version"`
+	Kind      string    `json:"kind"`
+	SHA256    string    `json:"sha256"`
+	Size      int       `json:"size"`
+	Comment   string    `json:"comment,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// drawerCommandManifest es el índice persistido de versiones
+type drawerCommandManifest struct {
+	Active   int                    `json:"active"`
+	Versions []DrawerCommandVersion `json:"versions"`
+}
+
+// DrawerCommandStore guarda las versiones de la definición del comando de cajón en un directorio,
+// permitiendo activar cualquier versión anterior (rollback).
+type DrawerCommandStore struct {
+	mu       sync.RWMutex
+	dir      string
+	manifest drawerCommandManifest
+	logger   *Logger
+}
+
+// NewDrawerCommandStore abre (o inicializa) el almacén. Si no existe historial, importa el
+// archivo de comando actual como versión 1; una secuencia ESC/POS importada no se activa para
+// que DRAWER_PIN y DRAWER_PULSE_MS sigan aplicándose hasta que se active explícitamente.
+func NewDrawerCommandStore(dir, legacyPath string, logger *Logger) (*DrawerCommandStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("error al crear el directorio de comandos de cajón: %w", err)
+	}
+	s := &DrawerCommandStore{dir: dir, logger: logger}
+
+	data, err := os.ReadFile(s.manifestPath())
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(data, &s.manifest); err != nil {
+			return nil, fmt.Errorf("índice de comandos de cajón corrupto: %w", err)
+		}
+	case os.IsNotExist(err):
+		if legacy, err := os.ReadFile(legacyPath); err == nil && len(legacy) > 0 {
+			kind := DrawerCommandPowerShell
+			if _, err := ParseESCPOSDefinition(string(legacy)); err == nil {
+				kind = DrawerCommandESCPOS
+			}
+			if _, err := s.Add(kind, string(legacy), "Importado de "+legacyPath, kind == DrawerCommandPowerShell); err != nil {
+				logger.Warnf("No se pudo importar el comando de cajón existente: %v", err)
+			}
+		}
+	default:
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *DrawerCommandStore) manifestPath() string {
+	return filepath.Join(s.dir, "manifest.json")
+}
+
+func (s *DrawerCommandStore) versionPath(v DrawerCommandVersion) string {
+	ext := ".escpos"
+	if v.Kind == DrawerCommandPowerShell {
+		ext = ".ps1"
+	}
+	return filepath.Join(s.dir, fmt.Sprintf("v%04d%s", v.Version, ext))
+}
+
+func (s *DrawerCommandStore) saveManifest() error {
+	data, err := json.MarshalIndent(s.manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.manifestPath() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.manifestPath())
+}
+
+// ValidateDrawerCommand verifica que la definición sea utilizable antes de guardarla
+func ValidateDrawerCommand(kind, content string) error {
+	switch kind {
+	case DrawerCommandESCPOS:
+		_, err := ParseESCPOSDefinition(content)
+		return err
+	case DrawerCommandPowerShell:
+		if strings.TrimSpace(content) == "" {
+			return fmt.Errorf("el script está vacío")
+		}
+		if len(content) > maxPowerShellScriptLen {
+			return fmt.Errorf("el script supera el tamaño máximo de %d bytes", maxPowerShellScriptLen)
+		}
+		if !utf8.ValidString(content) {
+			return fmt.Errorf("el script no es texto UTF-8 válido")
+		}
+		return validatePowerShellSyntax(content)
+	default:
+		return fmt.Errorf("tipo de comando desconocido: %s (use escpos o powershell)", kind)
+	}
+}
+
+// Add valida y guarda una nueva versión; si activate es true pasa a ser la versión activa
+func (s *DrawerCommandStore) Add(kind, content, comment string, activate bool) (DrawerCommandVersion, error) {
+	if err := ValidateDrawerCommand(kind, content); err != nil {
+		return DrawerCommandVersion{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(content))
+	v := DrawerCommandVersion{
+		Version:   len(s.manifest.Versions) + 1,
+		Kind:      kind,
+		SHA256:    hex.EncodeToString(sum[:]),
+		Size:      len(content),
+		Comment:   comment,
+		CreatedAt: time.Now(),
+	}
+	if err := os.WriteFile(s.versionPath(v), []byte(content), 0o644); err != nil {
+		return DrawerCommandVersion{}, fmt.Errorf("error al guardar la versión: %w", err)
+	}
+
+	s.manifest.Versions = append(s.manifest.Versions, v)
+	if activate {
+		s.manifest.Active = v.Version
+	}
+	if err := s.saveManifest(); err != nil {
+		return DrawerCommandVersion{}, fmt.Errorf("error al guardar el índice: %w", err)
+	}
+	s.logger.Infof("Comando de cajón v%d (%s) guardado, activo=%v", v.Version, kind, activate)
+	return v, nil
+}
+
+// Activate establece la versión indicada como activa (rollback o roll-forward)
+func (s *DrawerCommandStore) Activate(version int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if version < 1 || version > len(s.manifest.Versions) {
+		return fmt.Errorf("la versión %d no existe", version)
+	}
+	previous := s.manifest.Active
+	s.manifest.Active = version
+	if err := s.saveManifest(); err != nil {
+		s.manifest.Active = previous
+		return fmt.Errorf("error al guardar el índice: %w", err)
+	}
+	s.logger.Infof("Comando de cajón activo cambiado de v%d a v%d", previous, version)
+	return nil
+}
+
+// List devuelve las versiones almacenadas y la versión activa
+func (s *DrawerCommandStore) List() (int, []DrawerCommandVersion) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.manifest.Active, append([]DrawerCommandVersion(nil), s.manifest.Versions...)
+}
+
+// Get devuelve los metadatos y el contenido de una versión
+func (s *DrawerCommandStore) Get(version int) (DrawerCommandVersion, string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if version < 1 || version > len(s.manifest.Versions) {
+		return DrawerCommandVersion{}, "", fmt.Errorf("la versión %d no existe", version)
+	}
+	v := s.manifest.Versions[version-1]
+	content, err := os.ReadFile(s.versionPath(v))
+	if err != nil {
+		return v, "", err
+	}
+	return v, string(content), nil
+}
+
+// Active devuelve la versión activa y la ruta de su archivo; ok es false si no hay versión activa
+func (s *DrawerCommandStore) Active() (v DrawerCommandVersion, path string, ok bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.manifest.Active < 1 || s.manifest.Active > len(s.manifest.Versions) {
+		return DrawerCommandVersion{}, "", false
+	}
+	v = s.manifest.Versions[s.manifest.Active-1]
+	return v, s.versionPath(v), true
+}
+
+// ActiveESCPOS devuelve los bytes de la versión activa si es una definición ESC/POS
+func (s *DrawerCommandStore) ActiveESCPOS() ([]byte, bool) {
+	v, path, ok := s.Active()
+	if !ok || v.Kind != DrawerCommandESCPOS {
+		return nil, false
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	cmd, err := ParseESCPOSDefinition(string(content))
+	return cmd, err == nil
+}
+
+// ParseESCPOSDefinition interpreta una secuencia de bytes escrita como \x1B\x70..., 0x1B 0x70... o 1B 70 ...
+func ParseESCPOSDefinition(def string) ([]byte, error) {
+	def = strings.TrimSpace(def)
+	if def == "" {
+		return nil, fmt.Errorf("la definición ESC/POS está vacía")
+	}
+
+	var tokens []string
+	if strings.Contains(def, `\x`) || strings.Contains(def, `\X`) {
+		normalized := strings.ReplaceAll(def, `\X`, `\x`)
+		for _, part := range strings.Split(normalized, `\x`) {
+			if part = strings.TrimSpace(part); part != "" {
+				tokens = append(tokens, part)
+			}
+		}
+	} else {
+		tokens = strings.FieldsFunc(def, func(r rune) bool { return r == ' ' || r == ',' || r == '\n' || r == '\r' || r == '\t' })
+	}
+
+	var out []byte
+	for _, tok := range tokens {
+		tok = strings.TrimPrefix(strings.TrimPrefix(tok, "0x"), "0X")
+		b, err := strconv.ParseUint(tok, 16, 8)
+		if err != nil || len(tok) > 2 {
+			return nil, fmt.Errorf("byte ESC/POS inválido: %q", tok)
+		}
+		out = append(out, byte(b))
+	}
+	if len(out) > maxESCPOSCommandBytes {
+		return nil, fmt.Errorf("la secuencia ESC/POS supera los %d bytes", maxESCPOSCommandBytes)
+	}
+	return out, nil
+}
+
+// DrawerCommandHandlers expone la gestión de comandos de cajón en la API administrativa
+type DrawerCommandHandlers struct {
+	Store  *DrawerCommandStore
+	Logger *Logger
+}
+
+type drawerCommandRequest struct {
+	Kind     string `json:"kind"`
+	Content  string `json:"content"`
+	Comment  string `json:"comment"`
+	Activate *bool  `json:"activate"`
+}
+
+// ListHandler lista las versiones (GET) o sube una nueva (POST)
+func (h DrawerCommandHandlers) ListHandler(w http.ResponseWriter, r *http.Request) {
+	h.Logger = h.Logger.ForRequest(r)
+	h.Logger.Info("Received request: /admin/drawer-commands")
+
+	switch r.Method {
+	case http.MethodGet:
+		active, versions := h.Store.List()
+		WriteJSON(w, http.StatusOK, map[string]interface{}{"active": active, "versions": versions})
+	case http.MethodPost:
+		var req drawerCommandRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxPowerShellScriptLen*2)).Decode(&req); err != nil {
+			WriteErrorJSON(w, http.StatusBadRequest, "Solicitud JSON inválida", err)
+			return
+		}
+		activate := req.Activate == nil || *req.Activate
+		v, err := h.Store.Add(strings.ToLower(req.Kind), req.Content, req.Comment, activate)
+		if err != nil {
+			h.Logger.Warnf("Comando de cajón rechazado: %v", err)
+			WriteErrorJSON(w, http.StatusBadRequest, "Definición de comando inválida", err)
+			return
+		}
+		WriteJSON(w, http.StatusCreated, v)
+	default:
+		WriteErrorJSON(w, http.StatusMethodNotAllowed, "Método HTTP no permitido", nil)
+	}
+}
+
+// ValidateHandler valida una definición sin guardarla
+func (h DrawerCommandHandlers) ValidateHandler(w http.ResponseWriter, r *http.Request) {
+	h.Logger = h.Logger.ForRequest(r)
+	h.Logger.Info("Received request: /admin/drawer-commands/validate")
+
+	if r.Method != http.MethodPost {
+		WriteErrorJSON(w, http.StatusMethodNotAllowed, "Método HTTP no permitido", nil)
+		return
+	}
+	var req drawerCommandRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxPowerShellScriptLen*2)).Decode(&req); err != nil {
+		WriteErrorJSON(w, http.StatusBadRequest, "Solicitud JSON inválida", err)
+		return
+	}
+	if err := ValidateDrawerCommand(strings.ToLower(req.Kind), req.Content); err != nil {
+		WriteJSON(w, http.StatusOK, map[string]interface{}{"valid": false, "error": err.Error()})
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]interface{}{"valid": true})
+}
+
+// VersionHandler devuelve el contenido de una versión (GET)
+func (h DrawerCommandHandlers) VersionHandler(w http.ResponseWriter, r *http.Request) {
+	h.Logger = h.Logger.ForRequest(r)
+	h.Logger.Info("Received request: /admin/drawer-commands/{version}")
+
+	if r.Method != http.MethodGet {
+		WriteErrorJSON(w, http.StatusMethodNotAllowed, "Método HTTP no permitido", nil)
+		return
+	}
+	version, err := strconv.Atoi(r.PathValue("version"))
+	if err != nil {
+		WriteErrorJSON(w, http.StatusBadRequest, "Versión inválida", err)
+		return
+	}
+	v, content, err := h.Store.Get(version)
+	if err != nil {
+		WriteErrorJSON(w, http.StatusNotFound, "Versión no encontrada", err)
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]interface{}{"version": v, "content": content})
+}
+
+// ActivateHandler activa una versión existente (POST), permitiendo el rollback
+func (h DrawerCommandHandlers) ActivateHandler(w http.ResponseWriter, r *http.Request) {
+	h.Logger = h.Logger.ForRequest(r)
+	h.Logger.Info("Received request: /admin/drawer-commands/{version}/activate")
+
+	if r.Method != http.MethodPost {
+		WriteErrorJSON(w, http.StatusMethodNotAllowed, "Método HTTP no permitido", nil)
+		return
+	}
+	version, err := strconv.Atoi(r.PathValue("version"))
+	if err != nil {
+		WriteErrorJSON(w, http.StatusBadRequest, "Versión inválida", err)
+		return
+	}
+	if err := h.Store.Activate(version); err != nil {
+		WriteErrorJSON(w, http.StatusNotFound, "No se pudo activar la versión", err)
+		return
+	}
+	active, versions := h.Store.List()
+	WriteJSON(w, http.StatusOK, map[string]interface{}{"active": active, "versions": versions})
+}
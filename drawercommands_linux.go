@@ -0,0 +1,8 @@
+package main
+
+import "errors"
+
+// validatePowerShellSyntax rechaza los scripts de PowerShell, que no pueden ejecutarse en Linux
+func validatePowerShellSyntax(script string) error {
+	return errors.New("los scripts de PowerShell solo están disponibles en Windows; use una definición escpos")
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// validatePowerShellSyntax analiza el script con el parser de PowerShell sin ejecutarlo
+func validatePowerShellSyntax(script string) error {
+	f, err := os.CreateTemp("", "drawer-*.ps1")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString(script); err != nil {
+		f.Close()
+		return err
+	}
+	f.Close()
+
+	check := fmt.Sprintf(`$e=$null; [void][System.Management.Automation.Language.Parser]::ParseFile('%s',[ref]$null,[ref]$e); if ($e.Count) { $e | ForEach-Object { "línea $($_.Extent.StartLineNumber): $($_.Message)" }; exit 1 }`,
+		strings.ReplaceAll(f.Name(), "'", "''"))
+	cmd := exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command", check)
+	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		if _, ok := err.(*exec.ExitError); ok {
+			return fmt.Errorf("error de sintaxis en el script: %s", strings.TrimSpace(string(output)))
+		}
+		return fmt.Errorf("no se pudo validar el script con PowerShell: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ============================
+// Estado del Cajón de Efectivo
+// ============================
+
+// ErrDrawerStatusUnsupported indica que no hay canal de retorno para consultar el cajón (impresoras
+// USB por el spooler, backend de archivo)
+var ErrDrawerStatusUnsupported = errors.New("la impresora no permite consultar el estado del cajón")
+
+// Nivel del pin 3 del conector del cajón que indica el cajón abierto; depende del interruptor del
+// cajón (la mayoría lo ponen en nivel bajo al abrirse)
+const (
+	DrawerSignalLow  = "low"
+	DrawerSignalHigh = "high"
+)
+
+// DrawerStatus es el estado del cajón leído del sensor de la impresora
+type DrawerStatus struct {
+	Printer     string     `json:"printer"`
+	Open        bool       `json:"open"`
+	Signal      string     `json:"signal"`
+	OpenSince   *time.Time `json:"open_since,omitempty"`
+	OpenSeconds int        `json:"open_seconds,omitempty"`
+	Source      string     `json:"source"`
+	CheckedAt   time.Time  `json:"checked_at"`
+}
+
+// DrawerStatusChecker consulta el sensor del cajón de una impresora
+type DrawerStatusChecker interface {
+	CheckDrawer(printer string) (*DrawerStatus, error)
+}
+
+// newDrawerStatus interpreta el pin 3 según el nivel que indica el cajón abierto
+func newDrawerStatus(printer string, high bool, openSignal, source string) *DrawerStatus {
+	signal := DrawerSignalLow
+	if high {
+		signal = DrawerSignalHigh
+	}
+	return &DrawerStatus{Printer: printer, Open: signal == openSignal, Signal: signal, Source: source, CheckedAt: time.Now()}
+}
+
+// queryDrawerSignal envía DLE EOT 1 e informa si el pin 3 del conector del cajón está en nivel alto
+// (bit 2 de la respuesta)
+func queryDrawerSignal(conn io.ReadWriter, address string) (bool, error) {
+	if _, err := conn.Write([]byte{escposDLE, escposEOT, 1}); err != nil {
+		return false, fmt.Errorf("error al consultar el cajón de %s: %w", address, err)
+	}
+	var status [1]byte
+	if _, err := io.ReadFull(conn, status[:]); err != nil {
+		return false, fmt.Errorf("la impresora %s no respondió a la consulta del cajón: %w", address, err)
+	}
+	return status[0]&0x04 != 0, nil
+}
+
+// ESCPOSDrawerStatusChecker consulta el cajón con DLE EOT 1 por el puerto serie de las impresoras
+// serie o por TCP cuando la impresora tiene dirección de red
+type ESCPOSDrawerStatusChecker struct {
+	Addresses  map[string]string
+	Serial     SerialPrinters
+	Timeout    time.Duration
+	OpenSignal string
+}
+
+// CheckDrawer consulta el sensor del cajón
+func (c ESCPOSDrawerStatusChecker) CheckDrawer(printer string) (*DrawerStatus, error) {
+	if port, ok := c.Serial.Port(printer); ok {
+		conn, err := OpenSerialPort(port, c.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("no se pudo abrir el puerto %s: %w", port.Port, err)
+		}
+		defer conn.Close()
+		high, err := queryDrawerSignal(conn, port.Port)
+		if err != nil {
+			return nil, err
+		}
+		return newDrawerStatus(printer, high, c.OpenSignal, "serial"), nil
+	}
+
+	address, err := resolvePrinterAddress(c.Addresses, printer)
+	if err != nil {
+		return nil, fmt.Errorf("%w: '%s' no tiene dirección de red ni puerto serie", ErrDrawerStatusUnsupported, printer)
+	}
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, defaultRawPort)
+	}
+	conn, err := net.DialTimeout("tcp", address, c.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo conectar a la impresora %s: %w", address, err)
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(c.Timeout)); err != nil {
+		return nil, err
+	}
+	high, err := queryDrawerSignal(conn, address)
+	if err != nil {
+		return nil, err
+	}
+	return newDrawerStatus(printer, high, c.OpenSignal, "escpos"), nil
+}
+
+// DrawerMonitor recuerda desde cuándo está abierto cada cajón y avisa (evento drawer.left_open)
+// cuando supera el tiempo permitido. Consulta en segundo plano las impresoras configuradas; las
+// demás se actualizan con cada consulta de /printers/{name}/drawer-status.
+type DrawerMonitor struct {
+	Checker    DrawerStatusChecker
+	Printers   []string
+	Poll       time.Duration
+	AlertAfter time.Duration
+	Events     *EventBus
+	Logger     *Logger
+
+	mu        sync.Mutex
+	openSince map[string]time.Time
+	alerted   map[string]bool
+	done      chan struct{}
+}
+
+// NewDrawerMonitor crea el monitor del cajón
+func NewDrawerMonitor(checker DrawerStatusChecker, cfg DrawerConfig, events *EventBus, logger *Logger) (*DrawerMonitor, error) {
+	switch cfg.OpenSignal {
+	case DrawerSignalLow, DrawerSignalHigh:
+	default:
+		return nil, fmt.Errorf("DRAWER_OPEN_SIGNAL inválido: %s (use low o high)", cfg.OpenSignal)
+	}
+	if cfg.MonitorPollSeconds <= 0 || cfg.OpenAlertSeconds <= 0 {
+		return nil, fmt.Errorf("DRAWER_MONITOR_POLL_SECONDS y DRAWER_OPEN_ALERT_SECONDS deben ser mayores que cero")
+	}
+	return &DrawerMonitor{
+		Checker:    checker,
+		Printers:   cfg.MonitorPrinters,
+		Poll:       time.Duration(cfg.MonitorPollSeconds) * time.Second,
+		AlertAfter: time.Duration(cfg.OpenAlertSeconds) * time.Second,
+		Events:     events,
+		Logger:     logger,
+		openSince:  make(map[string]time.Time),
+		alerted:    make(map[string]bool),
+		done:       make(chan struct{}),
+	}, nil
+}
+
+// CheckDrawer consulta el cajón y completa desde cuándo está abierto
+func (m *DrawerMonitor) CheckDrawer(printer string) (*DrawerStatus, error) {
+	status, err := m.Checker.CheckDrawer(printer)
+	if err != nil {
+		return nil, err
+	}
+	m.observe(status)
+	return status, nil
+}
+
+// observe actualiza el momento de apertura y publica los eventos de cierre y de cajón olvidado
+func (m *DrawerMonitor) observe(status *DrawerStatus) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	since, wasOpen := m.openSince[status.Printer]
+	if !status.Open {
+		if wasOpen {
+			delete(m.openSince, status.Printer)
+			delete(m.alerted, status.Printer)
+			m.Events.Publish(EventDrawerClosed, map[string]interface{}{"printer": status.Printer, "open_seconds": int(status.CheckedAt.Sub(since).Seconds())})
+		}
+		return
+	}
+	if !wasOpen {
+		since = status.CheckedAt
+		m.openSince[status.Printer] = since
+	}
+	open := status.CheckedAt.Sub(since)
+	status.OpenSince, status.OpenSeconds = &since, int(open.Seconds())
+	if open >= m.AlertAfter && !m.alerted[status.Printer] {
+		m.alerted[status.Printer] = true
+		m.Logger.Warnf("El cajón de '%s' está abierto hace %d segundos", status.Printer, status.OpenSeconds)
+		m.Events.Publish(EventDrawerLeftOpen, status)
+	}
+}
+
+// Start inicia la consulta de los cajones configurados
+func (m *DrawerMonitor) Start() {
+	if len(m.Printers) == 0 {
+		return
+	}
+	m.Logger.Infof("Monitoreo del cajón de %v cada %s (aviso a los %s)", m.Printers, m.Poll, m.AlertAfter)
+	go m.run()
+}
+
+// Close detiene la consulta de los cajones
+func (m *DrawerMonitor) Close() error {
+	close(m.done)
+	return nil
+}
+
+func (m *DrawerMonitor) run() {
+	defer recoverCrash()
+	ticker := time.NewTicker(m.Poll)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-m.done:
+			return
+		}
+		for _, printer := range m.Printers {
+			if _, err := m.CheckDrawer(printer); err != nil {
+				m.Logger.Warnf("No se pudo consultar el cajón de '%s': %v", printer, err)
+			}
+		}
+	}
+}
+
+// DrawerStatus consulta el sensor del cajón de la impresora
+func (d DefaultPrinterService) DrawerStatus(printerName string) (*DrawerStatus, error) {
+	if d.DrawerSensor == nil {
+		return nil, ErrDrawerStatusUnsupported
+	}
+	printerName, err := d.resolvePrinter(printerName)
+	if err != nil {
+		return nil, err
+	}
+	return d.DrawerSensor.CheckDrawer(printerName)
+}
+
+// DrawerStatusHandler informa si el cajón de la impresora está abierto (GET /printers/{name}/drawer-status)
+func (h Handlers) DrawerStatusHandler(w http.ResponseWriter, r *http.Request) {
+	h.Logger = h.Logger.ForRequest(r)
+	h.Logger.Info("Received request: /printers/{name}/drawer-status")
+
+	if r.Method != http.MethodGet {
+		WriteErrorJSON(w, http.StatusMethodNotAllowed, "Método HTTP no permitido", nil)
+		return
+	}
+	name := r.PathValue("name")
+	status, err := h.Service.DrawerStatus(name)
+	switch {
+	case err == nil:
+		WriteJSON(w, http.StatusOK, status)
+	case errors.Is(err, ErrDrawerStatusUnsupported):
+		WriteErrorJSON(w, http.StatusNotImplemented, "La impresora no permite consultar el estado del cajón", err)
+	case errors.Is(err, ErrPrinterNotFound):
+		WriteErrorJSON(w, http.StatusNotFound, "La impresora no existe", err)
+	default:
+		h.Logger.Warnf("No se pudo consultar el cajón de '%s': %v", name, err)
+		WriteErrorJSON(w, http.StatusBadGateway, "La impresora no respondió a la consulta del cajón", err)
+	}
+}
@@ -0,0 +1,431 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ============================
+// Motores de Impresión PDF
+// ============================
+
+// Nombres de los motores incorporados
+const (
+	EnginePDFtoPrinter = "pdftoprinter"
+	EngineSumatra      = "sumatra"
+	EngineGhostscript  = "ghostscript"
+	EngineAdobe        = "adobe"
+	EngineBuiltin      = "builtin"
+	EngineCUPS         = "cups"
+)
+
+// PDFEngine es un motor capaz de enviar un PDF a una impresora
+type PDFEngine interface {
+	DocumentPrinter
+	Name() string
+}
+
+// EngineConfig define los motores disponibles y cómo se eligen
+type EngineConfig struct {
+	Default         string
+	SumatraPath     string
+	GhostscriptPath string
+	AdobePath       string
+	AdobeWait       time.Duration
+	BuiltinMaxDPI   int
+	PrinterEngines  map[string]string
+	Custom          []CustomEngineConfig
+}
+
+// CustomEngineConfig define un motor externo con una plantilla de argumentos.
+// Marcadores soportados: {file}, {printer}, {copies}, {pages}, {duplex}, {orientation}, {paper},
+// {tray}, {color}, {quality}, {scale}.
+// Un argumento cuyos marcadores quedan todos vacíos se omite (p. ej. "pages={pages}" sin rango).
+type CustomEngineConfig struct {
+	Name string
+	Path string
+	Args []string
+}
+
+// LoadEngineConfig carga la configuración de motores desde variables de entorno
+func LoadEngineConfig() EngineConfig {
+	cfg := EngineConfig{
+		Default:         strings.ToLower(getEnv("PDF_ENGINE", defaultPDFEngine)),
+		SumatraPath:     getEnv("SUMATRA_PDF_PATH", "./SumatraPDF.exe"),
+		GhostscriptPath: getEnv("GHOSTSCRIPT_PATH", "./gswin64c.exe"),
+		AdobePath:       getEnv("ADOBE_READER_PATH", `C:\Program Files (x86)\Adobe\Acrobat Reader DC\Reader\AcroRd32.exe`),
+		AdobeWait:       time.Duration(getEnvAsInt("ADOBE_READER_WAIT_SECONDS", 10)) * time.Second,
+		BuiltinMaxDPI:   getEnvAsInt("BUILTIN_PDF_MAX_DPI", 300),
+		PrinterEngines:  getEnvAsMap("PRINTER_ENGINES", ""),
+	}
+	for _, name := range getEnvAsSlice("PDF_CUSTOM_ENGINES", "") {
+		key := "PDF_ENGINE_" + strings.ToUpper(name)
+		cfg.Custom = append(cfg.Custom, CustomEngineConfig{
+			Name: strings.ToLower(name),
+			Path: getEnv(key+"_PATH", ""),
+			Args: splitCommandLine(getEnv(key+"_ARGS", "{file} {printer}")),
+		})
+	}
+	return cfg
+}
+
+// EngineDocumentPrinter implementa DocumentPrinter eligiendo el motor según la solicitud,
+// la impresora o el motor predeterminado, en ese orden.
+type EngineDocumentPrinter struct {
+	Engines        map[string]PDFEngine
+	Default        string
+	PrinterEngines map[string]string
+	Logger         *Logger
+}
+
+// NewEngineDocumentPrinter registra los motores incorporados y los personalizados
+func NewEngineDocumentPrinter(cfg EngineConfig, pdfPrinterPath string, logger *Logger) (*EngineDocumentPrinter, error) {
+	e := &EngineDocumentPrinter{
+		Engines:        make(map[string]PDFEngine),
+		Default:        cfg.Default,
+		PrinterEngines: make(map[string]string),
+		Logger:         logger,
+	}
+	registerPlatformEngines(e, cfg, pdfPrinterPath)
+	for _, custom := range cfg.Custom {
+		if custom.Path == "" {
+			return nil, fmt.Errorf("el motor '%s' no tiene ruta configurada", custom.Name)
+		}
+		e.Register(TemplateEngine{Config: custom})
+	}
+
+	if _, ok := e.Engines[e.Default]; !ok {
+		return nil, fmt.Errorf("motor PDF predeterminado desconocido: %s", e.Default)
+	}
+	for printer, engine := range cfg.PrinterEngines {
+		engine = strings.ToLower(engine)
+		if _, ok := e.Engines[engine]; !ok {
+			return nil, fmt.Errorf("motor PDF desconocido para la impresora '%s': %s", printer, engine)
+		}
+		e.PrinterEngines[printer] = engine
+	}
+	return e, nil
+}
+
+// Register agrega (o reemplaza) un motor
+func (e *EngineDocumentPrinter) Register(engine PDFEngine) {
+	e.Engines[engine.Name()] = engine
+}
+
+// Names devuelve los nombres de los motores registrados
+func (e *EngineDocumentPrinter) Names() []string {
+	names := make([]string, 0, len(e.Engines))
+	for name := range e.Engines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Resolve determina el motor a usar para la impresora y las opciones indicadas
+func (e *EngineDocumentPrinter) Resolve(printer string, opts PrintOptions) (PDFEngine, error) {
+	name := opts.Engine
+	if name == "" {
+		name = e.PrinterEngines[printer]
+	}
+	if name == "" {
+		name = e.Default
+	}
+	engine, ok := e.Engines[name]
+	if !ok {
+		return nil, fmt.Errorf("motor PDF desconocido: %s (disponibles: %s)", name, strings.Join(e.Names(), ", "))
+	}
+	return engine, nil
+}
+
+// PrintFile imprime el archivo con el motor correspondiente
+func (e *EngineDocumentPrinter) PrintFile(filePath, printer string, opts PrintOptions) error {
+	engine, err := e.Resolve(printer, opts)
+	if err != nil {
+		return err
+	}
+	e.Logger.Infof("Imprimiendo en '%s' con el motor %s", printer, engine.Name())
+	return engine.PrintFile(filePath, printer, opts)
+}
+
+// PDFtoPrinterEngine adapta ExternalDocumentPrinter (PDFtoPrinter.exe) como motor
+type PDFtoPrinterEngine struct {
+	ExternalDocumentPrinter
+}
+
+// Name devuelve el nombre del motor
+func (p PDFtoPrinterEngine) Name() string { return EnginePDFtoPrinter }
+
+// SumatraEngine imprime mediante SumatraPDF (-print-to), que soporta las opciones de forma nativa
+type SumatraEngine struct {
+	Path string
+}
+
+// Name devuelve el nombre del motor
+func (s SumatraEngine) Name() string { return EngineSumatra }
+
+// PrintFile imprime el archivo con SumatraPDF. La calidad y la escala personalizada no tienen ajuste
+// en -print-settings y se aplican mediante la configuración del controlador.
+func (s SumatraEngine) PrintFile(filePath, printer string, opts PrintOptions) error {
+	args := []string{"-print-to", printer, "-silent", "-exit-when-done"}
+	if settings := sumatraPrintSettings(opts); settings != "" {
+		args = append(args, "-print-settings", settings)
+	}
+	args = append(args, filePath)
+	driverOpts := PrintOptions{Quality: opts.Quality}
+	if opts.ScalePercent() > 0 {
+		driverOpts.Scale = opts.Scale
+	}
+	return withPrinterDevMode(printer, driverOpts, func() error {
+		return runExternalTool("SumatraPDF", s.Path, args, toolRun{JobID: opts.JobID, Printer: printer, Document: filePath})
+	})
+}
+
+// sumatraPrintSettings traduce las opciones al formato de -print-settings de SumatraPDF
+func sumatraPrintSettings(opts PrintOptions) string {
+	var settings []string
+	if opts.Pages != "" {
+		settings = append(settings, opts.Pages)
+	}
+	if opts.Copies > 1 {
+		settings = append(settings, strconv.Itoa(opts.Copies)+"x")
+	}
+	switch opts.Duplex {
+	case DuplexNone:
+		settings = append(settings, "simplex")
+	case DuplexLongEdge:
+		settings = append(settings, "duplexlong")
+	case DuplexShortEdge:
+		settings = append(settings, "duplexshort")
+	}
+	if opts.Orientation != "" {
+		settings = append(settings, opts.Orientation)
+	}
+	if opts.PaperSize != "" {
+		settings = append(settings, "paper="+strings.ToUpper(opts.PaperSize))
+	}
+	if bin := sumatraBin(opts.Tray); bin != "" {
+		settings = append(settings, "bin="+bin)
+	}
+	switch opts.Color {
+	case ColorColor:
+		settings = append(settings, "color")
+	case ColorGrayscale:
+		settings = append(settings, "monochrome")
+	}
+	switch opts.Scale {
+	case ScaleFit:
+		settings = append(settings, "fit")
+	case ScaleShrink:
+		settings = append(settings, "shrink")
+	case ScaleNone:
+		settings = append(settings, "noscale")
+	}
+	return strings.Join(settings, ",")
+}
+
+// sumatraBin traduce la bandeja al valor de bin de SumatraPDF, que acepta el código DMBIN_* o el
+// nombre de la bandeja en el controlador
+func sumatraBin(tray string) string {
+	if t, ok := trayKeywords[tray]; ok {
+		return strconv.Itoa(int(t.Bin))
+	}
+	if m := trayNumberPattern.FindStringSubmatch(tray); m != nil {
+		return "Tray " + m[1]
+	}
+	// Las comas separan los valores de -print-settings
+	return strings.ReplaceAll(tray, ",", " ")
+}
+
+// GhostscriptEngine imprime mediante Ghostscript con el dispositivo mswinpr2, que envía las páginas
+// rasterizadas al controlador de Windows. Copias y páginas van en la línea de comandos; duplex,
+// orientación y papel se aplican mediante la configuración del controlador. Las páginas se ajustan a
+// la hoja salvo con scale none o un porcentaje (Ghostscript no distingue fit de shrink).
+type GhostscriptEngine struct {
+	Path string
+}
+
+// Name devuelve el nombre del motor
+func (g GhostscriptEngine) Name() string { return EngineGhostscript }
+
+// PrintFile imprime el archivo con Ghostscript
+func (g GhostscriptEngine) PrintFile(filePath, printer string, opts PrintOptions) error {
+	args := []string{
+		"-dBATCH", "-dNOPAUSE", "-dQUIET", "-dNoCancel",
+		"-sDEVICE=mswinpr2",
+		"-sOutputFile=%printer%" + printer,
+		"-sDocumentName=" + filepath.Base(filePath),
+	}
+	if opts.Copies > 1 {
+		args = append(args, "-dNumCopies="+strconv.Itoa(opts.Copies))
+	}
+	if opts.Pages != "" {
+		args = append(args, "-sPageList="+opts.Pages)
+	}
+	if opts.Scale != ScaleNone && opts.ScalePercent() == 0 {
+		args = append(args, "-dPDFFitPage")
+	}
+	args = append(args, filePath)
+	return withPrinterDevMode(printer, opts, func() error {
+		return runExternalTool("Ghostscript", g.Path, args, toolRun{JobID: opts.JobID, Printer: printer, Document: filePath})
+	})
+}
+
+// AdobeReaderEngine imprime mediante Adobe Reader/Acrobat (/t). La línea de comandos no admite
+// copias ni páginas: las copias se imprimen repitiendo el envío y el rango de páginas se rechaza.
+type AdobeReaderEngine struct {
+	Path string
+	// Wait es la espera máxima por copia: Reader suele quedar abierto tras enviar el documento,
+	// por lo que al vencerla se cierra y la copia se da por enviada
+	Wait time.Duration
+}
+
+// Name devuelve el nombre del motor
+func (a AdobeReaderEngine) Name() string { return EngineAdobe }
+
+// PrintFile imprime el archivo con Adobe Reader
+func (a AdobeReaderEngine) PrintFile(filePath, printer string, opts PrintOptions) error {
+	if opts.Pages != "" {
+		return fmt.Errorf("el motor %s no permite imprimir un rango de páginas; use otro motor", EngineAdobe)
+	}
+	copies := max(opts.Copies, 1)
+	return withFittedPDF(filePath, printer, opts, func(path string) error {
+		args := []string{"/n", "/s", "/h", "/t", path, printer}
+		return withPrinterDevMode(printer, opts, func() error {
+			for i := 0; i < copies; i++ {
+				err := runExternalToolWithin("Adobe Reader", a.Path, args, toolRun{JobID: opts.JobID, Printer: printer, Document: path}, a.Wait)
+				if err != nil && !errors.Is(err, errToolStillRunning) {
+					return err
+				}
+			}
+			return nil
+		})
+	})
+}
+
+// TemplateEngine es un motor externo configurado con una plantilla de argumentos
+type TemplateEngine struct {
+	Config CustomEngineConfig
+}
+
+// Name devuelve el nombre del motor
+func (t TemplateEngine) Name() string { return t.Config.Name }
+
+// PrintFile imprime el archivo con el ejecutable configurado. Las opciones no presentes en la
+// plantilla (duplex, orientación, papel, bandeja, color, calidad, escala) se aplican mediante la
+// configuración del controlador; sin {scale}, fit y shrink se aplican escalando el documento.
+func (t TemplateEngine) PrintFile(filePath, printer string, opts PrintOptions) error {
+	values := map[string]string{
+		"file":        filePath,
+		"printer":     printer,
+		"pages":       opts.Pages,
+		"duplex":      opts.Duplex,
+		"orientation": opts.Orientation,
+		"paper":       opts.PaperSize,
+		"tray":        opts.Tray,
+		"color":       opts.Color,
+		"quality":     opts.Quality,
+		"scale":       opts.Scale,
+	}
+	if opts.Copies > 0 {
+		values["copies"] = strconv.Itoa(opts.Copies)
+	}
+
+	// Solo se delegan al controlador las opciones que la plantilla no maneja
+	driverOpts := opts
+	if templateUses(t.Config.Args, "duplex") {
+		driverOpts.Duplex = ""
+	}
+	if templateUses(t.Config.Args, "orientation") {
+		driverOpts.Orientation = ""
+	}
+	if templateUses(t.Config.Args, "paper") {
+		driverOpts.PaperSize = ""
+	}
+	if templateUses(t.Config.Args, "tray") {
+		driverOpts.Tray = ""
+	}
+	if templateUses(t.Config.Args, "color") {
+		driverOpts.Color = ""
+	}
+	if templateUses(t.Config.Args, "quality") {
+		driverOpts.Quality = ""
+	}
+	if templateUses(t.Config.Args, "scale") {
+		driverOpts.Scale = ""
+	}
+	return withFittedPDF(filePath, printer, driverOpts, func(path string) error {
+		values["file"] = path
+		args := expandArgTemplate(t.Config.Args, values)
+		return withPrinterDevMode(printer, driverOpts, func() error {
+			return runExternalTool(t.Config.Name, t.Config.Path, args, toolRun{JobID: opts.JobID, Printer: printer, Document: path})
+		})
+	})
+}
+
+// templateUses indica si algún argumento de la plantilla contiene el marcador
+func templateUses(args []string, key string) bool {
+	for _, arg := range args {
+		if strings.Contains(arg, "{"+key+"}") {
+			return true
+		}
+	}
+	return false
+}
+
+// expandArgTemplate reemplaza los marcadores {clave} y descarta los argumentos
+// cuyos marcadores quedaron todos vacíos
+func expandArgTemplate(args []string, values map[string]string) []string {
+	var result []string
+	for _, arg := range args {
+		expanded := arg
+		placeholders, filled := 0, 0
+		for key, val := range values {
+			marker := "{" + key + "}"
+			if strings.Contains(expanded, marker) {
+				placeholders++
+				if val != "" {
+					filled++
+				}
+				expanded = strings.ReplaceAll(expanded, marker, val)
+			}
+		}
+		if placeholders > 0 && filled == 0 {
+			continue
+		}
+		result = append(result, expanded)
+	}
+	return result
+}
+
+// splitCommandLine separa argumentos por espacios respetando comillas dobles
+func splitCommandLine(s string) []string {
+	var args []string
+	var current strings.Builder
+	inQuotes, hasArg := false, false
+	for _, r := range s {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+			hasArg = true
+		case (r == ' ' || r == '\t') && !inQuotes:
+			if hasArg {
+				args = append(args, current.String())
+				current.Reset()
+				hasArg = false
+			}
+		default:
+			current.WriteRune(r)
+			hasArg = true
+		}
+	}
+	if hasArg {
+		args = append(args, current.String())
+	}
+	return args
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// ============================
+// Códigos de Error
+// ============================
+
+// Códigos de error estables que el ERP puede comparar en lugar de interpretar los mensajes
+const (
+	ErrCodePrinterNotFound     = "PRINTER_NOT_FOUND"
+	ErrCodePrinterNotReady     = "PRINTER_NOT_READY"
+	ErrCodePrinterOffline      = "PRINTER_OFFLINE"
+	ErrCodePaperOut            = "PAPER_OUT"
+	ErrCodePrinterTypeMismatch = "PRINTER_TYPE_MISMATCH"
+	ErrCodeDownloadBlocked     = "DOWNLOAD_BLOCKED"
+	ErrCodeDownloadFailed      = "DOWNLOAD_FAILED"
+	ErrCodeDownloadTimeout     = "DOWNLOAD_TIMEOUT"
+	ErrCodeInvalidPDF          = "INVALID_PDF"
+	ErrCodeInvalidBase64       = "INVALID_BASE64"
+	ErrCodeInvalidJSON         = "INVALID_JSON"
+	ErrCodePayloadTooLarge     = "PAYLOAD_TOO_LARGE"
+	ErrCodeLicenseInvalid      = "LICENSE_INVALID"
+	ErrCodeIPNotAllowed        = "IP_NOT_ALLOWED"
+	ErrCodeJobCanceled         = "JOB_CANCELED"
+	ErrCodeJobNotCancelable    = "JOB_NOT_CANCELABLE"
+	ErrCodeNotSupported        = "NOT_SUPPORTED"
+	ErrCodeSpoolerError        = "SPOOLER_ERROR"
+	ErrCodeDrawerError         = "DRAWER_ERROR"
+	ErrCodeInvalidRequest      = "INVALID_REQUEST"
+	ErrCodeUnauthorized        = "UNAUTHORIZED"
+	ErrCodeForbidden           = "FORBIDDEN"
+	ErrCodeNotFound            = "NOT_FOUND"
+	ErrCodeMethodNotAllowed    = "METHOD_NOT_ALLOWED"
+	ErrCodeConflict            = "CONFLICT"
+	ErrCodeUnprocessable       = "UNPROCESSABLE"
+	ErrCodeRateLimited         = "RATE_LIMITED"
+	ErrCodeInternal            = "INTERNAL_ERROR"
+	ErrCodeNotImplemented      = "NOT_IMPLEMENTED"
+	ErrCodeUnavailable         = "UNAVAILABLE"
+	ErrCodeTimeout             = "TIMEOUT"
+)
+
+// errorMessagesEN traduce cada código para los clientes que piden inglés (Accept-Language: en)
+var errorMessagesEN = map[string]string{
+	ErrCodePrinterNotFound:     "The printer does not exist",
+	ErrCodePrinterNotReady:     "The printer is not ready",
+	ErrCodePaperOut:            "The printer is out of paper",
+	ErrCodePrinterOffline:      "The printer is offline",
+	ErrCodePrinterTypeMismatch: "The printer profile does not support this job",
+	ErrCodeDownloadBlocked:     "The download policy rejected the document URL",
+	ErrCodeDownloadFailed:      "The document could not be downloaded",
+	ErrCodeDownloadTimeout:     "The document download timed out",
+	ErrCodeInvalidPDF:          "The document is not a valid PDF",
+	ErrCodeInvalidBase64:       "The base64 data is invalid",
+	ErrCodeInvalidJSON:         "Invalid JSON request",
+	ErrCodePayloadTooLarge:     "The request is too large",
+	ErrCodeLicenseInvalid:      "Invalid or inactive license",
+	ErrCodeIPNotAllowed:        "The source address is not allowed to call this endpoint",
+	ErrCodeJobCanceled:         "The job was canceled",
+	ErrCodeJobNotCancelable:    "The running job cannot be canceled",
+	ErrCodeNotSupported:        "Not supported by the printer backend",
+	ErrCodeSpoolerError:        "The print spooler rejected the job",
+	ErrCodeDrawerError:         "The cash drawer could not be opened",
+	ErrCodeInvalidRequest:      "Invalid request",
+	ErrCodeUnauthorized:        "Authentication required",
+	ErrCodeForbidden:           "Access denied",
+	ErrCodeNotFound:            "Not found",
+	ErrCodeMethodNotAllowed:    "HTTP method not allowed",
+	ErrCodeConflict:            "The request conflicts with the current state",
+	ErrCodeUnprocessable:       "The request could not be processed",
+	ErrCodeRateLimited:         "Too many requests",
+	ErrCodeInternal:            "Internal agent error",
+	ErrCodeNotImplemented:      "Not implemented",
+	ErrCodeUnavailable:         "Service unavailable",
+	ErrCodeTimeout:             "The operation timed out",
+}
+
+// errorCodes devuelve los códigos conocidos (documentación OpenAPI)
+func errorCodes() []string {
+	codes := make([]string, 0, len(errorMessagesEN))
+	for code := range errorMessagesEN {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// errorCode clasifica el error por su causa y, si no la reconoce, por el código HTTP
+func errorCode(status int, err error) string {
+	var notReady *PrinterNotReadyError
+	var corrupt base64.CorruptInputError
+	var tooLarge *http.MaxBytesError
+	var syntax *json.SyntaxError
+	var unmarshal *json.UnmarshalTypeError
+	switch {
+	case err == nil:
+	case errors.As(err, &notReady) && notReady.PaperOut:
+		return ErrCodePaperOut
+	case notReady != nil:
+		return ErrCodePrinterNotReady
+	case errors.Is(err, ErrPrinterOffline):
+		return ErrCodePrinterOffline
+	case errors.Is(err, ErrPrinterNotFound):
+		return ErrCodePrinterNotFound
+	case errors.Is(err, ErrPrinterTypeMismatch):
+		return ErrCodePrinterTypeMismatch
+	case errors.Is(err, ErrDownloadBlocked):
+		return ErrCodeDownloadBlocked
+	case errors.Is(err, ErrInvalidDocument):
+		return ErrCodeInvalidPDF
+	case errors.Is(err, ErrJobCanceled):
+		return ErrCodeJobCanceled
+	case errors.Is(err, ErrJobNotCancelable):
+		return ErrCodeJobNotCancelable
+	case errors.Is(err, ErrQueueUnsupported), errors.Is(err, ErrDrawerStatusUnsupported), errors.Is(err, ErrGDIUnsupported),
+		errors.Is(err, ErrXPSUnsupported), errors.Is(err, ErrPrinterAdminUnsupported):
+		return ErrCodeNotSupported
+	case errors.Is(err, ErrNoLicense):
+		return ErrCodeLicenseInvalid
+	case errors.As(err, &corrupt):
+		return ErrCodeInvalidBase64
+	case errors.As(err, &tooLarge):
+		return ErrCodePayloadTooLarge
+	case errors.As(err, &syntax) || errors.As(err, &unmarshal):
+		return ErrCodeInvalidJSON
+	case errors.Is(err, context.DeadlineExceeded):
+		if errors.Is(err, ErrDownloadFailed) {
+			return ErrCodeDownloadTimeout
+		}
+		return ErrCodeTimeout
+	case errors.Is(err, ErrDownloadFailed):
+		return ErrCodeDownloadFailed
+	}
+
+	switch status {
+	case http.StatusUnauthorized:
+		return ErrCodeUnauthorized
+	case http.StatusForbidden:
+		return ErrCodeForbidden
+	case http.StatusNotFound:
+		return ErrCodeNotFound
+	case http.StatusMethodNotAllowed:
+		return ErrCodeMethodNotAllowed
+	case http.StatusConflict:
+		return ErrCodeConflict
+	case http.StatusRequestEntityTooLarge:
+		return ErrCodePayloadTooLarge
+	case http.StatusUnprocessableEntity:
+		return ErrCodeUnprocessable
+	case http.StatusTooManyRequests:
+		return ErrCodeRateLimited
+	case http.StatusNotImplemented:
+		return ErrCodeNotImplemented
+	case http.StatusServiceUnavailable:
+		return ErrCodeUnavailable
+	case http.StatusGatewayTimeout:
+		return ErrCodeTimeout
+	}
+	if status >= 500 {
+		return ErrCodeInternal
+	}
+	return ErrCodeInvalidRequest
+}
+
+// jobErrorCode clasifica el error de un trabajo; lo que no tiene causa reconocida es un error del
+// spooler (o del cajón, en los trabajos de apertura)
+func jobErrorCode(job *PrintJob, err error) string {
+	code := errorCode(0, err)
+	if code != ErrCodeInvalidRequest {
+		return code
+	}
+	if job.Kind == JobKindDrawer {
+		return ErrCodeDrawerError
+	}
+	return ErrCodeSpoolerError
+}
+
+// errorLanguage elige el idioma de los mensajes de error según Accept-Language: inglés si el
+// cliente lo prefiere, español en cualquier otro caso
+func errorLanguage(r *http.Request) string {
+	for _, part := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+		tag, _, _ := strings.Cut(strings.TrimSpace(part), ";")
+		lang, _, _ := strings.Cut(strings.ToLower(tag), "-")
+		switch lang {
+		case "en":
+			return "en"
+		case "es":
+			return "es"
+		}
+	}
+	return "es"
+}
+
+// localizedMessage devuelve el mensaje del error en el idioma de la respuesta (Content-Language)
+func localizedMessage(w http.ResponseWriter, code, message string) string {
+	if w.Header().Get("Content-Language") == "en" {
+		if translated, ok := errorMessagesEN[code]; ok {
+			return translated
+		}
+	}
+	return message
+}
@@ -0,0 +1,357 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/unicode/norm"
+)
+
+// ============================
+// Comandos ESC/POS
+// ============================
+
+// Bytes de control ESC/POS
+const (
+	escposESC = 0x1B
+	escposFS  = 0x1C
+	escposGS  = 0x1D
+)
+
+// Conectores del pulso del cajón (ESC p m)
+const (
+	DrawerPin2 = 2 // conector 2 (m = 0)
+	DrawerPin5 = 5 // conector 5 (m = 1)
+)
+
+// DrawerKickCommand genera la secuencia ESC p m t1 t2 que envía el pulso de apertura del cajón.
+// onMs y offMs se expresan en milisegundos; el protocolo los codifica en unidades de 2ms (máximo 510ms).
+func DrawerKickCommand(pin, onMs, offMs int) ([]byte, error) {
+	var m byte
+	switch pin {
+	case DrawerPin2:
+		m = 0
+	case DrawerPin5:
+		m = 1
+	default:
+		return nil, fmt.Errorf("conector de cajón inválido: %d (use 2 o 5)", pin)
+	}
+	t1, err := escposPulseUnits(onMs)
+	if err != nil {
+		return nil, err
+	}
+	t2, err := escposPulseUnits(offMs)
+	if err != nil {
+		return nil, err
+	}
+	return []byte{escposESC, 'p', m, t1, t2}, nil
+}
+
+func escposPulseUnits(ms int) (byte, error) {
+	if ms < 2 || ms > 510 {
+		return 0, fmt.Errorf("duración de pulso inválida: %dms (rango 2-510)", ms)
+	}
+	return byte(ms / 2), nil
+}
+
+// Alineación del texto (ESC a n)
+const (
+	AlignLeft   = "left"
+	AlignCenter = "center"
+	AlignRight  = "right"
+)
+
+// escposCodepage es una página de códigos de los caracteres de la impresora: el número que la
+// selecciona con ESC t n (numeración de Epson, que siguen la mayoría de las térmicas) y su tabla
+type escposCodepage struct {
+	table   byte
+	charmap *charmap.Charmap
+}
+
+// escposCodepageTables son las páginas de códigos con acentos y ñ; "ascii" no selecciona ninguna
+// y translitera el texto
+var escposCodepageTables = map[string]escposCodepage{
+	"cp437":        {0, charmap.CodePage437},
+	"cp850":        {2, charmap.CodePage850},
+	"cp858":        {19, charmap.CodePage858}, // CP850 con el símbolo del euro
+	"cp1252":       {16, charmap.Windows1252},
+	"windows-1252": {16, charmap.Windows1252},
+}
+
+// ESCPOSBuffer acumula comandos ESC/POS para enviarlos como un único trabajo RAW
+type ESCPOSBuffer struct {
+	buf      bytes.Buffer
+	codepage *escposCodepage
+}
+
+// Bytes devuelve los comandos acumulados
+func (b *ESCPOSBuffer) Bytes() []byte {
+	return b.buf.Bytes()
+}
+
+// Init reinicia la impresora a su configuración predeterminada (ESC @)
+func (b *ESCPOSBuffer) Init() {
+	b.buf.Write([]byte{escposESC, '@'})
+}
+
+// Codepage selecciona la página de códigos del texto siguiente (ESC t n); con "ascii" o una página
+// desconocida el texto se translitera. Se debe llamar después de Init, que la restablece.
+func (b *ESCPOSBuffer) Codepage(name string) {
+	cp, ok := escposCodepageTables[name]
+	if !ok {
+		b.codepage = nil
+		return
+	}
+	b.codepage = &cp
+	b.buf.Write([]byte{escposESC, 't', cp.table})
+}
+
+// Align fija la alineación de las líneas siguientes (ESC a n)
+func (b *ESCPOSBuffer) Align(align string) {
+	var n byte
+	switch align {
+	case AlignCenter:
+		n = 1
+	case AlignRight:
+		n = 2
+	}
+	b.buf.Write([]byte{escposESC, 'a', n})
+}
+
+// Bold activa o desactiva la negrita (ESC E n)
+func (b *ESCPOSBuffer) Bold(on bool) {
+	var n byte
+	if on {
+		n = 1
+	}
+	b.buf.Write([]byte{escposESC, 'E', n})
+}
+
+// Size fija el multiplicador de ancho y alto de los caracteres, de 1 a 8 (GS ! n)
+func (b *ESCPOSBuffer) Size(width, height int) {
+	b.buf.Write([]byte{escposGS, '!', byte((width-1)<<4 | (height - 1))})
+}
+
+// Line escribe el texto seguido de un salto de línea en la página de códigos seleccionada; los
+// caracteres que no existen en ella se transliteran
+func (b *ESCPOSBuffer) Line(text string) {
+	if b.codepage == nil {
+		b.buf.WriteString(escposASCII(text))
+	} else {
+		b.buf.Write(escposEncode(text, b.codepage.charmap))
+	}
+	b.buf.WriteByte('\n')
+}
+
+// Feed avanza el papel n líneas (ESC d n)
+func (b *ESCPOSBuffer) Feed(lines int) {
+	if lines > 0 {
+		b.buf.Write([]byte{escposESC, 'd', byte(lines)})
+	}
+}
+
+// Cut avanza el papel hasta la cuchilla y corta (GS V 65/66 0); partial deja una unión central
+func (b *ESCPOSBuffer) Cut(partial bool) {
+	m := byte(65)
+	if partial {
+		m = 66
+	}
+	b.buf.Write([]byte{escposGS, 'V', m, 0})
+}
+
+// Raw agrega bytes sin procesar (p. ej. un comando de código de barras ya armado)
+func (b *ESCPOSBuffer) Raw(data []byte) {
+	b.buf.Write(data)
+}
+
+// escposTransliterations reemplaza los caracteres sin equivalente ASCII que no se resuelven
+// quitando los acentos
+var escposTransliterations = map[rune]string{
+	'¿': "?", '¡': "!", '€': "EUR", 'º': "o", 'ª': "a", '°': "o", '«': "\"", '»': "\"",
+	'“': "\"", '”': "\"", '‘': "'", '’': "'", '–': "-", '—': "-", '…': "...", '·': ".",
+	'₱': "PHP",
+}
+
+// escposASCII convierte el texto a ASCII quitando acentos ("Año Café" -> "Ano Cafe"), que todas
+// las impresoras térmicas imprimen sin importar la página de códigos configurada
+func escposASCII(text string) string {
+	var out strings.Builder
+	for _, r := range norm.NFD.String(text) {
+		switch {
+		case r < 0x80:
+			out.WriteRune(r)
+		case unicode.Is(unicode.Mn, r):
+			// marca diacrítica separada por NFD: se descarta
+		default:
+			if repl, ok := escposTransliterations[r]; ok {
+				out.WriteString(repl)
+			} else {
+				out.WriteByte('?')
+			}
+		}
+	}
+	return out.String()
+}
+
+// escposEncode codifica el texto en la página de códigos; los caracteres que no tiene (p. ej. "€"
+// en CP850) se transliteran como en escposASCII
+func escposEncode(text string, cm *charmap.Charmap) []byte {
+	var out []byte
+	for _, r := range norm.NFC.String(text) {
+		if c, ok := cm.EncodeRune(r); ok {
+			out = append(out, c)
+			continue
+		}
+		out = append(out, escposASCII(string(r))...)
+	}
+	return out
+}
+
+// Niveles de corrección de errores del código QR
+var escposQRCorrection = map[string]byte{"L": 48, "M": 49, "Q": 50, "H": 51}
+
+// QR imprime un código QR modelo 2 con el comando nativo GS ( k. moduleSize es el tamaño del
+// módulo en puntos (1-16) y correction el nivel de corrección (L, M, Q o H).
+func (b *ESCPOSBuffer) QR(data string, moduleSize int, correction string) {
+	qr := func(fn byte, params ...byte) {
+		n := len(params) + 2
+		b.buf.Write([]byte{escposGS, '(', 'k', byte(n), byte(n >> 8), 49, fn})
+		b.buf.Write(params)
+	}
+	qr(65, 50, 0)                          // modelo 2
+	qr(67, byte(moduleSize))               // tamaño del módulo
+	qr(69, escposQRCorrection[correction]) // corrección de errores
+	qr(80, append([]byte{48}, data...)...) // almacenar los datos
+	qr(81, 48)                             // imprimir
+}
+
+// Simbologías de código de barras (GS k m, formato con longitud)
+var escposBarcodeSymbologies = map[string]byte{
+	"upca":    65,
+	"ean13":   67,
+	"ean8":    68,
+	"code39":  69,
+	"code128": 73,
+}
+
+// Barcode imprime un código de barras con GS k. height es el alto en puntos y hri indica si se
+// imprime el texto legible debajo del código.
+func (b *ESCPOSBuffer) Barcode(symbology, data string, height int, hri bool) {
+	var position byte
+	if hri {
+		position = 2
+	}
+	b.buf.Write([]byte{escposGS, 'H', position})
+	b.buf.Write([]byte{escposGS, 'h', byte(height)})
+	b.buf.Write([]byte{escposGS, 'w', 2})
+	if symbology == "code128" {
+		data = "{B" + data // juego de caracteres B: ASCII imprimible
+	}
+	b.buf.Write([]byte{escposGS, 'k', escposBarcodeSymbologies[symbology], byte(len(data))})
+	b.buf.WriteString(data)
+}
+
+// rasterBandRows limita el alto de cada comando GS v 0: varias impresoras descartan las imágenes
+// que no entran en su búfer de recepción
+const rasterBandRows = 256
+
+// Raster imprime la imagen como mapa de bits (GS v 0) en franjas de hasta rasterBandRows filas: los
+// puntos más oscuros que el gris medio se imprimen en negro. El ancho de la imagen no debe superar
+// el ancho imprimible del rollo.
+func (b *ESCPOSBuffer) Raster(img image.Image) {
+	bounds := img.Bounds()
+	widthBytes := (bounds.Dx() + 7) / 8
+	row := make([]byte, widthBytes)
+	for top := bounds.Min.Y; top < bounds.Max.Y; top += rasterBandRows {
+		bottom := min(top+rasterBandRows, bounds.Max.Y)
+		height := bottom - top
+		b.buf.Write([]byte{escposGS, 'v', '0', 0, byte(widthBytes), byte(widthBytes >> 8), byte(height), byte(height >> 8)})
+		for y := top; y < bottom; y++ {
+			for i := range row {
+				row[i] = 0
+			}
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				if color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y < 128 {
+					i := x - bounds.Min.X
+					row[i/8] |= 0x80 >> (i % 8)
+				}
+			}
+			b.buf.Write(row)
+		}
+	}
+}
+
+// nvGraphicKey es la clave de dos caracteres (kc1 kc2) con la que se guarda el logo de la posición
+// slot: la posición 1 es "01"
+func nvGraphicKey(slot int) (byte, byte) {
+	return byte('0' + slot/10%10), byte('0' + slot%10)
+}
+
+// StoreNVGraphic guarda la imagen en la memoria no volátil de la impresora con GS ( L función 67
+// (formato raster), reemplazando el logo que tuviera la misma posición. Las imágenes de más de
+// 64 KB se envían con la forma extendida GS 8 L.
+func (b *ESCPOSBuffer) StoreNVGraphic(slot int, img image.Image) {
+	bounds := img.Bounds()
+	widthBytes := (bounds.Dx() + 7) / 8
+	kc1, kc2 := nvGraphicKey(slot)
+	params := []byte{48, 67, 48, kc1, kc2, 1, byte(bounds.Dx()), byte(bounds.Dx() >> 8), byte(bounds.Dy()), byte(bounds.Dy() >> 8), 49}
+	size := len(params) + widthBytes*bounds.Dy()
+	if size <= 0xFFFF {
+		b.buf.Write([]byte{escposGS, '(', 'L', byte(size), byte(size >> 8)})
+	} else {
+		b.buf.Write([]byte{escposGS, '8', 'L', byte(size), byte(size >> 8), byte(size >> 16), byte(size >> 24)})
+	}
+	b.buf.Write(params)
+	row := make([]byte, widthBytes)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for i := range row {
+			row[i] = 0
+		}
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y < 128 {
+				i := x - bounds.Min.X
+				row[i/8] |= 0x80 >> (i % 8)
+			}
+		}
+		b.buf.Write(row)
+	}
+}
+
+// PrintNVGraphic imprime el logo guardado con StoreNVGraphic en la posición slot (GS ( L función 69)
+func (b *ESCPOSBuffer) PrintNVGraphic(slot int) {
+	kc1, kc2 := nvGraphicKey(slot)
+	b.buf.Write([]byte{escposGS, '(', 'L', 6, 0, 48, 69, kc1, kc2, 1, 1})
+}
+
+// StoreNVBitImage guarda la imagen como imagen de bits NV 1 con el comando anterior FS q, que
+// entienden las impresoras que no tienen GS ( L. FS q borra todas las imágenes guardadas antes. El
+// ancho y el alto se completan hasta múltiplos de 8 puntos; los datos van por columnas, con el
+// bit más significativo arriba.
+func (b *ESCPOSBuffer) StoreNVBitImage(img image.Image) {
+	bounds := img.Bounds()
+	x, y := (bounds.Dx()+7)/8, (bounds.Dy()+7)/8
+	b.buf.Write([]byte{escposFS, 'q', 1, byte(x), byte(x >> 8), byte(y), byte(y >> 8)})
+	column := make([]byte, y)
+	for col := 0; col < x*8; col++ {
+		for i := range column {
+			column[i] = 0
+		}
+		for row := 0; row < y*8; row++ {
+			px, py := bounds.Min.X+col, bounds.Min.Y+row
+			if px < bounds.Max.X && py < bounds.Max.Y && color.GrayModel.Convert(img.At(px, py)).(color.Gray).Y < 128 {
+				column[row/8] |= 0x80 >> (row % 8)
+			}
+		}
+		b.buf.Write(column)
+	}
+}
+
+// PrintNVBitImage imprime la imagen de bits NV n guardada con FS q, en tamaño normal (FS p)
+func (b *ESCPOSBuffer) PrintNVBitImage(n int) {
+	b.buf.Write([]byte{escposFS, 'p', byte(n), 0})
+}
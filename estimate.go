@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// ============================
+// Estimación de Trabajos de Impresión
+// ============================
+
+// Umbrales a partir de los cuales la estimación incluye advertencias
+const (
+	estimateWarnPages    = 20
+	estimateWarnLengthMM = 1000
+)
+
+// defaultRollWidthMM es el ancho de rollo térmico usado si la solicitud no lo indica
+const defaultRollWidthMM = 80
+
+// PrintEstimate es el resultado de analizar un documento sin imprimirlo
+type PrintEstimate struct {
+	*PDFInspection
+	RollWidthMM              float64  `json:"roll_width_mm"`
+	EstimatedThermalLengthMM float64  `json:"estimated_thermal_length_mm"`
+	Warnings                 []string `json:"warnings"`
+}
+
+// EstimateDocument descarga (o decodifica) el documento y estima su consumo de papel.
+// Para impresoras térmicas cada página se escala al ancho del rollo, por lo que el largo
+// estimado es la suma de las alturas de página proporcionales a ese ancho.
+func (d DefaultPrinterService) EstimateDocument(ctx context.Context, fileURL, data string, rollWidthMM float64) (*PrintEstimate, error) {
+	var filePath string
+	var err error
+	if data != "" {
+		filePath, err = saveTempFile(base64DocumentReader(data))
+		if err != nil {
+			err = fmt.Errorf("error al guardar el archivo recibido: %w", err)
+		}
+	} else {
+		filePath, err = d.downloadDocument(ctx, fileURL)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := os.Remove(filePath); err != nil {
+			d.Logger.Errorf("Error al eliminar archivo temporal: %v", err)
+		}
+	}()
+
+	info, err := InspectPDF(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if rollWidthMM <= 0 {
+		rollWidthMM = defaultRollWidthMM
+	}
+	estimate := &PrintEstimate{PDFInspection: info, RollWidthMM: rollWidthMM}
+	estimate.EstimatedThermalLengthMM = thermalLengthMM(info, rollWidthMM)
+	estimate.Warnings = paperWarnings(info, rollWidthMM, estimate.EstimatedThermalLengthMM)
+	return estimate, nil
+}
+
+// thermalLengthMM suma las alturas de página proporcionales al ancho del rollo
+func thermalLengthMM(info *PDFInspection, rollWidthMM float64) float64 {
+	var length float64
+	for _, page := range info.Pages {
+		if page.WidthMM > 0 {
+			length += page.HeightMM * rollWidthMM / page.WidthMM
+		}
+	}
+	return roundMM(length)
+}
+
+// paperWarnings advierte de los documentos con demasiadas páginas o que consumirían demasiado rollo
+func paperWarnings(info *PDFInspection, rollWidthMM, lengthMM float64) []string {
+	warnings := []string{}
+	if info.PageCount > estimateWarnPages {
+		warnings = append(warnings,
+			fmt.Sprintf("El documento tiene %d páginas; verifique que no se envíe a una impresora de recibos", info.PageCount))
+	}
+	if lengthMM > estimateWarnLengthMM {
+		warnings = append(warnings,
+			fmt.Sprintf("En un rollo de %.0fmm consumiría aproximadamente %.1f metros de papel", rollWidthMM, lengthMM/1000))
+	}
+	return warnings
+}
+
+// EstimateRequest es el cuerpo de POST /estimate
+type EstimateRequest struct {
+	URL         string  `json:"url"`
+	Data        string  `json:"data"`
+	RollWidthMM float64 `json:"roll_width_mm"`
+}
+
+// EstimateHandler maneja la solicitud para estimar un documento sin imprimirlo
+func (h Handlers) EstimateHandler(w http.ResponseWriter, r *http.Request) {
+	h.Logger = h.Logger.ForRequest(r)
+	h.Logger.Info("Received request: /estimate")
+
+	if r.Method != http.MethodPost {
+		h.Logger.Warnf("Método HTTP no permitido: %s", r.Method)
+		WriteErrorJSON(w, http.StatusMethodNotAllowed, "Método HTTP no permitido", nil)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, h.MaxUploadBytes*4/3+4096)
+
+	var req EstimateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.Logger.Warnf("Error al decodificar JSON: %v", err)
+		WriteErrorJSON(w, http.StatusBadRequest, "Solicitud JSON inválida", err)
+		return
+	}
+
+	if (req.URL == "") == (req.Data == "") {
+		h.Logger.Warn("Se debe especificar url o data")
+		WriteErrorJSON(w, http.StatusBadRequest, "Especifique uno de los campos url o data", nil)
+		return
+	}
+
+	estimate, err := h.Service.EstimateDocument(r.Context(), req.URL, req.Data, req.RollWidthMM)
+	if err != nil {
+		h.Logger.Errorf("Error al estimar el documento: %v", err)
+		WriteErrorJSON(w, http.StatusUnprocessableEntity, "Error al analizar el documento", err)
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, estimate)
+}
@@ -0,0 +1,435 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// ============================
+// Eventos en Tiempo Real
+// ============================
+
+// Tipos de evento publicados a los clientes conectados
+const (
+	EventJobQueued      = "job.queued"
+	EventJobStarted     = "job.started"
+	EventPrinterOnline  = "printer.online"
+	EventPrinterOffline = "printer.offline"
+	EventDrawerOpened   = "drawer.opened"
+	EventDrawerClosed   = "drawer.closed"
+	EventDrawerLeftOpen = "drawer.left_open"
+	// EventStreamReset indica al cliente que los eventos desde su último id ya no están disponibles
+	// (desconexión larga o reinicio del agente) y debe consultar /jobs para ponerse al día
+	EventStreamReset = "stream.reset"
+)
+
+const (
+	// eventBufferSize es la cantidad de eventos pendientes por cliente; si se llena (cliente lento)
+	// los eventos nuevos se descartan para no frenar los trabajos
+	eventBufferSize = 64
+	// wsPingInterval es la frecuencia de los ping que mantienen viva la conexión en proxies y firewalls
+	wsPingInterval = 30 * time.Second
+	// wsWriteTimeout limita el envío de cada mensaje a un cliente
+	wsWriteTimeout = 10 * time.Second
+	// eventHistorySize es la cantidad de eventos recientes que se conservan para reanudar /events
+	eventHistorySize = 500
+	// sseKeepAlive es la frecuencia de los comentarios que mantienen abierta la conexión de /events
+	sseKeepAlive = 15 * time.Second
+)
+
+// Event es un cambio de estado enviado a los clientes: los eventos de trabajos llevan el trabajo
+// (igual que /jobs/{id}) y los de impresoras y cajón, la impresora afectada
+type Event struct {
+	ID   uint64      `json:"id"`
+	Type string      `json:"type"`
+	Time time.Time   `json:"time"`
+	Data interface{} `json:"data"`
+}
+
+// eventSubscriber es un cliente conectado con los prefijos de evento que le interesan
+type eventSubscriber struct {
+	ch    chan Event
+	types []string
+}
+
+// wants indica si el cliente pidió el tipo de evento (sin filtro recibe todos)
+func (s *eventSubscriber) wants(eventType string) bool {
+	if len(s.types) == 0 {
+		return true
+	}
+	for _, t := range s.types {
+		if eventType == t || strings.HasPrefix(eventType, t+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// EventBus reparte los eventos del agente entre los clientes conectados. Los identificadores son
+// correlativos para que el cliente detecte eventos perdidos; los últimos eventos se conservan en
+// memoria para que un cliente que se reconecta reciba los que se perdió.
+type EventBus struct {
+	mu      sync.Mutex
+	nextID  uint64
+	subs    map[*eventSubscriber]struct{}
+	history []Event
+}
+
+// NewEventBus crea un bus sin clientes
+func NewEventBus() *EventBus {
+	return &EventBus{subs: make(map[*eventSubscriber]struct{})}
+}
+
+// Publish envía el evento a los clientes que lo pidieron; no bloquea si un cliente no lo recibe
+func (b *EventBus) Publish(eventType string, data interface{}) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.nextID++
+	event := Event{ID: b.nextID, Type: eventType, Time: time.Now(), Data: data}
+	if len(b.history) == eventHistorySize {
+		b.history = append(b.history[:0], b.history[1:]...)
+	}
+	b.history = append(b.history, event)
+	for sub := range b.subs {
+		if !sub.wants(eventType) {
+			continue
+		}
+		select {
+		case sub.ch <- event:
+		default:
+		}
+	}
+}
+
+// Subscribe registra un cliente para los tipos indicados ("job" incluye todos los job.*; vacío,
+// todos los eventos). La función devuelta lo da de baja.
+func (b *EventBus) Subscribe(types []string) (<-chan Event, func()) {
+	_, ch, unsubscribe := b.Resume(types, 0)
+	return ch, unsubscribe
+}
+
+// Resume es como Subscribe, pero devuelve además los eventos posteriores a lastID que el cliente
+// no recibió. Si ya no están todos en memoria (o el agente se reinició y lastID no existe), la
+// lista empieza con un evento stream.reset. lastID 0 no recupera eventos.
+func (b *EventBus) Resume(types []string, lastID uint64) ([]Event, <-chan Event, func()) {
+	sub := &eventSubscriber{ch: make(chan Event, eventBufferSize), types: types}
+	b.mu.Lock()
+	var missed []Event
+	if lastID > 0 {
+		missed = b.sinceLocked(sub, lastID)
+	}
+	b.subs[sub] = struct{}{}
+	b.mu.Unlock()
+	return missed, sub.ch, func() {
+		b.mu.Lock()
+		delete(b.subs, sub)
+		b.mu.Unlock()
+	}
+}
+
+// sinceLocked devuelve los eventos del cliente posteriores a lastID
+func (b *EventBus) sinceLocked(sub *eventSubscriber, lastID uint64) []Event {
+	var missed []Event
+	oldest := b.nextID + 1
+	if len(b.history) > 0 {
+		oldest = b.history[0].ID
+	}
+	if lastID > b.nextID || lastID+1 < oldest {
+		missed = append(missed, Event{Type: EventStreamReset, Time: time.Now(), Data: map[string]interface{}{"last_event_id": lastID}})
+	}
+	for _, event := range b.history {
+		if event.ID > lastID && sub.wants(event.Type) {
+			missed = append(missed, event)
+		}
+	}
+	return missed
+}
+
+// Subscribers devuelve la cantidad de clientes conectados
+func (b *EventBus) Subscribers() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subs)
+}
+
+// publish publica el evento del trabajo con una copia de su estado actual
+func (j *JobRunner) publish(eventType string, job *PrintJob) {
+	if j.Events == nil {
+		return
+	}
+	snapshot := *job
+	j.Events.Publish(eventType, &snapshot)
+}
+
+// ============================
+// Monitoreo de Impresoras
+// ============================
+
+// PrinterWatcher consulta el estado de las impresoras mientras haya clientes conectados y publica
+// printer.offline / printer.online cuando cambia
+type PrinterWatcher struct {
+	Service PrinterService
+	Events  *EventBus
+	Poll    time.Duration
+	Logger  *Logger
+
+	online map[string]bool
+	done   chan struct{}
+}
+
+// NewPrinterWatcher crea el monitor con el intervalo de consulta indicado
+func NewPrinterWatcher(service PrinterService, events *EventBus, pollSeconds int, logger *Logger) *PrinterWatcher {
+	return &PrinterWatcher{
+		Service: service,
+		Events:  events,
+		Poll:    time.Duration(pollSeconds) * time.Second,
+		Logger:  logger,
+		online:  make(map[string]bool),
+		done:    make(chan struct{}),
+	}
+}
+
+// Start inicia el monitoreo en segundo plano
+func (w *PrinterWatcher) Start() {
+	go w.run()
+}
+
+// Close detiene el monitoreo
+func (w *PrinterWatcher) Close() error {
+	close(w.done)
+	return nil
+}
+
+func (w *PrinterWatcher) run() {
+	defer recoverCrash()
+	ticker := time.NewTicker(w.Poll)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-w.done:
+			return
+		}
+		// Sin clientes no se consulta a las impresoras; el estado se vuelve a tomar como referencia
+		// cuando alguien se conecta
+		if w.Events.Subscribers() == 0 {
+			clear(w.online)
+			continue
+		}
+		w.check()
+	}
+}
+
+// check compara el estado de cada impresora con la consulta anterior
+func (w *PrinterWatcher) check() {
+	printers, err := w.Service.GetPrinters()
+	if err != nil {
+		w.Logger.Warnf("No se pudo listar las impresoras para los eventos: %v", err)
+		return
+	}
+	for _, p := range printers {
+		name := p["Name"]
+		if name == "" {
+			continue
+		}
+		status, err := w.Service.PrinterStatus(name)
+		online := err == nil && status.Online
+		previous, known := w.online[name]
+		w.online[name] = online
+		if !known || previous == online {
+			continue
+		}
+		eventType := EventPrinterOffline
+		if online {
+			eventType = EventPrinterOnline
+		}
+		data := map[string]interface{}{"printer": name, "online": online}
+		if status != nil {
+			data["status"] = status
+		}
+		w.Events.Publish(eventType, data)
+	}
+}
+
+// ============================
+// WebSocket y Server-Sent Events
+// ============================
+
+// EventHandlers atiende las conexiones de eventos en tiempo real (/ws y /events)
+type EventHandlers struct {
+	Events         *EventBus
+	AllowedOrigins []string
+	Logger         *Logger
+}
+
+// checkOrigin acepta los mismos orígenes que CORS (ALLOWED_ORIGINS); los clientes sin Origin
+// (aplicaciones de escritorio, scripts) se aceptan siempre
+func (h EventHandlers) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	for _, allowed := range h.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	return false
+}
+
+// WebSocketHandler atiende /ws: envía como JSON cada evento del agente (trabajos, impresoras,
+// cajón). ?types=job,printer.offline limita los eventos recibidos.
+func (h EventHandlers) WebSocketHandler(w http.ResponseWriter, r *http.Request) {
+	logger := h.Logger.ForRequest(r)
+	logger.Info("Received request: /ws")
+
+	upgrader := websocket.Upgrader{CheckOrigin: h.checkOrigin}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade ya respondió al cliente con el error
+		logger.Warnf("Conexión WebSocket rechazada desde %s: %v", r.RemoteAddr, err)
+		return
+	}
+	defer conn.Close()
+
+	events, unsubscribe := h.Events.Subscribe(splitAndTrim(r.URL.Query().Get("types"), ","))
+	defer unsubscribe()
+	logger.Infof("Cliente de eventos conectado desde %s", r.RemoteAddr)
+
+	// El cliente no envía mensajes: la lectura solo detecta el cierre y procesa los pong
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ping := time.NewTicker(wsPingInterval)
+	defer ping.Stop()
+	for {
+		select {
+		case event := <-events:
+			conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+			if err := conn.WriteJSON(event); err != nil {
+				logger.Warnf("Cliente de eventos desconectado (%s): %v", r.RemoteAddr, err)
+				return
+			}
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout)); err != nil {
+				return
+			}
+		case <-closed:
+			logger.Infof("Cliente de eventos desconectado desde %s", r.RemoteAddr)
+			return
+		}
+	}
+}
+
+// EventsHandler atiende GET /events con Server-Sent Events, para los clientes que no pueden usar
+// WebSocket (proxies que no permiten Upgrade). Envía los mismos eventos que /ws; el navegador
+// reenvía el último id en Last-Event-ID al reconectarse y el agente le envía los que se perdió.
+func (h EventHandlers) EventsHandler(w http.ResponseWriter, r *http.Request) {
+	logger := h.Logger.ForRequest(r)
+	logger.Info("Received request: /events")
+
+	if r.Method != http.MethodGet {
+		WriteErrorJSON(w, http.StatusMethodNotAllowed, "Método HTTP no permitido", nil)
+		return
+	}
+	// EventSource no permite enviar cabeceras en la primera conexión: se acepta también ?last_event_id=
+	lastHeader := r.Header.Get("Last-Event-ID")
+	if lastHeader == "" {
+		lastHeader = r.URL.Query().Get("last_event_id")
+	}
+	var lastID uint64
+	if lastHeader != "" {
+		id, err := strconv.ParseUint(lastHeader, 10, 64)
+		if err != nil {
+			WriteErrorJSON(w, http.StatusBadRequest, "Last-Event-ID inválido", err)
+			return
+		}
+		lastID = id
+	}
+
+	// La conexión queda abierta: sin el límite de escritura del servidor (HTTP_WRITE_TIMEOUT)
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		logger.Warnf("No se pudo quitar el límite de escritura de /events: %v", err)
+	}
+
+	missed, events, unsubscribe := h.Events.Resume(splitAndTrim(r.URL.Query().Get("types"), ","), lastID)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	// Evita que nginx y proxies similares acumulen la respuesta
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: 3000\n\n")
+	for _, event := range missed {
+		if err := writeSSE(w, event); err != nil {
+			return
+		}
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+	logger.Infof("Cliente de eventos (SSE) conectado desde %s, %d eventos recuperados", r.RemoteAddr, len(missed))
+
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		var err error
+		select {
+		case event := <-events:
+			err = writeSSE(w, event)
+		case <-keepAlive.C:
+			_, err = fmt.Fprintf(w, ": ping\n\n")
+		case <-r.Context().Done():
+			logger.Infof("Cliente de eventos (SSE) desconectado desde %s", r.RemoteAddr)
+			return
+		}
+		if err == nil {
+			err = rc.Flush()
+		}
+		if err != nil {
+			logger.Warnf("Cliente de eventos (SSE) desconectado (%s): %v", r.RemoteAddr, err)
+			return
+		}
+	}
+}
+
+// writeSSE escribe el evento en formato Server-Sent Events; el id permite reanudar la conexión.
+// stream.reset no tiene id para que el navegador conserve el último recibido.
+func writeSSE(w http.ResponseWriter, event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if event.ID > 0 {
+		if _, err := fmt.Fprintf(w, "id: %d\n", event.ID); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(w, "data: %s\n\n", data)
+	return err
+}
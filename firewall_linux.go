@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// ============================
+// Reglas del Firewall (ufw)
+// ============================
+
+// firewallRuleName es el comentario con el que se registra la regla de entrada del agente
+const firewallRuleName = "PrinterMatiasERP"
+
+// AddFirewallRule permite las conexiones entrantes al puerto configurado y las consultas mDNS
+// (UDP 5353) con ufw
+func AddFirewallRule(port int) error {
+	if err := runUFW("allow", strconv.Itoa(port)+"/tcp", "comment", firewallRuleName); err != nil {
+		return err
+	}
+	return runUFW("allow", "5353/udp", "comment", firewallRuleName)
+}
+
+// RemoveFirewallRule elimina las reglas de ufw con el comentario del agente
+func RemoveFirewallRule() error {
+	output, err := exec.Command("ufw", "status", "numbered").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("error al ejecutar ufw (¿está instalado y se ejecuta como root?): %v, salida: %s", err, string(output))
+	}
+	// Las reglas se eliminan desde la última para que no cambie la numeración de las restantes
+	var numbers []string
+	for _, line := range strings.Split(string(output), "\n") {
+		if !strings.HasSuffix(strings.TrimSpace(line), "# "+firewallRuleName) {
+			continue
+		}
+		if start, end := strings.Index(line, "["), strings.Index(line, "]"); start >= 0 && end > start {
+			numbers = append([]string{strings.TrimSpace(line[start+1 : end])}, numbers...)
+		}
+	}
+	for _, n := range numbers {
+		if err := runUFW("--force", "delete", n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runUFW ejecuta ufw y devuelve su salida en caso de error
+func runUFW(args ...string) error {
+	output, err := exec.Command("ufw", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("error al ejecutar ufw (¿está instalado y se ejecuta como root?): %v, salida: %s", err, string(output))
+	}
+	return nil
+}
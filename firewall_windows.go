@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"syscall"
+)
+
+// ============================
+// Reglas del Firewall de Windows
+// ============================
+
+// firewallRuleName es el nombre con el que se registra la regla de entrada del agente
+const firewallRuleName = "PrinterMatiasERP"
+
+// AddFirewallRule crea (o reemplaza) la regla de entrada TCP para el puerto configurado y la de
+// UDP 5353 para el anuncio mDNS; ambas llevan el mismo nombre
+func AddFirewallRule(port int) error {
+	// Se elimina la regla previa para no acumular duplicados cuando cambia el puerto
+	_ = RemoveFirewallRule()
+
+	if err := runNetsh("advfirewall", "firewall", "add", "rule",
+		"name="+firewallRuleName,
+		"dir=in",
+		"action=allow",
+		"protocol=TCP",
+		"localport="+strconv.Itoa(port),
+		"profile=any",
+	); err != nil {
+		return err
+	}
+	return runNetsh("advfirewall", "firewall", "add", "rule",
+		"name="+firewallRuleName,
+		"dir=in",
+		"action=allow",
+		"protocol=UDP",
+		"localport=5353",
+		"profile=private,domain",
+	)
+}
+
+// RemoveFirewallRule elimina las reglas de entrada del agente
+func RemoveFirewallRule() error {
+	return runNetsh("advfirewall", "firewall", "delete", "rule", "name="+firewallRuleName)
+}
+
+// runNetsh ejecuta netsh con la ventana oculta y devuelve su salida en caso de error
+func runNetsh(args ...string) error {
+	cmd := exec.Command("netsh", args...)
+
+	// Configura SysProcAttr para ocultar la ventana de netsh
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		HideWindow: true,
+	}
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("error al ejecutar netsh: %v, salida: %s", err, string(output))
+	}
+	return nil
+}
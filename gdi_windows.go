@@ -0,0 +1,241 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// ============================
+// Impresión GDI (StartDoc/StartPage)
+// ============================
+
+var (
+	modGDI32 = windows.NewLazySystemDLL("gdi32.dll")
+
+	procCreateDCW         = modGDI32.NewProc("CreateDCW")
+	procDeleteDC          = modGDI32.NewProc("DeleteDC")
+	procGetDeviceCaps     = modGDI32.NewProc("GetDeviceCaps")
+	procStartDocW         = modGDI32.NewProc("StartDocW")
+	procEndDoc            = modGDI32.NewProc("EndDoc")
+	procAbortDoc          = modGDI32.NewProc("AbortDoc")
+	procStartPage         = modGDI32.NewProc("StartPage")
+	procEndPage           = modGDI32.NewProc("EndPage")
+	procSetStretchBltMode = modGDI32.NewProc("SetStretchBltMode")
+	procStretchDIBits     = modGDI32.NewProc("StretchDIBits")
+)
+
+// Índices de GetDeviceCaps
+const (
+	capHorzRes    = 8
+	capVertRes    = 10
+	capLogPixelsX = 88
+	capLogPixelsY = 90
+)
+
+const (
+	stretchHalftone = 4
+	dibRGBColors    = 0
+	rasterSrcCopy   = 0x00CC0020
+	biRGB           = 0
+)
+
+// docInfoW refleja la estructura DOCINFOW
+type docInfoW struct {
+	Size     int32
+	DocName  *uint16
+	Output   *uint16
+	Datatype *uint16
+	Type     uint32
+}
+
+// bitmapInfoHeader refleja la estructura BITMAPINFOHEADER
+type bitmapInfoHeader struct {
+	Size          uint32
+	Width         int32
+	Height        int32
+	Planes        uint16
+	BitCount      uint16
+	Compression   uint32
+	SizeImage     uint32
+	XPelsPerMeter int32
+	YPelsPerMeter int32
+	ClrUsed       uint32
+	ClrImportant  uint32
+}
+
+// gdiDocument es un documento abierto en el contexto de dispositivo de una impresora
+type gdiDocument struct {
+	hdc uintptr
+	// Área imprimible y resolución del dispositivo
+	Width, Height int
+	DPIX, DPIY    int
+}
+
+// startGDIDocument crea el contexto de dispositivo de la impresora con las opciones de impresión
+// aplicadas a su DEVMODE e inicia un documento del spooler con el nombre indicado
+func startGDIDocument(printer, docName string, opts PrintOptions) (*gdiDocument, error) {
+	bin, err := resolveTrayBin(printer, opts.Tray)
+	if err != nil {
+		return nil, err
+	}
+	h, err := openSpoolerPrinter(printer)
+	if err != nil {
+		return nil, err
+	}
+	base, err := getUserDevMode(h)
+	if err == nil {
+		base, err = documentProperties(h, printer, base, func(dm *devMode) {
+			applyPrintOptions(dm, opts, bin)
+		})
+	}
+	closeSpoolerPrinter(h)
+	if err != nil {
+		return nil, fmt.Errorf("error al preparar la configuración de impresión: %w", err)
+	}
+
+	driverPtr, _ := windows.UTF16PtrFromString("WINSPOOL")
+	namePtr, err := windows.UTF16PtrFromString(printer)
+	if err != nil {
+		return nil, err
+	}
+	hdc, _, err := procCreateDCW.Call(uintptr(unsafe.Pointer(driverPtr)), uintptr(unsafe.Pointer(namePtr)), 0,
+		uintptr(unsafe.Pointer(&base[0])))
+	if hdc == 0 {
+		return nil, fmt.Errorf("CreateDC falló para '%s': %w", printer, err)
+	}
+
+	doc := &gdiDocument{
+		hdc:    hdc,
+		Width:  deviceCaps(hdc, capHorzRes),
+		Height: deviceCaps(hdc, capVertRes),
+		DPIX:   deviceCaps(hdc, capLogPixelsX),
+		DPIY:   deviceCaps(hdc, capLogPixelsY),
+	}
+	if doc.Width <= 0 || doc.Height <= 0 || doc.DPIX <= 0 || doc.DPIY <= 0 {
+		procDeleteDC.Call(hdc)
+		return nil, errors.New("el controlador no informó el área imprimible de la impresora")
+	}
+
+	docNamePtr, err := windows.UTF16PtrFromString(docName)
+	if err != nil {
+		procDeleteDC.Call(hdc)
+		return nil, err
+	}
+	info := docInfoW{DocName: docNamePtr}
+	info.Size = int32(unsafe.Sizeof(info))
+	if r1, _, err := procStartDocW.Call(hdc, uintptr(unsafe.Pointer(&info))); int32(r1) <= 0 {
+		procDeleteDC.Call(hdc)
+		return nil, fmt.Errorf("StartDoc falló: %w", err)
+	}
+	procSetStretchBltMode.Call(hdc, stretchHalftone)
+	return doc, nil
+}
+
+func deviceCaps(hdc uintptr, index int) int {
+	r1, _, _ := procGetDeviceCaps.Call(hdc, uintptr(index))
+	return int(int32(r1))
+}
+
+// PrintPage imprime la imagen en una página nueva, centrada horizontalmente y sin deformarse, con la
+// escala de opts.Scale: fit ocupa el área imprimible, none y los porcentajes parten del tamaño real
+// (lo que no cabe se recorta) y shrink, la predeterminada, reduce solo las páginas que no caben
+func (d *gdiDocument) PrintPage(img *image.RGBA, dpi int, scale string) error {
+	if err := d.StartPage(); err != nil {
+		return err
+	}
+	bounds := img.Bounds()
+	// Tamaño de la página en el dispositivo según su resolución
+	dstW := bounds.Dx() * d.DPIX / dpi
+	dstH := bounds.Dy() * d.DPIY / dpi
+	fit := min(float64(d.Width)/float64(dstW), float64(d.Height)/float64(dstH))
+	factor := 1.0
+	switch percent := (PrintOptions{Scale: scale}).ScalePercent(); {
+	case percent > 0:
+		factor = float64(percent) / 100
+	case scale == ScaleFit:
+		factor = fit
+	case scale != ScaleNone && fit < 1:
+		factor = fit
+	}
+	dstW, dstH = int(float64(dstW)*factor), int(float64(dstH)*factor)
+	if err := d.DrawImage(img, (d.Width-dstW)/2, 0, dstW, dstH); err != nil {
+		return err
+	}
+	return d.EndPage()
+}
+
+// StartPage inicia una página nueva
+func (d *gdiDocument) StartPage() error {
+	if r1, _, err := procStartPage.Call(d.hdc); int32(r1) <= 0 {
+		return fmt.Errorf("StartPage falló: %w", err)
+	}
+	return nil
+}
+
+// EndPage termina la página en curso
+func (d *gdiDocument) EndPage() error {
+	if r1, _, err := procEndPage.Call(d.hdc); int32(r1) <= 0 {
+		return fmt.Errorf("EndPage falló: %w", err)
+	}
+	return nil
+}
+
+// DrawImage dibuja la imagen en el rectángulo indicado de la página en curso, en puntos del dispositivo
+func (d *gdiDocument) DrawImage(img *image.RGBA, x, y, width, height int) error {
+	bounds := img.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+	header := bitmapInfoHeader{
+		Width:       int32(srcW),
+		Height:      -int32(srcH), // negativo: filas de arriba hacia abajo
+		Planes:      1,
+		BitCount:    32,
+		Compression: biRGB,
+	}
+	header.Size = uint32(unsafe.Sizeof(header))
+	bits := bgraPixels(img)
+	r1, _, err := procStretchDIBits.Call(d.hdc,
+		uintptr(x), uintptr(y), uintptr(width), uintptr(height),
+		0, 0, uintptr(srcW), uintptr(srcH),
+		uintptr(unsafe.Pointer(&bits[0])), uintptr(unsafe.Pointer(&header)),
+		dibRGBColors, rasterSrcCopy)
+	if int32(r1) <= 0 {
+		return fmt.Errorf("StretchDIBits falló: %w", err)
+	}
+	return nil
+}
+
+// End cierra el documento, que queda en la cola del spooler
+func (d *gdiDocument) End() error {
+	defer procDeleteDC.Call(d.hdc)
+	if r1, _, err := procEndDoc.Call(d.hdc); int32(r1) <= 0 {
+		return fmt.Errorf("EndDoc falló: %w", err)
+	}
+	return nil
+}
+
+// Abort descarta el documento sin imprimirlo
+func (d *gdiDocument) Abort() {
+	procAbortDoc.Call(d.hdc)
+	procDeleteDC.Call(d.hdc)
+}
+
+// bgraPixels convierte la imagen al formato de un DIB de 32 bits (B, G, R, sin uso). Las zonas
+// transparentes se combinan con fondo blanco (image.RGBA guarda los colores premultiplicados).
+func bgraPixels(img *image.RGBA) []byte {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	bits := make([]byte, w*h*4)
+	for y := 0; y < h; y++ {
+		row := img.Pix[y*img.Stride : y*img.Stride+w*4]
+		out := bits[y*w*4 : (y+1)*w*4]
+		for i := 0; i < len(row); i += 4 {
+			white := 255 - row[i+3]
+			out[i], out[i+1], out[i+2] = row[i+2]+white, row[i+1]+white, row[i]+white
+		}
+	}
+	return bits
+}
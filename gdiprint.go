@@ -0,0 +1,47 @@
+package main
+
+import (
+	"errors"
+	"image"
+)
+
+// ============================
+// Impresión Directa con GDI (texto e imágenes)
+// ============================
+
+// ErrGDIUnsupported indica que el backend no permite imprimir directamente con GDI (CUPS, archivo)
+var ErrGDIUnsupported = errors.New("la impresión directa con GDI solo está disponible con las impresoras de Windows")
+
+// Salida GDI de /print-text y /print-image: se dibuja directo en el controlador de la impresora
+// (StartDoc/TextOut/StretchDIBits), sin generar un PDF ni ejecutar un motor externo
+const (
+	TextOutputGDI  = "gdi"
+	ImageOutputGDI = "gdi"
+)
+
+// GDIPrinter dibuja texto e imágenes en un documento del spooler de la impresora
+type GDIPrinter interface {
+	DrawText(printer, text string, opts TextOptions) error
+	DrawImage(printer string, img image.Image, opts ImageOptions) error
+}
+
+// SerializedGDIPrinter imprime cuando la impresora queda libre, compartiendo el candado con los
+// documentos y los envíos RAW
+type SerializedGDIPrinter struct {
+	Next  GDIPrinter
+	Locks *PrinterLocks
+}
+
+// DrawText imprime el texto cuando la impresora queda libre
+func (s SerializedGDIPrinter) DrawText(printer, text string, opts TextOptions) error {
+	return s.Locks.Do(printer, func() error {
+		return s.Next.DrawText(printer, text, opts)
+	})
+}
+
+// DrawImage imprime la imagen cuando la impresora queda libre
+func (s SerializedGDIPrinter) DrawImage(printer string, img image.Image, opts ImageOptions) error {
+	return s.Locks.Do(printer, func() error {
+		return s.Next.DrawImage(printer, img, opts)
+	})
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	procCreateFontW     = modGDI32.NewProc("CreateFontW")
+	procSelectObject    = modGDI32.NewProc("SelectObject")
+	procDeleteObject    = modGDI32.NewProc("DeleteObject")
+	procSetBkMode       = modGDI32.NewProc("SetBkMode")
+	procTextOutW        = modGDI32.NewProc("TextOutW")
+	procGetTextMetricsW = modGDI32.NewProc("GetTextMetricsW")
+)
+
+const (
+	fontWeightNormal   = 400
+	fontDefaultCharset = 1
+	fontFixedModern    = 0x31 // FIXED_PITCH | FF_MODERN
+	bkModeTransparent  = 1
+)
+
+// gdiTextFont es la fuente monoespaciada del texto: conserva las columnas de los reportes como en
+// la salida PDF (Courier)
+const gdiTextFont = "Courier New"
+
+// textMetricW refleja la estructura TEXTMETRICW
+type textMetricW struct {
+	Height           int32
+	Ascent           int32
+	Descent          int32
+	InternalLeading  int32
+	ExternalLeading  int32
+	AveCharWidth     int32
+	MaxCharWidth     int32
+	Weight           int32
+	Overhang         int32
+	DigitizedAspectX int32
+	DigitizedAspectY int32
+	FirstChar        uint16
+	LastChar         uint16
+	DefaultChar      uint16
+	BreakChar        uint16
+	Italic           byte
+	Underlined       byte
+	StruckOut        byte
+	PitchAndFamily   byte
+	CharSet          byte
+}
+
+// WindowsGDIPrinter imprime texto e imágenes dibujándolos directamente en el contexto de
+// dispositivo de la impresora. No genera archivos ni lanza procesos, por lo que un comprobante
+// simple llega a la cola en una fracción de lo que tarda un motor PDF.
+type WindowsGDIPrinter struct{}
+
+// DrawText imprime el texto en la fuente monoespaciada, con tantas columnas y líneas por página
+// como permita el área imprimible del controlador (un rollo de 80mm o una hoja A4)
+func (WindowsGDIPrinter) DrawText(printer, text string, opts TextOptions) error {
+	doc, err := startGDIDocument(printer, "PrinterMatiasERP - Texto", PrintOptions{})
+	if err != nil {
+		return err
+	}
+	font, metrics, err := doc.selectFont(gdiTextFont, textBaseFontSize*float64(opts.Size))
+	if err != nil {
+		doc.Abort()
+		return err
+	}
+	// Se elimina después de cerrar el documento, cuando ya no está seleccionada
+	defer procDeleteObject.Call(font)
+
+	// Margen de un cuarto de pulgada en las hojas; en los rollos angostos, apenas el 5% del ancho
+	marginX, marginY := min(doc.DPIX/4, doc.Width/20), min(doc.DPIY/4, doc.Height/20)
+	lineHeight := int(metrics.Height + metrics.ExternalLeading)
+	columns := max((doc.Width-2*marginX)/int(metrics.AveCharWidth), 1)
+	perPage := max((doc.Height-2*marginY)/lineHeight, 1)
+	lines := textLines(text, columns, *opts.Wrap)
+
+	for copy := 0; copy < max(opts.Copies, 1); copy++ {
+		for start := 0; start < len(lines); start += perPage {
+			if isJobCanceled(opts.JobID) {
+				doc.Abort()
+				return ErrJobCanceled
+			}
+			if err := doc.StartPage(); err != nil {
+				doc.Abort()
+				return err
+			}
+			for i, line := range lines[start:min(start+perPage, len(lines))] {
+				if err := doc.textOut(marginX, marginY+i*lineHeight, line); err != nil {
+					doc.Abort()
+					return err
+				}
+			}
+			if err := doc.EndPage(); err != nil {
+				doc.Abort()
+				return err
+			}
+		}
+	}
+	return doc.End()
+}
+
+// DrawImage imprime la imagen arriba de la página, con el ancho indicado en puntos del dispositivo
+// o, si se omite, a la mitad del área imprimible como la salida PDF
+func (WindowsGDIPrinter) DrawImage(printer string, img image.Image, opts ImageOptions) error {
+	bounds := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), img, bounds.Min, draw.Src)
+
+	doc, err := startGDIDocument(printer, "PrinterMatiasERP - Imagen", PrintOptions{})
+	if err != nil {
+		return err
+	}
+	width := opts.Width
+	if width == 0 {
+		width = doc.Width / 2
+	}
+	width = min(width, doc.Width)
+	height := bounds.Dy() * width / bounds.Dx()
+	if height > doc.Height {
+		height = doc.Height
+		width = bounds.Dx() * height / bounds.Dy()
+	}
+	x := (doc.Width - width) / 2
+	switch opts.Align {
+	case AlignLeft:
+		x = 0
+	case AlignRight:
+		x = doc.Width - width
+	}
+
+	for copy := 0; copy < max(opts.Copies, 1); copy++ {
+		if isJobCanceled(opts.JobID) {
+			doc.Abort()
+			return ErrJobCanceled
+		}
+		err := doc.StartPage()
+		if err == nil {
+			err = doc.DrawImage(rgba, x, 0, width, height)
+		}
+		if err == nil {
+			err = doc.EndPage()
+		}
+		if err != nil {
+			doc.Abort()
+			return err
+		}
+	}
+	return doc.End()
+}
+
+// selectFont crea la fuente con el tamaño indicado en puntos tipográficos según la resolución
+// vertical del dispositivo, la selecciona y devuelve sus métricas. El llamador debe eliminarla.
+func (d *gdiDocument) selectFont(face string, points float64) (uintptr, *textMetricW, error) {
+	facePtr, err := windows.UTF16PtrFromString(face)
+	if err != nil {
+		return 0, nil, err
+	}
+	// Altura negativa: tamaño del carácter sin el espacio interlineal
+	height := -int(points * float64(d.DPIY) / 72)
+	font, _, err := procCreateFontW.Call(uintptr(height), 0, 0, 0, fontWeightNormal, 0, 0, 0,
+		fontDefaultCharset, 0, 0, 0, fontFixedModern, uintptr(unsafe.Pointer(facePtr)))
+	if font == 0 {
+		return 0, nil, fmt.Errorf("CreateFont falló: %w", err)
+	}
+	procSelectObject.Call(d.hdc, font)
+	procSetBkMode.Call(d.hdc, bkModeTransparent)
+
+	var metrics textMetricW
+	if r1, _, err := procGetTextMetricsW.Call(d.hdc, uintptr(unsafe.Pointer(&metrics))); r1 == 0 || metrics.AveCharWidth <= 0 {
+		procDeleteObject.Call(font)
+		return 0, nil, fmt.Errorf("GetTextMetrics falló: %w", err)
+	}
+	return font, &metrics, nil
+}
+
+// textOut escribe una línea en la posición indicada, en puntos del dispositivo
+func (d *gdiDocument) textOut(x, y int, line string) error {
+	if line == "" {
+		return nil
+	}
+	text, err := windows.UTF16FromString(line)
+	if err != nil {
+		return err
+	}
+	text = text[:len(text)-1] // sin el terminador
+	if r1, _, err := procTextOutW.Call(d.hdc, uintptr(x), uintptr(y), uintptr(unsafe.Pointer(&text[0])), uintptr(len(text))); r1 == 0 {
+		return fmt.Errorf("TextOut falló: %w", err)
+	}
+	return nil
+}
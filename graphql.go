@@ -0,0 +1,276 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	graphql "github.com/graph-gophers/graphql-go"
+	"github.com/graph-gophers/graphql-go/relay"
+)
+
+// ============================
+// API GraphQL (consultas para el panel administrativo)
+// ============================
+
+// graphQLSchema describe los modelos consultables por el panel administrativo
+const graphQLSchema = `
+	schema {
+		query: Query
+	}
+
+	type Query {
+		store: Store!
+		printers: [Printer!]!
+		printer(name: String!): Printer
+		jobs(printer: String, status: String, kind: String, since: String, limit: Int, offset: Int): [Job!]!
+		job(id: ID!): Job
+		stats(since: String): Stats!
+	}
+
+	type Store {
+		name: String!
+		address: String!
+		printers: [Printer!]!
+	}
+
+	type Printer {
+		name: String!
+		driverName: String!
+		portName: String!
+		status: String!
+		location: String!
+		jobs(status: String, kind: String, since: String, limit: Int, offset: Int): [Job!]!
+	}
+
+	type Job {
+		id: ID!
+		kind: String!
+		printer: String!
+		source: String
+		documentSha256: String
+		status: String!
+		error: String
+		startedAt: String!
+		finishedAt: String
+		durationMs: Float!
+	}
+
+	type Stats {
+		total: Int!
+		completed: Int!
+		failed: Int!
+		averageDurationMs: Float!
+		byPrinter: [PrinterCount!]!
+	}
+
+	type PrinterCount {
+		printer: String!
+		count: Int!
+	}
+`
+
+// GraphQLResolver resuelve las consultas GraphQL usando el PrinterService
+type GraphQLResolver struct {
+	Service   PrinterService
+	History   JobHistory
+	StoreName string
+	Address   string
+}
+
+// NewGraphQLHandler compila el esquema y devuelve el manejador HTTP del endpoint /graphql
+func NewGraphQLHandler(resolver *GraphQLResolver) (http.Handler, error) {
+	schema, err := graphql.ParseSchema(graphQLSchema, resolver, graphql.UseFieldResolvers())
+	if err != nil {
+		return nil, err
+	}
+	return &relay.Handler{Schema: schema}, nil
+}
+
+// Store resuelve los datos del punto de venta atendido por este agente
+func (r *GraphQLResolver) Store() *storeResolver {
+	return &storeResolver{root: r}
+}
+
+// Printers resuelve la lista de impresoras instaladas
+func (r *GraphQLResolver) Printers() ([]*printerResolver, error) {
+	printers, err := r.Service.GetPrinters()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*printerResolver, 0, len(printers))
+	for _, p := range printers {
+		result = append(result, &printerResolver{root: r, details: p})
+	}
+	return result, nil
+}
+
+// Printer resuelve una impresora por nombre
+func (r *GraphQLResolver) Printer(args struct{ Name string }) (*printerResolver, error) {
+	printers, err := r.Printers()
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range printers {
+		if p.Name() == args.Name {
+			return p, nil
+		}
+	}
+	return nil, nil
+}
+
+type storeResolver struct {
+	root *GraphQLResolver
+}
+
+func (s *storeResolver) Name() string    { return s.root.StoreName }
+func (s *storeResolver) Address() string { return s.root.Address }
+
+func (s *storeResolver) Printers() ([]*printerResolver, error) {
+	return s.root.Printers()
+}
+
+type printerResolver struct {
+	root    *GraphQLResolver
+	details map[string]string
+}
+
+func (p *printerResolver) Name() string       { return p.details["Name"] }
+func (p *printerResolver) DriverName() string { return p.details["DriverName"] }
+func (p *printerResolver) PortName() string   { return p.details["PortName"] }
+func (p *printerResolver) Status() string     { return p.details["PrinterStatus"] }
+func (p *printerResolver) Location() string   { return p.details["Location"] }
+
+// Jobs resuelve el historial de la impresora, para obtener p. ej. sus últimas fallas en la misma consulta
+func (p *printerResolver) Jobs(args struct {
+	Status, Kind, Since *string
+	Limit, Offset       *int32
+}) ([]*jobResolver, error) {
+	name := p.Name()
+	return p.root.Jobs(struct {
+		Printer, Status, Kind, Since *string
+		Limit, Offset                *int32
+	}{Printer: &name, Status: args.Status, Kind: args.Kind, Since: args.Since, Limit: args.Limit, Offset: args.Offset})
+}
+
+// Jobs resuelve el historial de trabajos con los mismos filtros que GET /jobs
+func (r *GraphQLResolver) Jobs(args struct {
+	Printer, Status, Kind, Since *string
+	Limit, Offset                *int32
+}) ([]*jobResolver, error) {
+	filter := JobFilter{Printer: deref(args.Printer), Status: deref(args.Status), Kind: deref(args.Kind), Limit: defaultJobsLimit}
+	if err := parseGraphQLTime(args.Since, &filter.Since); err != nil {
+		return nil, err
+	}
+	if args.Limit != nil && *args.Limit > 0 && *args.Limit <= maxJobsLimit {
+		filter.Limit = int(*args.Limit)
+	}
+	if args.Offset != nil && *args.Offset > 0 {
+		filter.Offset = int(*args.Offset)
+	}
+
+	jobs, _, err := r.History.Query(filter)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*jobResolver, 0, len(jobs))
+	for _, job := range jobs {
+		result = append(result, &jobResolver{job: job})
+	}
+	return result, nil
+}
+
+// Job resuelve un trabajo por su identificador
+func (r *GraphQLResolver) Job(args struct{ ID graphql.ID }) (*jobResolver, error) {
+	job, err := r.History.Get(string(args.ID))
+	if err != nil || job == nil {
+		return nil, err
+	}
+	return &jobResolver{job: job}, nil
+}
+
+// Stats resuelve los totales del historial
+func (r *GraphQLResolver) Stats(args struct{ Since *string }) (*statsResolver, error) {
+	var filter JobFilter
+	if err := parseGraphQLTime(args.Since, &filter.Since); err != nil {
+		return nil, err
+	}
+	stats, err := r.History.Stats(filter)
+	if err != nil {
+		return nil, err
+	}
+	return &statsResolver{stats: stats}, nil
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func parseGraphQLTime(s *string, dst *time.Time) error {
+	if s == nil || *s == "" {
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339, *s)
+	if err != nil {
+		return fmt.Errorf("fecha inválida (use RFC3339): %s", *s)
+	}
+	*dst = t
+	return nil
+}
+
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+type jobResolver struct {
+	job *PrintJob
+}
+
+func (j *jobResolver) ID() graphql.ID          { return graphql.ID(j.job.ID) }
+func (j *jobResolver) Kind() string            { return j.job.Kind }
+func (j *jobResolver) Printer() string         { return j.job.Printer }
+func (j *jobResolver) Source() *string         { return optionalString(j.job.Source) }
+func (j *jobResolver) DocumentSha256() *string { return optionalString(j.job.SHA256) }
+func (j *jobResolver) Status() string          { return j.job.Status }
+func (j *jobResolver) Error() *string          { return optionalString(j.job.Error) }
+func (j *jobResolver) StartedAt() string       { return j.job.StartedAt.Format(time.RFC3339) }
+func (j *jobResolver) DurationMs() float64     { return float64(j.job.DurationMs) }
+func (j *jobResolver) FinishedAt() *string {
+	if j.job.FinishedAt.IsZero() {
+		return nil
+	}
+	return optionalString(j.job.FinishedAt.Format(time.RFC3339))
+}
+
+type statsResolver struct {
+	stats JobStats
+}
+
+func (s *statsResolver) Total() int32               { return int32(s.stats.Total) }
+func (s *statsResolver) Completed() int32           { return int32(s.stats.Completed) }
+func (s *statsResolver) Failed() int32              { return int32(s.stats.Failed) }
+func (s *statsResolver) AverageDurationMs() float64 { return float64(s.stats.AverageDurationMs) }
+
+func (s *statsResolver) ByPrinter() []*printerCountResolver {
+	result := make([]*printerCountResolver, 0, len(s.stats.ByPrinter))
+	for printer, count := range s.stats.ByPrinter {
+		result = append(result, &printerCountResolver{printer: printer, count: int32(count)})
+	}
+	sort.Slice(result, func(i, k int) bool { return result[i].count > result[k].count })
+	return result
+}
+
+type printerCountResolver struct {
+	printer string
+	count   int32
+}
+
+func (p *printerCountResolver) Printer() string { return p.printer }
+func (p *printerCountResolver) Count() int32    { return p.count }
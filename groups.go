@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ============================
+// Grupos de Impresoras
+// ============================
+
+// PrinterGroups reparte los trabajos enviados a un grupo (p. ej. "facturas" = HP-1, HP-2) entre sus
+// impresoras por turnos, saltando las que no están listas
+type PrinterGroups struct {
+	members map[string][]string
+
+	mu   sync.Mutex
+	next map[string]int
+}
+
+// NewPrinterGroups valida los grupos de PRINTER_GROUPS ("facturas=HP-1|HP-2"); los nombres de
+// grupo no distinguen mayúsculas y sus miembros pueden ser alias
+func NewPrinterGroups(entries map[string]string) (*PrinterGroups, error) {
+	g := &PrinterGroups{members: make(map[string][]string, len(entries)), next: make(map[string]int)}
+	for group, list := range entries {
+		key := strings.ToLower(strings.TrimSpace(group))
+		members := splitAndTrim(list, "|")
+		if key == "" || len(members) == 0 {
+			return nil, fmt.Errorf("grupo de impresoras inválido: '%s=%s'", group, list)
+		}
+		if _, dup := g.members[key]; dup {
+			return nil, fmt.Errorf("grupo de impresoras duplicado: %s", group)
+		}
+		g.members[key] = members
+	}
+	return g, nil
+}
+
+// Members devuelve las impresoras del grupo, o false si el nombre no es un grupo
+func (g *PrinterGroups) Members(name string) ([]string, bool) {
+	if g == nil {
+		return nil, false
+	}
+	members, ok := g.members[strings.ToLower(strings.TrimSpace(name))]
+	return members, ok
+}
+
+// Pick elige la impresora del grupo que sigue en turno y está lista según ready. Si ninguna lo está,
+// devuelve la que sigue en turno para que el trabajo falle (o quede retenido) con su estado real.
+func (g *PrinterGroups) Pick(group string, ready func(string) bool) string {
+	members, _ := g.Members(group)
+	key := strings.ToLower(strings.TrimSpace(group))
+
+	// El turno avanza antes de consultar el estado para no serializar las solicitudes del grupo
+	g.mu.Lock()
+	start := g.next[key] % len(members)
+	g.next[key] = start + 1
+	g.mu.Unlock()
+
+	for i := range members {
+		member := members[(start+i)%len(members)]
+		if ready(member) {
+			// Si se saltaron impresoras, el turno sigue después de la elegida
+			g.mu.Lock()
+			if i > 0 && g.next[key] == start+1 {
+				g.next[key] = start + i + 1
+			}
+			g.mu.Unlock()
+			return member
+		}
+	}
+	return members[start]
+}
+
+// groupPrinter resuelve el grupo al que se envía la solicitud en una de sus impresoras; los nombres
+// que no son grupos se devuelven igual con grupo vacío
+func (h Handlers) groupPrinter(name string) (string, string) {
+	if _, ok := h.Groups.Members(name); !ok {
+		return name, ""
+	}
+	printer := h.Groups.Pick(name, func(member string) bool {
+		status, err := h.Service.PrinterStatus(member)
+		if err != nil {
+			h.Logger.Warnf("No se pudo consultar el estado de '%s' (grupo '%s'): %v", member, name, err)
+			return false
+		}
+		return status.Ready
+	})
+	h.Logger.Infof("Grupo '%s': se usa la impresora '%s'", name, printer)
+	return printer, name
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ============================
+// Salud de las Impresoras (monitoreo en segundo plano)
+// ============================
+
+// ErrPrinterOffline indica que el monitoreo ya detectó la impresora fuera de línea, por lo que la
+// impresión se rechaza de inmediato en lugar de esperar a que venza el plazo del spooler
+var ErrPrinterOffline = errors.New("la impresora está fuera de línea")
+
+// Estados de salud de una impresora
+const (
+	HealthHealthy  = "healthy"  // en línea y lista para imprimir
+	HealthDegraded = "degraded" // en línea, pero con problemas (sin papel, tapa abierta, cola pausada)
+	HealthOffline  = "offline"  // fuera de línea o sin responder
+	HealthUnknown  = "unknown"  // todavía no se consultó
+)
+
+// HealthConfig configura el monitoreo de salud de las impresoras
+type HealthConfig struct {
+	// Printers son las impresoras monitoreadas; "*" incluye todas las del sistema
+	Printers        []string
+	IntervalSeconds int
+	// Failures es la cantidad de consultas seguidas sin respuesta para considerarla fuera de línea
+	Failures int
+	FailFast bool
+}
+
+// LoadHealthConfig carga la configuración del monitoreo de salud desde variables de entorno
+func LoadHealthConfig() HealthConfig {
+	return HealthConfig{
+		Printers:        getEnvAsSlice("HEALTH_CHECK_PRINTERS", ""),
+		IntervalSeconds: getEnvAsInt("HEALTH_CHECK_INTERVAL_SECONDS", 30),
+		Failures:        getEnvAsInt("HEALTH_CHECK_FAILURES", 2),
+		FailFast:        getEnvAsBool("HEALTH_CHECK_FAIL_FAST", true),
+	}
+}
+
+// PrinterHealth es el último estado conocido de una impresora monitoreada
+type PrinterHealth struct {
+	Printer             string     `json:"printer"`
+	Health              string     `json:"health"`
+	Online              bool       `json:"online"`
+	Ready               bool       `json:"ready"`
+	Problems            []string   `json:"problems,omitempty"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	LastError           string     `json:"last_error,omitempty"`
+	CheckedAt           *time.Time `json:"checked_at,omitempty"`
+	ChangedAt           *time.Time `json:"changed_at,omitempty"`
+}
+
+// HealthMonitor consulta periódicamente el estado de las impresoras configuradas y conserva en
+// memoria el último resultado. Las impresiones a una impresora fuera de línea fallan de inmediato
+// con PRINTER_OFFLINE y /list-printers informa la salud de cada impresora sin consultarlas.
+type HealthMonitor struct {
+	Service  PrinterService
+	Aliases  PrinterAliases
+	Printers []string
+	Interval time.Duration
+	Failures int
+	FailFast bool
+	Logger   *Logger
+	// Alerts avisa al canal de soporte cuando una impresora queda fuera de línea y cuando se recupera
+	Alerts *Alerter
+
+	mu     sync.RWMutex
+	states map[string]*PrinterHealth
+	done   chan struct{}
+}
+
+// NewHealthMonitor crea el monitoreo; sin impresoras configuradas devuelve nil
+func NewHealthMonitor(service PrinterService, aliases PrinterAliases, cfg HealthConfig, logger *Logger) (*HealthMonitor, error) {
+	if len(cfg.Printers) == 0 {
+		return nil, nil
+	}
+	if cfg.IntervalSeconds <= 0 || cfg.Failures <= 0 {
+		return nil, fmt.Errorf("HEALTH_CHECK_INTERVAL_SECONDS y HEALTH_CHECK_FAILURES deben ser mayores que cero")
+	}
+	return &HealthMonitor{
+		Service:  service,
+		Aliases:  aliases,
+		Printers: cfg.Printers,
+		Interval: time.Duration(cfg.IntervalSeconds) * time.Second,
+		Failures: cfg.Failures,
+		FailFast: cfg.FailFast,
+		Logger:   logger,
+		states:   make(map[string]*PrinterHealth),
+		done:     make(chan struct{}),
+	}, nil
+}
+
+// Start hace la primera consulta e inicia el monitoreo en segundo plano
+func (m *HealthMonitor) Start() {
+	if m == nil {
+		return
+	}
+	m.Logger.Infof("Monitoreo de salud de %v cada %s", m.Printers, m.Interval)
+	go m.run()
+}
+
+// Close detiene el monitoreo
+func (m *HealthMonitor) Close() error {
+	if m != nil {
+		close(m.done)
+	}
+	return nil
+}
+
+func (m *HealthMonitor) run() {
+	defer recoverCrash()
+	ticker := time.NewTicker(m.Interval)
+	defer ticker.Stop()
+
+	for {
+		m.check()
+		select {
+		case <-ticker.C:
+		case <-m.done:
+			return
+		}
+	}
+}
+
+// monitored devuelve las impresoras a consultar: las configuradas o, con "*", todas las del sistema
+func (m *HealthMonitor) monitored() []string {
+	all := false
+	for _, name := range m.Printers {
+		all = all || name == "*"
+	}
+	if !all {
+		return m.Printers
+	}
+	printers, err := m.Service.GetPrinters()
+	if err != nil {
+		m.Logger.Warnf("No se pudo listar las impresoras para el monitoreo de salud: %v", err)
+		return nil
+	}
+	names := make([]string, 0, len(printers))
+	for _, p := range printers {
+		if p["Name"] != "" {
+			names = append(names, p["Name"])
+		}
+	}
+	return names
+}
+
+// check consulta cada impresora y actualiza su estado
+func (m *HealthMonitor) check() {
+	for _, printer := range m.monitored() {
+		select {
+		case <-m.done:
+			return
+		default:
+		}
+		status, err := m.Service.PrinterStatus(printer)
+		m.update(m.Aliases.Resolve(printer), status, err)
+	}
+}
+
+// update registra el resultado de una consulta. La impresora pasa a fuera de línea recién después
+// de Failures consultas seguidas sin respuesta, para no rechazar trabajos por una falla aislada.
+func (m *HealthMonitor) update(printer string, status *PrinterStatus, err error) {
+	now := time.Now()
+	m.mu.Lock()
+	state, ok := m.states[printer]
+	if !ok {
+		state = &PrinterHealth{Printer: printer, Health: HealthUnknown}
+		m.states[printer] = state
+	}
+	previous := state.Health
+	state.CheckedAt = &now
+
+	switch {
+	case err != nil || !status.Online:
+		state.ConsecutiveFailures++
+		state.Online, state.Ready, state.Problems, state.LastError = false, false, nil, ""
+		if err != nil {
+			state.LastError = err.Error()
+		} else {
+			state.Problems = status.Problems
+		}
+		if state.ConsecutiveFailures >= m.Failures {
+			state.Health = HealthOffline
+		}
+	case !status.Ready:
+		state.ConsecutiveFailures = 0
+		state.Online, state.Ready, state.Problems, state.LastError = true, false, status.Problems, ""
+		state.Health = HealthDegraded
+	default:
+		state.ConsecutiveFailures = 0
+		state.Online, state.Ready, state.Problems, state.LastError = true, true, nil, ""
+		state.Health = HealthHealthy
+	}
+
+	if state.Health != previous {
+		state.ChangedAt = &now
+		if previous != HealthUnknown || state.Health != HealthHealthy {
+			m.Logger.Infof("Salud de '%s': %s -> %s", printer, previous, state.Health)
+		}
+	}
+	health, detail := state.Health, state.LastError
+	if detail == "" {
+		detail = strings.Join(state.Problems, ", ")
+	}
+	m.mu.Unlock()
+
+	// Las alertas se envían fuera del bloqueo
+	switch {
+	case health == previous:
+	case health == HealthOffline:
+		m.Alerts.PrinterOffline(printer, detail)
+	case previous == HealthOffline:
+		m.Alerts.PrinterOnline(printer)
+	}
+}
+
+// Get devuelve el último estado conocido de la impresora (o alias)
+func (m *HealthMonitor) Get(printer string) (PrinterHealth, bool) {
+	if m == nil {
+		return PrinterHealth{}, false
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	state, ok := m.states[m.Aliases.Resolve(printer)]
+	if !ok {
+		return PrinterHealth{}, false
+	}
+	return *state, true
+}
+
+// List devuelve el estado de las impresoras monitoreadas ordenadas por nombre
+func (m *HealthMonitor) List() []PrinterHealth {
+	out := []PrinterHealth{}
+	if m == nil {
+		return out
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, state := range m.states {
+		out = append(out, *state)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Printer < out[j].Printer })
+	return out
+}
+
+// CheckOnline rechaza de inmediato la impresión si el monitoreo detectó la impresora fuera de
+// línea en su última consulta. Un estado de más de tres intervalos no se considera: el monitoreo
+// pudo atrasarse.
+func (m *HealthMonitor) CheckOnline(printer string) error {
+	if m == nil || !m.FailFast {
+		return nil
+	}
+	state, ok := m.Get(printer)
+	if !ok || state.Health != HealthOffline || time.Since(*state.CheckedAt) > 3*m.Interval {
+		return nil
+	}
+	return permanent(fmt.Errorf("%w: '%s' no responde desde %s", ErrPrinterOffline, printer, state.ChangedAt.Format(time.RFC3339)))
+}
+
+// PrintersHealthHandler devuelve el último estado conocido de las impresoras monitoreadas (GET /printers/health)
+func (h Handlers) PrintersHealthHandler(w http.ResponseWriter, r *http.Request) {
+	h.Logger = h.Logger.ForRequest(r)
+	h.Logger.Info("Received request: /printers/health")
+
+	if r.Method != http.MethodGet {
+		WriteErrorJSON(w, http.StatusMethodNotAllowed, "Método HTTP no permitido", nil)
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]interface{}{"printers": h.Health.List()})
+}
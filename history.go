@@ -0,0 +1,348 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// ============================
+// Historial Persistente de Trabajos
+// ============================
+
+var (
+	bucketJobs   = []byte("jobs")    // clave cronológica -> trabajo (JSON)
+	bucketJobIDs = []byte("job_ids") // job_id -> clave cronológica
+)
+
+// Límites de paginación de GET /jobs
+const (
+	defaultJobsLimit = 50
+	maxJobsLimit     = 500
+)
+
+// JobFilter son los criterios de búsqueda del historial; los campos vacíos no filtran
+type JobFilter struct {
+	Printer string
+	Status  string
+	Kind    string
+	Since   time.Time
+	Until   time.Time
+	Limit   int
+	Offset  int
+}
+
+// matches indica si el trabajo cumple el filtro
+func (f JobFilter) matches(job *PrintJob) bool {
+	return (f.Printer == "" || job.Printer == f.Printer) &&
+		(f.Status == "" || job.Status == f.Status) &&
+		(f.Kind == "" || job.Kind == f.Kind) &&
+		(f.Since.IsZero() || !job.StartedAt.Before(f.Since)) &&
+		(f.Until.IsZero() || job.StartedAt.Before(f.Until))
+}
+
+// JobStats resume el historial para reportes y el panel administrativo
+type JobStats struct {
+	Total             int            `json:"total"`
+	Completed         int            `json:"completed"`
+	Failed            int            `json:"failed"`
+	Canceled          int            `json:"canceled"`
+	AverageDurationMs int64          `json:"average_duration_ms"`
+	ByPrinter         map[string]int `json:"by_printer"`
+}
+
+// JobHistory interface para almacenar y consultar los trabajos ejecutados
+type JobHistory interface {
+	Record(job *PrintJob) error
+	Get(id string) (*PrintJob, error)
+	Query(filter JobFilter) ([]*PrintJob, int, error)
+	Stats(filter JobFilter) (JobStats, error)
+}
+
+// BoltJobHistory es la implementación de JobHistory sobre una base de datos embebida (bbolt)
+type BoltJobHistory struct {
+	db        *bolt.DB
+	retention time.Duration
+	logger    *Logger
+	done      chan struct{}
+	// pruned se cierra al terminar la depuración periódica, que debe terminar antes de cerrar la base
+	pruned chan struct{}
+}
+
+// NewBoltJobHistory abre (o crea) la base de datos del historial y elimina los trabajos
+// más antiguos que retentionDays (0 conserva todo).
+func NewBoltJobHistory(path string, retentionDays int, logger *Logger) (*BoltJobHistory, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 2 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("error al abrir el historial '%s': %w", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, b := range [][]byte{bucketJobs, bucketJobIDs} {
+			if _, err := tx.CreateBucketIfNotExists(b); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	h := &BoltJobHistory{db: db, retention: time.Duration(retentionDays) * 24 * time.Hour, logger: logger, done: make(chan struct{}), pruned: make(chan struct{})}
+	if h.retention > 0 {
+		go h.pruneLoop()
+	} else {
+		close(h.pruned)
+	}
+	return h, nil
+}
+
+// jobKey ordena los trabajos cronológicamente: marca de tiempo (big-endian) + job_id
+func jobKey(job *PrintJob) []byte {
+	key := make([]byte, 8, 8+len(job.ID))
+	binary.BigEndian.PutUint64(key, uint64(job.StartedAt.UnixNano()))
+	return append(key, job.ID...)
+}
+
+// Record guarda (o actualiza) el trabajo en el historial
+func (h *BoltJobHistory) Record(job *PrintJob) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return h.db.Update(func(tx *bolt.Tx) error {
+		key := jobKey(job)
+		// Los trabajos programados cambian su hora de inicio al ejecutarse: se quita la clave anterior
+		if old := tx.Bucket(bucketJobIDs).Get([]byte(job.ID)); old != nil && !bytes.Equal(old, key) {
+			if err := tx.Bucket(bucketJobs).Delete(old); err != nil {
+				return err
+			}
+		}
+		if err := tx.Bucket(bucketJobs).Put(key, data); err != nil {
+			return err
+		}
+		return tx.Bucket(bucketJobIDs).Put([]byte(job.ID), key)
+	})
+}
+
+// Get busca un trabajo por su job_id; devuelve nil si no existe
+func (h *BoltJobHistory) Get(id string) (*PrintJob, error) {
+	var job *PrintJob
+	err := h.db.View(func(tx *bolt.Tx) error {
+		key := tx.Bucket(bucketJobIDs).Get([]byte(id))
+		if key == nil {
+			return nil
+		}
+		data := tx.Bucket(bucketJobs).Get(key)
+		if data == nil {
+			return nil
+		}
+		job = &PrintJob{}
+		return json.Unmarshal(data, job)
+	})
+	return job, err
+}
+
+// each recorre los trabajos que cumplen el filtro, del más reciente al más antiguo
+func (h *BoltJobHistory) each(filter JobFilter, fn func(job *PrintJob) bool) error {
+	return h.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucketJobs).Cursor()
+		for k, v := c.Last(); k != nil; k, v = c.Prev() {
+			var job PrintJob
+			if err := json.Unmarshal(v, &job); err != nil {
+				continue
+			}
+			if !filter.Since.IsZero() && job.StartedAt.Before(filter.Since) {
+				break
+			}
+			if filter.matches(&job) && !fn(&job) {
+				break
+			}
+		}
+		return nil
+	})
+}
+
+// Query devuelve una página de trabajos (recientes primero) y el total que cumple el filtro
+func (h *BoltJobHistory) Query(filter JobFilter) ([]*PrintJob, int, error) {
+	jobs := []*PrintJob{}
+	total := 0
+	err := h.each(filter, func(job *PrintJob) bool {
+		if total >= filter.Offset && len(jobs) < filter.Limit {
+			jobs = append(jobs, job)
+		}
+		total++
+		return true
+	})
+	return jobs, total, err
+}
+
+// Stats calcula los totales del historial que cumple el filtro
+func (h *BoltJobHistory) Stats(filter JobFilter) (JobStats, error) {
+	stats := JobStats{ByPrinter: map[string]int{}}
+	var totalMs int64
+	err := h.each(filter, func(job *PrintJob) bool {
+		stats.Total++
+		stats.ByPrinter[job.Printer]++
+		totalMs += job.DurationMs
+		switch job.Status {
+		case JobStatusCompleted:
+			stats.Completed++
+		case JobStatusFailed:
+			stats.Failed++
+		case JobStatusCanceled:
+			stats.Canceled++
+		}
+		return true
+	})
+	if stats.Total > 0 {
+		stats.AverageDurationMs = totalMs / int64(stats.Total)
+	}
+	return stats, err
+}
+
+// Close cierra la base de datos del historial
+func (h *BoltJobHistory) Close() error {
+	close(h.done)
+	<-h.pruned
+	return h.db.Close()
+}
+
+// pruneLoop elimina periódicamente los trabajos fuera del período de retención
+func (h *BoltJobHistory) pruneLoop() {
+	defer recoverCrash()
+	defer close(h.pruned)
+	ticker := time.NewTicker(24 * time.Hour)
+	defer ticker.Stop()
+	for {
+		if n, err := h.prune(time.Now().Add(-h.retention)); err != nil {
+			h.logger.Errorf("Error al depurar el historial: %v", err)
+		} else if n > 0 {
+			h.logger.Infof("Historial depurado: %d trabajos eliminados", n)
+		}
+		select {
+		case <-ticker.C:
+		case <-h.done:
+			return
+		}
+	}
+}
+
+func (h *BoltJobHistory) prune(before time.Time) (int, error) {
+	limit := make([]byte, 8)
+	binary.BigEndian.PutUint64(limit, uint64(before.UnixNano()))
+
+	removed := 0
+	err := h.db.Update(func(tx *bolt.Tx) error {
+		jobs, ids := tx.Bucket(bucketJobs), tx.Bucket(bucketJobIDs)
+		c := jobs.Cursor()
+		for k, _ := c.First(); k != nil && bytes.Compare(k[:8], limit) < 0; k, _ = c.First() {
+			if err := ids.Delete(k[8:]); err != nil {
+				return err
+			}
+			if err := jobs.Delete(k); err != nil {
+				return err
+			}
+			removed++
+		}
+		return nil
+	})
+	return removed, err
+}
+
+// ParseJobFilter obtiene el filtro desde los parámetros de consulta de la URL
+func ParseJobFilter(r *http.Request) (JobFilter, error) {
+	q := r.URL.Query()
+	filter := JobFilter{
+		Printer: q.Get("printer"),
+		Status:  q.Get("status"),
+		Kind:    q.Get("kind"),
+		Limit:   defaultJobsLimit,
+	}
+
+	for name, dst := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if v := q.Get(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return filter, fmt.Errorf("fecha inválida en '%s' (use RFC3339): %s", name, v)
+			}
+			*dst = t
+		}
+	}
+	for name, dst := range map[string]*int{"limit": &filter.Limit, "offset": &filter.Offset} {
+		if v := q.Get(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return filter, fmt.Errorf("valor inválido en '%s': %s", name, v)
+			}
+			*dst = n
+		}
+	}
+	if filter.Limit == 0 || filter.Limit > maxJobsLimit {
+		filter.Limit = maxJobsLimit
+	}
+	return filter, nil
+}
+
+// JobListResponse es la respuesta de GET /jobs
+type JobListResponse struct {
+	Total  int         `json:"total"`
+	Limit  int         `json:"limit"`
+	Offset int         `json:"offset"`
+	Jobs   []*PrintJob `json:"jobs"`
+}
+
+// JobsHandler lista el historial de trabajos con filtros y paginación
+func (h Handlers) JobsHandler(w http.ResponseWriter, r *http.Request) {
+	h.Logger = h.Logger.ForRequest(r)
+	h.Logger.Info("Received request: /jobs")
+
+	if r.Method != http.MethodGet {
+		WriteErrorJSON(w, http.StatusMethodNotAllowed, "Método HTTP no permitido", nil)
+		return
+	}
+	filter, err := ParseJobFilter(r)
+	if err != nil {
+		WriteErrorJSON(w, http.StatusBadRequest, "Filtro inválido", err)
+		return
+	}
+	jobs, total, err := h.History.Query(filter)
+	if err != nil {
+		WriteErrorJSON(w, http.StatusInternalServerError, "Error al consultar el historial", err)
+		return
+	}
+	WriteJSON(w, http.StatusOK, JobListResponse{Total: total, Limit: filter.Limit, Offset: filter.Offset, Jobs: jobs})
+}
+
+// JobHandler devuelve un trabajo del historial por su job_id (GET) o lo cancela (DELETE)
+func (h Handlers) JobHandler(w http.ResponseWriter, r *http.Request) {
+	h.Logger = h.Logger.ForRequest(r)
+	h.Logger.Info("Received request: /jobs/{id}")
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodDelete:
+		h.cancelJob(w, r)
+		return
+	default:
+		WriteErrorJSON(w, http.StatusMethodNotAllowed, "Método HTTP no permitido", nil)
+		return
+	}
+	job, err := h.History.Get(r.PathValue("id"))
+	if err != nil {
+		WriteErrorJSON(w, http.StatusInternalServerError, "Error al consultar el historial", err)
+		return
+	}
+	if job == nil {
+		WriteErrorJSON(w, http.StatusNotFound, "Trabajo no encontrado", nil)
+		return
+	}
+	WriteJSON(w, http.StatusOK, job)
+}
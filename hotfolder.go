@@ -0,0 +1,252 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ============================
+// Carpetas de Impresión Automática
+// ============================
+
+// Qué se hace con el archivo después de imprimirlo
+const (
+	HotFolderArchive = "archive"
+	HotFolderDelete  = "delete"
+)
+
+// Subcarpetas predeterminadas de los archivos impresos y de los que fallaron
+const (
+	hotFolderArchiveName = "impresos"
+	hotFolderErrorName   = "errores"
+)
+
+// HotFolderConfig configura las carpetas cuyos PDF se imprimen automáticamente, para los sistemas
+// que solo pueden exportar archivos a una carpeta compartida
+type HotFolderConfig struct {
+	// Folders asigna cada carpeta a su impresora (o alias o grupo)
+	Folders     map[string]string
+	After       string
+	ArchiveDir  string
+	PollSeconds int
+}
+
+// LoadHotFolderConfig carga la configuración de las carpetas desde variables de entorno
+func LoadHotFolderConfig() HotFolderConfig {
+	return HotFolderConfig{
+		Folders:     getEnvAsMap("HOT_FOLDERS", ""),
+		After:       getEnv("HOT_FOLDER_AFTER", HotFolderArchive),
+		ArchiveDir:  getEnv("HOT_FOLDER_ARCHIVE_DIR", ""),
+		PollSeconds: getEnvAsInt("HOT_FOLDER_POLL_SECONDS", 5),
+	}
+}
+
+// hotFolderFile es el tamaño y la fecha de un archivo en la consulta anterior; el archivo se imprime
+// cuando no cambió entre dos consultas, para no tomar uno que se está copiando
+type hotFolderFile struct {
+	size    int64
+	modTime time.Time
+}
+
+// HotFolderWatcher consulta las carpetas configuradas e imprime los PDF nuevos en la impresora de
+// cada carpeta; después los archiva o elimina. Los que no se pueden imprimir pasan a "errores".
+type HotFolderWatcher struct {
+	Folders    map[string]string
+	After      string
+	ArchiveDir string
+	Poll       time.Duration
+	// Print imprime el archivo como un trabajo más
+	Print  func(path, printer string) error
+	Logger *Logger
+
+	seen map[string]hotFolderFile
+	// stuck son los archivos ya procesados que no se pudieron mover ni eliminar; se ignoran mientras
+	// no cambien, para no imprimirlos otra vez
+	stuck map[string]hotFolderFile
+	done  chan struct{}
+}
+
+// NewHotFolderWatcher valida la configuración; sin carpetas devuelve nil
+func NewHotFolderWatcher(cfg HotFolderConfig, logger *Logger) (*HotFolderWatcher, error) {
+	if len(cfg.Folders) == 0 {
+		return nil, nil
+	}
+	if cfg.After != HotFolderArchive && cfg.After != HotFolderDelete {
+		return nil, fmt.Errorf("HOT_FOLDER_AFTER inválido: %s (use archive o delete)", cfg.After)
+	}
+	if cfg.PollSeconds <= 0 {
+		return nil, fmt.Errorf("HOT_FOLDER_POLL_SECONDS debe ser mayor que cero")
+	}
+	folders := make(map[string]string, len(cfg.Folders))
+	for dir, printer := range cfg.Folders {
+		if printer == "" {
+			return nil, fmt.Errorf("HOT_FOLDERS: la carpeta '%s' no indica la impresora", dir)
+		}
+		// Las carpetas compartidas pueden no estar disponibles todavía al iniciar el equipo
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			logger.Warnf("Carpeta de impresión automática '%s' no disponible por ahora: %v", dir, err)
+		}
+		folders[filepath.Clean(dir)] = printer
+	}
+	return &HotFolderWatcher{
+		Folders:    folders,
+		After:      cfg.After,
+		ArchiveDir: cfg.ArchiveDir,
+		Poll:       time.Duration(cfg.PollSeconds) * time.Second,
+		Logger:     logger,
+		seen:       make(map[string]hotFolderFile),
+		stuck:      make(map[string]hotFolderFile),
+		done:       make(chan struct{}),
+	}, nil
+}
+
+// Start inicia la consulta de las carpetas en segundo plano
+func (w *HotFolderWatcher) Start() {
+	for dir, printer := range w.Folders {
+		w.Logger.Infof("Carpeta de impresión automática '%s' -> '%s'", dir, printer)
+	}
+	go w.run()
+}
+
+// Close detiene la consulta de las carpetas
+func (w *HotFolderWatcher) Close() error {
+	close(w.done)
+	return nil
+}
+
+func (w *HotFolderWatcher) run() {
+	defer recoverCrash()
+	ticker := time.NewTicker(w.Poll)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-w.done:
+			return
+		}
+		w.scan()
+	}
+}
+
+// scan imprime, en orden alfabético, los PDF que no cambiaron desde la consulta anterior
+func (w *HotFolderWatcher) scan() {
+	current := make(map[string]hotFolderFile, len(w.seen))
+	dirs := make([]string, 0, len(w.Folders))
+	for dir := range w.Folders {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			w.Logger.Warnf("No se pudo leer la carpeta '%s': %v", dir, err)
+			continue
+		}
+		for _, entry := range entries {
+			if !entry.Type().IsRegular() || !strings.EqualFold(filepath.Ext(entry.Name()), ".pdf") {
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil {
+				continue
+			}
+			path := filepath.Join(dir, entry.Name())
+			state := hotFolderFile{size: info.Size(), modTime: info.ModTime()}
+			if prev, ok := w.stuck[path]; ok && prev == state {
+				continue
+			}
+			delete(w.stuck, path)
+			if prev, ok := w.seen[path]; !ok || prev != state {
+				current[path] = state
+				continue
+			}
+			select {
+			case <-w.done:
+				return
+			default:
+			}
+			w.process(dir, path)
+			if _, err := os.Stat(path); err == nil {
+				w.stuck[path] = state
+			}
+		}
+	}
+	w.seen = current
+}
+
+// process imprime el archivo y lo archiva o elimina; si falla, lo mueve a la subcarpeta de errores
+// para no reintentarlo en cada consulta
+func (w *HotFolderWatcher) process(dir, path string) {
+	printer := w.Folders[dir]
+	w.Logger.Infof("Imprimiendo '%s' de la carpeta automática en '%s'", path, printer)
+	if err := w.Print(path, printer); err != nil {
+		w.Logger.Errorf("Error al imprimir '%s': %v", path, err)
+		w.move(path, filepath.Join(dir, hotFolderErrorName))
+		return
+	}
+	if w.After == HotFolderDelete {
+		if err := os.Remove(path); err != nil {
+			w.Logger.Errorf("Error al eliminar '%s': %v", path, err)
+		}
+		return
+	}
+	archive := w.ArchiveDir
+	if archive == "" {
+		archive = filepath.Join(dir, hotFolderArchiveName)
+	}
+	w.move(path, archive)
+}
+
+// move traslada el archivo a la carpeta indicada con la fecha y hora como prefijo del nombre
+func (w *HotFolderWatcher) move(path, dstDir string) {
+	if err := os.MkdirAll(dstDir, 0o755); err != nil {
+		w.Logger.Errorf("Error al crear la carpeta '%s': %v", dstDir, err)
+		return
+	}
+	dst := filepath.Join(dstDir, time.Now().Format("20060102-150405")+"-"+filepath.Base(path))
+	err := os.Rename(path, dst)
+	if err != nil {
+		// La carpeta de destino puede estar en otra unidad
+		if err = copyFile(path, dst); err == nil {
+			err = os.Remove(path)
+		}
+	}
+	if err != nil {
+		w.Logger.Errorf("Error al mover '%s' a '%s': %v", path, dstDir, err)
+	}
+}
+
+// printHotFolderFile imprime un PDF de una carpeta automática como un trabajo de /print-file
+func (h Handlers) printHotFolderFile(path, printer string) error {
+	// Sin licencia válida el archivo pasa a la carpeta de errores, como con /print-file
+	if err := h.Licenses.Check(); err != nil {
+		return fmt.Errorf("archivo rechazado: %w", err)
+	}
+	printer, group := h.groupPrinter(printer)
+	job := NewPrintJob(JobKindPrint, printer, "hotfolder:"+filepath.Base(path))
+	job.Group = group
+	var opts PrintOptions
+	job.Options = &opts
+	opts.JobID = job.ID
+	if f, err := os.Open(path); err == nil {
+		job.SHA256 = documentSHA256(f)
+		f.Close()
+	}
+
+	// El archivo se archiva al terminar, por lo que estos trabajos no se retienen por falta de papel
+	return h.Jobs.Run(job, func() error {
+		if _, err := inspectDocument(path, h.MaxUploadBytes); err != nil {
+			return err
+		}
+		if err := h.preflight(job); err != nil {
+			return err
+		}
+		return h.Service.PrintPDFFromFile(path, printer, opts)
+	})
+}
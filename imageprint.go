@@ -0,0 +1,407 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	xdraw "golang.org/x/image/draw"
+)
+
+// ============================
+// Impresión de Imágenes (logos, firmas)
+// ============================
+
+// Métodos para llevar la imagen a blanco y negro
+const (
+	DitherFloydSteinberg = "floyd-steinberg" // difusión del error: conserva los grises (fotos, firmas escaneadas)
+	DitherThreshold      = "threshold"       // umbral fijo: bordes nítidos (logos, texto)
+)
+
+// Salidas de /print-image
+const (
+	ImageOutputRaster = "raster" // mapa de bits ESC/POS (GS v 0) para impresoras térmicas
+	ImageOutputPDF    = "pdf"    // PDF con la imagen, impreso como cualquier documento
+)
+
+// maxImagePixels limita el tamaño de la imagen decodificada (una imagen comprimida pequeña puede
+// ocupar gigabytes en memoria)
+const maxImagePixels = 40_000_000
+
+// imagePDFImport ubica la imagen centrada arriba de una hoja A4 con el ancho a la mitad de la
+// página; un logo o una firma a página completa se vería pixelado
+const imagePDFImport = "formsize:A4, position:tc, offset:0 -36, scalefactor:0.5 rel"
+
+// ImageOptions son las opciones de impresión de una imagen
+type ImageOptions struct {
+	Width     int    `json:"width,omitempty"`
+	Dither    string `json:"dither,omitempty"`
+	Threshold int    `json:"threshold,omitempty"`
+	Align     string `json:"align,omitempty"`
+	Cut       string `json:"cut,omitempty"`
+	Output    string `json:"output,omitempty"`
+	Copies    int    `json:"copies,omitempty"`
+	JobID     string `json:"-"`
+}
+
+// Normalize valida las opciones y completa los valores predeterminados
+func (o *ImageOptions) Normalize() error {
+	o.Dither, o.Align, o.Cut, o.Output = strings.ToLower(o.Dither), strings.ToLower(o.Align), strings.ToLower(o.Cut), strings.ToLower(o.Output)
+	switch o.Dither {
+	case "":
+		o.Dither = DitherFloydSteinberg
+	case DitherFloydSteinberg, DitherThreshold:
+	default:
+		return fmt.Errorf("dither inválido: %s (use floyd-steinberg o threshold)", o.Dither)
+	}
+	if o.Threshold == 0 {
+		o.Threshold = 128
+	}
+	if o.Threshold < 1 || o.Threshold > 255 {
+		return fmt.Errorf("threshold inválido: %d (rango 1-255)", o.Threshold)
+	}
+	switch o.Align {
+	case "":
+		o.Align = AlignCenter
+	case AlignLeft, AlignCenter, AlignRight:
+	default:
+		return fmt.Errorf("align inválido: %s (use left, center o right)", o.Align)
+	}
+	switch o.Cut {
+	case "", ReceiptCutFull, ReceiptCutPartial, ReceiptCutNone:
+	default:
+		return fmt.Errorf("cut inválido: %s (use full, partial o none)", o.Cut)
+	}
+	switch o.Output {
+	case "", ImageOutputRaster, ImageOutputPDF, ImageOutputGDI:
+	default:
+		return fmt.Errorf("output inválido: %s (use raster, pdf o gdi)", o.Output)
+	}
+	if o.Width < 0 {
+		return fmt.Errorf("width inválido: %d", o.Width)
+	}
+	if o.Copies < 0 || o.Copies > maxCopies {
+		return fmt.Errorf("cantidad de copias inválida: %d (máximo %d)", o.Copies, maxCopies)
+	}
+	return nil
+}
+
+// parseImageOptionsForm lee las opciones de los campos de un formulario multipart
+func parseImageOptionsForm(get func(string) string) (ImageOptions, error) {
+	opts := ImageOptions{Dither: get("dither"), Align: get("align"), Cut: get("cut"), Output: get("output")}
+	for _, f := range []struct {
+		name string
+		dst  *int
+	}{{"width", &opts.Width}, {"threshold", &opts.Threshold}, {"copies", &opts.Copies}} {
+		if v := get(f.name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return opts, fmt.Errorf("%s inválido: %s", f.name, v)
+			}
+			*f.dst = n
+		}
+	}
+	return opts, opts.Normalize()
+}
+
+// checkImage verifica que los datos sean una imagen PNG, JPEG o GIF de tamaño razonable
+func checkImage(data []byte) error {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("la imagen no es PNG, JPEG ni GIF: %w", err)
+	}
+	if cfg.Width*cfg.Height > maxImagePixels {
+		return fmt.Errorf("la imagen %s de %dx%d supera el máximo de %d píxeles", format, cfg.Width, cfg.Height, maxImagePixels)
+	}
+	return nil
+}
+
+// DitherImage escala la imagen al ancho indicado (en puntos) conservando la proporción y la lleva
+// a blanco y negro. Las zonas transparentes se consideran blancas.
+func DitherImage(src image.Image, width int, method string, threshold int) *image.Gray {
+	bounds := src.Bounds()
+	height := max(1, bounds.Dy()*width/bounds.Dx())
+	canvas := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(canvas, canvas.Bounds(), image.White, image.Point{}, draw.Src)
+	xdraw.CatmullRom.Scale(canvas, canvas.Bounds(), src, bounds, draw.Over, nil)
+
+	levels := make([]float64, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			levels[y*width+x] = float64(color.GrayModel.Convert(canvas.At(x, y)).(color.Gray).Y)
+		}
+	}
+
+	out := image.NewGray(canvas.Bounds())
+	spread := func(x, y int, amount float64) {
+		if x >= 0 && x < width && y < height {
+			levels[y*width+x] += amount
+		}
+	}
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			old := levels[y*width+x]
+			value := 255.0
+			if old < float64(threshold) {
+				value = 0
+			}
+			out.Pix[y*out.Stride+x] = uint8(value)
+			if method == DitherFloydSteinberg {
+				e := old - value
+				spread(x+1, y, e*7/16)
+				spread(x-1, y+1, e*3/16)
+				spread(x, y+1, e*5/16)
+				spread(x+1, y+1, e*1/16)
+			}
+		}
+	}
+	return out
+}
+
+// imageToPDF escribe un PDF temporal con la imagen para las impresoras de páginas
+func imageToPDF(data []byte) (string, error) {
+	imp, err := api.Import(imagePDFImport, types.POINTS)
+	if err != nil {
+		return "", err
+	}
+	out, err := os.CreateTemp("", "image-*.pdf")
+	if err != nil {
+		return "", fmt.Errorf("error al crear el PDF de la imagen: %w", err)
+	}
+	defer out.Close()
+	if err := api.ImportImages(nil, out, []io.Reader{bytes.NewReader(data)}, imp, model.NewDefaultConfiguration()); err != nil {
+		out.Close()
+		os.Remove(out.Name())
+		return "", fmt.Errorf("error al convertir la imagen a PDF: %w", err)
+	}
+	return out.Name(), nil
+}
+
+// PrintImage imprime la imagen: como mapa de bits ESC/POS en las impresoras térmicas o dentro de
+// un PDF en las impresoras de tipo laser (según el perfil, salvo que se indique output). Con
+// GDI_PRINTING las impresoras de tipo laser reciben la imagen dibujada con GDI.
+func (d DefaultPrinterService) PrintImage(printerName string, data []byte, opts ImageOptions) error {
+	printerName, err := d.resolvePrinter(printerName)
+	if err != nil {
+		return err
+	}
+	profile := d.profile(printerName)
+	if err := profile.requireType(printerName, PrinterTypeThermal, PrinterTypeLaser); err != nil {
+		return err
+	}
+	if opts.Copies == 0 {
+		opts.Copies = profile.Copies
+	}
+	output := opts.Output
+	if output == "" {
+		output = ImageOutputRaster
+		if profile.Type == PrinterTypeLaser {
+			output = ImageOutputPDF
+			if d.GDIDefault && d.GDI != nil {
+				output = ImageOutputGDI
+			}
+		}
+	}
+
+	if output == ImageOutputPDF {
+		pdfPath, err := imageToPDF(data)
+		if err != nil {
+			return err
+		}
+		return d.printTempFile(pdfPath, printerName, PrintOptions{Copies: opts.Copies, JobID: opts.JobID})
+	}
+
+	if output == ImageOutputGDI && d.GDI == nil {
+		return permanent(ErrGDIUnsupported)
+	}
+	if output == ImageOutputRaster && d.ReceiptWriter == nil {
+		return fmt.Errorf("la impresión de imágenes ESC/POS no está disponible")
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("error al decodificar la imagen: %w", err)
+	}
+	if output == ImageOutputGDI {
+		if err := d.GDI.DrawImage(printerName, img, opts); err != nil {
+			return fmt.Errorf("error al imprimir la imagen: %w", err)
+		}
+		return nil
+	}
+	dots := NewReceiptLayout(profile.WidthMM, false).Dots
+	width := opts.Width
+	if width == 0 {
+		width = min(img.Bounds().Dx(), dots)
+	}
+	if width > dots {
+		return fmt.Errorf("width %d supera el ancho imprimible del rollo de %dmm (%d puntos)", width, profile.WidthMM, dots)
+	}
+
+	var b ESCPOSBuffer
+	b.Init()
+	b.Align(opts.Align)
+	b.Raster(DitherImage(img, width, opts.Dither, opts.Threshold))
+	cut := opts.Cut
+	if cut == "" {
+		cut = profile.Cut
+	}
+	b.Feed(3)
+	if cut != ReceiptCutNone {
+		b.Cut(cut == ReceiptCutPartial)
+	}
+	raster := b.Bytes()
+	if opts.Copies > 1 {
+		raster = bytes.Repeat(raster, opts.Copies)
+	}
+	d.Logger.Infof("Imagen de %dx%d enviada como mapa de bits de %d puntos de ancho a '%s'", img.Bounds().Dx(), img.Bounds().Dy(), width, printerName)
+	if err := d.ReceiptWriter.WriteRaw(printerName, raster); err != nil {
+		return fmt.Errorf("error al imprimir la imagen: %w", err)
+	}
+	return nil
+}
+
+// ImageRequest es el cuerpo JSON de POST /print-image
+type ImageRequest struct {
+	Printer string `json:"printer"`
+	URL     string `json:"url"`
+	Data    string `json:"data"`
+	ImageOptions
+}
+
+// PrintImageHandler imprime una imagen PNG, JPEG o GIF (POST /print-image). Acepta JSON con url o
+// data (base64), o multipart/form-data con el campo file.
+func (h Handlers) PrintImageHandler(w http.ResponseWriter, r *http.Request) {
+	h.Logger = h.Logger.ForRequest(r)
+	h.Logger.Info("Received request: /print-image")
+
+	if r.Method != http.MethodPost {
+		h.Logger.Warnf("Método HTTP no permitido: %s", r.Method)
+		WriteErrorJSON(w, http.StatusMethodNotAllowed, "Método HTTP no permitido", nil)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, h.MaxUploadBytes)
+	var printer, fileURL, source string
+	var data []byte
+	var opts ImageOptions
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		if err := r.ParseMultipartForm(multipartMemoryLimit); err != nil {
+			h.Logger.Warnf("Error al procesar el formulario multipart: %v", err)
+			WriteErrorJSON(w, http.StatusBadRequest, "Formulario multipart inválido o archivo demasiado grande", err)
+			return
+		}
+		defer r.MultipartForm.RemoveAll()
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			WriteErrorJSON(w, http.StatusBadRequest, "Falta el archivo de imagen (campo file)", err)
+			return
+		}
+		defer file.Close()
+		if data, err = io.ReadAll(file); err != nil {
+			WriteErrorJSON(w, http.StatusBadRequest, "Error al leer la imagen", err)
+			return
+		}
+		printer, source = r.FormValue("printer"), "upload:"+header.Filename
+		if opts, err = parseImageOptionsForm(r.FormValue); err != nil {
+			h.Logger.Warnf("Opciones de imagen inválidas: %v", err)
+			WriteErrorJSON(w, http.StatusBadRequest, "Opciones de imagen inválidas", err)
+			return
+		}
+	} else {
+		var req ImageRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			h.Logger.Warnf("Error al decodificar JSON: %v", err)
+			WriteErrorJSON(w, http.StatusBadRequest, "Solicitud JSON inválida", err)
+			return
+		}
+		if (req.URL == "") == (req.Data == "") {
+			WriteErrorJSON(w, http.StatusBadRequest, "Debe indicar url o data", nil)
+			return
+		}
+		if req.Data != "" {
+			decoded, err := io.ReadAll(base64DocumentReader(req.Data))
+			if err != nil {
+				WriteErrorJSON(w, http.StatusBadRequest, "data no es base64 válido", err)
+				return
+			}
+			data, source = decoded, "base64"
+		}
+		printer, fileURL, opts = req.Printer, req.URL, req.ImageOptions
+		if fileURL != "" {
+			source = fileURL
+		}
+		if err := opts.Normalize(); err != nil {
+			h.Logger.Warnf("Opciones de imagen inválidas: %v", err)
+			WriteErrorJSON(w, http.StatusBadRequest, "Opciones de imagen inválidas", err)
+			return
+		}
+	}
+	if data != nil {
+		if err := checkImage(data); err != nil {
+			h.Logger.Warnf("Imagen inválida: %v", err)
+			WriteErrorJSON(w, http.StatusBadRequest, "Imagen inválida", err)
+			return
+		}
+	}
+	printer, err := h.defaultPrinter(printer)
+	if err != nil {
+		h.Logger.Warnf("No se especificó la impresora: %v", err)
+		WriteErrorJSON(w, http.StatusBadRequest, "No se especificó la impresora", err)
+		return
+	}
+
+	job := NewPrintJob(JobKindImage, printer, source)
+	job.RequestID = RequestID(r)
+	job.ClientCN = ClientCommonName(r)
+	opts.JobID = job.ID
+	if data != nil {
+		job.SHA256 = documentSHA256(bytes.NewReader(data))
+	}
+	err = h.Jobs.RunHoldable(job, func() error {
+		if err := h.preflight(job); err != nil {
+			return err
+		}
+		image := data
+		if fileURL != "" {
+			ctx, cancel := downloadContext(r, 0)
+			defer cancel()
+			path, err := h.Service.FetchDocument(ctx, fileURL, "")
+			if err != nil {
+				return err
+			}
+			defer os.Remove(path)
+			if image, err = os.ReadFile(path); err != nil {
+				return err
+			}
+			if err := checkImage(image); err != nil {
+				return err
+			}
+		}
+		return h.Service.PrintImage(printer, image, opts)
+	})
+	if errors.Is(err, ErrJobHeld) {
+		WriteJobHeldJSON(w, job)
+		return
+	}
+	if err != nil {
+		h.Logger.Errorf("Error al imprimir la imagen: %v", err)
+		WriteJobErrorJSON(w, jobErrorStatus(err), job, "Error al imprimir la imagen", err)
+		return
+	}
+
+	WriteJobJSON(w, job, "Imagen enviada a la impresora.")
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ============================
+// Restricción de Acceso por IP
+// ============================
+
+// IPAccess limita qué direcciones pueden llamar a cada ruta, para que cualquier equipo de la red
+// Wi-Fi de la tienda no pueda imprimir ni abrir el cajón
+type IPAccess struct {
+	// rules son las redes permitidas por ruta; "*" aplica a las rutas sin regla propia
+	rules  map[string][]*net.IPNet
+	Logger *Logger
+}
+
+// NewIPAccess interpreta IP_ALLOWLIST: "ruta=red|red" separados por comas, donde cada red es una IP
+// o un CIDR (p. ej. "/open-box=192.168.1.10|192.168.1.11,*=192.168.1.0/24"). Sin reglas devuelve nil.
+func NewIPAccess(allowlist map[string]string, logger *Logger) (*IPAccess, error) {
+	if len(allowlist) == 0 {
+		return nil, nil
+	}
+	a := &IPAccess{rules: make(map[string][]*net.IPNet, len(allowlist)), Logger: logger}
+	for route, list := range allowlist {
+		if route != "*" && !strings.HasPrefix(route, "/") {
+			return nil, fmt.Errorf("IP_ALLOWLIST: la ruta debe empezar con / o ser *: %s", route)
+		}
+		// Las reglas se aplican igual a la ruta con y sin el prefijo /v1
+		if trimmed := strings.TrimPrefix(route, apiPrefix); strings.HasPrefix(trimmed, "/") {
+			route = trimmed
+		}
+		// Una lista vacía solo permite el propio equipo
+		a.rules[route] = nil
+		for _, entry := range splitAndTrim(list, "|") {
+			network, err := parseNetwork(entry)
+			if err != nil {
+				return nil, fmt.Errorf("IP_ALLOWLIST: %s: %w", route, err)
+			}
+			a.rules[route] = append(a.rules[route], network)
+		}
+	}
+	return a, nil
+}
+
+// parseNetwork acepta una IP (que se trata como /32 o /128) o un CIDR
+func parseNetwork(entry string) (*net.IPNet, error) {
+	if strings.Contains(entry, "/") {
+		_, network, err := net.ParseCIDR(entry)
+		return network, err
+	}
+	ip := net.ParseIP(entry)
+	if ip == nil {
+		return nil, fmt.Errorf("dirección inválida: %s", entry)
+	}
+	bits := 8 * net.IPv6len
+	if ip.To4() != nil {
+		ip, bits = ip.To4(), 8*net.IPv4len
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+}
+
+// Allowed indica si la dirección puede llamar a la ruta. El propio equipo (loopback) y las
+// solicitudes del relay, que se autentican con RELAY_TOKEN, siempre se permiten.
+func (a *IPAccess) Allowed(route, remoteAddr string) bool {
+	networks, ok := a.rules[route]
+	if !ok {
+		if networks, ok = a.rules["*"]; !ok {
+			return true
+		}
+	}
+	if remoteAddr == "relay" {
+		return true
+	}
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	if ip.IsLoopback() {
+		return true
+	}
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Wrap protege el manejador de la ruta con su lista de direcciones permitidas
+func (a *IPAccess) Wrap(route string, next http.Handler) http.Handler {
+	if a == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !a.Allowed(route, r.RemoteAddr) {
+			a.Logger.ForRequest(r).Warnf("Acceso a %s rechazado desde %s (IP_ALLOWLIST)", r.URL.Path, r.RemoteAddr)
+			WriteErrorCodeJSON(w, http.StatusForbidden, ErrCodeIPNotAllowed, "La dirección de origen no tiene permiso para usar este endpoint", nil)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,639 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// ============================
+// Cliente IPP (Internet Printing Protocol)
+// ============================
+
+// Operaciones y etiquetas de IPP/1.1 (RFC 8010 y RFC 8011) usadas por el agente
+const (
+	ippOpPrintJob             = 0x0002
+	ippOpGetPrinterAttributes = 0x000B
+
+	ippTagOperation = 0x01
+	ippTagJob       = 0x02
+	ippTagEnd       = 0x03
+	ippTagPrinter   = 0x04
+
+	ippValueInteger       = 0x21
+	ippValueBoolean       = 0x22
+	ippValueEnum          = 0x23
+	ippValueRange         = 0x33
+	ippValueBegCollection = 0x34
+	ippValueEndCollection = 0x37
+	ippValueText          = 0x41
+	ippValueName          = 0x42
+	ippValueKeyword       = 0x44
+	ippValueURI           = 0x45
+	ippValueCharset       = 0x47
+	ippValueLanguage      = 0x48
+	ippValueMimeType      = 0x49
+	ippValueMemberName    = 0x4A
+)
+
+// Valores de printer-state
+const (
+	ippPrinterIdle       = 3
+	ippPrinterProcessing = 4
+	ippPrinterStopped    = 5
+)
+
+// ippMediaNames traduce los tamaños de papel aceptados a los nombres de medio de IPP (PWG 5101.1)
+var ippMediaNames = map[string]string{
+	"letter":    "na_letter_8.5x11in",
+	"legal":     "na_legal_8.5x14in",
+	"executive": "na_executive_7.25x10.5in",
+	"a3":        "iso_a3_297x420mm",
+	"a4":        "iso_a4_210x297mm",
+	"a5":        "iso_a5_148x210mm",
+	"b5":        "iso_b5_176x250mm",
+}
+
+// ippMediaSizes son las dimensiones de cada tamaño de papel en centésimas de milímetro (media-size de media-col)
+var ippMediaSizes = map[string][2]int{
+	"letter":    {21590, 27940},
+	"legal":     {21590, 35560},
+	"executive": {18415, 26670},
+	"a3":        {29700, 42000},
+	"a4":        {21000, 29700},
+	"a5":        {14800, 21000},
+	"b5":        {17600, 25000},
+}
+
+// ippPrintScaling mapea las escalas a los valores de print-scaling (PWG 5100.13)
+var ippPrintScaling = map[string]string{
+	ScaleFit:    "fit",
+	ScaleShrink: "auto-fit",
+	ScaleNone:   "none",
+}
+
+// ippRequestID numera las solicitudes enviadas por el agente
+var ippRequestID uint32
+
+// ippAttribute es un atributo IPP con uno o más valores codificados
+type ippAttribute struct {
+	Tag    byte
+	Name   string
+	Values [][]byte
+}
+
+// AsString devuelve el primer valor como texto
+func (a ippAttribute) AsString() string {
+	if len(a.Values) == 0 {
+		return ""
+	}
+	return string(a.Values[0])
+}
+
+// AsStrings devuelve todos los valores como texto
+func (a ippAttribute) AsStrings() []string {
+	values := make([]string, 0, len(a.Values))
+	for _, v := range a.Values {
+		values = append(values, string(v))
+	}
+	return values
+}
+
+// AsInt devuelve el primer valor como entero (integer o enum)
+func (a ippAttribute) AsInt() int {
+	if len(a.Values) == 0 || len(a.Values[0]) != 4 {
+		return 0
+	}
+	return int(int32(binary.BigEndian.Uint32(a.Values[0])))
+}
+
+// AsBool devuelve el primer valor como booleano
+func (a ippAttribute) AsBool() bool {
+	return len(a.Values) > 0 && len(a.Values[0]) == 1 && a.Values[0][0] == 1
+}
+
+// ippMessage es una solicitud o respuesta IPP
+type ippMessage struct {
+	Code      uint16 // operation-id en solicitudes, status-code en respuestas
+	RequestID uint32
+	Groups    []ippGroup
+}
+
+// ippGroup es un grupo de atributos (operación, trabajo o impresora)
+type ippGroup struct {
+	Tag        byte
+	Attributes []ippAttribute
+}
+
+// Attribute busca el atributo en todos los grupos del mensaje
+func (m *ippMessage) Attribute(name string) (ippAttribute, bool) {
+	for _, g := range m.Groups {
+		for _, a := range g.Attributes {
+			if a.Name == name {
+				return a, true
+			}
+		}
+	}
+	return ippAttribute{}, false
+}
+
+// ippStrings crea un atributo de texto con uno o más valores
+func ippStrings(tag byte, name string, values ...string) ippAttribute {
+	attr := ippAttribute{Tag: tag, Name: name}
+	for _, v := range values {
+		attr.Values = append(attr.Values, []byte(v))
+	}
+	return attr
+}
+
+// ippInt crea un atributo integer o enum
+func ippInt(tag byte, name string, value int) ippAttribute {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, uint32(int32(value)))
+	return ippAttribute{Tag: tag, Name: name, Values: [][]byte{b}}
+}
+
+// ippMediaCol arma el atributo media-col con la bandeja y, si se indica, el tamaño de papel. La
+// colección se codifica como una secuencia de atributos sin nombre entre begCollection y endCollection.
+func ippMediaCol(source, paper string) []ippAttribute {
+	empty := [][]byte{{}}
+	member := func(name string) ippAttribute {
+		return ippStrings(ippValueMemberName, "", name)
+	}
+	attrs := []ippAttribute{
+		{Tag: ippValueBegCollection, Name: "media-col", Values: empty},
+		member("media-source"),
+		ippStrings(ippValueKeyword, "", source),
+	}
+	if size, ok := ippMediaSizes[paper]; ok {
+		attrs = append(attrs,
+			member("media-size"),
+			ippAttribute{Tag: ippValueBegCollection, Values: empty},
+			member("x-dimension"), ippInt(ippValueInteger, "", size[0]),
+			member("y-dimension"), ippInt(ippValueInteger, "", size[1]),
+			ippAttribute{Tag: ippValueEndCollection, Values: empty},
+		)
+	}
+	return append(attrs, ippAttribute{Tag: ippValueEndCollection, Values: empty})
+}
+
+// Encode serializa el mensaje; los valores adicionales de un atributo se escriben con nombre vacío
+func (m *ippMessage) Encode() []byte {
+	var buf bytes.Buffer
+	buf.Write([]byte{1, 1})
+	binary.Write(&buf, binary.BigEndian, m.Code)
+	binary.Write(&buf, binary.BigEndian, m.RequestID)
+	for _, g := range m.Groups {
+		buf.WriteByte(g.Tag)
+		for _, a := range g.Attributes {
+			for i, v := range a.Values {
+				name := a.Name
+				if i > 0 {
+					name = ""
+				}
+				buf.WriteByte(a.Tag)
+				binary.Write(&buf, binary.BigEndian, uint16(len(name)))
+				buf.WriteString(name)
+				binary.Write(&buf, binary.BigEndian, uint16(len(v)))
+				buf.Write(v)
+			}
+		}
+	}
+	buf.WriteByte(ippTagEnd)
+	return buf.Bytes()
+}
+
+// decodeIPPMessage interpreta una respuesta IPP
+func decodeIPPMessage(data []byte) (*ippMessage, error) {
+	r := bytes.NewReader(data)
+	var header struct {
+		Version   [2]byte
+		Code      uint16
+		RequestID uint32
+	}
+	if err := binary.Read(r, binary.BigEndian, &header); err != nil {
+		return nil, fmt.Errorf("respuesta IPP incompleta: %w", err)
+	}
+	msg := &ippMessage{Code: header.Code, RequestID: header.RequestID}
+
+	var group *ippGroup
+	for {
+		tag, err := r.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("respuesta IPP sin fin de atributos: %w", err)
+		}
+		if tag == ippTagEnd {
+			return msg, nil
+		}
+		if tag < 0x10 {
+			msg.Groups = append(msg.Groups, ippGroup{Tag: tag})
+			group = &msg.Groups[len(msg.Groups)-1]
+			continue
+		}
+		if group == nil {
+			return nil, errors.New("respuesta IPP con atributos fuera de un grupo")
+		}
+		name, err := readIPPField(r)
+		if err != nil {
+			return nil, err
+		}
+		value, err := readIPPField(r)
+		if err != nil {
+			return nil, err
+		}
+		if len(name) == 0 && len(group.Attributes) > 0 {
+			last := &group.Attributes[len(group.Attributes)-1]
+			last.Values = append(last.Values, value)
+			continue
+		}
+		group.Attributes = append(group.Attributes, ippAttribute{Tag: tag, Name: string(name), Values: [][]byte{value}})
+	}
+}
+
+func readIPPField(r *bytes.Reader) ([]byte, error) {
+	var length uint16
+	if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+		return nil, fmt.Errorf("respuesta IPP truncada: %w", err)
+	}
+	field := make([]byte, length)
+	if _, err := io.ReadFull(r, field); err != nil {
+		return nil, fmt.Errorf("respuesta IPP truncada: %w", err)
+	}
+	return field, nil
+}
+
+// IPPClient envía operaciones IPP por HTTP (ipp://) o HTTPS (ipps://)
+type IPPClient struct {
+	HTTP     *http.Client
+	UserName string
+}
+
+// NewIPPClient crea el cliente; insecureTLS acepta certificados autofirmados, habituales en impresoras
+func NewIPPClient(timeout time.Duration, insecureTLS bool) *IPPClient {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if insecureTLS {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	return &IPPClient{
+		HTTP:     &http.Client{Timeout: timeout, Transport: transport},
+		UserName: "PrinterMatiasERP",
+	}
+}
+
+// ippHTTPURL convierte la URI de la impresora (ipp://host/ipp/print) en la URL HTTP equivalente
+func ippHTTPURL(printerURI string) (string, error) {
+	u, err := url.Parse(printerURI)
+	if err != nil {
+		return "", fmt.Errorf("URI de impresora IPP inválida '%s': %w", printerURI, err)
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "ipp", "http":
+		u.Scheme = "http"
+	case "ipps", "https":
+		u.Scheme = "https"
+	default:
+		return "", fmt.Errorf("URI de impresora IPP inválida '%s': use ipp:// o ipps://", printerURI)
+	}
+	if u.Port() == "" {
+		u.Host += ":631"
+	}
+	return u.String(), nil
+}
+
+// do envía la solicitud (con el documento a continuación, si corresponde) y verifica el status-code
+func (c *IPPClient) do(printerURI string, req *ippMessage, document io.Reader) (*ippMessage, error) {
+	endpoint, err := ippHTTPURL(printerURI)
+	if err != nil {
+		return nil, err
+	}
+	req.RequestID = atomic.AddUint32(&ippRequestID, 1)
+	var body io.Reader = bytes.NewReader(req.Encode())
+	if document != nil {
+		body = io.MultiReader(body, document)
+	}
+	httpReq, err := http.NewRequest(http.MethodPost, endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/ipp")
+
+	resp, err := c.HTTP.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo conectar a la impresora IPP %s: %w", endpoint, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("la impresora IPP %s respondió HTTP %d", endpoint, resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("error al leer la respuesta IPP: %w", err)
+	}
+	msg, err := decodeIPPMessage(data)
+	if err != nil {
+		return nil, err
+	}
+	// Los códigos 0x0000-0x00FF indican éxito (posiblemente con atributos ignorados)
+	if msg.Code > 0x00FF {
+		detail := fmt.Sprintf("0x%04X", msg.Code)
+		if status, ok := msg.Attribute("status-message"); ok {
+			detail += " " + status.AsString()
+		}
+		return nil, fmt.Errorf("la impresora IPP rechazó la operación: %s", detail)
+	}
+	return msg, nil
+}
+
+// operationAttributes son los atributos obligatorios de toda solicitud
+func (c *IPPClient) operationAttributes(printerURI string) []ippAttribute {
+	return []ippAttribute{
+		ippStrings(ippValueCharset, "attributes-charset", "utf-8"),
+		ippStrings(ippValueLanguage, "attributes-natural-language", "es"),
+		ippStrings(ippValueURI, "printer-uri", printerURI),
+		ippStrings(ippValueName, "requesting-user-name", c.UserName),
+	}
+}
+
+// PrintJob envía un PDF con Print-Job y devuelve el job-id asignado por la impresora
+func (c *IPPClient) PrintJob(printerURI, jobName string, document io.Reader, opts PrintOptions) (int, error) {
+	operation := append(c.operationAttributes(printerURI),
+		ippStrings(ippValueName, "job-name", jobName),
+		ippStrings(ippValueMimeType, "document-format", "application/pdf"),
+	)
+	req := &ippMessage{
+		Code: ippOpPrintJob,
+		Groups: []ippGroup{
+			{Tag: ippTagOperation, Attributes: operation},
+			{Tag: ippTagJob, Attributes: ippJobAttributes(opts)},
+		},
+	}
+	resp, err := c.do(printerURI, req, document)
+	if err != nil {
+		return 0, err
+	}
+	jobID, _ := resp.Attribute("job-id")
+	return jobID.AsInt(), nil
+}
+
+// ippJobAttributes traduce las opciones de impresión a atributos de plantilla de trabajo
+func ippJobAttributes(opts PrintOptions) []ippAttribute {
+	var attrs []ippAttribute
+	if opts.Copies > 1 {
+		attrs = append(attrs, ippInt(ippValueInteger, "copies", opts.Copies))
+	}
+	switch opts.Duplex {
+	case DuplexNone:
+		attrs = append(attrs, ippStrings(ippValueKeyword, "sides", "one-sided"))
+	case DuplexLongEdge:
+		attrs = append(attrs, ippStrings(ippValueKeyword, "sides", "two-sided-long-edge"))
+	case DuplexShortEdge:
+		attrs = append(attrs, ippStrings(ippValueKeyword, "sides", "two-sided-short-edge"))
+	}
+	switch opts.Orientation {
+	case OrientationPortrait:
+		attrs = append(attrs, ippInt(ippValueEnum, "orientation-requested", 3))
+	case OrientationLandscape:
+		attrs = append(attrs, ippInt(ippValueEnum, "orientation-requested", 4))
+	}
+	if opts.Tray != "" {
+		// La bandeja solo existe dentro de media-col, que reemplaza a media
+		source, _ := opts.MediaSource()
+		attrs = append(attrs, ippMediaCol(source, opts.PaperSize)...)
+	} else if media, ok := ippMediaNames[opts.PaperSize]; ok {
+		attrs = append(attrs, ippStrings(ippValueKeyword, "media", media))
+	}
+	switch opts.Color {
+	case ColorColor:
+		attrs = append(attrs, ippStrings(ippValueKeyword, "print-color-mode", "color"))
+	case ColorGrayscale:
+		attrs = append(attrs, ippStrings(ippValueKeyword, "print-color-mode", "monochrome"))
+	}
+	if level, ok := qualityLevels[opts.Quality]; ok {
+		attrs = append(attrs, ippInt(ippValueEnum, "print-quality", level.IPP))
+	}
+	if scaling, ok := ippPrintScaling[opts.Scale]; ok {
+		attrs = append(attrs, ippStrings(ippValueKeyword, "print-scaling", scaling))
+	}
+	if opts.Pages != "" {
+		ranges := ippAttribute{Tag: ippValueRange, Name: "page-ranges"}
+		for _, part := range strings.Split(opts.Pages, ",") {
+			from, to, found := strings.Cut(part, "-")
+			if !found {
+				to = from
+			}
+			lower, _ := strconv.Atoi(from)
+			upper, _ := strconv.Atoi(to)
+			b := make([]byte, 8)
+			binary.BigEndian.PutUint32(b[:4], uint32(lower))
+			binary.BigEndian.PutUint32(b[4:], uint32(upper))
+			ranges.Values = append(ranges.Values, b)
+		}
+		attrs = append(attrs, ranges)
+	}
+	return attrs
+}
+
+// GetPrinterAttributes consulta el estado de la impresora
+func (c *IPPClient) GetPrinterAttributes(printerURI string) (*ippMessage, error) {
+	operation := append(c.operationAttributes(printerURI),
+		ippStrings(ippValueKeyword, "requested-attributes",
+			"printer-state", "printer-state-reasons", "printer-is-accepting-jobs", "queued-job-count", "printer-make-and-model"),
+	)
+	return c.do(printerURI, &ippMessage{
+		Code:   ippOpGetPrinterAttributes,
+		Groups: []ippGroup{{Tag: ippTagOperation, Attributes: operation}},
+	}, nil)
+}
+
+// ippPrinterStatus traduce printer-state y printer-state-reasons al estado informado por la API
+func ippPrinterStatus(name string, attrs *ippMessage) *PrinterStatus {
+	status := &PrinterStatus{Printer: name, Status: "Normal", States: []string{}, Online: true, Source: "ipp", CheckedAt: time.Now()}
+	if state, ok := attrs.Attribute("printer-state"); ok {
+		switch state.AsInt() {
+		case ippPrinterProcessing:
+			status.States = append(status.States, "Printing")
+		case ippPrinterStopped:
+			status.Paused = true
+			status.States = append(status.States, "Stopped")
+		}
+	}
+	if accepting, ok := attrs.Attribute("printer-is-accepting-jobs"); ok && !accepting.AsBool() {
+		status.Error = true
+		status.States = append(status.States, "NotAcceptingJobs")
+	}
+	if jobs, ok := attrs.Attribute("queued-job-count"); ok {
+		status.Jobs = jobs.AsInt()
+	}
+	reasons, _ := attrs.Attribute("printer-state-reasons")
+	for _, reason := range reasons.AsStrings() {
+		// Los motivos llevan un sufijo de severidad (-report, -warning, -error) que no cambia su significado
+		keyword := reason
+		for _, suffix := range []string{"-report", "-warning", "-error"} {
+			keyword = strings.TrimSuffix(keyword, suffix)
+		}
+		switch keyword {
+		case "none":
+			continue
+		case "offline", "shutdown", "timed-out", "connecting-to-device":
+			status.Online = false
+		case "media-empty", "media-needed":
+			status.PaperOut = true
+		case "media-jam":
+			status.PaperJam = true
+		case "door-open", "cover-open":
+			status.DoorOpen = true
+		case "toner-low", "marker-supply-low":
+			status.TonerLow = true
+		case "paused", "moving-to-paused":
+			status.Paused = true
+		case "toner-empty", "marker-supply-empty", "other", "spool-area-full", "output-area-full":
+			status.Error = true
+		}
+		status.States = append(status.States, reason)
+	}
+	if len(status.States) > 0 {
+		status.Status = status.States[0]
+	}
+	status.finish()
+	return status
+}
+
+// IPPPrinters asocia el nombre lógico de una impresora con su URI IPP (ipp://host/ipp/print o una cola
+// CUPS como ipp://servidor:631/printers/Laser)
+type IPPPrinters map[string]string
+
+// URI devuelve la URI de la impresora si es una impresora IPP
+func (p IPPPrinters) URI(printer string) (string, bool) {
+	uri, ok := p[printer]
+	return uri, ok
+}
+
+// IPPPrinterManager agrega las impresoras IPP a las del PrinterManager real
+type IPPPrinterManager struct {
+	Next     PrinterManager
+	Printers IPPPrinters
+	Client   *IPPClient
+}
+
+// ListPrinters lista las impresoras del sistema seguidas de las impresoras IPP
+func (m IPPPrinterManager) ListPrinters() ([]string, error) {
+	printers, err := m.Next.ListPrinters()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(m.Printers))
+	for name := range m.Printers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		printers = append(printers, fmt.Sprintf("Name=%s;DriverName=IPP;PortName=%s;PrinterStatus=Network;Location=Red", name, m.Printers[name]))
+	}
+	return printers, nil
+}
+
+// PrinterExists considera existentes las impresoras IPP configuradas; la conexión se prueba al imprimir
+func (m IPPPrinterManager) PrinterExists(name string) (bool, error) {
+	if _, ok := m.Printers.URI(name); ok {
+		return true, nil
+	}
+	return m.Next.PrinterExists(name)
+}
+
+// DefaultPrinter delega en el administrador real
+func (m IPPPrinterManager) DefaultPrinter() (string, error) {
+	return m.Next.DefaultPrinter()
+}
+
+// GetPrinterStatus consulta las impresoras IPP con Get-Printer-Attributes; si no responden se
+// informan fuera de línea
+func (m IPPPrinterManager) GetPrinterStatus(name string) (*PrinterStatus, error) {
+	uri, ok := m.Printers.URI(name)
+	if !ok {
+		return m.Next.GetPrinterStatus(name)
+	}
+	attrs, err := m.Client.GetPrinterAttributes(uri)
+	if err != nil {
+		status := &PrinterStatus{Printer: name, Status: "Offline", States: []string{"Offline"}, Source: "ipp", CheckedAt: time.Now()}
+		status.finish()
+		return status, nil
+	}
+	return ippPrinterStatus(name, attrs), nil
+}
+
+// IPPDocumentPrinter envía los PDF de las impresoras IPP con Print-Job, sin PDFtoPrinter ni el spooler,
+// y delega el resto en el DocumentPrinter real
+type IPPDocumentPrinter struct {
+	Next     DocumentPrinter
+	Printers IPPPrinters
+	Client   *IPPClient
+	Logger   *Logger
+}
+
+// PrintFile imprime el archivo en la impresora indicada
+func (p IPPDocumentPrinter) PrintFile(filePath, printer string, opts PrintOptions) error {
+	uri, ok := p.Printers.URI(printer)
+	if !ok {
+		return p.Next.PrintFile(filePath, printer, opts)
+	}
+	if opts.Engine != "" {
+		p.Logger.Warnf("La impresora IPP '%s' recibe el PDF directamente; se ignora el motor '%s'", printer, opts.Engine)
+	}
+	if opts.ScalePercent() > 0 {
+		p.Logger.Warnf("IPP no admite una escala en porcentaje; '%s' imprime el documento con su escala predeterminada", printer)
+	}
+	f, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("error al leer el documento: %w", err)
+	}
+	defer f.Close()
+
+	jobName := "PrinterMatiasERP"
+	if opts.JobID != "" {
+		jobName += " " + opts.JobID
+	}
+	jobID, err := p.Client.PrintJob(uri, jobName, f, opts)
+	if err != nil {
+		return err
+	}
+	p.Logger.Infof("Documento enviado por IPP a '%s' (%s), trabajo %d", printer, uri, jobID)
+	return nil
+}
+
+// IPPStatusChecker consulta por IPP el estado de las impresoras IPP antes de imprimir y delega el resto
+type IPPStatusChecker struct {
+	Next     StatusChecker
+	Printers IPPPrinters
+	Client   *IPPClient
+}
+
+// CheckStatus consulta el estado de la impresora
+func (c IPPStatusChecker) CheckStatus(printer string) (*PrinterReadiness, error) {
+	uri, ok := c.Printers.URI(printer)
+	if !ok {
+		return c.Next.CheckStatus(printer)
+	}
+	attrs, err := c.Client.GetPrinterAttributes(uri)
+	if err != nil {
+		return nil, err
+	}
+	status := ippPrinterStatus(printer, attrs)
+	return &PrinterReadiness{
+		Online:    status.Online,
+		CoverOpen: status.DoorOpen,
+		PaperOut:  status.PaperOut,
+		Error:     status.Error || status.PaperJam,
+		Source:    "ipp",
+	}, nil
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// ============================
+// Trabajos de Impresión
+// ============================
+
+// Estados posibles de un trabajo
+const (
+	JobStatusRunning   = "running"
+	JobStatusCompleted = "completed"
+	JobStatusFailed    = "failed"
+	JobStatusHeld      = "held"
+	JobStatusCanceled  = "canceled"
+	JobStatusScheduled = "scheduled"
+	// JobStatusSpooled indica que el documento ya está en la cola del sistema y, con SPOOLER_TRACKING,
+	// se espera a que el spooler lo imprima
+	JobStatusSpooled = "spooled"
+)
+
+// Tipos de trabajo
+const (
+	JobKindPrint   = "print"
+	JobKindDrawer  = "drawer"
+	JobKindLabel   = "label"
+	JobKindReceipt = "receipt"
+	JobKindCommand = "command"
+	JobKindImage   = "image"
+	JobKindText    = "text"
+)
+
+// PrintJob representa una solicitud de impresión (o apertura de cajón) y su resultado
+type PrintJob struct {
+	ID      string        `json:"job_id"`
+	Kind    string        `json:"kind"`
+	Printer string        `json:"printer"`
+	Group   string        `json:"group,omitempty"`
+	Source  string        `json:"source,omitempty"`
+	SHA256  string        `json:"document_sha256,omitempty"`
+	Options *PrintOptions `json:"options,omitempty"`
+	Status  string        `json:"status"`
+	// ScheduledAt es la hora pedida con schedule_at; Schedule, la impresión periódica que lo creó
+	ScheduledAt *time.Time `json:"scheduled_at,omitempty"`
+	Schedule    string     `json:"schedule,omitempty"`
+	Error       string     `json:"error,omitempty"`
+	ErrorCode   string     `json:"error_code,omitempty"`
+	Warnings    []string   `json:"warnings,omitempty"`
+	// ToolOutput es el final de la salida de las herramientas externas (PDFtoPrinter, script de cajón, ...)
+	ToolOutput string `json:"tool_output,omitempty"`
+	// Spooler es el estado de los documentos en la cola del sistema (SPOOLER_TRACKING)
+	Spooler *SpoolerState `json:"spooler,omitempty"`
+	// Pages son las páginas impresas por el documento, contando el rango y las copias
+	Pages      int       `json:"pages,omitempty"`
+	Attempts   int       `json:"attempts,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at,omitempty"`
+	DurationMs int64     `json:"duration_ms"`
+	RequestID  string    `json:"request_id,omitempty"`
+	ClientCN   string    `json:"client_cn,omitempty"`
+	WebhookURL string    `json:"-"`
+}
+
+// NewPrintJob crea un trabajo con un identificador único
+func NewPrintJob(kind, printer, source string) *PrintJob {
+	return &PrintJob{
+		ID:      newJobID(),
+		Kind:    kind,
+		Printer: printer,
+		Source:  source,
+		Status:  JobStatusRunning,
+	}
+}
+
+// newJobID genera un identificador aleatorio de 16 caracteres hexadecimales
+func newJobID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return hex.EncodeToString([]byte(time.Now().Format("150405.000")))
+	}
+	return hex.EncodeToString(b)
+}
+
+// documentSHA256 calcula el hash del documento para identificarlo en el historial
+func documentSHA256(r io.Reader) string {
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// runRecovered ejecuta fn convirtiendo un panic en error; la falla queda registrada en un reporte
+// sin detener el servidor.
+func runRecovered(fn func() error) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			if crashReporter != nil {
+				crashReporter.Write(fmt.Sprintf("panic en trabajo: %v", v), false)
+			}
+			err = fmt.Errorf("error interno: %v", v)
+		}
+	}()
+	return fn()
+}
+
+// JobRunner ejecuta los trabajos midiendo su duración, guardándolos en el historial y notificando su resultado
+type JobRunner struct {
+	History  JobHistory
+	Metrics  *Metrics
+	Webhooks *WebhookNotifier
+	Holds    *PaperHold
+	// Scheduler guarda los trabajos con schedule_at hasta su hora
+	Scheduler *Scheduler
+	Logger    *Logger
+	// Events recibe los cambios de estado de los trabajos para los clientes de /ws
+	Events *EventBus
+	// Retry es la política de reintentos predeterminada de los documentos
+	Retry RetryPolicy
+	// Spooler sigue los documentos en la cola del sistema hasta que se imprimen
+	Spooler *SpoolerTracker
+	// Alerts avisa al canal de soporte de los trabajos que fallaron después de los reintentos
+	Alerts *Alerter
+	// Usage acumula los trabajos y páginas por impresora y por día para los reportes de uso
+	Usage *UsageStore
+}
+
+// Run ejecuta fn como parte del trabajo y registra el resultado
+func (j *JobRunner) Run(job *PrintJob, fn func() error) error {
+	return j.run(job, fn, false)
+}
+
+// RunHoldable es como Run, pero si la impresora se queda sin papel el trabajo queda retenido y se
+// reanuda al reponerlo; en ese caso devuelve ErrJobHeld. fn debe poder ejecutarse más de una vez.
+func (j *JobRunner) RunHoldable(job *PrintJob, fn func() error) error {
+	return j.run(job, fn, true)
+}
+
+func (j *JobRunner) run(job *PrintJob, fn func() error, holdable bool) error {
+	job.StartedAt = time.Now()
+	startJobSpan(job)
+	j.publish(EventJobQueued, job)
+	holds := j.Holds
+	if !holdable {
+		holds = nil
+	}
+	// Con la cola retenida, los trabajos nuevos esperan detrás de los anteriores
+	if holds != nil && holds.IsHeld(job.Printer) {
+		return j.hold(job, fn)
+	}
+
+	trackJob(job)
+	j.publish(EventJobStarted, job)
+	err := j.runWithRetries(job, fn)
+	untrackJob(job)
+	if err != nil && holds != nil && !isJobCanceled(job.ID) && holds.ShouldHold(job.Printer, err) {
+		return j.hold(job, fn)
+	}
+	j.finish(job, err)
+	return err
+}
+
+// hold registra el trabajo como retenido (evento job.held) y lo agrega a la cola de la impresora
+func (j *JobRunner) hold(job *PrintJob, fn func() error) error {
+	job.Status = JobStatusHeld
+	j.jobLogger(job).Warnf("Trabajo %s retenido: la impresora '%s' no tiene papel", job.ID, job.Printer)
+	j.record(job)
+	j.Holds.Hold(job, fn)
+	return ErrJobHeld
+}
+
+// finish cierra el trabajo con el resultado de fn
+func (j *JobRunner) finish(job *PrintJob, err error) {
+	job.FinishedAt = time.Now()
+	job.DurationMs = job.FinishedAt.Sub(job.StartedAt).Milliseconds()
+	job.ToolOutput = takeToolOutput(job.ID)
+	job.Pages = takeJobPages(job.ID)
+	documents := takeSpooledDocuments(job.ID)
+
+	logger := j.jobLogger(job)
+	canceled := errors.Is(err, ErrJobCanceled) || (err != nil && isJobCanceled(job.ID))
+	clearJobCanceled(job.ID)
+	if canceled {
+		job.Status = JobStatusCanceled
+		job.Error = ErrJobCanceled.Error()
+		job.ErrorCode = ErrCodeJobCanceled
+		logger.Warnf("Trabajo %s (%s) cancelado en '%s' tras %dms", job.ID, job.Kind, job.Printer, job.DurationMs)
+	} else if err != nil {
+		job.Status = JobStatusFailed
+		job.Error = err.Error()
+		job.ErrorCode = jobErrorCode(job, err)
+		logger.Errorf("Trabajo %s (%s) falló en '%s' tras %dms: %v", job.ID, job.Kind, job.Printer, job.DurationMs, err)
+	} else if spooled := j.Spooler.Find(documents); len(spooled) > 0 {
+		job.Status = JobStatusSpooled
+		job.Spooler = &SpoolerState{Documents: spooled}
+		logger.Infof("Trabajo %s (%s) enviado a la cola de '%s' en %dms; se espera a que el spooler lo imprima", job.ID, job.Kind, job.Printer, job.DurationMs)
+	} else {
+		job.Status = JobStatusCompleted
+		logger.Infof("Trabajo %s (%s) completado en '%s' en %dms", job.ID, job.Kind, job.Printer, job.DurationMs)
+	}
+
+	if j.Metrics != nil {
+		j.Metrics.ObserveJob(job)
+	}
+	endJobSpan(job)
+	j.Usage.Record(job)
+	j.record(job)
+	if job.Status == JobStatusSpooled {
+		j.Spooler.Track(job)
+	}
+	if job.Kind == JobKindDrawer && job.Status == JobStatusCompleted && j.Events != nil {
+		j.Events.Publish(EventDrawerOpened, map[string]interface{}{"printer": job.Printer, "job_id": job.ID})
+	}
+}
+
+// jobLogger devuelve el logger del trabajo, con su solicitud y el certificado del cliente (mTLS)
+func (j *JobRunner) jobLogger(job *PrintJob) *Logger {
+	return j.Logger.WithRequestID(job.RequestID).WithClient(job.ClientCN)
+}
+
+// record guarda el estado actual del trabajo en el historial y lo notifica al webhook y a los
+// clientes de eventos
+func (j *JobRunner) record(job *PrintJob) {
+	if j.History != nil {
+		if herr := j.History.Record(job); herr != nil {
+			j.Logger.Errorf("Error al guardar el trabajo %s en el historial: %v", job.ID, herr)
+		}
+	}
+	if j.Webhooks != nil {
+		j.Webhooks.Notify(job)
+	}
+	j.publish("job."+job.Status, job)
+	if job.Status == JobStatusFailed {
+		j.Alerts.JobFailed(job)
+	}
+}
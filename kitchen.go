@@ -0,0 +1,491 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ============================
+// Comandas de Cocina por Categoría
+// ============================
+
+// kitchenCatchAll es la categoría de la estación que recibe los productos que ninguna otra atiende
+const kitchenCatchAll = "*"
+
+// KitchenStation son las categorías de productos que se preparan en una impresora (cocina, barra,
+// parrilla). La impresora puede ser un alias o un grupo.
+type KitchenStation struct {
+	Title      string   `json:"title,omitempty"`
+	Categories []string `json:"categories"`
+	Copies     int      `json:"copies,omitempty"`
+}
+
+// Validate verifica la estación y normaliza las categorías
+func (s *KitchenStation) Validate() error {
+	seen := make(map[string]bool, len(s.Categories))
+	categories := make([]string, 0, len(s.Categories))
+	for _, category := range s.Categories {
+		category = strings.ToLower(strings.TrimSpace(category))
+		if category == "" {
+			return errors.New("categoría vacía")
+		}
+		if !seen[category] {
+			seen[category] = true
+			categories = append(categories, category)
+		}
+	}
+	s.Categories = categories
+	if s.Copies < 0 || s.Copies > maxCopies {
+		return fmt.Errorf("copies inválido: %d (máximo %d)", s.Copies, maxCopies)
+	}
+	return nil
+}
+
+// KitchenStationEntry es una estación tal como la devuelve la API, con su origen
+type KitchenStationEntry struct {
+	Printer string         `json:"printer"`
+	Source  string         `json:"source"`
+	Station KitchenStation `json:"station"`
+}
+
+// KitchenConfig son las estaciones de la configuración y el archivo de las editadas por la API
+type KitchenConfig struct {
+	Path string
+	// Routes asigna a cada impresora sus categorías, por ejemplo "Barra=bebidas|cafeteria,Cocina=*"
+	Routes map[string]string
+}
+
+// LoadKitchenConfig carga la configuración de las comandas desde variables de entorno
+func LoadKitchenConfig() KitchenConfig {
+	return KitchenConfig{
+		Path:   getEnv("KITCHEN_ROUTES_PATH", "./kitchen_routes.json"),
+		Routes: getEnvAsMap("KITCHEN_ROUTES", ""),
+	}
+}
+
+// KitchenRouteStore reúne las estaciones de la configuración y las guardadas por la API, que las
+// reemplazan por impresora y se conservan en un archivo JSON. Así el ERP envía el pedido con las
+// categorías de sus productos sin conocer qué impresora tiene cada sector del local.
+type KitchenRouteStore struct {
+	mu     sync.RWMutex
+	path   string
+	config map[string]KitchenStation
+	stored map[string]KitchenStation
+}
+
+// NewKitchenRouteStore construye el registro y carga las estaciones guardadas por la API
+func NewKitchenRouteStore(cfg KitchenConfig) (*KitchenRouteStore, error) {
+	s := &KitchenRouteStore{path: cfg.Path, config: map[string]KitchenStation{}, stored: map[string]KitchenStation{}}
+	for printer, value := range cfg.Routes {
+		station := KitchenStation{Categories: strings.Split(value, "|")}
+		if err := station.Validate(); err != nil {
+			return nil, fmt.Errorf("KITCHEN_ROUTES inválido para '%s': %w", printer, err)
+		}
+		s.config[printer] = station
+	}
+	data, err := os.ReadFile(cfg.Path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("error al leer las estaciones de cocina: %w", err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &s.stored); err != nil {
+			return nil, fmt.Errorf("archivo de estaciones de cocina corrupto '%s': %w", cfg.Path, err)
+		}
+		for printer, station := range s.stored {
+			if err := station.Validate(); err != nil {
+				return nil, fmt.Errorf("estación de cocina '%s' inválida en '%s': %w", printer, cfg.Path, err)
+			}
+			s.stored[printer] = station
+		}
+	}
+	return s, nil
+}
+
+// Entry devuelve la estación de la impresora con su origen
+func (s *KitchenRouteStore) Entry(printer string) (KitchenStationEntry, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if station, ok := s.stored[printer]; ok {
+		return KitchenStationEntry{Printer: printer, Source: ProfileSourceAPI, Station: station}, true
+	}
+	if station, ok := s.config[printer]; ok {
+		return KitchenStationEntry{Printer: printer, Source: ProfileSourceConfig, Station: station}, true
+	}
+	return KitchenStationEntry{Printer: printer}, false
+}
+
+// List devuelve las estaciones ordenadas por impresora
+func (s *KitchenRouteStore) List() []KitchenStationEntry {
+	s.mu.RLock()
+	names := make([]string, 0, len(s.config)+len(s.stored))
+	for name := range s.config {
+		names = append(names, name)
+	}
+	for name := range s.stored {
+		if _, ok := s.config[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	s.mu.RUnlock()
+	sort.Strings(names)
+
+	entries := make([]KitchenStationEntry, 0, len(names))
+	for _, name := range names {
+		entry, _ := s.Entry(name)
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// Set guarda la estación de la impresora, reemplazando a la de la configuración. Una estación sin
+// categorías deja de recibir comandas.
+func (s *KitchenRouteStore) Set(printer string, station KitchenStation) error {
+	if strings.TrimSpace(printer) == "" {
+		return errors.New("falta el nombre de la impresora")
+	}
+	if err := station.Validate(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	previous, existed := s.stored[printer]
+	s.stored[printer] = station
+	if err := s.save(); err != nil {
+		if existed {
+			s.stored[printer] = previous
+		} else {
+			delete(s.stored, printer)
+		}
+		return err
+	}
+	return nil
+}
+
+// Delete elimina la estación guardada por la API; la impresora vuelve a la de la configuración
+func (s *KitchenRouteStore) Delete(printer string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	previous, ok := s.stored[printer]
+	if !ok {
+		return false, nil
+	}
+	delete(s.stored, printer)
+	if err := s.save(); err != nil {
+		s.stored[printer] = previous
+		return false, err
+	}
+	return true, nil
+}
+
+// save escribe las estaciones de la API de forma atómica
+func (s *KitchenRouteStore) save() error {
+	data, err := json.MarshalIndent(s.stored, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("error al guardar las estaciones de cocina: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("error al guardar las estaciones de cocina: %w", err)
+	}
+	return nil
+}
+
+// Empty indica que no hay estaciones configuradas
+func (s *KitchenRouteStore) Empty() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.config) == 0 && len(s.stored) == 0
+}
+
+// KitchenItem es un producto del pedido
+type KitchenItem struct {
+	Description string   `json:"description"`
+	Quantity    float64  `json:"quantity,omitempty"`
+	Category    string   `json:"category"`
+	Modifiers   []string `json:"modifiers,omitempty"`
+	Notes       string   `json:"notes,omitempty"`
+}
+
+// KitchenOrder es el cuerpo de POST /print-order
+type KitchenOrder struct {
+	Order  string        `json:"order,omitempty"`
+	Table  string        `json:"table,omitempty"`
+	Waiter string        `json:"waiter,omitempty"`
+	Note   string        `json:"note,omitempty"`
+	Items  []KitchenItem `json:"items"`
+}
+
+// Validate verifica el pedido antes de repartirlo
+func (o KitchenOrder) Validate() error {
+	if len(o.Items) == 0 {
+		return errors.New("el pedido no tiene productos")
+	}
+	for i, item := range o.Items {
+		if strings.TrimSpace(item.Description) == "" {
+			return fmt.Errorf("items[%d]: falta description", i)
+		}
+		if strings.TrimSpace(item.Category) == "" {
+			return fmt.Errorf("items[%d]: falta category", i)
+		}
+		if item.Quantity < 0 {
+			return fmt.Errorf("items[%d]: quantity no puede ser negativa", i)
+		}
+	}
+	return nil
+}
+
+// KitchenTicket son los productos del pedido que se preparan en una estación
+type KitchenTicket struct {
+	Printer string
+	Station KitchenStation
+	Items   []KitchenItem
+}
+
+// Route reparte los productos entre las estaciones: cada producto va a todas las estaciones que
+// tienen su categoría y, si ninguna la tiene, a las estaciones con "*". Los tickets se devuelven
+// ordenados por impresora; si algún producto no tiene estación, no se reparte nada.
+func (s *KitchenRouteStore) Route(order KitchenOrder) ([]KitchenTicket, error) {
+	entries := s.List()
+	tickets := make(map[string]*KitchenTicket, len(entries))
+	var unrouted []string
+	for _, item := range order.Items {
+		category := strings.ToLower(strings.TrimSpace(item.Category))
+		var targets, catchAll []string
+		for _, entry := range entries {
+			for _, c := range entry.Station.Categories {
+				switch c {
+				case category:
+					targets = append(targets, entry.Printer)
+				case kitchenCatchAll:
+					catchAll = append(catchAll, entry.Printer)
+				}
+			}
+		}
+		if len(targets) == 0 {
+			targets = catchAll
+		}
+		if len(targets) == 0 {
+			unrouted = append(unrouted, category)
+			continue
+		}
+		for _, printer := range targets {
+			ticket, ok := tickets[printer]
+			if !ok {
+				entry, _ := s.Entry(printer)
+				ticket = &KitchenTicket{Printer: printer, Station: entry.Station}
+				tickets[printer] = ticket
+			}
+			ticket.Items = append(ticket.Items, item)
+		}
+	}
+	if len(unrouted) > 0 {
+		return nil, fmt.Errorf("no hay estación de cocina para las categorías: %s", strings.Join(unrouted, ", "))
+	}
+	result := make([]KitchenTicket, 0, len(tickets))
+	for _, ticket := range tickets {
+		result = append(result, *ticket)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Printer < result[j].Printer })
+	return result, nil
+}
+
+// Receipt arma la comanda de la estación: sin precios, con las cantidades y los productos en
+// tamaño doble para leerla de lejos
+func (t KitchenTicket) Receipt(order KitchenOrder, now time.Time) Receipt {
+	title := t.Station.Title
+	if title == "" {
+		title = t.Printer
+	}
+	header := []ReceiptBlock{{Text: strings.ToUpper(title), Align: AlignCenter, Bold: true, Size: 2}}
+	if order.Order != "" {
+		header = append(header, ReceiptBlock{Text: "Pedido " + order.Order, Align: AlignCenter, Bold: true, Size: 2})
+	}
+	var info []string
+	if order.Table != "" {
+		info = append(info, "Mesa "+order.Table)
+	}
+	if order.Waiter != "" {
+		info = append(info, order.Waiter)
+	}
+	info = append(info, now.Format("02/01 15:04"))
+	header = append(header, ReceiptBlock{Text: strings.Join(info, " - "), Align: AlignCenter},
+		ReceiptBlock{Type: ReceiptBlockSeparator})
+	for _, item := range t.Items {
+		quantity := item.Quantity
+		if quantity == 0 {
+			quantity = 1
+		}
+		header = append(header, ReceiptBlock{Text: strconv.FormatFloat(quantity, 'f', -1, 64) + " x " + item.Description, Bold: true, Size: 2})
+		for _, modifier := range item.Modifiers {
+			header = append(header, ReceiptBlock{Text: "  + " + modifier})
+		}
+		if item.Notes != "" {
+			header = append(header, ReceiptBlock{Text: "  * " + item.Notes, Bold: true})
+		}
+	}
+	if order.Note != "" {
+		header = append(header, ReceiptBlock{Type: ReceiptBlockSeparator}, ReceiptBlock{Text: "NOTA: " + order.Note, Bold: true})
+	}
+	return Receipt{Header: header, Copies: t.Station.Copies}
+}
+
+// PrintOrderHandler reparte un pedido entre las impresoras de cocina según la categoría de cada
+// producto (POST /print-order). Cada estación es un trabajo propio; si una falla se continúa con
+// las demás y se informa el detalle de cada una.
+func (h Handlers) PrintOrderHandler(w http.ResponseWriter, r *http.Request) {
+	h.Logger = h.Logger.ForRequest(r)
+	h.Logger.Info("Received request: /print-order")
+
+	if r.Method != http.MethodPost {
+		h.Logger.Warnf("Método HTTP no permitido: %s", r.Method)
+		WriteErrorJSON(w, http.StatusMethodNotAllowed, "Método HTTP no permitido", nil)
+		return
+	}
+
+	var order KitchenOrder
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, h.MaxUploadBytes)).Decode(&order); err != nil {
+		h.Logger.Warnf("Error al decodificar JSON: %v", err)
+		WriteErrorJSON(w, http.StatusBadRequest, "Solicitud JSON inválida", err)
+		return
+	}
+	if err := order.Validate(); err != nil {
+		h.Logger.Warnf("Pedido inválido: %v", err)
+		WriteErrorJSON(w, http.StatusBadRequest, "Pedido inválido", err)
+		return
+	}
+	tickets, err := h.Kitchen.Route(order)
+	if err != nil {
+		h.Logger.Warnf("Pedido sin estación: %v", err)
+		WriteErrorJSON(w, http.StatusBadRequest, "Hay productos sin impresora de cocina", err)
+		return
+	}
+
+	now := time.Now()
+	var firstErr error
+	var firstCode string
+	results := make([]map[string]interface{}, 0, len(tickets))
+	for _, ticket := range tickets {
+		receipt := ticket.Receipt(order, now)
+		printer, group := h.groupPrinter(ticket.Printer)
+		job := NewPrintJob(JobKindReceipt, printer, "order:"+order.Order)
+		job.Group = group
+		job.RequestID = RequestID(r)
+		job.ClientCN = ClientCommonName(r)
+
+		err := h.Jobs.Run(job, func() error {
+			if err := h.preflight(job); err != nil {
+				return err
+			}
+			return h.Service.PrintReceipt(printer, receipt)
+		})
+
+		result := map[string]interface{}{"printer": ticket.Printer, "items": len(ticket.Items), "job_id": job.ID, "status": job.Status}
+		if err != nil {
+			h.Logger.Errorf("Error al imprimir la comanda del pedido '%s' en '%s': %v", order.Order, ticket.Printer, err)
+			result["error"] = err.Error()
+			if job.ErrorCode != "" {
+				result["error_code"] = job.ErrorCode
+			}
+			if firstErr == nil {
+				firstErr, firstCode = err, job.ErrorCode
+			}
+		}
+		if len(job.Warnings) > 0 {
+			result["warnings"] = job.Warnings
+		}
+		results = append(results, result)
+	}
+
+	if firstErr != nil {
+		status := jobErrorStatus(firstErr)
+		if firstCode == "" {
+			firstCode = errorCode(status, firstErr)
+		}
+		resp := newErrorResponse(w, status, firstCode, "Error al imprimir una o más comandas del pedido", firstErr)
+		resp.Jobs = results
+		WriteJSON(w, status, resp)
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"message": fmt.Sprintf("Pedido enviado a %d impresoras de cocina.", len(tickets)),
+		"jobs":    results,
+	})
+}
+
+// KitchenRouteHandlers expone las estaciones de cocina en la API administrativa
+type KitchenRouteHandlers struct {
+	Store  *KitchenRouteStore
+	Logger *Logger
+}
+
+// ListHandler lista las estaciones de cocina (GET)
+func (h KitchenRouteHandlers) ListHandler(w http.ResponseWriter, r *http.Request) {
+	h.Logger = h.Logger.ForRequest(r)
+	h.Logger.Info("Received request: /admin/kitchen-routes")
+
+	if r.Method != http.MethodGet {
+		WriteErrorJSON(w, http.StatusMethodNotAllowed, "Método HTTP no permitido", nil)
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]interface{}{"stations": h.Store.List()})
+}
+
+// StationHandler consulta (GET), guarda (PUT) o elimina (DELETE) la estación de una impresora
+func (h KitchenRouteHandlers) StationHandler(w http.ResponseWriter, r *http.Request) {
+	h.Logger = h.Logger.ForRequest(r)
+	h.Logger.Info("Received request: /admin/kitchen-routes/{printer}")
+
+	printer := r.PathValue("printer")
+	switch r.Method {
+	case http.MethodGet:
+		entry, ok := h.Store.Entry(printer)
+		if !ok {
+			WriteErrorJSON(w, http.StatusNotFound, "La impresora no es una estación de cocina", nil)
+			return
+		}
+		WriteJSON(w, http.StatusOK, entry)
+	case http.MethodPut:
+		var station KitchenStation
+		decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&station); err != nil {
+			WriteErrorJSON(w, http.StatusBadRequest, "Solicitud JSON inválida", err)
+			return
+		}
+		if err := h.Store.Set(printer, station); err != nil {
+			h.Logger.Warnf("Estación de cocina '%s' rechazada: %v", printer, err)
+			WriteErrorJSON(w, http.StatusBadRequest, "Estación de cocina inválida", err)
+			return
+		}
+		h.Logger.Infof("Estación de cocina '%s' actualizada", printer)
+		entry, _ := h.Store.Entry(printer)
+		WriteJSON(w, http.StatusOK, entry)
+	case http.MethodDelete:
+		deleted, err := h.Store.Delete(printer)
+		if err != nil {
+			h.Logger.Errorf("Error al eliminar la estación de cocina '%s': %v", printer, err)
+			WriteErrorJSON(w, http.StatusInternalServerError, "Error al eliminar la estación", err)
+			return
+		}
+		if !deleted {
+			WriteErrorJSON(w, http.StatusNotFound, "La impresora no tiene una estación guardada por la API", nil)
+			return
+		}
+		h.Logger.Infof("Estación de cocina '%s' eliminada", printer)
+		entry, _ := h.Store.Entry(printer)
+		WriteJSON(w, http.StatusOK, entry)
+	default:
+		WriteErrorJSON(w, http.StatusMethodNotAllowed, "Método HTTP no permitido", nil)
+	}
+}
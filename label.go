@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ============================
+// Impresión de Etiquetas (ZPL/EPL)
+// ============================
+
+// Lenguajes de etiquetas aceptados
+const (
+	LabelLanguageZPL = "zpl"
+	LabelLanguageEPL = "epl"
+)
+
+// LabelRequest es el cuerpo de POST /print-label. El contenido se indica como texto en zpl o epl,
+// o en base64 en data_base64 junto con language (para etiquetas con gráficos binarios).
+type LabelRequest struct {
+	Printer    string `json:"printer"`
+	ZPL        string `json:"zpl,omitempty"`
+	EPL        string `json:"epl,omitempty"`
+	DataBase64 string `json:"data_base64,omitempty"`
+	Language   string `json:"language,omitempty"`
+	Copies     int    `json:"copies,omitempty"`
+}
+
+// Decode devuelve el lenguaje y el contenido de la etiqueta ya validados
+func (l LabelRequest) Decode() (string, []byte, error) {
+	var language string
+	var data []byte
+	sources := 0
+	if l.ZPL != "" {
+		language, data = LabelLanguageZPL, []byte(l.ZPL)
+		sources++
+	}
+	if l.EPL != "" {
+		language, data = LabelLanguageEPL, []byte(l.EPL)
+		sources++
+	}
+	if l.DataBase64 != "" {
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(l.DataBase64))
+		if err != nil {
+			return "", nil, fmt.Errorf("data_base64 inválido: %w", err)
+		}
+		language, data = strings.ToLower(l.Language), decoded
+		sources++
+	}
+	if sources != 1 {
+		return "", nil, fmt.Errorf("debe indicar exactamente uno de zpl, epl o data_base64")
+	}
+	if l.Copies < 0 || l.Copies > maxCopies {
+		return "", nil, fmt.Errorf("cantidad de copias inválida: %d (máximo %d)", l.Copies, maxCopies)
+	}
+	if err := ValidateLabel(language, data); err != nil {
+		return "", nil, err
+	}
+	return language, data, nil
+}
+
+// ValidateLabel hace una verificación mínima del lenguaje para no enviar a la impresora de
+// etiquetas un documento equivocado (p. ej. un PDF), que imprimiría metros de papel basura
+func ValidateLabel(language string, data []byte) error {
+	switch language {
+	case LabelLanguageZPL:
+		upper := bytes.ToUpper(data)
+		if !bytes.Contains(upper, []byte("^XA")) || !bytes.Contains(upper, []byte("^XZ")) {
+			return fmt.Errorf("la etiqueta ZPL debe comenzar con ^XA y terminar con ^XZ")
+		}
+	case LabelLanguageEPL:
+		hasPrint := false
+		for _, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimSpace(line)
+			if len(line) >= 1 && line[0] == 'P' && strings.Trim(line[1:], "0123456789,") == "" {
+				hasPrint = true
+			}
+		}
+		if !hasPrint {
+			return fmt.Errorf("la etiqueta EPL no contiene el comando de impresión P")
+		}
+	case "":
+		return fmt.Errorf("debe indicar language (zpl o epl) junto con data_base64")
+	default:
+		return fmt.Errorf("lenguaje de etiqueta no soportado: %s (use zpl o epl)", language)
+	}
+	return nil
+}
+
+// PrintLabel envía la etiqueta tal cual a la impresora, repitiéndola por cada copia (las del perfil
+// si no se indican)
+func (d DefaultPrinterService) PrintLabel(printerName string, data []byte, copies int) error {
+	if d.LabelWriter == nil {
+		return fmt.Errorf("la impresión de etiquetas no está disponible")
+	}
+	printerName, err := d.resolvePrinter(printerName)
+	if err != nil {
+		return err
+	}
+	profile := d.profile(printerName)
+	if err := profile.requireType(printerName, PrinterTypeLabel); err != nil {
+		return err
+	}
+	if copies == 0 {
+		copies = profile.Copies
+	}
+	if copies > 1 {
+		data = bytes.Repeat(data, copies)
+	}
+	if err := d.LabelWriter.WriteRaw(printerName, data); err != nil {
+		return fmt.Errorf("error al imprimir la etiqueta: %w", err)
+	}
+	return nil
+}
+
+// PrintLabelHandler imprime etiquetas ZPL/EPL en impresoras Zebra o compatibles (POST /print-label)
+func (h Handlers) PrintLabelHandler(w http.ResponseWriter, r *http.Request) {
+	h.Logger = h.Logger.ForRequest(r)
+	h.Logger.Info("Received request: /print-label")
+
+	if r.Method != http.MethodPost {
+		h.Logger.Warnf("Método HTTP no permitido: %s", r.Method)
+		WriteErrorJSON(w, http.StatusMethodNotAllowed, "Método HTTP no permitido", nil)
+		return
+	}
+
+	var req LabelRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, h.MaxUploadBytes)).Decode(&req); err != nil {
+		h.Logger.Warnf("Error al decodificar JSON: %v", err)
+		WriteErrorJSON(w, http.StatusBadRequest, "Solicitud JSON inválida", err)
+		return
+	}
+	language, data, err := req.Decode()
+	if err != nil {
+		h.Logger.Warnf("Etiqueta inválida: %v", err)
+		WriteErrorJSON(w, http.StatusBadRequest, "Etiqueta inválida", err)
+		return
+	}
+	printer, err := h.defaultPrinter(req.Printer)
+	if err != nil {
+		h.Logger.Warnf("No se especificó la impresora: %v", err)
+		WriteErrorJSON(w, http.StatusBadRequest, "No se especificó la impresora", err)
+		return
+	}
+
+	job := NewPrintJob(JobKindLabel, printer, language)
+	job.RequestID = RequestID(r)
+	job.ClientCN = ClientCommonName(r)
+	job.SHA256 = documentSHA256(bytes.NewReader(data))
+	err = h.Jobs.Run(job, func() error {
+		return h.Service.PrintLabel(printer, data, req.Copies)
+	})
+	if err != nil {
+		h.Logger.Errorf("Error al imprimir la etiqueta: %v", err)
+		WriteJobErrorJSON(w, jobErrorStatus(err), job, "Error al imprimir la etiqueta", err)
+		return
+	}
+
+	WriteJobJSON(w, job, "Etiqueta enviada a la impresora.")
+}
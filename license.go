@@ -0,0 +1,261 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ============================
+// Licencia y Activación
+// ============================
+
+// licensePublicKey es la clave pública Ed25519 (base64) con la que MatiasERP firma las licencias.
+// Se inyecta al compilar: go build -ldflags "-X main.licensePublicKey=<clave>". Si está vacía,
+// la verificación de licencia queda deshabilitada (compilaciones de desarrollo).
+var licensePublicKey = ""
+
+// ErrNoLicense indica que el agente no ha sido activado
+var ErrNoLicense = errors.New("el agente no tiene una licencia activa")
+
+// License son los datos firmados que vinculan el agente a un cliente y punto de venta del ERP
+type License struct {
+	LicenseID  string    `json:"license_id"`
+	CustomerID string    `json:"customer_id"`
+	StoreID    string    `json:"store_id"`
+	StoreName  string    `json:"store_name,omitempty"`
+	Machine    string    `json:"machine,omitempty"`
+	IssuedAt   time.Time `json:"issued_at"`
+	ExpiresAt  time.Time `json:"expires_at,omitempty"`
+}
+
+// LicenseConfig define de dónde se obtiene la licencia
+type LicenseConfig struct {
+	Token string
+	Path  string
+}
+
+// LoadLicenseConfig carga la configuración de licencia desde variables de entorno
+func LoadLicenseConfig() LicenseConfig {
+	return LicenseConfig{
+		Token: getEnv("LICENSE_TOKEN", ""),
+		Path:  getEnv("LICENSE_FILE", "./license.key"),
+	}
+}
+
+// ParseLicense verifica la firma de un token "<payload base64url>.<firma base64url>" y devuelve la licencia
+func ParseLicense(token string, publicKey ed25519.PublicKey) (*License, error) {
+	payloadPart, sigPart, ok := strings.Cut(strings.TrimSpace(token), ".")
+	if !ok {
+		return nil, fmt.Errorf("formato de licencia inválido")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(payloadPart)
+	if err != nil {
+		return nil, fmt.Errorf("formato de licencia inválido: %w", err)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(sigPart)
+	if err != nil {
+		return nil, fmt.Errorf("formato de licencia inválido: %w", err)
+	}
+	if !ed25519.Verify(publicKey, payload, sig) {
+		return nil, fmt.Errorf("la firma de la licencia no es válida")
+	}
+
+	var lic License
+	if err := json.Unmarshal(payload, &lic); err != nil {
+		return nil, fmt.Errorf("contenido de licencia inválido: %w", err)
+	}
+	if lic.LicenseID == "" || lic.CustomerID == "" || lic.StoreID == "" {
+		return nil, fmt.Errorf("la licencia no indica cliente o punto de venta")
+	}
+	return &lic, nil
+}
+
+// Check valida la vigencia de la licencia y, si está vinculada a un equipo, que corresponda al actual
+func (l *License) Check(now time.Time) error {
+	if !l.ExpiresAt.IsZero() && now.After(l.ExpiresAt) {
+		return fmt.Errorf("la licencia %s venció el %s", l.LicenseID, l.ExpiresAt.Format("2006-01-02"))
+	}
+	if l.Machine != "" {
+		host, _ := os.Hostname()
+		if !strings.EqualFold(l.Machine, host) {
+			return fmt.Errorf("la licencia %s pertenece al equipo %s", l.LicenseID, l.Machine)
+		}
+	}
+	return nil
+}
+
+// LicenseManager mantiene la licencia activa del agente
+type LicenseManager struct {
+	mu        sync.RWMutex
+	cfg       LicenseConfig
+	publicKey ed25519.PublicKey
+	license   *License
+	logger    *Logger
+}
+
+// NewLicenseManager crea el administrador de licencias. Un error en la clave embebida es fatal;
+// la ausencia de licencia no lo es, para permitir la activación remota.
+func NewLicenseManager(cfg LicenseConfig, logger *Logger) (*LicenseManager, error) {
+	m := &LicenseManager{cfg: cfg, logger: logger}
+	if licensePublicKey == "" {
+		return m, nil
+	}
+	key, err := base64.StdEncoding.DecodeString(licensePublicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("clave pública de licencia inválida")
+	}
+	m.publicKey = key
+	return m, nil
+}
+
+// Enabled indica si esta compilación exige licencia
+func (m *LicenseManager) Enabled() bool {
+	return m.publicKey != nil
+}
+
+// Load carga la licencia desde LICENSE_TOKEN o, si no está definida, desde LICENSE_FILE
+func (m *LicenseManager) Load() error {
+	if !m.Enabled() {
+		m.logger.Warn("Verificación de licencia deshabilitada en esta compilación")
+		return nil
+	}
+
+	token := m.cfg.Token
+	if token == "" {
+		data, err := os.ReadFile(m.cfg.Path)
+		if os.IsNotExist(err) {
+			return ErrNoLicense
+		}
+		if err != nil {
+			return fmt.Errorf("error al leer la licencia: %w", err)
+		}
+		token = string(data)
+	}
+
+	lic, err := m.verify(token)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.license = lic
+	m.mu.Unlock()
+	m.logger.Infof("Licencia %s activa para el cliente %s, punto de venta %s", lic.LicenseID, lic.CustomerID, lic.StoreID)
+	return nil
+}
+
+func (m *LicenseManager) verify(token string) (*License, error) {
+	lic, err := ParseLicense(token, m.publicKey)
+	if err != nil {
+		return nil, err
+	}
+	if err := lic.Check(time.Now()); err != nil {
+		return nil, err
+	}
+	return lic, nil
+}
+
+// Activate verifica un token y lo guarda en LICENSE_FILE como licencia activa
+func (m *LicenseManager) Activate(token string) (*License, error) {
+	if !m.Enabled() {
+		return nil, fmt.Errorf("esta compilación no requiere licencia")
+	}
+	lic, err := m.verify(token)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(m.cfg.Path, []byte(strings.TrimSpace(token)), 0o600); err != nil {
+		return nil, fmt.Errorf("error al guardar la licencia: %w", err)
+	}
+	m.mu.Lock()
+	m.license = lic
+	m.mu.Unlock()
+	m.logger.Infof("Agente activado con la licencia %s (cliente %s, punto de venta %s)", lic.LicenseID, lic.CustomerID, lic.StoreID)
+	return lic, nil
+}
+
+// Current devuelve la licencia activa, revalidando su vigencia
+func (m *LicenseManager) Current() (*License, error) {
+	m.mu.RLock()
+	lic := m.license
+	m.mu.RUnlock()
+	if lic == nil {
+		return nil, ErrNoLicense
+	}
+	if err := lic.Check(time.Now()); err != nil {
+		return lic, err
+	}
+	return lic, nil
+}
+
+// Check verifica la licencia de los trabajos que no llegan por la API (p. ej. las carpetas
+// automáticas); nil si esta compilación no exige licencia
+func (m *LicenseManager) Check() error {
+	if m == nil || !m.Enabled() {
+		return nil
+	}
+	if _, err := m.Current(); err != nil {
+		if errors.Is(err, ErrNoLicense) {
+			return err
+		}
+		return fmt.Errorf("%w: %v", ErrNoLicense, err)
+	}
+	return nil
+}
+
+// Require protege los endpoints sensibles: sin licencia válida responden 403
+func (m *LicenseManager) Require(next http.HandlerFunc) http.HandlerFunc {
+	if !m.Enabled() {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, err := m.Current(); err != nil {
+			m.logger.ForRequest(r).Warnf("Solicitud a %s rechazada: %v", r.URL.Path, err)
+			WriteErrorCodeJSON(w, http.StatusForbidden, ErrCodeLicenseInvalid, "Licencia inválida o no activada", err)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// LicenseHandler consulta (GET) o instala (POST {"token": "..."}) la licencia del agente
+func (m *LicenseManager) LicenseHandler(w http.ResponseWriter, r *http.Request) {
+	m.logger.ForRequest(r).Info("Received request: /admin/license")
+
+	switch r.Method {
+	case http.MethodGet:
+		status := map[string]interface{}{"required": m.Enabled()}
+		lic, err := m.Current()
+		status["valid"] = err == nil || !m.Enabled()
+		if lic != nil {
+			status["license"] = lic
+		}
+		if err != nil && m.Enabled() {
+			status["error"] = err.Error()
+		}
+		WriteJSON(w, http.StatusOK, status)
+	case http.MethodPost:
+		var req struct {
+			Token string `json:"token"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 16<<10)).Decode(&req); err != nil {
+			WriteErrorJSON(w, http.StatusBadRequest, "Solicitud JSON inválida", err)
+			return
+		}
+		lic, err := m.Activate(req.Token)
+		if err != nil {
+			WriteErrorJSON(w, http.StatusBadRequest, "No se pudo activar la licencia", err)
+			return
+		}
+		WriteJSON(w, http.StatusOK, map[string]interface{}{"valid": true, "license": lic})
+	default:
+		WriteErrorJSON(w, http.StatusMethodNotAllowed, "Método HTTP no permitido", nil)
+	}
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ============================
+// Logos en la Memoria de la Impresora
+// ============================
+
+// maxLogoSlot es la última posición de logo: la clave de cada logo son los dos dígitos de la posición
+const maxLogoSlot = 99
+
+// maxLogoHeight es el alto máximo, en puntos, de una imagen NV (GS ( L y FS q)
+const maxLogoHeight = 2304
+
+// LogoOptions son las opciones del logo guardado en la memoria no volátil de la impresora
+type LogoOptions struct {
+	Slot      int    `json:"slot,omitempty"`
+	Width     int    `json:"width,omitempty"`
+	Dither    string `json:"dither,omitempty"`
+	Threshold int    `json:"threshold,omitempty"`
+	Legacy    bool   `json:"legacy,omitempty"`
+}
+
+// validateLogoSlot verifica la posición del logo. FS q guarda una sola imagen, siempre la 1.
+func validateLogoSlot(slot int, legacy bool) error {
+	if slot < 0 || slot > maxLogoSlot {
+		return fmt.Errorf("slot inválido: %d (rango 1-%d)", slot, maxLogoSlot)
+	}
+	if legacy && slot > 1 {
+		return fmt.Errorf("con legacy (FS q) la impresora guarda un único logo: use slot 1")
+	}
+	return nil
+}
+
+// Normalize valida las opciones y completa los valores predeterminados
+func (o *LogoOptions) Normalize() error {
+	if err := validateLogoSlot(o.Slot, o.Legacy); err != nil {
+		return err
+	}
+	if o.Slot == 0 {
+		o.Slot = 1
+	}
+	// Los logos suelen tener bordes nítidos: por defecto se usa el umbral fijo
+	o.Dither = strings.ToLower(o.Dither)
+	switch o.Dither {
+	case "":
+		o.Dither = DitherThreshold
+	case DitherFloydSteinberg, DitherThreshold:
+	default:
+		return fmt.Errorf("dither inválido: %s (use floyd-steinberg o threshold)", o.Dither)
+	}
+	if o.Threshold == 0 {
+		o.Threshold = 128
+	}
+	if o.Threshold < 1 || o.Threshold > 255 {
+		return fmt.Errorf("threshold inválido: %d (rango 1-255)", o.Threshold)
+	}
+	if o.Width < 0 {
+		return fmt.Errorf("width inválido: %d", o.Width)
+	}
+	return nil
+}
+
+// parseLogoOptionsForm lee las opciones de los campos de un formulario multipart
+func parseLogoOptionsForm(get func(string) string) (LogoOptions, error) {
+	opts := LogoOptions{Dither: get("dither")}
+	for _, f := range []struct {
+		name string
+		dst  *int
+	}{{"slot", &opts.Slot}, {"width", &opts.Width}, {"threshold", &opts.Threshold}} {
+		if v := get(f.name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return opts, fmt.Errorf("%s inválido: %s", f.name, v)
+			}
+			*f.dst = n
+		}
+	}
+	if v := get("legacy"); v != "" {
+		legacy, err := strconv.ParseBool(v)
+		if err != nil {
+			return opts, fmt.Errorf("legacy inválido: %s", v)
+		}
+		opts.Legacy = legacy
+	}
+	return opts, opts.Normalize()
+}
+
+// StoreLogo convierte la imagen a blanco y negro y la guarda en la memoria no volátil de la
+// impresora térmica, para que los recibos la impriman con el bloque "logo" sin enviarla cada vez.
+// Por defecto usa GS ( L en la posición indicada; con legacy usa FS q, que reemplaza todos los
+// logos guardados.
+func (d DefaultPrinterService) StoreLogo(printerName string, data []byte, opts LogoOptions) error {
+	if d.ReceiptWriter == nil {
+		return fmt.Errorf("la carga de logos ESC/POS no está disponible")
+	}
+	printerName, err := d.resolvePrinter(printerName)
+	if err != nil {
+		return err
+	}
+	profile := d.profile(printerName)
+	if err := profile.requireType(printerName, PrinterTypeThermal); err != nil {
+		return err
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("error al decodificar la imagen: %w", err)
+	}
+	dots := NewReceiptLayout(profile.WidthMM, false).Dots
+	width := opts.Width
+	if width == 0 {
+		width = min(img.Bounds().Dx(), dots)
+	}
+	if width > dots {
+		return fmt.Errorf("width %d supera el ancho imprimible del rollo de %dmm (%d puntos)", width, profile.WidthMM, dots)
+	}
+	logo := DitherImage(img, width, opts.Dither, opts.Threshold)
+	if height := logo.Bounds().Dy(); height > maxLogoHeight {
+		return fmt.Errorf("el logo escalado mide %d puntos de alto y la impresora admite hasta %d; reduzca width", height, maxLogoHeight)
+	}
+
+	var b ESCPOSBuffer
+	b.Init()
+	if opts.Legacy {
+		b.StoreNVBitImage(logo)
+	} else {
+		b.StoreNVGraphic(opts.Slot, logo)
+	}
+	d.Logger.Infof("Logo de %dx%d guardado en la posición %d de '%s'", logo.Bounds().Dx(), logo.Bounds().Dy(), opts.Slot, printerName)
+	if err := d.ReceiptWriter.WriteRaw(printerName, b.Bytes()); err != nil {
+		return fmt.Errorf("error al guardar el logo: %w", err)
+	}
+	return nil
+}
+
+// LogoRequest es el cuerpo JSON de POST /printers/{name}/logo
+type LogoRequest struct {
+	URL  string `json:"url"`
+	Data string `json:"data"`
+	LogoOptions
+}
+
+// PrinterLogoHandler guarda el logo del comercio en la memoria de una impresora térmica
+// (POST /printers/{name}/logo). Acepta JSON con url o data (base64), o multipart/form-data con el
+// campo file. La memoria NV admite una cantidad limitada de escrituras, por lo que se carga una
+// vez al instalar la impresora y no en cada recibo.
+func (h Handlers) PrinterLogoHandler(w http.ResponseWriter, r *http.Request) {
+	h.Logger = h.Logger.ForRequest(r)
+	h.Logger.Info("Received request: /printers/{name}/logo")
+
+	if r.Method != http.MethodPost {
+		h.Logger.Warnf("Método HTTP no permitido: %s", r.Method)
+		WriteErrorJSON(w, http.StatusMethodNotAllowed, "Método HTTP no permitido", nil)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, h.MaxUploadBytes)
+	printer := r.PathValue("name")
+	var fileURL, source string
+	var data []byte
+	var opts LogoOptions
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		if err := r.ParseMultipartForm(multipartMemoryLimit); err != nil {
+			h.Logger.Warnf("Error al procesar el formulario multipart: %v", err)
+			WriteErrorJSON(w, http.StatusBadRequest, "Formulario multipart inválido o archivo demasiado grande", err)
+			return
+		}
+		defer r.MultipartForm.RemoveAll()
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			WriteErrorJSON(w, http.StatusBadRequest, "Falta el archivo de imagen (campo file)", err)
+			return
+		}
+		defer file.Close()
+		if data, err = io.ReadAll(file); err != nil {
+			WriteErrorJSON(w, http.StatusBadRequest, "Error al leer la imagen", err)
+			return
+		}
+		source = "upload:" + header.Filename
+		if opts, err = parseLogoOptionsForm(r.FormValue); err != nil {
+			h.Logger.Warnf("Opciones del logo inválidas: %v", err)
+			WriteErrorJSON(w, http.StatusBadRequest, "Opciones del logo inválidas", err)
+			return
+		}
+	} else {
+		var req LogoRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			h.Logger.Warnf("Error al decodificar JSON: %v", err)
+			WriteErrorJSON(w, http.StatusBadRequest, "Solicitud JSON inválida", err)
+			return
+		}
+		if (req.URL == "") == (req.Data == "") {
+			WriteErrorJSON(w, http.StatusBadRequest, "Debe indicar url o data", nil)
+			return
+		}
+		if req.Data != "" {
+			decoded, err := io.ReadAll(base64DocumentReader(req.Data))
+			if err != nil {
+				WriteErrorJSON(w, http.StatusBadRequest, "data no es base64 válido", err)
+				return
+			}
+			data, source = decoded, "base64"
+		}
+		fileURL, opts = req.URL, req.LogoOptions
+		if fileURL != "" {
+			source = fileURL
+		}
+		if err := opts.Normalize(); err != nil {
+			h.Logger.Warnf("Opciones del logo inválidas: %v", err)
+			WriteErrorJSON(w, http.StatusBadRequest, "Opciones del logo inválidas", err)
+			return
+		}
+	}
+	if data != nil {
+		if err := checkImage(data); err != nil {
+			h.Logger.Warnf("Imagen inválida: %v", err)
+			WriteErrorJSON(w, http.StatusBadRequest, "Imagen inválida", err)
+			return
+		}
+	}
+
+	job := NewPrintJob(JobKindCommand, printer, fmt.Sprintf("logo:%d:%s", opts.Slot, source))
+	job.RequestID = RequestID(r)
+	job.ClientCN = ClientCommonName(r)
+	err := h.Jobs.Run(job, func() error {
+		logo := data
+		if fileURL != "" {
+			ctx, cancel := downloadContext(r, 0)
+			defer cancel()
+			path, err := h.Service.FetchDocument(ctx, fileURL, "")
+			if err != nil {
+				return err
+			}
+			defer os.Remove(path)
+			if logo, err = os.ReadFile(path); err != nil {
+				return err
+			}
+			if err := checkImage(logo); err != nil {
+				return err
+			}
+		}
+		return h.Service.StoreLogo(printer, logo, opts)
+	})
+	if err != nil {
+		h.Logger.Errorf("Error al guardar el logo: %v", err)
+		WriteJobErrorJSON(w, jobErrorStatus(err), job, "Error al guardar el logo", err)
+		return
+	}
+
+	h.Logger.Warnf("Logo de '%s' actualizado en la posición %d desde %s", printer, opts.Slot, r.RemoteAddr)
+	WriteJobJSON(w, job, fmt.Sprintf("Logo guardado en la posición %d de la impresora.", opts.Slot))
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ============================
+// Consulta Remota del Log (soporte)
+// ============================
+
+// logLevels ordena los niveles del log; el filtro level incluye el nivel indicado y los más graves
+var logLevels = map[string]int{"info": 0, "warn": 1, "error": 2}
+
+// LogHandlers permite al soporte revisar el log del agente sin conectarse por escritorio remoto:
+// el final filtrado por nivel y la descarga de los archivos rotados
+type LogHandlers struct {
+	LogFile string
+	Logger  *Logger
+}
+
+// LogFileInfo describe un archivo del log (el actual o uno rotado por LOG_MAX_SIZE_MB)
+type LogFileInfo struct {
+	Name     string    `json:"name"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+	Current  bool      `json:"current,omitempty"`
+}
+
+// LogsHandler devuelve las últimas líneas del log como texto (GET /admin/logs?tail=500&level=warn)
+func (h LogHandlers) LogsHandler(w http.ResponseWriter, r *http.Request) {
+	h.Logger = h.Logger.ForRequest(r)
+	h.Logger.Info("Received request: /admin/logs")
+	if r.Method != http.MethodGet {
+		WriteErrorJSON(w, http.StatusMethodNotAllowed, "Método HTTP no permitido", nil)
+		return
+	}
+
+	q := r.URL.Query()
+	lines := defaultLogTailLines
+	if v := q.Get("tail"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			WriteErrorJSON(w, http.StatusBadRequest, "Parámetro tail inválido", err)
+			return
+		}
+		lines = min(n, maxLogTailLines)
+	}
+	var keep func(line string) bool
+	if v := strings.ToLower(q.Get("level")); v != "" {
+		minimum, ok := logLevels[v]
+		if !ok {
+			WriteErrorJSON(w, http.StatusBadRequest, "Parámetro level inválido", fmt.Errorf("use info, warn o error: %s", v))
+			return
+		}
+		keep = func(line string) bool { return logLineLevel(line) >= minimum }
+	}
+
+	tail, err := tailLogFile(h.LogFile, lines, keep)
+	if err != nil {
+		WriteErrorJSON(w, http.StatusInternalServerError, "Error al leer el log", err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(tail)
+}
+
+// FilesHandler lista el log actual y los rotados, del más reciente al más antiguo (GET /admin/logs/files)
+func (h LogHandlers) FilesHandler(w http.ResponseWriter, r *http.Request) {
+	h.Logger = h.Logger.ForRequest(r)
+	h.Logger.Info("Received request: /admin/logs/files")
+	if r.Method != http.MethodGet {
+		WriteErrorJSON(w, http.StatusMethodNotAllowed, "Método HTTP no permitido", nil)
+		return
+	}
+	files, err := h.files()
+	if err != nil {
+		WriteErrorJSON(w, http.StatusInternalServerError, "Error al listar los archivos del log", err)
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]interface{}{"files": files})
+}
+
+// FileHandler descarga un archivo del log por su nombre (GET /admin/logs/files/{name}); los rotados
+// con LOG_COMPRESS se descargan comprimidos (.gz)
+func (h LogHandlers) FileHandler(w http.ResponseWriter, r *http.Request) {
+	h.Logger = h.Logger.ForRequest(r)
+	h.Logger.Info("Received request: /admin/logs/files/{name}")
+	if r.Method != http.MethodGet {
+		WriteErrorJSON(w, http.StatusMethodNotAllowed, "Método HTTP no permitido", nil)
+		return
+	}
+	name := r.PathValue("name")
+	// Solo se sirven los archivos del log, nunca otra ruta del equipo
+	if !h.isLogFile(name) {
+		WriteErrorJSON(w, http.StatusNotFound, "Archivo de log no encontrado", nil)
+		return
+	}
+	f, err := os.Open(filepath.Join(filepath.Dir(h.LogFile), name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			WriteErrorJSON(w, http.StatusNotFound, "Archivo de log no encontrado", nil)
+			return
+		}
+		WriteErrorJSON(w, http.StatusInternalServerError, "Error al leer el log", err)
+		return
+	}
+	defer f.Close()
+
+	contentType := "text/plain; charset=utf-8"
+	if strings.HasSuffix(name, ".gz") {
+		contentType = "application/gzip"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, name))
+	w.Header().Set("Cache-Control", "no-store")
+	// El log actual sigue creciendo: se envía lo escrito hasta ahora
+	if _, err := io.Copy(w, f); err != nil {
+		h.Logger.Warnf("Descarga del log '%s' interrumpida: %v", name, err)
+	}
+}
+
+// files lista los archivos del log en su carpeta
+func (h LogHandlers) files() ([]LogFileInfo, error) {
+	entries, err := os.ReadDir(filepath.Dir(h.LogFile))
+	if err != nil {
+		return nil, err
+	}
+	files := []LogFileInfo{}
+	for _, entry := range entries {
+		if entry.IsDir() || !h.isLogFile(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, LogFileInfo{
+			Name:     entry.Name(),
+			Size:     info.Size(),
+			Modified: info.ModTime(),
+			Current:  entry.Name() == filepath.Base(h.LogFile),
+		})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Modified.After(files[j].Modified) })
+	return files, nil
+}
+
+// isLogFile indica si el nombre es el log actual o uno rotado por lumberjack
+// ("app-2024-05-02T10-30-00.000.log", con ".gz" si está comprimido)
+func (h LogHandlers) isLogFile(name string) bool {
+	base := filepath.Base(h.LogFile)
+	if name == base {
+		return true
+	}
+	if name != filepath.Base(name) {
+		return false
+	}
+	ext := filepath.Ext(base)
+	prefix := strings.TrimSuffix(base, ext) + "-"
+	name = strings.TrimSuffix(name, ".gz")
+	return strings.HasPrefix(name, prefix) && strings.HasSuffix(name, ext) && len(name) > len(prefix)+len(ext)
+}
+
+// logLineLevel devuelve el nivel de la línea según logLevels; las líneas sin nivel (p. ej. la
+// salida de un panic) se consideran info
+func logLineLevel(line string) int {
+	switch {
+	case strings.Contains(line, "[ERROR] "):
+		return logLevels["error"]
+	case strings.Contains(line, "[WARN] "):
+		return logLevels["warn"]
+	}
+	return logLevels["info"]
+}
+
+// tailLogFile lee desde el final del archivo las últimas n líneas que cumplen keep (todas si keep
+// es nil), sin cargar todo el log
+func tailLogFile(path string, n int, keep func(line string) bool) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	const chunk = 32 << 10
+	var kept []string // del final hacia el principio
+	var partial string
+	offset := info.Size()
+	for offset > 0 && len(kept) < n {
+		size := min(int64(chunk), offset)
+		offset -= size
+		buf := make([]byte, size)
+		if _, err := f.ReadAt(buf, offset); err != nil && err != io.EOF {
+			return nil, err
+		}
+		lines := strings.SplitAfter(string(buf)+partial, "\n")
+		// La primera línea del bloque puede estar incompleta hasta leer el bloque anterior
+		first := 1
+		if offset == 0 {
+			first = 0
+		}
+		partial = lines[0]
+		for i := len(lines) - 1; i >= first && len(kept) < n; i-- {
+			if lines[i] != "" && (keep == nil || keep(lines[i])) {
+				kept = append(kept, lines[i])
+			}
+		}
+	}
+
+	var out strings.Builder
+	for i := len(kept) - 1; i >= 0; i-- {
+		out.WriteString(kept[i])
+	}
+	return []byte(out.String()), nil
+}
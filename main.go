@@ -1,18 +1,23 @@
 package main
 
 import (
-	"bytes"
+	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"mime"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
 	"os/exec"
+	"os/signal"
 	"strconv"
 	"strings"
-	"syscall" // Importa syscall para configurar SysProcAttr
+	"syscall"
 	"time"
 
 	"github.com/rs/cors"
@@ -25,52 +30,263 @@ import (
 
 // Config almacena las configuraciones del servidor y herramientas externas
 type Config struct {
-	Port              int
-	PDFPrinterPath    string
-	DrawerCommandPath string
-	TLSCertPath       string
-	TLSKeyPath        string
-	AllowedOrigins    []string
-	LogFile           string
-	LogMaxSize        int
-	LogMaxBackups     int
-	LogMaxAge         int
-	LogCompress       bool
-	HTTPReadTimeout   int
-	HTTPWriteTimeout  int
-	HTTPIdleTimeout   int
-}
-
-// LoadConfig carga la configuración desde variables de entorno o valores por defecto
+	ConfigFile             string
+	Profile                string
+	Profiles               []string
+	Port                   int
+	BindAddress            string
+	PrinterBackend         string
+	MockPrinters           string
+	MockSlowDelayMs        int
+	PDFPrinterPath         string
+	DrawerCommandPath      string
+	TLSCertPath            string
+	TLSKeyPath             string
+	TLSSelfSigned          bool
+	TLSDir                 string
+	TLSSelfSignedHosts     []string
+	TLSGenerated           bool
+	ACME                   ACMEConfig
+	TLSClientCAPath        string
+	IPAllowlist            map[string]string
+	RateLimit              RateLimitConfig
+	AllowedOrigins         []string
+	LogFile                string
+	LogMaxSize             int
+	LogMaxBackups          int
+	LogMaxAge              int
+	LogCompress            bool
+	HTTPReadTimeout        int
+	HTTPWriteTimeout       int
+	HTTPIdleTimeout        int
+	MaxUploadSizeMB        int
+	StoreName              string
+	WebhookURL             string
+	WebhookSecret          string
+	WebhookRetries         int
+	WebhookTimeout         int
+	GraphQLEnabled         bool
+	MetricsEnabled         bool
+	PprofEnabled           bool
+	StatusCheckMode        string
+	StatusCheckPrinters    []string
+	RoutingFile            string
+	SessionTTLHours        int
+	PrinterAliases         map[string]string
+	PrinterGroups          map[string]string
+	DownloadAllowedHosts   []string
+	DownloadBlockPrivate   bool
+	DownloadMaxSizeMB      int
+	DownloadTimeoutSeconds int
+	DocumentCacheDir       string
+	DocumentCacheMB        int
+	DocumentCacheMaxAge    int
+	DefaultPrinter         string
+	ToolHashes             map[string]string
+	ToolVerifyStrict       bool
+	ToolRequireSignature   bool
+	PaperHoldEnabled       bool
+	PaperHoldPollSeconds   int
+	PaperHoldMaxMinutes    int
+	EventsPollSeconds      int
+	AdminToken             string
+	CrashDir               string
+	CrashReportURL         string
+	HistoryPath            string
+	HistoryRetentionDays   int
+	ArtifactsDir           string
+	StampImagesDir         string
+	ReprintStamp           string
+	OfficeConverterPath    string
+	GDIPrinting            bool
+	ArtifactRetentionHours int
+	PrinterAddresses       map[string]string
+	NetworkPrinters        NetworkPrinters
+	LabelTransport         string
+	ReceiptTransport       string
+	NetworkTimeoutSeconds  int
+	PrinterBusyWaitSeconds int
+	MaxConcurrentProcesses int
+	JobTimeoutSeconds      int
+	PrintRetries           int
+	PrintRetryDelayMs      int
+	IPPPrinters            IPPPrinters
+	IPPTimeoutSeconds      int
+	IPPInsecureTLS         bool
+	Drawer                 DrawerConfig
+	PrinterProfiles        PrinterProfileConfig
+	Kitchen                KitchenConfig
+	Health                 HealthConfig
+	SNMP                   SNMPConfig
+	SpoolerTracking        SpoolerTrackingConfig
+	Alerts                 AlertConfig
+	License                LicenseConfig
+	Engines                EngineConfig
+	Outbound               OutboundConfig
+	Relay                  RelayConfig
+	MDNSEnabled            bool
+	MDNSInstance           string
+	Chaos                  ChaosConfig
+	Schedule               ScheduleConfig
+	HotFolders             HotFolderConfig
+	Archive                ArchiveConfig
+	Displays               DisplayConfig
+	Tracing                TracingConfig
+	Update                 UpdateConfig
+}
+
+// LoadConfig carga la configuración desde variables de entorno o valores por defecto,
+// aplicando las variables del perfil activo (PROFILE_<PERFIL>_<VARIABLE>) cuando existan
 func LoadConfig() Config {
+	configFile = loadConfigFile()
+	return loadProfileConfig(selectProfile())
+}
+
+// LoadConfigForProfile carga la configuración con el perfil indicado en lugar del guardado, para
+// probar un perfil nuevo antes de guardarlo
+func LoadConfigForProfile(profile string) Config {
+	configFile = loadConfigFile()
+	return loadProfileConfig(profile)
+}
+
+// loadProfileConfig carga la configuración aplicando las variables del perfil indicado
+func loadProfileConfig(profile string) Config {
+	activeProfile = profile
 	return Config{
-		Port:              getEnvAsInt("PORT", 8080),
-		PDFPrinterPath:    getEnv("PDF_PRINTER_PATH", "./PDFtoPrinter.exe"),
-		DrawerCommandPath: getEnv("DRAWER_COMMAND_PATH", "./drawer_open_command.txt"),
-		TLSCertPath:       getEnv("TLS_CERT_PATH", ""),
-		TLSKeyPath:        getEnv("TLS_KEY_PATH", ""),
-		AllowedOrigins:    getEnvAsSlice("ALLOWED_ORIGINS", "*"),
-		LogFile:           getEnv("LOG_FILE", "app.log"),
-		LogMaxSize:        getEnvAsInt("LOG_MAX_SIZE_MB", 10),
-		LogMaxBackups:     getEnvAsInt("LOG_MAX_BACKUPS", 3),
-		LogMaxAge:         getEnvAsInt("LOG_MAX_AGE_DAYS", 28),
-		LogCompress:       getEnvAsBool("LOG_COMPRESS", true),
-		HTTPReadTimeout:   getEnvAsInt("HTTP_READ_TIMEOUT", 15),
-		HTTPWriteTimeout:  getEnvAsInt("HTTP_WRITE_TIMEOUT", 15),
-		HTTPIdleTimeout:   getEnvAsInt("HTTP_IDLE_TIMEOUT", 60),
+		ConfigFile:             configFile.Path,
+		Profile:                activeProfile,
+		Profiles:               availableProfiles(),
+		Port:                   getEnvAsInt("PORT", 8080),
+		BindAddress:            getEnv("BIND_ADDRESS", ""),
+		PrinterBackend:         strings.ToLower(getEnv("PRINTER_BACKEND", defaultPrinterBackend)),
+		MockPrinters:           getEnv("MOCK_PRINTERS", "Mock-POS-58=ok,Mock-Laser=ok,Mock-Offline=offline,Mock-Fail=fail"),
+		MockSlowDelayMs:        getEnvAsInt("MOCK_SLOW_DELAY_MS", 2000),
+		PDFPrinterPath:         getEnv("PDF_PRINTER_PATH", "./PDFtoPrinter.exe"),
+		DrawerCommandPath:      getEnv("DRAWER_COMMAND_PATH", "./drawer_open_command.txt"),
+		TLSCertPath:            getEnv("TLS_CERT_PATH", ""),
+		TLSKeyPath:             getEnv("TLS_KEY_PATH", ""),
+		TLSSelfSigned:          getEnvAsBool("TLS_SELF_SIGNED", false),
+		TLSDir:                 getEnv("TLS_DIR", "./tls"),
+		TLSSelfSignedHosts:     getEnvAsSlice("TLS_SELF_SIGNED_HOSTS", ""),
+		ACME:                   LoadACMEConfig(),
+		TLSClientCAPath:        getEnv("TLS_CLIENT_CA_FILE", ""),
+		IPAllowlist:            getEnvAsMap("IP_ALLOWLIST", ""),
+		RateLimit:              LoadRateLimitConfig(),
+		AllowedOrigins:         getEnvAsSlice("ALLOWED_ORIGINS", "*"),
+		LogFile:                getEnv("LOG_FILE", "app.log"),
+		LogMaxSize:             getEnvAsInt("LOG_MAX_SIZE_MB", 10),
+		LogMaxBackups:          getEnvAsInt("LOG_MAX_BACKUPS", 3),
+		LogMaxAge:              getEnvAsInt("LOG_MAX_AGE_DAYS", 28),
+		LogCompress:            getEnvAsBool("LOG_COMPRESS", true),
+		HTTPReadTimeout:        getEnvAsInt("HTTP_READ_TIMEOUT", 15),
+		HTTPWriteTimeout:       getEnvAsInt("HTTP_WRITE_TIMEOUT", 15),
+		HTTPIdleTimeout:        getEnvAsInt("HTTP_IDLE_TIMEOUT", 60),
+		MaxUploadSizeMB:        getEnvAsInt("MAX_UPLOAD_SIZE_MB", 50),
+		StoreName:              getEnv("STORE_NAME", defaultStoreName()),
+		WebhookURL:             getEnv("WEBHOOK_URL", ""),
+		WebhookSecret:          getEnv("WEBHOOK_SECRET", ""),
+		WebhookRetries:         getEnvAsInt("WEBHOOK_RETRIES", 3),
+		WebhookTimeout:         getEnvAsInt("WEBHOOK_TIMEOUT", 10),
+		GraphQLEnabled:         getEnvAsBool("GRAPHQL_ENABLED", false),
+		MetricsEnabled:         getEnvAsBool("METRICS_ENABLED", true),
+		PprofEnabled:           getEnvAsBool("PPROF_ENABLED", true),
+		StatusCheckMode:        strings.ToLower(getEnv("STATUS_CHECK", StatusCheckOff)),
+		StatusCheckPrinters:    getEnvAsSlice("STATUS_CHECK_PRINTERS", "*"),
+		RoutingFile:            getEnv("ROUTING_FILE", "./routing.json"),
+		SessionTTLHours:        getEnvAsInt("SESSION_TTL_HOURS", 12),
+		PrinterAliases:         getEnvAsMap("PRINTER_ALIASES", ""),
+		PrinterGroups:          getEnvAsMap("PRINTER_GROUPS", ""),
+		DownloadAllowedHosts:   getEnvAsSlice("DOWNLOAD_ALLOWED_HOSTS", ""),
+		DownloadBlockPrivate:   getEnvAsBool("DOWNLOAD_BLOCK_PRIVATE", true),
+		DownloadMaxSizeMB:      getEnvAsInt("DOWNLOAD_MAX_SIZE_MB", 50),
+		DownloadTimeoutSeconds: getEnvAsInt("DOWNLOAD_TIMEOUT_SECONDS", 30),
+		DocumentCacheDir:       getEnv("DOCUMENT_CACHE_DIR", "./cache"),
+		DocumentCacheMB:        getEnvAsInt("DOCUMENT_CACHE_MB", 100),
+		DocumentCacheMaxAge:    getEnvAsInt("DOCUMENT_CACHE_MAX_AGE_SECONDS", 0),
+		DefaultPrinter:         getEnv("DEFAULT_PRINTER", ""),
+		ToolHashes:             getEnvAsMap("TOOL_HASHES", ""),
+		ToolVerifyStrict:       getEnvAsBool("TOOL_VERIFY_STRICT", false),
+		ToolRequireSignature:   getEnvAsBool("TOOL_REQUIRE_SIGNATURE", false),
+		PaperHoldEnabled:       getEnvAsBool("PAPER_HOLD", false),
+		PaperHoldPollSeconds:   getEnvAsInt("PAPER_HOLD_POLL_SECONDS", 5),
+		PaperHoldMaxMinutes:    getEnvAsInt("PAPER_HOLD_MAX_MINUTES", 30),
+		EventsPollSeconds:      getEnvAsInt("EVENTS_PRINTER_POLL_SECONDS", 10),
+		AdminToken:             getEnv("ADMIN_TOKEN", ""),
+		CrashDir:               getEnv("CRASH_DIR", "./crash"),
+		CrashReportURL:         getEnv("CRASH_REPORT_URL", ""),
+		HistoryPath:            getEnv("HISTORY_DB_PATH", "./history.db"),
+		HistoryRetentionDays:   getEnvAsInt("HISTORY_RETENTION_DAYS", 90),
+		ArtifactsDir:           getEnv("ARTIFACTS_DIR", "./artifacts"),
+		StampImagesDir:         getEnv("STAMP_IMAGES_DIR", "./stamps"),
+		ReprintStamp:           getEnv("REPRINT_STAMP", ""),
+		OfficeConverterPath:    getEnv("OFFICE_CONVERTER_PATH", defaultOfficeConverterPath),
+		GDIPrinting:            getEnvAsBool("GDI_PRINTING", false),
+		ArtifactRetentionHours: getEnvAsInt("ARTIFACT_RETENTION_HOURS", 24),
+		PrinterAddresses:       getEnvAsMap("PRINTER_ADDRESSES", ""),
+		NetworkPrinters:        getEnvAsMap("NETWORK_PRINTERS", ""),
+		LabelTransport:         getEnv("LABEL_TRANSPORT", TransportSpooler),
+		ReceiptTransport:       getEnv("RECEIPT_TRANSPORT", TransportSpooler),
+		NetworkTimeoutSeconds:  getEnvAsInt("NETWORK_PRINTER_TIMEOUT_SECONDS", 15),
+		PrinterBusyWaitSeconds: getEnvAsInt("PRINTER_BUSY_WAIT_SECONDS", 120),
+		MaxConcurrentProcesses: getEnvAsInt("MAX_CONCURRENT_PROCESSES", 4),
+		JobTimeoutSeconds:      getEnvAsInt("JOB_TIMEOUT_SECONDS", 300),
+		PrintRetries:           getEnvAsInt("PRINT_RETRIES", 0),
+		PrintRetryDelayMs:      getEnvAsInt("PRINT_RETRY_DELAY_MS", 1000),
+		IPPPrinters:            getEnvAsMap("IPP_PRINTERS", ""),
+		IPPTimeoutSeconds:      getEnvAsInt("IPP_TIMEOUT_SECONDS", 60),
+		IPPInsecureTLS:         getEnvAsBool("IPP_TLS_INSECURE", false),
+		Drawer:                 LoadDrawerConfig(),
+		PrinterProfiles:        LoadPrinterProfileConfig(),
+		Kitchen:                LoadKitchenConfig(),
+		Health:                 LoadHealthConfig(),
+		SNMP:                   LoadSNMPConfig(),
+		SpoolerTracking:        LoadSpoolerTrackingConfig(),
+		Alerts:                 LoadAlertConfig(),
+		License:                LoadLicenseConfig(),
+		Engines:                LoadEngineConfig(),
+		Outbound:               LoadOutboundConfig(),
+		Relay:                  LoadRelayConfig(),
+		MDNSEnabled:            getEnvAsBool("MDNS_ENABLED", true),
+		MDNSInstance:           getEnv("MDNS_INSTANCE", ""),
+		Chaos:                  LoadChaosConfig(),
+		Schedule:               LoadScheduleConfig(),
+		HotFolders:             LoadHotFolderConfig(),
+		Archive:                LoadArchiveConfig(),
+		Displays:               LoadDisplayConfig(),
+		Tracing:                LoadTracingConfig(),
+		Update:                 LoadUpdateConfig(),
+	}
+}
+
+// ListenAddr devuelve la dirección efectiva de escucha (host:puerto).
+// Un BindAddress vacío escucha en todas las interfaces; "localhost" se normaliza a 127.0.0.1
+// para evitar que el servidor quede expuesto por IPv6 o resoluciones inesperadas.
+func (c Config) ListenAddr() string {
+	host := strings.TrimSpace(c.BindAddress)
+	if strings.EqualFold(host, "localhost") {
+		host = "127.0.0.1"
+	}
+	return net.JoinHostPort(host, strconv.Itoa(c.Port))
+}
+
+// defaultStoreName usa el nombre del equipo como identificador del punto de venta
+func defaultStoreName() string {
+	if hostname, err := os.Hostname(); err == nil {
+		return hostname
 	}
+	return "PrinterMatiasERP"
 }
 
 // Funciones auxiliares para obtener variables de entorno con valores por defecto
 func getEnv(key, defaultVal string) string {
-	if val, ok := os.LookupEnv(key); ok {
+	if val, ok := lookupEnv(key); ok {
 		return val
 	}
 	return defaultVal
 }
 
 func getEnvAsInt(key string, defaultVal int) int {
-	if valStr, ok := os.LookupEnv(key); ok {
+	if valStr, ok := lookupEnv(key); ok {
 		if val, err := strconv.Atoi(valStr); err == nil {
 			return val
 		}
@@ -78,8 +294,17 @@ func getEnvAsInt(key string, defaultVal int) int {
 	return defaultVal
 }
 
+func getEnvAsFloat(key string, defaultVal float64) float64 {
+	if valStr, ok := lookupEnv(key); ok {
+		if val, err := strconv.ParseFloat(valStr, 64); err == nil {
+			return val
+		}
+	}
+	return defaultVal
+}
+
 func getEnvAsBool(key string, defaultVal bool) bool {
-	if valStr, ok := os.LookupEnv(key); ok {
+	if valStr, ok := lookupEnv(key); ok {
 		if val, err := strconv.ParseBool(valStr); err == nil {
 			return val
 		}
@@ -88,12 +313,24 @@ func getEnvAsBool(key string, defaultVal bool) bool {
 }
 
 func getEnvAsSlice(key string, defaultVal string) []string {
-	if val, ok := os.LookupEnv(key); ok {
+	if val, ok := lookupEnv(key); ok {
 		return splitAndTrim(val, ",")
 	}
 	return splitAndTrim(defaultVal, ",")
 }
 
+// getEnvAsMap interpreta una lista "clave=valor,clave2=valor2"
+func getEnvAsMap(key string, defaultVal string) map[string]string {
+	result := make(map[string]string)
+	for _, entry := range getEnvAsSlice(key, defaultVal) {
+		k, v, found := strings.Cut(entry, "=")
+		if found {
+			result[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+	return result
+}
+
 func splitAndTrim(s string, sep string) []string {
 	parts := strings.Split(s, sep)
 	var result []string
@@ -176,134 +413,243 @@ func (l *Logger) Errorf(format string, v ...interface{}) {
 type PrinterManager interface {
 	ListPrinters() ([]string, error)
 	PrinterExists(name string) (bool, error)
+	DefaultPrinter() (string, error)
+	GetPrinterStatus(name string) (*PrinterStatus, error)
 }
 
 // DocumentPrinter interface para imprimir documentos
 type DocumentPrinter interface {
-	PrintFile(filePath, printer string) error
+	PrintFile(filePath, printer string, opts PrintOptions) error
+}
+
+// Downloader interface para descargar documentos remotos a un archivo temporal
+type Downloader interface {
+	Download(ctx context.Context, fileURL string) (string, error)
 }
 
 // DrawerOpener interface para abrir el cajón de la impresora
 type DrawerOpener interface {
-	OpenDrawer(printerName string) error
+	OpenDrawer(printerName string, opts DrawerOptions) error
 }
 
 // PrinterService interface que combina todas las funcionalidades
 type PrinterService interface {
 	GetPrinters() ([]map[string]string, error)
-	PrintPDFFromURL(fileURL, printerName string) error
-	OpenDrawer(printerName string) error
+	PrintPDFFromURL(ctx context.Context, fileURL, printerName string, opts PrintOptions) error
+	PrintPDFFromReader(r io.Reader, printerName string, opts PrintOptions) error
+	PrintPDFFromBase64(data, printerName string, opts PrintOptions) error
+	EstimateDocument(ctx context.Context, fileURL, data string, rollWidthMM float64) (*PrintEstimate, error)
+	ValidateDocument(ctx context.Context, fileURL, data, printerName string) (*PrintValidation, error)
+	OpenDrawer(printerName string, opts DrawerOptions) error
+	DrawerStatus(printerName string) (*DrawerStatus, error)
+	ReprintDocument(jobID, printerName string, opts PrintOptions) error
+	FetchDocument(ctx context.Context, fileURL, data string) (string, error)
+	PrintPDFFromFile(filePath, printerName string, opts PrintOptions) error
+	DefaultPrinter() (name, source string, err error)
+	PrinterStatus(printerName string) (*PrinterStatus, error)
+	PrintLabel(printerName string, data []byte, copies int) error
+	PrintReceipt(printerName string, receipt Receipt) error
+	SendPrinterCommand(printerName string, cmd PrinterCommand) error
+	PrintImage(printerName string, data []byte, opts ImageOptions) error
+	StoreLogo(printerName string, data []byte, opts LogoOptions) error
+	PrintText(printerName, text string, opts TextOptions) error
+	PrinterQueue(printerName string) ([]QueueJob, error)
+	ControlQueue(printerName, action string) (int, error)
+	SetDefaultPrinter(printerName string) (string, error)
+	AddPrinter(spec PrinterSpec) (string, error)
+	ConfigurePrinter(printerName string, changes PrinterChanges) (string, error)
+	RemovePrinter(printerName string) (string, error)
 }
 
 // ============================
 // Implementaciones Concretas
 // ============================
 
-// WindowsPrinterManager es una implementación de PrinterManager para Windows
-type WindowsPrinterManager struct{}
+// ExternalDocumentPrinter es una implementación de DocumentPrinter que utiliza un ejecutable externo
+type ExternalDocumentPrinter struct {
+	PDFPrinterPath string
+}
 
-// ListPrinters lista todas las impresoras instaladas en el sistema Windows incluyendo la ubicación
-func (w WindowsPrinterManager) ListPrinters() ([]string, error) {
-	cmd := exec.Command("powershell", "-Command",
-		"Get-Printer | Select-Object Name, DriverName, PortName, PrinterStatus, Location | ForEach-Object { \"Name=$($_.Name);DriverName=$($_.DriverName);PortName=$($_.PortName);PrinterStatus=$($_.PrinterStatus);Location=$($_.Location)\" }")
+// PrintFile imprime un archivo PDF en la impresora especificada
+func (e ExternalDocumentPrinter) PrintFile(filePath, printer string, opts PrintOptions) error {
+	fmt.Printf("Imprimiendo archivo %s en impresora %s\n", filePath, printer)
+	// Duplex, orientación y papel no existen en la línea de comandos de PDFtoPrinter;
+	// se aplican mediante la configuración del controlador mientras dura la impresión. PDFtoPrinter
+	// imprime a tamaño real, por lo que fit y shrink se aplican escalando el documento.
+	return withFittedPDF(filePath, printer, opts, func(path string) error {
+		return withPrinterDevMode(printer, opts, func() error {
+			args := append([]string{path, printer}, opts.PDFtoPrinterArgs()...)
+			return runExternalTool("PDFPrinter", e.PDFPrinterPath, args, toolRun{JobID: opts.JobID, Printer: printer, Document: path})
+		})
+	})
+}
 
-	// Configura SysProcAttr para ocultar la ventana de PowerShell
-	cmd.SysProcAttr = &syscall.SysProcAttr{
-		HideWindow: true,
-	}
+// runExternalTool ejecuta una herramienta de impresión externa con la ventana oculta. El proceso
+// queda asociado al trabajo de run para poder terminarlo con DELETE /jobs/{id}.
+func runExternalTool(label, path string, args []string, run toolRun) error {
+	return runExternalToolWithin(label, path, args, run, 0)
+}
 
-	var out bytes.Buffer
-	cmd.Stdout = &out
-	cmd.Stderr = &out // Captura también los errores
+// errToolStillRunning indica que la herramienta externa no terminó dentro de la espera máxima
+var errToolStillRunning = errors.New("la herramienta externa no terminó a tiempo")
 
-	// Ejecuta el comando
-	err := cmd.Run()
-	if err != nil {
-		return nil, fmt.Errorf("error ejecutando PowerShell: %w, salida: %s", err, out.String())
-	}
+// runExternalToolWithin es como runExternalTool, pero si maxWait es mayor que cero termina el
+// proceso al vencer la espera y devuelve errToolStillRunning
+func runExternalToolWithin(label, path string, args []string, run toolRun, maxWait time.Duration) (err error) {
+	span := startToolSpan(label, path, run)
+	defer func() { endSpan(span, err) }()
 
-	// Procesa la salida en líneas y elimina caracteres de control
-	lines := strings.Split(out.String(), "\n")
-	var printers []string
-	for _, line := range lines {
-		trimmed := strings.TrimSpace(line)
-		if trimmed != "" {
-			printers = append(printers, trimmed)
+	// Con TOOL_HASHES o TOOL_REQUIRE_SIGNATURE solo se ejecutan los binarios esperados
+	if toolVerifier != nil {
+		resolved, err := toolVerifier.Verify(path)
+		if err != nil {
+			return fmt.Errorf("%s rechazado: %w", label, err)
 		}
+		path = resolved
 	}
 
-	return printers, nil
-}
-
-// PrinterExists verifica si una impresora específica existe
-func (w WindowsPrinterManager) PrinterExists(name string) (bool, error) {
-	printers, err := w.ListPrinters()
-	if err != nil {
-		return false, fmt.Errorf("error al listar impresoras: %w", err)
-	}
-	for _, p := range printers {
-		if strings.Contains(p, "Name="+name+";") {
-			return true, nil
-		}
-	}
-	return false, nil
-}
+	// MAX_CONCURRENT_PROCESSES limita las herramientas en ejecución simultánea en todo el agente
+	release := acquireProcessSlot()
+	defer release()
 
-// ExternalDocumentPrinter es una implementación de DocumentPrinter que utiliza un ejecutable externo
-type ExternalDocumentPrinter struct {
-	PDFPrinterPath string
-}
+	// Crea un comando para ejecutar el ejecutable de impresión, limitado por JOB_TIMEOUT_SECONDS
+	cmd, timedOut, cancel := toolCommand(path, args...)
+	defer cancel()
 
-// PrintFile imprime un archivo PDF en la impresora especificada
-func (e ExternalDocumentPrinter) PrintFile(filePath, printer string) error {
-	fmt.Printf("Imprimiendo archivo %s en impresora %s\n", filePath, printer)
-	// Crea un comando para ejecutar el ejecutable de impresión
-	cmd := exec.Command(e.PDFPrinterPath, filePath, printer)
+	// Ocultar la ventana de la aplicación externa
+	hideWindow(cmd)
 
-	// Configura SysProcAttr para ocultar la ventana de la aplicación externa
-	cmd.SysProcAttr = &syscall.SysProcAttr{
-		HideWindow: true,
+	// La salida se conserva en el trabajo: en el servicio de Windows no hay consola donde verla
+	output := newTailBuffer(maxToolOutputBytes)
+	cmd.Stdout = output
+	cmd.Stderr = output
+	if err := startJobProcess(run, cmd); err != nil {
+		if errors.Is(err, ErrJobCanceled) {
+			return err
+		}
+		return fmt.Errorf("error al ejecutar %s: %v", label, err)
+	}
+	err = waitExternalTool(cmd, maxWait)
+	endJobProcess(run)
+	recordToolOutput(run.JobID, label, output.String())
+	if isJobCanceled(run.JobID) {
+		return ErrJobCanceled
+	}
+	if timedOut() {
+		return toolTimeoutError(label)
+	}
+	if errors.Is(err, errToolStillRunning) {
+		return err
 	}
-
-	/* 	cmd.Stderr = &bytes.Buffer{}
-	   	cmd.Stdout = &bytes.Buffer{}
-	*/
-	cmd.Stderr = os.Stderr // Captura y muestra errores de impresión
-	err := cmd.Run()
 	if err != nil {
-		return fmt.Errorf("error al ejecutar PDFPrinter: %v, salida: %s", err, cmd.Stderr)
+		return fmt.Errorf("error al ejecutar %s: %v, salida: %s", label, err, toolOutputTail(output.String(), toolOutputErrorBytes))
 	}
+	noteSpooledDocument(run)
 	return nil
 }
 
-// WindowsDrawerOpener es una implementación de DrawerOpener para Windows
-type WindowsDrawerOpener struct {
-	DrawerCommandPath string
+// waitExternalTool espera a que termine el proceso; con maxWait mayor que cero lo termina al vencer la espera
+func waitExternalTool(cmd *exec.Cmd, maxWait time.Duration) error {
+	if maxWait <= 0 {
+		return cmd.Wait()
+	}
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(maxWait):
+		killProcessTree(cmd)
+		<-done
+		return errToolStillRunning
+	}
 }
 
-// OpenDrawer abre el cajón de la impresora especificada
-func (w WindowsDrawerOpener) OpenDrawer(printerName string) error {
-	// Ejecutar el script de PowerShell contenido en DrawerCommandPath
-	cmd := exec.Command("powershell", "-NoProfile", "-ExecutionPolicy", "Bypass", "-File", w.DrawerCommandPath, "-Printer", printerName)
+// HTTPDownloader es la implementación por defecto de Downloader usando HTTP(S)
+type HTTPDownloader struct {
+	Policy DownloadPolicy
+	// MaxBytes es el tamaño máximo del documento (0 no limita)
+	MaxBytes int64
+	// Timeout es el tiempo máximo de una descarga cuando la solicitud no indica download_timeout
+	Timeout time.Duration
+	// Cache conserva los documentos descargados (nil deshabilitada)
+	Cache  *DocumentCache
+	client *http.Client
+}
 
-	// Configura SysProcAttr para ocultar la ventana de PowerShell
-	cmd.SysProcAttr = &syscall.SysProcAttr{
-		HideWindow: true,
+// NewHTTPDownloader crea el descargador con la política de servidores permitidos y la conexión
+// saliente (proxy y certificados) configurada
+func NewHTTPDownloader(policy DownloadPolicy, outbound OutboundConfig, maxBytes int64, timeout time.Duration) (HTTPDownloader, error) {
+	client, err := policy.Client(0, outbound)
+	if err != nil {
+		return HTTPDownloader{}, err
 	}
+	return HTTPDownloader{Policy: policy, MaxBytes: maxBytes, Timeout: timeout, client: client}, nil
+}
 
-	output, err := cmd.CombinedOutput()
+// Download descarga el archivo indicado y devuelve la ruta del archivo temporal. La descarga se
+// interrumpe al cancelarse ctx (p. ej. si el ERP cierra la conexión) o al vencer su plazo.
+func (h HTTPDownloader) Download(ctx context.Context, fileURL string) (string, error) {
+	parsed, err := url.Parse(fileURL)
 	if err != nil {
-		return fmt.Errorf("error al ejecutar comando de apertura de cajón: %v, salida: %s", err, string(output))
+		return "", permanent(err)
 	}
-	return nil
+	if err := h.Policy.CheckURL(parsed); err != nil {
+		return "", permanent(err)
+	}
+	if _, ok := ctx.Deadline(); !ok && h.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.Timeout)
+		defer cancel()
+	}
+	var cached cacheValidators
+	if h.Cache != nil {
+		validators, fresh, ok := h.Cache.Lookup(fileURL)
+		if fresh {
+			return h.Cache.Open(fileURL)
+		}
+		if ok {
+			cached = validators
+		}
+	}
+	path, validators, err := downloadFile(ctx, h.client, fileURL, h.MaxBytes, cached)
+	if errors.Is(err, errNotModified) {
+		h.Cache.Refresh(fileURL)
+		return h.Cache.Open(fileURL)
+	}
+	if errors.Is(err, ErrDownloadBlocked) {
+		return "", permanent(err)
+	}
+	if err == nil && h.Cache != nil {
+		h.Cache.Store(fileURL, path, validators)
+	}
+	return path, err
 }
 
 // DefaultPrinterService es la implementación por defecto de PrinterService
 type DefaultPrinterService struct {
-	PrinterManager  PrinterManager
-	DocumentPrinter DocumentPrinter
-	DrawerOpener    DrawerOpener
-	Logger          *Logger
+	PrinterManager     PrinterManager
+	DocumentPrinter    DocumentPrinter
+	DrawerOpener       DrawerOpener
+	LabelWriter        RawWriter
+	ReceiptWriter      RawWriter
+	CommandWriter      RawWriter
+	Profiles           *PrinterProfileStore
+	Queues             QueueManager
+	Admin              PrinterAdmin
+	Downloader         Downloader
+	Artifacts          *ArtifactStore
+	Aliases            PrinterAliases
+	MaxDocumentBytes   int64
+	DefaultPrinterName string
+	Backend            string
+	DrawerSensor       DrawerStatusChecker
+	ReprintStamp       string
+	OfficeConverter    *OfficeConverter
+	GDI                GDIPrinter
+	XPS                XPSPrinter
+	GDIDefault         bool
+	Logger             *Logger
 }
 
 // GetPrinters obtiene la lista de impresoras con detalles
@@ -320,6 +666,9 @@ func (d DefaultPrinterService) GetPrinters() ([]map[string]string, error) {
 			d.Logger.Errorf("Error al parsear detalles de impresora: %v", err)
 			continue
 		}
+		if aliases := d.Aliases.AliasesOf(details["Name"]); len(aliases) > 0 {
+			details["Aliases"] = strings.Join(aliases, ",")
+		}
 		printers = append(printers, details)
 	}
 
@@ -327,77 +676,333 @@ func (d DefaultPrinterService) GetPrinters() ([]map[string]string, error) {
 }
 
 // PrintPDFFromURL descarga un PDF desde una URL y lo envía a la impresora especificada
-func (d DefaultPrinterService) PrintPDFFromURL(fileURL, printerName string) error {
-	exists, err := d.PrinterManager.PrinterExists(printerName)
+func (d DefaultPrinterService) PrintPDFFromURL(ctx context.Context, fileURL, printerName string, opts PrintOptions) error {
+	printerName, err := d.resolvePrinter(printerName)
 	if err != nil {
-		return fmt.Errorf("error al verificar la impresora: %w", err)
+		return err
 	}
-	if !exists {
-		return fmt.Errorf("la impresora '%s' no existe", printerName)
+
+	filePath, err := d.downloadDocument(ctx, fileURL)
+	if err != nil {
+		return err
 	}
+	return d.printTempFile(filePath, printerName, opts)
+}
+
+// ErrDownloadFailed indica que no se pudo descargar el documento (red o respuesta del servidor)
+var ErrDownloadFailed = errors.New("error al descargar el archivo")
 
+// downloadDocument valida la URL y descarga el documento a un archivo temporal
+func (d DefaultPrinterService) downloadDocument(ctx context.Context, fileURL string) (string, error) {
 	parsedURL, err := url.ParseRequestURI(fileURL)
 	if err != nil {
-		return fmt.Errorf("URL inválida: %w", err)
+		return "", permanent(fmt.Errorf("URL inválida: %w", err))
 	}
 
 	if parsedURL.Scheme != "http" && parsedURL.Scheme != "https" {
-		return fmt.Errorf("esquema de URL no soportado: %s", parsedURL.Scheme)
+		return "", permanent(fmt.Errorf("esquema de URL no soportado: %s", parsedURL.Scheme))
+	}
+
+	filePath, err := d.Downloader.Download(ctx, fileURL)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrDownloadFailed, err)
+	}
+	d.Logger.Infof("Archivo descargado: %s", filePath)
+	return filePath, nil
+}
+
+// PrintPDFFromReader guarda el contenido recibido en un archivo temporal y lo envía a la impresora
+func (d DefaultPrinterService) PrintPDFFromReader(r io.Reader, printerName string, opts PrintOptions) error {
+	printerName, err := d.resolvePrinter(printerName)
+	if err != nil {
+		return err
+	}
+
+	filePath, err := saveTempFile(r)
+	if err != nil {
+		return fmt.Errorf("error al guardar el archivo recibido: %w", err)
+	}
+	d.Logger.Infof("Archivo recibido: %s", filePath)
+	return d.printTempFile(filePath, printerName, opts)
+}
+
+// PrintPDFFromBase64 decodifica un PDF en base64 a un archivo temporal y lo envía a la impresora
+func (d DefaultPrinterService) PrintPDFFromBase64(data, printerName string, opts PrintOptions) error {
+	return d.PrintPDFFromReader(base64DocumentReader(data), printerName, opts)
+}
+
+// base64DocumentReader decodifica tanto payloads simples como data URIs ("data:application/pdf;base64,...")
+func base64DocumentReader(data string) io.Reader {
+	if _, payload, found := strings.Cut(data, ";base64,"); found && strings.HasPrefix(data, "data:") {
+		data = payload
+	}
+	return base64.NewDecoder(base64.StdEncoding, strings.NewReader(strings.TrimSpace(data)))
+}
+
+// DefaultPrinter devuelve la impresora a usar cuando la solicitud no indica una: DEFAULT_PRINTER
+// (que puede ser un alias) o, en su defecto, la predeterminada del backend (Windows, CUPS o mock)
+func (d DefaultPrinterService) DefaultPrinter() (string, string, error) {
+	if d.DefaultPrinterName != "" {
+		return d.DefaultPrinterName, "config", nil
+	}
+	name, err := d.PrinterManager.DefaultPrinter()
+	if err != nil {
+		return "", "", fmt.Errorf("no se indicó la impresora y no hay impresora predeterminada: %w", err)
+	}
+	return name, d.Backend, nil
+}
+
+// resolvePrinter traduce el alias al nombre real y verifica que la impresora exista antes de
+// procesar la solicitud
+func (d DefaultPrinterService) resolvePrinter(printerName string) (string, error) {
+	name := d.Aliases.Resolve(printerName)
+	if name != printerName {
+		d.Logger.Infof("Alias '%s' resuelto a la impresora '%s'", printerName, name)
+	}
+	exists, err := d.PrinterManager.PrinterExists(name)
+	if err != nil {
+		return "", fmt.Errorf("error al verificar la impresora: %w", err)
 	}
+	if !exists {
+		if name != printerName {
+			return "", permanent(fmt.Errorf("%w: '%s' (alias '%s')", ErrPrinterNotFound, name, printerName))
+		}
+		return "", permanent(fmt.Errorf("%w: '%s'", ErrPrinterNotFound, printerName))
+	}
+	return name, nil
+}
 
-	filePath, err := downloadFile(fileURL)
+// PrinterStatus consulta el estado de la impresora (o alias) sin exigir que esté en línea
+func (d DefaultPrinterService) PrinterStatus(printerName string) (*PrinterStatus, error) {
+	status, err := d.PrinterManager.GetPrinterStatus(d.Aliases.Resolve(printerName))
 	if err != nil {
-		return fmt.Errorf("error al descargar el archivo: %w", err)
+		return nil, err
 	}
+	status.Printer = printerName
+	return status, nil
+}
+
+// printTempFile imprime un archivo temporal y lo elimina al terminar
+func (d DefaultPrinterService) printTempFile(filePath, printerName string, opts PrintOptions) error {
 	defer func() {
+		// Conservar el documento para reimpresión; si no es posible, se elimina como cualquier temporal
+		if d.Artifacts != nil && opts.JobID != "" {
+			err := d.Artifacts.Keep(opts.JobID, filePath)
+			if err == nil {
+				return
+			}
+			d.Logger.Errorf("Error al conservar el documento del trabajo %s: %v", opts.JobID, err)
+		}
 		if err := os.Remove(filePath); err != nil {
 			d.Logger.Errorf("Error al eliminar archivo temporal: %v", err)
 		}
 	}()
-	d.Logger.Infof("Archivo descargado: %s", filePath)
-	if err := d.DocumentPrinter.PrintFile(filePath, printerName); err != nil {
+	return d.printDocument(filePath, printerName, opts)
+}
+
+// printDocument envía el documento al DocumentPrinter con las copias, el sello de las copias y la
+// bandeja del perfil si la solicitud no los indica. Los documentos de Office se convierten a PDF antes de
+// enviarlos; el trabajo conserva el original. Los documentos XPS se envían tal cual.
+func (d DefaultPrinterService) printDocument(filePath, printerName string, opts PrintOptions) error {
+	// El trabajo pudo cancelarse mientras se descargaba el documento
+	if isJobCanceled(opts.JobID) {
+		return ErrJobCanceled
+	}
+	if DetectXPSDocument(filePath) {
+		return d.printXPS(filePath, printerName, opts)
+	}
+	if ext, ok := DetectOfficeDocument(filePath); ok && d.OfficeConverter != nil {
+		pdf, cleanup, err := d.OfficeConverter.Convert(filePath, ext, toolRun{JobID: opts.JobID, Printer: printerName, Document: filePath})
+		if err != nil {
+			return fmt.Errorf("error al convertir el documento a PDF: %w", err)
+		}
+		defer cleanup()
+		filePath = pdf
+	}
+	if err := CheckPDFFile(filePath, d.MaxDocumentBytes); err != nil {
+		return err
+	}
+	profile := d.profile(printerName)
+	if opts.Copies == 0 {
+		opts.Copies = profile.Copies
+	}
+	if opts.CopyLabel == "" {
+		opts.CopyLabel = profile.CopyLabel
+	}
+	if opts.Tray == "" {
+		opts.Tray = profile.Tray
+	}
+	if err := d.DocumentPrinter.PrintFile(filePath, printerName, opts); err != nil {
 		return fmt.Errorf("error al imprimir el archivo: %w", err)
 	}
+	noteJobPages(opts.JobID, countPrintedPages(filePath, opts))
 	return nil
 }
 
-// OpenDrawer abre el cajón de la impresora especificada
-func (d DefaultPrinterService) OpenDrawer(printerName string) error {
-	exists, err := d.PrinterManager.PrinterExists(printerName)
+// ReprintDocument vuelve a imprimir el documento conservado de un trabajo anterior
+func (d DefaultPrinterService) ReprintDocument(jobID, printerName string, opts PrintOptions) error {
+	if d.Artifacts == nil {
+		return fmt.Errorf("la reimpresión está deshabilitada (ARTIFACT_RETENTION_HOURS=0)")
+	}
+	path, err := d.Artifacts.Open(jobID)
 	if err != nil {
-		return fmt.Errorf("error al verificar la impresora: %w", err)
+		return err
 	}
-	if !exists {
-		return fmt.Errorf("la impresora '%s' no existe", printerName)
+	if printerName, err = d.resolvePrinter(printerName); err != nil {
+		return err
+	}
+
+	// Las reimpresiones de comprobantes fiscales deben identificarse como tales
+	if opts.Stamp == "" {
+		opts.Stamp = d.ReprintStamp
 	}
+	d.Logger.Infof("Reimprimiendo el documento del trabajo %s en '%s'", jobID, printerName)
+	return d.printDocument(path, printerName, opts)
+}
 
-	if err := d.DrawerOpener.OpenDrawer(printerName); err != nil {
+// FetchDocument descarga (URL) o decodifica (base64) el documento en un archivo temporal para
+// imprimirlo varias veces; quien lo llama debe eliminarlo
+func (d DefaultPrinterService) FetchDocument(ctx context.Context, fileURL, data string) (string, error) {
+	if data != "" {
+		filePath, err := saveTempFile(base64DocumentReader(data))
+		if err != nil {
+			return "", fmt.Errorf("error al guardar el archivo recibido: %w", err)
+		}
+		return filePath, nil
+	}
+	return d.downloadDocument(ctx, fileURL)
+}
+
+// PrintPDFFromFile imprime un archivo obtenido con FetchDocument sin eliminarlo, conservando
+// una copia para reimpresión
+func (d DefaultPrinterService) PrintPDFFromFile(filePath, printerName string, opts PrintOptions) error {
+	printerName, err := d.resolvePrinter(printerName)
+	if err != nil {
+		return err
+	}
+	if d.Artifacts != nil && opts.JobID != "" {
+		if dst, err := d.Artifacts.Path(opts.JobID); err == nil {
+			if err := copyFile(filePath, dst); err != nil {
+				d.Logger.Errorf("Error al conservar el documento del trabajo %s: %v", opts.JobID, err)
+			}
+		}
+	}
+	return d.printDocument(filePath, printerName, opts)
+}
+
+// OpenDrawer abre el cajón de la impresora especificada; si la solicitud no indica el pulso se usa
+// la secuencia del perfil de la impresora, cuando la tiene. Con drawer se envía el pulso estándar
+// al conector que el perfil asigna a ese cajón.
+func (d DefaultPrinterService) OpenDrawer(printerName string, opts DrawerOptions) error {
+	printerName, err := d.resolvePrinter(printerName)
+	if err != nil {
+		return err
+	}
+	profile := d.profile(printerName)
+	switch {
+	case opts.Drawer != 0:
+		if opts.Pin, err = profile.DrawerPin(opts.Drawer); err != nil {
+			return err
+		}
+	case opts.Pin == 0 && opts.PulseMs == 0:
+		opts.Command = profile.DrawerKickBytes()
+	}
+
+	if err := d.DrawerOpener.OpenDrawer(printerName, opts); err != nil {
 		return fmt.Errorf("error al abrir el cajón: %w", err)
 	}
 	return nil
 }
 
-// downloadFile descarga un archivo desde una URL y lo guarda temporalmente
-func downloadFile(fileURL string) (string, error) {
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Get(fileURL)
+// downloadFile descarga un archivo desde una URL y lo guarda temporalmente, sin superar maxBytes
+// (0 no limita) aunque el servidor no informe el tamaño o informe uno falso. Con los validadores
+// de una copia en caché la solicitud es condicional y devuelve errNotModified si no cambió.
+func downloadFile(ctx context.Context, client *http.Client, fileURL string, maxBytes int64, cached cacheValidators) (string, cacheValidators, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fileURL, nil)
 	if err != nil {
-		return "", err
+		return "", cacheValidators{}, permanent(err)
+	}
+	// El servidor del documento (normalmente el ERP) recibe la traza de la solicitud
+	injectTraceContext(ctx, req.Header)
+	if cached.ETag != "" {
+		req.Header.Set("If-None-Match", cached.ETag)
+	}
+	if cached.LastModified != "" {
+		req.Header.Set("If-Modified-Since", cached.LastModified)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", cacheValidators{}, err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotModified && !cached.empty() {
+		return "", cached, errNotModified
+	}
+	validators := cacheValidators{ETag: resp.Header.Get("ETag"), LastModified: resp.Header.Get("Last-Modified")}
+	path, err := saveDownload(resp, maxBytes)
+	return path, validators, err
+}
+
+// saveDownload valida la respuesta y guarda el documento en un archivo temporal
+func saveDownload(resp *http.Response, maxBytes int64) (string, error) {
+	// Un servidor que responde HTML (página de error o de inicio de sesión) no entregó el documento
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType == "text/html" && resp.StatusCode == http.StatusOK {
+		return "", fmt.Errorf("%w: el servidor respondió %s en lugar de un PDF", ErrInvalidDocument, mediaType)
+	}
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("el servidor retornó estado no OK: %d %s", resp.StatusCode, resp.Status)
+		err := fmt.Errorf("el servidor retornó estado no OK: %d %s", resp.StatusCode, resp.Status)
+		// Los errores 4xx (salvo 408 y 429) no cambian al reintentar
+		if resp.StatusCode >= 400 && resp.StatusCode < 500 &&
+			resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests {
+			return "", permanent(err)
+		}
+		return "", err
+	}
+
+	if maxBytes <= 0 {
+		return saveTempFile(resp.Body)
+	}
+	tooLarge := fmt.Errorf("%w: el documento supera el máximo de %d bytes", ErrInvalidDocument, maxBytes)
+	if resp.ContentLength > maxBytes {
+		return "", tooLarge
+	}
+	// Se lee un byte más del máximo para detectar los documentos que lo superan
+	path, err := saveTempFile(io.LimitReader(resp.Body, maxBytes+1))
+	if err != nil {
+		return "", err
+	}
+	if info, err := os.Stat(path); err == nil && info.Size() > maxBytes {
+		os.Remove(path)
+		return "", tooLarge
+	}
+	return path, nil
+}
+
+// downloadContext devuelve el contexto de la descarga de una solicitud: se cancela si el cliente
+// cierra la conexión y vence a los timeoutSeconds indicados (0 usa DOWNLOAD_TIMEOUT_SECONDS). Un
+// trabajo retenido que se reanuda después de responder la solicitud descarga sin ese vínculo.
+func downloadContext(r *http.Request, timeoutSeconds int) (context.Context, context.CancelFunc) {
+	ctx := r.Context()
+	if ctx.Err() != nil {
+		ctx = context.Background()
+	}
+	if timeoutSeconds > 0 {
+		return context.WithTimeout(ctx, time.Duration(timeoutSeconds)*time.Second)
 	}
+	return context.WithCancel(ctx)
+}
 
+// saveTempFile copia el contenido a un archivo temporal .pdf y devuelve su ruta
+func saveTempFile(r io.Reader) (string, error) {
 	tempFile, err := os.CreateTemp("", "*.pdf")
 	if err != nil {
 		return "", err
 	}
 	defer tempFile.Close()
 
-	_, err = io.Copy(tempFile, resp.Body)
-	if err != nil {
+	if _, err := io.Copy(tempFile, r); err != nil {
+		os.Remove(tempFile.Name())
 		return "", err
 	}
 
@@ -424,12 +1029,28 @@ func parsePrinterDetails(details string) (map[string]string, error) {
 
 // Handlers agrupa todos los manejadores necesarios
 type Handlers struct {
-	Service PrinterService
-	Logger  *Logger
+	Service        PrinterService
+	Preflight      *StatusPreflight
+	Jobs           *JobRunner
+	History        JobHistory
+	Logger         *Logger
+	Address        string
+	MaxUploadBytes int64
+	Routes         RoutingRules
+	Groups         *PrinterGroups
+	Kitchen        *KitchenRouteStore
+	Health         *HealthMonitor
+	// Licenses se verifica en los trabajos que no pasan por licenses.Require
+	Licenses *LicenseManager
 }
 
+// multipartMemoryLimit es la porción de un formulario multipart que se mantiene en memoria;
+// el resto se guarda en archivos temporales
+const multipartMemoryLimit = 8 << 20
+
 // ListPrintersHandler maneja la solicitud para listar impresoras
 func (h Handlers) ListPrintersHandler(w http.ResponseWriter, r *http.Request) {
+	h.Logger = h.Logger.ForRequest(r)
 	h.Logger.Info("Received request: /list-printers")
 	printers, err := h.Service.GetPrinters()
 	if err != nil {
@@ -438,14 +1059,34 @@ func (h Handlers) ListPrintersHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Con el monitoreo de salud cada impresora informa su último estado conocido, sin consultarla
+	for _, printer := range printers {
+		if state, ok := h.Health.Get(printer["Name"]); ok {
+			printer["Health"] = state.Health
+		}
+	}
 	response := map[string]interface{}{
 		"printers": printers,
 	}
 	WriteJSON(w, http.StatusOK, response)
 }
 
+// PrintRequest es el cuerpo de POST /print (mejor práctica que los parámetros de consulta).
+// Data permite enviar el PDF en base64 como alternativa a URL, sin exponer el documento en la red.
+type PrintRequest struct {
+	URL          string `json:"url"`
+	Data         string `json:"data"`
+	Printer      string `json:"printer"`
+	DocumentType string `json:"document_type"`
+	WebhookURL   string `json:"webhook_url"`
+	// ScheduleAt (RFC3339) deja el trabajo programado para esa hora en lugar de imprimirlo ahora
+	ScheduleAt *time.Time `json:"schedule_at,omitempty"`
+	PrintOptions
+}
+
 // PrintHandler maneja la solicitud para imprimir un PDF desde una URL
 func (h Handlers) PrintHandler(w http.ResponseWriter, r *http.Request) {
+	h.Logger = h.Logger.ForRequest(r)
 	h.Logger.Info("Received request: /print")
 
 	if r.Method != http.MethodPost {
@@ -454,11 +1095,8 @@ func (h Handlers) PrintHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Obtener parámetros desde el cuerpo de la solicitud (mejor práctica que desde query params)
-	type PrintRequest struct {
-		URL     string `json:"url"`
-		Printer string `json:"printer"`
-	}
+	// El contenido en base64 ocupa aproximadamente un 33% más que el PDF original
+	r.Body = http.MaxBytesReader(w, r.Body, h.MaxUploadBytes*4/3+4096)
 
 	var req PrintRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -467,23 +1105,184 @@ func (h Handlers) PrintHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.URL == "" || req.Printer == "" {
-		h.Logger.Warn("URL o impresora no especificados")
+	if req.URL == "" && req.Data == "" {
+		h.Logger.Warn("URL no especificada")
 		WriteErrorJSON(w, http.StatusBadRequest, "URL o impresora no especificados", nil)
 		return
 	}
 
-	if err := h.Service.PrintPDFFromURL(req.URL, req.Printer); err != nil {
+	// Con document_type las impresoras salen de las reglas de enrutamiento; sin impresora se usa la predeterminada
+	if req.DocumentType == "" {
+		printer, err := h.defaultPrinter(req.Printer)
+		if err != nil {
+			h.Logger.Warnf("Impresora no especificada: %v", err)
+			WriteErrorJSON(w, http.StatusBadRequest, "URL o impresora no especificados", err)
+			return
+		}
+		req.Printer = printer
+	}
+
+	if req.URL != "" && req.Data != "" {
+		h.Logger.Warn("Se especificaron url y data simultáneamente")
+		WriteErrorJSON(w, http.StatusBadRequest, "Especifique solo uno de los campos url o data", nil)
+		return
+	}
+
+	if err := h.Jobs.Webhooks.CheckURL(req.WebhookURL); err != nil {
+		h.Logger.Warnf("Webhook inválido: %v", err)
+		WriteErrorJSON(w, http.StatusBadRequest, "URL de webhook inválida", err)
+		return
+	}
+
+	opts := req.PrintOptions
+	if err := opts.Normalize(); err != nil {
+		h.Logger.Warnf("Opciones de impresión inválidas: %v", err)
+		WriteErrorJSON(w, http.StatusBadRequest, "Opciones de impresión inválidas", err)
+		return
+	}
+
+	if req.ScheduleAt != nil {
+		h.schedulePrint(w, r, req, opts)
+		return
+	}
+
+	if req.DocumentType != "" {
+		h.printRouted(w, r, req.DocumentType, req.URL, req.Data, req.WebhookURL, opts)
+		return
+	}
+
+	source := req.URL
+	if req.Data != "" {
+		source = "base64"
+	}
+	printer, group := h.groupPrinter(req.Printer)
+	job := NewPrintJob(JobKindPrint, printer, source)
+	job.Group = group
+	job.RequestID = RequestID(r)
+	job.ClientCN = ClientCommonName(r)
+	job.WebhookURL = req.WebhookURL
+	job.Options = &opts
+	opts.JobID = job.ID
+	if req.Data != "" {
+		job.SHA256 = documentSHA256(base64DocumentReader(req.Data))
+	}
+
+	err := h.Jobs.RunHoldable(job, func() error {
+		if err := h.preflight(job); err != nil {
+			return err
+		}
+		if req.Data != "" {
+			return h.Service.PrintPDFFromBase64(req.Data, printer, opts)
+		}
+		ctx, cancel := downloadContext(r, opts.DownloadTimeout)
+		defer cancel()
+		return h.Service.PrintPDFFromURL(ctx, req.URL, printer, opts)
+	})
+	if errors.Is(err, ErrJobHeld) {
+		WriteJobHeldJSON(w, job)
+		return
+	}
+	if err != nil {
 		h.Logger.Errorf("Error al imprimir: %v", err)
-		WriteErrorJSON(w, http.StatusInternalServerError, "Error al imprimir el archivo", err)
+		WriteJobErrorJSON(w, jobErrorStatus(err), job, "Error al imprimir el archivo", err)
 		return
 	}
 
-	WriteJSON(w, http.StatusOK, map[string]string{"message": "PDF enviado a la impresora exitosamente."})
+	WriteJobJSON(w, job, "PDF enviado a la impresora exitosamente.")
+}
+
+// PrintFileHandler maneja la solicitud para imprimir un PDF enviado como multipart/form-data
+func (h Handlers) PrintFileHandler(w http.ResponseWriter, r *http.Request) {
+	h.Logger = h.Logger.ForRequest(r)
+	h.Logger.Info("Received request: /print-file")
+
+	if r.Method != http.MethodPost {
+		h.Logger.Warnf("Método HTTP no permitido: %s", r.Method)
+		WriteErrorJSON(w, http.StatusMethodNotAllowed, "Método HTTP no permitido", nil)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, h.MaxUploadBytes)
+	if err := r.ParseMultipartForm(multipartMemoryLimit); err != nil {
+		h.Logger.Warnf("Error al procesar el formulario multipart: %v", err)
+		WriteErrorJSON(w, http.StatusBadRequest, "Formulario multipart inválido o archivo demasiado grande", err)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		h.Logger.Warn("Archivo o impresora no especificados")
+		WriteErrorJSON(w, http.StatusBadRequest, "Archivo o impresora no especificados", err)
+		return
+	}
+	defer file.Close()
+
+	printer, err := h.defaultPrinter(r.FormValue("printer"))
+	if err != nil {
+		h.Logger.Warnf("Impresora no especificada: %v", err)
+		WriteErrorJSON(w, http.StatusBadRequest, "Archivo o impresora no especificados", err)
+		return
+	}
+
+	webhookURL := r.FormValue("webhook_url")
+	if err := h.Jobs.Webhooks.CheckURL(webhookURL); err != nil {
+		h.Logger.Warnf("Webhook inválido: %v", err)
+		WriteErrorJSON(w, http.StatusBadRequest, "URL de webhook inválida", err)
+		return
+	}
+
+	opts, err := ParsePrintOptionsForm(r.FormValue)
+	if err != nil {
+		h.Logger.Warnf("Opciones de impresión inválidas: %v", err)
+		WriteErrorJSON(w, http.StatusBadRequest, "Opciones de impresión inválidas", err)
+		return
+	}
+
+	h.Logger.Infof("Archivo recibido para imprimir: %s (%d bytes)", header.Filename, header.Size)
+	printer, group := h.groupPrinter(printer)
+	job := NewPrintJob(JobKindPrint, printer, "upload:"+header.Filename)
+	job.Group = group
+	job.RequestID = RequestID(r)
+	job.ClientCN = ClientCommonName(r)
+	job.WebhookURL = webhookURL
+	job.Options = &opts
+	opts.JobID = job.ID
+	job.SHA256 = documentSHA256(file)
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		WriteErrorJSON(w, http.StatusInternalServerError, "Error al leer el archivo", err)
+		return
+	}
+
+	// El archivo recibido no sobrevive a la solicitud, por lo que estos trabajos no se retienen por falta de papel
+	err = h.Jobs.Run(job, func() error {
+		if err := h.preflight(job); err != nil {
+			return err
+		}
+		// Cada reintento vuelve a leer el archivo desde el principio
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		return h.Service.PrintPDFFromReader(file, printer, opts)
+	})
+	if err != nil {
+		h.Logger.Errorf("Error al imprimir: %v", err)
+		WriteJobErrorJSON(w, jobErrorStatus(err), job, "Error al imprimir el archivo", err)
+		return
+	}
+
+	WriteJobJSON(w, job, "PDF enviado a la impresora exitosamente.")
+}
+
+// OpenDrawerRequest es el cuerpo de POST /open-box
+type OpenDrawerRequest struct {
+	Printer string `json:"printer"`
+	DrawerOptions
 }
 
 // OpenDrawerHandler maneja la solicitud para abrir el cajón de una impresora
 func (h Handlers) OpenDrawerHandler(w http.ResponseWriter, r *http.Request) {
+	h.Logger = h.Logger.ForRequest(r)
 	h.Logger.Info("Received request: /open-box")
 
 	if r.Method != http.MethodPost {
@@ -492,11 +1291,6 @@ func (h Handlers) OpenDrawerHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Obtener parámetros desde el cuerpo de la solicitud
-	type OpenDrawerRequest struct {
-		Printer string `json:"printer"`
-	}
-
 	var req OpenDrawerRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		h.Logger.Warnf("Error al decodificar JSON: %v", err)
@@ -504,31 +1298,140 @@ func (h Handlers) OpenDrawerHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.Printer == "" {
-		h.Logger.Warn("No se especificó la impresora")
-		WriteErrorJSON(w, http.StatusBadRequest, "No se especificó la impresora", nil)
+	printer, err := h.defaultPrinter(req.Printer)
+	if err != nil {
+		h.Logger.Warnf("No se especificó la impresora: %v", err)
+		WriteErrorJSON(w, http.StatusBadRequest, "No se especificó la impresora", err)
+		return
+	}
+	req.Printer = printer
+	if req.Drawer != 0 && req.Pin != 0 {
+		WriteErrorJSON(w, http.StatusBadRequest, "Indique el cajón (drawer) o el conector (pin), no ambos", nil)
 		return
 	}
 
-	if err := h.Service.OpenDrawer(req.Printer); err != nil {
+	job := NewPrintJob(JobKindDrawer, req.Printer, "")
+	job.RequestID = RequestID(r)
+	job.ClientCN = ClientCommonName(r)
+	opts := req.DrawerOptions
+	opts.JobID = job.ID
+	err = h.Jobs.Run(job, func() error {
+		return h.Service.OpenDrawer(req.Printer, opts)
+	})
+	if err != nil {
 		h.Logger.Errorf("Error al abrir el cajón: %v", err)
-		WriteErrorJSON(w, http.StatusInternalServerError, "Error al abrir el cajón", err)
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrDrawerNotConfigured) {
+			status = http.StatusBadRequest
+		}
+		WriteJobErrorJSON(w, status, job, "Error al abrir el cajón", err)
+		return
+	}
+
+	WriteJobJSON(w, job, "Cajón abierto exitosamente.")
+}
+
+// defaultPrinter completa la impresora omitida en la solicitud con la predeterminada
+func (h Handlers) defaultPrinter(printer string) (string, error) {
+	if printer != "" {
+		return printer, nil
+	}
+	name, source, err := h.Service.DefaultPrinter()
+	if err != nil {
+		return "", err
+	}
+	h.Logger.Infof("La solicitud no indica impresora: se usa la predeterminada '%s' (%s)", name, source)
+	return name, nil
+}
+
+// DefaultPrinterHandler devuelve la impresora usada cuando la solicitud no indica una; con PUT
+// cambia la predeterminada del sistema
+func (h Handlers) DefaultPrinterHandler(w http.ResponseWriter, r *http.Request) {
+	h.Logger = h.Logger.ForRequest(r)
+	h.Logger.Info("Received request: /default-printer")
+
+	if r.Method == http.MethodPut {
+		h.SetDefaultPrinterHandler(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		WriteErrorJSON(w, http.StatusMethodNotAllowed, "Método HTTP no permitido", nil)
+		return
+	}
+	name, source, err := h.Service.DefaultPrinter()
+	if err != nil {
+		WriteErrorJSON(w, http.StatusNotFound, "No hay impresora predeterminada", err)
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]interface{}{"printer": name, "source": source})
+}
+
+// PrinterStatusHandler informa el estado actual de una impresora (GET /printers/{name}/status).
+// Si la impresora tiene dirección de red, el estado del spooler se completa con la consulta
+// directa al dispositivo.
+func (h Handlers) PrinterStatusHandler(w http.ResponseWriter, r *http.Request) {
+	h.Logger = h.Logger.ForRequest(r)
+	h.Logger.Info("Received request: /printers/{name}/status")
+
+	if r.Method != http.MethodGet {
+		WriteErrorJSON(w, http.StatusMethodNotAllowed, "Método HTTP no permitido", nil)
+		return
+	}
+	name := r.PathValue("name")
+	status, err := h.Service.PrinterStatus(name)
+	if err != nil {
+		if errors.Is(err, ErrPrinterNotFound) {
+			WriteErrorJSON(w, http.StatusNotFound, "La impresora no existe", err)
+			return
+		}
+		h.Logger.Errorf("Error al consultar el estado de '%s': %v", name, err)
+		WriteErrorJSON(w, http.StatusInternalServerError, "Error al consultar el estado de la impresora", err)
 		return
 	}
+	if h.Preflight != nil && h.Preflight.Checker != nil {
+		if device, err := h.Preflight.Checker.CheckStatus(name); err != nil {
+			h.Logger.Warnf("No se pudo consultar el dispositivo '%s': %v", name, err)
+		} else if device.Source != status.Source {
+			status.MergeDevice(device)
+		}
+	}
+	WriteJSON(w, http.StatusOK, status)
+}
 
-	WriteJSON(w, http.StatusOK, map[string]string{"message": "Cajón abierto exitosamente."})
+// preflight verifica el estado de la impresora antes de imprimir y guarda las advertencias en el trabajo
+func (h Handlers) preflight(job *PrintJob) error {
+	if err := h.Health.CheckOnline(job.Printer); err != nil {
+		return err
+	}
+	warnings, err := h.Preflight.Check(job.Printer)
+	job.Warnings = append(job.Warnings, warnings...)
+	return err
 }
 
 // HealthHandler maneja la solicitud de salud del servidor
 func (h Handlers) HealthHandler(w http.ResponseWriter, r *http.Request) {
+	h.Logger = h.Logger.ForRequest(r)
 	h.Logger.Info("Received request: /health")
-	WriteJSON(w, http.StatusOK, map[string]bool{"running": true})
+	WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"running": true,
+		"address": h.Address,
+	})
 }
 
 // ============================
 // Funciones Utilitarias
 // ============================
 
+// matchesPrinter indica si la impresora está en la lista de nombres ("*" incluye todas)
+func matchesPrinter(names []string, printer string) bool {
+	for _, name := range names {
+		if name == "*" || name == printer {
+			return true
+		}
+	}
+	return false
+}
+
 // WriteJSON escribe una respuesta JSON con el estado especificado
 func WriteJSON(w http.ResponseWriter, status int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
@@ -538,24 +1441,255 @@ func WriteJSON(w http.ResponseWriter, status int, data interface{}) {
 	}
 }
 
-// WriteErrorJSON escribe una respuesta de error en formato JSON
+// ErrorResponse es el cuerpo de las respuestas de error: Code es un código estable (errorcodes.go)
+// con el que el ERP decide qué hacer, Message el texto en el idioma pedido y Error el mensaje original
+type ErrorResponse struct {
+	Error     string   `json:"error"`
+	Code      string   `json:"code"`
+	Status    int      `json:"status"`
+	Message   string   `json:"message"`
+	Details   string   `json:"details,omitempty"`
+	RequestID string   `json:"request_id,omitempty"`
+	JobID     string   `json:"job_id,omitempty"`
+	Warnings  []string `json:"warnings,omitempty"`
+	Attempts  int      `json:"attempts,omitempty"`
+	// ToolOutput es el final de la salida de la herramienta externa del trabajo fallido
+	ToolOutput string `json:"tool_output,omitempty"`
+	// Jobs detalla el resultado de cada salida de un documento enrutado a varias impresoras
+	Jobs []map[string]interface{} `json:"jobs,omitempty"`
+}
+
+// JobResponse es el cuerpo de las respuestas de los trabajos enviados, retenidos o cancelados
+type JobResponse struct {
+	Message  string   `json:"message"`
+	JobID    string   `json:"job_id"`
+	Status   string   `json:"status,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+	Attempts int      `json:"attempts,omitempty"`
+}
+
+// WriteErrorJSON escribe una respuesta de error en formato JSON, con el código deducido del error
 func WriteErrorJSON(w http.ResponseWriter, status int, message string, err error) {
-	resp := map[string]string{"error": message}
+	WriteErrorCodeJSON(w, status, errorCode(status, err), message, err)
+}
+
+// WriteErrorCodeJSON escribe una respuesta de error con un código explícito
+func WriteErrorCodeJSON(w http.ResponseWriter, status int, code, message string, err error) {
+	WriteJSON(w, status, newErrorResponse(w, status, code, message, err))
+}
+
+// newErrorResponse arma el cuerpo de error con el identificador de la solicitud y el mensaje localizado
+func newErrorResponse(w http.ResponseWriter, status int, code, message string, err error) ErrorResponse {
+	resp := ErrorResponse{
+		Error:     message,
+		Code:      code,
+		Status:    status,
+		Message:   localizedMessage(w, code, message),
+		RequestID: w.Header().Get(requestIDHeader),
+	}
 	if err != nil {
-		resp["details"] = err.Error()
+		resp.Details = err.Error()
+	}
+	return resp
+}
+
+// WriteJobErrorJSON escribe una respuesta de error que incluye el identificador del trabajo
+func WriteJobErrorJSON(w http.ResponseWriter, status int, job *PrintJob, message string, err error) {
+	resp := newErrorResponse(w, status, jobErrorCode(job, err), message, err)
+	resp.JobID = job.ID
+	resp.Warnings = job.Warnings
+	if job.RequestID != "" {
+		resp.RequestID = job.RequestID
 	}
+	if job.Attempts > 1 {
+		resp.Attempts = job.Attempts
+	}
+	resp.ToolOutput = toolOutputTail(job.ToolOutput, toolOutputErrorBytes)
 	WriteJSON(w, status, resp)
 }
 
+// WriteJobJSON escribe la respuesta exitosa de un trabajo, incluyendo sus advertencias si las hay
+func WriteJobJSON(w http.ResponseWriter, job *PrintJob, message string) {
+	resp := JobResponse{Message: message, JobID: job.ID, Warnings: job.Warnings}
+	if job.Attempts > 1 {
+		resp.Attempts = job.Attempts
+	}
+	WriteJSON(w, http.StatusOK, resp)
+}
+
+// WriteJobHeldJSON responde 202 cuando el trabajo quedó retenido hasta que se reponga el papel
+func WriteJobHeldJSON(w http.ResponseWriter, job *PrintJob) {
+	WriteJSON(w, http.StatusAccepted, JobResponse{
+		Message:  "La impresora no tiene papel: el trabajo quedó retenido y se imprimirá al reponerlo.",
+		JobID:    job.ID,
+		Status:   job.Status,
+		Warnings: job.Warnings,
+	})
+}
+
+// jobErrorStatus elige el código HTTP de un trabajo fallido: 409 si la impresora no estaba lista o
+// su perfil no admite el trabajo, 403 si la política de descargas rechazó la URL, 422 si el
+// documento no es un PDF válido y 504 si la descarga superó su tiempo máximo
+func jobErrorStatus(err error) int {
+	var notReady *PrinterNotReadyError
+	if errors.As(err, &notReady) || errors.Is(err, ErrPrinterTypeMismatch) {
+		return http.StatusConflict
+	}
+	if errors.Is(err, ErrDownloadBlocked) {
+		return http.StatusForbidden
+	}
+	if errors.Is(err, ErrInvalidDocument) {
+		return http.StatusUnprocessableEntity
+	}
+	if errors.Is(err, ErrPrinterOffline) {
+		return http.StatusServiceUnavailable
+	}
+	if errors.Is(err, ErrGDIUnsupported) || errors.Is(err, ErrXPSUnsupported) {
+		return http.StatusNotImplemented
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusInternalServerError
+}
+
 // ============================
 // Función Principal
 // ============================
 
 func main() {
-	// Cargar configuración
+	// Cargar configuración (--config indica un archivo distinto de config.yaml junto al ejecutable)
+	os.Args = extractConfigFlag(os.Args)
+	cfg := LoadConfig()
+
+	// Comandos administrativos (firewall, servicio, etc.)
+	if len(os.Args) > 1 {
+		os.Exit(runCLI(cfg, os.Args[1:]))
+	}
+
+	// Ejecución como servicio de Windows (iniciado por el Service Control Manager)
+	if isWindowsService() {
+		runWindowsService(cfg)
+		return
+	}
+
+	logger := newAppLogger(cfg)
+	initCrashReporter(cfg, logger)
+	defer recoverCrash()
+	err := serveWithProfiles(logger, shutdownSignals())
+	if errors.Is(err, errRestartForUpdate) {
+		os.Exit(restartAfterUpdate(logger))
+	}
+	if err != nil {
+		if crashReporter != nil {
+			crashReporter.Write("salida anormal: "+err.Error(), true)
+		}
+		log.Fatal(err)
+	}
+}
+
+// shutdownTimeout es el tiempo máximo para terminar las solicitudes en curso al detener o recargar el servidor
+const shutdownTimeout = 10 * time.Second
+
+// serveWithProfiles ejecuta el servidor y lo reconstruye con la configuración recargada cada vez
+// que se cambia de perfil desde la API. Termina al cerrarse stop o si el servidor falla, y con
+// errRestartForUpdate después de instalar una versión nueva.
+func serveWithProfiles(logger *Logger, stop <-chan struct{}) error {
+	reload := make(chan string, 1)
+	restart := make(chan struct{}, 1)
 	cfg := LoadConfig()
+	server, err := newProfileServer(&cfg, logger, reload, restart)
+	if err != nil {
+		return err
+	}
+	for {
+		if cfg.Profile != "" {
+			logger.Infof("Perfil de configuración activo: %s", cfg.Profile)
+		}
+
+		serverErr := make(chan error, 1)
+		go func() {
+			defer recoverCrash()
+			serverErr <- startServer(server.Server, cfg, logger)
+		}()
+
+		select {
+		case err := <-serverErr:
+			server.Release()
+			return err
+		case profile := <-reload:
+			shutdownServer(server.Server, logger)
+			<-serverErr
+			server.Release()
+			cfg, server, err = switchProfile(cfg, profile, logger, reload, restart)
+			if err != nil {
+				return err
+			}
+		case <-restart:
+			shutdownServer(server.Server, logger)
+			<-serverErr
+			server.Release()
+			return errRestartForUpdate
+		case <-stop:
+			shutdownServer(server.Server, logger)
+			<-serverErr
+			server.Release()
+			return nil
+		}
+	}
+}
 
-	// Configurar logger
+// switchProfile construye el servidor con el perfil pedido desde la API y solo entonces lo guarda
+// como perfil de inicio. Si la configuración del perfil es inválida se vuelve a construir el
+// servidor con el perfil anterior, para no dejar el agente en un ciclo de reinicios fallidos.
+func switchProfile(previous Config, profile string, logger *Logger, reload chan<- string, restart chan<- struct{}) (Config, *AgentServer, error) {
+	cfg := LoadConfigForProfile(profile)
+	server, err := newProfileServer(&cfg, logger, reload, restart)
+	if err == nil {
+		if err := SaveActiveProfile(profile, cfg.Profiles); err != nil {
+			logger.Errorf("No se pudo guardar el perfil '%s' para los próximos inicios: %v", profile, err)
+		}
+		return cfg, server, nil
+	}
+
+	logger.Errorf("No se pudo cambiar al perfil '%s', se mantiene '%s': %v", profile, previous.Profile, err)
+	cfg = LoadConfigForProfile(previous.Profile)
+	server, err = newProfileServer(&cfg, logger, reload, restart)
+	return cfg, server, err
+}
+
+// newProfileServer construye el servidor, generando antes el certificado autofirmado si corresponde
+func newProfileServer(cfg *Config, logger *Logger, reload chan<- string, restart chan<- struct{}) (*AgentServer, error) {
+	if err := applySelfSignedTLS(cfg, logger); err != nil {
+		return nil, err
+	}
+	return NewServer(*cfg, logger, reload, restart)
+}
+
+// shutdownSignals se cierra al recibir SIGINT o SIGTERM (Ctrl+C, "systemctl stop") para detener el
+// servidor ordenadamente y cerrar el historial
+func shutdownSignals() <-chan struct{} {
+	stop := make(chan struct{})
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		close(stop)
+	}()
+	return stop
+}
+
+// shutdownServer detiene el servidor esperando a que terminen las solicitudes en curso
+func shutdownServer(server *http.Server, logger *Logger) {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		logger.Errorf("Error al detener el servidor: %v", err)
+	}
+}
+
+// newAppLogger configura el logger con rotación de archivos
+func newAppLogger(cfg Config) *Logger {
 	loggerConfig := LoggerConfig{
 		Filename:   cfg.LogFile,
 		MaxSize:    cfg.LogMaxSize,
@@ -564,61 +1698,689 @@ func main() {
 		Compress:   cfg.LogCompress,
 		UseFile:    true,
 	}
-	logger := NewLogger(loggerConfig)
+	return NewLogger(loggerConfig)
+}
+
+// AgentServer es el servidor HTTP junto con los recursos que deben liberarse al detenerlo
+type AgentServer struct {
+	*http.Server
+	closers []func() error
+}
+
+// Release libera los recursos del servidor (p. ej. la base de datos del historial) una vez detenido,
+// en el orden inverso al que se abrieron: los trabajos retenidos se cierran antes que el historial
+// para quedar registrados, y las trazas se envían al final para incluir esos trabajos.
+func (s *AgentServer) Release() {
+	for i := len(s.closers) - 1; i >= 0; i-- {
+		s.closers[i]()
+	}
+}
+
+// NewServer construye los servicios, manejadores y el servidor HTTP a partir de la configuración
+func NewServer(cfg Config, logger *Logger, reload chan<- string, restart chan<- struct{}) (_ *AgentServer, err error) {
+	// Recursos abiertos hasta el momento; si la configuración resulta inválida más adelante se
+	// liberan para poder volver a construir el servidor (p. ej. con el perfil anterior)
+	var closers []func() error
+	defer func() {
+		if err != nil {
+			(&AgentServer{closers: closers}).Release()
+		}
+	}()
+
+	if configFile.Err != nil {
+		return nil, configFile.Err
+	}
+	if cfg.ConfigFile != "" {
+		logger.Infof("Archivo de configuración: %s (las variables de entorno tienen prioridad)", cfg.ConfigFile)
+	}
+	if err := validateProfile(cfg.Profile, cfg.Profiles); err != nil {
+		return nil, err
+	}
+
+	// Verificar la licencia; sin ella el servidor inicia pero los endpoints sensibles quedan bloqueados
+	licenses, err := NewLicenseManager(cfg.License, logger)
+	if err != nil {
+		return nil, err
+	}
+	if err := licenses.Load(); err != nil {
+		logger.Errorf("Licencia no válida: %v. Active el agente con 'license activate' o POST /admin/license", err)
+	}
+
+	// Verificación de los ejecutables externos (PDFtoPrinter, SumatraPDF, motores personalizados)
+	verifier, err := NewToolVerifier(cfg.ToolHashes, cfg.ToolVerifyStrict, cfg.ToolRequireSignature, logger)
+	if err != nil {
+		return nil, err
+	}
+	toolVerifier = verifier
 
 	// Inicializar servicios
-	pm := WindowsPrinterManager{}
-	dp := ExternalDocumentPrinter{PDFPrinterPath: cfg.PDFPrinterPath}
-	do := WindowsDrawerOpener{DrawerCommandPath: cfg.DrawerCommandPath}
+	var pm PrinterManager = newSystemPrinterManager()
+	queues := newSystemQueueManager()
+	printerAdmin := newSystemPrinterAdmin()
+	engines, err := NewEngineDocumentPrinter(cfg.Engines, cfg.PDFPrinterPath, logger)
+	if err != nil {
+		return nil, fmt.Errorf("configuración de motores PDF inválida: %w", err)
+	}
+	var dp DocumentPrinter = engines
+	drawerCommands, err := NewDrawerCommandStore(cfg.Drawer.CommandsDir, cfg.DrawerCommandPath, logger)
+	if err != nil {
+		return nil, fmt.Errorf("error al abrir el almacén de comandos de cajón: %w", err)
+	}
+	printerProfiles, err := NewPrinterProfileStore(cfg.PrinterProfiles)
+	if err != nil {
+		return nil, err
+	}
+	// Las impresoras del perfil con puerto serie reciben los trabajos RAW y el cajón por el puerto
+	serialPrinters := SerialPrinters{Profiles: printerProfiles}
+	// Las impresoras de red sin controlador también reciben el cajón y la consulta de estado por TCP
+	addresses := mergeAddresses(cfg.PrinterAddresses, cfg.NetworkPrinters)
+	networkTimeout := time.Duration(cfg.NetworkTimeoutSeconds) * time.Second
+	var do DrawerOpener
+	switch cfg.Drawer.Method {
+	case DrawerMethodESCPOS:
+		writer, err := NewRawWriter(cfg.Drawer.Transport, addresses, "PrinterMatiasERP - Cajón")
+		if err != nil {
+			return nil, fmt.Errorf("configuración del cajón inválida: %w", err)
+		}
+		if len(cfg.NetworkPrinters) > 0 {
+			writer = NetworkRawWriter{Next: writer, Printers: cfg.NetworkPrinters, Timeout: networkTimeout}
+		}
+		writer = SerialRawWriter{Next: writer, Printers: serialPrinters}
+		do = ESCPOSDrawerOpener{Writer: writer, DefaultPin: cfg.Drawer.Pin, DefaultPulseMs: cfg.Drawer.PulseMs, Commands: drawerCommands}
+	case DrawerMethodScript:
+		do = newScriptDrawerOpener(cfg.DrawerCommandPath, drawerCommands)
+	default:
+		return nil, fmt.Errorf("DRAWER_METHOD desconocido: %s", cfg.Drawer.Method)
+	}
+
+	// Etiquetas ZPL/EPL: trabajo RAW por la cola del sistema o directo al puerto 9100
+	labelWriter, err := NewRawWriter(cfg.LabelTransport, addresses, "PrinterMatiasERP - Etiqueta")
+	if err != nil {
+		return nil, fmt.Errorf("LABEL_TRANSPORT inválido: %w", err)
+	}
+	if len(cfg.NetworkPrinters) > 0 {
+		labelWriter = NetworkRawWriter{Next: labelWriter, Printers: cfg.NetworkPrinters, Timeout: networkTimeout}
+	}
+	labelWriter = SerialRawWriter{Next: labelWriter, Printers: serialPrinters}
+
+	// Recibos ESC/POS generados desde JSON y comandos de impresora: mismo esquema que las etiquetas
+	receiptWriter, err := NewRawWriter(cfg.ReceiptTransport, addresses, "PrinterMatiasERP - Recibo")
+	if err != nil {
+		return nil, fmt.Errorf("RECEIPT_TRANSPORT inválido: %w", err)
+	}
+	if len(cfg.NetworkPrinters) > 0 {
+		receiptWriter = NetworkRawWriter{Next: receiptWriter, Printers: cfg.NetworkPrinters, Timeout: networkTimeout}
+	}
+	receiptWriter = SerialRawWriter{Next: receiptWriter, Printers: serialPrinters}
+	if cfg.DownloadTimeoutSeconds <= 0 {
+		return nil, fmt.Errorf("DOWNLOAD_TIMEOUT_SECONDS debe ser mayor que cero")
+	}
+	if cfg.Outbound.InsecureTLS {
+		logger.Warnf("ATENCIÓN: DOWNLOAD_TLS_INSECURE activo, las descargas NO verifican los certificados TLS " +
+			"y el documento puede ser interceptado o reemplazado. Use DOWNLOAD_CA_FILE con la autoridad del proxy.")
+	}
+	if cfg.Outbound.HTTPProxy != "" || cfg.Outbound.HTTPSProxy != "" {
+		logger.Infof("Descargas a través del proxy: http=%s https=%s (excepciones: %s)",
+			cfg.Outbound.HTTPProxy, cfg.Outbound.HTTPSProxy, cfg.Outbound.NoProxy)
+	}
+	httpDownloader, err := NewHTTPDownloader(DownloadPolicy{
+		AllowedHosts: cfg.DownloadAllowedHosts,
+		BlockPrivate: cfg.DownloadBlockPrivate,
+	}, cfg.Outbound, int64(cfg.DownloadMaxSizeMB)<<20, time.Duration(cfg.DownloadTimeoutSeconds)*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	// Caché de documentos descargados (plantillas repetidas, reimpresiones)
+	var docCache *DocumentCache
+	if cfg.DocumentCacheMB > 0 {
+		if docCache, err = NewDocumentCache(cfg.DocumentCacheDir, cfg.DocumentCacheMB, cfg.DocumentCacheMaxAge, logger); err != nil {
+			return nil, err
+		}
+		httpDownloader.Cache = docCache
+	}
+	var dl Downloader = httpDownloader
+
+	var mockBackend *MockBackend
+	var archiveBackend *ArchiveBackend
+	var gdi GDIPrinter
+	var xps XPSPrinter
+	switch cfg.PrinterBackend {
+	case defaultPrinterBackend:
+		gdi, xps = newGDIPrinter(), newXPSPrinter()
+	case "mock":
+		mockBackend, err = NewMockBackend(cfg.MockPrinters, time.Duration(cfg.MockSlowDelayMs)*time.Millisecond, logger)
+		if err != nil {
+			return nil, fmt.Errorf("configuración de impresoras simuladas inválida: %w", err)
+		}
+		logger.Warnf("Usando backend de impresoras SIMULADO: %v", mockBackend.Printers())
+		pm, dp, do, labelWriter, receiptWriter, gdi, xps = mockBackend, mockBackend, mockBackend, mockBackend, mockBackend, mockBackend, mockBackend
+		queues, printerAdmin = nil, nil
+	case "archive":
+		archiveBackend, err = NewArchiveBackend(cfg.Archive, cfg.Outbound, logger)
+		if err != nil {
+			return nil, fmt.Errorf("configuración del backend de archivo inválida: %w", err)
+		}
+		logger.Warnf("Usando backend de ARCHIVO: los documentos se guardan en %s sin imprimirse (%v)", cfg.Archive.Dir, archiveBackend.Printers())
+		pm, dp, do, labelWriter, receiptWriter, xps = archiveBackend, archiveBackend, archiveBackend, archiveBackend, archiveBackend, archiveBackend
+		queues, printerAdmin = nil, nil
+	default:
+		return nil, fmt.Errorf("PRINTER_BACKEND desconocido: %s", cfg.PrinterBackend)
+	}
+
+	if len(cfg.NetworkPrinters) > 0 {
+		if cfg.NetworkTimeoutSeconds <= 0 {
+			return nil, fmt.Errorf("NETWORK_PRINTER_TIMEOUT_SECONDS debe ser mayor que cero")
+		}
+		logger.Infof("Impresoras de red (RAW 9100, sin spooler): %v", cfg.NetworkPrinters)
+		pm = NetworkPrinterManager{Next: pm, Printers: cfg.NetworkPrinters, Timeout: 2 * time.Second}
+		dp = NetworkDocumentPrinter{Next: dp, Printers: cfg.NetworkPrinters, Timeout: networkTimeout, Logger: logger}
+	}
+	pm = SerialPrinterManager{Next: pm, Printers: serialPrinters}
+	dp = SerialDocumentPrinter{Next: dp, Printers: serialPrinters, Logger: logger}
+
+	var ippClient *IPPClient
+	if len(cfg.IPPPrinters) > 0 {
+		for name, uri := range cfg.IPPPrinters {
+			if _, err := ippHTTPURL(uri); err != nil {
+				return nil, fmt.Errorf("IPP_PRINTERS inválido para '%s': %w", name, err)
+			}
+		}
+		if cfg.IPPTimeoutSeconds <= 0 {
+			return nil, fmt.Errorf("IPP_TIMEOUT_SECONDS debe ser mayor que cero")
+		}
+		logger.Infof("Impresoras IPP: %v", cfg.IPPPrinters)
+		ippClient = NewIPPClient(time.Duration(cfg.IPPTimeoutSeconds)*time.Second, cfg.IPPInsecureTLS)
+		pm = IPPPrinterManager{Next: pm, Printers: cfg.IPPPrinters, Client: ippClient}
+		dp = IPPDocumentPrinter{Next: dp, Printers: cfg.IPPPrinters, Client: ippClient, Logger: logger}
+	}
+
+	// Estado, tóner y papel de las impresoras láser de red por SNMP
+	if len(cfg.SNMP.Printers) > 0 {
+		if cfg.SNMP.TimeoutSeconds <= 0 {
+			return nil, fmt.Errorf("SNMP_TIMEOUT_SECONDS debe ser mayor que cero")
+		}
+		logger.Infof("Estado por SNMP de %v (tóner bajo al %d%%)", cfg.SNMP.Printers, cfg.SNMP.LowSupplyPercent)
+		pm = SNMPPrinterManager{
+			Next:             pm,
+			Printers:         cfg.SNMP.Printers,
+			Addresses:        addresses,
+			Client:           NewSNMPClient(cfg.SNMP.Community, time.Duration(cfg.SNMP.TimeoutSeconds)*time.Second),
+			LowSupplyPercent: cfg.SNMP.LowSupplyPercent,
+			Logger:           logger,
+		}
+	}
+
+	metrics := GetMetrics()
+	metrics.SetInfo(cfg)
+	dl = MeteredDownloader{Next: dl, Metrics: metrics}
+	dl = TracedDownloader{Next: dl}
+
+	// Trazas de OpenTelemetry para seguir la impresión desde el clic en el ERP hasta la impresora
+	stopTracing, err := StartTracing(cfg.Tracing, cfg.Outbound, cfg.StoreName, logger)
+	if err != nil {
+		return nil, err
+	}
+	closers = append(closers, stopTracing)
+
+	// Modo caos para QA: envuelve los componentes reales con fallas simuladas
+	if cfg.Chaos.Enabled {
+		logger.Warnf("MODO CAOS ACTIVO: latencia=%dms, fallas de impresión=%.0f%%, impresoras fuera de línea=%.0f%% %v",
+			cfg.Chaos.DownloadLatencyMs, cfg.Chaos.PrintFailureRate*100, cfg.Chaos.OfflineRate*100, cfg.Chaos.OfflinePrinters)
+		pm, dp, dl = NewChaosComponents(cfg.Chaos, pm, dp, dl, logger)
+	}
+
+	// Un envío a la vez por impresora (documentos, RAW y cajón comparten el candado); impresoras
+	// distintas imprimen en paralelo
+	locks := NewPrinterLocks(cfg.PrinterBusyWaitSeconds)
+	dp = SerializedDocumentPrinter{Next: dp, Locks: locks}
+	do = SerializedDrawerOpener{Next: do, Locks: locks}
+	labelWriter = SerializedRawWriter{Next: labelWriter, Locks: locks}
+	receiptWriter = SerializedRawWriter{Next: receiptWriter, Locks: locks}
+	if gdi != nil {
+		gdi = SerializedGDIPrinter{Next: gdi, Locks: locks}
+	}
+	if xps != nil {
+		xps = SerializedXPSPrinter{Next: xps, Locks: locks}
+	}
+	setProcessLimit(cfg.MaxConcurrentProcesses)
+	setToolTimeout(cfg.JobTimeoutSeconds)
+
+	// El sello se agrega fuera del candado: no ocupa la impresora
+	dp = StampingDocumentPrinter{Next: dp, ImagesDir: cfg.StampImagesDir, Logger: logger}
+
+	var artifacts *ArtifactStore
+	if cfg.ArtifactRetentionHours > 0 {
+		if artifacts, err = NewArtifactStore(cfg.ArtifactsDir, cfg.ArtifactRetentionHours, logger); err != nil {
+			return nil, err
+		}
+		closers = append(closers, artifacts.Close)
+	}
+
+	aliases, err := NewPrinterAliases(cfg.PrinterAliases)
+	if err != nil {
+		return nil, err
+	}
+	groups, err := NewPrinterGroups(cfg.PrinterGroups)
+	if err != nil {
+		return nil, err
+	}
 
 	service := DefaultPrinterService{
-		PrinterManager:  pm,
-		DocumentPrinter: dp,
-		DrawerOpener:    do,
-		Logger:          logger,
+		PrinterManager:     pm,
+		DocumentPrinter:    dp,
+		DrawerOpener:       do,
+		LabelWriter:        labelWriter,
+		ReceiptWriter:      receiptWriter,
+		CommandWriter:      receiptWriter,
+		Profiles:           printerProfiles,
+		Queues:             queues,
+		Admin:              printerAdmin,
+		Downloader:         dl,
+		Artifacts:          artifacts,
+		Aliases:            aliases,
+		MaxDocumentBytes:   int64(cfg.MaxUploadSizeMB) << 20,
+		DefaultPrinterName: cfg.DefaultPrinter,
+		Backend:            cfg.PrinterBackend,
+		ReprintStamp:       cfg.ReprintStamp,
+		GDI:                gdi,
+		XPS:                xps,
+		GDIDefault:         cfg.GDIPrinting,
+		Logger:             logger,
+	}
+	// Sin OFFICE_CONVERTER_PATH los documentos de Office se rechazan como cualquier archivo que no es PDF
+	if cfg.OfficeConverterPath != "" {
+		service.OfficeConverter = &OfficeConverter{Path: cfg.OfficeConverterPath, Logger: logger}
+	}
+
+	// Verificación de estado antes de imprimir (ESC/POS en tiempo real o spooler)
+	preflight := &StatusPreflight{
+		Checker:  ESCPOSStatusChecker{Addresses: addresses, Timeout: 2 * time.Second},
+		Mode:     cfg.StatusCheckMode,
+		Printers: cfg.StatusCheckPrinters,
+		Logger:   logger,
+	}
+	if mockBackend != nil {
+		preflight.Checker = mockBackend
+	}
+	if archiveBackend != nil {
+		preflight.Checker = archiveBackend
+	}
+	preflight.Checker = SerialStatusChecker{Next: preflight.Checker, Printers: serialPrinters}
+	if ippClient != nil {
+		preflight.Checker = IPPStatusChecker{Next: preflight.Checker, Printers: cfg.IPPPrinters, Client: ippClient}
+	}
+	preflight.Checker = AliasStatusChecker{Next: preflight.Checker, Aliases: aliases}
+	switch preflight.Mode {
+	case StatusCheckOff, StatusCheckWarn, StatusCheckFail:
+	default:
+		return nil, fmt.Errorf("STATUS_CHECK desconocido: %s (use off, warn o fail)", preflight.Mode)
+	}
+
+	routes, err := LoadRoutingRules(cfg.RoutingFile)
+	if err != nil {
+		return nil, err
+	}
+	kitchen, err := NewKitchenRouteStore(cfg.Kitchen)
+	if err != nil {
+		return nil, err
 	}
 
 	// Inicializar manejadores
+	history, err := NewBoltJobHistory(cfg.HistoryPath, cfg.HistoryRetentionDays, logger)
+	if err != nil {
+		return nil, err
+	}
+	closers = append(closers, history.Close)
+	if cfg.PrintRetries < 0 || cfg.PrintRetries > maxRetries {
+		return nil, fmt.Errorf("PRINT_RETRIES debe estar entre 0 y %d", maxRetries)
+	}
+	if cfg.EventsPollSeconds <= 0 {
+		return nil, fmt.Errorf("EVENTS_PRINTER_POLL_SECONDS debe ser mayor que cero")
+	}
+	// Alertas al canal de soporte (Slack, Telegram, correo)
+	alerts, err := NewAlerter(cfg.Alerts, cfg.Outbound, cfg.StoreName, logger)
+	if err != nil {
+		return nil, err
+	}
+	alerts.Start()
+	closers = append(closers, alerts.Close)
+	// Actualización del agente desde el manifiesto firmado
+	updater, err := NewUpdater(cfg.Update, cfg.Outbound, logger)
+	if err != nil {
+		return nil, err
+	}
+	closers = append(closers, updater.Close)
+	webhooks, err := NewWebhookNotifier(cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+	events := NewEventBus()
+	jobs := &JobRunner{
+		History:  history,
+		Metrics:  metrics,
+		Webhooks: webhooks,
+		Logger:   logger,
+		Events:   events,
+		Alerts:   alerts,
+		Retry: RetryPolicy{
+			Retries: cfg.PrintRetries,
+			Delay:   time.Duration(cfg.PrintRetryDelayMs) * time.Millisecond,
+		},
+	}
+	// Seguimiento de los documentos en la cola del sistema hasta que se imprimen
+	if jobs.Spooler, err = NewSpoolerTracker(queues, cfg.SpoolerTracking, logger); err != nil {
+		return nil, err
+	}
+	if jobs.Spooler != nil {
+		jobs.Spooler.Jobs = jobs
+		jobs.Spooler.Start()
+		closers = append(closers, jobs.Spooler.Close)
+	}
+	// Retención de la cola cuando una impresora se queda sin papel
+	if cfg.PaperHoldEnabled {
+		if cfg.PaperHoldPollSeconds <= 0 {
+			return nil, fmt.Errorf("PAPER_HOLD_POLL_SECONDS debe ser mayor que cero")
+		}
+		jobs.Holds = NewPaperHold(preflight.Checker, cfg.PaperHoldPollSeconds, cfg.PaperHoldMaxMinutes, logger)
+		jobs.Holds.Runner = jobs
+		closers = append(closers, jobs.Holds.Close)
+	}
+	// Trabajos con schedule_at e impresiones periódicas (SCHEDULED_PRINTS)
+	if jobs.Scheduler, err = NewScheduler(cfg.Schedule, history, logger); err != nil {
+		return nil, err
+	}
+	jobs.Scheduler.Runner = jobs
+	closers = append(closers, jobs.Scheduler.Close)
+	if jobs.Usage, err = NewUsageStore(history, logger); err != nil {
+		return nil, err
+	}
+	hotFolders, err := NewHotFolderWatcher(cfg.HotFolders, logger)
+	if err != nil {
+		return nil, err
+	}
+	if hotFolders != nil {
+		closers = append(closers, hotFolders.Close)
+	}
+	displays, err := NewDisplayManager(cfg.Displays, logger)
+	if err != nil {
+		return nil, err
+	}
+	if displays != nil && mockBackend != nil {
+		displays.Writer = mockBackend
+	}
+	// Sensor del cajón por DLE EOT 1; el backend de archivo no tiene cajón que consultar
+	var drawerSensor DrawerStatusChecker = ESCPOSDrawerStatusChecker{Addresses: addresses, Serial: serialPrinters, Timeout: 2 * time.Second, OpenSignal: cfg.Drawer.OpenSignal}
+	if mockBackend != nil {
+		drawerSensor = mockBackend
+	}
+	drawerMonitor, err := NewDrawerMonitor(drawerSensor, cfg.Drawer, events, logger)
+	if err != nil {
+		return nil, err
+	}
+	if archiveBackend == nil {
+		service.DrawerSensor = drawerMonitor
+	}
+	drawerMonitor.Start()
+	closers = append(closers, drawerMonitor.Close)
+	// Salud de las impresoras para rechazar de inmediato los trabajos a impresoras fuera de línea
+	health, err := NewHealthMonitor(service, aliases, cfg.Health, logger)
+	if err != nil {
+		return nil, err
+	}
+	if health != nil {
+		health.Alerts = alerts
+	}
+	health.Start()
+	closers = append(closers, health.Close)
+
 	handlers := Handlers{
-		Service: service,
-		Logger:  logger,
+		Service:        service,
+		Jobs:           jobs,
+		History:        history,
+		Preflight:      preflight,
+		Logger:         logger,
+		Address:        cfg.ListenAddr(),
+		MaxUploadBytes: int64(cfg.MaxUploadSizeMB) << 20,
+		Routes:         routes,
+		Groups:         groups,
+		Kitchen:        kitchen,
+		Health:         health,
+		Licenses:       licenses,
 	}
 
 	// Configurar rutas
-	mux := http.NewServeMux()
-	mux.HandleFunc("/print", handlers.PrintHandler)
-	mux.HandleFunc("/open-box", handlers.OpenDrawerHandler)
+	mux := newRouteMux()
+	if mux.access, err = NewIPAccess(cfg.IPAllowlist, logger); err != nil {
+		return nil, err
+	}
+	mux.limits = NewRateLimiter(cfg.RateLimit, metrics, logger)
+	sessions := NewSessionStore(cfg.SessionTTLHours, logger)
+	mux.HandleFunc("/session", sessions.SessionHandler)
+	mux.HandleFunc("/capabilities", sessions.CapabilitiesHandler)
+	mux.HandleFunc("/print", licenses.Require(handlers.PrintHandler))
+	mux.HandleFunc("/print-file", licenses.Require(handlers.PrintFileHandler))
+	mux.HandleFunc("/print-batch", licenses.Require(handlers.PrintBatchHandler))
+	mux.HandleFunc("/estimate", handlers.EstimateHandler)
+	mux.HandleFunc("/validate-print", handlers.ValidatePrintHandler)
+	mux.HandleFunc("/pdf-info", handlers.PDFInfoHandler)
+	mux.HandleFunc("/open-box", licenses.Require(handlers.OpenDrawerHandler))
+	mux.HandleFunc("/print-label", licenses.Require(handlers.PrintLabelHandler))
+	mux.HandleFunc("/print-receipt", licenses.Require(handlers.PrintReceiptHandler))
+	mux.HandleFunc("/print-order", licenses.Require(handlers.PrintOrderHandler))
+	mux.HandleFunc("/printer-command", licenses.Require(handlers.PrinterCommandHandler))
+	mux.HandleFunc("/print-image", licenses.Require(handlers.PrintImageHandler))
+	mux.HandleFunc("/print-text", licenses.Require(handlers.PrintTextHandler))
+	if displays != nil {
+		displayHandlers := DisplayHandlers{Displays: displays, Logger: logger}
+		mux.HandleFunc("/display", licenses.Require(displayHandlers.DisplayHandler))
+		mux.HandleFunc("/display/clear", licenses.Require(displayHandlers.ClearHandler))
+	}
 	mux.HandleFunc("/list-printers", handlers.ListPrintersHandler)
+	mux.HandleFunc("/printers/{name}/status", handlers.PrinterStatusHandler)
+	if health != nil {
+		mux.HandleFunc("/printers/health", handlers.PrintersHealthHandler)
+	}
+	mux.HandleFunc("/printers/{name}/queue", handlers.PrinterQueueHandler)
+	mux.HandleFunc("/printers/{name}/drawer-status", handlers.DrawerStatusHandler)
 	mux.HandleFunc("/health", handlers.HealthHandler)
+	if cfg.TLSGenerated {
+		mux.HandleFunc("/tls/ca.pem", TLSHandlers{CAPath: SelfSignedTLS{Dir: cfg.TLSDir}.CAPath(), Logger: logger}.CAHandler)
+	}
+	if cfg.MetricsEnabled {
+		mux.Handle("/metrics", metrics.Handler())
+	}
+	mux.HandleFunc("/jobs", handlers.JobsHandler)
+	mux.HandleFunc("/jobs/{id}", handlers.JobHandler)
+	mux.HandleFunc("/jobs/{id}/reprint", licenses.Require(handlers.ReprintHandler))
+	mux.HandleFunc("/reports/usage", jobs.Usage.ReportHandler)
+
+	// Eventos en tiempo real para el ERP (trabajos, impresoras y cajón)
+	eventHandlers := EventHandlers{Events: events, AllowedOrigins: cfg.AllowedOrigins, Logger: logger}
+	mux.HandleFunc("/ws", eventHandlers.WebSocketHandler)
+	mux.HandleFunc("/events", eventHandlers.EventsHandler)
+	watcher := NewPrinterWatcher(service, events, cfg.EventsPollSeconds, logger)
+	watcher.Start()
+	closers = append(closers, watcher.Close)
+	if cfg.GraphQLEnabled {
+		graphQLHandler, err := NewGraphQLHandler(&GraphQLResolver{
+			Service:   service,
+			History:   history,
+			StoreName: cfg.StoreName,
+			Address:   cfg.ListenAddr(),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error al inicializar el esquema GraphQL: %w", err)
+		}
+		mux.Handle("/graphql", graphQLHandler)
+	}
+
+	// API administrativa (requiere ADMIN_TOKEN)
+	admin := AdminAuth{Token: cfg.AdminToken, Logger: logger}
+	drawerHandlers := DrawerCommandHandlers{Store: drawerCommands, Logger: logger}
+	profileHandlers := PrinterProfileHandlers{Store: printerProfiles, Logger: logger}
+	mux.HandleFunc("/admin/license", admin.Require(licenses.LicenseHandler))
+	dashboard := DashboardHandlers{History: history, LogFile: cfg.LogFile, StoreName: cfg.StoreName, Backend: cfg.PrinterBackend, Logger: logger}
+	mux.HandleFunc("/admin", dashboard.PageHandler)
+	mux.HandleFunc("/admin/summary", admin.Require(dashboard.SummaryHandler))
+	mux.HandleFunc("/admin/log", admin.Require(dashboard.LogTailHandler))
+	logHandlers := LogHandlers{LogFile: cfg.LogFile, Logger: logger}
+	mux.HandleFunc("/admin/logs", admin.Require(logHandlers.LogsHandler))
+	mux.HandleFunc("/admin/logs/files", admin.Require(logHandlers.FilesHandler))
+	mux.HandleFunc("/admin/logs/files/{name}", admin.Require(logHandlers.FileHandler))
+	// Diagnóstico del crecimiento de memoria en los agentes con meses en ejecución
+	runtimeHandlers := RuntimeHandlers{Jobs: jobs, Events: events, Logger: logger}
+	mux.HandleFunc("/admin/runtime", admin.Require(runtimeHandlers.RuntimeHandler))
+	if cfg.PprofEnabled {
+		registerPprof(mux, admin)
+	}
+	if updater != nil {
+		mux.HandleFunc("/admin/update", admin.Require(updater.StatusHandler))
+		mux.HandleFunc("/admin/update/check", admin.Require(updater.CheckHandler))
+		mux.HandleFunc("/admin/update/install", admin.Require(updater.InstallHandler))
+	}
+	profiles := ProfileHandlers{Active: cfg.Profile, Profiles: cfg.Profiles, Reload: reload, Logger: logger}
+	mux.HandleFunc("/admin/profile", admin.Require(profiles.ProfileHandler))
+	mux.HandleFunc("/admin/drawer-commands", admin.Require(drawerHandlers.ListHandler))
+	mux.HandleFunc("/admin/drawer-commands/validate", admin.Require(drawerHandlers.ValidateHandler))
+	mux.HandleFunc("/admin/drawer-commands/{version}", admin.Require(drawerHandlers.VersionHandler))
+	mux.HandleFunc("/admin/drawer-commands/{version}/activate", admin.Require(drawerHandlers.ActivateHandler))
+	mux.HandleFunc("/admin/printer-profiles", admin.Require(profileHandlers.ListHandler))
+	mux.HandleFunc("/admin/printer-profiles/{printer}", admin.Require(profileHandlers.ProfileHandler))
+	kitchenHandlers := KitchenRouteHandlers{Store: kitchen, Logger: logger}
+	mux.HandleFunc("/admin/kitchen-routes", admin.Require(kitchenHandlers.ListHandler))
+	mux.HandleFunc("/admin/kitchen-routes/{printer}", admin.Require(kitchenHandlers.StationHandler))
+	mux.HandleFunc("/admin/sessions", admin.Require(sessions.SessionsHandler))
+	if docCache != nil {
+		mux.HandleFunc("/admin/cache", admin.Require(docCache.CacheHandler))
+	}
+	// Consultar la impresora predeterminada es libre; cambiarla en el sistema requiere el token
+	mux.HandleFunc("/default-printer", admin.RequireMethods(handlers.DefaultPrinterHandler, http.MethodPut))
+	// Pausar, reanudar o vaciar la cola afecta a todos los usuarios de la impresora
+	mux.HandleFunc("/printers/{name}/queue/{action}", admin.Require(handlers.PrinterQueueActionHandler))
+	mux.HandleFunc("/printers/{name}/logo", admin.Require(handlers.PrinterLogoHandler))
+	// Instalación remota de impresoras, sin conectarse por escritorio remoto a cada equipo
+	mux.HandleFunc("/admin/printers", admin.Require(handlers.PrintersAdminHandler))
+	mux.HandleFunc("/admin/printers/{name}", admin.Require(handlers.PrinterAdminHandler))
+
+	if alerts != nil {
+		mux.HandleFunc("/admin/alerts/test", admin.Require(alerts.TestHandler))
+	}
+	if crashReporter != nil {
+		mux.HandleFunc("/admin/crash-reports", admin.Require(crashReporter.CrashReportsHandler))
+		mux.HandleFunc("/admin/crash-reports/{name}", admin.Require(crashReporter.CrashReportHandler))
+	}
+
+	if mockBackend != nil {
+		mockHandlers := MockHandlers{Backend: mockBackend, Logger: logger}
+		mux.HandleFunc("/admin/mock/printers", admin.Require(mockHandlers.MockPrintersHandler))
+		mux.HandleFunc("/admin/mock/calls", admin.Require(mockHandlers.MockCallsHandler))
+	}
+
+	// El documento OpenAPI se genera con las rutas ya registradas
+	mux.Handle("/openapi.json", OpenAPIHandler{Document: BuildOpenAPI(mux), Logger: logger})
+	sessions.Capabilities = buildCapabilities(cfg, mux, engines.Names(), jobs, artifacts != nil, len(routes) > 0, !kitchen.Empty(), licenses.Enabled())
 
 	// Configurar CORS
 	c := cors.New(cors.Options{
 		AllowedOrigins:   cfg.AllowedOrigins,
 		AllowedMethods:   []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete},
-		AllowedHeaders:   []string{"Content-Type", "Authorization", "X-Requested-With", "Accept", "authorization", "x-app-version"},
+		AllowedHeaders:   []string{"Content-Type", "Authorization", "X-Requested-With", "Accept", "authorization", "x-app-version", "X-Admin-Token", requestIDHeader, sessionTokenHeader, "traceparent", "tracestate"},
+		ExposedHeaders:   []string{requestIDHeader, "Retry-After"},
 		AllowCredentials: false,
 		MaxAge:           300, // 5 minutos
 		Debug:            false,
 	})
 
-	handlerWithCORS := c.Handler(mux)
+	// El middleware de accesos envuelve también a CORS para registrar las solicitudes preliminares; el
+	// span de cada solicitud continúa la traza del ERP (traceparent)
+	handlerWithCORS := RequestIDMiddleware(TracingMiddleware(c.Handler(mux), mux.ServeMux), logger)
+
+	// Modo relay: el ERP en la nube envía los trabajos por una conexión saliente del agente
+	var relay *RelayClient
+	if cfg.Relay.Enabled() {
+		if cfg.Relay.AgentID == "" {
+			cfg.Relay.AgentID = cfg.StoreName
+		}
+		if relay, err = NewRelayClient(cfg.Relay, cfg.Outbound, handlerWithCORS, events, logger); err != nil {
+			return nil, err
+		}
+		relay.Start()
+		closers = append(closers, relay.Close)
+	}
+
+	// Anuncio en la red local para que el ERP y las aplicaciones móviles encuentren el agente; si la
+	// red no permite multicast el agente sigue funcionando con la dirección configurada
+	var advertiser *MDNSAdvertiser
+	if cfg.MDNSEnabled {
+		if advertiser, err = NewMDNSAdvertiser(cfg, cfg.MDNSInstance, logger); err != nil {
+			logger.Warnf("%v", err)
+		} else {
+			closers = append(closers, advertiser.Close)
+		}
+	}
 
 	// Configurar servidor HTTP
 	server := &http.Server{
-		Addr:         fmt.Sprintf(":%d", cfg.Port),
+		Addr:         cfg.ListenAddr(),
 		Handler:      handlerWithCORS,
 		ReadTimeout:  time.Duration(cfg.HTTPReadTimeout) * time.Second,
 		WriteTimeout: time.Duration(cfg.HTTPWriteTimeout) * time.Second,
 		IdleTimeout:  time.Duration(cfg.HTTPIdleTimeout) * time.Second,
 	}
 
-	logger.Infof("Servidor iniciado en puerto :%d", cfg.Port)
+	// Con ACME_DOMAINS el certificado se obtiene y renueva solo, salvo que se indiquen los archivos
+	var certs *ACMEManager
+	if cfg.ACME.Enabled() && !cfg.tlsFiles() {
+		if certs, err = NewACMEManager(cfg.ACME, cfg.Outbound, cfg.Port, logger); err != nil {
+			return nil, err
+		}
+		server.TLSConfig = certs.TLSConfig()
+		closers = append(closers, certs.Close)
+	}
+	if err := configureClientAuth(server, cfg); err != nil {
+		return nil, err
+	}
 
-	// Iniciar servidor con o sin TLS
-	if cfg.TLSCertPath != "" && cfg.TLSKeyPath != "" {
+	if updater != nil {
+		// El reinicio se pide una sola vez aunque coincidan la consulta periódica y la API
+		updater.Restart = func() {
+			select {
+			case restart <- struct{}{}:
+			default:
+			}
+		}
+		updater.Start()
+	}
+	jobs.Scheduler.Print = handlers.runScheduled
+	jobs.Scheduler.Start()
+	if hotFolders != nil {
+		hotFolders.Print = handlers.printHotFolderFile
+		hotFolders.Start()
+	}
+	return &AgentServer{Server: server, closers: closers}, nil
+}
+
+// startServer inicia el servidor con o sin TLS; bloquea hasta que el servidor se detiene
+func startServer(server *http.Server, cfg Config, logger *Logger) error {
+	logger.Infof("Servidor iniciado en %s", cfg.ListenAddr())
+
+	if cfg.tlsFiles() {
 		logger.Infof("Iniciando servidor TLS")
-		log.Fatal(server.ListenAndServeTLS(cfg.TLSCertPath, cfg.TLSKeyPath))
-	} else {
-		log.Fatal(server.ListenAndServe())
+		return server.ListenAndServeTLS(cfg.TLSCertPath, cfg.TLSKeyPath)
 	}
+	if server.TLSConfig != nil {
+		logger.Infof("Iniciando servidor TLS con certificado ACME")
+		return server.ListenAndServeTLS("", "")
+	}
+	return server.ListenAndServe()
+}
+
+// tlsFiles indica si se configuraron el certificado y la clave del servidor
+func (c Config) tlsFiles() bool {
+	return c.TLSCertPath != "" && c.TLSKeyPath != ""
+}
+
+// TLSEnabled indica si el servidor atiende por HTTPS (archivos, autofirmado o ACME)
+func (c Config) TLSEnabled() bool {
+	return c.tlsFiles() || c.ACME.Enabled()
 }
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/grandcat/zeroconf"
+)
+
+// ============================
+// Anuncio del Agente por mDNS/Bonjour
+// ============================
+
+// mdnsService es el tipo de servicio con el que el ERP y las aplicaciones móviles buscan el agente
+const mdnsService = "_printermatias._tcp"
+
+// MDNSAdvertiser anuncia el agente en la red local para que los clientes lo encuentren sin que el
+// usuario escriba IP:puerto
+type MDNSAdvertiser struct {
+	server *zeroconf.Server
+}
+
+// mdnsText arma los registros TXT del anuncio: versión, tienda, esquema y rutas para empezar
+func mdnsText(cfg Config) []string {
+	scheme := "http"
+	if cfg.TLSEnabled() {
+		scheme = "https"
+	}
+	return []string{
+		"version=" + agentVersion,
+		"store=" + cfg.StoreName,
+		"scheme=" + scheme,
+		"health=/health",
+		"capabilities=/capabilities",
+		"backend=" + cfg.PrinterBackend,
+	}
+}
+
+// NewMDNSAdvertiser publica el servicio con el nombre de instancia indicado (por defecto, la tienda).
+// Si el agente escucha solo en loopback no se anuncia: nadie más en la red podría conectarse.
+func NewMDNSAdvertiser(cfg Config, instance string, logger *Logger) (*MDNSAdvertiser, error) {
+	host := strings.TrimSpace(cfg.BindAddress)
+	if ip := net.ParseIP(host); (ip != nil && ip.IsLoopback()) || strings.EqualFold(host, "localhost") {
+		logger.Infof("Anuncio mDNS omitido: el agente escucha solo en %s", cfg.ListenAddr())
+		return nil, nil
+	}
+	if instance == "" {
+		instance = cfg.StoreName
+	}
+
+	server, err := zeroconf.Register(instance, mdnsService, "local.", cfg.Port, mdnsText(cfg), nil)
+	if err != nil {
+		return nil, fmt.Errorf("error al anunciar el agente por mDNS: %w", err)
+	}
+	logger.Infof("Agente anunciado por mDNS como '%s' (%s, puerto %d)", instance, mdnsService, cfg.Port)
+	return &MDNSAdvertiser{server: server}, nil
+}
+
+// Close retira el anuncio de la red
+func (a *MDNSAdvertiser) Close() error {
+	a.server.Shutdown()
+	return nil
+}
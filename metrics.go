@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// ============================
+// Métricas de Prometheus
+// ============================
+
+// Metrics agrupa los indicadores expuestos en /metrics. Se crean una sola vez por proceso
+// para que los contadores se conserven al recargar la configuración (cambio de perfil).
+type Metrics struct {
+	registry      *prometheus.Registry
+	info          *prometheus.GaugeVec
+	printRequests *prometheus.CounterVec
+	printFailures *prometheus.CounterVec
+	printDuration *prometheus.HistogramVec
+	drawerOpens   *prometheus.CounterVec
+	downloadBytes prometheus.Counter
+	rateLimited   *prometheus.CounterVec
+}
+
+var (
+	agentMetrics     *Metrics
+	agentMetricsOnce sync.Once
+)
+
+// GetMetrics devuelve las métricas del proceso, registrándolas en el primer uso
+func GetMetrics() *Metrics {
+	agentMetricsOnce.Do(func() {
+		m := &Metrics{
+			registry: prometheus.NewRegistry(),
+			info: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+				Name: "printmatias_agent_info",
+				Help: "Datos del agente de impresión (siempre 1).",
+			}, []string{"store", "profile", "backend"}),
+			printRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+				Name: "printmatias_print_requests_total",
+				Help: "Solicitudes de impresión recibidas por impresora y tipo de trabajo.",
+			}, []string{"printer", "kind"}),
+			printFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+				Name: "printmatias_print_failures_total",
+				Help: "Impresiones fallidas por impresora y tipo de trabajo.",
+			}, []string{"printer", "kind"}),
+			printDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+				Name:    "printmatias_print_duration_seconds",
+				Help:    "Duración de las impresiones por impresora y tipo de trabajo (descarga incluida).",
+				Buckets: []float64{0.25, 0.5, 1, 2, 5, 10, 20, 30, 60},
+			}, []string{"printer", "kind"}),
+			drawerOpens: prometheus.NewCounterVec(prometheus.CounterOpts{
+				Name: "printmatias_drawer_opens_total",
+				Help: "Aperturas de cajón por impresora y resultado.",
+			}, []string{"printer", "status"}),
+			downloadBytes: prometheus.NewCounter(prometheus.CounterOpts{
+				Name: "printmatias_download_bytes_total",
+				Help: "Bytes descargados de documentos a imprimir.",
+			}),
+			rateLimited: prometheus.NewCounterVec(prometheus.CounterOpts{
+				Name: "printmatias_rate_limited_total",
+				Help: "Solicitudes rechazadas por el límite de solicitudes, por ruta y límite (ip o global).",
+			}, []string{"route", "scope"}),
+		}
+		m.registry.MustRegister(
+			collectors.NewGoCollector(),
+			collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+			m.info, m.printRequests, m.printFailures, m.printDuration, m.drawerOpens, m.downloadBytes, m.rateLimited,
+		)
+		agentMetrics = m
+	})
+	return agentMetrics
+}
+
+// SetInfo actualiza la métrica informativa con la configuración vigente
+func (m *Metrics) SetInfo(cfg Config) {
+	m.info.Reset()
+	m.info.WithLabelValues(cfg.StoreName, cfg.Profile, cfg.PrinterBackend).Set(1)
+}
+
+// ObserveJob registra el resultado de un trabajo terminado. Todos los trabajos que imprimen (PDF,
+// tickets, etiquetas, imágenes, texto y comandos) se cuentan por tipo; el cajón tiene su propia métrica.
+func (m *Metrics) ObserveJob(job *PrintJob) {
+	if job.Kind == JobKindDrawer {
+		m.drawerOpens.WithLabelValues(job.Printer, job.Status).Inc()
+		return
+	}
+	m.printRequests.WithLabelValues(job.Printer, job.Kind).Inc()
+	if job.Status == JobStatusFailed {
+		m.printFailures.WithLabelValues(job.Printer, job.Kind).Inc()
+	}
+	m.printDuration.WithLabelValues(job.Printer, job.Kind).Observe(float64(job.DurationMs) / 1000)
+}
+
+// ObserveSpoolerFailure suma a los fallos un trabajo que ObserveJob contó como enviado a la cola
+// (spooled) y que el spooler terminó con error
+func (m *Metrics) ObserveSpoolerFailure(job *PrintJob) {
+	m.printFailures.WithLabelValues(job.Printer, job.Kind).Inc()
+}
+
+// Handler devuelve el manejador HTTP del endpoint /metrics
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// MeteredDownloader cuenta los bytes descargados por el Downloader que envuelve
+type MeteredDownloader struct {
+	Next    Downloader
+	Metrics *Metrics
+}
+
+// Download descarga el archivo y suma su tamaño a printmatias_download_bytes_total
+func (d MeteredDownloader) Download(ctx context.Context, fileURL string) (string, error) {
+	path, err := d.Next.Download(ctx, fileURL)
+	if err != nil {
+		return path, err
+	}
+	if info, err := os.Stat(path); err == nil {
+		d.Metrics.downloadBytes.Add(float64(info.Size()))
+	}
+	return path, nil
+}
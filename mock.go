@@ -0,0 +1,453 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ============================
+// Backend Simulado (mock) para pruebas de integración
+// ============================
+
+// Comportamientos soportados por las impresoras simuladas
+const (
+	MockBehaviorOK         = "ok"          // imprime y abre el cajón sin errores
+	MockBehaviorFail       = "fail"        // falla al imprimir y al abrir el cajón
+	MockBehaviorOffline    = "offline"     // la impresora se reporta fuera de línea
+	MockBehaviorSlow       = "slow"        // imprime correctamente pero con demora
+	MockBehaviorPaperLow   = "paper-low"   // imprime, pero reporta papel por agotarse
+	MockBehaviorPaperOut   = "paper-out"   // reporta falta de papel y falla al imprimir
+	MockBehaviorDrawerOpen = "drawer-open" // imprime, pero el sensor informa el cajón abierto
+)
+
+// Operaciones registradas por el backend simulado
+const (
+	MockCallPrint   = "print"   // documento PDF
+	MockCallRaw     = "raw"     // datos RAW (tickets ESC/POS, etiquetas ZPL/EPL)
+	MockCallDrawer  = "drawer"  // apertura de cajón
+	MockCallDisplay = "display" // texto enviado a un visor de cliente
+	MockCallGDI     = "gdi"     // texto o imagen dibujados con GDI (PNG en data para las imágenes)
+	MockCallXPS     = "xps"     // documento XPS
+)
+
+// maxMockCalls limita las llamadas registradas; al superarlo se descartan las más antiguas
+const maxMockCalls = 1000
+
+// MockCall es una llamada recibida por el backend simulado, para que las pruebas de integración
+// verifiquen qué se habría impreso
+type MockCall struct {
+	Time      time.Time      `json:"time"`
+	Operation string         `json:"operation"`
+	Printer   string         `json:"printer"`
+	SHA256    string         `json:"document_sha256,omitempty"`
+	SizeBytes int64          `json:"size_bytes,omitempty"`
+	Data      []byte         `json:"data,omitempty"`
+	Options   *PrintOptions  `json:"options,omitempty"`
+	Drawer    *DrawerOptions `json:"drawer,omitempty"`
+	Error     string         `json:"error,omitempty"`
+}
+
+// MockBackend implementa PrinterManager, DocumentPrinter y DrawerOpener con impresoras ficticias
+// cuyo comportamiento puede programarse por configuración o vía HTTP. Registra cada impresión,
+// envío RAW y apertura de cajón para consultarlas en /admin/mock/calls.
+type MockBackend struct {
+	mu        sync.RWMutex
+	printers  map[string]string
+	calls     []MockCall
+	slowDelay time.Duration
+	logger    *Logger
+}
+
+// NewMockBackend crea el backend simulado a partir de una especificación "Nombre=comportamiento,..."
+func NewMockBackend(spec string, slowDelay time.Duration, logger *Logger) (*MockBackend, error) {
+	m := &MockBackend{
+		printers:  make(map[string]string),
+		slowDelay: slowDelay,
+		logger:    logger,
+	}
+	for _, entry := range splitAndTrim(spec, ",") {
+		name, behavior, found := strings.Cut(entry, "=")
+		if !found {
+			behavior = MockBehaviorOK
+		}
+		if err := m.SetBehavior(strings.TrimSpace(name), strings.TrimSpace(behavior)); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// SetBehavior crea o actualiza una impresora simulada
+func (m *MockBackend) SetBehavior(name, behavior string) error {
+	if name == "" {
+		return fmt.Errorf("nombre de impresora simulada vacío")
+	}
+	behavior = strings.ToLower(behavior)
+	switch behavior {
+	case MockBehaviorOK, MockBehaviorFail, MockBehaviorOffline, MockBehaviorSlow, MockBehaviorPaperLow, MockBehaviorPaperOut, MockBehaviorDrawerOpen:
+	default:
+		return fmt.Errorf("comportamiento simulado desconocido: %s", behavior)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.printers[name] = behavior
+	return nil
+}
+
+// RemovePrinter elimina una impresora simulada
+func (m *MockBackend) RemovePrinter(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.printers, name)
+}
+
+// Printers devuelve una copia de las impresoras simuladas y su comportamiento
+func (m *MockBackend) Printers() map[string]string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := make(map[string]string, len(m.printers))
+	for k, v := range m.printers {
+		out[k] = v
+	}
+	return out
+}
+
+func (m *MockBackend) behavior(name string) (string, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	b, ok := m.printers[name]
+	return b, ok
+}
+
+// ListPrinters lista las impresoras simuladas con el mismo formato que el backend de Windows
+func (m *MockBackend) ListPrinters() ([]string, error) {
+	printers := m.Printers()
+	names := make([]string, 0, len(printers))
+	for name := range printers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var result []string
+	for _, name := range names {
+		status := "Normal"
+		switch printers[name] {
+		case MockBehaviorOffline:
+			status = "Offline"
+		case MockBehaviorFail:
+			status = "Error"
+		case MockBehaviorPaperOut:
+			status = "PaperOut"
+		}
+		result = append(result, fmt.Sprintf("Name=%s;DriverName=Mock Driver;PortName=MOCK:;PrinterStatus=%s;Location=Mock", name, status))
+	}
+	return result, nil
+}
+
+// PrinterExists verifica si la impresora simulada existe y está en línea
+func (m *MockBackend) PrinterExists(name string) (bool, error) {
+	behavior, ok := m.behavior(name)
+	if !ok {
+		return false, nil
+	}
+	if behavior == MockBehaviorOffline {
+		return false, &PrinterNotReadyError{Printer: name, Problems: []string{"la impresora está fuera de línea (mock)"}}
+	}
+	return true, nil
+}
+
+// DefaultPrinter devuelve la primera impresora simulada en orden alfabético
+func (m *MockBackend) DefaultPrinter() (string, error) {
+	printers := m.Printers()
+	names := make([]string, 0, len(printers))
+	for name := range printers {
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return "", fmt.Errorf("no hay impresoras simuladas")
+	}
+	sort.Strings(names)
+	return names[0], nil
+}
+
+// GetPrinterStatus simula el estado del spooler según el comportamiento programado
+func (m *MockBackend) GetPrinterStatus(name string) (*PrinterStatus, error) {
+	behavior, ok := m.behavior(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: '%s' (mock)", ErrPrinterNotFound, name)
+	}
+	status := &PrinterStatus{
+		Printer:   name,
+		Status:    "Normal",
+		States:    []string{},
+		Online:    behavior != MockBehaviorOffline,
+		PaperOut:  behavior == MockBehaviorPaperOut,
+		Error:     behavior == MockBehaviorFail,
+		Source:    "mock",
+		CheckedAt: time.Now(),
+	}
+	switch behavior {
+	case MockBehaviorOffline:
+		status.Status = "Offline"
+	case MockBehaviorFail:
+		status.Status = "Error"
+	case MockBehaviorPaperOut:
+		status.Status = "PaperOut"
+	}
+	if status.Status != "Normal" {
+		status.States = append(status.States, status.Status)
+	}
+	status.finish()
+	return status, nil
+}
+
+// PrintFile simula la impresión de un archivo
+func (m *MockBackend) PrintFile(filePath, printer string, opts PrintOptions) error {
+	call := MockCall{Operation: MockCallPrint, Printer: printer, Options: &opts}
+	if f, err := os.Open(filePath); err == nil {
+		call.SHA256 = documentSHA256(f)
+		f.Close()
+	}
+	if info, err := os.Stat(filePath); err == nil {
+		call.SizeBytes = info.Size()
+	}
+	err := m.simulate(printer)
+	m.record(call, err)
+	if err != nil {
+		return err
+	}
+	m.logger.Infof("[MOCK] Archivo %s impreso en '%s' con opciones %+v", filePath, printer, opts)
+	return nil
+}
+
+// PrintXPS simula la impresión de un documento XPS
+func (m *MockBackend) PrintXPS(filePath, printer string, opts PrintOptions) error {
+	call := MockCall{Operation: MockCallXPS, Printer: printer, Options: &opts}
+	if f, err := os.Open(filePath); err == nil {
+		call.SHA256 = documentSHA256(f)
+		f.Close()
+	}
+	if info, err := os.Stat(filePath); err == nil {
+		call.SizeBytes = info.Size()
+	}
+	err := m.simulate(printer)
+	m.record(call, err)
+	if err != nil {
+		return err
+	}
+	m.logger.Infof("[MOCK] Documento XPS %s impreso en '%s' con opciones %+v", filePath, printer, opts)
+	return nil
+}
+
+// OpenDrawer simula la apertura del cajón
+func (m *MockBackend) OpenDrawer(printerName string, opts DrawerOptions) error {
+	err := m.simulate(printerName)
+	m.record(MockCall{Operation: MockCallDrawer, Printer: printerName, Drawer: &opts}, err)
+	if err != nil {
+		return err
+	}
+	m.logger.Infof("[MOCK] Cajón abierto en '%s' con opciones %+v", printerName, opts)
+	return nil
+}
+
+// CheckStatus simula la consulta de estado en tiempo real
+func (m *MockBackend) CheckStatus(printer string) (*PrinterReadiness, error) {
+	behavior, ok := m.behavior(printer)
+	if !ok {
+		return nil, fmt.Errorf("la impresora '%s' no existe (mock)", printer)
+	}
+	return &PrinterReadiness{
+		Online:       behavior != MockBehaviorOffline,
+		PaperOut:     behavior == MockBehaviorPaperOut,
+		PaperNearEnd: behavior == MockBehaviorPaperLow,
+		Error:        behavior == MockBehaviorFail,
+		Source:       "mock",
+	}, nil
+}
+
+// CheckDrawer simula el sensor del cajón: abierto con el comportamiento drawer-open
+func (m *MockBackend) CheckDrawer(printer string) (*DrawerStatus, error) {
+	behavior, ok := m.behavior(printer)
+	if !ok {
+		return nil, fmt.Errorf("%w: '%s' (mock)", ErrPrinterNotFound, printer)
+	}
+	if behavior == MockBehaviorOffline {
+		return nil, fmt.Errorf("la impresora '%s' está fuera de línea (mock)", printer)
+	}
+	status := newDrawerStatus(printer, false, DrawerSignalLow, "mock")
+	if behavior != MockBehaviorDrawerOpen {
+		status = newDrawerStatus(printer, true, DrawerSignalLow, "mock")
+	}
+	return status, nil
+}
+
+// WriteRaw simula el envío de datos crudos (etiquetas ZPL/EPL)
+func (m *MockBackend) WriteRaw(printer string, data []byte) error {
+	err := m.simulate(printer)
+	m.record(MockCall{Operation: MockCallRaw, Printer: printer, SizeBytes: int64(len(data)), Data: append([]byte(nil), data...)}, err)
+	if err != nil {
+		return err
+	}
+	m.logger.Infof("[MOCK] %d bytes RAW enviados a '%s'", len(data), printer)
+	return nil
+}
+
+// DrawText simula la impresión de texto con GDI; el texto queda en data
+func (m *MockBackend) DrawText(printer, text string, opts TextOptions) error {
+	err := m.simulate(printer)
+	m.record(MockCall{Operation: MockCallGDI, Printer: printer, SizeBytes: int64(len(text)), Data: []byte(text)}, err)
+	if err != nil {
+		return err
+	}
+	m.logger.Infof("[MOCK] Texto de %d bytes dibujado con GDI en '%s' con opciones %+v", len(text), printer, opts)
+	return nil
+}
+
+// DrawImage simula la impresión de una imagen con GDI; la imagen queda en data como PNG
+func (m *MockBackend) DrawImage(printer string, img image.Image, opts ImageOptions) error {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return err
+	}
+	err := m.simulate(printer)
+	m.record(MockCall{Operation: MockCallGDI, Printer: printer, SizeBytes: int64(buf.Len()), Data: buf.Bytes()}, err)
+	if err != nil {
+		return err
+	}
+	m.logger.Infof("[MOCK] Imagen de %dx%d dibujada con GDI en '%s' con opciones %+v", img.Bounds().Dx(), img.Bounds().Dy(), printer, opts)
+	return nil
+}
+
+// WriteDisplay simula el envío a un visor de cliente; el visor se registra en el campo printer
+func (m *MockBackend) WriteDisplay(name string, data []byte) error {
+	m.record(MockCall{Operation: MockCallDisplay, Printer: name, SizeBytes: int64(len(data)), Data: append([]byte(nil), data...)}, nil)
+	m.logger.Infof("[MOCK] %d bytes enviados al visor '%s'", len(data), name)
+	return nil
+}
+
+// simulate aplica el comportamiento programado para la impresora
+func (m *MockBackend) simulate(printer string) error {
+	behavior, ok := m.behavior(printer)
+	if !ok {
+		return fmt.Errorf("la impresora '%s' no existe (mock)", printer)
+	}
+	switch behavior {
+	case MockBehaviorFail:
+		return fmt.Errorf("falla simulada en la impresora '%s'", printer)
+	case MockBehaviorOffline:
+		return fmt.Errorf("la impresora '%s' está fuera de línea (mock)", printer)
+	case MockBehaviorPaperOut:
+		return fmt.Errorf("la impresora '%s' no tiene papel (mock)", printer)
+	case MockBehaviorSlow:
+		time.Sleep(m.slowDelay)
+	}
+	return nil
+}
+
+// record guarda la llamada con el resultado simulado
+func (m *MockBackend) record(call MockCall, err error) {
+	call.Time = time.Now()
+	if err != nil {
+		call.Error = err.Error()
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.calls) >= maxMockCalls {
+		m.calls = append(m.calls[:0], m.calls[len(m.calls)-maxMockCalls+1:]...)
+	}
+	m.calls = append(m.calls, call)
+}
+
+// Calls devuelve las llamadas registradas en orden de llegada, filtradas por impresora y operación
+// cuando se indican
+func (m *MockBackend) Calls(printer, operation string) []MockCall {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := []MockCall{}
+	for _, call := range m.calls {
+		if (printer == "" || call.Printer == printer) && (operation == "" || call.Operation == operation) {
+			out = append(out, call)
+		}
+	}
+	return out
+}
+
+// ResetCalls descarta las llamadas registradas
+func (m *MockBackend) ResetCalls() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = nil
+}
+
+// MockHandlers expone la programación del backend simulado a través de la API HTTP
+type MockHandlers struct {
+	Backend *MockBackend
+	Logger  *Logger
+}
+
+// MockPrintersHandler lista (GET), crea/actualiza (POST) o elimina (DELETE) impresoras simuladas
+func (h MockHandlers) MockPrintersHandler(w http.ResponseWriter, r *http.Request) {
+	h.Logger = h.Logger.ForRequest(r)
+	h.Logger.Info("Received request: /admin/mock/printers")
+
+	type MockPrinterRequest struct {
+		Name     string `json:"name"`
+		Behavior string `json:"behavior"`
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		WriteJSON(w, http.StatusOK, map[string]interface{}{"printers": h.Backend.Printers()})
+	case http.MethodPost:
+		var req MockPrinterRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			WriteErrorJSON(w, http.StatusBadRequest, "Solicitud JSON inválida", err)
+			return
+		}
+		if req.Behavior == "" {
+			req.Behavior = MockBehaviorOK
+		}
+		if err := h.Backend.SetBehavior(req.Name, req.Behavior); err != nil {
+			WriteErrorJSON(w, http.StatusBadRequest, "Impresora simulada inválida", err)
+			return
+		}
+		WriteJSON(w, http.StatusOK, map[string]interface{}{"printers": h.Backend.Printers()})
+	case http.MethodDelete:
+		name := r.URL.Query().Get("name")
+		if name == "" {
+			WriteErrorJSON(w, http.StatusBadRequest, "No se especificó la impresora", nil)
+			return
+		}
+		h.Backend.RemovePrinter(name)
+		WriteJSON(w, http.StatusOK, map[string]interface{}{"printers": h.Backend.Printers()})
+	default:
+		WriteErrorJSON(w, http.StatusMethodNotAllowed, "Método HTTP no permitido", nil)
+	}
+}
+
+// MockCallsHandler lista (GET ?printer=&operation=) o descarta (DELETE) las llamadas registradas por
+// el backend simulado, para que las pruebas del ERP verifiquen qué se imprimió
+func (h MockHandlers) MockCallsHandler(w http.ResponseWriter, r *http.Request) {
+	h.Logger = h.Logger.ForRequest(r)
+	h.Logger.Info("Received request: /admin/mock/calls")
+
+	switch r.Method {
+	case http.MethodGet:
+		calls := h.Backend.Calls(r.URL.Query().Get("printer"), r.URL.Query().Get("operation"))
+		WriteJSON(w, http.StatusOK, map[string]interface{}{"total": len(calls), "calls": calls})
+	case http.MethodDelete:
+		h.Backend.ResetCalls()
+		WriteJSON(w, http.StatusOK, map[string]interface{}{"total": 0, "calls": []MockCall{}})
+	default:
+		WriteErrorJSON(w, http.StatusMethodNotAllowed, "Método HTTP no permitido", nil)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+)
+
+// ============================
+// Autenticación Mutua TLS (mTLS)
+// ============================
+
+// configureClientAuth exige a los clientes un certificado firmado por la CA de TLS_CLIENT_CA_FILE,
+// para que solo las terminales del ERP puedan imprimir y abrir el cajón. Las solicitudes del relay
+// no pasan por este servidor: se autentican con RELAY_TOKEN.
+func configureClientAuth(server *http.Server, cfg Config) error {
+	if cfg.TLSClientCAPath == "" {
+		return nil
+	}
+	if !cfg.TLSEnabled() {
+		return fmt.Errorf("TLS_CLIENT_CA_FILE requiere HTTPS (TLS_CERT_PATH, TLS_SELF_SIGNED o ACME_DOMAINS)")
+	}
+	data, err := os.ReadFile(cfg.TLSClientCAPath)
+	if err != nil {
+		return fmt.Errorf("error al leer TLS_CLIENT_CA_FILE: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return fmt.Errorf("TLS_CLIENT_CA_FILE no contiene certificados PEM: %s", cfg.TLSClientCAPath)
+	}
+
+	if server.TLSConfig == nil {
+		server.TLSConfig = &tls.Config{}
+	}
+	server.TLSConfig.ClientCAs = pool
+	server.TLSConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	return nil
+}
+
+// ClientCommonName devuelve el nombre común del certificado con el que se autenticó el cliente
+// (vacío sin mTLS)
+func ClientCommonName(r *http.Request) string {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return ""
+	}
+	return r.TLS.PeerCertificates[0].Subject.CommonName
+}
+
+// WithClient devuelve un logger que agrega el certificado del cliente a cada línea
+func (l *Logger) WithClient(cn string) *Logger {
+	if cn == "" {
+		return l
+	}
+	return &Logger{Logger: log.New(l.Writer(), l.Prefix()+"[cliente "+cn+"] ", l.Flags()|log.Lmsgprefix)}
+}
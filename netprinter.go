@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// ============================
+// Impresoras de Red sin Controlador (RAW/JetDirect 9100)
+// ============================
+
+// NetworkPrinters asocia el nombre lógico de una impresora de red con su dirección host[:puerto].
+// Estas impresoras no se instalan en Windows: los trabajos se envían directo al puerto 9100
+// sin pasar por el spooler.
+type NetworkPrinters map[string]string
+
+// Address devuelve la dirección de la impresora si es una impresora de red
+func (n NetworkPrinters) Address(printer string) (string, bool) {
+	address, ok := n[printer]
+	return address, ok
+}
+
+// Names devuelve las impresoras de red ordenadas por nombre
+func (n NetworkPrinters) Names() []string {
+	names := make([]string, 0, len(n))
+	for name := range n {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// mergeAddresses combina PRINTER_ADDRESSES con las impresoras de red para los componentes
+// que se comunican por TCP (cajón, estado en tiempo real)
+func mergeAddresses(addresses map[string]string, network NetworkPrinters) map[string]string {
+	merged := make(map[string]string, len(addresses)+len(network))
+	for name, address := range addresses {
+		merged[name] = address
+	}
+	for name, address := range network {
+		merged[name] = address
+	}
+	return merged
+}
+
+// NetworkPrinterManager agrega las impresoras de red a las del PrinterManager real
+type NetworkPrinterManager struct {
+	Next     PrinterManager
+	Printers NetworkPrinters
+	Timeout  time.Duration
+}
+
+// ListPrinters lista las impresoras del sistema seguidas de las impresoras de red
+func (n NetworkPrinterManager) ListPrinters() ([]string, error) {
+	printers, err := n.Next.ListPrinters()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range n.Printers.Names() {
+		printers = append(printers, fmt.Sprintf("Name=%s;DriverName=RAW 9100;PortName=TCP:%s;PrinterStatus=Network;Location=Red",
+			name, n.Printers[name]))
+	}
+	return printers, nil
+}
+
+// PrinterExists considera existentes las impresoras de red configuradas; la conexión se prueba al imprimir
+func (n NetworkPrinterManager) PrinterExists(name string) (bool, error) {
+	if _, ok := n.Printers.Address(name); ok {
+		return true, nil
+	}
+	return n.Next.PrinterExists(name)
+}
+
+// DefaultPrinter delega en el administrador real
+func (n NetworkPrinterManager) DefaultPrinter() (string, error) {
+	return n.Next.DefaultPrinter()
+}
+
+// GetPrinterStatus consulta las impresoras de red con DLE EOT; si no responden se informan fuera de línea
+func (n NetworkPrinterManager) GetPrinterStatus(name string) (*PrinterStatus, error) {
+	address, ok := n.Printers.Address(name)
+	if !ok {
+		return n.Next.GetPrinterStatus(name)
+	}
+	readiness, err := QueryESCPOSStatus(address, n.Timeout)
+	return deviceStatus(name, readiness, err), nil
+}
+
+// deviceStatus arma el estado de una impresora sin spooler a partir de la respuesta a DLE EOT; si
+// no respondió se informa fuera de línea
+func deviceStatus(name string, readiness *PrinterReadiness, err error) *PrinterStatus {
+	status := &PrinterStatus{Printer: name, Status: "Normal", States: []string{}, Source: "escpos", CheckedAt: time.Now()}
+	if err != nil {
+		status.States = append(status.States, "Offline")
+	} else {
+		status.Online = readiness.Online
+		status.DoorOpen = readiness.CoverOpen
+		status.PaperOut = readiness.PaperOut
+		status.Error = readiness.Error
+		status.Device = readiness
+		for _, s := range []struct {
+			active bool
+			name   string
+		}{
+			{readiness.Error, "Error"},
+			{!readiness.Online, "Offline"},
+			{readiness.PaperOut, "PaperOut"},
+			{readiness.CoverOpen, "DoorOpen"},
+			{readiness.PaperNearEnd && !readiness.PaperOut, "PaperLow"},
+		} {
+			if s.active {
+				status.States = append(status.States, s.name)
+			}
+		}
+	}
+	if len(status.States) > 0 {
+		status.Status = status.States[0]
+	}
+	status.finish()
+	return status
+}
+
+// NetworkDocumentPrinter envía el documento tal cual al puerto RAW de las impresoras de red y delega
+// el resto en el DocumentPrinter real. Sirve para impresoras que interpretan PDF directamente o para
+// documentos ya generados en el lenguaje de la impresora (ESC/POS, ZPL).
+type NetworkDocumentPrinter struct {
+	Next     DocumentPrinter
+	Printers NetworkPrinters
+	Timeout  time.Duration
+	Logger   *Logger
+}
+
+// PrintFile imprime el archivo en la impresora indicada
+func (n NetworkDocumentPrinter) PrintFile(filePath, printer string, opts PrintOptions) error {
+	address, ok := n.Printers.Address(printer)
+	if !ok {
+		return n.Next.PrintFile(filePath, printer, opts)
+	}
+	if opts.Duplex != "" || opts.Orientation != "" || opts.PaperSize != "" || opts.Tray != "" || opts.Color != "" || opts.Quality != "" || opts.Scale != "" || opts.Pages != "" || opts.Engine != "" {
+		n.Logger.Warnf("La impresora de red '%s' recibe el documento sin procesar; se ignoran duplex, orientación, papel, bandeja, color, calidad, escala, páginas y motor", printer)
+	}
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("error al leer el documento: %w", err)
+	}
+	copies := opts.Copies
+	if copies < 1 {
+		copies = 1
+	}
+	n.Logger.Infof("Enviando %d bytes a la impresora de red '%s' (%s), %d copia(s)", len(data), printer, address, copies)
+	for i := 0; i < copies; i++ {
+		if err := WriteRawTCP(address, data, n.Timeout); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NetworkRawWriter envía los datos crudos por TCP a las impresoras de red aunque el transporte
+// configurado sea el spooler, donde esas impresoras no existen
+type NetworkRawWriter struct {
+	Next     RawWriter
+	Printers NetworkPrinters
+	Timeout  time.Duration
+}
+
+// WriteRaw envía los datos a la impresora indicada
+func (n NetworkRawWriter) WriteRaw(printer string, data []byte) error {
+	if address, ok := n.Printers.Address(printer); ok {
+		return WriteRawTCP(address, data, n.Timeout)
+	}
+	return n.Next.WriteRaw(printer, data)
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ============================
+// Documentos de Office (conversión a PDF)
+// ============================
+
+// officeFormats identifica los documentos de Office por una entrada característica del zip
+var officeFormats = []struct {
+	Entry     string
+	Extension string
+}{
+	{"word/document.xml", "docx"},
+	{"xl/workbook.xml", "xlsx"},
+	{"ppt/presentation.xml", "pptx"},
+}
+
+// odfFormats identifica los documentos OpenDocument por el contenido de la entrada mimetype
+var odfFormats = map[string]string{
+	"application/vnd.oasis.opendocument.text":         "odt",
+	"application/vnd.oasis.opendocument.spreadsheet":  "ods",
+	"application/vnd.oasis.opendocument.presentation": "odp",
+}
+
+// DetectOfficeDocument devuelve la extensión del documento si es un documento de Office (docx,
+// xlsx, pptx) u OpenDocument (odt, ods, odp). Se reconoce por el contenido: los archivos
+// recibidos se guardan como temporales sin la extensión original.
+func DetectOfficeDocument(filePath string) (string, bool) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return "", false
+	}
+	defer f.Close()
+	head := make([]byte, 4)
+	if _, err := io.ReadFull(f, head); err != nil || !bytes.Equal(head, []byte("PK\x03\x04")) {
+		return "", false
+	}
+
+	r, err := zip.OpenReader(filePath)
+	if err != nil {
+		return "", false
+	}
+	defer r.Close()
+	for _, format := range officeFormats {
+		for _, file := range r.File {
+			if file.Name == format.Entry {
+				return format.Extension, true
+			}
+		}
+	}
+	for _, file := range r.File {
+		if file.Name != "mimetype" {
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return "", false
+		}
+		mimetype, _ := io.ReadAll(io.LimitReader(rc, 128))
+		rc.Close()
+		ext, ok := odfFormats[strings.TrimSpace(string(mimetype))]
+		return ext, ok
+	}
+	return "", false
+}
+
+// OfficeConverter convierte documentos de Office a PDF con LibreOffice sin interfaz (soffice
+// --headless) antes de enviarlos a la impresora, para imprimir las órdenes de compra que llegan
+// como archivos de Word o Excel
+type OfficeConverter struct {
+	Path   string
+	Logger *Logger
+}
+
+// Convert convierte el documento a PDF en una carpeta temporal. Devuelve la ruta del PDF y la
+// función que elimina la carpeta; cada conversión usa su propio perfil de LibreOffice para que
+// varias conversiones simultáneas no se bloqueen entre sí.
+func (c OfficeConverter) Convert(filePath, ext string, run toolRun) (string, func(), error) {
+	dir, err := os.MkdirTemp("", "office-*")
+	if err != nil {
+		return "", nil, fmt.Errorf("error al crear la carpeta de conversión: %w", err)
+	}
+	cleanup := func() {
+		if err := os.RemoveAll(dir); err != nil {
+			c.Logger.Errorf("Error al eliminar la carpeta de conversión: %v", err)
+		}
+	}
+
+	// LibreOffice reconoce el formato por la extensión y nombra el PDF igual que el documento
+	source := filepath.Join(dir, "documento."+ext)
+	if err := copyFile(filePath, source); err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("error al preparar el documento para la conversión: %w", err)
+	}
+	args := []string{
+		"--headless", "--norestore", "--nolockcheck",
+		"-env:UserInstallation=" + fileURL(filepath.Join(dir, "perfil")),
+		"--convert-to", "pdf", "--outdir", dir, source,
+	}
+	c.Logger.Infof("Convirtiendo el documento %s a PDF con LibreOffice", ext)
+	if err := runExternalTool("LibreOffice", c.Path, args, run); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+
+	pdf := filepath.Join(dir, "documento.pdf")
+	if _, err := os.Stat(pdf); err != nil {
+		cleanup()
+		return "", nil, permanent(fmt.Errorf("%w: LibreOffice no generó el PDF del documento %s", ErrInvalidDocument, ext))
+	}
+	return pdf, cleanup, nil
+}
+
+// fileURL devuelve la URL file:// de una ruta local, también para las rutas con unidad de Windows
+func fileURL(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	path = filepath.ToSlash(path)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return (&url.URL{Scheme: "file", Path: path}).String()
+}
@@ -0,0 +1,438 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ============================
+// Documento OpenAPI de la API /v1
+// ============================
+
+// apiSchema es un esquema JSON escrito a mano, para las respuestas que no tienen un tipo propio
+type apiSchema map[string]interface{}
+
+// apiOperation describe un endpoint para el documento OpenAPI. Request y Response son valores del
+// tipo que se decodifica o se devuelve (o un apiSchema); su esquema se genera a partir de las
+// etiquetas json, por lo que el documento sigue a los tipos sin mantenerlo a mano.
+type apiOperation struct {
+	Method      string
+	Path        string
+	Tag         string
+	Summary     string
+	Query       []string
+	Request     interface{}
+	Multipart   []string
+	Response    interface{}
+	Status      int
+	Errors      []int
+	Admin       bool
+	Licensed    bool
+	Description string
+}
+
+// Códigos de error comunes de los endpoints que imprimen (ver jobErrorStatus)
+var printErrors = []int{http.StatusBadRequest, http.StatusForbidden, http.StatusConflict,
+	http.StatusUnprocessableEntity, http.StatusInternalServerError, http.StatusGatewayTimeout}
+
+// errorDescriptions explica cada código de error en el documento
+var errorDescriptions = map[int]string{
+	http.StatusBadRequest:          "Solicitud inválida (JSON, opciones o impresora faltante)",
+	http.StatusUnauthorized:        "Token administrativo o sesión inválidos",
+	http.StatusForbidden:           "Licencia inválida, descarga bloqueada por DOWNLOAD_ALLOWED_HOSTS, dirección rechazada por IP_ALLOWLIST o API administrativa deshabilitada",
+	http.StatusNotFound:            "La impresora, el trabajo o el recurso no existe",
+	http.StatusMethodNotAllowed:    "Método HTTP no permitido",
+	http.StatusConflict:            "La impresora no está lista, su perfil no admite el trabajo o el trabajo ya terminó",
+	http.StatusUnprocessableEntity: "El documento no es válido (no es un PDF o supera el tamaño máximo)",
+	http.StatusTooManyRequests:     "Se superó el límite de solicitudes; reintentar después de Retry-After segundos",
+	http.StatusInternalServerError: "Error de impresión o interno; details tiene el motivo",
+	http.StatusNotImplemented:      "No disponible con el backend de impresión actual",
+	http.StatusGatewayTimeout:      "La descarga del documento superó su tiempo máximo",
+}
+
+// apiOperations son los endpoints documentados; solo se publican los registrados en el servidor
+var apiOperations = []apiOperation{
+	{Method: "GET", Path: "/health", Tag: "agente", Summary: "Estado del servidor",
+		Response: apiSchema{"type": "object", "properties": apiSchema{"running": apiSchema{"type": "boolean"}, "address": apiSchema{"type": "string"}}}},
+	{Method: "GET", Path: "/capabilities", Tag: "agente", Summary: "Capacidades del agente instalado", Response: Capabilities{}},
+	{Method: "GET", Path: "/tls/ca.pem", Tag: "agente", Summary: "Descargar la CA local del certificado autofirmado",
+		Errors: []int{http.StatusNotFound}, Description: "Solo con TLS_SELF_SIGNED. Devuelve el certificado PEM para importarlo en los navegadores."},
+	{Method: "POST", Path: "/session", Tag: "agente", Summary: "Registrar un cliente", Request: SessionRequest{}, Status: http.StatusCreated,
+		Response: apiSchema{"type": "object", "properties": apiSchema{"session_token": apiSchema{"type": "string"}, "session": refOf(ClientSession{}), "capabilities": refOf(Capabilities{})}},
+		Errors:   []int{http.StatusBadRequest}},
+	{Method: "GET", Path: "/session", Tag: "agente", Summary: "Consultar y renovar la sesión (cabecera X-Session-Token)",
+		Response: apiSchema{"type": "object", "properties": apiSchema{"session": refOf(ClientSession{}), "capabilities": refOf(Capabilities{})}},
+		Errors:   []int{http.StatusUnauthorized}},
+	{Method: "DELETE", Path: "/session", Tag: "agente", Summary: "Cerrar la sesión", Errors: []int{http.StatusUnauthorized}},
+
+	{Method: "POST", Path: "/print", Tag: "impresión", Summary: "Imprimir un PDF desde una URL o en base64", Request: PrintRequest{}, Response: JobResponse{},
+		Errors: printErrors, Licensed: true,
+		Description: "Responde 202 si el trabajo quedó retenido por falta de papel (PAPER_HOLD). Con document_type la respuesta incluye jobs con un trabajo por salida."},
+	{Method: "POST", Path: "/print-file", Tag: "impresión", Summary: "Imprimir un PDF subido como multipart/form-data",
+		Multipart: []string{"printer", "webhook_url", "copies", "duplex", "orientation", "paper_size", "tray", "color", "quality", "scale", "pages", "engine", "stamp", "stamp_image", "stamp_position", "copy_label", "retries", "retry_delay"},
+		Response:  JobResponse{}, Errors: printErrors, Licensed: true},
+	{Method: "POST", Path: "/print-batch", Tag: "impresión", Summary: "Unir varios PDF en un único trabajo (JSON con documents o multipart con varios file)",
+		Request: PrintBatchRequest{}, Response: JobResponse{}, Errors: printErrors, Licensed: true},
+	{Method: "POST", Path: "/estimate", Tag: "impresión", Summary: "Estimar páginas y papel sin imprimir", Request: EstimateRequest{}, Response: PrintEstimate{},
+		Errors: []int{http.StatusBadRequest, http.StatusUnprocessableEntity}},
+	{Method: "POST", Path: "/pdf-info", Tag: "impresión", Summary: "Analizar un PDF (JSON con url o data, o multipart con file)", Request: PDFInfoRequest{}, Response: PDFInspection{},
+		Errors: []int{http.StatusBadRequest, http.StatusForbidden, http.StatusUnprocessableEntity}},
+	{Method: "POST", Path: "/validate-print", Tag: "impresión", Summary: "Verificar impresora y documento sin imprimir", Request: ValidatePrintRequest{}, Response: PrintValidation{},
+		Errors: []int{http.StatusBadRequest}},
+	{Method: "POST", Path: "/open-box", Tag: "impresión", Summary: "Abrir el cajón monedero", Request: OpenDrawerRequest{}, Response: JobResponse{},
+		Errors: []int{http.StatusBadRequest, http.StatusForbidden, http.StatusInternalServerError}, Licensed: true},
+	{Method: "POST", Path: "/print-label", Tag: "impresión", Summary: "Imprimir una etiqueta ZPL o EPL", Request: LabelRequest{}, Response: JobResponse{},
+		Errors: printErrors, Licensed: true},
+	{Method: "POST", Path: "/print-receipt", Tag: "impresión", Summary: "Imprimir un recibo ESC/POS", Request: Receipt{}, Response: JobResponse{},
+		Errors: printErrors, Licensed: true},
+	{Method: "POST", Path: "/print-order", Tag: "impresión", Summary: "Repartir un pedido en comandas por impresora de cocina según la categoría de cada producto",
+		Request: KitchenOrder{}, Response: apiSchema{"type": "object", "properties": apiSchema{"message": apiSchema{"type": "string"}, "jobs": apiSchema{"type": "array", "items": apiSchema{"type": "object"}}}},
+		Errors: printErrors, Licensed: true},
+	{Method: "POST", Path: "/printer-command", Tag: "impresión", Summary: "Enviar un corte, avance o pitido", Request: PrinterCommandRequest{}, Response: JobResponse{},
+		Errors: printErrors, Licensed: true},
+	{Method: "POST", Path: "/print-image", Tag: "impresión", Summary: "Imprimir una imagen PNG, JPEG o GIF (JSON o multipart con el campo file)",
+		Request: ImageRequest{}, Response: JobResponse{}, Errors: printErrors, Licensed: true},
+	{Method: "POST", Path: "/print-text", Tag: "impresión", Summary: "Imprimir texto plano", Request: TextRequest{}, Response: JobResponse{},
+		Errors: printErrors, Licensed: true},
+	{Method: "GET", Path: "/display", Tag: "impresión", Summary: "Visores de cliente y el texto que muestran",
+		Response: apiSchema{"type": "object", "properties": apiSchema{"displays": apiSchema{"type": "array", "items": refOf(DisplayStatus{})}}}, Licensed: true},
+	{Method: "POST", Path: "/display", Tag: "impresión", Summary: "Mostrar texto en un visor de cliente", Request: DisplayRequest{},
+		Response: apiSchema{"type": "object", "properties": apiSchema{"display": apiSchema{"type": "string"}, "lines": apiSchema{"type": "array", "items": apiSchema{"type": "string"}}}},
+		Errors:   []int{http.StatusBadRequest, http.StatusNotFound, http.StatusBadGateway}, Licensed: true},
+	{Method: "POST", Path: "/display/clear", Tag: "impresión", Summary: "Borrar un visor de cliente", Request: DisplayRequest{},
+		Response: apiSchema{"type": "object", "properties": apiSchema{"display": apiSchema{"type": "string"}, "message": apiSchema{"type": "string"}}},
+		Errors:   []int{http.StatusBadRequest, http.StatusNotFound, http.StatusBadGateway}, Licensed: true},
+
+	{Method: "GET", Path: "/list-printers", Tag: "impresoras", Summary: "Listar las impresoras instaladas",
+		Response: apiSchema{"type": "object", "properties": apiSchema{"printers": apiSchema{"type": "array", "items": apiSchema{"type": "object", "additionalProperties": apiSchema{"type": "string"}}}}},
+		Errors:   []int{http.StatusInternalServerError}},
+	{Method: "GET", Path: "/default-printer", Tag: "impresoras", Summary: "Impresora predeterminada",
+		Response: apiSchema{"type": "object", "properties": apiSchema{"printer": apiSchema{"type": "string"}, "source": apiSchema{"type": "string"}}},
+		Errors:   []int{http.StatusNotFound}},
+	{Method: "PUT", Path: "/default-printer", Tag: "impresoras", Summary: "Cambiar la impresora predeterminada del sistema", Admin: true,
+		Request:  DefaultPrinterRequest{},
+		Response: apiSchema{"type": "object", "properties": apiSchema{"printer": apiSchema{"type": "string"}, "previous": apiSchema{"type": "string"}, "warning": apiSchema{"type": "string"}}},
+		Errors:   []int{http.StatusBadRequest, http.StatusNotFound, http.StatusNotImplemented, http.StatusInternalServerError}},
+	{Method: "GET", Path: "/printers/health", Tag: "impresoras", Summary: "Último estado conocido de las impresoras monitoreadas (HEALTH_CHECK_PRINTERS)",
+		Response: apiSchema{"type": "object", "properties": apiSchema{"printers": apiSchema{"type": "array", "items": refOf(PrinterHealth{})}}}},
+	{Method: "GET", Path: "/printers/{name}/status", Tag: "impresoras", Summary: "Estado actual de una impresora", Response: PrinterStatus{},
+		Errors: []int{http.StatusNotFound, http.StatusInternalServerError}},
+	{Method: "GET", Path: "/printers/{name}/queue", Tag: "impresoras", Summary: "Trabajos en la cola de la impresora",
+		Response: apiSchema{"type": "object", "properties": apiSchema{"printer": apiSchema{"type": "string"}, "jobs": apiSchema{"type": "array", "items": refOf(QueueJob{})}}},
+		Errors:   []int{http.StatusNotFound, http.StatusNotImplemented, http.StatusInternalServerError}},
+	{Method: "GET", Path: "/printers/{name}/drawer-status", Tag: "impresoras", Summary: "Estado del cajón de efectivo (abierto o cerrado) según el sensor de la impresora", Response: DrawerStatus{},
+		Errors: []int{http.StatusNotFound, http.StatusNotImplemented, http.StatusBadGateway}},
+	{Method: "POST", Path: "/printers/{name}/queue/{action}", Tag: "impresoras", Summary: "Pausar, reanudar o vaciar la cola (pause, resume, purge)", Admin: true,
+		Response: apiSchema{"type": "object", "properties": apiSchema{"printer": apiSchema{"type": "string"}, "action": apiSchema{"type": "string"}, "removed": apiSchema{"type": "integer"}}},
+		Errors:   []int{http.StatusNotFound, http.StatusNotImplemented, http.StatusInternalServerError}},
+	{Method: "POST", Path: "/printers/{name}/logo", Tag: "impresoras", Summary: "Guardar el logo en la memoria de una impresora térmica (JSON o multipart con el campo file)", Admin: true,
+		Request: LogoRequest{}, Response: JobResponse{}, Errors: printErrors},
+	{Method: "POST", Path: "/admin/printers", Tag: "administración", Summary: "Instalar una impresora de red con un controlador instalado", Admin: true,
+		Request: PrinterSpec{}, Status: http.StatusCreated, Response: apiSchema{"type": "object", "properties": apiSchema{"printer": apiSchema{"type": "string"}, "action": apiSchema{"type": "string"}}},
+		Errors: []int{http.StatusBadRequest, http.StatusConflict, http.StatusNotImplemented, http.StatusInternalServerError}},
+	{Method: "PATCH", Path: "/admin/printers/{name}", Tag: "administración", Summary: "Renombrar una impresora o cambiar su puerto", Admin: true,
+		Request: PrinterChanges{}, Response: apiSchema{"type": "object", "properties": apiSchema{"printer": apiSchema{"type": "string"}, "action": apiSchema{"type": "string"}}},
+		Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict, http.StatusNotImplemented, http.StatusInternalServerError}},
+	{Method: "DELETE", Path: "/admin/printers/{name}", Tag: "administración", Summary: "Eliminar una impresora", Admin: true,
+		Response: apiSchema{"type": "object", "properties": apiSchema{"printer": apiSchema{"type": "string"}, "action": apiSchema{"type": "string"}}},
+		Errors:   []int{http.StatusNotFound, http.StatusNotImplemented, http.StatusInternalServerError}},
+
+	{Method: "GET", Path: "/jobs", Tag: "trabajos", Summary: "Historial de trabajos", Query: []string{"printer", "status", "kind", "since", "until", "limit", "offset"},
+		Response: JobListResponse{}, Errors: []int{http.StatusBadRequest, http.StatusInternalServerError}},
+	{Method: "GET", Path: "/jobs/{id}", Tag: "trabajos", Summary: "Consultar un trabajo", Response: PrintJob{},
+		Errors: []int{http.StatusNotFound, http.StatusInternalServerError}},
+	{Method: "DELETE", Path: "/jobs/{id}", Tag: "trabajos", Summary: "Cancelar un trabajo pendiente, retenido o en curso", Response: JobResponse{},
+		Errors:      []int{http.StatusNotFound, http.StatusConflict, http.StatusInternalServerError},
+		Description: "Responde 202 mientras la cancelación de un trabajo en curso se completa."},
+	{Method: "GET", Path: "/reports/usage", Tag: "trabajos", Summary: "Trabajos y páginas por impresora y por día o mes", Query: []string{"from", "to", "group", "printer", "format"},
+		Response: UsageReport{}, Errors: []int{http.StatusBadRequest, http.StatusInternalServerError},
+		Description: "Con format=csv (o Accept: text/csv) devuelve una planilla con period, printer, jobs, pages y failed."},
+	{Method: "POST", Path: "/jobs/{id}/reprint", Tag: "trabajos", Summary: "Reimprimir el documento de un trabajo anterior", Request: ReprintRequest{}, Response: JobResponse{},
+		Errors: append([]int{http.StatusNotFound}, printErrors...), Licensed: true},
+
+	{Method: "GET", Path: "/ws", Tag: "eventos", Summary: "Eventos en tiempo real por WebSocket", Query: []string{"types"},
+		Response: Event{}, Status: http.StatusSwitchingProtocols},
+	{Method: "GET", Path: "/events", Tag: "eventos", Summary: "Eventos en tiempo real por Server-Sent Events (text/event-stream)", Query: []string{"types", "last_event_id"},
+		Response: Event{}, Errors: []int{http.StatusBadRequest}},
+
+	{Method: "GET", Path: "/admin/cache", Tag: "administración", Summary: "Documentos en caché", Admin: true,
+		Response: apiSchema{"type": "object", "properties": apiSchema{"documents": apiSchema{"type": "array", "items": refOf(CacheEntry{})}, "size": apiSchema{"type": "integer"},
+			"max_size": apiSchema{"type": "integer"}, "hits": apiSchema{"type": "integer"}, "misses": apiSchema{"type": "integer"}}}},
+	{Method: "DELETE", Path: "/admin/cache", Tag: "administración", Summary: "Vaciar la caché o quitar un documento", Query: []string{"url"}, Admin: true,
+		Response: apiSchema{"type": "object", "properties": apiSchema{"removed": apiSchema{"type": "integer"}}}},
+	{Method: "GET", Path: "/admin/sessions", Tag: "administración", Summary: "Clientes registrados", Admin: true,
+		Response: apiSchema{"type": "object", "properties": apiSchema{"sessions": apiSchema{"type": "array", "items": refOf(ClientSession{})}}}},
+	{Method: "GET", Path: "/admin/summary", Tag: "administración", Summary: "Resumen de trabajos y errores por impresora de las últimas 24 horas", Admin: true,
+		Response: DashboardSummary{}},
+	{Method: "GET", Path: "/admin/log", Tag: "administración", Summary: "Últimas líneas de app.log (text/plain)", Query: []string{"lines"}, Admin: true,
+		Errors: []int{http.StatusBadRequest}},
+	{Method: "GET", Path: "/admin/logs", Tag: "administración", Summary: "Últimas líneas del log filtradas por nivel (text/plain)", Query: []string{"tail", "level"}, Admin: true,
+		Errors: []int{http.StatusBadRequest, http.StatusInternalServerError}},
+	{Method: "GET", Path: "/admin/logs/files", Tag: "administración", Summary: "Listar el log actual y los archivos rotados", Admin: true,
+		Errors: []int{http.StatusInternalServerError}},
+	{Method: "GET", Path: "/admin/logs/files/{name}", Tag: "administración", Summary: "Descargar un archivo del log", Admin: true,
+		Errors: []int{http.StatusNotFound, http.StatusInternalServerError}},
+	{Method: "GET", Path: "/admin/runtime", Tag: "administración", Summary: "Memoria, goroutines, archivos temporales y colas del proceso", Query: []string{"gc"}, Admin: true,
+		Response: RuntimeInfo{}},
+	{Method: "GET", Path: "/debug/pprof/", Tag: "administración", Summary: "Perfiles de Go (pprof): heap, goroutine, profile, trace, ...", Admin: true,
+		Description: "Deshabilitado con PPROF_ENABLED=false. Los perfiles se piden sin el prefijo /v1, p. ej. /debug/pprof/heap."},
+	{Method: "GET", Path: "/admin/update", Tag: "administración", Summary: "Versión instalada, versión disponible y estado de la actualización", Admin: true,
+		Response: UpdateStatus{}, Description: "Disponible con UPDATE_URL y una compilación con clave pública de actualizaciones."},
+	{Method: "POST", Path: "/admin/update/check", Tag: "administración", Summary: "Buscar una versión nueva en el manifiesto", Admin: true,
+		Response: UpdateStatus{}, Errors: []int{http.StatusConflict, http.StatusBadGateway}},
+	{Method: "POST", Path: "/admin/update/install", Tag: "administración", Summary: "Instalar la versión disponible y reiniciar el agente", Admin: true,
+		Status: http.StatusAccepted, Response: UpdateStatus{}, Errors: []int{http.StatusConflict},
+		Description: "La instalación continúa en segundo plano: espera a que terminen los trabajos en curso, verifica la firma y reemplaza el ejecutable."},
+}
+
+// typeRef es un tipo anidado en un apiSchema
+type typeRef struct{ value interface{} }
+
+// refOf anida el esquema del tipo en un apiSchema; los structs se referencian por nombre
+func refOf(v interface{}) typeRef {
+	return typeRef{value: v}
+}
+
+// openAPIBuilder genera los esquemas de los tipos a partir de sus etiquetas json
+type openAPIBuilder struct {
+	schemas map[string]interface{}
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+var timeType = reflect.TypeOf(time.Time{})
+
+// schema devuelve el esquema del valor: los apiSchema se usan tal cual y los structs con nombre se
+// agregan a components.schemas
+func (b *openAPIBuilder) schema(v interface{}) interface{} {
+	switch s := v.(type) {
+	case apiSchema:
+		return b.resolve(s)
+	case typeRef:
+		return b.typeSchema(reflect.TypeOf(s.value))
+	}
+	return b.typeSchema(reflect.TypeOf(v))
+}
+
+// resolve reemplaza los typeRef anidados en un apiSchema
+func (b *openAPIBuilder) resolve(s apiSchema) apiSchema {
+	out := make(apiSchema, len(s))
+	for k, v := range s {
+		switch inner := v.(type) {
+		case apiSchema:
+			out[k] = b.resolve(inner)
+		case typeRef:
+			out[k] = b.schema(inner)
+		default:
+			out[k] = v
+		}
+	}
+	return out
+}
+
+func (b *openAPIBuilder) typeSchema(t reflect.Type) interface{} {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return apiSchema{"type": "string", "format": "date-time"}
+	case t == durationType:
+		return apiSchema{"type": "integer"}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return apiSchema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return apiSchema{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return apiSchema{"type": "number"}
+	case reflect.String:
+		return apiSchema{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return apiSchema{"type": "string", "format": "byte"}
+		}
+		return apiSchema{"type": "array", "items": b.typeSchema(t.Elem())}
+	case reflect.Map:
+		return apiSchema{"type": "object", "additionalProperties": b.typeSchema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.structSchema(t)
+		}
+		ref := apiSchema{"$ref": "#/components/schemas/" + t.Name()}
+		if _, done := b.schemas[t.Name()]; !done {
+			// Se reserva el nombre antes de recorrer los campos por los tipos recursivos
+			b.schemas[t.Name()] = apiSchema{}
+			b.schemas[t.Name()] = b.structSchema(t)
+		}
+		return ref
+	}
+	return apiSchema{}
+}
+
+// structSchema recorre los campos exportados como lo hace encoding/json (los embebidos sin nombre
+// se aplanan)
+func (b *openAPIBuilder) structSchema(t reflect.Type) apiSchema {
+	properties := apiSchema{}
+	b.addFields(t, properties)
+	return apiSchema{"type": "object", "properties": properties}
+}
+
+func (b *openAPIBuilder) addFields(t reflect.Type, properties apiSchema) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		name, _, _ := strings.Cut(tag, ",")
+		if name == "-" || (!f.IsExported() && !f.Anonymous) {
+			continue
+		}
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			for ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				b.addFields(ft, properties)
+				continue
+			}
+		}
+		if name == "" {
+			name = f.Name
+		}
+		properties[name] = b.typeSchema(f.Type)
+	}
+}
+
+// pathParamPattern encuentra los parámetros {name} de las rutas
+var pathParamPattern = regexp.MustCompile(`\{([a-z_]+)\}`)
+
+// BuildOpenAPI genera el documento OpenAPI 3 de los endpoints registrados en el servidor
+func BuildOpenAPI(mux *routeMux) map[string]interface{} {
+	b := &openAPIBuilder{schemas: map[string]interface{}{}}
+	errorRef := b.schema(ErrorResponse{})
+	// El código de error se documenta con sus valores posibles
+	b.schemas["ErrorResponse"].(apiSchema)["properties"].(apiSchema)["code"] = apiSchema{"type": "string", "enum": errorCodes()}
+	paths := map[string]apiSchema{}
+
+	for _, op := range apiOperations {
+		if !mux.Registered(op.Path) {
+			continue
+		}
+		operation := apiSchema{
+			"tags":        []string{op.Tag},
+			"summary":     op.Summary,
+			"operationId": operationID(op.Method, op.Path),
+		}
+		if op.Description != "" {
+			operation["description"] = op.Description
+		}
+
+		var params []apiSchema
+		for _, m := range pathParamPattern.FindAllStringSubmatch(op.Path, -1) {
+			params = append(params, apiSchema{"name": m[1], "in": "path", "required": true, "schema": apiSchema{"type": "string"}})
+		}
+		for _, q := range op.Query {
+			params = append(params, apiSchema{"name": q, "in": "query", "schema": apiSchema{"type": "string"}})
+		}
+		if len(params) > 0 {
+			operation["parameters"] = params
+		}
+
+		switch {
+		case op.Request != nil:
+			operation["requestBody"] = apiSchema{"required": true, "content": apiSchema{
+				"application/json": apiSchema{"schema": b.schema(op.Request)},
+			}}
+		case len(op.Multipart) > 0:
+			properties := apiSchema{}
+			for _, field := range op.Multipart {
+				properties[field] = apiSchema{"type": "string"}
+			}
+			properties["file"] = apiSchema{"type": "string", "format": "binary"}
+			operation["requestBody"] = apiSchema{"required": true, "content": apiSchema{
+				"multipart/form-data": apiSchema{"schema": apiSchema{"type": "object", "properties": properties, "required": []string{"file"}}},
+			}}
+		}
+
+		status := op.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		success := apiSchema{"description": http.StatusText(status)}
+		if op.Response != nil {
+			success["content"] = apiSchema{"application/json": apiSchema{"schema": b.schema(op.Response)}}
+		}
+		responses := apiSchema{strconv.Itoa(status): success}
+		errors := append([]int(nil), op.Errors...)
+		if op.Licensed {
+			errors = append(errors, http.StatusForbidden)
+		}
+		if mux.limits.Limits(op.Path) {
+			errors = append(errors, http.StatusTooManyRequests)
+		}
+		if op.Admin {
+			errors = append(errors, http.StatusUnauthorized, http.StatusForbidden)
+			operation["security"] = []apiSchema{{"adminToken": []string{}}}
+		}
+		for _, code := range append(errors, http.StatusMethodNotAllowed) {
+			responses[strconv.Itoa(code)] = apiSchema{
+				"description": errorDescriptions[code],
+				"content":     apiSchema{"application/json": apiSchema{"schema": errorRef}},
+			}
+		}
+		operation["responses"] = responses
+
+		if paths[op.Path] == nil {
+			paths[op.Path] = apiSchema{}
+		}
+		paths[op.Path][strings.ToLower(op.Method)] = operation
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": apiSchema{
+			"title":       "PrinterMatiasERP",
+			"version":     agentVersion,
+			"description": "Agente de impresión local. Las rutas sin /v1 se mantienen como alias de la versión 1.",
+		},
+		// Relativo al documento: sirve igual con HTTP, HTTPS o detrás del relay
+		"servers": []apiSchema{{"url": apiPrefix}},
+		"paths":   paths,
+		"components": apiSchema{
+			"schemas": b.schemas,
+			"securitySchemes": apiSchema{
+				"adminToken": apiSchema{"type": "http", "scheme": "bearer", "description": "ADMIN_TOKEN (también se acepta en X-Admin-Token)"},
+			},
+		},
+	}
+}
+
+// operationID arma un identificador estable para los generadores de clientes (p. ej. getPrintersNameStatus)
+func operationID(method, path string) string {
+	var sb strings.Builder
+	sb.WriteString(strings.ToLower(method))
+	for _, part := range strings.FieldsFunc(path, func(r rune) bool { return r == '/' || r == '-' || r == '{' || r == '}' || r == '_' }) {
+		sb.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return sb.String()
+}
+
+// OpenAPIHandler sirve el documento generado al iniciar el servidor (GET /v1/openapi.json)
+type OpenAPIHandler struct {
+	Document map[string]interface{}
+	Logger   *Logger
+}
+
+func (h OpenAPIHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.Logger.ForRequest(r).Info("Received request: /openapi.json")
+	if r.Method != http.MethodGet {
+		WriteErrorJSON(w, http.StatusMethodNotAllowed, "Método HTTP no permitido", nil)
+		return
+	}
+	WriteJSON(w, http.StatusOK, h.Document)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// ============================
+// Conexión Saliente (Proxy y Certificados)
+// ============================
+
+// OutboundConfig configura la conexión de las descargas hacia el ERP. Se configura en el agente
+// porque el servicio de Windows no hereda el proxy del usuario ni Go usa la configuración de
+// Internet Explorer/WinINET.
+type OutboundConfig struct {
+	HTTPProxy  string
+	HTTPSProxy string
+	NoProxy    string
+	// CAFile es un archivo PEM con las autoridades privadas que se suman a las del sistema
+	CAFile string
+	// InsecureTLS desactiva la verificación de certificados (solo para diagnóstico)
+	InsecureTLS bool
+}
+
+// LoadOutboundConfig carga la configuración de proxy y certificados
+func LoadOutboundConfig() OutboundConfig {
+	return OutboundConfig{
+		HTTPProxy:   getEnv("HTTP_PROXY", ""),
+		HTTPSProxy:  getEnv("HTTPS_PROXY", ""),
+		NoProxy:     getEnv("NO_PROXY", ""),
+		CAFile:      getEnv("DOWNLOAD_CA_FILE", ""),
+		InsecureTLS: getEnvAsBool("DOWNLOAD_TLS_INSECURE", false),
+	}
+}
+
+// proxyConfig devuelve la configuración de proxy en el formato de net/http
+func (o OutboundConfig) proxyConfig() *httpproxy.Config {
+	return &httpproxy.Config{HTTPProxy: o.HTTPProxy, HTTPSProxy: o.HTTPSProxy, NoProxy: o.NoProxy}
+}
+
+// proxyHosts devuelve los servidores proxy configurados, a los que el agente siempre puede
+// conectarse aunque tengan una dirección interna
+func (o OutboundConfig) proxyHosts() map[string]bool {
+	hosts := make(map[string]bool)
+	for _, raw := range []string{o.HTTPProxy, o.HTTPSProxy} {
+		if raw == "" {
+			continue
+		}
+		if !strings.Contains(raw, "://") {
+			raw = "http://" + raw
+		}
+		if u, err := url.Parse(raw); err == nil && u.Hostname() != "" {
+			hosts[strings.ToLower(u.Hostname())] = true
+		}
+	}
+	return hosts
+}
+
+// apply configura el proxy y los certificados del transporte
+func (o OutboundConfig) apply(transport *http.Transport) error {
+	for key, raw := range map[string]string{"HTTP_PROXY": o.HTTPProxy, "HTTPS_PROXY": o.HTTPSProxy} {
+		if raw == "" {
+			continue
+		}
+		if !strings.Contains(raw, "://") {
+			raw = "http://" + raw
+		}
+		if u, err := url.Parse(raw); err != nil || u.Host == "" {
+			return fmt.Errorf("%s inválido: %s", key, raw)
+		}
+	}
+	proxy := o.proxyConfig().ProxyFunc()
+	transport.Proxy = func(req *http.Request) (*url.URL, error) {
+		return proxy(req.URL)
+	}
+
+	if o.CAFile == "" && !o.InsecureTLS {
+		return nil
+	}
+	tlsConfig := &tls.Config{InsecureSkipVerify: o.InsecureTLS}
+	if o.CAFile != "" {
+		pem, err := os.ReadFile(o.CAFile)
+		if err != nil {
+			return fmt.Errorf("error al leer DOWNLOAD_CA_FILE: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("DOWNLOAD_CA_FILE no contiene certificados PEM válidos: %s", o.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	transport.TLSClientConfig = tlsConfig
+	return nil
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ============================
+// Retención de Trabajos por Falta de Papel
+// ============================
+
+// ErrJobHeld indica que el trabajo quedó retenido hasta que la impresora vuelva a tener papel
+var ErrJobHeld = errors.New("trabajo retenido: la impresora no tiene papel")
+
+// heldJob es un trabajo en espera junto con la función que lo imprime
+type heldJob struct {
+	job *PrintJob
+	fn  func() error
+}
+
+// PaperHold retiene la cola de una impresora cuando se queda sin papel y reanuda los trabajos
+// pendientes, en orden, cuando el papel se repone (p. ej. durante un cambio de rollo).
+type PaperHold struct {
+	Checker StatusChecker
+	Poll    time.Duration
+	MaxWait time.Duration
+	Runner  *JobRunner
+	Logger  *Logger
+
+	mu     sync.Mutex
+	queues map[string][]heldJob
+	done   chan struct{}
+}
+
+// NewPaperHold crea el retenedor con el intervalo de consulta y la espera máxima indicados
+func NewPaperHold(checker StatusChecker, pollSeconds, maxWaitMinutes int, logger *Logger) *PaperHold {
+	return &PaperHold{
+		Checker: checker,
+		Poll:    time.Duration(pollSeconds) * time.Second,
+		MaxWait: time.Duration(maxWaitMinutes) * time.Minute,
+		Logger:  logger,
+		queues:  make(map[string][]heldJob),
+		done:    make(chan struct{}),
+	}
+}
+
+// IsHeld indica si la cola de la impresora está retenida
+func (p *PaperHold) IsHeld(printer string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, held := p.queues[printer]
+	return held
+}
+
+// Pending devuelve la cantidad de trabajos retenidos por impresora
+func (p *PaperHold) Pending() map[string]int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	pending := make(map[string]int, len(p.queues))
+	for printer, queue := range p.queues {
+		pending[printer] = len(queue)
+	}
+	return pending
+}
+
+// ShouldHold decide si el error de impresión se debe a la falta de papel. Si la verificación previa
+// no lo determinó, se consulta el estado de la impresora.
+func (p *PaperHold) ShouldHold(printer string, err error) bool {
+	var notReady *PrinterNotReadyError
+	if errors.As(err, &notReady) {
+		return notReady.PaperOut
+	}
+	readiness, serr := p.Checker.CheckStatus(printer)
+	return serr == nil && readiness.PaperOut
+}
+
+// Hold agrega el trabajo al final de la cola de la impresora; la primera retención inicia el monitoreo
+func (p *PaperHold) Hold(job *PrintJob, fn func() error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	queue, held := p.queues[job.Printer]
+	p.queues[job.Printer] = append(queue, heldJob{job: job, fn: fn})
+	if !held {
+		p.Logger.Warnf("Impresora '%s' sin papel: se retiene su cola hasta que se reponga", job.Printer)
+		go p.monitor(job.Printer)
+	}
+}
+
+// Remove quita un trabajo retenido de su cola y lo devuelve; nil si no está retenido o si ya se
+// está reanudando
+func (p *PaperHold) Remove(jobID string) *PrintJob {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, running := runningJobs.Load(jobID); running {
+		return nil
+	}
+	for printer, queue := range p.queues {
+		for i, h := range queue {
+			if h.job.ID != jobID {
+				continue
+			}
+			p.queues[printer] = append(queue[:i:i], queue[i+1:]...)
+			return h.job
+		}
+	}
+	return nil
+}
+
+// Close detiene el monitoreo y da por fallidos los trabajos retenidos
+func (p *PaperHold) Close() error {
+	close(p.done)
+	p.mu.Lock()
+	queues := p.queues
+	p.queues = make(map[string][]heldJob)
+	p.mu.Unlock()
+
+	for _, queue := range queues {
+		for _, h := range queue {
+			p.Runner.finish(h.job, errors.New("el agente se detuvo con el trabajo retenido por falta de papel"))
+		}
+	}
+	return nil
+}
+
+// monitor consulta el estado de la impresora hasta que vuelva a tener papel y reanuda su cola.
+// Si se supera la espera máxima, los trabajos retenidos se dan por fallidos.
+func (p *PaperHold) monitor(printer string) {
+	defer recoverCrash()
+	ticker := time.NewTicker(p.Poll)
+	defer ticker.Stop()
+	since := time.Now()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-p.done:
+			return
+		}
+
+		if p.MaxWait > 0 && time.Since(since) > p.MaxWait {
+			p.Logger.Errorf("Impresora '%s' sin papel por más de %s: se descartan los trabajos retenidos", printer, p.MaxWait)
+			for _, h := range p.take(printer) {
+				p.Runner.finish(h.job, fmt.Errorf("la impresora '%s' no recuperó el papel en %s", printer, p.MaxWait))
+			}
+			return
+		}
+
+		readiness, err := p.Checker.CheckStatus(printer)
+		if err != nil || readiness.PaperOut {
+			continue
+		}
+
+		p.Logger.Infof("Impresora '%s' recuperó el papel: reanudando los trabajos retenidos", printer)
+		if p.resume(printer) {
+			return
+		}
+		// Se volvió a agotar el papel: seguir esperando con los trabajos restantes
+		since = time.Now()
+	}
+}
+
+// resume imprime los trabajos retenidos en orden; devuelve false si la impresora vuelve a quedarse
+// sin papel, devolviendo el trabajo actual al inicio de la cola.
+func (p *PaperHold) resume(printer string) bool {
+	for {
+		select {
+		case <-p.done:
+			return true
+		default:
+		}
+
+		p.mu.Lock()
+		queue := p.queues[printer]
+		if len(queue) == 0 {
+			delete(p.queues, printer)
+			p.mu.Unlock()
+			return true
+		}
+		// Se quita de la cola antes de imprimirlo para que Close no lo dé por fallido mientras se
+		// imprime; la cola vacía sigue registrada para que los trabajos nuevos esperen su turno.
+		// Registrado antes de liberar la cola para que Remove no lo cancele como retenido.
+		next := queue[0]
+		p.queues[printer] = queue[1:]
+		trackJob(next.job)
+		p.mu.Unlock()
+		p.Runner.publish(EventJobStarted, next.job)
+
+		err := runRecovered(next.fn)
+		untrackJob(next.job)
+		if err != nil && !isJobCanceled(next.job.ID) && p.ShouldHold(printer, err) && p.requeue(printer, next) {
+			return false
+		}
+		p.Runner.finish(next.job, err)
+	}
+}
+
+// requeue devuelve el trabajo al inicio de la cola; false si el retenedor ya se cerró
+func (p *PaperHold) requeue(printer string, h heldJob) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	select {
+	case <-p.done:
+		return false
+	default:
+	}
+	p.queues[printer] = append([]heldJob{h}, p.queues[printer]...)
+	return true
+}
+
+// take quita y devuelve la cola de la impresora
+func (p *PaperHold) take(printer string) []heldJob {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	queue := p.queues[printer]
+	delete(p.queues, printer)
+	return queue
+}
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// ============================
+// Inspección de Documentos PDF
+// ============================
+
+func init() {
+	// Evita que pdfcpu cree su directorio de configuración en el perfil del usuario
+	model.ConfigPath = "disable"
+}
+
+// pointsPerMM convierte milímetros a puntos PDF (1/72 de pulgada)
+const pointsPerMM = 72.0 / 25.4
+
+// PageSize es el tamaño de una página en milímetros
+type PageSize struct {
+	WidthMM  float64 `json:"width_mm"`
+	HeightMM float64 `json:"height_mm"`
+}
+
+// PDFInspection contiene los datos obtenidos al analizar un PDF sin imprimirlo
+type PDFInspection struct {
+	PageCount int        `json:"page_count"`
+	Pages     []PageSize `json:"pages"`
+	UsesColor bool       `json:"uses_color"`
+	Version   string     `json:"pdf_version,omitempty"`
+	Encrypted bool       `json:"encrypted"`
+	// PasswordRequired indica que el PDF no puede abrirse sin contraseña; el resto de los datos
+	// quedan vacíos
+	PasswordRequired bool   `json:"password_required,omitempty"`
+	Title            string `json:"title,omitempty"`
+	Author           string `json:"author,omitempty"`
+	Creator          string `json:"creator,omitempty"`
+	Producer         string `json:"producer,omitempty"`
+}
+
+// ErrInvalidDocument indica que el contenido recibido no es un PDF imprimible (p. ej. la página de
+// error HTML del ERP guardada como .pdf) o supera el tamaño permitido
+var ErrInvalidDocument = errors.New("documento inválido")
+
+// ErrPDFPasswordProtected indica que el PDF requiere contraseña para abrirse, por lo que no puede
+// analizarse ni imprimirse
+var ErrPDFPasswordProtected = fmt.Errorf("%w: el PDF está protegido con contraseña", ErrInvalidDocument)
+
+// pdfHeaderWindow es la porción inicial donde se busca la cabecera %PDF-; los lectores la aceptan
+// aunque esté precedida de algunos bytes
+const pdfHeaderWindow = 1024
+
+// CheckPDFFile verifica la cabecera %PDF- y el tamaño del archivo antes de enviarlo a la impresora
+// (maxBytes 0 no limita el tamaño)
+func CheckPDFFile(filePath string, maxBytes int64) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() == 0 {
+		return fmt.Errorf("%w: el archivo está vacío", ErrInvalidDocument)
+	}
+	if maxBytes > 0 && info.Size() > maxBytes {
+		return fmt.Errorf("%w: el archivo tiene %d bytes y el máximo es %d", ErrInvalidDocument, info.Size(), maxBytes)
+	}
+
+	head := make([]byte, pdfHeaderWindow)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return err
+	}
+	head = head[:n]
+	if !bytes.Contains(head, []byte("%PDF-")) {
+		return fmt.Errorf("%w: el contenido no es un PDF (comienza con %q)", ErrInvalidDocument, previewBytes(head))
+	}
+	return nil
+}
+
+// previewBytes devuelve el comienzo del contenido para identificarlo en el mensaje de error
+func previewBytes(b []byte) string {
+	b = bytes.TrimSpace(b)
+	if len(b) > 32 {
+		b = b[:32]
+	}
+	return string(b)
+}
+
+// InspectPDF analiza el archivo indicado con el parser PDF embebido
+func InspectPDF(filePath string) (*PDFInspection, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	ctx, err := api.ReadAndValidate(f, model.NewDefaultConfiguration())
+	if errors.Is(err, pdfcpu.ErrWrongPassword) {
+		return nil, ErrPDFPasswordProtected
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: el archivo no es un PDF válido: %w", ErrInvalidDocument, err)
+	}
+
+	dims, err := ctx.PageDims()
+	if err != nil {
+		return nil, fmt.Errorf("error al leer las dimensiones de página: %w", err)
+	}
+
+	info := &PDFInspection{
+		PageCount: ctx.PageCount,
+		Encrypted: ctx.Encrypt != nil,
+		Title:     ctx.Title,
+		Author:    ctx.Author,
+		Creator:   ctx.Creator,
+		Producer:  ctx.Producer,
+	}
+	if v := ctx.RootVersion; v != nil {
+		info.Version = v.String()
+	} else if v := ctx.HeaderVersion; v != nil {
+		info.Version = v.String()
+	}
+	for _, d := range dims {
+		info.Pages = append(info.Pages, PageSize{
+			WidthMM:  roundMM(d.Width / pointsPerMM),
+			HeightMM: roundMM(d.Height / pointsPerMM),
+		})
+	}
+
+	for pageNr := 1; pageNr <= ctx.PageCount && !info.UsesColor; pageNr++ {
+		info.UsesColor = pageUsesColor(ctx, pageNr)
+	}
+	return info, nil
+}
+
+func roundMM(v float64) float64 {
+	return float64(int(v*10+0.5)) / 10
+}
+
+// pageUsesColor estima si la página contiene color, ya sea por operadores de color no gris
+// en el contenido o por imágenes en espacios de color RGB/CMYK.
+func pageUsesColor(ctx *model.Context, pageNr int) bool {
+	d, _, _, err := ctx.PageDict(pageNr, true)
+	if err != nil || d == nil {
+		return false
+	}
+
+	if content, err := ctx.PageContent(d); err == nil && contentUsesColor(content) {
+		return true
+	}
+
+	resources := d.DictEntry("Resources")
+	if resources == nil {
+		return false
+	}
+	xobjects, err := ctx.DereferenceDict(resources["XObject"])
+	if err != nil || xobjects == nil {
+		return false
+	}
+	for _, obj := range xobjects {
+		sd, _, err := ctx.DereferenceStreamDict(obj)
+		if err != nil || sd == nil {
+			continue
+		}
+		if subtype := sd.Dict.NameEntry("Subtype"); subtype == nil || *subtype != "Image" {
+			continue
+		}
+		if colorSpaceIsColor(ctx, sd.Dict["ColorSpace"]) {
+			return true
+		}
+	}
+	return false
+}
+
+// colorSpaceIsColor indica si el espacio de color de una imagen tiene más de un canal
+func colorSpaceIsColor(ctx *model.Context, obj types.Object) bool {
+	obj, err := ctx.Dereference(obj)
+	if err != nil || obj == nil {
+		return false
+	}
+	switch cs := obj.(type) {
+	case types.Name:
+		return cs == "DeviceRGB" || cs == "DeviceCMYK" || cs == "CalRGB"
+	case types.Array:
+		if len(cs) == 0 {
+			return false
+		}
+		family, _ := cs[0].(types.Name)
+		switch family {
+		case "ICCBased":
+			if len(cs) > 1 {
+				if sd, _, err := ctx.DereferenceStreamDict(cs[1]); err == nil && sd != nil {
+					if n := sd.Dict.IntEntry("N"); n != nil {
+						return *n > 1
+					}
+				}
+			}
+			return true
+		case "Indexed":
+			return len(cs) > 1 && colorSpaceIsColor(ctx, cs[1])
+		case "CalGray", "DeviceGray":
+			return false
+		}
+		return true
+	}
+	return false
+}
+
+// contentUsesColor busca operadores de color con componentes distintos de gris
+func contentUsesColor(content []byte) bool {
+	var operands []float64
+	for _, tok := range bytes.Fields(content) {
+		if v, err := strconv.ParseFloat(string(tok), 64); err == nil {
+			operands = append(operands, v)
+			continue
+		}
+		switch string(tok) {
+		case "rg", "RG", "sc", "SC", "scn", "SCN":
+			if n := len(operands); n >= 3 {
+				r, g, b := operands[n-3], operands[n-2], operands[n-1]
+				if r != g || g != b {
+					return true
+				}
+			}
+		case "k", "K":
+			if n := len(operands); n >= 4 {
+				if operands[n-4] != 0 || operands[n-3] != 0 || operands[n-2] != 0 {
+					return true
+				}
+			}
+		}
+		operands = operands[:0]
+	}
+	return false
+}
+
+// PDFInfoRequest es el cuerpo JSON de POST /pdf-info
+type PDFInfoRequest struct {
+	URL  string `json:"url"`
+	Data string `json:"data"`
+}
+
+// PDFInfoHandler analiza un PDF sin imprimirlo (POST /pdf-info): páginas, tamaños, cifrado y
+// metadatos. Acepta JSON con url o data (base64), o multipart/form-data con el campo file.
+func (h Handlers) PDFInfoHandler(w http.ResponseWriter, r *http.Request) {
+	h.Logger = h.Logger.ForRequest(r)
+	h.Logger.Info("Received request: /pdf-info")
+
+	if r.Method != http.MethodPost {
+		h.Logger.Warnf("Método HTTP no permitido: %s", r.Method)
+		WriteErrorJSON(w, http.StatusMethodNotAllowed, "Método HTTP no permitido", nil)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, h.MaxUploadBytes*4/3+4096)
+	var filePath string
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		if err := r.ParseMultipartForm(multipartMemoryLimit); err != nil {
+			h.Logger.Warnf("Error al procesar el formulario multipart: %v", err)
+			WriteErrorJSON(w, http.StatusBadRequest, "Formulario multipart inválido o archivo demasiado grande", err)
+			return
+		}
+		defer r.MultipartForm.RemoveAll()
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			WriteErrorJSON(w, http.StatusBadRequest, "Falta el archivo PDF (campo file)", err)
+			return
+		}
+		defer file.Close()
+		if filePath, err = saveTempFile(file); err != nil {
+			h.Logger.Errorf("Error al guardar el archivo recibido: %v", err)
+			WriteErrorJSON(w, http.StatusInternalServerError, "Error al guardar el archivo recibido", err)
+			return
+		}
+	} else {
+		var req PDFInfoRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			h.Logger.Warnf("Error al decodificar JSON: %v", err)
+			WriteErrorJSON(w, http.StatusBadRequest, "Solicitud JSON inválida", err)
+			return
+		}
+		if (req.URL == "") == (req.Data == "") {
+			h.Logger.Warn("Se debe especificar url o data")
+			WriteErrorJSON(w, http.StatusBadRequest, "Especifique uno de los campos url o data", nil)
+			return
+		}
+		ctx, cancel := downloadContext(r, 0)
+		var err error
+		filePath, err = h.Service.FetchDocument(ctx, req.URL, req.Data)
+		cancel()
+		if err != nil {
+			h.Logger.Errorf("Error al obtener el documento: %v", err)
+			WriteErrorJSON(w, jobErrorStatus(err), "Error al obtener el documento", err)
+			return
+		}
+	}
+	defer func() {
+		if err := os.Remove(filePath); err != nil {
+			h.Logger.Errorf("Error al eliminar archivo temporal: %v", err)
+		}
+	}()
+
+	info, err := inspectDocument(filePath, h.MaxUploadBytes)
+	if errors.Is(err, ErrPDFPasswordProtected) {
+		WriteJSON(w, http.StatusOK, &PDFInspection{Pages: []PageSize{}, Encrypted: true, PasswordRequired: true})
+		return
+	}
+	if err != nil {
+		h.Logger.Warnf("Error al analizar el documento: %v", err)
+		WriteErrorJSON(w, jobErrorStatus(err), "Error al analizar el documento", err)
+		return
+	}
+	WriteJSON(w, http.StatusOK, info)
+}
+
+// inspectDocument verifica la cabecera y el tamaño del archivo antes de analizarlo
+func inspectDocument(filePath string, maxBytes int64) (*PDFInspection, error) {
+	if err := CheckPDFFile(filePath, maxBytes); err != nil {
+		return nil, err
+	}
+	return InspectPDF(filePath)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/klippa-app/go-pdfium"
+	"github.com/klippa-app/go-pdfium/references"
+	"github.com/klippa-app/go-pdfium/requests"
+	"github.com/klippa-app/go-pdfium/webassembly"
+)
+
+// ============================
+// Motor PDF Incorporado (PDFium + GDI)
+// ============================
+
+// pdfiumInstanceTimeout es la espera máxima por una instancia libre de PDFium
+const pdfiumInstanceTimeout = 30 * time.Second
+
+// pdfiumPool es el grupo de instancias de PDFium, compilado al primer uso (tarda alrededor de un segundo)
+var pdfiumPool = struct {
+	once sync.Once
+	pool pdfium.Pool
+	err  error
+}{}
+
+// getPDFium devuelve una instancia de PDFium; el llamador debe cerrarla
+func getPDFium() (pdfium.Pdfium, error) {
+	pdfiumPool.once.Do(func() {
+		pdfiumPool.pool, pdfiumPool.err = webassembly.Init(webassembly.Config{MinIdle: 1, MaxIdle: 1, MaxTotal: 2})
+	})
+	if pdfiumPool.err != nil {
+		return nil, fmt.Errorf("error al inicializar PDFium: %w", pdfiumPool.err)
+	}
+	return pdfiumPool.pool.GetInstance(pdfiumInstanceTimeout)
+}
+
+// BuiltinEngine imprime sin ejecutables externos: PDFium (compilado a WebAssembly y embebido en el
+// agente) dibuja cada página y se envía al controlador con GDI (StartDoc/StartPage). Duplex,
+// orientación y papel se aplican al DEVMODE del documento, sin modificar la configuración del usuario;
+// la escala la aplica el motor al dibujar cada página.
+type BuiltinEngine struct {
+	// MaxDPI limita la resolución de dibujo; GDI escala la imagen a la resolución de la impresora
+	MaxDPI int
+}
+
+// Name devuelve el nombre del motor
+func (b BuiltinEngine) Name() string { return EngineBuiltin }
+
+// PrintFile dibuja las páginas del PDF y las imprime en un único documento del spooler
+func (b BuiltinEngine) PrintFile(filePath, printer string, opts PrintOptions) error {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return err
+	}
+	instance, err := getPDFium()
+	if err != nil {
+		return err
+	}
+	defer instance.Close()
+
+	doc, err := instance.OpenDocument(&requests.OpenDocument{File: &data})
+	if err != nil {
+		return fmt.Errorf("PDF inválido: %w", err)
+	}
+	defer instance.FPDF_CloseDocument(&requests.FPDF_CloseDocument{Document: doc.Document})
+
+	count, err := instance.FPDF_GetPageCount(&requests.FPDF_GetPageCount{Document: doc.Document})
+	if err != nil {
+		return fmt.Errorf("error al leer las páginas del PDF: %w", err)
+	}
+	pages := opts.PageIndexes(count.PageCount)
+	if len(pages) == 0 {
+		return errors.New("el rango de páginas no incluye ninguna página del documento")
+	}
+
+	// El nombre del archivo identifica el documento en la cola (p. ej. para cancelarlo)
+	docOpts := opts
+	docOpts.Scale = ""
+	gdi, err := startGDIDocument(printer, filepath.Base(filePath), docOpts)
+	if err != nil {
+		return err
+	}
+	dpi := min(max(gdi.DPIX, gdi.DPIY), b.MaxDPI)
+
+	for copy := 0; copy < max(opts.Copies, 1); copy++ {
+		for _, index := range pages {
+			if isJobCanceled(opts.JobID) {
+				gdi.Abort()
+				return ErrJobCanceled
+			}
+			if err := b.printPage(instance, gdi, doc.Document, index, dpi, opts.Scale); err != nil {
+				gdi.Abort()
+				return err
+			}
+		}
+	}
+	return gdi.End()
+}
+
+// printPage dibuja una página con PDFium y la envía al documento GDI
+func (b BuiltinEngine) printPage(instance pdfium.Pdfium, gdi *gdiDocument, document references.FPDF_DOCUMENT, index, dpi int, scale string) error {
+	rendered, err := instance.RenderPageInDPI(&requests.RenderPageInDPI{
+		Page: requests.Page{ByIndex: &requests.PageByIndex{Document: document, Index: index}},
+		DPI:  dpi,
+	})
+	if err != nil {
+		return fmt.Errorf("error al dibujar la página %d: %w", index+1, err)
+	}
+	defer rendered.Cleanup()
+	return gdi.PrintPage(rendered.Result.Image, dpi, scale)
+}
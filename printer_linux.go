@@ -0,0 +1,368 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ============================
+// Impresoras del Sistema: Linux (CUPS)
+// ============================
+
+// Valores predeterminados de la plataforma
+const (
+	defaultPrinterBackend      = "cups"
+	defaultPDFEngine           = EngineCUPS
+	defaultOfficeConverterPath = "soffice"
+)
+
+// newSystemPrinterManager devuelve el PrinterManager de las colas CUPS del equipo
+func newSystemPrinterManager() PrinterManager {
+	return CUPSPrinterManager{Server: cupsServer(), Client: NewIPPClient(5*time.Second, false)}
+}
+
+// newGDIPrinter devuelve nil: CUPS no tiene GDI; /print-text y /print-image usan ESC/POS o PDF
+func newGDIPrinter() GDIPrinter {
+	return nil
+}
+
+// newXPSPrinter devuelve nil: CUPS no imprime documentos XPS
+func newXPSPrinter() XPSPrinter {
+	return nil
+}
+
+// newSpoolerEventSource devuelve nil: CUPS no publica eventos; el seguimiento consulta la cola con lpq
+func newSpoolerEventSource() SpoolerEventSource {
+	return nil
+}
+
+// newScriptDrawerOpener devuelve el DrawerOpener de DRAWER_METHOD=script
+func newScriptDrawerOpener(commandPath string, commands *DrawerCommandStore) DrawerOpener {
+	return ShellDrawerOpener{DrawerCommandPath: commandPath, Commands: commands}
+}
+
+// registerPlatformEngines registra el motor PDF incorporado de Linux (lp de CUPS)
+func registerPlatformEngines(e *EngineDocumentPrinter, cfg EngineConfig, pdfPrinterPath string) {
+	e.Register(CUPSEngine{})
+}
+
+// hideWindow no hace nada en Linux: los procesos externos no abren ventanas
+func hideWindow(cmd *exec.Cmd) {}
+
+// cupsServer devuelve el servidor CUPS (host:puerto) consultado por IPP. Se usa la misma variable
+// CUPS_SERVER que las herramientas de CUPS; los sockets locales se reemplazan por localhost:631.
+func cupsServer() string {
+	server := getEnv("CUPS_SERVER", "localhost:631")
+	if strings.HasPrefix(server, "/") {
+		return "localhost:631"
+	}
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "631")
+	}
+	return server
+}
+
+// runCUPSTool ejecuta una herramienta de CUPS (lpstat) con mensajes en inglés para poder interpretarlos
+func runCUPSTool(name string, args ...string) (string, error) {
+	cmd, timedOut, cancel := toolCommand(name, args...)
+	defer cancel()
+	cmd.Env = append(os.Environ(), "LC_ALL=C", "LANG=C")
+	output, err := cmd.CombinedOutput()
+	if timedOut() {
+		return string(output), toolTimeoutError(name)
+	}
+	if err != nil {
+		return string(output), fmt.Errorf("error al ejecutar %s: %v, salida: %s", name, err, strings.TrimSpace(string(output)))
+	}
+	return string(output), nil
+}
+
+// cupsNoDestinations indica que CUPS no tiene colas configuradas (lpstat termina con error en ese caso)
+func cupsNoDestinations(output string) bool {
+	return strings.Contains(output, "No destinations added")
+}
+
+// cupsPrinterStates devuelve el estado de cada cola según "lpstat -p"
+// ("printer X is idle.", "printer X now printing X-12.", "printer X disabled since ...")
+func cupsPrinterStates() (map[string]string, error) {
+	output, err := runCUPSTool("lpstat", "-p")
+	if err != nil {
+		if cupsNoDestinations(output) {
+			return map[string]string{}, nil
+		}
+		return nil, err
+	}
+	states := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 || fields[0] != "printer" {
+			continue
+		}
+		state := "Normal"
+		switch {
+		case fields[2] == "disabled":
+			state = "Paused"
+		case fields[2] == "now" || (fields[2] == "is" && len(fields) > 3 && fields[3] == "printing"):
+			state = "Printing"
+		}
+		states[fields[1]] = state
+	}
+	return states, scanner.Err()
+}
+
+// cupsDeviceURIs devuelve el dispositivo de cada cola según "lpstat -v" ("device for X: socket://...")
+func cupsDeviceURIs() (map[string]string, error) {
+	output, err := runCUPSTool("lpstat", "-v")
+	if err != nil {
+		if cupsNoDestinations(output) {
+			return map[string]string{}, nil
+		}
+		return nil, err
+	}
+	devices := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		rest, ok := strings.CutPrefix(scanner.Text(), "device for ")
+		if !ok {
+			continue
+		}
+		name, uri, ok := strings.Cut(rest, ": ")
+		if ok {
+			devices[name] = strings.TrimSpace(uri)
+		}
+	}
+	return devices, scanner.Err()
+}
+
+// CUPSPrinterManager es la implementación de PrinterManager para Linux: lista las colas con lpstat
+// y consulta su estado al servidor CUPS por IPP
+type CUPSPrinterManager struct {
+	Server string
+	Client *IPPClient
+}
+
+// ListPrinters lista las colas CUPS con el mismo formato que el backend de Windows
+func (c CUPSPrinterManager) ListPrinters() ([]string, error) {
+	states, err := cupsPrinterStates()
+	if err != nil {
+		return nil, fmt.Errorf("error consultando CUPS: %w", err)
+	}
+	devices, err := cupsDeviceURIs()
+	if err != nil {
+		return nil, fmt.Errorf("error consultando CUPS: %w", err)
+	}
+
+	names := make([]string, 0, len(states))
+	for name := range states {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var printers []string
+	for _, name := range names {
+		printers = append(printers, fmt.Sprintf("Name=%s;DriverName=CUPS;PortName=%s;PrinterStatus=%s;Location=",
+			name, devices[name], states[name]))
+	}
+	return printers, nil
+}
+
+// PrinterExists verifica si la cola existe en CUPS
+func (c CUPSPrinterManager) PrinterExists(name string) (bool, error) {
+	states, err := cupsPrinterStates()
+	if err != nil {
+		return false, fmt.Errorf("error al consultar la impresora: %w", err)
+	}
+	_, ok := states[name]
+	return ok, nil
+}
+
+// DefaultPrinter devuelve el destino predeterminado de CUPS ("lpstat -d")
+func (c CUPSPrinterManager) DefaultPrinter() (string, error) {
+	output, err := runCUPSTool("lpstat", "-d")
+	if err != nil {
+		return "", err
+	}
+	if name, ok := strings.CutPrefix(strings.TrimSpace(output), "system default destination: "); ok {
+		return strings.TrimSpace(name), nil
+	}
+	return "", errors.New("CUPS no tiene una impresora predeterminada")
+}
+
+// GetPrinterStatus consulta el estado de la cola con Get-Printer-Attributes; si el servidor no
+// responde por IPP se informa el estado básico de lpstat
+func (c CUPSPrinterManager) GetPrinterStatus(name string) (*PrinterStatus, error) {
+	states, err := cupsPrinterStates()
+	if err != nil {
+		return nil, fmt.Errorf("error al consultar el estado de la impresora: %w", err)
+	}
+	state, ok := states[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: '%s'", ErrPrinterNotFound, name)
+	}
+
+	uri := (&url.URL{Scheme: "ipp", Host: c.Server, Path: "/printers/" + name}).String()
+	if attrs, err := c.Client.GetPrinterAttributes(uri); err == nil {
+		status := ippPrinterStatus(name, attrs)
+		status.Source = "cups"
+		return status, nil
+	}
+	status := &PrinterStatus{Printer: name, Status: state, States: []string{}, Online: true, Paused: state == "Paused", Source: "lpstat", CheckedAt: time.Now()}
+	if state != "Normal" {
+		status.States = append(status.States, state)
+	}
+	status.finish()
+	return status, nil
+}
+
+// spoolerReadiness traduce el estado de la cola CUPS a PrinterReadiness
+func spoolerReadiness(printer string) (*PrinterReadiness, error) {
+	status, err := newSystemPrinterManager().GetPrinterStatus(printer)
+	if err != nil {
+		return nil, err
+	}
+	return &PrinterReadiness{
+		Online:    status.Online,
+		CoverOpen: status.DoorOpen,
+		PaperOut:  status.PaperOut,
+		Error:     status.Error || status.PaperJam,
+		Source:    status.Source,
+	}, nil
+}
+
+// printerPortAddress devuelve la dirección de las colas con dispositivo socket:// (RAW 9100),
+// o "" si la cola no es de red
+func printerPortAddress(printer string) (string, error) {
+	devices, err := cupsDeviceURIs()
+	if err != nil {
+		return "", err
+	}
+	device, ok := devices[printer]
+	if !ok {
+		return "", fmt.Errorf("la impresora '%s' no existe en CUPS", printer)
+	}
+	u, err := url.Parse(device)
+	if err != nil || u.Scheme != "socket" {
+		return "", nil
+	}
+	if u.Port() == "" {
+		return net.JoinHostPort(u.Hostname(), defaultRawPort), nil
+	}
+	return u.Host, nil
+}
+
+// CUPSEngine imprime con "lp", que aplica las opciones como atributos del trabajo sin depender
+// de la configuración del controlador
+type CUPSEngine struct{}
+
+// Name devuelve el nombre del motor
+func (c CUPSEngine) Name() string { return EngineCUPS }
+
+// PrintFile envía el archivo a la cola CUPS
+func (c CUPSEngine) PrintFile(filePath, printer string, opts PrintOptions) error {
+	// El nombre del archivo en el título identifica el documento en la cola (cancelación, SPOOLER_TRACKING)
+	args := []string{"-d", printer, "-t", "PrinterMatiasERP - " + filepath.Base(filePath)}
+	if opts.Copies > 1 {
+		args = append(args, "-n", strconv.Itoa(opts.Copies))
+	}
+	switch opts.Duplex {
+	case DuplexNone:
+		args = append(args, "-o", "sides=one-sided")
+	case DuplexLongEdge:
+		args = append(args, "-o", "sides=two-sided-long-edge")
+	case DuplexShortEdge:
+		args = append(args, "-o", "sides=two-sided-short-edge")
+	}
+	switch opts.Orientation {
+	case OrientationPortrait:
+		args = append(args, "-o", "orientation-requested=3")
+	case OrientationLandscape:
+		args = append(args, "-o", "orientation-requested=4")
+	}
+	if media, ok := ippMediaNames[opts.PaperSize]; ok {
+		args = append(args, "-o", "media="+media)
+	}
+	// CUPS traduce media-source a la bandeja del PPD; los nombres propios van como InputSlot del PPD
+	if source, generic := opts.MediaSource(); generic {
+		args = append(args, "-o", "media-source="+source)
+	} else if source != "" {
+		args = append(args, "-o", "InputSlot="+source)
+	}
+	switch opts.Color {
+	case ColorColor:
+		args = append(args, "-o", "print-color-mode=color")
+	case ColorGrayscale:
+		args = append(args, "-o", "print-color-mode=monochrome")
+	}
+	if level, ok := qualityLevels[opts.Quality]; ok {
+		args = append(args, "-o", "print-quality="+strconv.Itoa(level.IPP))
+	}
+	// natural-scaling es el porcentaje sobre el tamaño real del documento (filtros de cups-filters)
+	if percent := opts.ScalePercent(); percent > 0 {
+		args = append(args, "-o", "natural-scaling="+strconv.Itoa(percent))
+	} else if scaling, ok := ippPrintScaling[opts.Scale]; ok {
+		args = append(args, "-o", "print-scaling="+scaling)
+	}
+	if opts.Pages != "" {
+		args = append(args, "-o", "page-ranges="+opts.Pages)
+	}
+	args = append(args, "--", filePath)
+	return runExternalTool("lp", "lp", args, toolRun{JobID: opts.JobID, Printer: printer, Document: filePath})
+}
+
+// ShellDrawerOpener ejecuta el script de apertura de cajón con /bin/sh, pasando la impresora como $1
+type ShellDrawerOpener struct {
+	DrawerCommandPath string
+	Commands          *DrawerCommandStore
+}
+
+// OpenDrawer abre el cajón de la impresora especificada; las opciones de pulso las define el script
+func (s ShellDrawerOpener) OpenDrawer(printerName string, opts DrawerOptions) error {
+	if s.Commands != nil {
+		if v, _, ok := s.Commands.Active(); ok && v.Kind == DrawerCommandPowerShell {
+			return fmt.Errorf("la definición de cajón activa (versión %d) es un script de PowerShell, que solo se ejecuta en Windows; use DRAWER_METHOD=escpos", v.Version)
+		}
+	}
+	cmd, timedOut, cancel := toolCommand("/bin/sh", s.DrawerCommandPath, printerName)
+	defer cancel()
+	output, err := cmd.CombinedOutput()
+	recordToolOutput(opts.JobID, "cajón", string(output))
+	if timedOut() {
+		return toolTimeoutError("el comando de apertura de cajón")
+	}
+	if err != nil {
+		return fmt.Errorf("error al ejecutar comando de apertura de cajón: %v, salida: %s", err, toolOutputTail(string(output), toolOutputErrorBytes))
+	}
+	return nil
+}
+
+// removeSpooledDocument cancela los trabajos de la cola CUPS enviados desde el archivo indicado
+// ("lpq -l" muestra el nombre del archivo de cada trabajo). Devuelve la cantidad de trabajos cancelados.
+func removeSpooledDocument(printer, filePath string) (int, error) {
+	jobs, err := CUPSQueueManager{}.ListQueue(printer)
+	if err != nil {
+		return 0, err
+	}
+	name := filepath.Base(filePath)
+	removed := 0
+	for _, job := range jobs {
+		if !strings.Contains(job.Document, name) {
+			continue
+		}
+		if _, err := runCUPSTool("cancel", printer+"-"+strconv.FormatUint(uint64(job.ID), 10)); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// ============================
+// Impresoras del Sistema: Windows (spooler)
+// ============================
+
+// Valores predeterminados de la plataforma
+const (
+	defaultPrinterBackend      = "windows"
+	defaultPDFEngine           = EnginePDFtoPrinter
+	defaultOfficeConverterPath = `C:\Program Files\LibreOffice\program\soffice.exe`
+)
+
+// newSystemPrinterManager devuelve el PrinterManager de las impresoras instaladas en el sistema
+func newSystemPrinterManager() PrinterManager {
+	return WindowsPrinterManager{}
+}
+
+// newGDIPrinter devuelve el GDIPrinter que dibuja texto e imágenes en las impresoras del sistema
+func newGDIPrinter() GDIPrinter {
+	return WindowsGDIPrinter{}
+}
+
+// newXPSPrinter devuelve el XPSPrinter de la API de impresión XPS de Windows
+func newXPSPrinter() XPSPrinter {
+	return WindowsXPSPrinter{}
+}
+
+// newScriptDrawerOpener devuelve el DrawerOpener de DRAWER_METHOD=script
+func newScriptDrawerOpener(commandPath string, commands *DrawerCommandStore) DrawerOpener {
+	return WindowsDrawerOpener{DrawerCommandPath: commandPath, Commands: commands}
+}
+
+// registerPlatformEngines registra los motores PDF incorporados de Windows
+func registerPlatformEngines(e *EngineDocumentPrinter, cfg EngineConfig, pdfPrinterPath string) {
+	e.Register(PDFtoPrinterEngine{ExternalDocumentPrinter{PDFPrinterPath: pdfPrinterPath}})
+	e.Register(SumatraEngine{Path: cfg.SumatraPath})
+	e.Register(GhostscriptEngine{Path: cfg.GhostscriptPath})
+	e.Register(AdobeReaderEngine{Path: cfg.AdobePath, Wait: cfg.AdobeWait})
+	e.Register(BuiltinEngine{MaxDPI: cfg.BuiltinMaxDPI})
+}
+
+// hideWindow evita que las herramientas externas abran una ventana de consola
+func hideWindow(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
+}
+
+// WindowsPrinterManager es una implementación de PrinterManager para Windows
+type WindowsPrinterManager struct{}
+
+// ListPrinters lista todas las impresoras instaladas en el sistema Windows incluyendo la ubicación.
+// Consulta directamente al spooler (winspool.drv) en lugar de invocar PowerShell, que es lento
+// y suele estar restringido en equipos bloqueados.
+func (w WindowsPrinterManager) ListPrinters() ([]string, error) {
+	spoolerPrinters, err := enumSpoolerPrinters()
+	if err != nil {
+		return nil, fmt.Errorf("error consultando el spooler: %w", err)
+	}
+
+	var printers []string
+	for _, p := range spoolerPrinters {
+		printers = append(printers, fmt.Sprintf("Name=%s;DriverName=%s;PortName=%s;PrinterStatus=%s;Location=%s",
+			p.Name, p.DriverName, p.PortName, p.StatusName(), p.Location))
+	}
+
+	return printers, nil
+}
+
+// PrinterExists verifica si una impresora específica existe
+func (w WindowsPrinterManager) PrinterExists(name string) (bool, error) {
+	if _, err := getSpoolerPrinter(name); err != nil {
+		if isPrinterNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("error al consultar la impresora: %w", err)
+	}
+	return true, nil
+}
+
+// DefaultPrinter devuelve la impresora predeterminada de Windows
+func (w WindowsPrinterManager) DefaultPrinter() (string, error) {
+	return getDefaultSpoolerPrinter()
+}
+
+// GetPrinterStatus consulta el estado actual de la impresora con GetPrinterW nivel 2
+func (w WindowsPrinterManager) GetPrinterStatus(name string) (*PrinterStatus, error) {
+	sp, err := getSpoolerPrinter(name)
+	if err != nil {
+		if isPrinterNotFound(err) {
+			return nil, fmt.Errorf("%w: '%s'", ErrPrinterNotFound, name)
+		}
+		return nil, fmt.Errorf("error al consultar el estado de la impresora: %w", err)
+	}
+	return sp.PrinterStatus(), nil
+}
+
+// WindowsDrawerOpener es una implementación de DrawerOpener para Windows
+type WindowsDrawerOpener struct {
+	DrawerCommandPath string
+	Commands          *DrawerCommandStore
+}
+
+// OpenDrawer abre el cajón de la impresora especificada; las opciones de pulso las define el script
+func (w WindowsDrawerOpener) OpenDrawer(printerName string, opts DrawerOptions) error {
+	// Ejecutar el script de PowerShell activo (o el contenido en DrawerCommandPath)
+	scriptPath := w.DrawerCommandPath
+	if w.Commands != nil {
+		if v, path, ok := w.Commands.Active(); ok && v.Kind == DrawerCommandPowerShell {
+			scriptPath = path
+		}
+	}
+	cmd, timedOut, cancel := toolCommand("powershell", "-NoProfile", "-ExecutionPolicy", "Bypass", "-File", scriptPath, "-Printer", printerName)
+	defer cancel()
+
+	// Ocultar la ventana de PowerShell
+	hideWindow(cmd)
+
+	output, err := cmd.CombinedOutput()
+	recordToolOutput(opts.JobID, "cajón", string(output))
+	if timedOut() {
+		return toolTimeoutError("el comando de apertura de cajón")
+	}
+	if err != nil {
+		return fmt.Errorf("error al ejecutar comando de apertura de cajón: %v, salida: %s", err, toolOutputTail(string(output), toolOutputErrorBytes))
+	}
+	return nil
+}
+
+// spoolerReadiness traduce los bits de estado del spooler a PrinterReadiness
+func spoolerReadiness(printer string) (*PrinterReadiness, error) {
+	sp, err := getSpoolerPrinter(printer)
+	if err != nil {
+		return nil, err
+	}
+	return &PrinterReadiness{
+		Online:    sp.Status&(printerStatusOffline|printerStatusNotAvailable) == 0,
+		CoverOpen: sp.Status&printerStatusDoorOpen != 0,
+		PaperOut:  sp.Status&printerStatusPaperOut != 0,
+		Error:     sp.Status&(printerStatusError|printerStatusPaperJam|printerStatusPaperProblem) != 0,
+		Source:    "spooler",
+	}, nil
+}
+
+// printerPortAddress devuelve la IP del puerto TCP/IP estándar de la impresora, o "" si el puerto no es de red
+func printerPortAddress(printer string) (string, error) {
+	sp, err := getSpoolerPrinter(printer)
+	if err != nil {
+		return "", err
+	}
+	return addressFromPortName(sp.PortName), nil
+}
+
+// removeSpooledDocument elimina de la cola de la impresora los documentos enviados desde el archivo
+// indicado (PDFtoPrinter y SumatraPDF usan el nombre del archivo como nombre del documento).
+// Devuelve la cantidad de documentos eliminados.
+func removeSpooledDocument(printer, filePath string) (int, error) {
+	jobs, err := enumSpoolerJobs(printer)
+	if err != nil {
+		return 0, err
+	}
+	name := filepath.Base(filePath)
+	removed := 0
+	for _, job := range jobs {
+		if !strings.Contains(job.Document, name) {
+			continue
+		}
+		if err := deleteSpoolerJob(printer, job.ID); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ============================
+// Administración de Impresoras del Sistema
+// ============================
+
+// ErrPrinterAdminUnsupported indica que el backend de impresoras no permite configurar el sistema (mock o archivo)
+var ErrPrinterAdminUnsupported = errors.New("el backend de impresoras no permite administrar las impresoras del sistema")
+
+// ErrPrinterExists indica que ya hay una impresora instalada con ese nombre
+var ErrPrinterExists = errors.New("ya existe una impresora con ese nombre")
+
+// ErrInvalidPrinterConfig indica que faltan datos de la impresora o son inválidos
+var ErrInvalidPrinterConfig = errors.New("configuración de impresora inválida")
+
+// PrinterAdmin configura las impresoras del sistema (spooler de Windows o CUPS)
+type PrinterAdmin interface {
+	SetDefaultPrinter(printer string) error
+	AddPrinter(spec PrinterSpec) error
+	RenamePrinter(printer, newName string) error
+	SetPrinterPort(printer string, port PrinterPort) error
+	RemovePrinter(printer string) error
+}
+
+// PrinterPort es la conexión de la impresora: la dirección de red (host o host:puerto, RAW 9100 por
+// defecto) o un puerto existente del sistema (p. ej. "USB001" en Windows o un URI de dispositivo en CUPS)
+type PrinterPort struct {
+	Address string `json:"address,omitempty"`
+	Port    string `json:"port,omitempty"`
+}
+
+// IsZero indica que no se indicó la conexión
+func (p PrinterPort) IsZero() bool {
+	return p.Address == "" && p.Port == ""
+}
+
+// Validate exige la dirección o el puerto, pero no ambos
+func (p PrinterPort) Validate() error {
+	if p.Address != "" && p.Port != "" {
+		return fmt.Errorf("%w: indique address o port, no ambos", ErrInvalidPrinterConfig)
+	}
+	if p.Address != "" {
+		if _, _, err := p.HostPort(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HostPort separa la dirección de red en host y puerto TCP
+func (p PrinterPort) HostPort() (string, int, error) {
+	host, portText, err := net.SplitHostPort(p.Address)
+	if err != nil {
+		// Sin puerto: "192.168.1.50" o "cocina.local"
+		host, portText = strings.Trim(p.Address, "[]"), defaultRawPort
+	}
+	port, perr := strconv.Atoi(portText)
+	if host == "" || strings.ContainsAny(host, " /\\") || perr != nil || port <= 0 || port > 65535 {
+		return "", 0, fmt.Errorf("%w: dirección inválida %s (use host o host:puerto)", ErrInvalidPrinterConfig, p.Address)
+	}
+	return host, port, nil
+}
+
+// PrinterSpec describe una impresora de red a instalar (POST /admin/printers)
+type PrinterSpec struct {
+	Name string `json:"name"`
+	// Driver es el controlador ya instalado en Windows o el modelo de lpadmin -m en CUPS
+	Driver string `json:"driver"`
+	PrinterPort
+}
+
+// PrinterChanges son los cambios de una impresora instalada (PATCH /admin/printers/{name}); los
+// campos vacíos no se modifican
+type PrinterChanges struct {
+	Name string `json:"name,omitempty"`
+	PrinterPort
+}
+
+// SetDefaultPrinter cambia la impresora predeterminada del sistema por la impresora (o alias)
+func (d DefaultPrinterService) SetDefaultPrinter(printerName string) (string, error) {
+	if d.Admin == nil {
+		return "", ErrPrinterAdminUnsupported
+	}
+	name, err := d.resolvePrinter(printerName)
+	if err != nil {
+		return "", err
+	}
+	return name, d.Admin.SetDefaultPrinter(name)
+}
+
+// AddPrinter instala una impresora de red con el controlador indicado y devuelve su nombre
+func (d DefaultPrinterService) AddPrinter(spec PrinterSpec) (string, error) {
+	if d.Admin == nil {
+		return "", ErrPrinterAdminUnsupported
+	}
+	if spec.Name == "" || spec.Driver == "" || spec.PrinterPort.IsZero() {
+		return "", fmt.Errorf("%w: name, driver y address (o port) son obligatorios", ErrInvalidPrinterConfig)
+	}
+	if err := spec.PrinterPort.Validate(); err != nil {
+		return "", err
+	}
+	exists, err := d.PrinterManager.PrinterExists(spec.Name)
+	if err != nil {
+		return "", fmt.Errorf("error al verificar la impresora: %w", err)
+	}
+	if exists {
+		return "", fmt.Errorf("%w: '%s'", ErrPrinterExists, spec.Name)
+	}
+	return spec.Name, d.Admin.AddPrinter(spec)
+}
+
+// ConfigurePrinter cambia la conexión y el nombre de la impresora (o alias) y devuelve el nombre final
+func (d DefaultPrinterService) ConfigurePrinter(printerName string, changes PrinterChanges) (string, error) {
+	if d.Admin == nil {
+		return "", ErrPrinterAdminUnsupported
+	}
+	if changes.Name == "" && changes.PrinterPort.IsZero() {
+		return "", fmt.Errorf("%w: indique name, address o port", ErrInvalidPrinterConfig)
+	}
+	if err := changes.PrinterPort.Validate(); err != nil {
+		return "", err
+	}
+	name, err := d.resolvePrinter(printerName)
+	if err != nil {
+		return "", err
+	}
+	if !changes.PrinterPort.IsZero() {
+		if err := d.Admin.SetPrinterPort(name, changes.PrinterPort); err != nil {
+			return name, err
+		}
+	}
+	if changes.Name != "" && changes.Name != name {
+		exists, err := d.PrinterManager.PrinterExists(changes.Name)
+		if err != nil {
+			return name, fmt.Errorf("error al verificar la impresora: %w", err)
+		}
+		if exists {
+			return name, fmt.Errorf("%w: '%s'", ErrPrinterExists, changes.Name)
+		}
+		if err := d.Admin.RenamePrinter(name, changes.Name); err != nil {
+			return name, err
+		}
+		name = changes.Name
+	}
+	return name, nil
+}
+
+// RemovePrinter elimina la impresora (o alias) del sistema y devuelve su nombre
+func (d DefaultPrinterService) RemovePrinter(printerName string) (string, error) {
+	if d.Admin == nil {
+		return "", ErrPrinterAdminUnsupported
+	}
+	name, err := d.resolvePrinter(printerName)
+	if err != nil {
+		return "", err
+	}
+	return name, d.Admin.RemovePrinter(name)
+}
+
+// DefaultPrinterRequest es el cuerpo de PUT /default-printer
+type DefaultPrinterRequest struct {
+	Printer string `json:"printer"`
+}
+
+// SetDefaultPrinterHandler cambia la impresora predeterminada del sistema (PUT /default-printer,
+// desde DefaultPrinterHandler)
+func (h Handlers) SetDefaultPrinterHandler(w http.ResponseWriter, r *http.Request) {
+	var req DefaultPrinterRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Printer == "" {
+		WriteErrorJSON(w, http.StatusBadRequest, "Indique la impresora en el campo printer", err)
+		return
+	}
+	previous, _, _ := h.Service.DefaultPrinter()
+	name, err := h.Service.SetDefaultPrinter(req.Printer)
+	if err != nil {
+		h.writePrinterAdminError(w, req.Printer, err)
+		return
+	}
+	h.Logger.Warnf("Impresora predeterminada del sistema: '%s' (antes '%s') desde %s", name, previous, r.RemoteAddr)
+	resp := map[string]interface{}{"printer": name, "previous": previous}
+	if current, source, _ := h.Service.DefaultPrinter(); source == "config" {
+		// DEFAULT_PRINTER tiene prioridad: el agente sigue usando esa impresora
+		resp["warning"] = fmt.Sprintf("DEFAULT_PRINTER está configurada: el agente sigue usando '%s' cuando la solicitud no indica impresora", current)
+	}
+	WriteJSON(w, http.StatusOK, resp)
+}
+
+// PrintersAdminHandler instala una impresora de red (POST /admin/printers)
+func (h Handlers) PrintersAdminHandler(w http.ResponseWriter, r *http.Request) {
+	h.Logger = h.Logger.ForRequest(r)
+	h.Logger.Info("Received request: /admin/printers")
+
+	if r.Method != http.MethodPost {
+		WriteErrorJSON(w, http.StatusMethodNotAllowed, "Método HTTP no permitido", nil)
+		return
+	}
+	var spec PrinterSpec
+	if err := json.NewDecoder(r.Body).Decode(&spec); err != nil {
+		WriteErrorJSON(w, http.StatusBadRequest, "JSON inválido", err)
+		return
+	}
+	name, err := h.Service.AddPrinter(spec)
+	if err != nil {
+		h.writePrinterAdminError(w, spec.Name, err)
+		return
+	}
+	h.Logger.Warnf("Impresora '%s' instalada (controlador '%s', %s%s) desde %s", name, spec.Driver, spec.Address, spec.Port, r.RemoteAddr)
+	WriteJSON(w, http.StatusCreated, map[string]interface{}{"printer": name, "action": "added"})
+}
+
+// PrinterAdminHandler cambia el nombre o la conexión de una impresora (PATCH /admin/printers/{name})
+// o la elimina (DELETE)
+func (h Handlers) PrinterAdminHandler(w http.ResponseWriter, r *http.Request) {
+	h.Logger = h.Logger.ForRequest(r)
+	h.Logger.Info("Received request: /admin/printers/{name}")
+
+	name := r.PathValue("name")
+	switch r.Method {
+	case http.MethodPatch:
+		var changes PrinterChanges
+		if err := json.NewDecoder(r.Body).Decode(&changes); err != nil {
+			WriteErrorJSON(w, http.StatusBadRequest, "JSON inválido", err)
+			return
+		}
+		printer, err := h.Service.ConfigurePrinter(name, changes)
+		if err != nil {
+			h.writePrinterAdminError(w, name, err)
+			return
+		}
+		h.Logger.Warnf("Impresora '%s' modificada (nombre '%s', %s%s) desde %s", name, printer, changes.Address, changes.Port, r.RemoteAddr)
+		WriteJSON(w, http.StatusOK, map[string]interface{}{"printer": printer, "action": "updated"})
+	case http.MethodDelete:
+		printer, err := h.Service.RemovePrinter(name)
+		if err != nil {
+			h.writePrinterAdminError(w, name, err)
+			return
+		}
+		h.Logger.Warnf("Impresora '%s' eliminada desde %s", printer, r.RemoteAddr)
+		WriteJSON(w, http.StatusOK, map[string]interface{}{"printer": printer, "action": "removed"})
+	default:
+		WriteErrorJSON(w, http.StatusMethodNotAllowed, "Método HTTP no permitido", nil)
+	}
+}
+
+// writePrinterAdminError responde los errores de la administración de impresoras con el código HTTP correspondiente
+func (h Handlers) writePrinterAdminError(w http.ResponseWriter, name string, err error) {
+	switch {
+	case errors.Is(err, ErrPrinterAdminUnsupported):
+		WriteErrorJSON(w, http.StatusNotImplemented, "El backend de impresoras no permite administrar las impresoras", err)
+	case errors.Is(err, ErrPrinterNotFound):
+		WriteErrorJSON(w, http.StatusNotFound, "La impresora no existe", err)
+	case errors.Is(err, ErrPrinterExists):
+		WriteErrorJSON(w, http.StatusConflict, "Ya existe una impresora con ese nombre", err)
+	case errors.Is(err, ErrInvalidPrinterConfig):
+		WriteErrorJSON(w, http.StatusBadRequest, "Configuración de impresora inválida", err)
+	default:
+		h.Logger.Errorf("Error al administrar la impresora '%s': %v", name, err)
+		WriteErrorJSON(w, http.StatusInternalServerError, "Error al administrar la impresora", err)
+	}
+}
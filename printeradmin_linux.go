@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// ============================
+// Administración de Impresoras: Linux (CUPS)
+// ============================
+
+// newSystemPrinterAdmin devuelve el PrinterAdmin de CUPS
+func newSystemPrinterAdmin() PrinterAdmin {
+	return CUPSPrinterAdmin{}
+}
+
+// CUPSPrinterAdmin configura las colas CUPS con lpadmin
+type CUPSPrinterAdmin struct{}
+
+// SetDefaultPrinter cambia el destino predeterminado del servidor CUPS ("lpadmin -d")
+func (c CUPSPrinterAdmin) SetDefaultPrinter(printer string) error {
+	output, err := runCUPSTool("lpadmin", "-d", printer)
+	return cupsQueueError(printer, output, err)
+}
+
+// AddPrinter crea la cola habilitada con el dispositivo y el modelo indicados ("lpadmin -p -E -v -m")
+func (c CUPSPrinterAdmin) AddPrinter(spec PrinterSpec) error {
+	if err := validateCUPSName(spec.Name); err != nil {
+		return err
+	}
+	uri, err := cupsDeviceURI(spec.PrinterPort)
+	if err != nil {
+		return err
+	}
+	if _, err := runCUPSTool("lpadmin", "-p", spec.Name, "-E", "-v", uri, "-m", spec.Driver); err != nil {
+		return fmt.Errorf("no se pudo crear la cola '%s': %w", spec.Name, err)
+	}
+	return nil
+}
+
+// RenamePrinter no está disponible: CUPS no permite renombrar colas
+func (c CUPSPrinterAdmin) RenamePrinter(printer, newName string) error {
+	return fmt.Errorf("%w: CUPS no permite renombrar colas; elimine '%s' y créela como '%s'", ErrPrinterAdminUnsupported, printer, newName)
+}
+
+// SetPrinterPort cambia el dispositivo de la cola ("lpadmin -p -v")
+func (c CUPSPrinterAdmin) SetPrinterPort(printer string, port PrinterPort) error {
+	uri, err := cupsDeviceURI(port)
+	if err != nil {
+		return err
+	}
+	output, err := runCUPSTool("lpadmin", "-p", printer, "-v", uri)
+	return cupsQueueError(printer, output, err)
+}
+
+// RemovePrinter elimina la cola ("lpadmin -x")
+func (c CUPSPrinterAdmin) RemovePrinter(printer string) error {
+	output, err := runCUPSTool("lpadmin", "-x", printer)
+	return cupsQueueError(printer, output, err)
+}
+
+// cupsDeviceURI traduce la conexión al URI de dispositivo: la dirección de red usa socket:// (RAW) y
+// port es un URI de CUPS tal cual (p. ej. "usb://EPSON/TM-T20" o "ipp://10.0.0.5/ipp/print")
+func cupsDeviceURI(port PrinterPort) (string, error) {
+	if port.Port != "" {
+		if !strings.Contains(port.Port, ":") {
+			return "", fmt.Errorf("%w: en CUPS port debe ser un URI de dispositivo (p. ej. usb://...)", ErrInvalidPrinterConfig)
+		}
+		return port.Port, nil
+	}
+	host, tcpPort, err := port.HostPort()
+	if err != nil {
+		return "", err
+	}
+	return "socket://" + net.JoinHostPort(host, strconv.Itoa(tcpPort)), nil
+}
+
+// validateCUPSName rechaza los nombres que CUPS no admite en una cola
+func validateCUPSName(name string) error {
+	if len(name) > 127 || strings.ContainsAny(name, " \t/#\\'\"") {
+		return fmt.Errorf("%w: el nombre de una cola CUPS no puede tener espacios, /, #, \\ ni comillas", ErrInvalidPrinterConfig)
+	}
+	return nil
+}
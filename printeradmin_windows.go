@@ -0,0 +1,237 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// ============================
+// Administración de Impresoras: Windows (spooler)
+// ============================
+
+var (
+	procSetDefaultPrinterW = modWinspool.NewProc("SetDefaultPrinterW")
+	procAddPrinterW        = modWinspool.NewProc("AddPrinterW")
+	procDeletePrinter      = modWinspool.NewProc("DeletePrinter")
+	procXcvDataW           = modWinspool.NewProc("XcvDataW")
+)
+
+const (
+	printerAccessAll       = 0x000F000C // PRINTER_ALL_ACCESS
+	serverAccessAdminister = 0x00000001 // SERVER_ACCESS_ADMINISTER
+	// tcpMonitorName abre el monitor de puertos TCP/IP estándar para crear puertos con XcvData
+	tcpMonitorName    = ",XcvMonitor Standard TCP/IP Port"
+	portProtocolRAW   = 1 // PROTOCOL_RAWTCP_TYPE
+	portDataVersion   = 1
+	maxPortNameLen    = 64
+	maxNetworkNameLen = 49
+)
+
+// portData1 refleja la estructura PORT_DATA_1 del monitor TCP/IP estándar
+type portData1 struct {
+	PortName      [maxPortNameLen]uint16
+	Version       uint32
+	Protocol      uint32
+	Size          uint32
+	Reserved      uint32
+	HostAddress   [maxNetworkNameLen]uint16
+	SNMPCommunity [33]uint16
+	DoubleSpool   uint32
+	Queue         [33]uint16
+	IPAddress     [16]uint16
+	Reserved2     [540]byte
+	PortNumber    uint32
+	SNMPEnabled   uint32
+	SNMPDevIndex  uint32
+}
+
+// newSystemPrinterAdmin devuelve el PrinterAdmin del spooler de Windows
+func newSystemPrinterAdmin() PrinterAdmin {
+	return WindowsPrinterAdmin{}
+}
+
+// WindowsPrinterAdmin configura las impresoras con las funciones de winspool. Instalar, modificar y
+// eliminar impresoras requiere que el agente se ejecute como administrador (o como servicio).
+type WindowsPrinterAdmin struct{}
+
+// SetDefaultPrinter cambia la impresora predeterminada con SetDefaultPrinterW. La predeterminada es
+// del usuario: como servicio se cambia la de la cuenta del servicio.
+func (w WindowsPrinterAdmin) SetDefaultPrinter(printer string) error {
+	name, err := windows.UTF16PtrFromString(printer)
+	if err != nil {
+		return err
+	}
+	if r1, _, err := procSetDefaultPrinterW.Call(uintptr(unsafe.Pointer(name))); r1 == 0 {
+		return spoolerQueueError(printer, fmt.Errorf("SetDefaultPrinter falló: %w", err))
+	}
+	return nil
+}
+
+// AddPrinter instala la impresora con AddPrinterW nivel 2; con una dirección de red crea antes el
+// puerto TCP/IP estándar. El controlador debe estar instalado en el equipo.
+func (w WindowsPrinterAdmin) AddPrinter(spec PrinterSpec) error {
+	if err := validateSpoolerName(spec.Name); err != nil {
+		return err
+	}
+	port, err := ensureSpoolerPort(spec.PrinterPort)
+	if err != nil {
+		return err
+	}
+	name, err := windows.UTF16PtrFromString(spec.Name)
+	if err != nil {
+		return err
+	}
+	portName, err := windows.UTF16PtrFromString(port)
+	if err != nil {
+		return err
+	}
+	driver, err := windows.UTF16PtrFromString(spec.Driver)
+	if err != nil {
+		return err
+	}
+	info := printerInfo2{
+		PrinterName:    name,
+		PortName:       portName,
+		DriverName:     driver,
+		PrintProcessor: windows.StringToUTF16Ptr("winprint"),
+		Datatype:       windows.StringToUTF16Ptr("RAW"),
+	}
+	h, _, err := procAddPrinterW.Call(0, 2, uintptr(unsafe.Pointer(&info)))
+	if h == 0 {
+		if err == windows.ERROR_UNKNOWN_PRINTER_DRIVER {
+			return fmt.Errorf("%w: el controlador '%s' no está instalado", ErrInvalidPrinterConfig, spec.Driver)
+		}
+		return fmt.Errorf("AddPrinter falló para '%s': %w", spec.Name, err)
+	}
+	closeSpoolerPrinter(windows.Handle(h))
+	return nil
+}
+
+// RenamePrinter cambia el nombre de la impresora con SetPrinterW nivel 2
+func (w WindowsPrinterAdmin) RenamePrinter(printer, newName string) error {
+	if err := validateSpoolerName(newName); err != nil {
+		return err
+	}
+	name, err := windows.UTF16PtrFromString(newName)
+	if err != nil {
+		return err
+	}
+	return updateSpoolerPrinter(printer, func(info *printerInfo2) { info.PrinterName = name })
+}
+
+// SetPrinterPort conecta la impresora a otro puerto, creando el puerto TCP/IP si se indica una dirección
+func (w WindowsPrinterAdmin) SetPrinterPort(printer string, port PrinterPort) error {
+	portName, err := ensureSpoolerPort(port)
+	if err != nil {
+		return err
+	}
+	ptr, err := windows.UTF16PtrFromString(portName)
+	if err != nil {
+		return err
+	}
+	return updateSpoolerPrinter(printer, func(info *printerInfo2) { info.PortName = ptr })
+}
+
+// RemovePrinter elimina la impresora con DeletePrinter; los documentos pendientes se descartan. El
+// puerto se conserva por si lo usa otra impresora.
+func (w WindowsPrinterAdmin) RemovePrinter(printer string) error {
+	h, err := openSpoolerPrinterAccess(printer, printerAccessAll)
+	if err != nil {
+		return spoolerQueueError(printer, err)
+	}
+	defer closeSpoolerPrinter(h)
+	if r1, _, err := procDeletePrinter.Call(uintptr(h)); r1 == 0 {
+		return fmt.Errorf("DeletePrinter falló para '%s': %w", printer, err)
+	}
+	return nil
+}
+
+// updateSpoolerPrinter lee la configuración de la impresora (PRINTER_INFO_2), aplica el cambio y la
+// guarda con SetPrinterW. El descriptor de seguridad se omite para conservar los permisos actuales.
+func updateSpoolerPrinter(printer string, update func(info *printerInfo2)) error {
+	h, err := openSpoolerPrinterAccess(printer, printerAccessAll)
+	if err != nil {
+		return spoolerQueueError(printer, err)
+	}
+	defer closeSpoolerPrinter(h)
+
+	var needed uint32
+	r1, _, err := procGetPrinterW.Call(uintptr(h), 2, 0, 0, uintptr(unsafe.Pointer(&needed)))
+	if r1 == 0 && err != windows.ERROR_INSUFFICIENT_BUFFER {
+		return fmt.Errorf("GetPrinter falló: %w", err)
+	}
+	buf := make([]byte, needed)
+	r1, _, err = procGetPrinterW.Call(uintptr(h), 2,
+		uintptr(unsafe.Pointer(&buf[0])), uintptr(needed), uintptr(unsafe.Pointer(&needed)))
+	if r1 == 0 {
+		return fmt.Errorf("GetPrinter falló: %w", err)
+	}
+	info := *(*printerInfo2)(unsafe.Pointer(&buf[0]))
+	update(&info)
+	info.SecurityDescriptor = 0
+	r1, _, err = procSetPrinterW.Call(uintptr(h), 2, uintptr(unsafe.Pointer(&info)), 0)
+	// Los textos de info apuntan a buf
+	runtime.KeepAlive(buf)
+	if r1 == 0 {
+		return fmt.Errorf("SetPrinter falló para '%s': %w", printer, err)
+	}
+	return nil
+}
+
+// ensureSpoolerPort devuelve el puerto a usar: el indicado o, con una dirección de red, el puerto
+// TCP/IP estándar "IP_<host>" (o "IP_<host>_<puerto>" si no es 9100), creándolo si no existe
+func ensureSpoolerPort(port PrinterPort) (string, error) {
+	if port.Port != "" {
+		return port.Port, nil
+	}
+	host, tcpPort, err := port.HostPort()
+	if err != nil {
+		return "", err
+	}
+	name := "IP_" + host
+	if portText := strconv.Itoa(tcpPort); portText != defaultRawPort {
+		name += "_" + portText
+	}
+	if len(name) >= maxPortNameLen || len(host) >= maxNetworkNameLen {
+		return "", fmt.Errorf("%w: dirección demasiado larga: %s", ErrInvalidPrinterConfig, host)
+	}
+
+	h, err := openSpoolerPrinterAccess(tcpMonitorName, serverAccessAdminister)
+	if err != nil {
+		return "", fmt.Errorf("no se pudo abrir el monitor de puertos TCP/IP: %w", err)
+	}
+	defer closeSpoolerPrinter(h)
+
+	data := portData1{Version: portDataVersion, Protocol: portProtocolRAW, PortNumber: uint32(tcpPort)}
+	data.Size = uint32(unsafe.Sizeof(data))
+	copy(data.PortName[:], windows.StringToUTF16(name))
+	copy(data.HostAddress[:], windows.StringToUTF16(host))
+	command, err := windows.UTF16PtrFromString("AddPort")
+	if err != nil {
+		return "", err
+	}
+	var needed, status uint32
+	r1, _, err := procXcvDataW.Call(uintptr(h), uintptr(unsafe.Pointer(command)),
+		uintptr(unsafe.Pointer(&data)), uintptr(data.Size), 0, 0,
+		uintptr(unsafe.Pointer(&needed)), uintptr(unsafe.Pointer(&status)))
+	if r1 == 0 {
+		return "", fmt.Errorf("XcvData falló al crear el puerto %s: %w", name, err)
+	}
+	if status != 0 && windows.Errno(status) != windows.ERROR_ALREADY_EXISTS {
+		return "", fmt.Errorf("no se pudo crear el puerto %s: %w", name, windows.Errno(status))
+	}
+	return name, nil
+}
+
+// validateSpoolerName rechaza los nombres que el spooler no admite
+func validateSpoolerName(name string) error {
+	if len(name) > 220 || strings.ContainsAny(name, `\,!`) {
+		return fmt.Errorf("%w: el nombre de una impresora no puede tener \\, coma ni !", ErrInvalidPrinterConfig)
+	}
+	return nil
+}
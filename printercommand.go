@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// ============================
+// Comandos de Impresora (corte, avance, zumbador)
+// ============================
+
+// Dialectos de comandos: el corte y el zumbador no son iguales en todos los fabricantes
+const (
+	DialectESCPOS = "escpos" // ESC/POS genérico (Xprinter, 3nStar, Bixolon y la mayoría de los clones)
+	DialectEpson  = "epson"  // Epson TM con zumbador (ESC ( A)
+	DialectStar   = "star"   // Star en modo Star Line
+)
+
+// Operaciones de POST /printer-command
+const (
+	PrinterCommandCut  = "cut"
+	PrinterCommandFeed = "feed"
+	PrinterCommandBeep = "beep"
+)
+
+// PrinterCommand es una operación directa sobre la impresora
+type PrinterCommand struct {
+	Command    string `json:"command"`
+	Mode       string `json:"mode,omitempty"`
+	Lines      int    `json:"lines,omitempty"`
+	Times      int    `json:"times,omitempty"`
+	DurationMs int    `json:"duration_ms,omitempty"`
+}
+
+// Normalize valida la operación y completa los valores predeterminados
+func (c *PrinterCommand) Normalize() error {
+	switch c.Command {
+	case PrinterCommandCut:
+		switch c.Mode {
+		case "", ReceiptCutFull, ReceiptCutPartial:
+		default:
+			return fmt.Errorf("mode inválido: %s (use full o partial)", c.Mode)
+		}
+		if c.Lines < 0 || c.Lines > 255 {
+			return fmt.Errorf("lines inválido: %d (rango 0-255)", c.Lines)
+		}
+	case PrinterCommandFeed:
+		if c.Lines == 0 {
+			c.Lines = 3
+		}
+		if c.Lines < 1 || c.Lines > 255 {
+			return fmt.Errorf("lines inválido: %d (rango 1-255)", c.Lines)
+		}
+	case PrinterCommandBeep:
+		if c.Times == 0 {
+			c.Times = 1
+		}
+		if c.DurationMs == 0 {
+			c.DurationMs = 200
+		}
+		if c.Times < 1 || c.Times > 9 {
+			return fmt.Errorf("times inválido: %d (rango 1-9)", c.Times)
+		}
+		if c.DurationMs < 50 || c.DurationMs > 450 {
+			return fmt.Errorf("duration_ms inválido: %d (rango 50-450)", c.DurationMs)
+		}
+	case "":
+		return fmt.Errorf("falta command (cut, feed o beep)")
+	default:
+		return fmt.Errorf("comando desconocido: %s (use cut, feed o beep)", c.Command)
+	}
+	return nil
+}
+
+// Bytes genera la secuencia del comando en el dialecto de la impresora
+func (c PrinterCommand) Bytes(dialect string) []byte {
+	switch c.Command {
+	case PrinterCommandCut:
+		var b ESCPOSBuffer
+		if dialect == DialectStar {
+			// ESC d n: 2 = avance y corte total, 3 = avance y corte parcial
+			n := byte(2)
+			if c.Mode == ReceiptCutPartial {
+				n = 3
+			}
+			if c.Lines > 0 {
+				b.Raw([]byte{escposESC, 'a', byte(c.Lines)})
+			}
+			b.Raw([]byte{escposESC, 'd', n})
+			return b.Bytes()
+		}
+		b.Feed(c.Lines)
+		b.Cut(c.Mode == ReceiptCutPartial)
+		return b.Bytes()
+	case PrinterCommandFeed:
+		if dialect == DialectStar {
+			return []byte{escposESC, 'a', byte(c.Lines)}
+		}
+		return []byte{escposESC, 'd', byte(c.Lines)}
+	case PrinterCommandBeep:
+		switch dialect {
+		case DialectEpson:
+			// ESC ( A pL pH fn n c t: patrón 1, c repeticiones, t en unidades de 100ms
+			return []byte{escposESC, '(', 'A', 4, 0, 48, 49, byte(c.Times), byte((c.DurationMs + 99) / 100)}
+		case DialectStar:
+			// ESC BEL n1 n2 define el pulso del zumbador externo (unidades de 10ms) y BEL lo activa
+			data := []byte{escposESC, 0x07, byte(c.DurationMs / 10), byte(c.DurationMs / 10)}
+			for i := 0; i < c.Times; i++ {
+				data = append(data, 0x07)
+			}
+			return data
+		default:
+			// ESC B n t: n pitidos de t x 50ms
+			return []byte{escposESC, 'B', byte(c.Times), byte(c.DurationMs / 50)}
+		}
+	}
+	return nil
+}
+
+// SendPrinterCommand envía el comando a la impresora en el dialecto de su perfil. Un corte sin
+// mode es parcial si el perfil corta en forma parcial.
+func (d DefaultPrinterService) SendPrinterCommand(printerName string, cmd PrinterCommand) error {
+	if d.CommandWriter == nil {
+		return fmt.Errorf("los comandos de impresora no están disponibles")
+	}
+	printerName, err := d.resolvePrinter(printerName)
+	if err != nil {
+		return err
+	}
+	profile := d.profile(printerName)
+	if err := profile.requireType(printerName, PrinterTypeThermal); err != nil {
+		return err
+	}
+	if cmd.Command == PrinterCommandCut && cmd.Mode == "" && profile.Cut == ReceiptCutPartial {
+		cmd.Mode = ReceiptCutPartial
+	}
+	if err := d.CommandWriter.WriteRaw(printerName, cmd.Bytes(profile.Dialect)); err != nil {
+		return fmt.Errorf("error al enviar el comando: %w", err)
+	}
+	return nil
+}
+
+// PrinterCommandRequest es el cuerpo de POST /printer-command
+type PrinterCommandRequest struct {
+	Printer string `json:"printer"`
+	PrinterCommand
+}
+
+// PrinterCommandHandler envía un corte de papel, avance o pitido a la impresora (POST /printer-command)
+func (h Handlers) PrinterCommandHandler(w http.ResponseWriter, r *http.Request) {
+	h.Logger = h.Logger.ForRequest(r)
+	h.Logger.Info("Received request: /printer-command")
+
+	if r.Method != http.MethodPost {
+		h.Logger.Warnf("Método HTTP no permitido: %s", r.Method)
+		WriteErrorJSON(w, http.StatusMethodNotAllowed, "Método HTTP no permitido", nil)
+		return
+	}
+
+	var req PrinterCommandRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.Logger.Warnf("Error al decodificar JSON: %v", err)
+		WriteErrorJSON(w, http.StatusBadRequest, "Solicitud JSON inválida", err)
+		return
+	}
+	if err := req.PrinterCommand.Normalize(); err != nil {
+		h.Logger.Warnf("Comando inválido: %v", err)
+		WriteErrorJSON(w, http.StatusBadRequest, "Comando inválido", err)
+		return
+	}
+	printer, err := h.defaultPrinter(req.Printer)
+	if err != nil {
+		h.Logger.Warnf("No se especificó la impresora: %v", err)
+		WriteErrorJSON(w, http.StatusBadRequest, "No se especificó la impresora", err)
+		return
+	}
+
+	job := NewPrintJob(JobKindCommand, printer, req.Command)
+	job.RequestID = RequestID(r)
+	job.ClientCN = ClientCommonName(r)
+	err = h.Jobs.Run(job, func() error {
+		return h.Service.SendPrinterCommand(printer, req.PrinterCommand)
+	})
+	if err != nil {
+		h.Logger.Errorf("Error al enviar el comando: %v", err)
+		WriteJobErrorJSON(w, jobErrorStatus(err), job, "Error al enviar el comando", err)
+		return
+	}
+
+	WriteJobJSON(w, job, "Comando enviado a la impresora.")
+}
@@ -0,0 +1,511 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ============================
+// Perfiles de Impresora
+// ============================
+
+// Tipos de impresora
+const (
+	PrinterTypeThermal = "thermal"
+	PrinterTypeLaser   = "laser"
+	PrinterTypeLabel   = "label"
+)
+
+// Origen de un perfil
+const (
+	ProfileSourceConfig  = "config"
+	ProfileSourceAPI     = "api"
+	ProfileSourceDefault = "default"
+)
+
+// ErrPrinterTypeMismatch indica que el trabajo no corresponde al tipo de impresora del perfil
+var ErrPrinterTypeMismatch = errors.New("tipo de impresora incompatible")
+
+// defaultPrinterProfile son los valores que se aplican a las impresoras sin perfil
+var defaultPrinterProfile = PrinterProfile{Codepage: "ascii", WidthMM: 80, Cut: ReceiptCutFull, Copies: 1, Dialect: DialectESCPOS}
+
+// escposCodepages son las páginas de códigos con las que se puede escribir el texto de los recibos
+var escposCodepages = map[string]bool{"ascii": true, "cp437": true, "cp850": true, "cp858": true, "cp1252": true, "windows-1252": true}
+
+// PrinterProfile son las características de una impresora que el servicio usa en lugar de los
+// valores globales. Los campos vacíos toman el valor predeterminado.
+type PrinterProfile struct {
+	Type       string `json:"type,omitempty"`
+	Codepage   string `json:"codepage,omitempty"`
+	WidthMM    int    `json:"width_mm,omitempty"`
+	DrawerKick string `json:"drawer_kick,omitempty"`
+	Cut        string `json:"cut,omitempty"`
+	Copies     int    `json:"copies,omitempty"`
+	CopyLabel  string `json:"copy_label,omitempty"`
+	// Tray es la bandeja de los documentos cuando la solicitud no la indica (ver tray en /print)
+	Tray        string `json:"tray,omitempty"`
+	Dialect     string `json:"dialect,omitempty"`
+	RasterCodes bool   `json:"raster_codes,omitempty"`
+	Serial      string `json:"serial,omitempty"`
+	// DrawerPins asigna a cada cajón (1 y 2) el conector de su pulso
+	DrawerPins []int `json:"drawer_pins,omitempty"`
+}
+
+// Validate verifica los valores del perfil
+func (p PrinterProfile) Validate() error {
+	switch p.Type {
+	case "", PrinterTypeThermal, PrinterTypeLaser, PrinterTypeLabel:
+	default:
+		return fmt.Errorf("type inválido: %s (use thermal, laser o label)", p.Type)
+	}
+	if p.Codepage != "" && !escposCodepages[p.Codepage] {
+		return fmt.Errorf("codepage no soportada: %s", p.Codepage)
+	}
+	if _, ok := receiptColumns[p.WidthMM]; p.WidthMM != 0 && !ok {
+		return fmt.Errorf("width_mm inválido: %d (use 58 u 80)", p.WidthMM)
+	}
+	if p.DrawerKick != "" {
+		if _, err := ParseESCPOSDefinition(p.DrawerKick); err != nil {
+			return fmt.Errorf("drawer_kick inválido: %w", err)
+		}
+	}
+	switch p.Cut {
+	case "", ReceiptCutFull, ReceiptCutPartial, ReceiptCutNone:
+	default:
+		return fmt.Errorf("cut inválido: %s (use full, partial o none)", p.Cut)
+	}
+	if p.Copies < 0 || p.Copies > maxCopies {
+		return fmt.Errorf("copies inválido: %d (máximo %d)", p.Copies, maxCopies)
+	}
+	if len([]rune(p.CopyLabel)) > maxStampLength {
+		return fmt.Errorf("copy_label no puede superar %d caracteres", maxStampLength)
+	}
+	if len(p.Tray) > maxTrayLength {
+		return fmt.Errorf("tray no puede superar %d caracteres", maxTrayLength)
+	}
+	switch p.Dialect {
+	case "", DialectESCPOS, DialectEpson, DialectStar:
+	default:
+		return fmt.Errorf("dialect inválido: %s (use escpos, epson o star)", p.Dialect)
+	}
+	if p.Serial != "" {
+		if _, err := ParseSerialPort(p.Serial); err != nil {
+			return fmt.Errorf("serial inválido: %w", err)
+		}
+	}
+	if len(p.DrawerPins) > 2 {
+		return fmt.Errorf("drawer_pins inválido: %v (la impresora controla hasta 2 cajones)", p.DrawerPins)
+	}
+	for i, pin := range p.DrawerPins {
+		if pin != DrawerPin2 && pin != DrawerPin5 {
+			return fmt.Errorf("drawer_pins inválido: %d (use 2 o 5)", pin)
+		}
+		if i > 0 && pin == p.DrawerPins[0] {
+			return fmt.Errorf("drawer_pins inválido: los dos cajones usan el conector %d", pin)
+		}
+	}
+	return nil
+}
+
+// withDefaults completa los campos vacíos con los valores predeterminados
+func (p PrinterProfile) withDefaults(d PrinterProfile) PrinterProfile {
+	if p.Type == "" {
+		p.Type = d.Type
+	}
+	if p.Codepage == "" {
+		p.Codepage = d.Codepage
+	}
+	if p.WidthMM == 0 {
+		p.WidthMM = d.WidthMM
+	}
+	if p.DrawerKick == "" {
+		p.DrawerKick = d.DrawerKick
+	}
+	if p.Cut == "" {
+		p.Cut = d.Cut
+	}
+	if p.Copies == 0 {
+		p.Copies = d.Copies
+	}
+	if p.CopyLabel == "" {
+		p.CopyLabel = d.CopyLabel
+	}
+	if p.Tray == "" {
+		p.Tray = d.Tray
+	}
+	if p.Dialect == "" {
+		p.Dialect = d.Dialect
+	}
+	p.RasterCodes = p.RasterCodes || d.RasterCodes
+	if p.Serial == "" {
+		p.Serial = d.Serial
+	}
+	if len(p.DrawerPins) == 0 {
+		p.DrawerPins = d.DrawerPins
+	}
+	return p
+}
+
+// DrawerKickBytes devuelve la secuencia de apertura del cajón del perfil, o nil si usa la global
+func (p PrinterProfile) DrawerKickBytes() []byte {
+	if p.DrawerKick == "" {
+		return nil
+	}
+	cmd, _ := ParseESCPOSDefinition(p.DrawerKick)
+	return cmd
+}
+
+// DrawerPin devuelve el conector del cajón indicado (1 o 2). Sin drawer_pins se usa el cableado
+// habitual de los cables en Y: el cajón 1 en el conector 2 y el cajón 2 en el conector 5.
+func (p PrinterProfile) DrawerPin(drawer int) (int, error) {
+	pins := p.DrawerPins
+	if len(pins) == 0 {
+		pins = []int{DrawerPin2, DrawerPin5}
+	}
+	if drawer < 1 || drawer > len(pins) {
+		return 0, fmt.Errorf("%w: la impresora tiene %d cajón(es) y se pidió el %d", ErrDrawerNotConfigured, len(pins), drawer)
+	}
+	return pins[drawer-1], nil
+}
+
+// requireType verifica que la impresora sea de alguno de los tipos indicados; las impresoras sin
+// tipo declarado aceptan cualquier trabajo
+func (p PrinterProfile) requireType(printer string, types ...string) error {
+	if p.Type == "" {
+		return nil
+	}
+	for _, t := range types {
+		if p.Type == t {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: la impresora '%s' es de tipo %s y no admite este trabajo (requiere %s)", ErrPrinterTypeMismatch, printer, p.Type, strings.Join(types, " o "))
+}
+
+// PrinterProfileConfig reúne los valores por impresora de las variables de entorno (o de la
+// sección printers de config.yaml) y el archivo de perfiles editado por la API
+type PrinterProfileConfig struct {
+	Path        string
+	WidthMM     int
+	Widths      map[string]string
+	RasterCodes []string
+	Dialects    map[string]string
+	Types       map[string]string
+	Codepages   map[string]string
+	Cuts        map[string]string
+	Copies      map[string]string
+	CopyLabels  map[string]string
+	Trays       map[string]string
+	DrawerKicks map[string]string
+	SerialPorts map[string]string
+	DrawerPins  map[string]string
+}
+
+// LoadPrinterProfileConfig carga la configuración de perfiles desde variables de entorno
+func LoadPrinterProfileConfig() PrinterProfileConfig {
+	return PrinterProfileConfig{
+		Path:        getEnv("PRINTER_PROFILES_PATH", "./printer_profiles.json"),
+		WidthMM:     getEnvAsInt("RECEIPT_WIDTH_MM", 80),
+		Widths:      getEnvAsMap("RECEIPT_PRINTER_WIDTHS", ""),
+		RasterCodes: getEnvAsSlice("RECEIPT_RASTER_CODES", ""),
+		Dialects:    getEnvAsMap("PRINTER_DIALECTS", ""),
+		Types:       getEnvAsMap("PRINTER_TYPES", ""),
+		Codepages:   getEnvAsMap("PRINTER_CODEPAGES", ""),
+		Cuts:        getEnvAsMap("PRINTER_CUTS", ""),
+		Copies:      getEnvAsMap("PRINTER_COPIES", ""),
+		CopyLabels:  getEnvAsMap("PRINTER_COPY_LABELS", ""),
+		Trays:       getEnvAsMap("PRINTER_TRAYS", ""),
+		DrawerKicks: getEnvAsMap("PRINTER_DRAWER_KICKS", ""),
+		SerialPorts: getEnvAsMap("PRINTER_SERIAL_PORTS", ""),
+		DrawerPins:  getEnvAsMap("PRINTER_DRAWER_PINS", ""),
+	}
+}
+
+// profiles arma los perfiles declarados en la configuración
+func (c PrinterProfileConfig) profiles() (map[string]PrinterProfile, error) {
+	profiles := make(map[string]PrinterProfile)
+	update := func(values map[string]string, set func(p *PrinterProfile, value string) error) error {
+		for printer, value := range values {
+			p := profiles[printer]
+			if err := set(&p, value); err != nil {
+				return fmt.Errorf("'%s': %w", printer, err)
+			}
+			profiles[printer] = p
+		}
+		return nil
+	}
+	raster := make(map[string]string)
+	for _, printer := range c.RasterCodes {
+		if printer != "*" {
+			raster[printer] = "true"
+		}
+	}
+	for _, u := range []struct {
+		values map[string]string
+		set    func(p *PrinterProfile, value string) error
+	}{
+		{c.Widths, func(p *PrinterProfile, v string) (err error) {
+			p.WidthMM, err = strconv.Atoi(strings.TrimSuffix(strings.ToLower(v), "mm"))
+			return err
+		}},
+		{c.Copies, func(p *PrinterProfile, v string) (err error) {
+			p.Copies, err = strconv.Atoi(v)
+			return err
+		}},
+		{c.Dialects, func(p *PrinterProfile, v string) error { p.Dialect = strings.ToLower(v); return nil }},
+		{c.Types, func(p *PrinterProfile, v string) error { p.Type = strings.ToLower(v); return nil }},
+		{c.Codepages, func(p *PrinterProfile, v string) error { p.Codepage = strings.ToLower(v); return nil }},
+		{c.Cuts, func(p *PrinterProfile, v string) error { p.Cut = strings.ToLower(v); return nil }},
+		{c.CopyLabels, func(p *PrinterProfile, v string) error { p.CopyLabel = strings.TrimSpace(v); return nil }},
+		{c.Trays, func(p *PrinterProfile, v string) error {
+			opts := PrintOptions{Tray: v}
+			err := opts.Normalize()
+			p.Tray = opts.Tray
+			return err
+		}},
+		{c.DrawerKicks, func(p *PrinterProfile, v string) error { p.DrawerKick = v; return nil }},
+		{c.SerialPorts, func(p *PrinterProfile, v string) error { p.Serial = v; return nil }},
+		{c.DrawerPins, func(p *PrinterProfile, v string) error {
+			p.DrawerPins = nil
+			for _, pin := range strings.Split(v, "|") {
+				n, err := strconv.Atoi(strings.TrimSpace(pin))
+				if err != nil {
+					return fmt.Errorf("drawer_pins inválido: %s", v)
+				}
+				p.DrawerPins = append(p.DrawerPins, n)
+			}
+			return nil
+		}},
+		{raster, func(p *PrinterProfile, v string) error { p.RasterCodes = true; return nil }},
+	} {
+		if err := update(u.values, u.set); err != nil {
+			return nil, err
+		}
+	}
+	for printer, p := range profiles {
+		if err := p.Validate(); err != nil {
+			return nil, fmt.Errorf("'%s': %w", printer, err)
+		}
+	}
+	return profiles, nil
+}
+
+// PrinterProfileEntry es un perfil tal como lo devuelve la API, con su origen
+type PrinterProfileEntry struct {
+	Printer   string         `json:"printer"`
+	Source    string         `json:"source"`
+	Profile   PrinterProfile `json:"profile"`
+	Effective PrinterProfile `json:"effective"`
+}
+
+// PrinterProfileStore es el registro de perfiles: los de la configuración más los guardados por la
+// API, que los reemplazan por completo y se conservan en un archivo JSON
+type PrinterProfileStore struct {
+	mu       sync.RWMutex
+	path     string
+	config   map[string]PrinterProfile
+	stored   map[string]PrinterProfile
+	defaults PrinterProfile
+}
+
+// NewPrinterProfileStore construye el registro y carga los perfiles guardados por la API
+func NewPrinterProfileStore(cfg PrinterProfileConfig) (*PrinterProfileStore, error) {
+	if _, ok := receiptColumns[cfg.WidthMM]; !ok {
+		return nil, fmt.Errorf("RECEIPT_WIDTH_MM inválido: %d (use 58 u 80)", cfg.WidthMM)
+	}
+	profiles, err := cfg.profiles()
+	if err != nil {
+		return nil, fmt.Errorf("perfiles de impresora inválidos: %w", err)
+	}
+	defaults := defaultPrinterProfile
+	defaults.WidthMM = cfg.WidthMM
+	defaults.RasterCodes = matchesPrinter(cfg.RasterCodes, "*")
+	s := &PrinterProfileStore{path: cfg.Path, config: profiles, stored: map[string]PrinterProfile{}, defaults: defaults}
+	data, err := os.ReadFile(cfg.Path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("error al leer los perfiles de impresora: %w", err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &s.stored); err != nil {
+			return nil, fmt.Errorf("archivo de perfiles de impresora corrupto '%s': %w", cfg.Path, err)
+		}
+	}
+	return s, nil
+}
+
+// Get devuelve el perfil efectivo de la impresora (con los valores predeterminados aplicados)
+func (s *PrinterProfileStore) Get(printer string) PrinterProfile {
+	return s.Entry(printer).Effective
+}
+
+// Entry devuelve el perfil de la impresora con su origen
+func (s *PrinterProfileStore) Entry(printer string) PrinterProfileEntry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	entry := PrinterProfileEntry{Printer: printer, Source: ProfileSourceDefault}
+	if p, ok := s.stored[printer]; ok {
+		entry.Source, entry.Profile = ProfileSourceAPI, p
+	} else if p, ok := s.config[printer]; ok {
+		entry.Source, entry.Profile = ProfileSourceConfig, p
+	}
+	entry.Effective = entry.Profile.withDefaults(s.defaults)
+	return entry
+}
+
+// List devuelve los perfiles declarados, ordenados por impresora
+func (s *PrinterProfileStore) List() []PrinterProfileEntry {
+	s.mu.RLock()
+	names := make([]string, 0, len(s.config)+len(s.stored))
+	for name := range s.config {
+		names = append(names, name)
+	}
+	for name := range s.stored {
+		if _, ok := s.config[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	s.mu.RUnlock()
+	sort.Strings(names)
+
+	entries := make([]PrinterProfileEntry, 0, len(names))
+	for _, name := range names {
+		entries = append(entries, s.Entry(name))
+	}
+	return entries
+}
+
+// Defaults devuelve los valores predeterminados de los perfiles
+func (s *PrinterProfileStore) Defaults() PrinterProfile {
+	return s.defaults
+}
+
+// Set guarda el perfil de la impresora, reemplazando al de la configuración
+func (s *PrinterProfileStore) Set(printer string, p PrinterProfile) error {
+	if strings.TrimSpace(printer) == "" {
+		return errors.New("falta el nombre de la impresora")
+	}
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	previous, existed := s.stored[printer]
+	s.stored[printer] = p
+	if err := s.save(); err != nil {
+		if existed {
+			s.stored[printer] = previous
+		} else {
+			delete(s.stored, printer)
+		}
+		return err
+	}
+	return nil
+}
+
+// Delete elimina el perfil guardado por la API; la impresora vuelve al perfil de la configuración
+func (s *PrinterProfileStore) Delete(printer string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	previous, ok := s.stored[printer]
+	if !ok {
+		return false, nil
+	}
+	delete(s.stored, printer)
+	if err := s.save(); err != nil {
+		s.stored[printer] = previous
+		return false, err
+	}
+	return true, nil
+}
+
+// save escribe los perfiles de la API de forma atómica
+func (s *PrinterProfileStore) save() error {
+	data, err := json.MarshalIndent(s.stored, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("error al guardar los perfiles de impresora: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("error al guardar los perfiles de impresora: %w", err)
+	}
+	return nil
+}
+
+// profile devuelve el perfil efectivo de la impresora ya resuelta
+func (d DefaultPrinterService) profile(printerName string) PrinterProfile {
+	if d.Profiles == nil {
+		return defaultPrinterProfile
+	}
+	return d.Profiles.Get(printerName)
+}
+
+// PrinterProfileHandlers expone el registro de perfiles en la API administrativa
+type PrinterProfileHandlers struct {
+	Store  *PrinterProfileStore
+	Logger *Logger
+}
+
+// ListHandler lista los perfiles declarados y los valores predeterminados (GET)
+func (h PrinterProfileHandlers) ListHandler(w http.ResponseWriter, r *http.Request) {
+	h.Logger = h.Logger.ForRequest(r)
+	h.Logger.Info("Received request: /admin/printer-profiles")
+
+	if r.Method != http.MethodGet {
+		WriteErrorJSON(w, http.StatusMethodNotAllowed, "Método HTTP no permitido", nil)
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]interface{}{"defaults": h.Store.Defaults(), "profiles": h.Store.List()})
+}
+
+// ProfileHandler consulta (GET), guarda (PUT) o elimina (DELETE) el perfil de una impresora
+func (h PrinterProfileHandlers) ProfileHandler(w http.ResponseWriter, r *http.Request) {
+	h.Logger = h.Logger.ForRequest(r)
+	h.Logger.Info("Received request: /admin/printer-profiles/{printer}")
+
+	printer := r.PathValue("printer")
+	switch r.Method {
+	case http.MethodGet:
+		WriteJSON(w, http.StatusOK, h.Store.Entry(printer))
+	case http.MethodPut:
+		var p PrinterProfile
+		decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&p); err != nil {
+			WriteErrorJSON(w, http.StatusBadRequest, "Solicitud JSON inválida", err)
+			return
+		}
+		p.Type, p.Codepage, p.Cut, p.Dialect = strings.ToLower(p.Type), strings.ToLower(p.Codepage), strings.ToLower(p.Cut), strings.ToLower(p.Dialect)
+		if err := h.Store.Set(printer, p); err != nil {
+			h.Logger.Warnf("Perfil de '%s' rechazado: %v", printer, err)
+			WriteErrorJSON(w, http.StatusBadRequest, "Perfil de impresora inválido", err)
+			return
+		}
+		h.Logger.Infof("Perfil de la impresora '%s' actualizado", printer)
+		WriteJSON(w, http.StatusOK, h.Store.Entry(printer))
+	case http.MethodDelete:
+		deleted, err := h.Store.Delete(printer)
+		if err != nil {
+			h.Logger.Errorf("Error al eliminar el perfil de '%s': %v", printer, err)
+			WriteErrorJSON(w, http.StatusInternalServerError, "Error al eliminar el perfil", err)
+			return
+		}
+		if !deleted {
+			WriteErrorJSON(w, http.StatusNotFound, "La impresora no tiene un perfil guardado por la API", nil)
+			return
+		}
+		h.Logger.Infof("Perfil de la impresora '%s' eliminado", printer)
+		WriteJSON(w, http.StatusOK, h.Store.Entry(printer))
+	default:
+		WriteErrorJSON(w, http.StatusMethodNotAllowed, "Método HTTP no permitido", nil)
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ============================
+// Cola de Impresión del Sistema
+// ============================
+
+// Acciones sobre la cola de una impresora
+const (
+	QueueActionPause  = "pause"
+	QueueActionResume = "resume"
+	QueueActionPurge  = "purge"
+)
+
+// ErrQueueUnsupported indica que el backend de impresoras no tiene una cola consultable (mock, red o IPP)
+var ErrQueueUnsupported = errors.New("el backend de impresoras no permite administrar la cola")
+
+// QueueJob es un documento en la cola del sistema, informado en GET /printers/{name}/queue
+type QueueJob struct {
+	ID           uint32    `json:"id"`
+	Document     string    `json:"document"`
+	Status       string    `json:"status"`
+	States       []string  `json:"states"`
+	Pages        int       `json:"pages"`
+	PagesPrinted int       `json:"pages_printed"`
+	Size         int64     `json:"size,omitempty"`
+	User         string    `json:"user"`
+	Machine      string    `json:"machine,omitempty"`
+	Position     int       `json:"position"`
+	SubmittedAt  time.Time `json:"submitted_at,omitempty"`
+}
+
+// QueueManager consulta y controla la cola de impresión del sistema (spooler de Windows o CUPS)
+type QueueManager interface {
+	ListQueue(printer string) ([]QueueJob, error)
+	PauseQueue(printer string) error
+	ResumeQueue(printer string) error
+	PurgeQueue(printer string) (int, error)
+}
+
+// PrinterQueue lista los documentos en la cola de la impresora (o alias)
+func (d DefaultPrinterService) PrinterQueue(printerName string) ([]QueueJob, error) {
+	if d.Queues == nil {
+		return nil, ErrQueueUnsupported
+	}
+	return d.Queues.ListQueue(d.Aliases.Resolve(printerName))
+}
+
+// ControlQueue pausa, reanuda o vacía la cola de la impresora (o alias); para purge devuelve la
+// cantidad de documentos eliminados
+func (d DefaultPrinterService) ControlQueue(printerName, action string) (int, error) {
+	if d.Queues == nil {
+		return 0, ErrQueueUnsupported
+	}
+	printer := d.Aliases.Resolve(printerName)
+	switch action {
+	case QueueActionPause:
+		return 0, d.Queues.PauseQueue(printer)
+	case QueueActionResume:
+		return 0, d.Queues.ResumeQueue(printer)
+	case QueueActionPurge:
+		return d.Queues.PurgeQueue(printer)
+	default:
+		return 0, fmt.Errorf("acción desconocida: %s (use pause, resume o purge)", action)
+	}
+}
+
+// PrinterQueueHandler lista la cola del sistema de una impresora (GET /printers/{name}/queue)
+func (h Handlers) PrinterQueueHandler(w http.ResponseWriter, r *http.Request) {
+	h.Logger = h.Logger.ForRequest(r)
+	h.Logger.Info("Received request: /printers/{name}/queue")
+
+	if r.Method != http.MethodGet {
+		WriteErrorJSON(w, http.StatusMethodNotAllowed, "Método HTTP no permitido", nil)
+		return
+	}
+	name := r.PathValue("name")
+	jobs, err := h.Service.PrinterQueue(name)
+	if err != nil {
+		h.writeQueueError(w, name, err)
+		return
+	}
+	if jobs == nil {
+		jobs = []QueueJob{}
+	}
+	WriteJSON(w, http.StatusOK, map[string]interface{}{"printer": name, "jobs": jobs})
+}
+
+// PrinterQueueActionHandler pausa, reanuda o vacía la cola de una impresora
+// (POST /printers/{name}/queue/{action})
+func (h Handlers) PrinterQueueActionHandler(w http.ResponseWriter, r *http.Request) {
+	h.Logger = h.Logger.ForRequest(r)
+	h.Logger.Info("Received request: /printers/{name}/queue/{action}")
+
+	if r.Method != http.MethodPost {
+		WriteErrorJSON(w, http.StatusMethodNotAllowed, "Método HTTP no permitido", nil)
+		return
+	}
+	name, action := r.PathValue("name"), r.PathValue("action")
+	switch action {
+	case QueueActionPause, QueueActionResume, QueueActionPurge:
+	default:
+		WriteErrorJSON(w, http.StatusNotFound, "Acción desconocida (use pause, resume o purge)", nil)
+		return
+	}
+
+	removed, err := h.Service.ControlQueue(name, action)
+	if err != nil {
+		h.writeQueueError(w, name, err)
+		return
+	}
+	h.Logger.Warnf("Cola de '%s': %s desde %s", name, action, r.RemoteAddr)
+	resp := map[string]interface{}{"printer": name, "action": action}
+	if action == QueueActionPurge {
+		resp["removed"] = removed
+	}
+	WriteJSON(w, http.StatusOK, resp)
+}
+
+// writeQueueError responde los errores de la cola con el código HTTP correspondiente
+func (h Handlers) writeQueueError(w http.ResponseWriter, name string, err error) {
+	switch {
+	case errors.Is(err, ErrQueueUnsupported):
+		WriteErrorJSON(w, http.StatusNotImplemented, "La cola de impresión no está disponible", err)
+	case errors.Is(err, ErrPrinterNotFound):
+		WriteErrorJSON(w, http.StatusNotFound, "La impresora no existe", err)
+	default:
+		h.Logger.Errorf("Error en la cola de '%s': %v", name, err)
+		WriteErrorJSON(w, http.StatusInternalServerError, "Error al administrar la cola de impresión", err)
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ============================
+// Cola de Impresión: Linux (CUPS)
+// ============================
+
+// newSystemQueueManager devuelve el QueueManager de las colas CUPS
+func newSystemQueueManager() QueueManager {
+	return CUPSQueueManager{}
+}
+
+// CUPSQueueManager administra las colas CUPS con lpq, cupsdisable, cupsenable y cancel
+type CUPSQueueManager struct{}
+
+// ListQueue lista los trabajos pendientes de la cola según "lpq -l"
+func (c CUPSQueueManager) ListQueue(printer string) ([]QueueJob, error) {
+	output, err := runCUPSTool("lpq", "-P", printer, "-l")
+	if err != nil {
+		return nil, cupsQueueError(printer, output, err)
+	}
+	return parseLpqLong(output), nil
+}
+
+// PauseQueue deshabilita la cola: CUPS conserva los trabajos sin imprimirlos
+func (c CUPSQueueManager) PauseQueue(printer string) error {
+	output, err := runCUPSTool("cupsdisable", printer)
+	return cupsQueueError(printer, output, err)
+}
+
+// ResumeQueue vuelve a habilitar la cola
+func (c CUPSQueueManager) ResumeQueue(printer string) error {
+	output, err := runCUPSTool("cupsenable", printer)
+	return cupsQueueError(printer, output, err)
+}
+
+// PurgeQueue cancela todos los trabajos de la cola y devuelve cuántos había
+func (c CUPSQueueManager) PurgeQueue(printer string) (int, error) {
+	jobs, err := c.ListQueue(printer)
+	if err != nil {
+		return 0, err
+	}
+	if output, err := runCUPSTool("cancel", "-a", printer); err != nil {
+		return 0, cupsQueueError(printer, output, err)
+	}
+	return len(jobs), nil
+}
+
+// cupsQueueError identifica las colas inexistentes para responder 404
+func cupsQueueError(printer, output string, err error) error {
+	if err != nil && (strings.Contains(output, "Unknown destination") || strings.Contains(output, "does not exist")) {
+		return fmt.Errorf("%w: '%s'", ErrPrinterNotFound, printer)
+	}
+	return err
+}
+
+// parseLpqLong interpreta la salida de "lpq -l": cada trabajo es una línea "usuario: orden  [job N host]"
+// seguida de una línea por archivo ("nombre  1024 bytes")
+func parseLpqLong(output string) []QueueJob {
+	var jobs []QueueJob
+	var current *QueueJob
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if head, rest, ok := strings.Cut(line, "[job "); ok {
+			user, rank, _ := strings.Cut(head, ":")
+			idText, host, _ := strings.Cut(strings.TrimSuffix(strings.TrimSpace(rest), "]"), " ")
+			id, err := strconv.ParseUint(idText, 10, 32)
+			if err != nil {
+				current = nil
+				continue
+			}
+			status := "Queued"
+			if strings.TrimSpace(rank) == "active" {
+				status = "Printing"
+			}
+			jobs = append(jobs, QueueJob{
+				ID:       uint32(id),
+				Status:   status,
+				States:   []string{status},
+				User:     strings.TrimSpace(user),
+				Machine:  host,
+				Position: len(jobs) + 1,
+			})
+			current = &jobs[len(jobs)-1]
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if current == nil || len(fields) < 3 || fields[len(fields)-1] != "bytes" {
+			continue
+		}
+		size, _ := strconv.ParseInt(fields[len(fields)-2], 10, 64)
+		name := strings.Join(fields[:len(fields)-2], " ")
+		if current.Document != "" {
+			name = current.Document + ", " + name
+		}
+		current.Document = name
+		current.Size += size
+	}
+	return jobs
+}
@@ -0,0 +1,75 @@
+package main
+
+import "fmt"
+
+// ============================
+// Cola de Impresión: Windows (spooler)
+// ============================
+
+// newSystemQueueManager devuelve el QueueManager de la cola del spooler de Windows
+func newSystemQueueManager() QueueManager {
+	return WindowsQueueManager{}
+}
+
+// WindowsQueueManager administra la cola del spooler con EnumJobs, SetJob y SetPrinter
+type WindowsQueueManager struct{}
+
+// ListQueue lista los documentos en la cola de la impresora
+func (w WindowsQueueManager) ListQueue(printer string) ([]QueueJob, error) {
+	jobs, err := enumSpoolerJobs(printer)
+	if err != nil {
+		return nil, spoolerQueueError(printer, err)
+	}
+	queue := make([]QueueJob, 0, len(jobs))
+	for _, job := range jobs {
+		states := job.StatusNames()
+		status := states[0]
+		if job.StatusText != "" {
+			// Texto informado por el controlador o el monitor de puerto (p. ej. "Sin papel")
+			status = job.StatusText
+		}
+		queue = append(queue, QueueJob{
+			ID:           job.ID,
+			Document:     job.Document,
+			Status:       status,
+			States:       states,
+			Pages:        int(job.TotalPages),
+			PagesPrinted: int(job.PagesPrinted),
+			User:         job.User,
+			Machine:      job.Machine,
+			Position:     int(job.Position),
+			SubmittedAt:  job.Submitted,
+		})
+	}
+	return queue, nil
+}
+
+// PauseQueue pausa la impresora: los documentos se conservan en la cola sin imprimirse
+func (w WindowsQueueManager) PauseQueue(printer string) error {
+	return spoolerQueueError(printer, controlSpoolerPrinter(printer, printerControlPause))
+}
+
+// ResumeQueue reanuda la impresión de la cola
+func (w WindowsQueueManager) ResumeQueue(printer string) error {
+	return spoolerQueueError(printer, controlSpoolerPrinter(printer, printerControlResume))
+}
+
+// PurgeQueue elimina todos los documentos de la cola y devuelve cuántos había
+func (w WindowsQueueManager) PurgeQueue(printer string) (int, error) {
+	jobs, err := enumSpoolerJobs(printer)
+	if err != nil {
+		return 0, spoolerQueueError(printer, err)
+	}
+	if err := controlSpoolerPrinter(printer, printerControlPurge); err != nil {
+		return 0, spoolerQueueError(printer, err)
+	}
+	return len(jobs), nil
+}
+
+// spoolerQueueError identifica las impresoras inexistentes para responder 404
+func spoolerQueueError(printer string, err error) error {
+	if err != nil && isPrinterNotFound(err) {
+		return fmt.Errorf("%w: '%s'", ErrPrinterNotFound, printer)
+	}
+	return err
+}
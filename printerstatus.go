@@ -0,0 +1,240 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// ============================
+// Estado en Tiempo Real de Impresoras ESC/POS
+// ============================
+
+// Modos de verificación de estado antes de imprimir
+const (
+	StatusCheckOff  = "off"  // no se consulta el estado
+	StatusCheckWarn = "warn" // se imprime igual, informando los problemas en la respuesta
+	StatusCheckFail = "fail" // se rechaza la impresión si la impresora no está lista
+)
+
+// escposDLE es el prefijo de los comandos de estado en tiempo real (DLE EOT n)
+const (
+	escposDLE = 0x10
+	escposEOT = 0x04
+)
+
+// PrinterReadiness describe si la impresora puede imprimir en este momento
+type PrinterReadiness struct {
+	Online       bool   `json:"online"`
+	CoverOpen    bool   `json:"cover_open"`
+	PaperNearEnd bool   `json:"paper_near_end"`
+	PaperOut     bool   `json:"paper_out"`
+	Error        bool   `json:"error"`
+	Source       string `json:"source"`
+}
+
+// Problems devuelve las condiciones que impiden imprimir
+func (p PrinterReadiness) Problems() []string {
+	var problems []string
+	if !p.Online {
+		problems = append(problems, "la impresora está fuera de línea")
+	}
+	if p.CoverOpen {
+		problems = append(problems, "la tapa está abierta")
+	}
+	if p.PaperOut {
+		problems = append(problems, "no tiene papel")
+	}
+	if p.Error {
+		problems = append(problems, "la impresora reporta un error")
+	}
+	return problems
+}
+
+// Warnings devuelve las condiciones que permiten imprimir pero requieren atención
+func (p PrinterReadiness) Warnings() []string {
+	if p.PaperNearEnd && !p.PaperOut {
+		return []string{"el papel está por agotarse"}
+	}
+	return nil
+}
+
+// ErrPrinterNotFound indica que la impresora consultada no está instalada
+var ErrPrinterNotFound = errors.New("la impresora no existe")
+
+// PrinterStatus es el estado detallado de una impresora informado en GET /printers/{name}/status
+type PrinterStatus struct {
+	Printer   string            `json:"printer"`
+	Status    string            `json:"status"`
+	States    []string          `json:"states"`
+	Ready     bool              `json:"ready"`
+	Online    bool              `json:"online"`
+	PaperOut  bool              `json:"paper_out"`
+	PaperJam  bool              `json:"paper_jam"`
+	DoorOpen  bool              `json:"door_open"`
+	Error     bool              `json:"error"`
+	Paused    bool              `json:"paused"`
+	TonerLow  bool              `json:"toner_low"`
+	Jobs      int               `json:"jobs"`
+	Problems  []string          `json:"problems,omitempty"`
+	Warnings  []string          `json:"warnings,omitempty"`
+	Supplies  []PrinterSupply   `json:"supplies,omitempty"`
+	Trays     []PaperTray       `json:"trays,omitempty"`
+	Device    *PrinterReadiness `json:"device,omitempty"`
+	Source    string            `json:"source"`
+	CheckedAt time.Time         `json:"checked_at"`
+}
+
+// finish calcula los problemas y si la impresora está lista a partir de los indicadores
+func (s *PrinterStatus) finish() {
+	s.Problems = nil
+	if !s.Online {
+		s.Problems = append(s.Problems, "la impresora está fuera de línea")
+	}
+	if s.DoorOpen {
+		s.Problems = append(s.Problems, "la tapa está abierta")
+	}
+	if s.PaperOut {
+		s.Problems = append(s.Problems, "no tiene papel")
+	}
+	if s.PaperJam {
+		s.Problems = append(s.Problems, "hay papel atascado")
+	}
+	if s.Error {
+		s.Problems = append(s.Problems, "la impresora reporta un error")
+	}
+	if s.Paused {
+		s.Problems = append(s.Problems, "la cola de impresión está pausada")
+	}
+	s.Ready = len(s.Problems) == 0
+}
+
+// MergeDevice incorpora el estado consultado directamente al dispositivo (p. ej. DLE EOT), que es
+// más confiable que el del spooler en impresoras térmicas de red
+func (s *PrinterStatus) MergeDevice(device *PrinterReadiness) {
+	s.Device = device
+	s.Online = s.Online && device.Online
+	s.DoorOpen = s.DoorOpen || device.CoverOpen
+	s.PaperOut = s.PaperOut || device.PaperOut
+	s.Error = s.Error || device.Error
+	s.finish()
+}
+
+// StatusChecker consulta el estado de una impresora
+type StatusChecker interface {
+	CheckStatus(printer string) (*PrinterReadiness, error)
+}
+
+// PrinterNotReadyError indica que la verificación previa rechazó la impresión
+type PrinterNotReadyError struct {
+	Printer  string
+	Problems []string
+	PaperOut bool
+}
+
+func (e *PrinterNotReadyError) Error() string {
+	return fmt.Sprintf("la impresora '%s' no está lista: %s", e.Printer, strings.Join(e.Problems, ", "))
+}
+
+// ESCPOSStatusChecker consulta el estado con DLE EOT por TCP cuando la impresora tiene dirección de red;
+// en impresoras USB/serie (sin canal de retorno) usa el estado reportado por el spooler.
+type ESCPOSStatusChecker struct {
+	Addresses map[string]string
+	Timeout   time.Duration
+}
+
+// CheckStatus consulta el estado de la impresora
+func (c ESCPOSStatusChecker) CheckStatus(printer string) (*PrinterReadiness, error) {
+	if address, err := resolvePrinterAddress(c.Addresses, printer); err == nil {
+		return QueryESCPOSStatus(address, c.Timeout)
+	}
+	return spoolerReadiness(printer)
+}
+
+// QueryESCPOSStatus envía DLE EOT 1-4 al puerto RAW y decodifica las respuestas
+func QueryESCPOSStatus(address string, timeout time.Duration) (*PrinterReadiness, error) {
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, defaultRawPort)
+	}
+	conn, err := net.DialTimeout("tcp", address, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo conectar a la impresora %s: %w", address, err)
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+	return queryESCPOSStatus(conn, address)
+}
+
+// queryESCPOSStatus envía DLE EOT 1-4 por una conexión ya abierta (TCP o puerto serie)
+func queryESCPOSStatus(conn io.ReadWriter, address string) (*PrinterReadiness, error) {
+	var status [4]byte
+	for i := range status {
+		if _, err := conn.Write([]byte{escposDLE, escposEOT, byte(i + 1)}); err != nil {
+			return nil, fmt.Errorf("error al consultar el estado de %s: %w", address, err)
+		}
+		if _, err := io.ReadFull(conn, status[i:i+1]); err != nil {
+			return nil, fmt.Errorf("la impresora %s no respondió a la consulta de estado: %w", address, err)
+		}
+	}
+	return decodeESCPOSStatus(status), nil
+}
+
+// decodeESCPOSStatus interpreta las respuestas de DLE EOT 1 (impresora), 2 (fuera de línea),
+// 3 (errores) y 4 (sensores de papel)
+func decodeESCPOSStatus(s [4]byte) *PrinterReadiness {
+	return &PrinterReadiness{
+		Online:       s[0]&0x08 == 0,
+		CoverOpen:    s[1]&0x04 != 0,
+		PaperOut:     s[1]&0x20 != 0 || s[3]&0x60 != 0,
+		PaperNearEnd: s[3]&0x0C != 0,
+		Error:        s[1]&0x40 != 0 || s[2]&0x6C != 0,
+		Source:       "escpos",
+	}
+}
+
+// StatusPreflight verifica el estado de las impresoras configuradas antes de imprimir
+type StatusPreflight struct {
+	Checker  StatusChecker
+	Mode     string
+	Printers []string
+	Logger   *Logger
+}
+
+// applies indica si la verificación está habilitada para la impresora ("*" incluye todas)
+func (p *StatusPreflight) applies(printer string) bool {
+	if p == nil || p.Checker == nil || p.Mode == StatusCheckOff {
+		return false
+	}
+	return matchesPrinter(p.Printers, printer)
+}
+
+// Check devuelve las advertencias a informar y, en modo fail, un *PrinterNotReadyError si la impresora
+// no está lista. Si el estado no puede consultarse se advierte, pero no se bloquea la impresión.
+func (p *StatusPreflight) Check(printer string) ([]string, error) {
+	if !p.applies(printer) {
+		return nil, nil
+	}
+
+	readiness, err := p.Checker.CheckStatus(printer)
+	if err != nil {
+		p.Logger.Warnf("No se pudo consultar el estado de '%s': %v", printer, err)
+		return []string{"no se pudo consultar el estado de la impresora"}, nil
+	}
+
+	warnings := readiness.Warnings()
+	if problems := readiness.Problems(); len(problems) > 0 {
+		if p.Mode == StatusCheckFail {
+			return warnings, &PrinterNotReadyError{Printer: printer, Problems: problems, PaperOut: readiness.PaperOut}
+		}
+		warnings = append(problems, warnings...)
+	}
+	if len(warnings) > 0 {
+		p.Logger.Warnf("Estado de '%s' (%s): %s", printer, readiness.Source, strings.Join(warnings, ", "))
+	}
+	return warnings, nil
+}
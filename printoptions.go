@@ -0,0 +1,375 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ============================
+// Opciones de Impresión
+// ============================
+
+// Valores aceptados para las opciones de impresión
+const (
+	DuplexNone      = "none"
+	DuplexLongEdge  = "long-edge"
+	DuplexShortEdge = "short-edge"
+
+	OrientationPortrait  = "portrait"
+	OrientationLandscape = "landscape"
+
+	TrayAuto          = "auto"
+	TrayManual        = "manual"
+	TrayUpper         = "upper"
+	TrayMiddle        = "middle"
+	TrayLower         = "lower"
+	TrayEnvelope      = "envelope"
+	TrayLargeCapacity = "large-capacity"
+
+	ColorColor     = "color"
+	ColorGrayscale = "grayscale"
+
+	QualityDraft  = "draft"
+	QualityNormal = "normal"
+	QualityHigh   = "high"
+
+	ScaleFit    = "fit"
+	ScaleShrink = "shrink"
+	ScaleNone   = "none"
+)
+
+// scaleAliases son los otros nombres aceptados para las escalas
+var scaleAliases = map[string]string{
+	"fit-to-page":   ScaleFit,
+	"shrink-to-fit": ScaleShrink,
+	"actual":        ScaleNone,
+	"actual-size":   ScaleNone,
+	"noscale":       ScaleNone,
+}
+
+// Límites de la escala personalizada, en porcentaje (los de DEVMODE.dmScale)
+const (
+	minScalePercent = 10
+	maxScalePercent = 400
+)
+
+// qualityLevels mapea las calidades a las constantes DMRES_* de Windows y a los valores del enum
+// print-quality de IPP
+var qualityLevels = map[string]struct {
+	Res int16
+	IPP int
+}{
+	QualityDraft:  {-1, 3},
+	QualityNormal: {-3, 4},
+	QualityHigh:   {-4, 5},
+}
+
+// trayKeywords mapea las bandejas genéricas a las constantes DMBIN_* de Windows y a los valores de
+// media-source de IPP (PWG 5100.7)
+var trayKeywords = map[string]struct {
+	Bin    int16
+	Source string
+}{
+	TrayAuto:          {7, "auto"},
+	TrayManual:        {4, "manual"},
+	TrayUpper:         {1, "top"},
+	TrayMiddle:        {3, "middle"},
+	TrayLower:         {2, "bottom"},
+	TrayEnvelope:      {5, "envelope"},
+	TrayLargeCapacity: {11, "large-capacity"},
+}
+
+// trayNumberPattern reconoce las bandejas numeradas ("tray-1", "tray-2"...)
+var trayNumberPattern = regexp.MustCompile(`^tray-([1-9]|[1-9][0-9])$`)
+
+// maxTrayLength limita el nombre de la bandeja (los controladores de Windows usan hasta 24 caracteres)
+const maxTrayLength = 64
+
+// maxDownloadTimeout limita el download_timeout de una solicitud, en segundos
+const maxDownloadTimeout = 600
+
+// maxCopies limita las copias por solicitud para evitar errores de digitación (p. ej. 200 en lugar de 2)
+const maxCopies = 99
+
+// paperSizes mapea los nombres de papel aceptados a las constantes DMPAPER_* de Windows
+var paperSizes = map[string]int16{
+	"letter":    1,
+	"legal":     5,
+	"executive": 7,
+	"a3":        8,
+	"a4":        9,
+	"a5":        11,
+	"b5":        13,
+}
+
+// pageRangePattern valida rangos como "1", "1-3" o "1-3,5,8-10"
+var pageRangePattern = regexp.MustCompile(`^\d+(-\d+)?(,\d+(-\d+)?)*$`)
+
+// PrintOptions son las opciones opcionales de una solicitud de impresión.
+// Los valores vacíos (o cero) conservan la configuración predeterminada de la impresora.
+type PrintOptions struct {
+	Copies      int    `json:"copies,omitempty"`
+	Duplex      string `json:"duplex,omitempty"`
+	Orientation string `json:"orientation,omitempty"`
+	PaperSize   string `json:"paper_size,omitempty"`
+	// Tray es la bandeja de papel: auto, manual, upper, middle, lower, envelope, large-capacity,
+	// tray-N o el nombre que le da el controlador (p. ej. "Bandeja 2")
+	Tray string `json:"tray,omitempty"`
+	// Color imprime en color o en escala de grises (color, grayscale) y Quality elige la calidad del
+	// controlador (draft, normal, high); vacíos conservan la configuración de la impresora
+	Color   string `json:"color,omitempty"`
+	Quality string `json:"quality,omitempty"`
+	// Scale ajusta el documento a la hoja: fit (ampliar o reducir), shrink (reducir solo lo que no
+	// cabe), none (tamaño real) o un porcentaje como "90%"; vacío conserva el del motor
+	Scale  string `json:"scale,omitempty"`
+	Pages  string `json:"pages,omitempty"`
+	Engine string `json:"engine,omitempty"`
+	Stamp  string `json:"stamp,omitempty"`
+	// StampImage es el nombre de una imagen de STAMP_IMAGES_DIR superpuesta a cada página y
+	// StampPosition ubica el sello y la imagen: center (por defecto), top o bottom
+	StampImage    string `json:"stamp_image,omitempty"`
+	StampPosition string `json:"stamp_position,omitempty"`
+	// CopyLabel sella las copias posteriores a la primera (p. ej. "COPIA"); la primera sale como original
+	CopyLabel string `json:"copy_label,omitempty"`
+
+	// Retries reintenta el trabajo ante fallas transitorias (nil usa PRINT_RETRIES) y RetryDelay es la
+	// espera en milisegundos antes del primer reintento, que se duplica en cada intento
+	Retries    *int `json:"retries,omitempty"`
+	RetryDelay int  `json:"retry_delay,omitempty"`
+
+	// DownloadTimeout es el tiempo máximo en segundos para descargar el documento de url (0 usa
+	// DOWNLOAD_TIMEOUT_SECONDS)
+	DownloadTimeout int `json:"download_timeout,omitempty"`
+
+	// JobID identifica el trabajo en curso para conservar su documento y permitir la reimpresión
+	JobID string `json:"-"`
+}
+
+// Normalize valida las opciones y las deja en su forma canónica
+func (o *PrintOptions) Normalize() error {
+	if o.Copies < 0 || o.Copies > maxCopies {
+		return fmt.Errorf("cantidad de copias inválida: %d (máximo %d)", o.Copies, maxCopies)
+	}
+
+	o.Duplex = strings.ToLower(strings.TrimSpace(o.Duplex))
+	switch o.Duplex {
+	case "", DuplexNone, DuplexLongEdge, DuplexShortEdge:
+	default:
+		return fmt.Errorf("valor de duplex inválido: %s (use none, long-edge o short-edge)", o.Duplex)
+	}
+
+	o.Orientation = strings.ToLower(strings.TrimSpace(o.Orientation))
+	switch o.Orientation {
+	case "", OrientationPortrait, OrientationLandscape:
+	default:
+		return fmt.Errorf("orientación inválida: %s (use portrait o landscape)", o.Orientation)
+	}
+
+	o.PaperSize = strings.ToLower(strings.TrimSpace(o.PaperSize))
+	if _, ok := paperSizes[o.PaperSize]; o.PaperSize != "" && !ok {
+		return fmt.Errorf("tamaño de papel no soportado: %s", o.PaperSize)
+	}
+
+	o.Tray = strings.TrimSpace(o.Tray)
+	if lower := strings.ToLower(o.Tray); trayNumberPattern.MatchString(lower) {
+		o.Tray = lower
+	} else if _, ok := trayKeywords[lower]; ok {
+		o.Tray = lower
+	}
+	if len(o.Tray) > maxTrayLength {
+		return fmt.Errorf("tray no puede superar %d caracteres", maxTrayLength)
+	}
+	// Las comas y comillas romperían los ajustes de SumatraPDF y las opciones de lp
+	if strings.ContainsAny(o.Tray, ",=\"") || strings.IndexFunc(o.Tray, unicode.IsControl) >= 0 {
+		return fmt.Errorf("tray inválido: %q", o.Tray)
+	}
+
+	o.Color = strings.ToLower(strings.TrimSpace(o.Color))
+	switch o.Color {
+	case "", ColorColor, ColorGrayscale:
+	case "monochrome", "mono":
+		o.Color = ColorGrayscale
+	default:
+		return fmt.Errorf("valor de color inválido: %s (use color o grayscale)", o.Color)
+	}
+
+	o.Quality = strings.ToLower(strings.TrimSpace(o.Quality))
+	if _, ok := qualityLevels[o.Quality]; o.Quality != "" && !ok {
+		return fmt.Errorf("calidad inválida: %s (use draft, normal o high)", o.Quality)
+	}
+
+	o.Scale = strings.ToLower(strings.TrimSpace(o.Scale))
+	if alias, ok := scaleAliases[o.Scale]; ok {
+		o.Scale = alias
+	}
+	switch o.Scale {
+	case "", ScaleFit, ScaleShrink, ScaleNone:
+	default:
+		percent, err := strconv.Atoi(strings.TrimSpace(strings.TrimSuffix(o.Scale, "%")))
+		if err != nil || percent < minScalePercent || percent > maxScalePercent {
+			return fmt.Errorf("escala inválida: %s (use fit, shrink, none o un porcentaje entre %d%% y %d%%)", o.Scale, minScalePercent, maxScalePercent)
+		}
+		o.Scale = strconv.Itoa(percent) + "%"
+	}
+
+	o.Engine = strings.ToLower(strings.TrimSpace(o.Engine))
+
+	o.Stamp = strings.TrimSpace(o.Stamp)
+	if len([]rune(o.Stamp)) > maxStampLength {
+		return fmt.Errorf("el sello no puede superar %d caracteres", maxStampLength)
+	}
+	o.StampImage = strings.TrimSpace(o.StampImage)
+	if o.StampImage != "" && !stampImageNamePattern.MatchString(o.StampImage) {
+		return fmt.Errorf("stamp_image inválido: %s (use el nombre de la imagen, sin ruta ni extensión)", o.StampImage)
+	}
+	o.StampPosition = strings.ToLower(strings.TrimSpace(o.StampPosition))
+	switch o.StampPosition {
+	case "", StampPositionCenter, StampPositionTop, StampPositionBottom:
+	default:
+		return fmt.Errorf("stamp_position inválido: %s (use center, top o bottom)", o.StampPosition)
+	}
+	o.CopyLabel = strings.TrimSpace(o.CopyLabel)
+	if len([]rune(o.CopyLabel)) > maxStampLength {
+		return fmt.Errorf("copy_label no puede superar %d caracteres", maxStampLength)
+	}
+
+	if o.Retries != nil && (*o.Retries < 0 || *o.Retries > maxRetries) {
+		return fmt.Errorf("cantidad de reintentos inválida: %d (máximo %d)", *o.Retries, maxRetries)
+	}
+	if o.RetryDelay < 0 || time.Duration(o.RetryDelay)*time.Millisecond > maxRetryDelay {
+		return fmt.Errorf("espera entre reintentos inválida: %dms (máximo %s)", o.RetryDelay, maxRetryDelay)
+	}
+
+	if o.DownloadTimeout < 0 || o.DownloadTimeout > maxDownloadTimeout {
+		return fmt.Errorf("tiempo de descarga inválido: %ds (máximo %d)", o.DownloadTimeout, maxDownloadTimeout)
+	}
+
+	o.Pages = strings.ReplaceAll(o.Pages, " ", "")
+	if o.Pages != "" && (!pageRangePattern.MatchString(o.Pages) || !validPageRanges(o.Pages)) {
+		return fmt.Errorf("rango de páginas inválido: %s", o.Pages)
+	}
+	return nil
+}
+
+// validPageRanges comprueba que cada página sea al menos 1 y que ningún rango termine antes de
+// empezar (p. ej. "0" o "5-2"); el formato ya lo validó pageRangePattern
+func validPageRanges(pages string) bool {
+	for _, part := range strings.Split(pages, ",") {
+		from, to, isRange := strings.Cut(part, "-")
+		first, err := strconv.Atoi(from)
+		if err != nil || first < 1 {
+			return false
+		}
+		if isRange {
+			last, err := strconv.Atoi(to)
+			if err != nil || last < first {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// NeedsDevMode indica si alguna opción requiere modificar la configuración del controlador (DEVMODE)
+func (o PrintOptions) NeedsDevMode() bool {
+	return o.Duplex != "" || o.Orientation != "" || o.PaperSize != "" || o.Tray != "" ||
+		o.Color != "" || o.Quality != "" || o.ScalePercent() > 0
+}
+
+// ScalePercent devuelve el porcentaje de una escala personalizada (0 si no es un porcentaje)
+func (o PrintOptions) ScalePercent() int {
+	percent, err := strconv.Atoi(strings.TrimSuffix(o.Scale, "%"))
+	if err != nil {
+		return 0
+	}
+	return percent
+}
+
+// MediaSource devuelve la bandeja como valor de media-source de IPP y CUPS, e indica si es una
+// bandeja genérica; los nombres propios del controlador se devuelven tal cual
+func (o PrintOptions) MediaSource() (string, bool) {
+	if t, ok := trayKeywords[o.Tray]; ok {
+		return t.Source, true
+	}
+	return o.Tray, trayNumberPattern.MatchString(o.Tray)
+}
+
+// PDFtoPrinterArgs traduce las opciones soportadas por la línea de comandos de PDFtoPrinter
+func (o PrintOptions) PDFtoPrinterArgs() []string {
+	var args []string
+	if o.Pages != "" {
+		args = append(args, "pages="+o.Pages)
+	}
+	if o.Copies > 1 {
+		args = append(args, "copies="+strconv.Itoa(o.Copies))
+	}
+	return args
+}
+
+// ParsePrintOptionsForm obtiene las opciones desde los campos de un formulario (multipart)
+func ParsePrintOptionsForm(get func(string) string) (PrintOptions, error) {
+	opts := PrintOptions{
+		Duplex:      get("duplex"),
+		Orientation: get("orientation"),
+		PaperSize:   get("paper_size"),
+		Tray:        get("tray"),
+		Color:       get("color"),
+		Quality:     get("quality"),
+		Scale:       get("scale"),
+		Pages:       get("pages"),
+		Engine:      get("engine"),
+		Stamp:       get("stamp"),
+		CopyLabel:   get("copy_label"),
+	}
+	opts.StampImage, opts.StampPosition = get("stamp_image"), get("stamp_position")
+	if copies := get("copies"); copies != "" {
+		n, err := strconv.Atoi(copies)
+		if err != nil {
+			return opts, fmt.Errorf("cantidad de copias inválida: %s", copies)
+		}
+		opts.Copies = n
+	}
+	if retries := get("retries"); retries != "" {
+		n, err := strconv.Atoi(retries)
+		if err != nil {
+			return opts, fmt.Errorf("cantidad de reintentos inválida: %s", retries)
+		}
+		opts.Retries = &n
+	}
+	if delay := get("retry_delay"); delay != "" {
+		n, err := strconv.Atoi(delay)
+		if err != nil {
+			return opts, fmt.Errorf("espera entre reintentos inválida: %s", delay)
+		}
+		opts.RetryDelay = n
+	}
+	return opts, opts.Normalize()
+}
+
+// PageIndexes devuelve las páginas a imprimir (base 0) de un documento de count páginas según
+// el rango de Pages; sin rango se imprimen todas. Las páginas fuera del documento se ignoran.
+func (o PrintOptions) PageIndexes(count int) []int {
+	var pages []int
+	if o.Pages == "" {
+		for i := 0; i < count; i++ {
+			pages = append(pages, i)
+		}
+		return pages
+	}
+	for _, part := range strings.Split(o.Pages, ",") {
+		from, to, isRange := strings.Cut(part, "-")
+		first, _ := strconv.Atoi(from)
+		last := first
+		if isRange {
+			last, _ = strconv.Atoi(to)
+		}
+		for p := max(first, 1); p <= min(last, count); p++ {
+			pages = append(pages, p-1)
+		}
+	}
+	return pages
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// prepareProcessTree inicia la herramienta en su propio grupo de procesos para poder terminarla
+// junto con los procesos que lance (filtros de CUPS, intérpretes de scripts)
+func prepareProcessTree(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// killProcessTree termina el grupo de procesos de la herramienta
+func killProcessTree(cmd *exec.Cmd) error {
+	if cmd.Process == nil {
+		return nil
+	}
+	if err := syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL); err != nil {
+		return cmd.Process.Kill()
+	}
+	return nil
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"os/exec"
+	"strconv"
+)
+
+// prepareProcessTree no hace nada en Windows: el árbol de procesos se termina con taskkill
+func prepareProcessTree(cmd *exec.Cmd) {}
+
+// killProcessTree termina la herramienta y los procesos que lanzó (p. ej. el visor que abre
+// PDFtoPrinter), que de otro modo seguirían bloqueando el archivo y la impresora
+func killProcessTree(cmd *exec.Cmd) error {
+	if cmd.Process == nil {
+		return nil
+	}
+	kill := exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(cmd.Process.Pid))
+	hideWindow(kill)
+	if err := kill.Run(); err != nil {
+		return cmd.Process.Kill()
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// ============================
+// Perfiles de Configuración
+// ============================
+
+// activeProfile es el perfil con el que se cargó la configuración; getEnv lo consulta para
+// aplicar los valores PROFILE_<PERFIL>_<VARIABLE> antes que la variable general.
+var activeProfile string
+
+// defaultProfileStatePath guarda el perfil elegido por la API o la CLI para los próximos inicios
+const defaultProfileStatePath = "./active_profile"
+
+// profileEnvKey devuelve la variable de entorno que sobrescribe key en el perfil indicado
+func profileEnvKey(profile, key string) string {
+	name := strings.ToUpper(strings.ReplaceAll(profile, "-", "_"))
+	return "PROFILE_" + name + "_" + key
+}
+
+// lookupEnv busca una variable de entorno dando prioridad al perfil activo. Si no está definida
+// en el entorno se usa el archivo de configuración (sección del perfil y luego valores generales).
+func lookupEnv(key string) (string, bool) {
+	if activeProfile != "" {
+		if val, ok := os.LookupEnv(profileEnvKey(activeProfile, key)); ok {
+			return val, true
+		}
+	}
+	if val, ok := os.LookupEnv(key); ok {
+		return val, true
+	}
+	return configFile.Lookup(activeProfile, key)
+}
+
+// lookupGlobal busca una variable que no depende del perfil en el entorno o en el archivo de configuración
+func lookupGlobal(key string) string {
+	if val, ok := os.LookupEnv(key); ok {
+		return val
+	}
+	return configFile.Values[key]
+}
+
+// profileStatePath devuelve el archivo donde se recuerda el perfil activo
+func profileStatePath() string {
+	if path := os.Getenv("PROFILE_STATE_FILE"); path != "" {
+		return path
+	}
+	return defaultProfileStatePath
+}
+
+// selectProfile determina el perfil de inicio: el último elegido por la API o la CLI
+// (archivo de estado) o, si no hay ninguno, la variable PROFILE.
+func selectProfile() string {
+	if data, err := os.ReadFile(profileStatePath()); err == nil {
+		if name := strings.TrimSpace(string(data)); name != "" {
+			return name
+		}
+	}
+	return strings.TrimSpace(lookupGlobal("PROFILE"))
+}
+
+// availableProfiles devuelve los perfiles declarados en PROFILES y en la sección profiles del archivo
+func availableProfiles() []string {
+	profiles := splitAndTrim(lookupGlobal("PROFILES"), ",")
+	for _, name := range configFile.ProfileNames() {
+		if validateProfile(name, profiles) != nil {
+			profiles = append(profiles, name)
+		}
+	}
+	return profiles
+}
+
+// validateProfile verifica que el perfil esté declarado (el perfil vacío usa la configuración general)
+func validateProfile(name string, profiles []string) error {
+	if name == "" {
+		return nil
+	}
+	for _, p := range profiles {
+		if strings.EqualFold(p, name) {
+			return nil
+		}
+	}
+	return fmt.Errorf("perfil desconocido: %s (perfiles disponibles: %s)", name, strings.Join(profiles, ", "))
+}
+
+// SaveActiveProfile valida y guarda el perfil que se usará a partir del próximo inicio o recarga
+func SaveActiveProfile(name string, profiles []string) error {
+	if err := validateProfile(name, profiles); err != nil {
+		return err
+	}
+	return os.WriteFile(profileStatePath(), []byte(name), 0o644)
+}
+
+// ProfileHandlers permite consultar y cambiar el perfil activo desde la API administrativa
+type ProfileHandlers struct {
+	Active   string
+	Profiles []string
+	Reload   chan<- string
+	Logger   *Logger
+}
+
+// ProfileHandler devuelve el perfil activo (GET) o cambia de perfil y recarga el servidor (POST)
+func (h ProfileHandlers) ProfileHandler(w http.ResponseWriter, r *http.Request) {
+	h.Logger = h.Logger.ForRequest(r)
+	h.Logger.Info("Received request: /admin/profile")
+
+	switch r.Method {
+	case http.MethodGet:
+		WriteJSON(w, http.StatusOK, map[string]interface{}{"active": h.Active, "profiles": h.Profiles})
+	case http.MethodPost:
+		var req struct {
+			Profile string `json:"profile"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&req); err != nil {
+			WriteErrorJSON(w, http.StatusBadRequest, "Solicitud JSON inválida", err)
+			return
+		}
+		profile := strings.TrimSpace(req.Profile)
+		if err := validateProfile(profile, h.Profiles); err != nil {
+			WriteErrorJSON(w, http.StatusBadRequest, "No se pudo cambiar de perfil", err)
+			return
+		}
+
+		// El perfil se guarda después de construir el servidor con él; si su configuración es
+		// inválida se mantiene el perfil actual
+		h.Logger.Infof("Cambiando de perfil de '%s' a '%s'; recargando la configuración", h.Active, profile)
+		WriteJSON(w, http.StatusAccepted, map[string]interface{}{"active": profile, "message": "Recargando el servidor con el nuevo perfil."})
+		select {
+		case h.Reload <- profile:
+		default:
+		}
+	default:
+		WriteErrorJSON(w, http.StatusMethodNotAllowed, "Método HTTP no permitido", nil)
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ============================
+// Límite de Solicitudes (token bucket)
+// ============================
+
+// rateLimitIdle es el tiempo tras el cual se descarta el contador de una IP sin solicitudes
+const rateLimitIdle = 10 * time.Minute
+
+// RateLimitConfig configura los límites de las rutas que imprimen o abren el cajón, para frenar los
+// bucles de reintento del ERP que envían el mismo documento cientos de veces
+type RateLimitConfig struct {
+	// PerIPPerMinute y PerIPBurst limitan cada dirección de origen (0 desactiva el límite)
+	PerIPPerMinute int
+	PerIPBurst     int
+	// GlobalPerMinute y GlobalBurst limitan la suma de todas las direcciones (0 desactiva el límite)
+	GlobalPerMinute int
+	GlobalBurst     int
+	// Routes son las rutas limitadas
+	Routes []string
+}
+
+// LoadRateLimitConfig carga los límites desde variables de entorno
+func LoadRateLimitConfig() RateLimitConfig {
+	return RateLimitConfig{
+		PerIPPerMinute:  getEnvAsInt("RATE_LIMIT_PER_IP", 120),
+		PerIPBurst:      getEnvAsInt("RATE_LIMIT_PER_IP_BURST", 30),
+		GlobalPerMinute: getEnvAsInt("RATE_LIMIT_GLOBAL", 0),
+		GlobalBurst:     getEnvAsInt("RATE_LIMIT_GLOBAL_BURST", 60),
+		Routes: getEnvAsSlice("RATE_LIMIT_ROUTES",
+			"/print,/print-file,/print-batch,/open-box,/print-label,/print-receipt,/print-order,/print-image,/print-text,/printer-command,/jobs/{id}/reprint"),
+	}
+}
+
+// tokenBucket acumula permisos a razón de rate por segundo, hasta burst
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// take descuenta un permiso si lo hay; si no, devuelve cuánto falta para el siguiente
+func (b *tokenBucket) take(now time.Time, rate, burst float64) (bool, time.Duration) {
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / rate * float64(time.Second))
+}
+
+// RateLimiter aplica el límite por IP y el global a las rutas configuradas
+type RateLimiter struct {
+	perIPRate, perIPBurst   float64
+	globalRate, globalBurst float64
+	routes                  map[string]bool
+	Metrics                 *Metrics
+	Logger                  *Logger
+
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	global    *tokenBucket
+	lastSweep time.Time
+}
+
+// NewRateLimiter crea el limitador; sin límites configurados devuelve nil
+func NewRateLimiter(cfg RateLimitConfig, metrics *Metrics, logger *Logger) *RateLimiter {
+	if (cfg.PerIPPerMinute <= 0 && cfg.GlobalPerMinute <= 0) || len(cfg.Routes) == 0 {
+		return nil
+	}
+	l := &RateLimiter{
+		perIPRate:   float64(cfg.PerIPPerMinute) / 60,
+		perIPBurst:  float64(max(cfg.PerIPBurst, 1)),
+		globalRate:  float64(cfg.GlobalPerMinute) / 60,
+		globalBurst: float64(max(cfg.GlobalBurst, 1)),
+		routes:      make(map[string]bool, len(cfg.Routes)),
+		Metrics:     metrics,
+		Logger:      logger,
+		buckets:     make(map[string]*tokenBucket),
+	}
+	for _, route := range cfg.Routes {
+		if trimmed := strings.TrimPrefix(route, apiPrefix); strings.HasPrefix(trimmed, "/") {
+			route = trimmed
+		}
+		l.routes[route] = true
+	}
+	if l.globalRate > 0 {
+		l.global = &tokenBucket{tokens: l.globalBurst, last: time.Now()}
+	}
+	return l
+}
+
+// allow decide si la dirección puede hacer otra solicitud; scope indica qué límite se alcanzó
+func (l *RateLimiter) allow(ip string) (ok bool, retryAfter time.Duration, scope string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	l.sweep(now)
+
+	var bucket *tokenBucket
+	if l.perIPRate > 0 {
+		bucket = l.buckets[ip]
+		if bucket == nil {
+			bucket = &tokenBucket{tokens: l.perIPBurst, last: now}
+			l.buckets[ip] = bucket
+		}
+		if ok, wait := bucket.take(now, l.perIPRate, l.perIPBurst); !ok {
+			return false, wait, "ip"
+		}
+	}
+	if l.global != nil {
+		if ok, wait := l.global.take(now, l.globalRate, l.globalBurst); !ok {
+			// La solicitud rechazada no consume el permiso de la IP
+			if bucket != nil {
+				bucket.tokens++
+			}
+			return false, wait, "global"
+		}
+	}
+	return true, 0, ""
+}
+
+// sweep descarta cada tanto los contadores de las IP inactivas, que ya estarían llenos
+func (l *RateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+	for ip, bucket := range l.buckets {
+		if now.Sub(bucket.last) > rateLimitIdle {
+			delete(l.buckets, ip)
+		}
+	}
+}
+
+// Limits indica si la ruta está limitada
+func (l *RateLimiter) Limits(route string) bool {
+	return l != nil && l.routes[route]
+}
+
+// Wrap limita el manejador si la ruta está en RATE_LIMIT_ROUTES; responde 429 con Retry-After
+func (l *RateLimiter) Wrap(route string, next http.Handler) http.Handler {
+	if !l.Limits(route) {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			ip = r.RemoteAddr
+		}
+		ok, wait, scope := l.allow(ip)
+		if !ok {
+			seconds := int(math.Ceil(wait.Seconds()))
+			l.Logger.ForRequest(r).Warnf("Solicitud a %s desde %s rechazada por el límite %s (reintentar en %ds)", r.URL.Path, ip, scope, seconds)
+			if l.Metrics != nil {
+				l.Metrics.rateLimited.WithLabelValues(route, scope).Inc()
+			}
+			w.Header().Set("Retry-After", strconv.Itoa(seconds))
+			WriteErrorCodeJSON(w, http.StatusTooManyRequests, ErrCodeRateLimited, "Demasiadas solicitudes; reintente más tarde", nil)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// ============================
+// Envío de Datos RAW a Impresoras
+// ============================
+
+// defaultRawPort es el puerto RAW/JetDirect estándar de las impresoras de red
+const defaultRawPort = "9100"
+
+// WriteRawTCP envía datos directamente al puerto RAW de una impresora de red
+func WriteRawTCP(address string, data []byte, timeout time.Duration) error {
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, defaultRawPort)
+	}
+
+	conn, err := net.DialTimeout("tcp", address, timeout)
+	if err != nil {
+		return fmt.Errorf("no se pudo conectar a la impresora %s: %w", address, err)
+	}
+	defer conn.Close()
+
+	if err := conn.SetWriteDeadline(time.Now().Add(timeout)); err != nil {
+		return err
+	}
+	if _, err := conn.Write(data); err != nil {
+		return fmt.Errorf("error al enviar datos a la impresora %s: %w", address, err)
+	}
+	return nil
+}
+
+// addressFromPortName obtiene la dirección IP de un puerto TCP/IP estándar de Windows
+// (nombres como "IP_192.168.1.50" o "192.168.1.50"); devuelve "" si el puerto no es de red
+func addressFromPortName(portName string) string {
+	host := strings.TrimPrefix(portName, "IP_")
+	if ip := net.ParseIP(host); ip != nil {
+		return net.JoinHostPort(ip.String(), defaultRawPort)
+	}
+	return ""
+}
+
+// RawWriter envía datos crudos (ESC/POS, ZPL, etc.) a una impresora sin pasar por su controlador
+type RawWriter interface {
+	WriteRaw(printer string, data []byte) error
+}
+
+// TCPRawWriter envía los datos por TCP (puerto 9100) a la dirección configurada para la impresora
+// o, si no hay una, a la IP de su puerto TCP/IP estándar de Windows.
+type TCPRawWriter struct {
+	Addresses map[string]string
+	Timeout   time.Duration
+}
+
+// WriteRaw envía los datos por TCP a la impresora indicada
+func (t TCPRawWriter) WriteRaw(printer string, data []byte) error {
+	address, err := resolvePrinterAddress(t.Addresses, printer)
+	if err != nil {
+		return err
+	}
+	return WriteRawTCP(address, data, t.Timeout)
+}
+
+// resolvePrinterAddress devuelve la dirección de red configurada para la impresora o, si no hay una,
+// la del puerto de la cola del sistema (puerto TCP/IP estándar de Windows o dispositivo socket:// de CUPS)
+func resolvePrinterAddress(addresses map[string]string, printer string) (string, error) {
+	if address := addresses[printer]; address != "" {
+		return address, nil
+	}
+	address, err := printerPortAddress(printer)
+	if err != nil {
+		return "", fmt.Errorf("no se pudo determinar la dirección de red de '%s': %w", printer, err)
+	}
+	if address != "" {
+		return address, nil
+	}
+	return "", fmt.Errorf("la impresora '%s' no tiene una dirección de red configurada", printer)
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// ============================
+// Trabajos RAW en colas CUPS
+// ============================
+
+// WriteRawToPrinter envía los bytes indicados a la cola CUPS con "lp -o raw", sin filtros ni
+// controlador. Es el mecanismo para comandos ESC/POS, ZPL y similares.
+func WriteRawToPrinter(printer, docName string, data []byte) error {
+	cmd, timedOut, cancel := toolCommand("lp", "-d", printer, "-t", docName, "-o", "raw")
+	defer cancel()
+	cmd.Stdin = bytes.NewReader(data)
+	output, err := cmd.CombinedOutput()
+	if timedOut() {
+		return toolTimeoutError("lp")
+	}
+	if err != nil {
+		return fmt.Errorf("error al enviar el trabajo RAW a '%s': %v, salida: %s", printer, err, string(output))
+	}
+	return nil
+}
+
+// SpoolerRawWriter envía los datos como trabajo RAW a través de CUPS
+type SpoolerRawWriter struct {
+	DocName string
+}
+
+// WriteRaw envía los datos a la cola de la impresora indicada
+func (s SpoolerRawWriter) WriteRaw(printer string, data []byte) error {
+	return WriteRawToPrinter(printer, s.DocName, data)
+}
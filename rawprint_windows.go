@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// ============================
+// Trabajos RAW en el Spooler de Windows
+// ============================
+
+var (
+	procStartDocPrinterW = modWinspool.NewProc("StartDocPrinterW")
+	procEndDocPrinter    = modWinspool.NewProc("EndDocPrinter")
+	procStartPagePrinter = modWinspool.NewProc("StartPagePrinter")
+	procEndPagePrinter   = modWinspool.NewProc("EndPagePrinter")
+	procWritePrinter     = modWinspool.NewProc("WritePrinter")
+)
+
+// docInfo1 refleja la estructura DOC_INFO_1W
+type docInfo1 struct {
+	DocName    *uint16
+	OutputFile *uint16
+	Datatype   *uint16
+}
+
+// WriteRawToPrinter envía los bytes indicados como un trabajo RAW del spooler, sin pasar por el
+// controlador de la impresora. Es el mecanismo para comandos ESC/POS, ZPL y similares.
+func WriteRawToPrinter(printer, docName string, data []byte) error {
+	h, err := openSpoolerPrinter(printer)
+	if err != nil {
+		return err
+	}
+	defer closeSpoolerPrinter(h)
+
+	docNamePtr, err := windows.UTF16PtrFromString(docName)
+	if err != nil {
+		return err
+	}
+	datatypePtr, _ := windows.UTF16PtrFromString("RAW")
+	info := docInfo1{DocName: docNamePtr, Datatype: datatypePtr}
+
+	r1, _, err := procStartDocPrinterW.Call(uintptr(h), 1, uintptr(unsafe.Pointer(&info)))
+	if r1 == 0 {
+		return fmt.Errorf("StartDocPrinter falló: %w", err)
+	}
+	defer procEndDocPrinter.Call(uintptr(h))
+
+	r1, _, err = procStartPagePrinter.Call(uintptr(h))
+	if r1 == 0 {
+		return fmt.Errorf("StartPagePrinter falló: %w", err)
+	}
+	defer procEndPagePrinter.Call(uintptr(h))
+
+	if len(data) == 0 {
+		return nil
+	}
+	var written uint32
+	r1, _, err = procWritePrinter.Call(uintptr(h), uintptr(unsafe.Pointer(&data[0])), uintptr(len(data)), uintptr(unsafe.Pointer(&written)))
+	if r1 == 0 {
+		return fmt.Errorf("WritePrinter falló: %w", err)
+	}
+	if int(written) != len(data) {
+		return fmt.Errorf("WritePrinter escribió %d de %d bytes", written, len(data))
+	}
+	return nil
+}
+
+// SpoolerRawWriter envía los datos como trabajo RAW a través del spooler de Windows
+type SpoolerRawWriter struct {
+	DocName string
+}
+
+// WriteRaw envía los datos a la cola de la impresora indicada
+func (s SpoolerRawWriter) WriteRaw(printer string, data []byte) error {
+	return WriteRawToPrinter(printer, s.DocName, data)
+}
@@ -0,0 +1,619 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ============================
+// Recibos (JSON -> ESC/POS)
+// ============================
+
+// Columnas de texto de la fuente A según el ancho del rollo
+var receiptColumns = map[int]int{58: 32, 80: 48}
+
+// Formas de imprimir los códigos QR y de barras
+const (
+	ReceiptRenderNative = "native" // comandos GS ( k / GS k de la impresora
+	ReceiptRenderRaster = "raster" // imagen generada por el agente (GS v 0)
+)
+
+// ReceiptLayout describe el rollo donde se imprime el recibo
+type ReceiptLayout struct {
+	Columns     int
+	Dots        int
+	RasterCodes bool
+	// Codepage es la página de códigos del texto (la del perfil de la impresora)
+	Codepage string
+}
+
+// NewReceiptLayout devuelve el diseño para el ancho de rollo indicado (58 u 80 mm); rasterCodes
+// indica que la impresora no soporta los códigos nativos
+func NewReceiptLayout(widthMM int, rasterCodes bool) ReceiptLayout {
+	if _, ok := receiptColumns[widthMM]; !ok {
+		widthMM = 80
+	}
+	return ReceiptLayout{Columns: receiptColumns[widthMM], Dots: receiptDots[widthMM], RasterCodes: rasterCodes}
+}
+
+// Tipos de bloque del encabezado y el pie del recibo
+const (
+	ReceiptBlockText      = "text"
+	ReceiptBlockSeparator = "separator"
+	ReceiptBlockFeed      = "feed"
+	ReceiptBlockQR        = "qr"
+	ReceiptBlockBarcode   = "barcode"
+	ReceiptBlockLogo      = "logo"
+)
+
+// Modos de corte del papel al terminar el recibo
+const (
+	ReceiptCutFull    = "full"
+	ReceiptCutPartial = "partial"
+	ReceiptCutNone    = "none"
+)
+
+// maxQRDataLength es la capacidad de un código QR versión 40 con datos numéricos
+const maxQRDataLength = 7089
+
+// ReceiptAmount es un importe del recibo. Acepta un texto ya formateado por el ERP ("$ 1.500,00"),
+// que se imprime tal cual, o un número, que se imprime con dos decimales.
+type ReceiptAmount string
+
+// UnmarshalJSON acepta el importe como texto o como número
+func (a *ReceiptAmount) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*a = ReceiptAmount(text)
+		return nil
+	}
+	var number float64
+	if err := json.Unmarshal(data, &number); err != nil {
+		return fmt.Errorf("importe inválido: %s", data)
+	}
+	*a = ReceiptAmount(strconv.FormatFloat(number, 'f', 2, 64))
+	return nil
+}
+
+// ReceiptBlock es un elemento del encabezado o el pie: texto, separador, avance de papel,
+// código QR, código de barras o logo guardado en la impresora. Si no se indica type, el bloque es
+// de texto.
+type ReceiptBlock struct {
+	Type       string `json:"type,omitempty"`
+	Text       string `json:"text,omitempty"`
+	Align      string `json:"align,omitempty"`
+	Bold       bool   `json:"bold,omitempty"`
+	Size       int    `json:"size,omitempty"`
+	Char       string `json:"char,omitempty"`
+	Lines      int    `json:"lines,omitempty"`
+	Data       string `json:"data,omitempty"`
+	Symbology  string `json:"symbology,omitempty"`
+	ModuleSize int    `json:"module_size,omitempty"`
+	Correction string `json:"correction,omitempty"`
+	Height     int    `json:"height,omitempty"`
+	HideText   bool   `json:"hide_text,omitempty"`
+	Render     string `json:"render,omitempty"`
+	Slot       int    `json:"slot,omitempty"`
+	Legacy     bool   `json:"legacy,omitempty"`
+}
+
+// ReceiptItem es una línea de detalle del recibo
+type ReceiptItem struct {
+	Description string        `json:"description"`
+	Quantity    float64       `json:"quantity,omitempty"`
+	UnitPrice   ReceiptAmount `json:"unit_price,omitempty"`
+	Total       ReceiptAmount `json:"total"`
+	Detail      string        `json:"detail,omitempty"`
+}
+
+// ReceiptTotal es una línea de totales (subtotal, impuestos, total, pago, vuelto)
+type ReceiptTotal struct {
+	Label string        `json:"label"`
+	Value ReceiptAmount `json:"value"`
+	Bold  bool          `json:"bold,omitempty"`
+	Size  int           `json:"size,omitempty"`
+}
+
+// Receipt es el cuerpo de POST /print-receipt
+type Receipt struct {
+	Printer   string         `json:"printer,omitempty"`
+	WidthMM   int            `json:"width_mm,omitempty"`
+	Header    []ReceiptBlock `json:"header,omitempty"`
+	Items     []ReceiptItem  `json:"items,omitempty"`
+	Totals    []ReceiptTotal `json:"totals,omitempty"`
+	Footer    []ReceiptBlock `json:"footer,omitempty"`
+	Cut       string         `json:"cut,omitempty"`
+	FeedLines *int           `json:"feed_lines,omitempty"`
+	Copies    int            `json:"copies,omitempty"`
+	// CopyLabel encabeza las copias posteriores a la primera con una franja (p. ej. "COPIA")
+	CopyLabel string `json:"copy_label,omitempty"`
+}
+
+// Validate verifica el recibo antes de encolarlo, para responder 400 sin tocar la impresora
+func (rc Receipt) Validate() error {
+	if rc.WidthMM != 0 {
+		if _, ok := receiptColumns[rc.WidthMM]; !ok {
+			return fmt.Errorf("width_mm inválido: %d (use 58 u 80)", rc.WidthMM)
+		}
+	}
+	if len(rc.Header) == 0 && len(rc.Items) == 0 && len(rc.Totals) == 0 && len(rc.Footer) == 0 {
+		return fmt.Errorf("el recibo está vacío")
+	}
+	switch rc.Cut {
+	case "", ReceiptCutFull, ReceiptCutPartial, ReceiptCutNone:
+	default:
+		return fmt.Errorf("cut inválido: %s (use full, partial o none)", rc.Cut)
+	}
+	if rc.FeedLines != nil && (*rc.FeedLines < 0 || *rc.FeedLines > 255) {
+		return fmt.Errorf("feed_lines inválido: %d (rango 0-255)", *rc.FeedLines)
+	}
+	if rc.Copies < 0 || rc.Copies > maxCopies {
+		return fmt.Errorf("cantidad de copias inválida: %d (máximo %d)", rc.Copies, maxCopies)
+	}
+	if len([]rune(rc.CopyLabel)) > maxStampLength {
+		return fmt.Errorf("copy_label no puede superar %d caracteres", maxStampLength)
+	}
+	for i, item := range rc.Items {
+		if strings.TrimSpace(item.Description) == "" {
+			return fmt.Errorf("items[%d]: falta description", i)
+		}
+		if item.Quantity < 0 {
+			return fmt.Errorf("items[%d]: quantity no puede ser negativa", i)
+		}
+	}
+	for i, total := range rc.Totals {
+		if err := validateReceiptSize(total.Size); err != nil {
+			return fmt.Errorf("totals[%d]: %w", i, err)
+		}
+	}
+	for section, blocks := range map[string][]ReceiptBlock{"header": rc.Header, "footer": rc.Footer} {
+		for i, block := range blocks {
+			if err := block.validate(); err != nil {
+				return fmt.Errorf("%s[%d]: %w", section, i, err)
+			}
+		}
+	}
+	return nil
+}
+
+// validate verifica un bloque según su tipo
+func (b ReceiptBlock) validate() error {
+	switch b.Align {
+	case "", AlignLeft, AlignCenter, AlignRight:
+	default:
+		return fmt.Errorf("align inválido: %s (use left, center o right)", b.Align)
+	}
+	switch b.Render {
+	case "", ReceiptRenderNative, ReceiptRenderRaster:
+	default:
+		return fmt.Errorf("render inválido: %s (use native o raster)", b.Render)
+	}
+	switch b.Type {
+	case "", ReceiptBlockText:
+		return validateReceiptSize(b.Size)
+	case ReceiptBlockSeparator:
+		if utf8.RuneCountInString(b.Char) > 1 {
+			return fmt.Errorf("char debe ser un único carácter")
+		}
+	case ReceiptBlockFeed:
+		if b.Lines < 0 || b.Lines > 255 {
+			return fmt.Errorf("lines inválido: %d (rango 0-255)", b.Lines)
+		}
+	case ReceiptBlockQR:
+		if b.Data == "" {
+			return fmt.Errorf("falta data del código QR")
+		}
+		if len(b.Data) > maxQRDataLength {
+			return fmt.Errorf("el código QR admite hasta %d bytes", maxQRDataLength)
+		}
+		if b.ModuleSize < 0 || b.ModuleSize > 16 {
+			return fmt.Errorf("module_size inválido: %d (rango 1-16)", b.ModuleSize)
+		}
+		if _, ok := escposQRCorrection[strings.ToUpper(b.Correction)]; b.Correction != "" && !ok {
+			return fmt.Errorf("correction inválido: %s (use L, M, Q o H)", b.Correction)
+		}
+	case ReceiptBlockBarcode:
+		if b.Height < 0 || b.Height > 255 {
+			return fmt.Errorf("height inválido: %d (rango 1-255)", b.Height)
+		}
+		return validateBarcode(strings.ToLower(b.Symbology), b.Data)
+	case ReceiptBlockLogo:
+		return validateLogoSlot(b.Slot, b.Legacy)
+	default:
+		return fmt.Errorf("tipo de bloque desconocido: %s", b.Type)
+	}
+	return nil
+}
+
+// validateReceiptSize verifica el multiplicador de tamaño del texto
+func validateReceiptSize(size int) error {
+	if size < 0 || size > 8 {
+		return fmt.Errorf("size inválido: %d (rango 1-8)", size)
+	}
+	return nil
+}
+
+// validateBarcode verifica que los datos sean representables en la simbología
+func validateBarcode(symbology, data string) error {
+	digits := strings.Trim(data, "0123456789") == ""
+	switch symbology {
+	case "ean13":
+		if !digits || (len(data) != 12 && len(data) != 13) {
+			return fmt.Errorf("EAN-13 requiere 12 o 13 dígitos")
+		}
+	case "ean8":
+		if !digits || (len(data) != 7 && len(data) != 8) {
+			return fmt.Errorf("EAN-8 requiere 7 u 8 dígitos")
+		}
+	case "upca":
+		if !digits || (len(data) != 11 && len(data) != 12) {
+			return fmt.Errorf("UPC-A requiere 11 o 12 dígitos")
+		}
+	case "code39":
+		if data == "" || strings.Trim(data, "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ -.$/+%") != "" {
+			return fmt.Errorf("CODE39 admite dígitos, mayúsculas y los símbolos - . $ / + %% y espacio")
+		}
+	case "code128":
+		if data == "" {
+			return fmt.Errorf("falta data del código de barras")
+		}
+		for _, r := range data {
+			if r < 0x20 || r > 0x7E {
+				return fmt.Errorf("CODE128 admite solo caracteres ASCII imprimibles")
+			}
+		}
+	case "":
+		return fmt.Errorf("falta symbology (code128, ean13, ean8, upca o code39)")
+	default:
+		return fmt.Errorf("simbología no soportada: %s (use code128, ean13, ean8, upca o code39)", symbology)
+	}
+	if len(data) > 253 {
+		return fmt.Errorf("el código de barras admite hasta 253 caracteres")
+	}
+	return nil
+}
+
+// RenderReceipt genera los comandos ESC/POS del recibo para el rollo indicado
+func RenderReceipt(rc Receipt, layout ReceiptLayout) ([]byte, error) {
+	columns := layout.Columns
+	var b ESCPOSBuffer
+	b.Init()
+	b.Codepage(layout.Codepage)
+	if err := renderReceiptBlocks(&b, rc.Header, layout); err != nil {
+		return nil, fmt.Errorf("header: %w", err)
+	}
+
+	if len(rc.Items) > 0 {
+		b.Align(AlignLeft)
+		b.Line(strings.Repeat("-", columns))
+		for _, item := range rc.Items {
+			renderReceiptItem(&b, item, columns)
+		}
+	}
+	if len(rc.Totals) > 0 {
+		b.Align(AlignLeft)
+		b.Line(strings.Repeat("-", columns))
+		for _, total := range rc.Totals {
+			size := receiptSize(total.Size)
+			b.Bold(total.Bold)
+			b.Size(size, size)
+			for _, line := range receiptColumnsLine(total.Label, string(total.Value), columns/size) {
+				b.Line(line)
+			}
+			b.Size(1, 1)
+			b.Bold(false)
+		}
+	}
+	if len(rc.Footer) > 0 && (len(rc.Items) > 0 || len(rc.Totals) > 0) {
+		b.Align(AlignLeft)
+		b.Line(strings.Repeat("-", columns))
+	}
+	if err := renderReceiptBlocks(&b, rc.Footer, layout); err != nil {
+		return nil, fmt.Errorf("footer: %w", err)
+	}
+
+	feed := 3
+	if rc.FeedLines != nil {
+		feed = *rc.FeedLines
+	}
+	b.Feed(feed)
+	switch rc.Cut {
+	case "", ReceiptCutFull:
+		b.Cut(false)
+	case ReceiptCutPartial:
+		b.Cut(true)
+	}
+	return b.Bytes(), nil
+}
+
+// renderReceiptBlocks escribe los bloques del encabezado o el pie
+func renderReceiptBlocks(b *ESCPOSBuffer, blocks []ReceiptBlock, layout ReceiptLayout) error {
+	columns := layout.Columns
+	for _, block := range blocks {
+		align := block.Align
+		raster := block.Render == ReceiptRenderRaster || (block.Render == "" && layout.RasterCodes)
+		switch block.Type {
+		case "", ReceiptBlockText:
+			if align == "" {
+				align = AlignLeft
+			}
+			size := receiptSize(block.Size)
+			b.Align(align)
+			b.Bold(block.Bold)
+			b.Size(size, size)
+			for _, line := range wrapText(block.Text, columns/size) {
+				b.Line(line)
+			}
+			b.Size(1, 1)
+			b.Bold(false)
+		case ReceiptBlockSeparator:
+			char := block.Char
+			if char == "" {
+				char = "-"
+			}
+			b.Align(AlignLeft)
+			b.Line(strings.Repeat(char, columns))
+		case ReceiptBlockFeed:
+			lines := block.Lines
+			if lines == 0 {
+				lines = 1
+			}
+			b.Feed(lines)
+		case ReceiptBlockQR:
+			if align == "" {
+				align = AlignCenter
+			}
+			moduleSize := block.ModuleSize
+			if moduleSize == 0 {
+				moduleSize = 6
+			}
+			correction := strings.ToUpper(block.Correction)
+			if correction == "" {
+				correction = "M"
+			}
+			b.Align(align)
+			if !raster {
+				b.QR(block.Data, moduleSize, correction)
+				continue
+			}
+			img, err := QRImage(block.Data, moduleSize, correction, layout.Dots)
+			if err != nil {
+				return err
+			}
+			b.Raster(img)
+		case ReceiptBlockBarcode:
+			if align == "" {
+				align = AlignCenter
+			}
+			height := block.Height
+			if height == 0 {
+				height = 80
+			}
+			b.Align(align)
+			symbology := strings.ToLower(block.Symbology)
+			if !raster {
+				b.Barcode(symbology, block.Data, height, !block.HideText)
+				continue
+			}
+			img, err := BarcodeImage(symbology, block.Data, height, layout.Dots)
+			if err != nil {
+				return err
+			}
+			b.Raster(img)
+			if !block.HideText {
+				b.Line(block.Data)
+			}
+		case ReceiptBlockLogo:
+			if align == "" {
+				align = AlignCenter
+			}
+			b.Align(align)
+			if block.Legacy {
+				b.PrintNVBitImage(1)
+			} else {
+				b.PrintNVGraphic(max(block.Slot, 1))
+			}
+		}
+	}
+	return nil
+}
+
+// renderReceiptItem escribe una línea de detalle. Sin cantidad ni precio unitario se imprime
+// "descripción ..... total"; si no, la descripción y debajo "cantidad x precio ..... total".
+func renderReceiptItem(b *ESCPOSBuffer, item ReceiptItem, columns int) {
+	if item.UnitPrice == "" && item.Quantity <= 1 {
+		for _, line := range receiptColumnsLine(item.Description, string(item.Total), columns) {
+			b.Line(line)
+		}
+	} else {
+		for _, line := range wrapText(item.Description, columns) {
+			b.Line(line)
+		}
+		quantity := item.Quantity
+		if quantity == 0 {
+			quantity = 1
+		}
+		left := strconv.FormatFloat(quantity, 'f', -1, 64)
+		if item.UnitPrice != "" {
+			left += " x " + string(item.UnitPrice)
+		}
+		for _, line := range receiptColumnsLine(left, string(item.Total), columns-2) {
+			b.Line("  " + line)
+		}
+	}
+	if item.Detail != "" {
+		for _, line := range wrapText(item.Detail, columns-2) {
+			b.Line("  " + line)
+		}
+	}
+}
+
+// receiptSize devuelve el multiplicador de tamaño, 1 si no se indicó
+func receiptSize(size int) int {
+	if size <= 0 {
+		return 1
+	}
+	return size
+}
+
+// receiptColumnsLine alinea left a la izquierda y right a la derecha en el ancho indicado. Si el
+// texto no entra en una línea, left se parte en varias y right queda en la última.
+func receiptColumnsLine(left, right string, columns int) []string {
+	rightLen := utf8.RuneCountInString(right)
+	if rightLen >= columns {
+		return append(wrapText(left, columns), right)
+	}
+	lines := wrapText(left, columns-rightLen-1)
+	if len(lines) == 0 {
+		lines = []string{""}
+	}
+	last := lines[len(lines)-1]
+	pad := columns - utf8.RuneCountInString(last) - rightLen
+	lines[len(lines)-1] = last + strings.Repeat(" ", pad) + right
+	return lines
+}
+
+// wrapText parte el texto en líneas de hasta width caracteres, respetando los saltos de línea y
+// cortando las palabras más largas que el ancho
+func wrapText(text string, width int) []string {
+	if width < 1 {
+		width = 1
+	}
+	var lines []string
+	for _, paragraph := range strings.Split(text, "\n") {
+		var line []rune
+		for _, word := range strings.Fields(paragraph) {
+			w := []rune(word)
+			for len(w) > width {
+				if len(line) > 0 {
+					lines = append(lines, string(line))
+					line = nil
+				}
+				lines = append(lines, string(w[:width]))
+				w = w[width:]
+			}
+			switch {
+			case len(line) == 0:
+				line = w
+			case len(line)+1+len(w) <= width:
+				line = append(append(line, ' '), w...)
+			default:
+				lines = append(lines, string(line))
+				line = w
+			}
+		}
+		lines = append(lines, string(line))
+	}
+	return lines
+}
+
+// PrintReceipt genera el recibo en ESC/POS y lo envía a la impresora, repitiéndolo por cada copia;
+// con copy_label las copias posteriores a la primera llevan la franja indicada
+func (d DefaultPrinterService) PrintReceipt(printerName string, receipt Receipt) error {
+	if d.ReceiptWriter == nil {
+		return fmt.Errorf("la impresión de recibos no está disponible")
+	}
+	printerName, err := d.resolvePrinter(printerName)
+	if err != nil {
+		return err
+	}
+	profile := d.profile(printerName)
+	if err := profile.requireType(printerName, PrinterTypeThermal); err != nil {
+		return err
+	}
+	// Los valores omitidos en el recibo se toman del perfil de la impresora
+	if receipt.WidthMM == 0 {
+		receipt.WidthMM = profile.WidthMM
+	}
+	if receipt.Cut == "" {
+		receipt.Cut = profile.Cut
+	}
+	if receipt.Copies == 0 {
+		receipt.Copies = profile.Copies
+	}
+	if receipt.CopyLabel == "" {
+		receipt.CopyLabel = profile.CopyLabel
+	}
+	layout := NewReceiptLayout(receipt.WidthMM, profile.RasterCodes)
+	layout.Codepage = profile.Codepage
+	data, err := RenderReceipt(receipt, layout)
+	if err != nil {
+		return fmt.Errorf("error al generar el recibo: %w", err)
+	}
+	if receipt.Copies > 1 {
+		copyData := data
+		if receipt.CopyLabel != "" {
+			// La franja va antes del encabezado para que la copia se distinga apenas sale de la impresora
+			marked := receipt
+			marked.Header = append([]ReceiptBlock{
+				{Text: receipt.CopyLabel, Align: AlignCenter, Bold: true, Size: 2},
+				{Type: ReceiptBlockSeparator, Char: "="},
+			}, receipt.Header...)
+			if copyData, err = RenderReceipt(marked, layout); err != nil {
+				return fmt.Errorf("error al generar la copia del recibo: %w", err)
+			}
+		}
+		data = append(data, bytes.Repeat(copyData, receipt.Copies-1)...)
+	}
+	if err := d.ReceiptWriter.WriteRaw(printerName, data); err != nil {
+		return fmt.Errorf("error al imprimir el recibo: %w", err)
+	}
+	return nil
+}
+
+// PrintReceiptHandler imprime un recibo descrito en JSON en impresoras térmicas ESC/POS (POST /print-receipt)
+func (h Handlers) PrintReceiptHandler(w http.ResponseWriter, r *http.Request) {
+	h.Logger = h.Logger.ForRequest(r)
+	h.Logger.Info("Received request: /print-receipt")
+
+	if r.Method != http.MethodPost {
+		h.Logger.Warnf("Método HTTP no permitido: %s", r.Method)
+		WriteErrorJSON(w, http.StatusMethodNotAllowed, "Método HTTP no permitido", nil)
+		return
+	}
+
+	var receipt Receipt
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, h.MaxUploadBytes)).Decode(&receipt); err != nil {
+		h.Logger.Warnf("Error al decodificar JSON: %v", err)
+		WriteErrorJSON(w, http.StatusBadRequest, "Solicitud JSON inválida", err)
+		return
+	}
+	if err := receipt.Validate(); err != nil {
+		h.Logger.Warnf("Recibo inválido: %v", err)
+		WriteErrorJSON(w, http.StatusBadRequest, "Recibo inválido", err)
+		return
+	}
+	printer, err := h.defaultPrinter(receipt.Printer)
+	if err != nil {
+		h.Logger.Warnf("No se especificó la impresora: %v", err)
+		WriteErrorJSON(w, http.StatusBadRequest, "No se especificó la impresora", err)
+		return
+	}
+
+	job := NewPrintJob(JobKindReceipt, printer, "json")
+	job.RequestID = RequestID(r)
+	job.ClientCN = ClientCommonName(r)
+	err = h.Jobs.RunHoldable(job, func() error {
+		if err := h.preflight(job); err != nil {
+			return err
+		}
+		return h.Service.PrintReceipt(printer, receipt)
+	})
+	if errors.Is(err, ErrJobHeld) {
+		WriteJobHeldJSON(w, job)
+		return
+	}
+	if err != nil {
+		h.Logger.Errorf("Error al imprimir el recibo: %v", err)
+		WriteJobErrorJSON(w, jobErrorStatus(err), job, "Error al imprimir el recibo", err)
+		return
+	}
+
+	WriteJobJSON(w, job, "Recibo enviado a la impresora.")
+}
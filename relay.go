@@ -0,0 +1,342 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// ============================
+// Modo Relay (conexión saliente al ERP en la nube)
+// ============================
+
+const (
+	// relayMaxBackoff es la espera máxima entre dos intentos de conexión al relay
+	relayMaxBackoff = 60 * time.Second
+	// relayMaxMessageBytes limita el tamaño de las solicitudes recibidas por el relay (documentos en base64)
+	relayMaxMessageBytes = 64 << 20
+)
+
+// RelayConfig configura la conexión saliente con la que el agente recibe trabajos del ERP en la
+// nube, sin abrir puertos de entrada en el punto de venta
+type RelayConfig struct {
+	URL     string
+	Token   string
+	AgentID string
+	// AllowAdmin permite las rutas /admin/ y /debug/pprof/ a través del relay (siguen requiriendo ADMIN_TOKEN)
+	AllowAdmin bool
+	// Events reenvía al relay los eventos de trabajos, impresoras y cajón
+	Events bool
+}
+
+// LoadRelayConfig carga la configuración del relay desde variables de entorno
+func LoadRelayConfig() RelayConfig {
+	return RelayConfig{
+		URL:        getEnv("RELAY_URL", ""),
+		Token:      getEnv("RELAY_TOKEN", ""),
+		AgentID:    getEnv("RELAY_AGENT_ID", ""),
+		AllowAdmin: getEnvAsBool("RELAY_ALLOW_ADMIN", false),
+		Events:     getEnvAsBool("RELAY_EVENTS", true),
+	}
+}
+
+// Enabled indica si el modo relay está configurado
+func (c RelayConfig) Enabled() bool {
+	return c.URL != ""
+}
+
+// RelayMessage es un mensaje del protocolo del relay. El ERP envía "request" y el agente responde
+// con "response" (mismo id); además el agente envía "hello" al conectarse y "event" con cada evento.
+// Los cuerpos viajan en base64.
+type RelayMessage struct {
+	Type    string            `json:"type"`
+	ID      string            `json:"id,omitempty"`
+	Method  string            `json:"method,omitempty"`
+	Path    string            `json:"path,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    []byte            `json:"body,omitempty"`
+	Status  int               `json:"status,omitempty"`
+	Event   *Event            `json:"event,omitempty"`
+	Agent   map[string]string `json:"agent,omitempty"`
+}
+
+// RelayClient mantiene la conexión WebSocket saliente con el relay y atiende las solicitudes que
+// llegan por ella con los mismos manejadores del servidor HTTP local
+type RelayClient struct {
+	Config  RelayConfig
+	Handler http.Handler
+	Events  *EventBus
+	Logger  *Logger
+	dialer  *websocket.Dialer
+
+	mu        sync.Mutex
+	connected bool
+	ctx       context.Context
+	cancel    context.CancelFunc
+}
+
+// NewRelayClient valida la configuración y prepara el cliente con el proxy y los certificados de
+// las descargas (HTTP_PROXY, DOWNLOAD_CA_FILE)
+func NewRelayClient(cfg RelayConfig, outbound OutboundConfig, handler http.Handler, events *EventBus, logger *Logger) (*RelayClient, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil || (u.Scheme != "ws" && u.Scheme != "wss") || u.Host == "" {
+		return nil, fmt.Errorf("RELAY_URL inválido (use wss://...): %s", cfg.URL)
+	}
+	if cfg.Token == "" {
+		return nil, fmt.Errorf("RELAY_TOKEN es obligatorio con RELAY_URL")
+	}
+	if u.Scheme == "ws" {
+		logger.Warnf("RELAY_URL sin TLS (ws://): el token y los documentos viajan sin cifrar")
+	}
+
+	transport := &http.Transport{}
+	if err := outbound.apply(transport); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &RelayClient{
+		Config:  cfg,
+		Handler: handler,
+		Events:  events,
+		Logger:  logger,
+		dialer: &websocket.Dialer{
+			Proxy:            transport.Proxy,
+			TLSClientConfig:  transport.TLSClientConfig,
+			HandshakeTimeout: 15 * time.Second,
+		},
+		ctx:    ctx,
+		cancel: cancel,
+	}, nil
+}
+
+// Start conecta con el relay en segundo plano, reconectando con espera creciente si se corta
+func (c *RelayClient) Start() {
+	go c.run()
+}
+
+// Close cierra la conexión y detiene las reconexiones
+func (c *RelayClient) Close() error {
+	c.cancel()
+	return nil
+}
+
+// Connected indica si la conexión con el relay está activa
+func (c *RelayClient) Connected() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.connected
+}
+
+func (c *RelayClient) setConnected(connected bool) {
+	c.mu.Lock()
+	c.connected = connected
+	c.mu.Unlock()
+}
+
+func (c *RelayClient) run() {
+	defer recoverCrash()
+	backoff := time.Second
+	for {
+		start := time.Now()
+		err := c.session()
+		if c.ctx.Err() != nil {
+			return
+		}
+		// Una sesión que duró es un corte, no un rechazo: se reconecta enseguida
+		if time.Since(start) > relayMaxBackoff {
+			backoff = time.Second
+		}
+		c.Logger.Warnf("Conexión con el relay interrumpida: %v. Reintentando en %s", err, backoff)
+		select {
+		case <-time.After(backoff):
+		case <-c.ctx.Done():
+			return
+		}
+		backoff = min(backoff*2, relayMaxBackoff)
+	}
+}
+
+// session conecta con el relay y atiende sus solicitudes hasta que la conexión se corta
+func (c *RelayClient) session() error {
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+c.Config.Token)
+	header.Set("User-Agent", "PrinterMatiasERP/"+agentVersion)
+	conn, resp, err := c.dialer.DialContext(c.ctx, c.Config.URL, header)
+	if err != nil {
+		if resp != nil {
+			return fmt.Errorf("el relay respondió %s: %w", resp.Status, err)
+		}
+		return err
+	}
+	defer conn.Close()
+	conn.SetReadLimit(relayMaxMessageBytes)
+
+	c.setConnected(true)
+	defer c.setConnected(false)
+	c.Logger.Infof("Conectado al relay %s como '%s'", c.Config.URL, c.Config.AgentID)
+
+	// Las respuestas, los eventos y los ping se escriben desde varias goroutines
+	var writeMu sync.Mutex
+	write := func(msg RelayMessage) error {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+		return conn.WriteJSON(msg)
+	}
+	if err := write(RelayMessage{Type: "hello", Agent: map[string]string{"id": c.Config.AgentID, "version": agentVersion}}); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(c.ctx)
+	defer cancel()
+	go c.keepAlive(ctx, conn, &writeMu)
+	if c.Config.Events && c.Events != nil {
+		go c.forwardEvents(ctx, write)
+	}
+
+	// Sin respuesta al ping en dos intervalos la conexión se da por muerta
+	conn.SetReadDeadline(time.Now().Add(2 * wsPingInterval))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(2 * wsPingInterval))
+	})
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	for {
+		var msg RelayMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			return err
+		}
+		conn.SetReadDeadline(time.Now().Add(2 * wsPingInterval))
+		if msg.Type != "request" {
+			continue
+		}
+		go func() {
+			defer recoverCrash()
+			if err := write(c.serve(ctx, msg)); err != nil {
+				c.Logger.Warnf("No se pudo enviar la respuesta %s al relay: %v", msg.ID, err)
+			}
+		}()
+	}
+}
+
+// keepAlive envía un ping periódico para detectar cortes y mantener abierta la conexión en proxies
+func (c *RelayClient) keepAlive(ctx context.Context, conn *websocket.Conn, writeMu *sync.Mutex) {
+	ticker := time.NewTicker(wsPingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			writeMu.Lock()
+			err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout))
+			writeMu.Unlock()
+			if err != nil {
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// forwardEvents reenvía al relay los eventos del agente mientras dure la conexión
+func (c *RelayClient) forwardEvents(ctx context.Context, write func(RelayMessage) error) {
+	events, unsubscribe := c.Events.Subscribe(nil)
+	defer unsubscribe()
+	for {
+		select {
+		case event := <-events:
+			if err := write(RelayMessage{Type: "event", Event: &event}); err != nil {
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// serve atiende una solicitud del relay con los manejadores locales y devuelve la respuesta
+func (c *RelayClient) serve(ctx context.Context, msg RelayMessage) RelayMessage {
+	reply := func(status int, message string) RelayMessage {
+		rec := &relayResponseWriter{header: make(http.Header)}
+		WriteErrorJSON(rec, status, message, nil)
+		return RelayMessage{Type: "response", ID: msg.ID, Status: status,
+			Headers: map[string]string{"Content-Type": rec.header.Get("Content-Type")},
+			Body:    rec.body.Bytes()}
+	}
+
+	u, err := url.Parse(msg.Path)
+	if err != nil || !strings.HasPrefix(u.Path, "/") {
+		return reply(http.StatusBadRequest, "Ruta inválida")
+	}
+	// Las rutas se comparan sin el prefijo /v1, que sirve los mismos manejadores
+	route := u.Path
+	if trimmed := strings.TrimPrefix(route, apiPrefix); strings.HasPrefix(trimmed, "/") {
+		route = trimmed
+	}
+	// Los eventos ya se reenvían por la misma conexión
+	if route == "/ws" || route == "/events" {
+		return reply(http.StatusBadRequest, "Los eventos se reciben por la conexión del relay")
+	}
+	if (strings.HasPrefix(route, "/admin/") || strings.HasPrefix(route, "/debug/")) && !c.Config.AllowAdmin {
+		return reply(http.StatusForbidden, "Las rutas administrativas no están habilitadas en el relay (RELAY_ALLOW_ADMIN)")
+	}
+	method := msg.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u.RequestURI(), bytes.NewReader(msg.Body))
+	if err != nil {
+		return reply(http.StatusBadRequest, "Solicitud inválida")
+	}
+	for key, value := range msg.Headers {
+		req.Header.Set(key, value)
+	}
+	req.RemoteAddr = "relay"
+	req.Host = "relay"
+
+	rec := &relayResponseWriter{header: make(http.Header)}
+	c.Handler.ServeHTTP(rec, req)
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	headers := make(map[string]string, len(rec.header))
+	for key := range rec.header {
+		headers[key] = rec.header.Get(key)
+	}
+	return RelayMessage{Type: "response", ID: msg.ID, Status: rec.status, Headers: headers, Body: rec.body.Bytes()}
+}
+
+// relayResponseWriter guarda en memoria la respuesta de los manejadores para enviarla al relay
+type relayResponseWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *relayResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *relayResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *relayResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.body.Write(b)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"regexp"
+	"time"
+)
+
+// ============================
+// Identificador de Solicitud y Registro de Accesos
+// ============================
+
+// requestIDHeader es la cabecera con la que el ERP puede enviar su propio identificador de solicitud
+const requestIDHeader = "X-Request-ID"
+
+// requestIDPattern limita los identificadores recibidos a caracteres seguros para el log
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+type requestIDKey struct{}
+
+// RequestID devuelve el identificador asignado a la solicitud (vacío fuera del middleware)
+func RequestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDKey{}).(string)
+	return id
+}
+
+// ForRequest devuelve un logger que antepone el identificador de la solicitud (y, con mTLS, el
+// certificado del cliente) a cada línea
+func (l *Logger) ForRequest(r *http.Request) *Logger {
+	return l.WithRequestID(RequestID(r)).WithClient(ClientCommonName(r))
+}
+
+// WithRequestID devuelve un logger que antepone el identificador indicado a cada línea
+func (l *Logger) WithRequestID(id string) *Logger {
+	if id == "" {
+		return l
+	}
+	return &Logger{Logger: log.New(l.Writer(), "[req "+id+"] ", l.Flags()|log.Lmsgprefix)}
+}
+
+// statusRecorder guarda el código y el tamaño de la respuesta para el registro de accesos
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	if s.status == 0 {
+		s.status = status
+	}
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	n, err := s.ResponseWriter.Write(b)
+	s.bytes += n
+	return n, err
+}
+
+// Flush permite respuestas en streaming a través del middleware
+func (s *statusRecorder) Flush() {
+	if f, ok := s.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack permite tomar la conexión (WebSocket) a través del middleware
+func (s *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := s.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("la respuesta no permite tomar la conexión")
+	}
+	if s.status == 0 {
+		s.status = http.StatusSwitchingProtocols
+	}
+	return h.Hijack()
+}
+
+// Unwrap permite a http.ResponseController acceder al ResponseWriter original
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+// RequestIDMiddleware asigna a cada solicitud un X-Request-ID (o respeta el enviado por el ERP),
+// lo devuelve en la respuesta y registra método, ruta, código y latencia al terminar. También
+// indica en Content-Language el idioma de los mensajes de error.
+func RequestIDMiddleware(next http.Handler, logger *Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !requestIDPattern.MatchString(id) {
+			id = newJobID()
+		}
+		w.Header().Set(requestIDHeader, id)
+		// Los mensajes de error se localizan según el idioma que pide el cliente
+		w.Header().Set("Content-Language", errorLanguage(r))
+
+		rec := &statusRecorder{ResponseWriter: w}
+		start := time.Now()
+		defer func() {
+			if rec.status == 0 {
+				rec.status = http.StatusOK
+			}
+			logger.WithRequestID(id).WithClient(ClientCommonName(r)).Infof("%s %s %d %dB %s %s", r.Method, r.URL.Path, rec.status, rec.bytes,
+				time.Since(start).Round(time.Millisecond), r.RemoteAddr)
+		}()
+		next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
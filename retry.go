@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"time"
+)
+
+// ============================
+// Reintentos de Trabajos
+// ============================
+
+const (
+	// maxRetries limita los reintentos por solicitud
+	maxRetries = 10
+	// maxRetryDelay es la espera máxima entre dos intentos, aunque el backoff la supere
+	maxRetryDelay = 30 * time.Second
+)
+
+// RetryPolicy indica cuántas veces se reintenta un trabajo que falla por un error transitorio
+// (impresora ocupada, corte de red) y la espera antes del primer reintento, que se duplica en cada intento
+type RetryPolicy struct {
+	Retries int
+	Delay   time.Duration
+}
+
+// Backoff devuelve la espera antes del reintento indicado (1 es el primero)
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	delay := p.Delay
+	for i := 1; i < attempt && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxRetryDelay)
+}
+
+// permanentError marca los errores que no se resuelven reintentando (URL inválida, impresora
+// inexistente, documento rechazado por el servidor)
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// permanent marca el error como no reintentable
+func permanent(err error) error {
+	return permanentError{err: err}
+}
+
+// isRetryable indica si vale la pena reintentar el trabajo que falló con err
+func isRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	var perm permanentError
+	var corrupt base64.CorruptInputError
+	if errors.As(err, &perm) || errors.As(err, &corrupt) {
+		return false
+	}
+	// Sin papel el trabajo se retiene (PAPER_HOLD) en lugar de reintentarse
+	var notReady *PrinterNotReadyError
+	if errors.As(err, &notReady) && notReady.PaperOut {
+		return false
+	}
+	// El cliente cerró la conexión durante la descarga
+	return !errors.Is(err, context.Canceled) &&
+		!errors.Is(err, ErrJobCanceled) &&
+		!errors.Is(err, ErrJobHeld) &&
+		!errors.Is(err, ErrPrinterTypeMismatch) &&
+		!errors.Is(err, ErrInvalidDocument) &&
+		!errors.Is(err, ErrPrinterNotFound) &&
+		!errors.Is(err, ErrNoLicense)
+}
+
+// retryPolicy devuelve la política del trabajo: la de la solicitud (retries, retry_delay) o, en su
+// defecto, la configurada. Solo se reintentan los documentos (PDF), que se pueden reenviar
+// completos; los envíos RAW parciales duplicarían lo ya impreso.
+func (j *JobRunner) retryPolicy(job *PrintJob) RetryPolicy {
+	if job.Kind != JobKindPrint || job.Options == nil {
+		return RetryPolicy{}
+	}
+	policy := j.Retry
+	if job.Options.Retries != nil {
+		policy.Retries = *job.Options.Retries
+	}
+	if job.Options.RetryDelay > 0 {
+		policy.Delay = time.Duration(job.Options.RetryDelay) * time.Millisecond
+	}
+	return policy
+}
+
+// runWithRetries ejecuta fn y la repite con backoff mientras falle por un error transitorio
+func (j *JobRunner) runWithRetries(job *PrintJob, fn func() error) error {
+	policy := j.retryPolicy(job)
+	logger := j.jobLogger(job)
+	err := runRecovered(fn)
+	for attempt := 1; attempt <= policy.Retries && isRetryable(err) && !isJobCanceled(job.ID); attempt++ {
+		delay := policy.Backoff(attempt)
+		logger.Warnf("Trabajo %s falló en '%s' (intento %d de %d), reintentando en %s: %v",
+			job.ID, job.Printer, attempt, policy.Retries+1, delay, err)
+		time.Sleep(delay)
+		if isJobCanceled(job.ID) {
+			return ErrJobCanceled
+		}
+		job.Attempts = attempt + 1
+		err = runRecovered(fn)
+	}
+	return err
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// ============================
+// Enrutamiento de Copias por Tipo de Documento
+// ============================
+
+// PrintRoute es una de las salidas de un tipo de documento, por ejemplo el original en la
+// impresora láser o la copia sellada con "COPIA" en la térmica
+type PrintRoute struct {
+	Label   string `json:"label"`
+	Printer string `json:"printer"`
+	PrintOptions
+}
+
+// RoutingRules asocia cada tipo de documento (p. ej. "factura") con sus salidas
+type RoutingRules map[string][]PrintRoute
+
+// LoadRoutingRules lee las reglas desde un archivo JSON como:
+//
+//	{"factura": [{"label": "original", "printer": "HP-Laser"},
+//	             {"label": "copia", "printer": "POS-58", "stamp": "COPIA"}]}
+//
+// Si el archivo no existe no hay reglas.
+func LoadRoutingRules(path string) (RoutingRules, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return RoutingRules{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error al leer las reglas de enrutamiento '%s': %w", path, err)
+	}
+
+	var raw RoutingRules
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("reglas de enrutamiento inválidas en '%s': %w", path, err)
+	}
+	rules := make(RoutingRules, len(raw))
+	for docType, routes := range raw {
+		if len(routes) == 0 {
+			return nil, fmt.Errorf("el tipo de documento '%s' no tiene salidas", docType)
+		}
+		for i := range routes {
+			if routes[i].Printer == "" {
+				return nil, fmt.Errorf("la salida %d de '%s' no indica la impresora", i+1, docType)
+			}
+			if routes[i].Label == "" {
+				routes[i].Label = fmt.Sprintf("salida-%d", i+1)
+			}
+			if err := routes[i].PrintOptions.Normalize(); err != nil {
+				return nil, fmt.Errorf("opciones inválidas en la salida '%s' de '%s': %w", routes[i].Label, docType, err)
+			}
+		}
+		rules[strings.ToLower(docType)] = routes
+	}
+	return rules, nil
+}
+
+// Routes devuelve las salidas configuradas para el tipo de documento
+func (r RoutingRules) Routes(docType string) ([]PrintRoute, bool) {
+	routes, ok := r[strings.ToLower(strings.TrimSpace(docType))]
+	return routes, ok
+}
+
+// mergePrintOptions aplica sobre las opciones de la solicitud las definidas en la salida
+func mergePrintOptions(base, route PrintOptions) PrintOptions {
+	if route.Copies != 0 {
+		base.Copies = route.Copies
+	}
+	if route.Duplex != "" {
+		base.Duplex = route.Duplex
+	}
+	if route.Orientation != "" {
+		base.Orientation = route.Orientation
+	}
+	if route.PaperSize != "" {
+		base.PaperSize = route.PaperSize
+	}
+	if route.Tray != "" {
+		base.Tray = route.Tray
+	}
+	if route.Color != "" {
+		base.Color = route.Color
+	}
+	if route.Quality != "" {
+		base.Quality = route.Quality
+	}
+	if route.Scale != "" {
+		base.Scale = route.Scale
+	}
+	if route.Pages != "" {
+		base.Pages = route.Pages
+	}
+	if route.Engine != "" {
+		base.Engine = route.Engine
+	}
+	if route.Stamp != "" {
+		base.Stamp = route.Stamp
+	}
+	if route.StampImage != "" {
+		base.StampImage = route.StampImage
+	}
+	if route.StampPosition != "" {
+		base.StampPosition = route.StampPosition
+	}
+	if route.CopyLabel != "" {
+		base.CopyLabel = route.CopyLabel
+	}
+	if route.Retries != nil {
+		base.Retries = route.Retries
+	}
+	if route.RetryDelay != 0 {
+		base.RetryDelay = route.RetryDelay
+	}
+	return base
+}
+
+// printRouted imprime un mismo documento en todas las salidas del tipo indicado. Cada salida es un
+// trabajo propio; si una falla se continúa con las demás y se informa el detalle de cada una.
+func (h Handlers) printRouted(w http.ResponseWriter, r *http.Request, docType, fileURL, data, webhookURL string, opts PrintOptions) {
+	routes, ok := h.Routes.Routes(docType)
+	if !ok {
+		h.Logger.Warnf("Tipo de documento sin reglas de enrutamiento: %s", docType)
+		WriteErrorJSON(w, http.StatusBadRequest, "Tipo de documento desconocido", fmt.Errorf("no hay reglas para '%s'", docType))
+		return
+	}
+
+	ctx, cancel := downloadContext(r, opts.DownloadTimeout)
+	filePath, err := h.Service.FetchDocument(ctx, fileURL, data)
+	cancel()
+	if err != nil {
+		h.Logger.Errorf("Error al obtener el documento: %v", err)
+		WriteErrorJSON(w, http.StatusInternalServerError, "Error al obtener el documento", err)
+		return
+	}
+	defer os.Remove(filePath)
+
+	source := fileURL
+	if data != "" {
+		source = "base64"
+	}
+	var sha string
+	if f, err := os.Open(filePath); err == nil {
+		sha = documentSHA256(f)
+		f.Close()
+	}
+
+	var firstErr error
+	var firstCode string
+	results := make([]map[string]interface{}, 0, len(routes))
+	for _, route := range routes {
+		routeOpts := mergePrintOptions(opts, route.PrintOptions)
+		printer, group := h.groupPrinter(route.Printer)
+		job := NewPrintJob(JobKindPrint, printer, source)
+		job.Group = group
+		job.RequestID = RequestID(r)
+		job.ClientCN = ClientCommonName(r)
+		job.SHA256 = sha
+		job.WebhookURL = webhookURL
+		job.Options = &routeOpts
+		routeOpts.JobID = job.ID
+
+		err := h.Jobs.Run(job, func() error {
+			if err := h.preflight(job); err != nil {
+				return err
+			}
+			return h.Service.PrintPDFFromFile(filePath, printer, routeOpts)
+		})
+
+		result := map[string]interface{}{"label": route.Label, "printer": route.Printer, "job_id": job.ID, "status": job.Status}
+		if err != nil {
+			h.Logger.Errorf("Error al imprimir la salida '%s' de '%s': %v", route.Label, docType, err)
+			result["error"] = err.Error()
+			if job.ErrorCode != "" {
+				result["error_code"] = job.ErrorCode
+			}
+			if firstErr == nil {
+				firstErr, firstCode = err, job.ErrorCode
+			}
+		}
+		if len(job.Warnings) > 0 {
+			result["warnings"] = job.Warnings
+		}
+		results = append(results, result)
+	}
+
+	if firstErr != nil {
+		status := jobErrorStatus(firstErr)
+		if firstCode == "" {
+			firstCode = errorCode(status, firstErr)
+		}
+		resp := newErrorResponse(w, status, firstCode, "Error al imprimir una o más salidas del documento", firstErr)
+		resp.Jobs = results
+		WriteJSON(w, status, resp)
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"message": fmt.Sprintf("Documento '%s' enviado a %d impresoras exitosamente.", docType, len(routes)),
+		"jobs":    results,
+	})
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// ============================
+// Ajuste del Documento a la Hoja
+// ============================
+
+// pdfcpuPaperSizes mapea los tamaños de papel aceptados a los nombres de pdfcpu
+var pdfcpuPaperSizes = map[string]string{
+	"letter":    "Letter",
+	"legal":     "Legal",
+	"executive": "Executive",
+	"a3":        "A3",
+	"a4":        "A4",
+	"a5":        "A5",
+	"b5":        "B5",
+}
+
+// withFittedPDF ajusta el documento a la hoja para los motores que imprimen a tamaño real (como
+// PDFtoPrinter, que recorta los bordes de un A4 en papel carta): con scale fit o shrink se imprime
+// una copia con las páginas escaladas al papel de la solicitud o, si no se indica, al del
+// controlador. Las demás escalas imprimen el original.
+func withFittedPDF(filePath, printer string, opts PrintOptions, fn func(path string) error) error {
+	if opts.Scale != ScaleFit && opts.Scale != ScaleShrink {
+		return fn(filePath)
+	}
+	paper := opts.PaperSize
+	if paper == "" {
+		var err error
+		if paper, err = driverPaperSize(printer); err != nil {
+			return err
+		}
+	}
+	if paper == "" {
+		return permanent(fmt.Errorf("no se conoce el papel de '%s' para ajustar el documento; indique paper_size", printer))
+	}
+	fitted, err := FitPDF(filePath, paper, opts.Scale == ScaleShrink)
+	if err != nil {
+		return err
+	}
+	if fitted != filePath {
+		defer os.Remove(fitted)
+	}
+	return fn(fitted)
+}
+
+// FitPDF escribe en un archivo temporal una copia del PDF con las páginas escaladas (sin deformarse y
+// centradas) al tamaño de papel indicado. Con shrinkOnly solo se reducen las páginas que no caben;
+// si todas caben devuelve filePath.
+func FitPDF(filePath, paper string, shrinkOnly bool) (string, error) {
+	name, ok := pdfcpuPaperSizes[paper]
+	if !ok {
+		return "", fmt.Errorf("tamaño de papel no soportado: %s", paper)
+	}
+	size := *types.PaperSize[name]
+
+	var pages []string
+	if shrinkOnly {
+		dims, err := api.PageDimsFile(filePath)
+		if err != nil {
+			return "", fmt.Errorf("error al leer el tamaño de las páginas: %w", err)
+		}
+		for i, dim := range dims {
+			if !dimFits(dim, size) {
+				pages = append(pages, strconv.Itoa(i+1))
+			}
+		}
+		if len(pages) == 0 {
+			return filePath, nil
+		}
+	}
+
+	out, err := os.CreateTemp("", "fitted-*.pdf")
+	if err != nil {
+		return "", fmt.Errorf("error al crear el archivo ajustado: %w", err)
+	}
+	out.Close()
+	resize := &model.Resize{Unit: types.POINTS, PageSize: name, PageDim: &size}
+	if err := api.ResizeFile(filePath, out.Name(), pages, resize, model.NewDefaultConfiguration()); err != nil {
+		os.Remove(out.Name())
+		return "", fmt.Errorf("error al ajustar el documento al papel %s: %w", paper, err)
+	}
+	return out.Name(), nil
+}
+
+// dimFits indica si la página cabe en el papel en alguna de sus orientaciones (el controlador gira
+// las páginas apaisadas)
+func dimFits(page, paper types.Dim) bool {
+	// Medio punto de tolerancia por el redondeo de los tamaños de papel
+	const tolerance = 0.5
+	fits := func(w, h float64) bool {
+		return page.Width <= w+tolerance && page.Height <= h+tolerance
+	}
+	return fits(paper.Width, paper.Height) || fits(paper.Height, paper.Width)
+}
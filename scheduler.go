@@ -0,0 +1,475 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// ============================
+// Impresión Programada
+// ============================
+
+var (
+	bucketScheduled    = []byte("scheduled")     // job_id -> impresión programada (JSON)
+	bucketScheduleRuns = []byte("schedule_runs") // nombre de SCHEDULED_PRINTS -> última ejecución
+)
+
+// schedulerTick es la frecuencia con que se buscan impresiones vencidas
+const schedulerTick = 15 * time.Second
+
+// ScheduleConfig configura las impresiones programadas
+type ScheduleConfig struct {
+	// Recurring son las impresiones periódicas: nombre -> "cron|impresora|url"
+	Recurring map[string]string
+	// MaxDelayMinutes es el atraso máximo con que se imprime lo que venció con el agente detenido
+	// (0 imprime siempre)
+	MaxDelayMinutes int
+}
+
+// LoadScheduleConfig carga la configuración de las impresiones programadas desde variables de entorno
+func LoadScheduleConfig() ScheduleConfig {
+	return ScheduleConfig{
+		Recurring:       getEnvAsMap("SCHEDULED_PRINTS", ""),
+		MaxDelayMinutes: getEnvAsInt("SCHEDULE_MAX_DELAY_MINUTES", 720),
+	}
+}
+
+// ScheduledPrint es una solicitud de /print con schedule_at, guardada en el historial hasta su hora
+// para sobrevivir a los reinicios del agente
+type ScheduledPrint struct {
+	Job        *PrintJob    `json:"job"`
+	URL        string       `json:"url,omitempty"`
+	Data       string       `json:"data,omitempty"`
+	WebhookURL string       `json:"webhook_url,omitempty"`
+	Options    PrintOptions `json:"options"`
+	At         time.Time    `json:"at"`
+}
+
+// RecurringPrint es una impresión periódica de SCHEDULED_PRINTS
+type RecurringPrint struct {
+	Name    string
+	Spec    string
+	Printer string
+	URL     string
+	cron    *cronSchedule
+}
+
+// Scheduler guarda las impresiones programadas y las entrega a Print cuando llega su hora
+type Scheduler struct {
+	Recurring []RecurringPrint
+	MaxDelay  time.Duration
+	// Print ejecuta la impresión vencida como un trabajo más
+	Print  func(entry ScheduledPrint)
+	Runner *JobRunner
+	Logger *Logger
+
+	db   *bolt.DB
+	done chan struct{}
+	once sync.Once
+}
+
+// NewScheduler prepara las impresiones programadas sobre la base de datos del historial
+func NewScheduler(cfg ScheduleConfig, history *BoltJobHistory, logger *Logger) (*Scheduler, error) {
+	err := history.db.Update(func(tx *bolt.Tx) error {
+		for _, b := range [][]byte{bucketScheduled, bucketScheduleRuns} {
+			if _, err := tx.CreateBucketIfNotExists(b); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Scheduler{
+		MaxDelay: time.Duration(cfg.MaxDelayMinutes) * time.Minute,
+		Logger:   logger,
+		db:       history.db,
+		done:     make(chan struct{}),
+	}
+	names := make([]string, 0, len(cfg.Recurring))
+	for name := range cfg.Recurring {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		parts := splitAndTrim(cfg.Recurring[name], "|")
+		if len(parts) != 3 || parts[1] == "" || parts[2] == "" {
+			return nil, fmt.Errorf("SCHEDULED_PRINTS: '%s' debe tener el formato cron|impresora|url", name)
+		}
+		cron, err := parseCron(parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("SCHEDULED_PRINTS: '%s': %w", name, err)
+		}
+		s.Recurring = append(s.Recurring, RecurringPrint{Name: name, Spec: parts[0], Printer: parts[1], URL: parts[2], cron: cron})
+	}
+	return s, nil
+}
+
+// Start inicia la búsqueda periódica de impresiones vencidas; las que vencieron con el agente
+// detenido se imprimen de inmediato
+func (s *Scheduler) Start() {
+	go func() {
+		defer recoverCrash()
+		ticker := time.NewTicker(schedulerTick)
+		defer ticker.Stop()
+		for {
+			s.fireDue(time.Now())
+			select {
+			case <-ticker.C:
+			case <-s.done:
+				return
+			}
+		}
+	}()
+}
+
+// Close detiene el planificador; las impresiones pendientes quedan guardadas para el próximo inicio
+func (s *Scheduler) Close() error {
+	s.once.Do(func() { close(s.done) })
+	return nil
+}
+
+// Add guarda una impresión programada
+func (s *Scheduler) Add(entry ScheduledPrint) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketScheduled).Put([]byte(entry.Job.ID), data)
+	})
+}
+
+// Pending devuelve la cantidad de impresiones programadas pendientes (sin las periódicas)
+func (s *Scheduler) Pending() int {
+	if s == nil {
+		return 0
+	}
+	pending := 0
+	s.db.View(func(tx *bolt.Tx) error {
+		pending = tx.Bucket(bucketScheduled).Stats().KeyN
+		return nil
+	})
+	return pending
+}
+
+// Remove quita una impresión programada y devuelve su trabajo; nil si no está programada
+func (s *Scheduler) Remove(jobID string) *PrintJob {
+	var entry *ScheduledPrint
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketScheduled)
+		data := b.Get([]byte(jobID))
+		if data == nil {
+			return nil
+		}
+		entry = &ScheduledPrint{}
+		if err := json.Unmarshal(data, entry); err != nil {
+			return err
+		}
+		return b.Delete([]byte(jobID))
+	})
+	if err != nil {
+		s.Logger.Errorf("Error al quitar la impresión programada %s: %v", jobID, err)
+		return nil
+	}
+	if entry == nil {
+		return nil
+	}
+	return entry.Job
+}
+
+// fireDue entrega las impresiones cuya hora ya llegó y las periódicas que corresponden
+func (s *Scheduler) fireDue(now time.Time) {
+	var due []ScheduledPrint
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketScheduled)
+		var keys [][]byte
+		err := b.ForEach(func(k, v []byte) error {
+			var entry ScheduledPrint
+			if err := json.Unmarshal(v, &entry); err != nil {
+				s.Logger.Errorf("Impresión programada %s ilegible: %v", k, err)
+				keys = append(keys, k)
+				return nil
+			}
+			if !entry.At.After(now) {
+				due = append(due, entry)
+				keys = append(keys, k)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range keys {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		s.Logger.Errorf("Error al consultar las impresiones programadas: %v", err)
+		return
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].At.Before(due[j].At) })
+
+	for _, r := range s.Recurring {
+		if entry, ok := s.nextRecurring(r, now); ok {
+			due = append(due, entry)
+		}
+	}
+	for _, entry := range due {
+		s.fire(entry, now)
+	}
+}
+
+// nextRecurring decide si la impresión periódica vence ahora. La última ejecución se guarda para
+// recuperar la que correspondía mientras el agente estaba detenido.
+func (s *Scheduler) nextRecurring(r RecurringPrint, now time.Time) (ScheduledPrint, bool) {
+	var entry ScheduledPrint
+	fired := false
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketScheduleRuns)
+		last := now
+		if v := b.Get([]byte(r.Name)); v != nil {
+			if t, err := time.Parse(time.RFC3339, string(v)); err == nil {
+				last = t
+			}
+		} else {
+			// Primera vez: se imprime desde la próxima ocurrencia
+			return b.Put([]byte(r.Name), []byte(now.Format(time.RFC3339)))
+		}
+		next, ok := r.cron.Next(last)
+		if !ok || next.After(now) {
+			return nil
+		}
+		job := NewPrintJob(JobKindPrint, r.Printer, r.URL)
+		job.Schedule = r.Name
+		entry, fired = ScheduledPrint{Job: job, URL: r.URL, At: next}, true
+		return b.Put([]byte(r.Name), []byte(now.Format(time.RFC3339)))
+	})
+	if err != nil {
+		s.Logger.Errorf("Error al consultar la impresión periódica '%s': %v", r.Name, err)
+		return entry, false
+	}
+	return entry, fired
+}
+
+// fire imprime la entrada, salvo que haya vencido hace más de MaxDelay
+func (s *Scheduler) fire(entry ScheduledPrint, now time.Time) {
+	if late := now.Sub(entry.At); s.MaxDelay > 0 && late > s.MaxDelay {
+		err := fmt.Errorf("la impresión programada para %s venció con el agente detenido (atraso de %s)",
+			entry.At.Format(time.RFC3339), late.Round(time.Minute))
+		if entry.Job.Schedule != "" {
+			s.Logger.Warnf("Impresión periódica '%s' omitida: %v", entry.Job.Schedule, err)
+			return
+		}
+		s.Runner.finish(entry.Job, err)
+		return
+	}
+	if entry.Job.Schedule != "" {
+		s.Logger.Infof("Impresión periódica '%s' (%s) en '%s'", entry.Job.Schedule, entry.At.Format(time.RFC3339), entry.Job.Printer)
+	} else {
+		s.Logger.Infof("Impresión programada %s (%s) en '%s'", entry.Job.ID, entry.At.Format(time.RFC3339), entry.Job.Printer)
+	}
+	go func() {
+		defer recoverCrash()
+		s.Print(entry)
+	}()
+}
+
+// schedulePrint guarda una solicitud de /print con schedule_at y responde 202 con el trabajo en
+// estado scheduled
+func (h Handlers) schedulePrint(w http.ResponseWriter, r *http.Request, req PrintRequest, opts PrintOptions) {
+	if req.DocumentType != "" {
+		WriteErrorJSON(w, http.StatusBadRequest, "schedule_at no admite document_type", nil)
+		return
+	}
+	if !req.ScheduleAt.After(time.Now()) {
+		WriteErrorJSON(w, http.StatusBadRequest, "schedule_at debe ser una fecha futura", nil)
+		return
+	}
+
+	source := req.URL
+	if req.Data != "" {
+		source = "base64"
+	}
+	job := NewPrintJob(JobKindPrint, req.Printer, source)
+	job.RequestID = RequestID(r)
+	job.ClientCN = ClientCommonName(r)
+	job.Options = &opts
+	job.ScheduledAt = req.ScheduleAt
+	job.Status = JobStatusScheduled
+	job.StartedAt = time.Now()
+	if req.Data != "" {
+		job.SHA256 = documentSHA256(base64DocumentReader(req.Data))
+	}
+	entry := ScheduledPrint{Job: job, URL: req.URL, Data: req.Data, WebhookURL: req.WebhookURL, Options: opts, At: *req.ScheduleAt}
+	if err := h.Jobs.Scheduler.Add(entry); err != nil {
+		h.Logger.Errorf("Error al guardar la impresión programada: %v", err)
+		WriteErrorJSON(w, http.StatusInternalServerError, "Error al programar la impresión", err)
+		return
+	}
+	job.WebhookURL = req.WebhookURL
+	h.Jobs.record(job)
+	h.Logger.Infof("Trabajo %s programado para %s en '%s'", job.ID, req.ScheduleAt.Format(time.RFC3339), job.Printer)
+
+	WriteJSON(w, http.StatusAccepted, JobResponse{
+		Message: fmt.Sprintf("Impresión programada para %s.", req.ScheduleAt.Format(time.RFC3339)),
+		JobID:   job.ID,
+		Status:  job.Status,
+	})
+}
+
+// runScheduled imprime una impresión programada vencida como cualquier trabajo de /print
+func (h Handlers) runScheduled(entry ScheduledPrint) {
+	job := entry.Job
+	h.Logger = h.Logger.WithRequestID(job.RequestID)
+	printer, group := h.groupPrinter(job.Printer)
+	job.Printer, job.Group = printer, group
+	job.WebhookURL = entry.WebhookURL
+	opts := entry.Options
+	job.Options = &opts
+	opts.JobID = job.ID
+
+	err := h.Jobs.RunHoldable(job, func() error {
+		// La licencia pudo vencer entre la programación y el vencimiento
+		if err := h.Licenses.Check(); err != nil {
+			return err
+		}
+		if err := h.preflight(job); err != nil {
+			return err
+		}
+		if entry.Data != "" {
+			return h.Service.PrintPDFFromBase64(entry.Data, printer, opts)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		if opts.DownloadTimeout > 0 {
+			ctx, cancel = context.WithTimeout(context.Background(), time.Duration(opts.DownloadTimeout)*time.Second)
+		}
+		defer cancel()
+		return h.Service.PrintPDFFromURL(ctx, entry.URL, printer, opts)
+	})
+	if err != nil && !errors.Is(err, ErrJobHeld) {
+		h.Logger.Errorf("Error en la impresión programada %s: %v", job.ID, err)
+	}
+}
+
+// ============================
+// Expresiones cron
+// ============================
+
+// cronSchedule es una expresión cron de cinco campos (minuto, hora, día del mes, mes y día de la
+// semana) en la hora local del equipo
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	// domAny y dowAny indican "*": si ambos días están restringidos basta con que se cumpla uno
+	domAny, dowAny bool
+}
+
+// cronMaxSearch limita la búsqueda de la próxima ocurrencia (p. ej. "0 0 30 2 *" nunca ocurre)
+const cronMaxSearch = 366 * 24 * time.Hour
+
+// parseCron interpreta "min hora día mes día_semana" con *, listas (1,15), rangos (1-5) y pasos (*/10)
+func parseCron(spec string) (*cronSchedule, error) {
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("expresión cron inválida '%s': se esperan 5 campos (min hora día mes día_semana)", spec)
+	}
+	c := &cronSchedule{domAny: fields[2] == "*", dowAny: fields[4] == "*"}
+	limits := []struct {
+		dst      *uint64
+		min, max int
+	}{{&c.minute, 0, 59}, {&c.hour, 0, 23}, {&c.dom, 1, 31}, {&c.month, 1, 12}, {&c.dow, 0, 7}}
+	for i, l := range limits {
+		bits, err := parseCronField(fields[i], l.min, l.max)
+		if err != nil {
+			return nil, fmt.Errorf("expresión cron inválida '%s': %w", spec, err)
+		}
+		*l.dst = bits
+	}
+	// El domingo puede escribirse como 0 o 7
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	return c, nil
+}
+
+// parseCronField convierte un campo en el conjunto de valores permitidos
+func parseCronField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, step := part, 1
+		if r, s, found := strings.Cut(part, "/"); found {
+			n, err := strconv.Atoi(s)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("paso inválido: %s", part)
+			}
+			rng, step = r, n
+		}
+		lo, hi := min, max
+		if rng != "*" {
+			a, b, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = strconv.Atoi(a); err != nil {
+				return 0, fmt.Errorf("valor inválido: %s", part)
+			}
+			hi = lo
+			if isRange {
+				if hi, err = strconv.Atoi(b); err != nil {
+					return 0, fmt.Errorf("valor inválido: %s", part)
+				}
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("valor fuera de rango (%d-%d): %s", min, max, part)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+// matchesDay aplica la regla de cron para el día del mes y el día de la semana
+func (c *cronSchedule) matchesDay(t time.Time) bool {
+	dom := c.dom&(1<<t.Day()) != 0
+	dow := c.dow&(1<<int(t.Weekday())) != 0
+	if c.domAny || c.dowAny {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// Next devuelve la primera ocurrencia posterior a t; false si no hay ninguna en el próximo año
+func (c *cronSchedule) Next(t time.Time) (time.Time, bool) {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(cronMaxSearch)
+	for t.Before(limit) {
+		switch {
+		case c.month&(1<<int(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !c.matchesDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case c.hour&(1<<t.Hour()) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case c.minute&(1<<t.Minute()) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ============================
+// Puertos Serie (COM)
+// ============================
+
+// Control de flujo de un puerto serie
+const (
+	FlowControlNone    = "none"
+	FlowControlRTSCTS  = "rtscts"
+	FlowControlXONXOFF = "xonxoff"
+)
+
+// SerialPortConfig es la configuración de un puerto serie: COM3 en Windows o /dev/ttyUSB0 en Linux
+type SerialPortConfig struct {
+	Port        string `json:"port"`
+	BaudRate    int    `json:"baud_rate"`
+	DataBits    int    `json:"data_bits"`
+	Parity      string `json:"parity"`
+	StopBits    int    `json:"stop_bits"`
+	FlowControl string `json:"flow_control"`
+}
+
+// String devuelve la configuración con el mismo formato que acepta ParseSerialPort
+func (c SerialPortConfig) String() string {
+	return fmt.Sprintf("%s:%d:%d%s%d:%s", c.Port, c.BaudRate, c.DataBits, c.Parity, c.StopBits, c.FlowControl)
+}
+
+// ParseSerialPort interpreta "puerto[:baudios[:formato[:flujo]]]", por ejemplo "COM3",
+// "COM3:9600", "COM3:19200:7E1" o "/dev/ttyUSB0:9600:8N1:rtscts". El formato indica los bits de
+// datos (7 u 8), la paridad (N, E, O, M o S) y los bits de parada (1 o 2); por defecto, 9600 8N1
+// sin control de flujo.
+func ParseSerialPort(spec string) (SerialPortConfig, error) {
+	cfg := SerialPortConfig{BaudRate: 9600, DataBits: 8, Parity: "N", StopBits: 1, FlowControl: FlowControlNone}
+	parts := strings.Split(spec, ":")
+	cfg.Port = strings.TrimSpace(parts[0])
+	if cfg.Port == "" {
+		return cfg, fmt.Errorf("puerto serie vacío: %q", spec)
+	}
+	if len(parts) > 4 {
+		return cfg, fmt.Errorf("puerto serie inválido: %q (use puerto:baudios:formato:flujo)", spec)
+	}
+	if len(parts) > 1 && parts[1] != "" {
+		baud, err := strconv.Atoi(parts[1])
+		if err != nil || baud < 300 || baud > 921600 {
+			return cfg, fmt.Errorf("velocidad inválida en '%s': %s", spec, parts[1])
+		}
+		cfg.BaudRate = baud
+	}
+	if len(parts) > 2 && parts[2] != "" {
+		format := strings.ToUpper(parts[2])
+		if len(format) != 3 || (format[0] != '7' && format[0] != '8') || !strings.ContainsRune("NEOMS", rune(format[1])) || (format[2] != '1' && format[2] != '2') {
+			return cfg, fmt.Errorf("formato inválido en '%s': %s (por ejemplo 8N1 o 7E1)", spec, parts[2])
+		}
+		cfg.DataBits, cfg.Parity, cfg.StopBits = int(format[0]-'0'), string(format[1]), int(format[2]-'0')
+	}
+	if len(parts) > 3 && parts[3] != "" {
+		switch flow := strings.ToLower(parts[3]); flow {
+		case FlowControlNone, FlowControlRTSCTS, FlowControlXONXOFF:
+			cfg.FlowControl = flow
+		default:
+			return cfg, fmt.Errorf("control de flujo inválido en '%s': %s (use none, rtscts o xonxoff)", spec, parts[3])
+		}
+	}
+	return cfg, nil
+}
+
+// WriteSerial abre el puerto, envía los datos y lo cierra. El puerto queda libre entre envíos para
+// que otros programas del punto de venta puedan usarlo.
+func WriteSerial(cfg SerialPortConfig, data []byte, timeout time.Duration) error {
+	port, err := OpenSerialPort(cfg, timeout)
+	if err != nil {
+		return fmt.Errorf("no se pudo abrir el puerto %s: %w", cfg.Port, err)
+	}
+	defer port.Close()
+	if _, err := port.Write(data); err != nil {
+		return fmt.Errorf("error al enviar datos al puerto %s: %w", cfg.Port, err)
+	}
+	return nil
+}
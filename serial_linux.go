@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// serialBaudRates son las velocidades estándar de termios
+var serialBaudRates = map[int]uint32{
+	300: unix.B300, 600: unix.B600, 1200: unix.B1200, 2400: unix.B2400, 4800: unix.B4800,
+	9600: unix.B9600, 19200: unix.B19200, 38400: unix.B38400, 57600: unix.B57600,
+	115200: unix.B115200, 230400: unix.B230400, 460800: unix.B460800, 921600: unix.B921600,
+}
+
+// OpenSerialPort abre el dispositivo serie en modo crudo con la velocidad, el formato y el control
+// de flujo indicados. Las lecturas y escrituras vencen después de timeout.
+func OpenSerialPort(cfg SerialPortConfig, timeout time.Duration) (io.ReadWriteCloser, error) {
+	speed, ok := serialBaudRates[cfg.BaudRate]
+	if !ok {
+		return nil, fmt.Errorf("velocidad no soportada: %d", cfg.BaudRate)
+	}
+	fd, err := unix.Open(cfg.Port, unix.O_RDWR|unix.O_NOCTTY|unix.O_NONBLOCK|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, err
+	}
+	t, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+	if err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("%s no es un puerto serie: %w", cfg.Port, err)
+	}
+
+	t.Iflag, t.Oflag, t.Lflag = 0, 0, 0
+	t.Cflag = unix.CREAD | unix.CLOCAL | speed
+	if cfg.DataBits == 7 {
+		t.Cflag |= unix.CS7
+	} else {
+		t.Cflag |= unix.CS8
+	}
+	switch cfg.Parity {
+	case "E":
+		t.Cflag |= unix.PARENB
+	case "O":
+		t.Cflag |= unix.PARENB | unix.PARODD
+	case "M":
+		t.Cflag |= unix.PARENB | unix.PARODD | unix.CMSPAR
+	case "S":
+		t.Cflag |= unix.PARENB | unix.CMSPAR
+	}
+	if cfg.StopBits == 2 {
+		t.Cflag |= unix.CSTOPB
+	}
+	switch cfg.FlowControl {
+	case FlowControlRTSCTS:
+		t.Cflag |= unix.CRTSCTS
+	case FlowControlXONXOFF:
+		t.Iflag |= unix.IXON | unix.IXOFF
+	}
+	t.Ispeed, t.Ospeed = speed, speed
+	t.Cc[unix.VMIN], t.Cc[unix.VTIME] = 1, 0
+	if err := unix.IoctlSetTermios(fd, unix.TCSETS, t); err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("no se pudo configurar %s: %w", cfg.Port, err)
+	}
+
+	// El descriptor no bloqueante queda en el poller de Go, que aplica los plazos
+	port := os.NewFile(uintptr(fd), cfg.Port)
+	if err := port.SetDeadline(time.Now().Add(timeout)); err != nil {
+		port.Close()
+		return nil, err
+	}
+	return port, nil
+}
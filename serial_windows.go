@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// Bits de DCB.Flags
+const (
+	dcbBinary      = 0x00000001
+	dcbParity      = 0x00000002
+	dcbOutxCtsFlow = 0x00000004
+	dcbOutX        = 0x00000100
+	dcbInX         = 0x00000200
+)
+
+// serialParities traduce la paridad a la de DCB
+var serialParities = map[string]uint8{
+	"N": windows.NOPARITY, "O": windows.ODDPARITY, "E": windows.EVENPARITY,
+	"M": windows.MARKPARITY, "S": windows.SPACEPARITY,
+}
+
+// OpenSerialPort abre el puerto COM con la velocidad, el formato y el control de flujo indicados.
+// Las lecturas y escrituras vencen después de timeout.
+func OpenSerialPort(cfg SerialPortConfig, timeout time.Duration) (io.ReadWriteCloser, error) {
+	// Los puertos COM10 en adelante solo se abren con el prefijo \\.\
+	path := cfg.Port
+	if !strings.HasPrefix(path, `\\.\`) {
+		path = `\\.\` + path
+	}
+	pathPtr, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, err
+	}
+	h, err := windows.CreateFile(pathPtr, windows.GENERIC_READ|windows.GENERIC_WRITE, 0, nil, windows.OPEN_EXISTING, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var dcb windows.DCB
+	dcb.DCBlength = uint32(unsafe.Sizeof(dcb))
+	if err := windows.GetCommState(h, &dcb); err != nil {
+		windows.CloseHandle(h)
+		return nil, fmt.Errorf("%s no es un puerto serie: %w", cfg.Port, err)
+	}
+	dcb.BaudRate = uint32(cfg.BaudRate)
+	dcb.ByteSize = uint8(cfg.DataBits)
+	dcb.Parity = serialParities[cfg.Parity]
+	dcb.StopBits = windows.ONESTOPBIT
+	if cfg.StopBits == 2 {
+		dcb.StopBits = windows.TWOSTOPBITS
+	}
+	dcb.Flags = dcbBinary | windows.DTR_CONTROL_ENABLE
+	if cfg.Parity != "N" {
+		dcb.Flags |= dcbParity
+	}
+	switch cfg.FlowControl {
+	case FlowControlRTSCTS:
+		dcb.Flags |= dcbOutxCtsFlow | windows.RTS_CONTROL_HANDSHAKE
+	case FlowControlXONXOFF:
+		dcb.Flags |= dcbOutX | dcbInX | windows.RTS_CONTROL_ENABLE
+		dcb.XonChar, dcb.XoffChar = 0x11, 0x13
+		dcb.XonLim, dcb.XoffLim = 128, 128
+	default:
+		dcb.Flags |= windows.RTS_CONTROL_ENABLE
+	}
+	if err := windows.SetCommState(h, &dcb); err != nil {
+		windows.CloseHandle(h)
+		return nil, fmt.Errorf("no se pudo configurar %s: %w", cfg.Port, err)
+	}
+
+	ms := uint32(timeout.Milliseconds())
+	timeouts := windows.CommTimeouts{ReadTotalTimeoutConstant: ms, WriteTotalTimeoutConstant: ms}
+	if err := windows.SetCommTimeouts(h, &timeouts); err != nil {
+		windows.CloseHandle(h)
+		return nil, fmt.Errorf("no se pudo configurar %s: %w", cfg.Port, err)
+	}
+	return serialPort{handle: h, name: cfg.Port}, nil
+}
+
+// serialPort es un puerto COM abierto. Al vencer el plazo de SetCommTimeouts, ReadFile y WriteFile
+// terminan sin error con menos bytes de los pedidos, lo que aquí se informa como error.
+type serialPort struct {
+	handle windows.Handle
+	name   string
+}
+
+func (p serialPort) Read(buf []byte) (int, error) {
+	var n uint32
+	if err := windows.ReadFile(p.handle, buf, &n, nil); err != nil {
+		return int(n), err
+	}
+	if n == 0 && len(buf) > 0 {
+		return 0, fmt.Errorf("%s no respondió a tiempo", p.name)
+	}
+	return int(n), nil
+}
+
+func (p serialPort) Write(buf []byte) (int, error) {
+	var n uint32
+	if err := windows.WriteFile(p.handle, buf, &n, nil); err != nil {
+		return int(n), err
+	}
+	if int(n) < len(buf) {
+		return int(n), fmt.Errorf("%s no aceptó los datos a tiempo (%d de %d bytes)", p.name, n, len(buf))
+	}
+	return int(n), nil
+}
+
+func (p serialPort) Close() error {
+	return windows.CloseHandle(p.handle)
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// ============================
+// Impresoras y Cajones en Puertos Serie (COM)
+// ============================
+
+// serialTimeout limita cada envío y consulta de estado por puerto serie
+const serialTimeout = 5 * time.Second
+
+// SerialPrinters son las impresoras cuyo perfil indica un puerto serie (serial). No se instalan en
+// el sistema: los trabajos, el cajón y la consulta de estado van directo al puerto, sin spooler ni
+// controlador. Se consulta el perfil en cada envío para que los cambios por la API se apliquen sin
+// reiniciar.
+type SerialPrinters struct {
+	Profiles *PrinterProfileStore
+}
+
+// Port devuelve el puerto de la impresora si es una impresora serie
+func (s SerialPrinters) Port(printer string) (SerialPortConfig, bool) {
+	if s.Profiles == nil {
+		return SerialPortConfig{}, false
+	}
+	spec := s.Profiles.Get(printer).Serial
+	if spec == "" {
+		return SerialPortConfig{}, false
+	}
+	// El perfil se validó al guardarse
+	port, err := ParseSerialPort(spec)
+	return port, err == nil
+}
+
+// Names devuelve las impresoras serie ordenadas por nombre
+func (s SerialPrinters) Names() []string {
+	var names []string
+	if s.Profiles == nil {
+		return names
+	}
+	for _, entry := range s.Profiles.List() {
+		if entry.Effective.Serial != "" {
+			names = append(names, entry.Printer)
+		}
+	}
+	return names
+}
+
+// SerialPrinterManager agrega las impresoras serie a las del PrinterManager real
+type SerialPrinterManager struct {
+	Next     PrinterManager
+	Printers SerialPrinters
+}
+
+// ListPrinters lista las impresoras del sistema seguidas de las impresoras serie
+func (s SerialPrinterManager) ListPrinters() ([]string, error) {
+	printers, err := s.Next.ListPrinters()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range s.Printers.Names() {
+		port, _ := s.Printers.Port(name)
+		printers = append(printers, fmt.Sprintf("Name=%s;DriverName=RAW Serie;PortName=%s;PrinterStatus=Serial;Location=Puerto serie",
+			name, port))
+	}
+	return printers, nil
+}
+
+// PrinterExists considera existentes las impresoras serie; el puerto se prueba al imprimir
+func (s SerialPrinterManager) PrinterExists(name string) (bool, error) {
+	if _, ok := s.Printers.Port(name); ok {
+		return true, nil
+	}
+	return s.Next.PrinterExists(name)
+}
+
+// DefaultPrinter delega en el administrador real
+func (s SerialPrinterManager) DefaultPrinter() (string, error) {
+	return s.Next.DefaultPrinter()
+}
+
+// GetPrinterStatus consulta las impresoras serie con DLE EOT; si no responden se informan fuera de línea
+func (s SerialPrinterManager) GetPrinterStatus(name string) (*PrinterStatus, error) {
+	port, ok := s.Printers.Port(name)
+	if !ok {
+		return s.Next.GetPrinterStatus(name)
+	}
+	readiness, err := QuerySerialESCPOSStatus(port, 2*time.Second)
+	return deviceStatus(name, readiness, err), nil
+}
+
+// QuerySerialESCPOSStatus envía DLE EOT 1-4 por el puerto serie y decodifica las respuestas. Las
+// impresoras de impacto sin sensores responden igual, con los bits de papel en cero.
+func QuerySerialESCPOSStatus(cfg SerialPortConfig, timeout time.Duration) (*PrinterReadiness, error) {
+	port, err := OpenSerialPort(cfg, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo abrir el puerto %s: %w", cfg.Port, err)
+	}
+	defer port.Close()
+	return queryESCPOSStatus(port, cfg.Port)
+}
+
+// SerialStatusChecker consulta por el puerto serie el estado de las impresoras serie antes de
+// imprimir y delega las demás
+type SerialStatusChecker struct {
+	Next     StatusChecker
+	Printers SerialPrinters
+}
+
+// CheckStatus consulta el estado de la impresora
+func (c SerialStatusChecker) CheckStatus(printer string) (*PrinterReadiness, error) {
+	if port, ok := c.Printers.Port(printer); ok {
+		return QuerySerialESCPOSStatus(port, 2*time.Second)
+	}
+	return c.Next.CheckStatus(printer)
+}
+
+// SerialDocumentPrinter envía el documento tal cual al puerto de las impresoras serie y delega el
+// resto en el DocumentPrinter real. Sirve para documentos ya generados en el lenguaje de la
+// impresora (ESC/POS); las impresoras de impacto no interpretan PDF.
+type SerialDocumentPrinter struct {
+	Next     DocumentPrinter
+	Printers SerialPrinters
+	Logger   *Logger
+}
+
+// PrintFile imprime el archivo en la impresora indicada
+func (s SerialDocumentPrinter) PrintFile(filePath, printer string, opts PrintOptions) error {
+	port, ok := s.Printers.Port(printer)
+	if !ok {
+		return s.Next.PrintFile(filePath, printer, opts)
+	}
+	if opts.Duplex != "" || opts.Orientation != "" || opts.PaperSize != "" || opts.Tray != "" || opts.Color != "" || opts.Quality != "" || opts.Scale != "" || opts.Pages != "" || opts.Engine != "" {
+		s.Logger.Warnf("La impresora serie '%s' recibe el documento sin procesar; se ignoran duplex, orientación, papel, bandeja, color, calidad, escala, páginas y motor", printer)
+	}
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("error al leer el documento: %w", err)
+	}
+	copies := opts.Copies
+	if copies < 1 {
+		copies = 1
+	}
+	s.Logger.Infof("Enviando %d bytes a la impresora serie '%s' (%s), %d copia(s)", len(data), printer, port, copies)
+	for i := 0; i < copies; i++ {
+		if err := WriteSerial(port, data, serialTimeout); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SerialRawWriter envía los datos crudos (recibos, comandos, cajón) al puerto de las impresoras
+// serie y delega las demás en el transporte configurado
+type SerialRawWriter struct {
+	Next     RawWriter
+	Printers SerialPrinters
+}
+
+// WriteRaw envía los datos a la impresora indicada
+func (s SerialRawWriter) WriteRaw(printer string, data []byte) error {
+	if port, ok := s.Printers.Port(printer); ok {
+		return WriteSerial(port, data, serialTimeout)
+	}
+	return s.Next.WriteRaw(printer, data)
+}
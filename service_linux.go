@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ============================
+// Servicio de systemd
+// ============================
+
+const (
+	serviceName        = "printermatiaserp"
+	serviceDescription = "PrinterMatiasERP - Servidor local de impresión y apertura de cajón para MatiasERP"
+)
+
+// serviceUnitPath es la unidad de systemd creada por 'service install'
+var serviceUnitPath = "/etc/systemd/system/" + serviceName + ".service"
+
+// isWindowsService siempre es falso en Linux: systemd ejecuta el agente como un proceso normal
+func isWindowsService() bool {
+	return false
+}
+
+// runWindowsService no se usa en Linux (ver isWindowsService)
+func runWindowsService(cfg Config) {}
+
+// InstallService crea la unidad de systemd con reinicio ante fallas y la habilita al inicio
+func InstallService(cfg Config) error {
+	exePath, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("no se pudo determinar la ruta del ejecutable: %w", err)
+	}
+	if _, err := os.Stat(serviceUnitPath); err == nil {
+		return fmt.Errorf("el servicio %s ya está instalado (%s)", serviceName, serviceUnitPath)
+	}
+
+	// El servicio usa el mismo archivo de configuración indicado al instalarlo
+	execStart := exePath
+	if configFlagPath != "" {
+		execStart += " --config " + configFlagPath
+	}
+	unit := strings.Join([]string{
+		"[Unit]",
+		"Description=" + serviceDescription,
+		"After=network-online.target cups.service",
+		"Wants=network-online.target",
+		"",
+		"[Service]",
+		"ExecStart=" + execStart,
+		"WorkingDirectory=" + filepath.Dir(exePath),
+		"Restart=on-failure",
+		"RestartSec=5",
+		"",
+		"[Install]",
+		"WantedBy=multi-user.target",
+		"",
+	}, "\n")
+	if err := os.WriteFile(serviceUnitPath, []byte(unit), 0o644); err != nil {
+		return fmt.Errorf("error al crear la unidad de systemd (¿se ejecuta como root?): %w", err)
+	}
+	if err := runSystemctl("daemon-reload"); err != nil {
+		return err
+	}
+	if err := runSystemctl("enable", serviceName); err != nil {
+		return err
+	}
+
+	if err := AddFirewallRule(cfg.Port); err != nil {
+		fmt.Fprintf(os.Stderr, "Advertencia: no se pudo crear la regla de firewall: %v\n", err)
+	}
+	return nil
+}
+
+// UninstallService detiene y elimina el servicio y su regla de firewall
+func UninstallService() error {
+	if _, err := os.Stat(serviceUnitPath); err != nil {
+		return fmt.Errorf("el servicio %s no está instalado", serviceName)
+	}
+	if err := runSystemctl("disable", "--now", serviceName); err != nil {
+		fmt.Fprintf(os.Stderr, "Advertencia: %v\n", err)
+	}
+	if err := os.Remove(serviceUnitPath); err != nil {
+		return fmt.Errorf("error al eliminar el servicio: %w", err)
+	}
+	if err := runSystemctl("daemon-reload"); err != nil {
+		return err
+	}
+
+	if err := RemoveFirewallRule(); err != nil {
+		fmt.Fprintf(os.Stderr, "Advertencia: no se pudo eliminar la regla de firewall: %v\n", err)
+	}
+	return nil
+}
+
+// StartService inicia el servicio instalado
+func StartService() error {
+	return runSystemctl("start", serviceName)
+}
+
+// StopService detiene el servicio instalado y espera a que termine
+func StopService() error {
+	return runSystemctl("stop", serviceName)
+}
+
+func runSystemctl(args ...string) error {
+	output, err := exec.Command("systemctl", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("error al ejecutar systemctl %s: %v, salida: %s", strings.Join(args, " "), err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
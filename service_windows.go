@@ -0,0 +1,245 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// ============================
+// Servicio de Windows
+// ============================
+
+const (
+	serviceName        = "PrinterMatiasERP"
+	serviceDisplayName = "PrinterMatiasERP - Servidor de Impresión"
+	serviceDescription = "Servidor local de impresión y apertura de cajón para MatiasERP."
+)
+
+// isWindowsService indica si el proceso fue iniciado por el Service Control Manager
+func isWindowsService() bool {
+	ok, err := svc.IsWindowsService()
+	return err == nil && ok
+}
+
+// runWindowsService ejecuta el servidor bajo el control del Service Control Manager
+func runWindowsService(cfg Config) {
+	// Los servicios inician en System32; las rutas relativas (PDFtoPrinter.exe, app.log)
+	// deben resolverse desde el directorio del ejecutable
+	if exe, err := os.Executable(); err == nil {
+		_ = os.Chdir(filepath.Dir(exe))
+	}
+
+	if err := svc.Run(serviceName, &agentService{cfg: cfg}); err != nil {
+		log.Fatalf("Error al ejecutar el servicio: %v", err)
+	}
+}
+
+// agentService implementa svc.Handler
+type agentService struct {
+	cfg Config
+}
+
+// Execute inicia el servidor HTTP y atiende las órdenes del Service Control Manager
+func (a *agentService) Execute(args []string, requests <-chan svc.ChangeRequest, changes chan<- svc.Status) (bool, uint32) {
+	changes <- svc.Status{State: svc.StartPending}
+
+	logger := newAppLogger(a.cfg)
+	initCrashReporter(a.cfg, logger)
+	if crashReporter != nil {
+		// El servicio no tiene consola: los errores fatales del runtime quedan en el directorio de fallas
+		if err := redirectStderr(filepath.Join(crashReporter.Dir, crashStderrFile)); err != nil {
+			logger.Warnf("No se pudo redirigir la salida de errores: %v", err)
+		}
+	}
+
+	stop := make(chan struct{})
+	serverErr := make(chan error, 1)
+	go func() {
+		defer recoverCrash()
+		serverErr <- serveWithProfiles(logger, stop)
+	}()
+
+	changes <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+	logger.Info("Servicio de Windows en ejecución")
+
+	for {
+		select {
+		case err := <-serverErr:
+			if errors.Is(err, errRestartForUpdate) {
+				// Las acciones de recuperación inician la versión instalada
+				logger.Infof("Reiniciando el servicio para aplicar la actualización")
+				return true, updateRestartExitCode
+			}
+			// Código de salida distinto de cero para que se apliquen las acciones de recuperación
+			logger.Errorf("El servidor se detuvo inesperadamente: %v", err)
+			if crashReporter != nil {
+				crashReporter.Write("salida anormal: "+err.Error(), true)
+			}
+			return true, 2
+		case req := <-requests:
+			switch req.Cmd {
+			case svc.Interrogate:
+				changes <- req.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				changes <- svc.Status{State: svc.StopPending}
+				logger.Info("Deteniendo el servicio de Windows")
+				close(stop)
+				<-serverErr
+				return false, 0
+			}
+		}
+	}
+}
+
+// redirectStderr envía la salida de errores del proceso (incluida la del runtime de Go) al archivo indicado
+func redirectStderr(path string) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	if err := windows.SetStdHandle(windows.STD_ERROR_HANDLE, windows.Handle(f.Fd())); err != nil {
+		f.Close()
+		return err
+	}
+	os.Stderr = f
+	return nil
+}
+
+// InstallService registra el agente como servicio de inicio automático con reinicio ante fallas
+func InstallService(cfg Config) error {
+	exePath, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("no se pudo determinar la ruta del ejecutable: %w", err)
+	}
+
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("no se pudo conectar al Service Control Manager (¿se ejecuta como administrador?): %w", err)
+	}
+	defer m.Disconnect()
+
+	if s, err := m.OpenService(serviceName); err == nil {
+		s.Close()
+		return fmt.Errorf("el servicio %s ya está instalado", serviceName)
+	}
+
+	// El servicio usa el mismo archivo de configuración indicado al instalarlo
+	var args []string
+	if configFlagPath != "" {
+		args = append(args, "--config", configFlagPath)
+	}
+	s, err := m.CreateService(serviceName, exePath, mgr.Config{
+		DisplayName: serviceDisplayName,
+		Description: serviceDescription,
+		StartType:   mgr.StartAutomatic,
+	}, args...)
+	if err != nil {
+		return fmt.Errorf("error al crear el servicio: %w", err)
+	}
+	defer s.Close()
+
+	// Reiniciar el servicio ante fallas: 5s, 30s y luego cada minuto; el contador se reinicia a diario
+	recovery := []mgr.RecoveryAction{
+		{Type: mgr.ServiceRestart, Delay: 5 * time.Second},
+		{Type: mgr.ServiceRestart, Delay: 30 * time.Second},
+		{Type: mgr.ServiceRestart, Delay: time.Minute},
+	}
+	if err := s.SetRecoveryActions(recovery, uint32((24 * time.Hour).Seconds())); err != nil {
+		return fmt.Errorf("error al configurar la recuperación del servicio: %w", err)
+	}
+	if err := s.SetRecoveryActionsOnNonCrashFailures(true); err != nil {
+		return fmt.Errorf("error al configurar la recuperación del servicio: %w", err)
+	}
+
+	if err := AddFirewallRule(cfg.Port); err != nil {
+		fmt.Fprintf(os.Stderr, "Advertencia: no se pudo crear la regla de firewall: %v\n", err)
+	}
+	return nil
+}
+
+// UninstallService detiene (si es necesario) y elimina el servicio y su regla de firewall
+func UninstallService() error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("no se pudo conectar al Service Control Manager (¿se ejecuta como administrador?): %w", err)
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(serviceName)
+	if err != nil {
+		return fmt.Errorf("el servicio %s no está instalado", serviceName)
+	}
+	defer s.Close()
+
+	if status, err := s.Query(); err == nil && status.State != svc.Stopped {
+		if err := stopService(s); err != nil {
+			fmt.Fprintf(os.Stderr, "Advertencia: %v\n", err)
+		}
+	}
+
+	if err := s.Delete(); err != nil {
+		return fmt.Errorf("error al eliminar el servicio: %w", err)
+	}
+
+	if err := RemoveFirewallRule(); err != nil {
+		fmt.Fprintf(os.Stderr, "Advertencia: no se pudo eliminar la regla de firewall: %v\n", err)
+	}
+	return nil
+}
+
+// StartService inicia el servicio instalado
+func StartService() error {
+	return withService(func(s *mgr.Service) error {
+		if err := s.Start(); err != nil {
+			return fmt.Errorf("error al iniciar el servicio: %w", err)
+		}
+		return nil
+	})
+}
+
+// StopService detiene el servicio instalado y espera a que termine
+func StopService() error {
+	return withService(stopService)
+}
+
+func withService(fn func(s *mgr.Service) error) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("no se pudo conectar al Service Control Manager (¿se ejecuta como administrador?): %w", err)
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(serviceName)
+	if err != nil {
+		return fmt.Errorf("el servicio %s no está instalado", serviceName)
+	}
+	defer s.Close()
+	return fn(s)
+}
+
+func stopService(s *mgr.Service) error {
+	status, err := s.Control(svc.Stop)
+	if err != nil {
+		return fmt.Errorf("error al detener el servicio: %w", err)
+	}
+
+	deadline := time.Now().Add(shutdownTimeout + 5*time.Second)
+	for status.State != svc.Stopped {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("el servicio no se detuvo a tiempo")
+		}
+		time.Sleep(300 * time.Millisecond)
+		if status, err = s.Query(); err != nil {
+			return fmt.Errorf("error al consultar el estado del servicio: %w", err)
+		}
+	}
+	return nil
+}
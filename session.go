@@ -0,0 +1,337 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ============================
+// Sesiones de Clientes y Capacidades del Agente
+// ============================
+
+// agentVersion es la versión del agente informada a los clientes.
+// Se inyecta al compilar: go build -ldflags "-X main.agentVersion=1.4.0".
+var agentVersion = "dev"
+
+// sessionTokenHeader es la cabecera con la que el cliente presenta su sesión
+const sessionTokenHeader = "X-Session-Token"
+
+// Capabilities describe lo que soporta el agente instalado, para que clientes de distintas
+// versiones del ERP puedan adaptarse
+type Capabilities struct {
+	AgentVersion string   `json:"agent_version"`
+	Formats      []string `json:"formats"`
+	Endpoints    []string `json:"endpoints"`
+	APIBase      string   `json:"api_base"`
+	Backend      string   `json:"backend"`
+	Engines      []string `json:"engines"`
+	DrawerMethod string   `json:"drawer_method"`
+	Features     []string `json:"features"`
+	MaxUploadMB  int      `json:"max_upload_mb"`
+}
+
+// ClientSession es un cliente POS registrado
+type ClientSession struct {
+	ID            string    `json:"session_id"`
+	ClientType    string    `json:"client_type"`
+	ClientVersion string    `json:"client_version"`
+	Station       string    `json:"station"`
+	RemoteAddr    string    `json:"remote_addr"`
+	CreatedAt     time.Time `json:"created_at"`
+	LastSeen      time.Time `json:"last_seen"`
+	ExpiresAt     time.Time `json:"expires_at"`
+	token         string
+}
+
+// SessionStore guarda en memoria las sesiones de los clientes; se pierden al reiniciar o recargar
+// el agente, y el cliente debe registrarse nuevamente al recibir 401
+type SessionStore struct {
+	TTL          time.Duration
+	Capabilities Capabilities
+	Logger       *Logger
+
+	mu       sync.Mutex
+	sessions map[string]*ClientSession
+}
+
+// NewSessionStore crea el almacén con la duración de sesión indicada; las capacidades se
+// completan una vez registradas todas las rutas
+func NewSessionStore(ttlHours int, logger *Logger) *SessionStore {
+	return &SessionStore{
+		TTL:      time.Duration(ttlHours) * time.Hour,
+		Logger:   logger,
+		sessions: make(map[string]*ClientSession),
+	}
+}
+
+// Register crea una sesión y devuelve su token
+func (s *SessionStore) Register(clientType, clientVersion, station, remoteAddr string) (*ClientSession, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, "", err
+	}
+	now := time.Now()
+	session := &ClientSession{
+		ID:            newJobID(),
+		ClientType:    clientType,
+		ClientVersion: clientVersion,
+		Station:       station,
+		RemoteAddr:    remoteAddr,
+		CreatedAt:     now,
+		LastSeen:      now,
+		ExpiresAt:     now.Add(s.TTL),
+		token:         hex.EncodeToString(b),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pruneLocked(now)
+	s.sessions[session.ID] = session
+	return session, session.token, nil
+}
+
+// Lookup devuelve la sesión del token y extiende su vigencia
+func (s *SessionStore) Lookup(token string) *ClientSession {
+	if token == "" {
+		return nil
+	}
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pruneLocked(now)
+	for _, session := range s.sessions {
+		if subtle.ConstantTimeCompare([]byte(session.token), []byte(token)) == 1 {
+			session.LastSeen = now
+			session.ExpiresAt = now.Add(s.TTL)
+			found := *session
+			return &found
+		}
+	}
+	return nil
+}
+
+// Remove cierra la sesión del token
+func (s *SessionStore) Remove(token string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, session := range s.sessions {
+		if subtle.ConstantTimeCompare([]byte(session.token), []byte(token)) == 1 {
+			delete(s.sessions, id)
+			return true
+		}
+	}
+	return false
+}
+
+// List devuelve las sesiones vigentes ordenadas por fecha de creación
+func (s *SessionStore) List() []ClientSession {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pruneLocked(time.Now())
+	list := make([]ClientSession, 0, len(s.sessions))
+	for _, session := range s.sessions {
+		list = append(list, *session)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.Before(list[j].CreatedAt) })
+	return list
+}
+
+func (s *SessionStore) pruneLocked(now time.Time) {
+	for id, session := range s.sessions {
+		if now.After(session.ExpiresAt) {
+			delete(s.sessions, id)
+		}
+	}
+}
+
+// sessionToken obtiene el token de la cabecera X-Session-Token
+func sessionToken(r *http.Request) string {
+	return strings.TrimSpace(r.Header.Get(sessionTokenHeader))
+}
+
+// SessionRequest es el cuerpo de POST /session
+type SessionRequest struct {
+	ClientType    string `json:"client_type"`
+	ClientVersion string `json:"client_version"`
+	Station       string `json:"station"`
+}
+
+// SessionHandler registra un cliente (POST), consulta su sesión (GET) o la cierra (DELETE)
+func (s *SessionStore) SessionHandler(w http.ResponseWriter, r *http.Request) {
+	logger := s.Logger.ForRequest(r)
+	logger.Info("Received request: /session")
+
+	switch r.Method {
+	case http.MethodPost:
+		var req SessionRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&req); err != nil {
+			WriteErrorJSON(w, http.StatusBadRequest, "Solicitud JSON inválida", err)
+			return
+		}
+		if strings.TrimSpace(req.ClientType) == "" || strings.TrimSpace(req.Station) == "" {
+			WriteErrorJSON(w, http.StatusBadRequest, "Debe indicar client_type y station", nil)
+			return
+		}
+		session, token, err := s.Register(strings.TrimSpace(req.ClientType), strings.TrimSpace(req.ClientVersion), strings.TrimSpace(req.Station), r.RemoteAddr)
+		if err != nil {
+			WriteErrorJSON(w, http.StatusInternalServerError, "No se pudo crear la sesión", err)
+			return
+		}
+		logger.Infof("Cliente registrado: %s %s en la estación '%s' (sesión %s)", session.ClientType, session.ClientVersion, session.Station, session.ID)
+		WriteJSON(w, http.StatusCreated, map[string]interface{}{
+			"session_token": token,
+			"session":       session,
+			"capabilities":  s.Capabilities,
+		})
+	case http.MethodGet:
+		session := s.Lookup(sessionToken(r))
+		if session == nil {
+			WriteErrorJSON(w, http.StatusUnauthorized, "Sesión inválida o vencida; registre el cliente con POST /session", nil)
+			return
+		}
+		WriteJSON(w, http.StatusOK, map[string]interface{}{"session": session, "capabilities": s.Capabilities})
+	case http.MethodDelete:
+		if !s.Remove(sessionToken(r)) {
+			WriteErrorJSON(w, http.StatusUnauthorized, "Sesión inválida o vencida", nil)
+			return
+		}
+		WriteJSON(w, http.StatusOK, map[string]interface{}{"message": "Sesión cerrada."})
+	default:
+		WriteErrorJSON(w, http.StatusMethodNotAllowed, "Método HTTP no permitido", nil)
+	}
+}
+
+// CapabilitiesHandler devuelve las capacidades del agente sin registrar una sesión
+func (s *SessionStore) CapabilitiesHandler(w http.ResponseWriter, r *http.Request) {
+	s.Logger.ForRequest(r).Info("Received request: /capabilities")
+
+	if r.Method != http.MethodGet {
+		WriteErrorJSON(w, http.StatusMethodNotAllowed, "Método HTTP no permitido", nil)
+		return
+	}
+	WriteJSON(w, http.StatusOK, s.Capabilities)
+}
+
+// SessionsHandler lista los clientes registrados (GET /admin/sessions)
+func (s *SessionStore) SessionsHandler(w http.ResponseWriter, r *http.Request) {
+	s.Logger.ForRequest(r).Info("Received request: /admin/sessions")
+
+	if r.Method != http.MethodGet {
+		WriteErrorJSON(w, http.StatusMethodNotAllowed, "Método HTTP no permitido", nil)
+		return
+	}
+	WriteJSON(w, http.StatusOK, map[string]interface{}{"sessions": s.List()})
+}
+
+// buildCapabilities resume la configuración vigente del agente para los clientes
+func buildCapabilities(cfg Config, mux *routeMux, engines []string, jobs *JobRunner, reprint, routing, kitchenRouting, licensed bool) Capabilities {
+	features := []string{"base64", "upload", "webhooks", "jobs", "estimate", "stamp", "request_id", "printer_command", "job_cancel", "events", "events_sse", "schedule"}
+	optional := []struct {
+		name    string
+		enabled bool
+	}{
+		{"reprint", reprint},
+		{"routing", routing},
+		{"paper_hold", jobs.Holds != nil},
+		{"network_printers", len(cfg.NetworkPrinters) > 0},
+		{"ipp", len(cfg.IPPPrinters) > 0},
+		{"status_check", cfg.StatusCheckMode != StatusCheckOff},
+		{"graphql", cfg.GraphQLEnabled},
+		{"metrics", cfg.MetricsEnabled},
+		{"license", licensed},
+		{"relay", cfg.Relay.Enabled()},
+		{"mdns", cfg.MDNSEnabled},
+		{"tls_self_signed", cfg.TLSGenerated},
+		{"acme", cfg.ACME.Enabled() && !cfg.tlsFiles()},
+		{"mtls", cfg.TLSClientCAPath != ""},
+		{"ip_allowlist", len(cfg.IPAllowlist) > 0},
+		{"hot_folders", len(cfg.HotFolders.Folders) > 0},
+		{"customer_display", len(cfg.Displays.Ports) > 0},
+		{"drawer_monitor", len(cfg.Drawer.MonitorPrinters) > 0},
+		{"kitchen_routing", kitchenRouting},
+		{"health_monitor", len(cfg.Health.Printers) > 0},
+		{"snmp_status", len(cfg.SNMP.Printers) > 0},
+		{"spooler_tracking", cfg.SpoolerTracking.Enabled},
+		{"alerts", cfg.Alerts.Enabled()},
+		{"self_update", cfg.Update.Enabled() && updatePublicKey != ""},
+	}
+	for _, f := range optional {
+		if f.enabled {
+			features = append(features, f.name)
+		}
+	}
+	formats := []string{"pdf", LabelLanguageZPL, LabelLanguageEPL, "receipt", "image", "text"}
+	if cfg.OfficeConverterPath != "" {
+		formats = append(formats, "docx", "xlsx", "pptx", "odt", "ods", "odp")
+	}
+	return Capabilities{
+		AgentVersion: agentVersion,
+		Formats:      formats,
+		Endpoints:    mux.PublicEndpoints(),
+		APIBase:      apiPrefix,
+		Backend:      cfg.PrinterBackend,
+		Engines:      engines,
+		DrawerMethod: cfg.Drawer.Method,
+		Features:     features,
+		MaxUploadMB:  cfg.MaxUploadSizeMB,
+	}
+}
+
+// apiPrefix es el prefijo de la versión actual de la API
+const apiPrefix = "/v1"
+
+// routeMux registra las rutas del servidor para informarlas como capacidades
+type routeMux struct {
+	*http.ServeMux
+	patterns []string
+	// access restringe por IP las rutas que se registran (IP_ALLOWLIST)
+	access *IPAccess
+	// limits limita las solicitudes de las rutas de impresión y cajón (RATE_LIMIT_*)
+	limits *RateLimiter
+}
+
+func newRouteMux() *routeMux {
+	return &routeMux{ServeMux: http.NewServeMux()}
+}
+
+// Handle registra la ruta en la versión actual de la API (/v1) y sin prefijo, como alias para los
+// clientes anteriores
+func (m *routeMux) Handle(pattern string, handler http.Handler) {
+	m.patterns = append(m.patterns, pattern)
+	handler = m.access.Wrap(pattern, m.limits.Wrap(pattern, handler))
+	m.ServeMux.Handle(apiPrefix+pattern, handler)
+	m.ServeMux.Handle(pattern, handler)
+}
+
+func (m *routeMux) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	m.Handle(pattern, http.HandlerFunc(handler))
+}
+
+// Registered indica si la ruta está registrada
+func (m *routeMux) Registered(pattern string) bool {
+	for _, p := range m.patterns {
+		if p == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// PublicEndpoints devuelve las rutas registradas, excepto las administrativas
+func (m *routeMux) PublicEndpoints() []string {
+	endpoints := make([]string, 0, len(m.patterns))
+	for _, p := range m.patterns {
+		if p != "/admin" && !strings.HasPrefix(p, "/admin/") {
+			endpoints = append(endpoints, p)
+		}
+	}
+	sort.Strings(endpoints)
+	return endpoints
+}
@@ -0,0 +1,654 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ============================
+// Estado de Impresoras Láser por SNMP (Host Resources MIB y Printer MIB)
+// ============================
+
+// SNMPConfig configura la consulta de estado y consumibles por SNMP
+type SNMPConfig struct {
+	// Printers son las impresoras consultadas; "*" incluye todas las que tienen dirección de red
+	Printers         []string
+	Community        string
+	TimeoutSeconds   int
+	LowSupplyPercent int
+}
+
+// LoadSNMPConfig carga la configuración de SNMP desde variables de entorno
+func LoadSNMPConfig() SNMPConfig {
+	return SNMPConfig{
+		Printers:         getEnvAsSlice("SNMP_PRINTERS", ""),
+		Community:        getEnv("SNMP_COMMUNITY", "public"),
+		TimeoutSeconds:   getEnvAsInt("SNMP_TIMEOUT_SECONDS", 2),
+		LowSupplyPercent: getEnvAsInt("SNMP_LOW_SUPPLY_PERCENT", 10),
+	}
+}
+
+// defaultSNMPPort es el puerto UDP del agente SNMP de la impresora
+const defaultSNMPPort = "161"
+
+// OIDs consultados (RFC 2790 y RFC 3805)
+const (
+	oidHrPrinterStatus              = "1.3.6.1.2.1.25.3.5.1.1"
+	oidHrPrinterDetectedErrorState  = "1.3.6.1.2.1.25.3.5.1.2"
+	oidPrtMarkerSuppliesType        = "1.3.6.1.2.1.43.11.1.1.5"
+	oidPrtMarkerSuppliesDescription = "1.3.6.1.2.1.43.11.1.1.6"
+	oidPrtMarkerSuppliesMaxCapacity = "1.3.6.1.2.1.43.11.1.1.8"
+	oidPrtMarkerSuppliesLevel       = "1.3.6.1.2.1.43.11.1.1.9"
+	oidPrtInputMaxCapacity          = "1.3.6.1.2.1.43.8.2.1.9"
+	oidPrtInputCurrentLevel         = "1.3.6.1.2.1.43.8.2.1.10"
+	oidPrtInputName                 = "1.3.6.1.2.1.43.8.2.1.13"
+)
+
+// hrPrinterStatus
+const (
+	hrPrinterPrinting = 4
+	hrPrinterWarmup   = 5
+)
+
+// prtSupplyTypes traduce prtMarkerSuppliesType a un nombre estable
+var prtSupplyTypes = map[int64]string{
+	3:  "toner",
+	4:  "waste_toner",
+	5:  "ink",
+	6:  "ink_cartridge",
+	7:  "ink_ribbon",
+	8:  "waste_ink",
+	9:  "opc",
+	10: "developer",
+	15: "fuser",
+	18: "cleaner_unit",
+	20: "transfer_unit",
+	21: "toner_cartridge",
+}
+
+// tonerSupplyTypes son los consumibles cuyo nivel bajo se informa como tóner bajo
+var tonerSupplyTypes = map[string]bool{"toner": true, "toner_cartridge": true, "ink": true, "ink_cartridge": true}
+
+// PrinterSupply es un consumible informado por la impresora (tóner, tambor, fusor)
+type PrinterSupply struct {
+	Description string `json:"description"`
+	Type        string `json:"type"`
+	Level       int    `json:"level"`
+	MaxCapacity int    `json:"max_capacity"`
+	// Percent se omite si la impresora no informa el nivel o la capacidad
+	Percent *int `json:"percent,omitempty"`
+	Low     bool `json:"low"`
+}
+
+// PaperTray es una bandeja de entrada de papel
+type PaperTray struct {
+	Name        string `json:"name"`
+	Level       int    `json:"level"`
+	MaxCapacity int    `json:"max_capacity"`
+	Percent     *int   `json:"percent,omitempty"`
+	Empty       bool   `json:"empty"`
+}
+
+// SNMPStatus es el resultado de consultar una impresora por SNMP
+type SNMPStatus struct {
+	PrinterStatus int
+	ErrorState    []byte
+	Supplies      []PrinterSupply
+	Trays         []PaperTray
+}
+
+// snmpErrorStates son los bits de hrPrinterDetectedErrorState (el primer bit es el más significativo
+// del primer byte) con el estado que informan
+var snmpErrorStates = []struct {
+	byteIndex int
+	mask      byte
+	state     string
+}{
+	{0, 0x80, "PaperLow"},
+	{0, 0x40, "PaperOut"},
+	{0, 0x20, "TonerLow"},
+	{0, 0x10, "NoToner"},
+	{0, 0x08, "DoorOpen"},
+	{0, 0x04, "PaperJam"},
+	{0, 0x02, "Offline"},
+	{0, 0x01, "ServiceRequested"},
+	{1, 0x80, "InputTrayMissing"},
+	{1, 0x40, "OutputTrayMissing"},
+	{1, 0x20, "MarkerSupplyMissing"},
+	{1, 0x10, "OutputNearFull"},
+	{1, 0x08, "OutputFull"},
+	{1, 0x04, "InputTrayEmpty"},
+	{1, 0x02, "OverduePreventMaint"},
+}
+
+// States devuelve los estados activos de hrPrinterDetectedErrorState
+func (s *SNMPStatus) States() []string {
+	var states []string
+	switch s.PrinterStatus {
+	case hrPrinterPrinting:
+		states = append(states, "Printing")
+	case hrPrinterWarmup:
+		states = append(states, "WarmingUp")
+	}
+	for _, bit := range snmpErrorStates {
+		if bit.byteIndex < len(s.ErrorState) && s.ErrorState[bit.byteIndex]&bit.mask != 0 {
+			states = append(states, bit.state)
+		}
+	}
+	return states
+}
+
+// MergeSNMP incorpora el estado y los consumibles informados por SNMP. Los consumibles por debajo de
+// lowPercent se informan en warnings sin impedir la impresión.
+func (s *PrinterStatus) MergeSNMP(snmp *SNMPStatus, lowPercent int) {
+	for _, state := range snmp.States() {
+		switch state {
+		case "PaperOut":
+			s.PaperOut = true
+		case "PaperJam":
+			s.PaperJam = true
+		case "DoorOpen":
+			s.DoorOpen = true
+		case "Offline":
+			s.Online = false
+		case "TonerLow":
+			s.TonerLow = true
+		case "NoToner", "ServiceRequested", "MarkerSupplyMissing", "OutputFull":
+			s.Error = true
+		case "PaperLow":
+			s.Warnings = append(s.Warnings, "el papel está por agotarse")
+		}
+		s.States = append(s.States, state)
+	}
+
+	s.Supplies = snmp.Supplies
+	for i := range s.Supplies {
+		supply := &s.Supplies[i]
+		supply.Low = supply.Percent != nil && *supply.Percent <= lowPercent
+		if !supply.Low {
+			continue
+		}
+		s.Warnings = append(s.Warnings, fmt.Sprintf("%s al %d%%", supply.Description, *supply.Percent))
+		if tonerSupplyTypes[supply.Type] {
+			s.TonerLow = true
+		}
+	}
+	s.Trays = snmp.Trays
+	for _, tray := range s.Trays {
+		if tray.Empty {
+			s.Warnings = append(s.Warnings, fmt.Sprintf("la bandeja '%s' no tiene papel", tray.Name))
+		}
+	}
+	if s.TonerLow && !slices.Contains(s.States, "TonerLow") {
+		s.States = append(s.States, "TonerLow")
+	}
+	if len(s.States) > 0 {
+		s.Status = s.States[0]
+	}
+	s.finish()
+}
+
+// levelPercent calcula el porcentaje restante; nil si la impresora no informa el nivel o la
+// capacidad (niveles negativos: -2 desconocido, -3 queda algo)
+func levelPercent(level, maxCapacity int) *int {
+	if level < 0 || maxCapacity <= 0 {
+		return nil
+	}
+	percent := min(level*100/maxCapacity, 100)
+	return &percent
+}
+
+// QuerySNMPStatus consulta el estado, los consumibles y las bandejas de la impresora
+func (c *SNMPClient) QuerySNMPStatus(host string) (*SNMPStatus, error) {
+	status := &SNMPStatus{}
+	printerStatus, err := c.Walk(host, oidHrPrinterStatus)
+	if err != nil {
+		return nil, err
+	}
+	if len(printerStatus) == 0 {
+		return nil, fmt.Errorf("%s no informa hrPrinterStatus: no es una impresora o SNMP está restringido", host)
+	}
+	status.PrinterStatus = int(printerStatus[0].Int)
+	if errorState, err := c.Walk(host, oidHrPrinterDetectedErrorState); err != nil {
+		return nil, err
+	} else if len(errorState) > 0 {
+		status.ErrorState = errorState[0].Bytes
+	}
+
+	// Las columnas de cada tabla se consultan por separado y se combinan por el índice de la fila
+	columns := make(map[string]map[string]snmpVar)
+	for _, oid := range []string{
+		oidPrtMarkerSuppliesType, oidPrtMarkerSuppliesDescription, oidPrtMarkerSuppliesMaxCapacity, oidPrtMarkerSuppliesLevel,
+		oidPrtInputMaxCapacity, oidPrtInputCurrentLevel, oidPrtInputName,
+	} {
+		vars, err := c.Walk(host, oid)
+		if err != nil {
+			return nil, err
+		}
+		columns[oid] = make(map[string]snmpVar, len(vars))
+		for _, v := range vars {
+			columns[oid][strings.TrimPrefix(v.OID, oid+".")] = v
+		}
+	}
+
+	for _, index := range snmpRowIndexes(columns[oidPrtMarkerSuppliesLevel]) {
+		level := int(columns[oidPrtMarkerSuppliesLevel][index].Int)
+		maxCapacity := int(columns[oidPrtMarkerSuppliesMaxCapacity][index].Int)
+		supply := PrinterSupply{
+			Description: snmpText(columns[oidPrtMarkerSuppliesDescription][index].Bytes),
+			Type:        prtSupplyTypes[columns[oidPrtMarkerSuppliesType][index].Int],
+			Level:       level,
+			MaxCapacity: maxCapacity,
+			Percent:     levelPercent(level, maxCapacity),
+		}
+		if supply.Type == "" {
+			supply.Type = "other"
+		}
+		if supply.Description == "" {
+			supply.Description = "Consumible " + index
+		}
+		status.Supplies = append(status.Supplies, supply)
+	}
+	for _, index := range snmpRowIndexes(columns[oidPrtInputCurrentLevel]) {
+		level := int(columns[oidPrtInputCurrentLevel][index].Int)
+		maxCapacity := int(columns[oidPrtInputMaxCapacity][index].Int)
+		tray := PaperTray{
+			Name:        snmpText(columns[oidPrtInputName][index].Bytes),
+			Level:       level,
+			MaxCapacity: maxCapacity,
+			Percent:     levelPercent(level, maxCapacity),
+			Empty:       level == 0,
+		}
+		if tray.Name == "" {
+			tray.Name = "Bandeja " + index
+		}
+		status.Trays = append(status.Trays, tray)
+	}
+	return status, nil
+}
+
+// snmpRowIndexes devuelve los índices de las filas de una columna en el orden de la impresora
+func snmpRowIndexes(column map[string]snmpVar) []string {
+	indexes := make([]string, 0, len(column))
+	for index := range column {
+		indexes = append(indexes, index)
+	}
+	// Orden numérico por componente: 1.10 va después de 1.9
+	sort.Slice(indexes, func(i, j int) bool {
+		a, b := strings.Split(indexes[i], "."), strings.Split(indexes[j], ".")
+		for k := 0; k < len(a) && k < len(b); k++ {
+			na, _ := strconv.Atoi(a[k])
+			nb, _ := strconv.Atoi(b[k])
+			if na != nb {
+				return na < nb
+			}
+		}
+		return len(a) < len(b)
+	})
+	return indexes
+}
+
+// snmpText limpia las cadenas de la impresora, que a veces terminan en NUL o espacios
+func snmpText(b []byte) string {
+	return strings.TrimSpace(strings.TrimRight(string(b), "\x00"))
+}
+
+// SNMPPrinterManager completa el estado de las impresoras láser de red con el informado por SNMP:
+// errores del equipo (atasco, tapa abierta, sin tóner) y niveles de tóner y papel
+type SNMPPrinterManager struct {
+	Next             PrinterManager
+	Printers         []string
+	Addresses        map[string]string
+	Client           *SNMPClient
+	LowSupplyPercent int
+	Logger           *Logger
+}
+
+// ListPrinters delega en el administrador real
+func (m SNMPPrinterManager) ListPrinters() ([]string, error) {
+	return m.Next.ListPrinters()
+}
+
+// PrinterExists delega en el administrador real
+func (m SNMPPrinterManager) PrinterExists(name string) (bool, error) {
+	return m.Next.PrinterExists(name)
+}
+
+// DefaultPrinter delega en el administrador real
+func (m SNMPPrinterManager) DefaultPrinter() (string, error) {
+	return m.Next.DefaultPrinter()
+}
+
+// GetPrinterStatus agrega al estado del spooler el consultado por SNMP. Si la impresora no responde
+// se conserva el del spooler con una advertencia.
+func (m SNMPPrinterManager) GetPrinterStatus(name string) (*PrinterStatus, error) {
+	status, err := m.Next.GetPrinterStatus(name)
+	if err != nil || !matchesPrinter(m.Printers, name) {
+		return status, err
+	}
+	address, err := resolvePrinterAddress(m.Addresses, name)
+	if err != nil {
+		// Con "*" se omiten en silencio las impresoras locales (USB)
+		if slices.Contains(m.Printers, name) {
+			m.Logger.Warnf("No se puede consultar '%s' por SNMP: %v", name, err)
+		}
+		return status, nil
+	}
+	host := address
+	if h, _, err := net.SplitHostPort(address); err == nil {
+		host = h
+	}
+	snmp, err := m.Client.QuerySNMPStatus(host)
+	if err != nil {
+		m.Logger.Warnf("No se pudo consultar '%s' por SNMP: %v", name, err)
+		status.Warnings = append(status.Warnings, "no se pudo consultar la impresora por SNMP")
+		return status, nil
+	}
+	status.MergeSNMP(snmp, m.LowSupplyPercent)
+	return status, nil
+}
+
+// ============================
+// Cliente SNMP v2c (solo lectura)
+// ============================
+
+// ErrSNMPTimeout indica que el agente SNMP no respondió
+var ErrSNMPTimeout = errors.New("el agente SNMP no respondió")
+
+// Etiquetas BER de SNMP
+const (
+	berInteger      = 0x02
+	berOctetString  = 0x04
+	berNull         = 0x05
+	berOID          = 0x06
+	berSequence     = 0x30
+	berCounter32    = 0x41
+	berGauge32      = 0x42
+	berTimeTicks    = 0x43
+	berEndOfMibView = 0x82
+	snmpGetNext     = 0xA1
+	snmpResponse    = 0xA2
+	snmpVersion2c   = 1
+	// snmpMaxWalk limita las filas de una columna: las tablas de una impresora tienen pocas
+	snmpMaxWalk = 64
+)
+
+// snmpVar es un valor devuelto por el agente
+type snmpVar struct {
+	OID   string
+	Type  byte
+	Int   int64
+	Bytes []byte
+}
+
+// SNMPClient consulta agentes SNMP v2c con GetNext
+type SNMPClient struct {
+	Community string
+	Timeout   time.Duration
+	Port      string
+}
+
+// NewSNMPClient crea el cliente con la comunidad de solo lectura
+func NewSNMPClient(community string, timeout time.Duration) *SNMPClient {
+	return &SNMPClient{Community: community, Timeout: timeout, Port: defaultSNMPPort}
+}
+
+// Walk devuelve los valores bajo el OID raíz (una columna de una tabla)
+func (c *SNMPClient) Walk(host, root string) ([]snmpVar, error) {
+	conn, err := net.Dial("udp", net.JoinHostPort(host, c.Port))
+	if err != nil {
+		return nil, fmt.Errorf("no se pudo conectar al agente SNMP de %s: %w", host, err)
+	}
+	defer conn.Close()
+
+	var out []snmpVar
+	oid := root
+	for len(out) < snmpMaxWalk {
+		v, err := c.getNext(conn, oid)
+		if err != nil {
+			return nil, fmt.Errorf("error al consultar %s en %s: %w", root, host, err)
+		}
+		if v.Type == berEndOfMibView || !strings.HasPrefix(v.OID, root+".") {
+			break
+		}
+		out = append(out, v)
+		oid = v.OID
+	}
+	return out, nil
+}
+
+// getNext envía un GetNextRequest y espera la respuesta; reintenta una vez porque UDP puede perderla
+func (c *SNMPClient) getNext(conn net.Conn, oid string) (snmpVar, error) {
+	encodedOID, err := berEncodeOID(oid)
+	if err != nil {
+		return snmpVar{}, err
+	}
+	var id [4]byte
+	rand.Read(id[:])
+	requestID := int64(binary.BigEndian.Uint32(id[:]) & 0x7fffffff)
+
+	varbind := berTLV(berSequence, append(berTLV(berOID, encodedOID), berNull, 0))
+	pdu := berTLV(snmpGetNext, snmpConcat(berInt(requestID), berInt(0), berInt(0), berTLV(berSequence, varbind)))
+	message := berTLV(berSequence, snmpConcat(berInt(snmpVersion2c), berTLV(berOctetString, []byte(c.Community)), pdu))
+
+	buf := make([]byte, 65535)
+	for attempt := 0; attempt < 2; attempt++ {
+		if _, err := conn.Write(message); err != nil {
+			return snmpVar{}, err
+		}
+		conn.SetReadDeadline(time.Now().Add(c.Timeout))
+		for {
+			n, err := conn.Read(buf)
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				break
+			}
+			if err != nil {
+				return snmpVar{}, err
+			}
+			responseID, v, err := parseSNMPResponse(buf[:n])
+			if err != nil {
+				return snmpVar{}, err
+			}
+			// Se descartan las respuestas demoradas de un intento anterior
+			if responseID == requestID {
+				return v, nil
+			}
+		}
+	}
+	return snmpVar{}, ErrSNMPTimeout
+}
+
+// parseSNMPResponse decodifica la respuesta y devuelve su request-id y el primer valor
+func parseSNMPResponse(data []byte) (int64, snmpVar, error) {
+	malformed := errors.New("respuesta SNMP mal formada")
+	_, message, _, ok := berRead(data)
+	if !ok {
+		return 0, snmpVar{}, malformed
+	}
+	fields := make([][]byte, 0, 3)
+	tags := make([]byte, 0, 3)
+	for len(message) > 0 && len(fields) < 3 {
+		tag, content, rest, ok := berRead(message)
+		if !ok {
+			return 0, snmpVar{}, malformed
+		}
+		tags, fields, message = append(tags, tag), append(fields, content), rest
+	}
+	if len(fields) != 3 || tags[2] != snmpResponse {
+		return 0, snmpVar{}, malformed
+	}
+
+	// request-id, error-status, error-index y la lista de valores
+	pdu := fields[2]
+	var ints [3]int64
+	for i := range ints {
+		tag, content, rest, ok := berRead(pdu)
+		if !ok || tag != berInteger {
+			return 0, snmpVar{}, malformed
+		}
+		ints[i], pdu = berParseInt(content), rest
+	}
+	if ints[1] != 0 {
+		return ints[0], snmpVar{}, fmt.Errorf("el agente SNMP respondió con error %d", ints[1])
+	}
+	_, varbinds, _, ok := berRead(pdu)
+	if !ok {
+		return 0, snmpVar{}, malformed
+	}
+	_, varbind, _, ok := berRead(varbinds)
+	if !ok {
+		return 0, snmpVar{}, malformed
+	}
+	tag, oid, rest, ok := berRead(varbind)
+	if !ok || tag != berOID {
+		return 0, snmpVar{}, malformed
+	}
+	valueTag, value, _, ok := berRead(rest)
+	if !ok {
+		return 0, snmpVar{}, malformed
+	}
+	v := snmpVar{OID: berDecodeOID(oid), Type: valueTag, Bytes: value}
+	switch valueTag {
+	case berInteger:
+		v.Int = berParseInt(value)
+	case berCounter32, berGauge32, berTimeTicks:
+		v.Int = berParseUint(value)
+	}
+	return ints[0], v, nil
+}
+
+// berTLV codifica un valor con su etiqueta y longitud
+func berTLV(tag byte, content []byte) []byte {
+	out := []byte{tag}
+	switch n := len(content); {
+	case n < 0x80:
+		out = append(out, byte(n))
+	case n <= 0xff:
+		out = append(out, 0x81, byte(n))
+	default:
+		out = append(out, 0x82, byte(n>>8), byte(n))
+	}
+	return append(out, content...)
+}
+
+// berInt codifica un entero en complemento a dos con la menor cantidad de bytes
+func berInt(v int64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(v))
+	for len(b) > 1 && ((b[0] == 0 && b[1]&0x80 == 0) || (b[0] == 0xff && b[1]&0x80 != 0)) {
+		b = b[1:]
+	}
+	return berTLV(berInteger, b)
+}
+
+// berEncodeOID codifica un OID en notación de puntos
+func berEncodeOID(oid string) ([]byte, error) {
+	parts := strings.Split(oid, ".")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("OID inválido: %s", oid)
+	}
+	ids := make([]uint64, len(parts))
+	for i, part := range parts {
+		id, err := strconv.ParseUint(part, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("OID inválido: %s", oid)
+		}
+		ids[i] = id
+	}
+	out := []byte{byte(ids[0]*40 + ids[1])}
+	for _, id := range ids[2:] {
+		// Base 128, con el bit alto en todos los bytes menos el último
+		var chunk []byte
+		for {
+			chunk = append([]byte{byte(id & 0x7f)}, chunk...)
+			id >>= 7
+			if id == 0 {
+				break
+			}
+		}
+		for i := 0; i < len(chunk)-1; i++ {
+			chunk[i] |= 0x80
+		}
+		out = append(out, chunk...)
+	}
+	return out, nil
+}
+
+// berDecodeOID decodifica un OID a notación de puntos
+func berDecodeOID(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	parts := []string{strconv.Itoa(int(b[0]) / 40), strconv.Itoa(int(b[0]) % 40)}
+	var id uint64
+	for _, c := range b[1:] {
+		id = id<<7 | uint64(c&0x7f)
+		if c&0x80 == 0 {
+			parts = append(parts, strconv.FormatUint(id, 10))
+			id = 0
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+// berRead lee un valor y devuelve su etiqueta, su contenido y lo que sigue
+func berRead(data []byte) (tag byte, content, rest []byte, ok bool) {
+	if len(data) < 2 {
+		return 0, nil, nil, false
+	}
+	tag, length, offset := data[0], int(data[1]), 2
+	if length&0x80 != 0 {
+		n := length & 0x7f
+		if n == 0 || n > 3 || len(data) < 2+n {
+			return 0, nil, nil, false
+		}
+		length = 0
+		for _, b := range data[2 : 2+n] {
+			length = length<<8 | int(b)
+		}
+		offset += n
+	}
+	if len(data) < offset+length {
+		return 0, nil, nil, false
+	}
+	return tag, data[offset : offset+length], data[offset+length:], true
+}
+
+// berParseInt decodifica un entero con signo
+func berParseInt(b []byte) int64 {
+	var v int64
+	for i, c := range b {
+		if i == 0 && c&0x80 != 0 {
+			v = -1
+		}
+		v = v<<8 | int64(c)
+	}
+	return v
+}
+
+// berParseUint decodifica un entero sin signo (Counter32, Gauge32)
+func berParseUint(b []byte) int64 {
+	var v int64
+	for _, c := range b {
+		v = v<<8 | int64(c)
+	}
+	return v
+}
+
+// snmpConcat une los segmentos codificados
+func snmpConcat(parts ...[]byte) []byte {
+	var out []byte
+	for _, p := range parts {
+		out = append(out, p...)
+	}
+	return out
+}
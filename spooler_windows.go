@@ -0,0 +1,480 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// ============================
+// Integración nativa con el Spooler de Windows (winspool.drv)
+// ============================
+
+var (
+	modWinspool = windows.NewLazySystemDLL("winspool.drv")
+
+	procEnumPrintersW = modWinspool.NewProc("EnumPrintersW")
+	procOpenPrinterW  = modWinspool.NewProc("OpenPrinterW")
+	procClosePrinter  = modWinspool.NewProc("ClosePrinter")
+	procGetPrinterW   = modWinspool.NewProc("GetPrinterW")
+	procEnumJobsW     = modWinspool.NewProc("EnumJobsW")
+	procSetJobW       = modWinspool.NewProc("SetJobW")
+
+	procGetDefaultPrinterW = modWinspool.NewProc("GetDefaultPrinterW")
+)
+
+// Flags de EnumPrinters
+const (
+	printerEnumLocal       = 0x00000002
+	printerEnumConnections = 0x00000004
+)
+
+// Comandos de SetJob y SetPrinter
+const (
+	jobControlDelete = 5
+
+	printerControlPause  = 1
+	printerControlResume = 2
+	printerControlPurge  = 3
+)
+
+// printerAccessAdminister es el acceso requerido por OpenPrinter para pausar, reanudar o vaciar la cola
+const printerAccessAdminister = 0x00000004
+
+// printerDefaults refleja la estructura PRINTER_DEFAULTSW
+type printerDefaults struct {
+	Datatype      *uint16
+	DevMode       uintptr
+	DesiredAccess uint32
+}
+
+// Bits de estado de JOB_INFO_1.Status
+const (
+	jobStatusPaused           = 0x00000001
+	jobStatusError            = 0x00000002
+	jobStatusDeleting         = 0x00000004
+	jobStatusSpooling         = 0x00000008
+	jobStatusPrinting         = 0x00000010
+	jobStatusOffline          = 0x00000020
+	jobStatusPaperOut         = 0x00000040
+	jobStatusPrinted          = 0x00000080
+	jobStatusDeleted          = 0x00000100
+	jobStatusBlockedDevQ      = 0x00000200
+	jobStatusUserIntervention = 0x00000400
+	jobStatusRestart          = 0x00000800
+	jobStatusComplete         = 0x00001000
+	jobStatusRetained         = 0x00002000
+)
+
+// jobStatusNames ordena los estados de un documento por prioridad, igual que printerStatusNames
+var jobStatusNames = []struct {
+	bit  uint32
+	name string
+}{
+	{jobStatusError, "Error"},
+	{jobStatusOffline, "Offline"},
+	{jobStatusPaperOut, "PaperOut"},
+	{jobStatusUserIntervention, "UserIntervention"},
+	{jobStatusBlockedDevQ, "Blocked"},
+	{jobStatusDeleting, "Deleting"},
+	{jobStatusDeleted, "Deleted"},
+	{jobStatusPaused, "Paused"},
+	{jobStatusRestart, "Restart"},
+	{jobStatusPrinting, "Printing"},
+	{jobStatusSpooling, "Spooling"},
+	{jobStatusPrinted, "Printed"},
+	{jobStatusComplete, "Complete"},
+	{jobStatusRetained, "Retained"},
+}
+
+// Bits de estado de PRINTER_INFO_2.Status
+const (
+	printerStatusPaused           = 0x00000001
+	printerStatusError            = 0x00000002
+	printerStatusPendingDeletion  = 0x00000004
+	printerStatusPaperJam         = 0x00000008
+	printerStatusPaperOut         = 0x00000010
+	printerStatusManualFeed       = 0x00000020
+	printerStatusPaperProblem     = 0x00000040
+	printerStatusOffline          = 0x00000080
+	printerStatusIOActive         = 0x00000100
+	printerStatusBusy             = 0x00000200
+	printerStatusPrinting         = 0x00000400
+	printerStatusOutputBinFull    = 0x00000800
+	printerStatusNotAvailable     = 0x00001000
+	printerStatusWaiting          = 0x00002000
+	printerStatusProcessing       = 0x00004000
+	printerStatusInitializing     = 0x00008000
+	printerStatusWarmingUp        = 0x00010000
+	printerStatusTonerLow         = 0x00020000
+	printerStatusNoToner          = 0x00040000
+	printerStatusPagePunt         = 0x00080000
+	printerStatusUserIntervention = 0x00100000
+	printerStatusOutOfMemory      = 0x00200000
+	printerStatusDoorOpen         = 0x00400000
+	printerStatusServerUnknown    = 0x00800000
+	printerStatusPowerSave        = 0x01000000
+)
+
+// printerStatusNames conserva los mismos nombres que reportaba Get-Printer (PrinterStatus),
+// ordenados por prioridad para que los errores se reporten antes que los estados informativos.
+var printerStatusNames = []struct {
+	bit  uint32
+	name string
+}{
+	{printerStatusError, "Error"},
+	{printerStatusOffline, "Offline"},
+	{printerStatusPaperOut, "PaperOut"},
+	{printerStatusPaperJam, "PaperJam"},
+	{printerStatusPaperProblem, "PaperProblem"},
+	{printerStatusDoorOpen, "DoorOpen"},
+	{printerStatusNoToner, "NoToner"},
+	{printerStatusNotAvailable, "NotAvailable"},
+	{printerStatusUserIntervention, "UserIntervention"},
+	{printerStatusOutOfMemory, "OutOfMemory"},
+	{printerStatusOutputBinFull, "OutputBinFull"},
+	{printerStatusPaused, "Paused"},
+	{printerStatusPendingDeletion, "PendingDeletion"},
+	{printerStatusManualFeed, "ManualFeed"},
+	{printerStatusPagePunt, "PagePunt"},
+	{printerStatusTonerLow, "TonerLow"},
+	{printerStatusPrinting, "Printing"},
+	{printerStatusProcessing, "Processing"},
+	{printerStatusBusy, "Busy"},
+	{printerStatusIOActive, "IOActive"},
+	{printerStatusWaiting, "Waiting"},
+	{printerStatusInitializing, "Initializing"},
+	{printerStatusWarmingUp, "WarmingUp"},
+	{printerStatusServerUnknown, "ServerUnknown"},
+	{printerStatusPowerSave, "PowerSave"},
+}
+
+// printerInfo2 refleja la estructura PRINTER_INFO_2W de winspool
+type printerInfo2 struct {
+	ServerName         *uint16
+	PrinterName        *uint16
+	ShareName          *uint16
+	PortName           *uint16
+	DriverName         *uint16
+	Comment            *uint16
+	Location           *uint16
+	DevMode            uintptr
+	SepFile            *uint16
+	PrintProcessor     *uint16
+	Datatype           *uint16
+	Parameters         *uint16
+	SecurityDescriptor uintptr
+	Attributes         uint32
+	Priority           uint32
+	DefaultPriority    uint32
+	StartTime          uint32
+	UntilTime          uint32
+	Status             uint32
+	Jobs               uint32
+	AveragePPM         uint32
+}
+
+// SpoolerPrinter contiene los datos relevantes de una impresora obtenidos del spooler
+type SpoolerPrinter struct {
+	Name       string
+	DriverName string
+	PortName   string
+	Location   string
+	Status     uint32
+	Jobs       uint32
+}
+
+// StatusName traduce el campo de estado del spooler al nombre usado por Get-Printer
+func (p SpoolerPrinter) StatusName() string {
+	for _, s := range printerStatusNames {
+		if p.Status&s.bit != 0 {
+			return s.name
+		}
+	}
+	return "Normal"
+}
+
+// StatusNames devuelve todos los estados activos, en el mismo orden de prioridad que StatusName
+func (p SpoolerPrinter) StatusNames() []string {
+	names := []string{}
+	for _, s := range printerStatusNames {
+		if p.Status&s.bit != 0 {
+			names = append(names, s.name)
+		}
+	}
+	return names
+}
+
+// PrinterStatus traduce los bits de estado del spooler al estado informado por la API
+func (p SpoolerPrinter) PrinterStatus() *PrinterStatus {
+	status := &PrinterStatus{
+		Printer:   p.Name,
+		Status:    p.StatusName(),
+		States:    p.StatusNames(),
+		Online:    p.Status&(printerStatusOffline|printerStatusNotAvailable) == 0,
+		PaperOut:  p.Status&printerStatusPaperOut != 0,
+		PaperJam:  p.Status&printerStatusPaperJam != 0,
+		DoorOpen:  p.Status&printerStatusDoorOpen != 0,
+		Error:     p.Status&(printerStatusError|printerStatusPaperProblem|printerStatusNoToner|printerStatusUserIntervention|printerStatusOutOfMemory) != 0,
+		Paused:    p.Status&printerStatusPaused != 0,
+		TonerLow:  p.Status&printerStatusTonerLow != 0,
+		Jobs:      int(p.Jobs),
+		Source:    "spooler",
+		CheckedAt: time.Now(),
+	}
+	status.finish()
+	return status
+}
+
+func newSpoolerPrinter(info *printerInfo2) SpoolerPrinter {
+	return SpoolerPrinter{
+		Name:       windows.UTF16PtrToString(info.PrinterName),
+		DriverName: windows.UTF16PtrToString(info.DriverName),
+		PortName:   windows.UTF16PtrToString(info.PortName),
+		Location:   windows.UTF16PtrToString(info.Location),
+		Status:     info.Status,
+		Jobs:       info.Jobs,
+	}
+}
+
+// enumSpoolerPrinters enumera las impresoras locales y conectadas usando EnumPrintersW nivel 2
+func enumSpoolerPrinters() ([]SpoolerPrinter, error) {
+	flags := uintptr(printerEnumLocal | printerEnumConnections)
+	var needed, returned uint32
+
+	// Primera llamada para conocer el tamaño del buffer requerido
+	r1, _, err := procEnumPrintersW.Call(flags, 0, 2, 0, 0,
+		uintptr(unsafe.Pointer(&needed)), uintptr(unsafe.Pointer(&returned)))
+	if r1 == 0 && err != windows.ERROR_INSUFFICIENT_BUFFER {
+		return nil, fmt.Errorf("EnumPrinters falló: %w", err)
+	}
+	if needed == 0 {
+		return nil, nil
+	}
+
+	buf := make([]byte, needed)
+	r1, _, err = procEnumPrintersW.Call(flags, 0, 2,
+		uintptr(unsafe.Pointer(&buf[0])), uintptr(needed),
+		uintptr(unsafe.Pointer(&needed)), uintptr(unsafe.Pointer(&returned)))
+	if r1 == 0 {
+		return nil, fmt.Errorf("EnumPrinters falló: %w", err)
+	}
+
+	infos := unsafe.Slice((*printerInfo2)(unsafe.Pointer(&buf[0])), returned)
+	printers := make([]SpoolerPrinter, 0, returned)
+	for i := range infos {
+		printers = append(printers, newSpoolerPrinter(&infos[i]))
+	}
+	return printers, nil
+}
+
+// openSpoolerPrinter abre un handle a la impresora indicada; el llamador debe cerrarlo con closeSpoolerPrinter
+func openSpoolerPrinter(name string) (windows.Handle, error) {
+	return openSpoolerPrinterAccess(name, 0)
+}
+
+// openSpoolerPrinterAccess abre la impresora con el acceso indicado (0 usa el acceso predeterminado)
+func openSpoolerPrinterAccess(name string, access uint32) (windows.Handle, error) {
+	namePtr, err := windows.UTF16PtrFromString(name)
+	if err != nil {
+		return 0, err
+	}
+	var defaults uintptr
+	if access != 0 {
+		defaults = uintptr(unsafe.Pointer(&printerDefaults{DesiredAccess: access}))
+	}
+	var h windows.Handle
+	r1, _, err := procOpenPrinterW.Call(uintptr(unsafe.Pointer(namePtr)), uintptr(unsafe.Pointer(&h)), defaults)
+	if r1 == 0 {
+		return 0, fmt.Errorf("OpenPrinter falló para '%s': %w", name, err)
+	}
+	return h, nil
+}
+
+func closeSpoolerPrinter(h windows.Handle) {
+	procClosePrinter.Call(uintptr(h))
+}
+
+// getSpoolerPrinter consulta los datos de una impresora con GetPrinterW nivel 2
+func getSpoolerPrinter(name string) (SpoolerPrinter, error) {
+	h, err := openSpoolerPrinter(name)
+	if err != nil {
+		return SpoolerPrinter{}, err
+	}
+	defer closeSpoolerPrinter(h)
+
+	var needed uint32
+	r1, _, err := procGetPrinterW.Call(uintptr(h), 2, 0, 0, uintptr(unsafe.Pointer(&needed)))
+	if r1 == 0 && err != windows.ERROR_INSUFFICIENT_BUFFER {
+		return SpoolerPrinter{}, fmt.Errorf("GetPrinter falló: %w", err)
+	}
+	if needed == 0 {
+		return SpoolerPrinter{}, fmt.Errorf("GetPrinter no devolvió datos de '%s'", name)
+	}
+
+	buf := make([]byte, needed)
+	r1, _, err = procGetPrinterW.Call(uintptr(h), 2,
+		uintptr(unsafe.Pointer(&buf[0])), uintptr(needed), uintptr(unsafe.Pointer(&needed)))
+	if r1 == 0 {
+		return SpoolerPrinter{}, fmt.Errorf("GetPrinter falló: %w", err)
+	}
+	return newSpoolerPrinter((*printerInfo2)(unsafe.Pointer(&buf[0]))), nil
+}
+
+// getDefaultSpoolerPrinter devuelve la impresora predeterminada del usuario con GetDefaultPrinterW
+func getDefaultSpoolerPrinter() (string, error) {
+	var size uint32
+	r1, _, err := procGetDefaultPrinterW.Call(0, uintptr(unsafe.Pointer(&size)))
+	if r1 == 0 && err != windows.ERROR_INSUFFICIENT_BUFFER {
+		if err == windows.ERROR_FILE_NOT_FOUND {
+			return "", errors.New("Windows no tiene una impresora predeterminada")
+		}
+		return "", fmt.Errorf("GetDefaultPrinter falló: %w", err)
+	}
+
+	buf := make([]uint16, size)
+	r1, _, err = procGetDefaultPrinterW.Call(uintptr(unsafe.Pointer(&buf[0])), uintptr(unsafe.Pointer(&size)))
+	if r1 == 0 {
+		return "", fmt.Errorf("GetDefaultPrinter falló: %w", err)
+	}
+	return windows.UTF16ToString(buf), nil
+}
+
+// isPrinterNotFound indica si el error del spooler corresponde a una impresora inexistente
+func isPrinterNotFound(err error) bool {
+	return errors.Is(err, windows.ERROR_INVALID_PRINTER_NAME)
+}
+
+// jobInfo1 refleja la estructura JOB_INFO_1W de winspool
+type jobInfo1 struct {
+	JobID        uint32
+	PrinterName  *uint16
+	MachineName  *uint16
+	UserName     *uint16
+	Document     *uint16
+	Datatype     *uint16
+	Status       *uint16
+	StatusBits   uint32
+	Priority     uint32
+	Position     uint32
+	TotalPages   uint32
+	PagesPrinted uint32
+	Submitted    windows.Systemtime
+}
+
+// SpoolerJob contiene los datos de un documento en la cola de una impresora
+type SpoolerJob struct {
+	ID           uint32
+	Document     string
+	User         string
+	Machine      string
+	Status       uint32
+	StatusText   string
+	TotalPages   uint32
+	PagesPrinted uint32
+	Position     uint32
+	Submitted    time.Time
+}
+
+// StatusNames devuelve los estados activos del documento; sin bits de estado está en espera
+func (j SpoolerJob) StatusNames() []string {
+	names := []string{}
+	for _, s := range jobStatusNames {
+		if j.Status&s.bit != 0 {
+			names = append(names, s.name)
+		}
+	}
+	if len(names) == 0 {
+		names = append(names, "Queued")
+	}
+	return names
+}
+
+// enumSpoolerJobs lista los documentos en la cola de la impresora usando EnumJobsW nivel 1
+func enumSpoolerJobs(printer string) ([]SpoolerJob, error) {
+	h, err := openSpoolerPrinter(printer)
+	if err != nil {
+		return nil, err
+	}
+	defer closeSpoolerPrinter(h)
+
+	const maxJobs = 0xFFFFFFFF
+	var needed, returned uint32
+	r1, _, err := procEnumJobsW.Call(uintptr(h), 0, maxJobs, 1, 0, 0,
+		uintptr(unsafe.Pointer(&needed)), uintptr(unsafe.Pointer(&returned)))
+	if r1 == 0 && err != windows.ERROR_INSUFFICIENT_BUFFER {
+		return nil, fmt.Errorf("EnumJobs falló: %w", err)
+	}
+	if needed == 0 {
+		return nil, nil
+	}
+
+	buf := make([]byte, needed)
+	r1, _, err = procEnumJobsW.Call(uintptr(h), 0, maxJobs, 1,
+		uintptr(unsafe.Pointer(&buf[0])), uintptr(needed),
+		uintptr(unsafe.Pointer(&needed)), uintptr(unsafe.Pointer(&returned)))
+	if r1 == 0 {
+		return nil, fmt.Errorf("EnumJobs falló: %w", err)
+	}
+
+	infos := unsafe.Slice((*jobInfo1)(unsafe.Pointer(&buf[0])), returned)
+	jobs := make([]SpoolerJob, 0, returned)
+	for i := range infos {
+		info := &infos[i]
+		jobs = append(jobs, SpoolerJob{
+			ID:           info.JobID,
+			Document:     windows.UTF16PtrToString(info.Document),
+			User:         windows.UTF16PtrToString(info.UserName),
+			Machine:      windows.UTF16PtrToString(info.MachineName),
+			Status:       info.StatusBits,
+			StatusText:   windows.UTF16PtrToString(info.Status),
+			TotalPages:   info.TotalPages,
+			PagesPrinted: info.PagesPrinted,
+			Position:     info.Position,
+			Submitted:    systemTimeToTime(info.Submitted),
+		})
+	}
+	return jobs, nil
+}
+
+// deleteSpoolerJob elimina un documento de la cola de la impresora (SetJobW con JOB_CONTROL_DELETE)
+func deleteSpoolerJob(printer string, id uint32) error {
+	h, err := openSpoolerPrinter(printer)
+	if err != nil {
+		return err
+	}
+	defer closeSpoolerPrinter(h)
+
+	r1, _, err := procSetJobW.Call(uintptr(h), uintptr(id), 0, 0, jobControlDelete)
+	if r1 == 0 {
+		return fmt.Errorf("SetJob falló para el documento %d: %w", id, err)
+	}
+	return nil
+}
+
+// controlSpoolerPrinter pausa, reanuda o vacía la cola de la impresora con SetPrinterW
+func controlSpoolerPrinter(printer string, command uint32) error {
+	h, err := openSpoolerPrinterAccess(printer, printerAccessAdminister)
+	if err != nil {
+		return err
+	}
+	defer closeSpoolerPrinter(h)
+
+	r1, _, err := procSetPrinterW.Call(uintptr(h), 0, 0, uintptr(command))
+	if r1 == 0 {
+		return fmt.Errorf("SetPrinter falló: %w", err)
+	}
+	return nil
+}
+
+// systemTimeToTime convierte un SYSTEMTIME (UTC, como lo informa el spooler) a time.Time
+func systemTimeToTime(st windows.Systemtime) time.Time {
+	if st.Year == 0 {
+		return time.Time{}
+	}
+	return time.Date(int(st.Year), time.Month(st.Month), int(st.Day),
+		int(st.Hour), int(st.Minute), int(st.Second), int(st.Milliseconds)*int(time.Millisecond), time.UTC)
+}
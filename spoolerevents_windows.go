@@ -0,0 +1,235 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// ============================
+// Eventos WMI del Spooler (Win32_PrintJob)
+// ============================
+
+var (
+	modOle32                 = windows.NewLazySystemDLL("ole32.dll")
+	modOleAut32              = windows.NewLazySystemDLL("oleaut32.dll")
+	procCoCreateInstance     = modOle32.NewProc("CoCreateInstance")
+	procCoInitializeSecurity = modOle32.NewProc("CoInitializeSecurity")
+	procCoSetProxyBlanket    = modOle32.NewProc("CoSetProxyBlanket")
+	procSysAllocString       = modOleAut32.NewProc("SysAllocString")
+	procSysFreeString        = modOleAut32.NewProc("SysFreeString")
+	procVariantClear         = modOleAut32.NewProc("VariantClear")
+)
+
+var (
+	clsidWbemLocator    = windows.GUID{Data1: 0x4590F811, Data2: 0x1D3A, Data3: 0x11D0, Data4: [8]byte{0x89, 0x1F, 0x00, 0xAA, 0x00, 0x4B, 0x2E, 0x24}}
+	iidIWbemLocator     = windows.GUID{Data1: 0xDC12A687, Data2: 0x737F, Data3: 0x11CF, Data4: [8]byte{0x88, 0x4D, 0x00, 0xAA, 0x00, 0x4B, 0x2E, 0x24}}
+	iidIWbemClassObject = windows.GUID{Data1: 0xDC12A681, Data2: 0x737F, Data3: 0x11CF, Data4: [8]byte{0x88, 0x4D, 0x00, 0xAA, 0x00, 0x4B, 0x2E, 0x24}}
+)
+
+// Métodos de las interfaces WMI, por su posición en la tabla virtual
+const (
+	wbemLocatorConnectServer          = 3  // IWbemLocator::ConnectServer
+	wbemServicesExecNotificationQuery = 22 // IWbemServices::ExecNotificationQuery
+	wbemEnumNext                      = 4  // IEnumWbemClassObject::Next
+	wbemObjectGet                     = 4  // IWbemClassObject::Get
+)
+
+const (
+	wbemFlagReturnImmediately = 0x10
+	wbemFlagForwardOnly       = 0x20
+	wbemTimedOut              = 0x40004 // WBEM_S_TIMEDOUT
+	// wbemNextTimeoutMs es la espera de cada Next: cada cuánto se revisa si se cerró la suscripción
+	wbemNextTimeoutMs = 1000
+
+	clsctxInprocServer     = 1
+	rpcAuthnWinNT          = 10
+	rpcAuthzNone           = 0
+	rpcAuthnLevelDefault   = 0
+	rpcAuthnLevelCall      = 3
+	rpcImpLevelImpersonate = 3
+	eoacNone               = 0
+
+	vtI4      = 3
+	vtBSTR    = 8
+	vtUnknown = 13
+)
+
+// wmiPrintJobQuery recibe la creación, los cambios y la eliminación de los documentos de todas las
+// colas; WITHIN es el intervalo con que WMI consulta el spooler
+const wmiPrintJobQuery = "SELECT * FROM __InstanceOperationEvent WITHIN 1 WHERE TargetInstance ISA 'Win32_PrintJob'"
+
+// variant refleja la estructura VARIANT (16 bytes en 32 bits, 24 en 64 bits)
+type variant struct {
+	VT  uint16
+	_   [3]uint16
+	Val uintptr
+	_   uintptr
+}
+
+// newSpoolerEventSource devuelve la suscripción a los eventos WMI de los documentos del spooler
+func newSpoolerEventSource() SpoolerEventSource {
+	return WMISpoolerEvents{}
+}
+
+// WMISpoolerEvents informa los cambios de los documentos del spooler con una consulta de eventos
+// WMI sobre Win32_PrintJob
+type WMISpoolerEvents struct{}
+
+// Subscribe se suscribe a los eventos y los envía hasta que se cierra done
+func (WMISpoolerEvents) Subscribe(events chan<- SpoolerEvent, done <-chan struct{}) error {
+	// COM se inicializa por hilo: la suscripción completa corre en el mismo hilo del sistema
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	if err := windows.CoInitializeEx(0, windows.COINIT_MULTITHREADED); err != nil && err != syscall.Errno(windows.S_FALSE) {
+		return fmt.Errorf("CoInitializeEx falló: %w", err)
+	}
+	defer windows.CoUninitialize()
+	// Falla con RPC_E_TOO_LATE si el proceso ya configuró la seguridad COM; en ese caso se usa esa
+	procCoInitializeSecurity.Call(0, ^uintptr(0), 0, 0, rpcAuthnLevelDefault, rpcImpLevelImpersonate, 0, eoacNone, 0)
+
+	var locator *comObject
+	hr, _, _ := procCoCreateInstance.Call(uintptr(unsafe.Pointer(&clsidWbemLocator)), 0, clsctxInprocServer,
+		uintptr(unsafe.Pointer(&iidIWbemLocator)), uintptr(unsafe.Pointer(&locator)))
+	if int32(hr) < 0 {
+		return fmt.Errorf("no se pudo crear el localizador WMI: 0x%08X", uint32(hr))
+	}
+	defer locator.release()
+
+	namespace := sysAllocString(`ROOT\CIMV2`)
+	defer sysFreeString(namespace)
+	var services *comObject
+	if hr := locator.call(wbemLocatorConnectServer, namespace, 0, 0, 0, 0, 0, 0, uintptr(unsafe.Pointer(&services))); int32(hr) < 0 {
+		return fmt.Errorf("no se pudo conectar a WMI: 0x%08X", uint32(hr))
+	}
+	defer services.release()
+	procCoSetProxyBlanket.Call(uintptr(unsafe.Pointer(services)), rpcAuthnWinNT, rpcAuthzNone, 0,
+		rpcAuthnLevelCall, rpcImpLevelImpersonate, 0, eoacNone)
+
+	language, query := sysAllocString("WQL"), sysAllocString(wmiPrintJobQuery)
+	defer sysFreeString(language)
+	defer sysFreeString(query)
+	var enum *comObject
+	if hr := services.call(wbemServicesExecNotificationQuery, language, query,
+		wbemFlagReturnImmediately|wbemFlagForwardOnly, 0, uintptr(unsafe.Pointer(&enum))); int32(hr) < 0 {
+		return fmt.Errorf("no se pudo suscribir a los eventos de Win32_PrintJob: 0x%08X", uint32(hr))
+	}
+	defer enum.release()
+
+	for {
+		select {
+		case <-done:
+			return nil
+		default:
+		}
+		var object *comObject
+		var returned uint32
+		hr := enum.call(wbemEnumNext, wbemNextTimeoutMs, 1, uintptr(unsafe.Pointer(&object)), uintptr(unsafe.Pointer(&returned)))
+		if int32(hr) < 0 {
+			return fmt.Errorf("error al recibir los eventos de Win32_PrintJob: 0x%08X", uint32(hr))
+		}
+		if hr == wbemTimedOut || returned == 0 {
+			continue
+		}
+		event, ok := printJobEvent(object)
+		object.release()
+		if !ok {
+			continue
+		}
+		select {
+		case events <- event:
+		case <-done:
+			return nil
+		}
+	}
+}
+
+// printJobEvent traduce un evento de creación, modificación o eliminación de Win32_PrintJob
+func printJobEvent(event *comObject) (SpoolerEvent, bool) {
+	target, ok := wmiGet(event, "TargetInstance")
+	if !ok {
+		return SpoolerEvent{}, false
+	}
+	defer procVariantClear.Call(uintptr(unsafe.Pointer(&target)))
+	if target.VT != vtUnknown || target.Val == 0 {
+		return SpoolerEvent{}, false
+	}
+	var instance *comObject
+	unknown := *(**comObject)(unsafe.Pointer(&target.Val))
+	if hr := unknown.call(comQueryInterface, uintptr(unsafe.Pointer(&iidIWbemClassObject)), uintptr(unsafe.Pointer(&instance))); int32(hr) < 0 {
+		return SpoolerEvent{}, false
+	}
+	defer instance.release()
+
+	// Name es "Impresora, id"
+	name := wmiString(instance, "Name")
+	separator := strings.LastIndex(name, ", ")
+	if separator < 0 {
+		return SpoolerEvent{}, false
+	}
+	return SpoolerEvent{
+		Printer:      name[:separator],
+		ID:           uint32(wmiInt(instance, "JobId")),
+		States:       SpoolerJob{Status: uint32(wmiInt(instance, "StatusMask"))}.StatusNames(),
+		Pages:        int(wmiInt(instance, "TotalPages")),
+		PagesPrinted: int(wmiInt(instance, "PagesPrinted")),
+		Removed:      wmiString(event, "__Class") == "__InstanceDeletionEvent",
+	}, true
+}
+
+// wmiGet lee una propiedad del objeto; el llamador debe liberar el valor con VariantClear
+func wmiGet(object *comObject, property string) (variant, bool) {
+	var value variant
+	name, err := windows.UTF16PtrFromString(property)
+	if err != nil {
+		return value, false
+	}
+	hr := object.call(wbemObjectGet, uintptr(unsafe.Pointer(name)), 0, uintptr(unsafe.Pointer(&value)), 0, 0)
+	return value, int32(hr) >= 0
+}
+
+// wmiString lee una propiedad de texto; vacía si no existe o es nula
+func wmiString(object *comObject, property string) string {
+	value, ok := wmiGet(object, property)
+	if !ok {
+		return ""
+	}
+	defer procVariantClear.Call(uintptr(unsafe.Pointer(&value)))
+	if value.VT != vtBSTR || value.Val == 0 {
+		return ""
+	}
+	return windows.UTF16PtrToString(*(**uint16)(unsafe.Pointer(&value.Val)))
+}
+
+// wmiInt lee una propiedad numérica (los uint32 de WMI llegan como VT_I4); 0 si no existe o es nula
+func wmiInt(object *comObject, property string) int64 {
+	value, ok := wmiGet(object, property)
+	if !ok {
+		return 0
+	}
+	defer procVariantClear.Call(uintptr(unsafe.Pointer(&value)))
+	if value.VT != vtI4 {
+		return 0
+	}
+	return int64(uint32(value.Val))
+}
+
+// sysAllocString crea un BSTR; se libera con sysFreeString
+func sysAllocString(s string) uintptr {
+	ptr, err := windows.UTF16PtrFromString(s)
+	if err != nil {
+		return 0
+	}
+	bstr, _, _ := procSysAllocString.Call(uintptr(unsafe.Pointer(ptr)))
+	return bstr
+}
+
+func sysFreeString(bstr uintptr) {
+	if bstr != 0 {
+		procSysFreeString.Call(bstr)
+	}
+}
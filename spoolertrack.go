@@ -0,0 +1,455 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ============================
+// Seguimiento de los Documentos en el Spooler
+// ============================
+
+// ErrSpoolerJobFailed indica que el documento llegó a la cola del sistema, pero el spooler no pudo
+// imprimirlo (error del controlador, impresora fuera de línea, sin papel hasta vencer la espera)
+var ErrSpoolerJobFailed = errors.New("el spooler no pudo imprimir el documento")
+
+// EventJobSpooler informa los cambios de estado de un trabajo en la cola del sistema (imprimiendo,
+// sin papel, error) mientras el trabajo está en estado spooled
+const EventJobSpooler = "job.spooler"
+
+// spoolerErrorStates son los estados de un documento que indican que no se está imprimiendo
+var spoolerErrorStates = []string{"Error", "Offline", "PaperOut", "UserIntervention", "Blocked"}
+
+// SpoolerTrackingConfig configura el seguimiento de los documentos en la cola del sistema
+type SpoolerTrackingConfig struct {
+	Enabled        bool
+	PollSeconds    int
+	TimeoutMinutes int
+}
+
+// LoadSpoolerTrackingConfig carga la configuración del seguimiento desde variables de entorno
+func LoadSpoolerTrackingConfig() SpoolerTrackingConfig {
+	return SpoolerTrackingConfig{
+		Enabled:        getEnvAsBool("SPOOLER_TRACKING", false),
+		PollSeconds:    getEnvAsInt("SPOOLER_TRACKING_POLL_SECONDS", 5),
+		TimeoutMinutes: getEnvAsInt("SPOOLER_TRACKING_TIMEOUT_MINUTES", 30),
+	}
+}
+
+// SpoolerState es el estado del trabajo en la cola del sistema, informado en /jobs/{id}
+type SpoolerState struct {
+	Documents  []SpoolerDocument `json:"documents"`
+	FinishedAt *time.Time        `json:"finished_at,omitempty"`
+}
+
+// clone copia el estado para publicarlo mientras el seguimiento lo sigue actualizando
+func (s *SpoolerState) clone() *SpoolerState {
+	c := *s
+	c.Documents = make([]SpoolerDocument, len(s.Documents))
+	for i, doc := range s.Documents {
+		doc.States, doc.Errors = slices.Clone(doc.States), slices.Clone(doc.Errors)
+		c.Documents[i] = doc
+	}
+	return &c
+}
+
+// SpoolerDocument es un documento del trabajo en la cola de la impresora
+type SpoolerDocument struct {
+	Printer      string   `json:"printer"`
+	ID           uint32   `json:"id"`
+	States       []string `json:"states"`
+	Pages        int      `json:"pages"`
+	PagesPrinted int      `json:"pages_printed"`
+	InQueue      bool     `json:"in_queue"`
+	// Errors son los estados de error que tuvo el documento mientras estuvo en la cola
+	Errors []string `json:"errors,omitempty"`
+}
+
+// SpoolerEvent es un cambio de un documento en la cola del sistema (p. ej. un evento WMI de Win32_PrintJob)
+type SpoolerEvent struct {
+	Printer      string
+	ID           uint32
+	States       []string
+	Pages        int
+	PagesPrinted int
+	// Removed indica que el documento salió de la cola (impreso, eliminado o con error)
+	Removed bool
+}
+
+// SpoolerEventSource informa los cambios de los documentos en la cola del sistema hasta que se
+// cierra done
+type SpoolerEventSource interface {
+	Subscribe(events chan<- SpoolerEvent, done <-chan struct{}) error
+}
+
+// spooledDocument es un documento que una herramienta externa envió a la cola de una impresora
+type spooledDocument struct {
+	Printer  string
+	Document string
+}
+
+// jobSpooledDocuments registra los documentos enviados por cada trabajo en curso
+var jobSpooledDocuments = struct {
+	sync.Mutex
+	documents map[string][]spooledDocument
+}{documents: make(map[string][]spooledDocument)}
+
+// noteSpooledDocument registra que la herramienta envió el documento a la cola de la impresora
+func noteSpooledDocument(run toolRun) {
+	if run.JobID == "" || run.Printer == "" || run.Document == "" {
+		return
+	}
+	jobSpooledDocuments.Lock()
+	defer jobSpooledDocuments.Unlock()
+	jobSpooledDocuments.documents[run.JobID] = append(jobSpooledDocuments.documents[run.JobID],
+		spooledDocument{Printer: run.Printer, Document: run.Document})
+}
+
+// takeSpooledDocuments devuelve y olvida los documentos enviados por el trabajo
+func takeSpooledDocuments(jobID string) []spooledDocument {
+	jobSpooledDocuments.Lock()
+	defer jobSpooledDocuments.Unlock()
+	documents := jobSpooledDocuments.documents[jobID]
+	delete(jobSpooledDocuments.documents, jobID)
+	return documents
+}
+
+// spoolerKey identifica un documento en la cola del sistema
+type spoolerKey struct {
+	Printer string
+	ID      uint32
+}
+
+// trackedJob es un trabajo con documentos pendientes en la cola del sistema
+type trackedJob struct {
+	job   *PrintJob
+	since time.Time
+}
+
+// SpoolerTracker sigue los documentos de los trabajos en la cola del sistema. Con el seguimiento,
+// un trabajo cuya herramienta terminó queda en estado spooled y pasa a completed o failed (con su
+// webhook) recién cuando el spooler lo imprime o falla. En Windows se suscribe a los eventos WMI
+// de Win32_PrintJob; además consulta la cola periódicamente por si se pierde un evento.
+type SpoolerTracker struct {
+	Queues  QueueManager
+	Source  SpoolerEventSource
+	Jobs    *JobRunner
+	Poll    time.Duration
+	Timeout time.Duration
+	Logger  *Logger
+
+	mu      sync.Mutex
+	pending map[string]*trackedJob
+	docs    map[spoolerKey]string
+	done    chan struct{}
+}
+
+// NewSpoolerTracker crea el seguimiento; deshabilitado devuelve nil
+func NewSpoolerTracker(queues QueueManager, cfg SpoolerTrackingConfig, logger *Logger) (*SpoolerTracker, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	if queues == nil {
+		return nil, fmt.Errorf("SPOOLER_TRACKING requiere el backend de impresoras del sistema")
+	}
+	if cfg.PollSeconds <= 0 || cfg.TimeoutMinutes <= 0 {
+		return nil, fmt.Errorf("SPOOLER_TRACKING_POLL_SECONDS y SPOOLER_TRACKING_TIMEOUT_MINUTES deben ser mayores que cero")
+	}
+	return &SpoolerTracker{
+		Queues:  queues,
+		Source:  newSpoolerEventSource(),
+		Poll:    time.Duration(cfg.PollSeconds) * time.Second,
+		Timeout: time.Duration(cfg.TimeoutMinutes) * time.Minute,
+		Logger:  logger,
+		pending: make(map[string]*trackedJob),
+		docs:    make(map[spoolerKey]string),
+		done:    make(chan struct{}),
+	}, nil
+}
+
+// Start inicia la suscripción a los eventos del spooler y la consulta periódica de la cola
+func (t *SpoolerTracker) Start() {
+	if t == nil {
+		return
+	}
+	if t.Source != nil {
+		t.Logger.Infof("Seguimiento de los documentos en el spooler por eventos WMI (revisión cada %s)", t.Poll)
+		go t.subscribe()
+	} else {
+		t.Logger.Infof("Seguimiento de los documentos en la cola de impresión cada %s", t.Poll)
+	}
+	go t.run()
+}
+
+// Close detiene el seguimiento; los trabajos pendientes quedan en estado spooled
+func (t *SpoolerTracker) Close() error {
+	if t != nil {
+		close(t.done)
+	}
+	return nil
+}
+
+// subscribe recibe los eventos del spooler; si la suscripción falla se reintenta al minuto y,
+// mientras tanto, la consulta periódica sigue actualizando los trabajos
+func (t *SpoolerTracker) subscribe() {
+	defer recoverCrash()
+	events := make(chan SpoolerEvent, 64)
+	go func() {
+		defer recoverCrash()
+		for {
+			select {
+			case event := <-events:
+				t.apply(event)
+			case <-t.done:
+				return
+			}
+		}
+	}()
+	for {
+		err := t.Source.Subscribe(events, t.done)
+		select {
+		case <-t.done:
+			return
+		default:
+		}
+		t.Logger.Warnf("Se interrumpió la suscripción a los eventos del spooler: %v", err)
+		select {
+		case <-time.After(time.Minute):
+		case <-t.done:
+			return
+		}
+	}
+}
+
+func (t *SpoolerTracker) run() {
+	defer recoverCrash()
+	ticker := time.NewTicker(t.Poll)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			t.reconcile()
+		case <-t.done:
+			return
+		}
+	}
+}
+
+// Find busca en la cola del sistema los documentos enviados por el trabajo. Si ninguno sigue en la
+// cola, el spooler ya los procesó y el trabajo puede darse por completado.
+func (t *SpoolerTracker) Find(documents []spooledDocument) []SpoolerDocument {
+	if t == nil || len(documents) == 0 {
+		return nil
+	}
+	var found []SpoolerDocument
+	seen := make(map[spoolerKey]bool)
+	queues := make(map[string][]QueueJob)
+	for _, doc := range documents {
+		queue, ok := queues[doc.Printer]
+		if !ok {
+			var err error
+			if queue, err = t.Queues.ListQueue(doc.Printer); err != nil {
+				t.Logger.Warnf("No se pudo consultar la cola de '%s' para seguir el trabajo: %v", doc.Printer, err)
+			}
+			queues[doc.Printer] = queue
+		}
+		name := filepath.Base(doc.Document)
+		for _, q := range queue {
+			key := spoolerKey{doc.Printer, q.ID}
+			if seen[key] || !strings.Contains(q.Document, name) {
+				continue
+			}
+			seen[key] = true
+			found = append(found, SpoolerDocument{
+				Printer: doc.Printer, ID: q.ID, States: q.States, Pages: q.Pages, PagesPrinted: q.PagesPrinted, InQueue: true,
+				Errors: spoolerErrors(nil, q.States),
+			})
+		}
+	}
+	return found
+}
+
+// Track sigue el trabajo, ya registrado como spooled, hasta que sus documentos salen de la cola
+func (t *SpoolerTracker) Track(job *PrintJob) {
+	if t == nil || job.Spooler == nil {
+		return
+	}
+	// Se sigue una copia: el trabajo original pertenece a la solicitud que lo creó
+	tracked := *job
+	tracked.Spooler = job.Spooler.clone()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.pending[job.ID] = &trackedJob{job: &tracked, since: time.Now()}
+	for _, doc := range tracked.Spooler.Documents {
+		t.docs[spoolerKey{doc.Printer, doc.ID}] = job.ID
+	}
+}
+
+// Pending devuelve la cantidad de trabajos en seguimiento
+func (t *SpoolerTracker) Pending() int {
+	if t == nil {
+		return 0
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.pending)
+}
+
+// apply actualiza el documento con el evento recibido y cierra el trabajo si ya no quedan
+// documentos en la cola
+func (t *SpoolerTracker) apply(event SpoolerEvent) {
+	t.mu.Lock()
+	jobID, ok := t.docs[spoolerKey{event.Printer, event.ID}]
+	if !ok {
+		t.mu.Unlock()
+		return
+	}
+	tracked := t.pending[jobID]
+	changed := t.updateLocked(tracked, event)
+	finished, err := t.finishedLocked(tracked)
+	snapshot := *tracked.job
+	snapshot.Spooler = tracked.job.Spooler.clone()
+	t.mu.Unlock()
+
+	if finished {
+		t.Jobs.finishSpooled(tracked.job, err)
+	} else if changed {
+		t.Jobs.publish(EventJobSpooler, &snapshot)
+	}
+}
+
+// updateLocked aplica el evento al documento e indica si cambió su estado
+func (t *SpoolerTracker) updateLocked(tracked *trackedJob, event SpoolerEvent) bool {
+	for i := range tracked.job.Spooler.Documents {
+		doc := &tracked.job.Spooler.Documents[i]
+		if doc.Printer != event.Printer || doc.ID != event.ID {
+			continue
+		}
+		if event.Removed {
+			delete(t.docs, spoolerKey{doc.Printer, doc.ID})
+			doc.InQueue = false
+			return true
+		}
+		changed := !slices.Equal(doc.States, event.States)
+		doc.States, doc.Errors = event.States, spoolerErrors(doc.Errors, event.States)
+		doc.Pages = max(doc.Pages, event.Pages)
+		doc.PagesPrinted = max(doc.PagesPrinted, event.PagesPrinted)
+		return changed
+	}
+	return false
+}
+
+// finishedLocked indica si el trabajo ya no tiene documentos en la cola y, en ese caso, su resultado:
+// un documento que estuvo con error y salió de la cola sin imprimirse hace fallar el trabajo
+func (t *SpoolerTracker) finishedLocked(tracked *trackedJob) (bool, error) {
+	var failed []string
+	for _, doc := range tracked.job.Spooler.Documents {
+		if doc.InQueue {
+			return false, nil
+		}
+		if len(doc.Errors) > 0 && !slices.Contains(doc.States, "Printed") && !slices.Contains(doc.States, "Complete") {
+			failed = append(failed, fmt.Sprintf("documento %d en '%s' (%s)", doc.ID, doc.Printer, strings.Join(doc.Errors, ", ")))
+		}
+	}
+	delete(t.pending, tracked.job.ID)
+	if len(failed) > 0 {
+		return true, fmt.Errorf("%w: %s", ErrSpoolerJobFailed, strings.Join(failed, "; "))
+	}
+	return true, nil
+}
+
+// reconcile consulta la cola de las impresoras con trabajos pendientes: actualiza los documentos,
+// cierra los que salieron de la cola sin que llegara su evento y hace fallar los que superan la
+// espera máxima
+func (t *SpoolerTracker) reconcile() {
+	t.mu.Lock()
+	printers := make(map[string]bool)
+	for key := range t.docs {
+		printers[key.Printer] = true
+	}
+	t.mu.Unlock()
+
+	var events []SpoolerEvent
+	for printer := range printers {
+		queue, err := t.Queues.ListQueue(printer)
+		if err != nil {
+			t.Logger.Warnf("No se pudo consultar la cola de '%s': %v", printer, err)
+			continue
+		}
+		present := make(map[uint32]bool, len(queue))
+		for _, q := range queue {
+			present[q.ID] = true
+			events = append(events, SpoolerEvent{Printer: printer, ID: q.ID, States: q.States, Pages: q.Pages, PagesPrinted: q.PagesPrinted})
+		}
+		t.mu.Lock()
+		for key := range t.docs {
+			if key.Printer == printer && !present[key.ID] {
+				events = append(events, SpoolerEvent{Printer: printer, ID: key.ID, Removed: true})
+			}
+		}
+		t.mu.Unlock()
+	}
+	for _, event := range events {
+		t.apply(event)
+	}
+
+	t.mu.Lock()
+	var expired []*trackedJob
+	for _, tracked := range t.pending {
+		if time.Since(tracked.since) > t.Timeout {
+			expired = append(expired, tracked)
+			delete(t.pending, tracked.job.ID)
+			for _, doc := range tracked.job.Spooler.Documents {
+				delete(t.docs, spoolerKey{doc.Printer, doc.ID})
+			}
+		}
+	}
+	t.mu.Unlock()
+	for _, tracked := range expired {
+		var states []string
+		for _, doc := range tracked.job.Spooler.Documents {
+			if doc.InQueue {
+				states = append(states, doc.States...)
+			}
+		}
+		t.Jobs.finishSpooled(tracked.job, fmt.Errorf("%w: sigue en la cola tras %s (%s)", ErrSpoolerJobFailed, t.Timeout, strings.Join(states, ", ")))
+	}
+}
+
+// spoolerErrors agrega a los errores ya registrados los estados de error actuales
+func spoolerErrors(errs, states []string) []string {
+	for _, state := range states {
+		if slices.Contains(spoolerErrorStates, state) && !slices.Contains(errs, state) {
+			errs = append(errs, state)
+		}
+	}
+	return errs
+}
+
+// finishSpooled cierra el trabajo con el resultado informado por el spooler y lo notifica al
+// historial, al webhook y a los clientes de eventos
+func (j *JobRunner) finishSpooled(job *PrintJob, err error) {
+	now := time.Now()
+	job.Spooler.FinishedAt = &now
+	logger := j.jobLogger(job)
+	if err != nil {
+		job.Status = JobStatusFailed
+		job.Error = err.Error()
+		job.ErrorCode = jobErrorCode(job, err)
+		logger.Errorf("Trabajo %s falló en la cola de '%s': %v", job.ID, job.Printer, err)
+		if j.Metrics != nil {
+			j.Metrics.ObserveSpoolerFailure(job)
+		}
+	} else {
+		job.Status = JobStatusCompleted
+		logger.Infof("Trabajo %s impreso por el spooler en '%s' (%s después de enviarlo)", job.ID, job.Printer, now.Sub(job.FinishedAt).Round(time.Second))
+	}
+	j.Usage.Record(job)
+	j.record(job)
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+// ============================
+// Sello de Texto o Imagen sobre el Documento (p. ej. "COPIA" o "ANULADA")
+// ============================
+
+// maxStampLength limita el texto del sello para que entre en una tirilla
+const maxStampLength = 40
+
+// Posiciones del sello en la página
+const (
+	StampPositionCenter = "center"
+	StampPositionTop    = "top"
+	StampPositionBottom = "bottom"
+)
+
+// stampImageNamePattern limita el nombre de la imagen del sello a un archivo de STAMP_IMAGES_DIR
+var stampImageNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// stampImageExtensions son los formatos de imagen que se buscan en STAMP_IMAGES_DIR, en orden
+var stampImageExtensions = []string{".png", ".jpg", ".jpeg"}
+
+// stampDescriptions define la apariencia del sello de texto según la posición: en el centro va en
+// diagonal y semitransparente sobre el contenido; arriba y abajo, horizontal como una franja
+var stampDescriptions = map[string]string{
+	StampPositionCenter: "fontname:Helvetica-Bold, points:48, rotation:45, opacity:0.35, scalefactor:0.6 rel, fillcolor:#808080",
+	StampPositionTop:    "fontname:Helvetica-Bold, points:24, rotation:0, opacity:0.6, scalefactor:0.4 rel, fillcolor:#808080, position:tc, offset:0 -15",
+	StampPositionBottom: "fontname:Helvetica-Bold, points:24, rotation:0, opacity:0.6, scalefactor:0.4 rel, fillcolor:#808080, position:bc, offset:0 15",
+}
+
+// stampImageDescriptions define la apariencia de la imagen del sello según la posición
+var stampImageDescriptions = map[string]string{
+	StampPositionCenter: "rotation:0, opacity:0.35, scalefactor:0.5 rel",
+	StampPositionTop:    "rotation:0, opacity:0.6, scalefactor:0.25 rel, position:tc, offset:0 -15",
+	StampPositionBottom: "rotation:0, opacity:0.6, scalefactor:0.25 rel, position:bc, offset:0 15",
+}
+
+// StampingDocumentPrinter agrega a todas las páginas el sello de texto (opts.Stamp) y la imagen
+// (opts.StampImage, un archivo de ImagesDir) antes de imprimir
+type StampingDocumentPrinter struct {
+	Next      DocumentPrinter
+	ImagesDir string
+	Logger    *Logger
+}
+
+// PrintFile imprime una copia sellada del documento; sin sello imprime el original. Con CopyLabel
+// y más de una copia, la primera sale con el sello de la solicitud (o sin sello) y las demás con
+// CopyLabel, en dos envíos a la impresora.
+func (s StampingDocumentPrinter) PrintFile(filePath, printer string, opts PrintOptions) error {
+	if opts.CopyLabel != "" && opts.Copies > 1 {
+		original, copies := opts, opts
+		original.Copies, original.CopyLabel = 1, ""
+		copies.Copies, copies.CopyLabel, copies.Stamp = opts.Copies-1, "", opts.CopyLabel
+		if err := s.PrintFile(filePath, printer, original); err != nil {
+			return err
+		}
+		return s.PrintFile(filePath, printer, copies)
+	}
+	if opts.Stamp == "" && opts.StampImage == "" {
+		return s.Next.PrintFile(filePath, printer, opts)
+	}
+	position := opts.StampPosition
+	if position == "" {
+		position = StampPositionCenter
+	}
+
+	if opts.Stamp != "" {
+		stamped, err := StampPDF(filePath, opts.Stamp, position)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(stamped)
+		filePath = stamped
+		s.Logger.Infof("Documento sellado con '%s' para '%s'", opts.Stamp, printer)
+	}
+	if opts.StampImage != "" {
+		image, err := s.stampImage(opts.StampImage)
+		if err != nil {
+			return err
+		}
+		stamped, err := StampPDFImage(filePath, image, position)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(stamped)
+		filePath = stamped
+		s.Logger.Infof("Documento sellado con la imagen '%s' para '%s'", opts.StampImage, printer)
+	}
+	return s.Next.PrintFile(filePath, printer, opts)
+}
+
+// stampImage busca la imagen del sello en ImagesDir
+func (s StampingDocumentPrinter) stampImage(name string) (string, error) {
+	for _, ext := range stampImageExtensions {
+		path := filepath.Join(s.ImagesDir, name+ext)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("no existe la imagen de sello '%s' en %s", name, s.ImagesDir)
+}
+
+// StampPDF escribe en un archivo temporal una copia del PDF con el texto sobre cada página
+func StampPDF(filePath, text, position string) (string, error) {
+	return stampPDF(filePath, func(out string) error {
+		if err := api.AddTextWatermarksFile(filePath, out, nil, true, text, stampDescriptions[position], model.NewDefaultConfiguration()); err != nil {
+			return fmt.Errorf("error al sellar el documento con '%s': %w", text, err)
+		}
+		return nil
+	})
+}
+
+// StampPDFImage escribe en un archivo temporal una copia del PDF con la imagen sobre cada página
+func StampPDFImage(filePath, imagePath, position string) (string, error) {
+	return stampPDF(filePath, func(out string) error {
+		if err := api.AddImageWatermarksFile(filePath, out, nil, true, imagePath, stampImageDescriptions[position], model.NewDefaultConfiguration()); err != nil {
+			return fmt.Errorf("error al sellar el documento con la imagen '%s': %w", filepath.Base(imagePath), err)
+		}
+		return nil
+	})
+}
+
+// stampPDF crea el archivo temporal del documento sellado y lo elimina si el sello falla
+func stampPDF(filePath string, stamp func(out string) error) (string, error) {
+	out, err := os.CreateTemp("", "stamped-*.pdf")
+	if err != nil {
+		return "", fmt.Errorf("error al crear el archivo sellado: %w", err)
+	}
+	out.Close()
+
+	if err := stamp(out.Name()); err != nil {
+		os.Remove(out.Name())
+		return "", err
+	}
+	return out.Name(), nil
+}
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// ============================
+// Impresión de Texto Plano
+// ============================
+
+// Salidas de /print-text
+const (
+	TextOutputESCPOS = "escpos" // texto directo a la impresora térmica
+	TextOutputPDF    = "pdf"    // página A4 en Courier para las impresoras de páginas
+)
+
+// Página A4 del PDF de texto, en puntos
+const (
+	textPageWidth    = 595.0
+	textPageHeight   = 842.0
+	textPageMargin   = 36.0
+	textBaseFontSize = 10.0
+)
+
+// textTabWidth es la cantidad de columnas de cada tabulación
+const textTabWidth = 8
+
+// TextOptions son las opciones de impresión de texto plano
+type TextOptions struct {
+	Size     int    `json:"size,omitempty"`
+	Codepage string `json:"codepage,omitempty"`
+	Wrap     *bool  `json:"wrap,omitempty"`
+	Cut      string `json:"cut,omitempty"`
+	Output   string `json:"output,omitempty"`
+	Copies   int    `json:"copies,omitempty"`
+	JobID    string `json:"-"`
+}
+
+// Normalize valida las opciones y completa los valores predeterminados
+func (o *TextOptions) Normalize() error {
+	o.Codepage, o.Cut, o.Output = strings.ToLower(o.Codepage), strings.ToLower(o.Cut), strings.ToLower(o.Output)
+	if o.Size == 0 {
+		o.Size = 1
+	}
+	if o.Size < 1 || o.Size > 8 {
+		return fmt.Errorf("size inválido: %d (rango 1-8)", o.Size)
+	}
+	if o.Codepage != "" && !escposCodepages[o.Codepage] {
+		return fmt.Errorf("codepage no soportada: %s", o.Codepage)
+	}
+	if o.Wrap == nil {
+		wrap := true
+		o.Wrap = &wrap
+	}
+	switch o.Cut {
+	case "", ReceiptCutFull, ReceiptCutPartial, ReceiptCutNone:
+	default:
+		return fmt.Errorf("cut inválido: %s (use full, partial o none)", o.Cut)
+	}
+	switch o.Output {
+	case "", TextOutputESCPOS, TextOutputPDF, TextOutputGDI:
+	default:
+		return fmt.Errorf("output inválido: %s (use escpos, pdf o gdi)", o.Output)
+	}
+	if o.Copies < 0 || o.Copies > maxCopies {
+		return fmt.Errorf("cantidad de copias inválida: %d (máximo %d)", o.Copies, maxCopies)
+	}
+	return nil
+}
+
+// textLines separa el texto en líneas, expande las tabulaciones y, si wrap está activo, ajusta las
+// líneas que superan columns. Las líneas que entran se conservan tal cual, con sus espacios, para
+// no desalinear los reportes en columnas.
+func textLines(text string, columns int, wrap bool) []string {
+	text = strings.ReplaceAll(strings.ReplaceAll(text, "\r\n", "\n"), "\r", "\n")
+	text = strings.TrimRight(text, "\n")
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		var expanded []rune
+		for _, r := range line {
+			switch {
+			case r == '\t':
+				expanded = append(expanded, []rune(strings.Repeat(" ", textTabWidth-len(expanded)%textTabWidth))...)
+			case r < ' ' || r == 0x7f:
+				// Los caracteres de control podrían interpretarse como comandos de la impresora
+			default:
+				expanded = append(expanded, r)
+			}
+		}
+		line = strings.TrimRight(string(expanded), " ")
+		if !wrap || utf8.RuneCountInString(line) <= columns {
+			lines = append(lines, line)
+			continue
+		}
+		lines = append(lines, wrapText(line, columns)...)
+	}
+	return lines
+}
+
+// RenderTextESCPOS genera los comandos ESC/POS del texto para un rollo de widthMM
+func RenderTextESCPOS(text string, widthMM int, opts TextOptions, cut string) []byte {
+	columns := NewReceiptLayout(widthMM, false).Columns / opts.Size
+	var b ESCPOSBuffer
+	b.Init()
+	b.Codepage(opts.Codepage)
+	if opts.Size > 1 {
+		b.Size(opts.Size, opts.Size)
+	}
+	for _, line := range textLines(text, columns, *opts.Wrap) {
+		b.Line(line)
+	}
+	if opts.Size > 1 {
+		b.Size(1, 1)
+	}
+	b.Feed(3)
+	if cut != ReceiptCutNone {
+		b.Cut(cut == ReceiptCutPartial)
+	}
+	return b.Bytes()
+}
+
+// pdfTextString codifica la línea en Windows-1252 (la codificación WinAnsi de las fuentes estándar
+// del PDF) como cadena literal, reemplazando los caracteres sin equivalente por '?'
+func pdfTextString(line string) string {
+	encoder := charmap.Windows1252.NewEncoder()
+	var sb strings.Builder
+	sb.WriteByte('(')
+	for _, r := range line {
+		encoded, err := encoder.Bytes([]byte(string(r)))
+		if err != nil || len(encoded) == 0 {
+			encoded = []byte{'?'}
+		}
+		for _, c := range encoded {
+			if c == '(' || c == ')' || c == '\\' {
+				sb.WriteByte('\\')
+			}
+			sb.WriteByte(c)
+		}
+	}
+	sb.WriteByte(')')
+	return sb.String()
+}
+
+// RenderTextPDF genera un PDF A4 con el texto en Courier de 10 puntos por size, con tantas páginas
+// como hagan falta
+func RenderTextPDF(text string, opts TextOptions) []byte {
+	fontSize := textBaseFontSize * float64(opts.Size)
+	leading := fontSize * 1.2
+	// Courier es monoespaciada: cada carácter ocupa 0,6 veces el tamaño de la fuente
+	columns := int((textPageWidth - 2*textPageMargin) / (fontSize * 0.6))
+	perPage := int((textPageHeight - 2*textPageMargin) / leading)
+
+	lines := textLines(text, columns, *opts.Wrap)
+	var pages [][]string
+	for len(lines) > perPage {
+		pages = append(pages, lines[:perPage])
+		lines = lines[perPage:]
+	}
+	pages = append(pages, lines)
+
+	// Objetos: 1 catálogo, 2 páginas, 3 fuente y luego un par página/contenido por cada página
+	var objects []string
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 4+2*i)
+	}
+	objects = append(objects,
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>",
+	)
+	for i, page := range pages {
+		var content strings.Builder
+		fmt.Fprintf(&content, "BT /F1 %.1f Tf %.1f TL %.1f %.1f Td\n", fontSize, leading, textPageMargin, textPageHeight-textPageMargin-fontSize)
+		for _, line := range page {
+			fmt.Fprintf(&content, "%s '\n", pdfTextString(line))
+		}
+		content.WriteString("ET")
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>", textPageWidth, textPageHeight, 5+2*i),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", content.Len(), content.String()),
+		)
+	}
+
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return out.Bytes()
+}
+
+// PrintText imprime texto plano: directo en las impresoras térmicas o como PDF en las impresoras de
+// tipo laser (según el perfil, salvo que se indique output). Con GDI_PRINTING las impresoras de tipo
+// laser reciben el texto dibujado con GDI, sin pasar por el motor PDF.
+func (d DefaultPrinterService) PrintText(printerName, text string, opts TextOptions) error {
+	printerName, err := d.resolvePrinter(printerName)
+	if err != nil {
+		return err
+	}
+	profile := d.profile(printerName)
+	if err := profile.requireType(printerName, PrinterTypeThermal, PrinterTypeLaser); err != nil {
+		return err
+	}
+	if opts.Copies == 0 {
+		opts.Copies = profile.Copies
+	}
+	if opts.Codepage == "" {
+		opts.Codepage = profile.Codepage
+	}
+	output := opts.Output
+	if output == "" {
+		output = TextOutputESCPOS
+		if profile.Type == PrinterTypeLaser {
+			output = TextOutputPDF
+			if d.GDIDefault && d.GDI != nil {
+				output = TextOutputGDI
+			}
+		}
+	}
+
+	if output == TextOutputGDI {
+		if d.GDI == nil {
+			return permanent(ErrGDIUnsupported)
+		}
+		if err := d.GDI.DrawText(printerName, text, opts); err != nil {
+			return fmt.Errorf("error al imprimir el texto: %w", err)
+		}
+		return nil
+	}
+
+	if output == TextOutputPDF {
+		out, err := os.CreateTemp("", "text-*.pdf")
+		if err != nil {
+			return fmt.Errorf("error al crear el PDF del texto: %w", err)
+		}
+		_, err = out.Write(RenderTextPDF(text, opts))
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(out.Name())
+			return fmt.Errorf("error al crear el PDF del texto: %w", err)
+		}
+		return d.printTempFile(out.Name(), printerName, PrintOptions{Copies: opts.Copies, JobID: opts.JobID})
+	}
+
+	if d.ReceiptWriter == nil {
+		return fmt.Errorf("la impresión de texto ESC/POS no está disponible")
+	}
+	cut := opts.Cut
+	if cut == "" {
+		cut = profile.Cut
+	}
+	data := RenderTextESCPOS(text, profile.WidthMM, opts, cut)
+	if opts.Copies > 1 {
+		data = bytes.Repeat(data, opts.Copies)
+	}
+	if err := d.ReceiptWriter.WriteRaw(printerName, data); err != nil {
+		return fmt.Errorf("error al imprimir el texto: %w", err)
+	}
+	return nil
+}
+
+// TextRequest es el cuerpo de POST /print-text
+type TextRequest struct {
+	Printer string `json:"printer"`
+	Text    string `json:"text"`
+	TextOptions
+}
+
+// PrintTextHandler imprime texto plano UTF-8 (POST /print-text)
+func (h Handlers) PrintTextHandler(w http.ResponseWriter, r *http.Request) {
+	h.Logger = h.Logger.ForRequest(r)
+	h.Logger.Info("Received request: /print-text")
+
+	if r.Method != http.MethodPost {
+		h.Logger.Warnf("Método HTTP no permitido: %s", r.Method)
+		WriteErrorJSON(w, http.StatusMethodNotAllowed, "Método HTTP no permitido", nil)
+		return
+	}
+
+	var req TextRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, h.MaxUploadBytes)).Decode(&req); err != nil {
+		h.Logger.Warnf("Error al decodificar JSON: %v", err)
+		WriteErrorJSON(w, http.StatusBadRequest, "Solicitud JSON inválida", err)
+		return
+	}
+	if strings.TrimSpace(req.Text) == "" {
+		WriteErrorJSON(w, http.StatusBadRequest, "Falta el texto a imprimir", nil)
+		return
+	}
+	if err := req.TextOptions.Normalize(); err != nil {
+		h.Logger.Warnf("Opciones de texto inválidas: %v", err)
+		WriteErrorJSON(w, http.StatusBadRequest, "Opciones de texto inválidas", err)
+		return
+	}
+	printer, err := h.defaultPrinter(req.Printer)
+	if err != nil {
+		h.Logger.Warnf("No se especificó la impresora: %v", err)
+		WriteErrorJSON(w, http.StatusBadRequest, "No se especificó la impresora", err)
+		return
+	}
+
+	job := NewPrintJob(JobKindText, printer, "text")
+	job.RequestID = RequestID(r)
+	job.ClientCN = ClientCommonName(r)
+	job.SHA256 = documentSHA256(strings.NewReader(req.Text))
+	opts := req.TextOptions
+	opts.JobID = job.ID
+	err = h.Jobs.RunHoldable(job, func() error {
+		if err := h.preflight(job); err != nil {
+			return err
+		}
+		return h.Service.PrintText(printer, req.Text, opts)
+	})
+	if errors.Is(err, ErrJobHeld) {
+		WriteJobHeldJSON(w, job)
+		return
+	}
+	if err != nil {
+		h.Logger.Errorf("Error al imprimir el texto: %v", err)
+		WriteJobErrorJSON(w, jobErrorStatus(err), job, "Error al imprimir el texto", err)
+		return
+	}
+
+	WriteJobJSON(w, job, "Texto enviado a la impresora.")
+}
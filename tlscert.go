@@ -0,0 +1,303 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ============================
+// Certificado TLS Autofirmado
+// ============================
+
+// Archivos que se guardan en TLS_DIR: la CA local (que el navegador debe confiar) y el certificado
+// del servidor firmado por ella
+const (
+	tlsCAFile    = "ca.pem"
+	tlsCAKeyFile = "ca-key.pem"
+	tlsCertFile  = "cert.pem"
+	tlsKeyFile   = "key.pem"
+)
+
+const (
+	// selfSignedCAValidity es la vigencia de la CA local: al conservarla, la confianza instalada en
+	// los navegadores sobrevive a la renovación del certificado del servidor
+	selfSignedCAValidity = 10 * 365 * 24 * time.Hour
+	// selfSignedCertValidity respeta el máximo de 398 días que aceptan los navegadores
+	selfSignedCertValidity = 397 * 24 * time.Hour
+	// selfSignedRenewBefore renueva el certificado del servidor antes de que venza
+	selfSignedRenewBefore = 30 * 24 * time.Hour
+)
+
+// SelfSignedTLS genera y conserva en Dir una CA local y el certificado del servidor, para que un
+// ERP servido por HTTPS pueda llamar al agente sin que el navegador bloquee el contenido mixto
+type SelfSignedTLS struct {
+	Dir string
+}
+
+// CAPath es el certificado de la CA que se importa en los navegadores
+func (s SelfSignedTLS) CAPath() string {
+	return filepath.Join(s.Dir, tlsCAFile)
+}
+
+// CertPath es el certificado del servidor
+func (s SelfSignedTLS) CertPath() string {
+	return filepath.Join(s.Dir, tlsCertFile)
+}
+
+// KeyPath es la clave privada del servidor
+func (s SelfSignedTLS) KeyPath() string {
+	return filepath.Join(s.Dir, tlsKeyFile)
+}
+
+// Ensure crea la CA si no existe y (re)genera el certificado del servidor cuando falta, vence
+// pronto, no lo firmó la CA actual o no cubre alguno de los nombres o IP indicados. Devuelve true
+// si generó un certificado nuevo.
+func (s SelfSignedTLS) Ensure(hosts []string) (bool, error) {
+	if err := os.MkdirAll(s.Dir, 0o700); err != nil {
+		return false, fmt.Errorf("error al crear el directorio de certificados: %w", err)
+	}
+	ca, caKey, err := s.loadOrCreateCA()
+	if err != nil {
+		return false, err
+	}
+	if cert, err := loadCertificate(s.CertPath()); err == nil && certificateCovers(cert, ca, hosts) {
+		if _, err := os.Stat(s.KeyPath()); err == nil {
+			return false, nil
+		}
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return false, err
+	}
+	template, err := certificateTemplate(hostnameOr("PrinterMatiasERP"), selfSignedCertValidity)
+	if err != nil {
+		return false, err
+	}
+	template.KeyUsage = x509.KeyUsageDigitalSignature
+	template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, key.Public(), caKey)
+	if err != nil {
+		return false, fmt.Errorf("error al firmar el certificado del servidor: %w", err)
+	}
+	if err := writeKeyPair(s.CertPath(), s.KeyPath(), der, key); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// loadOrCreateCA carga la CA local o la crea la primera vez
+func (s SelfSignedTLS) loadOrCreateCA() (*x509.Certificate, crypto.Signer, error) {
+	caKeyPath := filepath.Join(s.Dir, tlsCAKeyFile)
+	ca, certErr := loadCertificate(s.CAPath())
+	key, keyErr := loadPrivateKey(caKeyPath)
+	if certErr == nil && keyErr == nil && time.Now().Before(ca.NotAfter) {
+		return ca, key, nil
+	}
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	template, err := certificateTemplate("PrinterMatiasERP CA local "+hostnameOr(""), selfSignedCAValidity)
+	if err != nil {
+		return nil, nil, err
+	}
+	template.IsCA = true
+	template.BasicConstraintsValid = true
+	template.MaxPathLenZero = true
+	template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign
+	der, err := x509.CreateCertificate(rand.Reader, template, template, ecKey.Public(), ecKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error al crear la CA local: %w", err)
+	}
+	if err := writeKeyPair(s.CAPath(), caKeyPath, der, ecKey); err != nil {
+		return nil, nil, err
+	}
+	ca, err = x509.ParseCertificate(der)
+	return ca, ecKey, err
+}
+
+// certificateTemplate arma la base de un certificado con número de serie aleatorio
+func certificateTemplate(commonName string, validity time.Duration) (*x509.Certificate, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	return &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: strings.TrimSpace(commonName), Organization: []string{"PrinterMatiasERP"}},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(validity),
+	}, nil
+}
+
+// certificateCovers indica si el certificado lo firmó la CA, sigue vigente por un tiempo y cubre
+// todos los nombres e IP
+func certificateCovers(cert, ca *x509.Certificate, hosts []string) bool {
+	if cert.CheckSignatureFrom(ca) != nil || time.Until(cert.NotAfter) < selfSignedRenewBefore {
+		return false
+	}
+	for _, host := range hosts {
+		if cert.VerifyHostname(host) != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// selfSignedHosts son los nombres e IP con los que se puede llegar al agente: localhost, el nombre
+// del equipo (también en .local para mDNS), sus IP y los indicados en TLS_SELF_SIGNED_HOSTS
+func selfSignedHosts(cfg Config) []string {
+	hosts := []string{"localhost", "127.0.0.1", "::1"}
+	if name, err := os.Hostname(); err == nil && name != "" {
+		name = strings.ToLower(name)
+		hosts = append(hosts, name)
+		if !strings.Contains(name, ".") {
+			hosts = append(hosts, name+".local")
+		}
+	}
+	if addrs, err := net.InterfaceAddrs(); err == nil {
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && !ipNet.IP.IsLoopback() && !ipNet.IP.IsLinkLocalUnicast() {
+				hosts = append(hosts, ipNet.IP.String())
+			}
+		}
+	}
+	if ip := net.ParseIP(cfg.BindAddress); ip != nil && !ip.IsUnspecified() {
+		hosts = append(hosts, ip.String())
+	}
+	hosts = append(hosts, cfg.TLSSelfSignedHosts...)
+
+	seen := make(map[string]bool, len(hosts))
+	unique := hosts[:0]
+	for _, host := range hosts {
+		if host != "" && !seen[host] {
+			seen[host] = true
+			unique = append(unique, host)
+		}
+	}
+	return unique
+}
+
+// applySelfSignedTLS usa el certificado autofirmado cuando TLS_SELF_SIGNED está activo y no se
+// configuraron TLS_CERT_PATH y TLS_KEY_PATH ni ACME_DOMAINS
+func applySelfSignedTLS(cfg *Config, logger *Logger) error {
+	if !cfg.TLSSelfSigned || cfg.tlsFiles() || cfg.ACME.Enabled() {
+		return nil
+	}
+	tls := SelfSignedTLS{Dir: cfg.TLSDir}
+	hosts := selfSignedHosts(*cfg)
+	generated, err := tls.Ensure(hosts)
+	if err != nil {
+		return err
+	}
+	if generated {
+		logger.Infof("Certificado TLS autofirmado generado para %s; importe %s en los navegadores (o use 'tls trust')",
+			strings.Join(hosts, ", "), tls.CAPath())
+	}
+	cfg.TLSCertPath, cfg.TLSKeyPath = tls.CertPath(), tls.KeyPath()
+	cfg.TLSGenerated = true
+	return nil
+}
+
+func loadCertificate(path string) (*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("%s no contiene un certificado PEM", path)
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+func loadPrivateKey(path string) (crypto.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s no contiene una clave PEM", path)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("tipo de clave no soportado")
+	}
+	return signer, nil
+}
+
+// writeKeyPair guarda el certificado y su clave en PEM; la clave solo la lee el usuario del agente
+func writeKeyPair(certPath, keyPath string, der []byte, key *ecdsa.PrivateKey) error {
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		return fmt.Errorf("error al guardar la clave privada: %w", err)
+	}
+	if err := os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o644); err != nil {
+		return fmt.Errorf("error al guardar el certificado: %w", err)
+	}
+	return nil
+}
+
+// hostnameOr devuelve el nombre del equipo o el valor indicado si no se puede obtener
+func hostnameOr(fallback string) string {
+	if name, err := os.Hostname(); err == nil && name != "" {
+		return name
+	}
+	return fallback
+}
+
+// TLSHandlers publica la CA local para instalarla en los equipos que usan el ERP
+type TLSHandlers struct {
+	CAPath string
+	Logger *Logger
+}
+
+// CAHandler descarga el certificado de la CA local (nunca su clave)
+func (h TLSHandlers) CAHandler(w http.ResponseWriter, r *http.Request) {
+	h.Logger = h.Logger.ForRequest(r)
+	h.Logger.Info("Received request: /tls/ca.pem")
+	if r.Method != http.MethodGet {
+		WriteErrorJSON(w, http.StatusMethodNotAllowed, "Método HTTP no permitido", nil)
+		return
+	}
+	data, err := os.ReadFile(h.CAPath)
+	if err != nil {
+		WriteErrorJSON(w, http.StatusNotFound, "No hay una CA local generada", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-pem-file")
+	w.Header().Set("Content-Disposition", `attachment; filename="PrinterMatiasERP-CA.crt"`)
+	w.Write(data)
+}
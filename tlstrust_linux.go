@@ -0,0 +1,26 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// linuxCAPath es donde update-ca-certificates busca las CA locales
+const linuxCAPath = "/usr/local/share/ca-certificates/printermatias-ca.crt"
+
+// TrustCA agrega la CA local al almacén del sistema (requiere root). Firefox y Chrome con su propio
+// almacén NSS deben importarla desde el navegador.
+func TrustCA(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(linuxCAPath, data, 0o644); err != nil {
+		return fmt.Errorf("error al copiar la CA (¿se ejecuta como root?): %w", err)
+	}
+	if output, err := exec.Command("update-ca-certificates").CombinedOutput(); err != nil {
+		return fmt.Errorf("error al ejecutar update-ca-certificates: %v, salida: %s", err, string(output))
+	}
+	return nil
+}
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"syscall"
+)
+
+// TrustCA instala la CA local en el almacén de raíces de confianza del usuario actual, que usan
+// Chrome y Edge; Windows pide confirmación antes de agregarla
+func TrustCA(path string) error {
+	cmd := exec.Command("certutil", "-user", "-addstore", "Root", path)
+	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("error al ejecutar certutil: %v, salida: %s", err, string(output))
+	}
+	return nil
+}
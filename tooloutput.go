@@ -0,0 +1,84 @@
+package main
+
+import (
+	"strings"
+	"sync"
+)
+
+// ============================
+// Salida de las Herramientas Externas
+// ============================
+
+// Límites de la salida conservada: el historial guarda el final de la salida de cada trabajo y los
+// mensajes de error, un final más corto
+const (
+	maxToolOutputBytes   = 4096
+	toolOutputErrorBytes = 1024
+)
+
+// tailBuffer conserva los últimos bytes escritos por una herramienta externa (stdout y stderr), que
+// en el servicio de Windows no tienen consola donde verse
+type tailBuffer struct {
+	mu   sync.Mutex
+	max  int
+	data []byte
+}
+
+func newTailBuffer(max int) *tailBuffer {
+	return &tailBuffer{max: max}
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.data = append(b.data, p...)
+	if over := len(b.data) - b.max; over > 0 {
+		b.data = append(b.data[:0], b.data[over:]...)
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return string(b.data)
+}
+
+// toolOutputTail devuelve las últimas n posiciones de la salida, sin espacios sobrantes
+func toolOutputTail(output string, n int) string {
+	output = strings.TrimSpace(output)
+	if len(output) > n {
+		output = "..." + output[len(output)-n:]
+	}
+	return output
+}
+
+// jobToolOutputs acumula la salida de las herramientas de cada trabajo en curso hasta que termina
+var jobToolOutputs = struct {
+	sync.Mutex
+	outputs map[string]string
+}{outputs: make(map[string]string)}
+
+// recordToolOutput agrega la salida de una herramienta al trabajo (varias si hubo reintentos)
+func recordToolOutput(jobID, label, output string) {
+	output = strings.TrimSpace(output)
+	if jobID == "" || output == "" {
+		return
+	}
+	jobToolOutputs.Lock()
+	defer jobToolOutputs.Unlock()
+	prev := jobToolOutputs.outputs[jobID]
+	if prev != "" {
+		prev += "\n"
+	}
+	jobToolOutputs.outputs[jobID] = toolOutputTail(prev+"["+label+"]\n"+output, maxToolOutputBytes)
+}
+
+// takeToolOutput devuelve y olvida la salida registrada para el trabajo
+func takeToolOutput(jobID string) string {
+	jobToolOutputs.Lock()
+	defer jobToolOutputs.Unlock()
+	output := jobToolOutputs.outputs[jobID]
+	delete(jobToolOutputs.outputs, jobID)
+	return output
+}
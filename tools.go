@@ -0,0 +1,136 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ============================
+// Verificación de Herramientas Externas
+// ============================
+
+// ToolVerifier comprueba el hash (y opcionalmente la firma Authenticode) de los ejecutables
+// externos antes de lanzarlos, para no ejecutar binarios reemplazados en equipos expuestos.
+type ToolVerifier struct {
+	Hashes           map[string]string // ruta o nombre del ejecutable -> SHA-256 esperado
+	Strict           bool              // rechaza los ejecutables sin hash configurado
+	RequireSignature bool              // exige una firma Authenticode válida
+	Logger           *Logger
+
+	mu       sync.Mutex
+	verified map[string]toolStamp
+}
+
+// toolStamp identifica la versión del archivo ya verificada para no recalcular el hash en cada impresión
+type toolStamp struct {
+	size    int64
+	modTime time.Time
+}
+
+// toolVerifier es el verificador del servidor en ejecución; nil cuando no hay verificación configurada
+var toolVerifier *ToolVerifier
+
+// NewToolVerifier crea el verificador; devuelve nil si no hay nada que verificar
+func NewToolVerifier(hashes map[string]string, strict, requireSignature bool, logger *Logger) (*ToolVerifier, error) {
+	if len(hashes) == 0 && !strict && !requireSignature {
+		return nil, nil
+	}
+	normalized := make(map[string]string, len(hashes))
+	for tool, hash := range hashes {
+		hash = strings.ToLower(strings.TrimSpace(hash))
+		if decoded, err := hex.DecodeString(hash); err != nil || len(decoded) != sha256.Size {
+			return nil, fmt.Errorf("hash SHA-256 inválido para '%s' en TOOL_HASHES", tool)
+		}
+		normalized[toolKey(tool)] = hash
+	}
+	return &ToolVerifier{
+		Hashes:           normalized,
+		Strict:           strict,
+		RequireSignature: requireSignature,
+		Logger:           logger,
+		verified:         make(map[string]toolStamp),
+	}, nil
+}
+
+// toolKey normaliza rutas y nombres para compararlos sin distinguir mayúsculas (como Windows)
+func toolKey(path string) string {
+	return strings.ToLower(filepath.Clean(strings.TrimSpace(path)))
+}
+
+// Verify resuelve la ruta del ejecutable y comprueba que coincida con lo configurado.
+// Devuelve la ruta resuelta, que es la que debe ejecutarse.
+func (v *ToolVerifier) Verify(path string) (string, error) {
+	resolved, err := exec.LookPath(path)
+	if err != nil {
+		return "", fmt.Errorf("no se encontró el ejecutable '%s': %w", path, err)
+	}
+	if abs, err := filepath.Abs(resolved); err == nil {
+		resolved = abs
+	}
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return "", err
+	}
+
+	stamp := toolStamp{size: info.Size(), modTime: info.ModTime()}
+	v.mu.Lock()
+	cached, ok := v.verified[resolved]
+	v.mu.Unlock()
+	if ok && cached == stamp {
+		return resolved, nil
+	}
+
+	expected, ok := v.Hashes[toolKey(path)]
+	if !ok {
+		expected, ok = v.Hashes[toolKey(resolved)]
+	}
+	if !ok {
+		expected, ok = v.Hashes[toolKey(filepath.Base(resolved))]
+	}
+	if !ok && v.Strict {
+		return "", fmt.Errorf("el ejecutable '%s' no tiene hash configurado en TOOL_HASHES", resolved)
+	}
+	if ok {
+		actual, err := FileSHA256(resolved)
+		if err != nil {
+			return "", fmt.Errorf("error al calcular el hash de '%s': %w", resolved, err)
+		}
+		if actual != expected {
+			v.Logger.Errorf("Ejecutable rechazado: '%s' tiene SHA-256 %s (se esperaba %s)", resolved, actual, expected)
+			return "", fmt.Errorf("el ejecutable '%s' no coincide con el hash configurado; posible manipulación", resolved)
+		}
+	}
+	if v.RequireSignature {
+		if err := verifyAuthenticode(resolved); err != nil {
+			v.Logger.Errorf("Ejecutable rechazado: '%s' no tiene una firma válida: %v", resolved, err)
+			return "", fmt.Errorf("el ejecutable '%s' no tiene una firma digital válida: %w", resolved, err)
+		}
+	}
+
+	v.mu.Lock()
+	v.verified[resolved] = stamp
+	v.mu.Unlock()
+	return resolved, nil
+}
+
+// FileSHA256 calcula el hash SHA-256 de un archivo en hexadecimal
+func FileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,8 @@
+package main
+
+import "errors"
+
+// verifyAuthenticode no está disponible fuera de Windows; use TOOL_HASHES para verificar los ejecutables
+func verifyAuthenticode(path string) error {
+	return errors.New("la verificación de firmas Authenticode solo está disponible en Windows; use TOOL_HASHES")
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// ============================
+// Trazas de OpenTelemetry
+// ============================
+
+// tracerName identifica al agente como origen de los spans
+const tracerName = "my-pdf-printer"
+
+// TracingConfig configura el envío de trazas por OTLP/HTTP. Se usan las variables estándar de
+// OpenTelemetry para reutilizar la configuración del backend de trazas existente.
+type TracingConfig struct {
+	// Endpoint es la URL base del colector (se agrega /v1/traces); TracesEndpoint, la URL completa
+	Endpoint       string
+	TracesEndpoint string
+	Headers        map[string]string
+	ServiceName    string
+	// SampleRatio es la fracción de las trazas iniciadas en el agente que se envían; las que llegan
+	// del ERP con traceparent respetan la decisión del ERP
+	SampleRatio float64
+}
+
+// LoadTracingConfig carga la configuración de las trazas
+func LoadTracingConfig() TracingConfig {
+	return TracingConfig{
+		Endpoint:       getEnv("OTEL_EXPORTER_OTLP_ENDPOINT", ""),
+		TracesEndpoint: getEnv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", ""),
+		Headers:        getEnvAsMap("OTEL_EXPORTER_OTLP_HEADERS", ""),
+		ServiceName:    getEnv("OTEL_SERVICE_NAME", "printmatias-agent"),
+		SampleRatio:    getEnvAsFloat("OTEL_TRACES_SAMPLER_ARG", 1),
+	}
+}
+
+// Enabled indica si hay un colector configurado
+func (c TracingConfig) Enabled() bool {
+	return c.Endpoint != "" || c.TracesEndpoint != ""
+}
+
+// tracesURL devuelve la URL a la que se envían las trazas
+func (c TracingConfig) tracesURL() string {
+	if c.TracesEndpoint != "" {
+		return c.TracesEndpoint
+	}
+	return strings.TrimSuffix(c.Endpoint, "/") + "/v1/traces"
+}
+
+func init() {
+	// El ERP envía traceparent: se propaga aunque el agente no exporte trazas
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+}
+
+// agentTracer devuelve el tracer del proveedor vigente (cambia al recargar la configuración)
+func agentTracer() trace.Tracer {
+	return otel.GetTracerProvider().Tracer(tracerName)
+}
+
+// StartTracing configura el proveedor de trazas del proceso y devuelve la función que envía los
+// spans pendientes al detener el servidor. Sin colector configurado los spans no se registran.
+func StartTracing(cfg TracingConfig, outbound OutboundConfig, storeName string, logger *Logger) (func() error, error) {
+	if !cfg.Enabled() {
+		otel.SetTracerProvider(noop.NewTracerProvider())
+		return func() error { return nil }, nil
+	}
+	if cfg.SampleRatio < 0 || cfg.SampleRatio > 1 {
+		return nil, fmt.Errorf("OTEL_TRACES_SAMPLER_ARG debe estar entre 0 y 1: %v", cfg.SampleRatio)
+	}
+	endpoint := cfg.tracesURL()
+	if u, err := url.Parse(endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("OTEL_EXPORTER_OTLP_ENDPOINT inválido: %s", endpoint)
+	}
+
+	// El colector se alcanza con el mismo proxy y certificados que las descargas
+	transport := &http.Transport{}
+	if err := outbound.apply(transport); err != nil {
+		return nil, err
+	}
+	opts := []otlptracehttp.Option{
+		otlptracehttp.WithEndpointURL(endpoint),
+		otlptracehttp.WithHeaders(cfg.Headers),
+		otlptracehttp.WithProxy(transport.Proxy),
+	}
+	if transport.TLSClientConfig != nil {
+		opts = append(opts, otlptracehttp.WithTLSClientConfig(transport.TLSClientConfig))
+	}
+	// El exportador se conecta al enviar el primer lote: un colector caído no impide iniciar el agente
+	exporter, err := otlptracehttp.New(context.Background(), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("error al crear el exportador de trazas: %w", err)
+	}
+
+	res := resource.NewSchemaless(
+		semconv.ServiceName(cfg.ServiceName),
+		semconv.ServiceVersion(agentVersion),
+		semconv.ServiceInstanceID(storeName),
+	)
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		logger.Warnf("Error al enviar las trazas: %v", err)
+	}))
+	logger.Infof("Trazas de OpenTelemetry enviadas a %s (muestreo %.0f%%)", endpoint, cfg.SampleRatio*100)
+
+	return func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		return provider.Shutdown(ctx)
+	}, nil
+}
+
+// requestSpans registra el span de cada solicitud en curso por su X-Request-ID para que los
+// trabajos que inicia queden en la misma traza
+var requestSpans = struct {
+	sync.Mutex
+	spans map[string]trace.SpanContext
+}{spans: make(map[string]trace.SpanContext)}
+
+// TracingMiddleware abre un span por solicitud, continuando la traza del ERP si envía traceparent.
+// El nombre usa el patrón de la ruta ("GET /jobs/{id}") para no crear un nombre por trabajo.
+func TracingMiddleware(next http.Handler, mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		_, route := mux.Handler(r)
+		name := r.Method
+		if route != "" {
+			name += " " + route
+		}
+		ctx, span := agentTracer().Start(ctx, name,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.HTTPRoute(route),
+				semconv.URLPath(r.URL.Path),
+				semconv.ClientAddress(r.RemoteAddr),
+				attribute.String("printmatias.request_id", RequestID(r)),
+			))
+		defer span.End()
+
+		if id := RequestID(r); id != "" && span.SpanContext().IsValid() {
+			requestSpans.Lock()
+			requestSpans.spans[id] = span.SpanContext()
+			requestSpans.Unlock()
+			defer func() {
+				requestSpans.Lock()
+				if requestSpans.spans[id].Equal(span.SpanContext()) {
+					delete(requestSpans.spans, id)
+				}
+				requestSpans.Unlock()
+			}()
+		}
+
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r.WithContext(ctx))
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		span.SetAttributes(semconv.HTTPResponseStatusCode(rec.status))
+		if rec.status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(rec.status))
+		}
+	})
+}
+
+// requestTraceContext devuelve un contexto con el span de la solicitud en curso (vacío si ya
+// terminó, como en los trabajos programados)
+func requestTraceContext(requestID string) context.Context {
+	ctx := context.Background()
+	requestSpans.Lock()
+	defer requestSpans.Unlock()
+	if sc, ok := requestSpans.spans[requestID]; ok {
+		ctx = trace.ContextWithSpanContext(ctx, sc)
+	}
+	return ctx
+}
+
+// jobSpans registra el span de cada trabajo en curso hasta que termina; los trabajos retenidos por
+// falta de papel conservan el span abierto hasta que se imprimen
+var jobSpans = struct {
+	sync.Mutex
+	spans map[string]trace.Span
+}{spans: make(map[string]trace.Span)}
+
+// startJobSpan abre el span del trabajo dentro de la traza de su solicitud
+func startJobSpan(job *PrintJob) {
+	_, span := agentTracer().Start(requestTraceContext(job.RequestID), "job "+job.Kind,
+		trace.WithAttributes(
+			attribute.String("printmatias.job_id", job.ID),
+			attribute.String("printmatias.job_kind", job.Kind),
+			attribute.String("printmatias.printer", job.Printer),
+			attribute.String("printmatias.source", job.Source),
+		))
+	jobSpans.Lock()
+	defer jobSpans.Unlock()
+	jobSpans.spans[job.ID] = span
+}
+
+// endJobSpan cierra el span del trabajo con su resultado
+func endJobSpan(job *PrintJob) {
+	jobSpans.Lock()
+	span, ok := jobSpans.spans[job.ID]
+	delete(jobSpans.spans, job.ID)
+	jobSpans.Unlock()
+	if !ok {
+		return
+	}
+	span.SetAttributes(
+		attribute.String("printmatias.job_status", job.Status),
+		attribute.Int("printmatias.pages", job.Pages),
+		attribute.Int("printmatias.attempts", job.Attempts),
+	)
+	if job.Status == JobStatusFailed {
+		span.SetStatus(codes.Error, job.Error)
+	}
+	span.End()
+}
+
+// jobTraceContext devuelve un contexto con el span del trabajo (vacío si no tiene)
+func jobTraceContext(jobID string) context.Context {
+	ctx := context.Background()
+	jobSpans.Lock()
+	defer jobSpans.Unlock()
+	if span, ok := jobSpans.spans[jobID]; ok {
+		ctx = trace.ContextWithSpan(ctx, span)
+	}
+	return ctx
+}
+
+// startToolSpan abre el span de una herramienta externa dentro del trabajo que la ejecuta
+func startToolSpan(label, path string, run toolRun) trace.Span {
+	_, span := agentTracer().Start(jobTraceContext(run.JobID), "exec "+label,
+		trace.WithAttributes(
+			semconv.ProcessExecutablePath(path),
+			attribute.String("printmatias.job_id", run.JobID),
+			attribute.String("printmatias.printer", run.Printer),
+		))
+	return span
+}
+
+// endSpan cierra el span registrando el error, si lo hubo
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// injectTraceContext agrega traceparent a una solicitud saliente para continuar la traza en el servidor
+func injectTraceContext(ctx context.Context, header http.Header) {
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(header))
+}
+
+// TracedDownloader registra un span por cada descarga del Downloader que envuelve
+type TracedDownloader struct {
+	Next Downloader
+}
+
+// Download descarga el archivo dentro de la traza de la solicitud. La URL se registra sin la
+// consulta, que suele llevar el token de acceso al documento.
+func (d TracedDownloader) Download(ctx context.Context, fileURL string) (string, error) {
+	attrs := []attribute.KeyValue{}
+	if u, err := url.Parse(fileURL); err == nil {
+		attrs = append(attrs, semconv.ServerAddress(u.Hostname()), semconv.URLPath(u.Path))
+	}
+	ctx, span := agentTracer().Start(ctx, "download", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+	path, err := d.Next.Download(ctx, fileURL)
+	endSpan(span, err)
+	return path, err
+}
@@ -0,0 +1,621 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ============================
+// Actualización Automática del Agente
+// ============================
+
+// updatePublicKey es la clave pública Ed25519 (base64) con la que MatiasERP firma las versiones
+// publicadas. Se inyecta al compilar: go build -ldflags "-X main.updatePublicKey=<clave>". Si está
+// vacía, la actualización automática queda deshabilitada (compilaciones de desarrollo).
+var updatePublicKey = ""
+
+// updatedExecutable es la ruta del ejecutable instalado por la actualización. Se guarda porque, después
+// del renombrado, os.Executable devuelve la versión anterior (".old") en Linux y Windows.
+var updatedExecutable string
+
+// errRestartForUpdate indica que el servidor se detuvo para reiniciar el agente con la versión nueva
+var errRestartForUpdate = errors.New("reinicio para aplicar la actualización")
+
+// updateRestartExitCode es el código de salida con el que el servicio (SCM o systemd) reinicia el
+// agente después de reemplazar el ejecutable
+const updateRestartExitCode = 3
+
+// Estados del actualizador
+const (
+	UpdateStateIdle        = "idle"
+	UpdateStateChecking    = "checking"
+	UpdateStateDownloading = "downloading"
+	UpdateStateInstalling  = "installing"
+	UpdateStateRestarting  = "restarting"
+)
+
+// Límites del actualizador
+const (
+	updateManifestMaxBytes = 1 << 20
+	// updateMaxJitter reparte las consultas de los puntos de venta para no saturar el servidor
+	updateMaxJitter = 10 * time.Minute
+	// updateIdleWait es la espera máxima a que terminen los trabajos en curso antes de instalar
+	updateIdleWait      = 2 * time.Minute
+	updateVerifyTimeout = 30 * time.Second
+)
+
+// UpdateConfig configura la búsqueda e instalación de versiones nuevas
+type UpdateConfig struct {
+	// URL es el manifiesto JSON con la última versión publicada
+	URL                string
+	CheckIntervalHours int
+	// AutoInstall instala las versiones nuevas al encontrarlas; si no, solo se informan en
+	// GET /admin/update y se instalan con POST /admin/update/install
+	AutoInstall    bool
+	MaxSizeMB      int
+	TimeoutSeconds int
+}
+
+// LoadUpdateConfig carga la configuración de la actualización automática
+func LoadUpdateConfig() UpdateConfig {
+	return UpdateConfig{
+		URL:                getEnv("UPDATE_URL", ""),
+		CheckIntervalHours: getEnvAsInt("UPDATE_CHECK_INTERVAL_HOURS", 6),
+		AutoInstall:        getEnvAsBool("UPDATE_AUTO_INSTALL", true),
+		MaxSizeMB:          getEnvAsInt("UPDATE_MAX_SIZE_MB", 200),
+		TimeoutSeconds:     getEnvAsInt("UPDATE_TIMEOUT_SECONDS", 600),
+	}
+}
+
+// Enabled indica si hay un manifiesto de versiones configurado
+func (c UpdateConfig) Enabled() bool {
+	return c.URL != ""
+}
+
+// UpdateManifest es el documento publicado en UPDATE_URL:
+//
+//	{"version": "1.5.0", "notes": "...", "assets": {"windows-amd64": {"url": "...", "sha256": "...", "signature": "..."}}}
+type UpdateManifest struct {
+	Version     string                 `json:"version"`
+	Notes       string                 `json:"notes,omitempty"`
+	PublishedAt string                 `json:"published_at,omitempty"`
+	Assets      map[string]UpdateAsset `json:"assets"`
+}
+
+// UpdateAsset es el ejecutable de una plataforma ("windows-amd64", "linux-amd64", ...). Signature
+// es la firma Ed25519 (base64) de updateSignedMessage.
+type UpdateAsset struct {
+	URL       string `json:"url"`
+	SHA256    string `json:"sha256"`
+	Size      int64  `json:"size,omitempty"`
+	Signature string `json:"signature"`
+}
+
+// updateSignedMessage es el texto firmado de cada ejecutable: versión, plataforma y hash. Incluir la
+// versión impide publicar un ejecutable anterior (firmado) como si fuera nuevo.
+func updateSignedMessage(version, platform, sha string) []byte {
+	return []byte(version + "|" + platform + "|" + strings.ToLower(sha))
+}
+
+// updatePlatform identifica el ejecutable de este equipo en el manifiesto
+func updatePlatform() string {
+	return runtime.GOOS + "-" + runtime.GOARCH
+}
+
+// UpdateStatus es el estado que devuelve GET /admin/update
+type UpdateStatus struct {
+	CurrentVersion   string     `json:"current_version"`
+	AvailableVersion string     `json:"available_version,omitempty"`
+	Notes            string     `json:"notes,omitempty"`
+	State            string     `json:"state"`
+	AutoInstall      bool       `json:"auto_install"`
+	LastCheck        *time.Time `json:"last_check,omitempty"`
+	LastError        string     `json:"last_error,omitempty"`
+}
+
+// Updater busca en UPDATE_URL versiones nuevas firmadas, las descarga, verifica la firma, reemplaza
+// el ejecutable y reinicia el agente
+type Updater struct {
+	Config UpdateConfig
+	Logger *Logger
+	// Restart detiene el servidor para iniciar la versión instalada
+	Restart func()
+
+	publicKey ed25519.PublicKey
+	client    *http.Client
+	mu        sync.Mutex
+	status    UpdateStatus
+	done      chan struct{}
+}
+
+// NewUpdater crea el actualizador; nil si no hay UPDATE_URL o la compilación no tiene clave pública
+func NewUpdater(cfg UpdateConfig, outbound OutboundConfig, logger *Logger) (*Updater, error) {
+	if !cfg.Enabled() {
+		return nil, nil
+	}
+	if updatePublicKey == "" {
+		logger.Warn("UPDATE_URL ignorada: esta compilación no tiene clave pública para verificar las actualizaciones")
+		return nil, nil
+	}
+	key, err := base64.StdEncoding.DecodeString(updatePublicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("clave pública de actualizaciones inválida")
+	}
+	if u, err := url.Parse(cfg.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("UPDATE_URL inválida: %s", cfg.URL)
+	}
+	if cfg.CheckIntervalHours < 0 || cfg.MaxSizeMB <= 0 || cfg.TimeoutSeconds <= 0 {
+		return nil, fmt.Errorf("UPDATE_CHECK_INTERVAL_HOURS no puede ser negativo y UPDATE_MAX_SIZE_MB y UPDATE_TIMEOUT_SECONDS deben ser mayores que cero")
+	}
+	transport := &http.Transport{}
+	if err := outbound.apply(transport); err != nil {
+		return nil, err
+	}
+	return &Updater{
+		Config:    cfg,
+		Logger:    logger,
+		publicKey: key,
+		client:    &http.Client{Transport: transport, Timeout: time.Duration(cfg.TimeoutSeconds) * time.Second},
+		status:    UpdateStatus{CurrentVersion: agentVersion, State: UpdateStateIdle, AutoInstall: cfg.AutoInstall},
+		done:      make(chan struct{}),
+	}, nil
+}
+
+// Start inicia la consulta periódica del manifiesto; la primera se demora al azar para repartir
+// las consultas de todos los puntos de venta
+func (u *Updater) Start() {
+	if u == nil || u.Config.CheckIntervalHours == 0 {
+		return
+	}
+	interval := time.Duration(u.Config.CheckIntervalHours) * time.Hour
+	u.Logger.Infof("Actualizaciones desde %s cada %s (instalación automática: %t)", u.Config.URL, interval, u.Config.AutoInstall)
+	go func() {
+		defer recoverCrash()
+		delay := time.Duration(rand.Int63n(int64(min(interval, updateMaxJitter))))
+		for {
+			select {
+			case <-time.After(delay):
+			case <-u.done:
+				return
+			}
+			delay = interval
+			if u.Config.AutoInstall {
+				if err := u.Install(context.Background()); err != nil && !errors.Is(err, errNoUpdate) {
+					u.Logger.Errorf("Error al actualizar el agente: %v", err)
+				}
+				continue
+			}
+			manifest, err := u.Check(context.Background())
+			switch {
+			case err == nil:
+				u.Logger.Infof("Versión %s del agente disponible (instalada: %s); instálela con POST /admin/update/install", manifest.Version, agentVersion)
+			case !errors.Is(err, errNoUpdate):
+				u.Logger.Errorf("Error al buscar actualizaciones: %v", err)
+			}
+		}
+	}()
+}
+
+// Close detiene la consulta periódica
+func (u *Updater) Close() error {
+	if u != nil {
+		close(u.done)
+	}
+	return nil
+}
+
+// Status devuelve el estado actual del actualizador
+func (u *Updater) Status() UpdateStatus {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.status
+}
+
+// errNoUpdate indica que no hay una versión más nueva que la instalada
+var errNoUpdate = errors.New("no hay una versión nueva")
+
+// errUpdateInProgress indica que ya se está buscando o instalando una actualización
+var errUpdateInProgress = errors.New("ya hay una actualización en curso")
+
+// begin pasa al estado indicado si el actualizador está libre
+func (u *Updater) begin(state string) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.status.State != UpdateStateIdle {
+		return errUpdateInProgress
+	}
+	u.status.State = state
+	return nil
+}
+
+// setState cambia el estado durante la instalación
+func (u *Updater) setState(state string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.status.State = state
+}
+
+// end vuelve al estado inicial registrando el error, si lo hubo
+func (u *Updater) end(err error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.status.State = UpdateStateIdle
+	u.status.LastError = ""
+	if err != nil && !errors.Is(err, errNoUpdate) {
+		u.status.LastError = err.Error()
+	}
+}
+
+// Check consulta el manifiesto y devuelve la versión disponible; errNoUpdate si la instalada es la última
+func (u *Updater) Check(ctx context.Context) (*UpdateManifest, error) {
+	if err := u.begin(UpdateStateChecking); err != nil {
+		return nil, err
+	}
+	manifest, err := u.check(ctx)
+	u.end(err)
+	return manifest, err
+}
+
+func (u *Updater) check(ctx context.Context) (*UpdateManifest, error) {
+	manifest, err := u.fetchManifest(ctx)
+	u.mu.Lock()
+	now := time.Now()
+	u.status.LastCheck = &now
+	u.status.AvailableVersion, u.status.Notes = "", ""
+	u.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	newer, err := compareVersions(manifest.Version, agentVersion)
+	if err != nil {
+		return nil, err
+	}
+	if newer <= 0 {
+		return nil, errNoUpdate
+	}
+	if _, ok := manifest.Assets[updatePlatform()]; !ok {
+		return nil, fmt.Errorf("la versión %s no incluye un ejecutable para %s", manifest.Version, updatePlatform())
+	}
+	u.mu.Lock()
+	u.status.AvailableVersion, u.status.Notes = manifest.Version, manifest.Notes
+	u.mu.Unlock()
+	return manifest, nil
+}
+
+// fetchManifest descarga y valida el manifiesto de UPDATE_URL
+func (u *Updater) fetchManifest(ctx context.Context) (*UpdateManifest, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.Config.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "PrinterMatiasERP/"+agentVersion+" ("+updatePlatform()+")")
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error al consultar las actualizaciones: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("el servidor de actualizaciones respondió %s", resp.Status)
+	}
+	var manifest UpdateManifest
+	if err := json.NewDecoder(io.LimitReader(resp.Body, updateManifestMaxBytes)).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("manifiesto de actualizaciones inválido: %w", err)
+	}
+	if manifest.Version == "" || len(manifest.Assets) == 0 {
+		return nil, fmt.Errorf("manifiesto de actualizaciones inválido: falta version o assets")
+	}
+	return &manifest, nil
+}
+
+// Install busca una versión nueva y, si la hay, la descarga, verifica la firma, reemplaza el
+// ejecutable y reinicia el agente. Espera a que terminen los trabajos en curso.
+func (u *Updater) Install(ctx context.Context) error {
+	if err := u.begin(UpdateStateChecking); err != nil {
+		return err
+	}
+	return u.installAndRestart(ctx)
+}
+
+// installAndRestart completa la instalación ya iniciada con begin
+func (u *Updater) installAndRestart(ctx context.Context) error {
+	if err := u.install(ctx); err != nil {
+		u.end(err)
+		return err
+	}
+	u.setState(UpdateStateRestarting)
+	u.Restart()
+	return nil
+}
+
+func (u *Updater) install(ctx context.Context) error {
+	manifest, err := u.check(ctx)
+	if err != nil {
+		return err
+	}
+	platform := updatePlatform()
+	asset := manifest.Assets[platform]
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("no se pudo determinar la ruta del ejecutable: %w", err)
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return fmt.Errorf("no se pudo determinar la ruta del ejecutable: %w", err)
+	}
+
+	u.setState(UpdateStateDownloading)
+	u.Logger.Infof("Descargando la versión %s del agente (instalada: %s)", manifest.Version, agentVersion)
+	// La descarga se guarda junto al ejecutable para poder reemplazarlo con un renombrado
+	download := exe + ".download"
+	defer os.Remove(download)
+	if err := u.download(ctx, asset, download); err != nil {
+		return err
+	}
+	if err := u.verify(manifest.Version, platform, asset, download); err != nil {
+		return err
+	}
+
+	u.setState(UpdateStateInstalling)
+	if err := verifyUpdateBinary(ctx, download, manifest.Version); err != nil {
+		return err
+	}
+	if !waitJobsIdle(ctx, updateIdleWait) {
+		return fmt.Errorf("hay trabajos en curso; la actualización se reintentará más tarde")
+	}
+	if err := swapExecutable(exe, download); err != nil {
+		return err
+	}
+	updatedExecutable = exe
+	u.Logger.Infof("Agente actualizado a la versión %s; reiniciando (la versión anterior queda en %s)", manifest.Version, exe+".old")
+	return nil
+}
+
+// download guarda el ejecutable en path sin superar UPDATE_MAX_SIZE_MB
+func (u *Updater) download(ctx context.Context, asset UpdateAsset, path string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, asset.URL, nil)
+	if err != nil {
+		return fmt.Errorf("URL de descarga inválida: %w", err)
+	}
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error al descargar la actualización: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("el servidor de actualizaciones respondió %s al descargar el ejecutable", resp.Status)
+	}
+	maxBytes := int64(u.Config.MaxSizeMB) << 20
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o755)
+	if err != nil {
+		return fmt.Errorf("error al guardar la actualización: %w", err)
+	}
+	n, err := io.Copy(f, io.LimitReader(resp.Body, maxBytes+1))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("error al descargar la actualización: %w", err)
+	}
+	if n > maxBytes {
+		return fmt.Errorf("la actualización supera UPDATE_MAX_SIZE_MB (%d MB)", u.Config.MaxSizeMB)
+	}
+	return nil
+}
+
+// verify comprueba el hash del archivo descargado y la firma de la versión
+func (u *Updater) verify(version, platform string, asset UpdateAsset, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+	if !strings.EqualFold(sum, asset.SHA256) || (asset.Size > 0 && size != asset.Size) {
+		return fmt.Errorf("el ejecutable descargado no coincide con el publicado (sha256 %s)", sum)
+	}
+	sig, err := base64.StdEncoding.DecodeString(asset.Signature)
+	if err != nil || !ed25519.Verify(u.publicKey, updateSignedMessage(version, platform, sum), sig) {
+		return fmt.Errorf("la firma de la versión %s no es válida", version)
+	}
+	return nil
+}
+
+// verifyUpdateBinary ejecuta "<ejecutable> version" para comprobar que la versión nueva inicia en
+// este equipo e informa la versión esperada antes de reemplazar la instalada
+func verifyUpdateBinary(ctx context.Context, path, version string) error {
+	ctx, cancel := context.WithTimeout(ctx, updateVerifyTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, path, "version")
+	hideWindow(cmd)
+	output, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("la versión descargada no se pudo ejecutar: %w", err)
+	}
+	if got := strings.TrimSpace(string(output)); got != version {
+		return fmt.Errorf("la versión descargada informa %q en lugar de %q", got, version)
+	}
+	return nil
+}
+
+// waitJobsIdle espera hasta maxWait a que no haya trabajos en curso
+func waitJobsIdle(ctx context.Context, maxWait time.Duration) bool {
+	deadline := time.Now().Add(maxWait)
+	for {
+		busy := false
+		runningJobs.Range(func(_, _ interface{}) bool {
+			busy = true
+			return false
+		})
+		if !busy {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		select {
+		case <-time.After(time.Second):
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+// swapExecutable reemplaza el ejecutable con la versión descargada. El ejecutable en uso se
+// renombra a ".old" (Windows no permite sobrescribirlo pero sí renombrarlo) y se conserva para
+// volver atrás manualmente.
+func swapExecutable(exe, download string) error {
+	old := exe + ".old"
+	if err := os.Remove(old); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("no se pudo eliminar la versión anterior %s: %w", old, err)
+	}
+	if err := os.Rename(exe, old); err != nil {
+		return fmt.Errorf("no se pudo reemplazar el ejecutable (¿permisos?): %w", err)
+	}
+	if err := os.Rename(download, exe); err != nil {
+		// Se restaura la versión en uso para no dejar el servicio sin ejecutable
+		if rerr := os.Rename(old, exe); rerr != nil {
+			return fmt.Errorf("no se pudo instalar la versión nueva (%v) ni restaurar la anterior: %w", err, rerr)
+		}
+		return fmt.Errorf("no se pudo instalar la versión nueva: %w", err)
+	}
+	return nil
+}
+
+// restartAfterUpdate inicia la versión instalada y devuelve el código de salida del proceso actual.
+// Bajo el Service Control Manager o systemd se sale con updateRestartExitCode para que el servicio
+// reinicie el agente; en una consola se inicia el nuevo ejecutable con los mismos argumentos.
+func restartAfterUpdate(logger *Logger) int {
+	if underServiceManager() {
+		return updateRestartExitCode
+	}
+	var args []string
+	if configFlagPath != "" {
+		args = append(args, "--config", configFlagPath)
+	}
+	cmd := exec.Command(updatedExecutable, append(args, os.Args[1:]...)...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Start(); err != nil {
+		logger.Errorf("No se pudo iniciar la versión nueva: %v", err)
+		return updateRestartExitCode
+	}
+	return 0
+}
+
+// compareVersions compara dos versiones "1.4.0" (con o sin "v"): negativo si a es anterior a b,
+// cero si son iguales y positivo si es posterior
+func compareVersions(a, b string) (int, error) {
+	pa, err := parseVersion(a)
+	if err != nil {
+		return 0, err
+	}
+	pb, err := parseVersion(b)
+	if err != nil {
+		return 0, err
+	}
+	for i := 0; i < max(len(pa), len(pb)); i++ {
+		var x, y int
+		if i < len(pa) {
+			x = pa[i]
+		}
+		if i < len(pb) {
+			y = pb[i]
+		}
+		if x != y {
+			return x - y, nil
+		}
+	}
+	return 0, nil
+}
+
+func parseVersion(v string) ([]int, error) {
+	parts := strings.Split(strings.TrimPrefix(strings.TrimSpace(v), "v"), ".")
+	numbers := make([]int, len(parts))
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("versión inválida: %q (use el formato 1.4.0)", v)
+		}
+		numbers[i] = n
+	}
+	return numbers, nil
+}
+
+// StatusHandler devuelve la versión instalada, la disponible y el estado de la actualización
+// (GET /admin/update)
+func (u *Updater) StatusHandler(w http.ResponseWriter, r *http.Request) {
+	logger := u.Logger.ForRequest(r)
+	logger.Info("Received request: /admin/update")
+	if r.Method != http.MethodGet {
+		WriteErrorJSON(w, http.StatusMethodNotAllowed, "Método HTTP no permitido", nil)
+		return
+	}
+	WriteJSON(w, http.StatusOK, u.Status())
+}
+
+// CheckHandler consulta el manifiesto en el momento (POST /admin/update/check)
+func (u *Updater) CheckHandler(w http.ResponseWriter, r *http.Request) {
+	logger := u.Logger.ForRequest(r)
+	logger.Info("Received request: /admin/update/check")
+	if r.Method != http.MethodPost {
+		WriteErrorJSON(w, http.StatusMethodNotAllowed, "Método HTTP no permitido", nil)
+		return
+	}
+	if _, err := u.Check(r.Context()); err != nil && !errors.Is(err, errNoUpdate) {
+		status := http.StatusBadGateway
+		if errors.Is(err, errUpdateInProgress) {
+			status = http.StatusConflict
+		}
+		WriteErrorJSON(w, status, "Error al buscar actualizaciones", err)
+		return
+	}
+	WriteJSON(w, http.StatusOK, u.Status())
+}
+
+// InstallHandler instala la versión disponible aunque UPDATE_AUTO_INSTALL sea false
+// (POST /admin/update/install). La instalación continúa en segundo plano: el progreso se consulta
+// con GET /admin/update y el agente se reinicia al terminar.
+func (u *Updater) InstallHandler(w http.ResponseWriter, r *http.Request) {
+	logger := u.Logger.ForRequest(r)
+	logger.Info("Received request: /admin/update/install")
+	if r.Method != http.MethodPost {
+		WriteErrorJSON(w, http.StatusMethodNotAllowed, "Método HTTP no permitido", nil)
+		return
+	}
+	if err := u.begin(UpdateStateChecking); err != nil {
+		WriteErrorJSON(w, http.StatusConflict, "Error al actualizar el agente", err)
+		return
+	}
+	go func() {
+		defer recoverCrash()
+		if err := u.installAndRestart(context.Background()); err != nil {
+			if errors.Is(err, errNoUpdate) {
+				logger.Infof("El agente ya tiene la última versión (%s)", agentVersion)
+				return
+			}
+			logger.Errorf("Error al actualizar el agente: %v", err)
+		}
+	}()
+	WriteJSON(w, http.StatusAccepted, u.Status())
+}
@@ -0,0 +1,9 @@
+package main
+
+import "os"
+
+// underServiceManager indica si systemd ejecuta el agente (define INVOCATION_ID en los servicios);
+// con Restart=on-failure, systemd inicia la versión nueva al salir con updateRestartExitCode
+func underServiceManager() bool {
+	return os.Getenv("INVOCATION_ID") != ""
+}
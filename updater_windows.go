@@ -0,0 +1,8 @@
+package main
+
+// underServiceManager indica si el agente se ejecuta como servicio de Windows. El servicio no pasa
+// por main: agentService.Execute termina con updateRestartExitCode y las acciones de recuperación
+// configuradas por 'service install' lo reinician con la versión nueva.
+func underServiceManager() bool {
+	return isWindowsService()
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	bolt "go.etcd.io/bbolt"
+)
+
+// ============================
+// Contabilidad de Uso por Impresora
+// ============================
+
+// bucketUsage guarda los totales diarios: "AAAA-MM-DD|impresora" -> UsageCounters (JSON). Se separan
+// del historial para que los reportes mensuales sobrevivan a HISTORY_RETENTION_DAYS.
+var bucketUsage = []byte("usage")
+
+// usageDateLayout es el formato de los días del reporte (hora local del agente)
+const usageDateLayout = "2006-01-02"
+
+// Agrupaciones de GET /reports/usage
+const (
+	UsageGroupDay   = "day"
+	UsageGroupMonth = "month"
+)
+
+// UsageCounters son los trabajos y páginas de una impresora en un período
+type UsageCounters struct {
+	// Jobs son los trabajos impresos y Pages sus páginas (por copia); Failed, los que fallaron
+	Jobs   int `json:"jobs"`
+	Pages  int `json:"pages"`
+	Failed int `json:"failed"`
+}
+
+func (c *UsageCounters) add(o UsageCounters) {
+	c.Jobs += o.Jobs
+	c.Pages += o.Pages
+	c.Failed += o.Failed
+}
+
+// UsageRow es una fila del reporte: una impresora en un día o mes
+type UsageRow struct {
+	Period  string `json:"period"`
+	Printer string `json:"printer"`
+	UsageCounters
+}
+
+// UsageReport es la respuesta de GET /reports/usage
+type UsageReport struct {
+	From      string                   `json:"from"`
+	To        string                   `json:"to"`
+	Group     string                   `json:"group"`
+	Rows      []UsageRow               `json:"rows"`
+	ByPrinter map[string]UsageCounters `json:"by_printer"`
+	Total     UsageCounters            `json:"total"`
+}
+
+// jobPages registra las páginas enviadas por cada trabajo en curso hasta que termina
+var jobPages = struct {
+	sync.Mutex
+	pages map[string]int
+}{pages: make(map[string]int)}
+
+// noteJobPages registra las páginas que imprimió el trabajo; un reintento reemplaza el valor anterior
+func noteJobPages(jobID string, pages int) {
+	if jobID == "" || pages <= 0 {
+		return
+	}
+	jobPages.Lock()
+	defer jobPages.Unlock()
+	jobPages.pages[jobID] = pages
+}
+
+// takeJobPages devuelve y olvida las páginas registradas para el trabajo
+func takeJobPages(jobID string) int {
+	jobPages.Lock()
+	defer jobPages.Unlock()
+	pages := jobPages.pages[jobID]
+	delete(jobPages.pages, jobID)
+	return pages
+}
+
+// countPrintedPages calcula las páginas que imprime el PDF con el rango y las copias de la solicitud
+func countPrintedPages(filePath string, opts PrintOptions) int {
+	count, err := api.PageCountFile(filePath)
+	if err != nil {
+		return 0
+	}
+	return len(opts.PageIndexes(count)) * max(opts.Copies, 1)
+}
+
+// UsageStore acumula los trabajos y páginas por impresora y por día en la base de datos del historial
+type UsageStore struct {
+	db     *bolt.DB
+	Logger *Logger
+}
+
+// NewUsageStore prepara los totales de uso sobre la base de datos del historial
+func NewUsageStore(history *BoltJobHistory, logger *Logger) (*UsageStore, error) {
+	err := history.db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(bucketUsage)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &UsageStore{db: history.db, Logger: logger}, nil
+}
+
+// Record suma el trabajo terminado al día en que empezó. Los trabajos de cajón y los comandos no
+// imprimen y no se cuentan; los que quedan en la cola del spooler se cuentan cuando el spooler los
+// termina (finishSpooled).
+func (u *UsageStore) Record(job *PrintJob) {
+	if u == nil || job.Printer == "" || job.Kind == JobKindDrawer || job.Kind == JobKindCommand {
+		return
+	}
+	var delta UsageCounters
+	switch job.Status {
+	case JobStatusCompleted:
+		delta = UsageCounters{Jobs: 1, Pages: job.Pages}
+	case JobStatusFailed:
+		delta = UsageCounters{Failed: 1}
+	default:
+		return
+	}
+	key := []byte(job.StartedAt.Local().Format(usageDateLayout) + "|" + job.Printer)
+	err := u.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketUsage)
+		var counters UsageCounters
+		if data := b.Get(key); data != nil {
+			if err := json.Unmarshal(data, &counters); err != nil {
+				return err
+			}
+		}
+		counters.add(delta)
+		data, err := json.Marshal(counters)
+		if err != nil {
+			return err
+		}
+		return b.Put(key, data)
+	})
+	if err != nil {
+		u.Logger.Errorf("Error al registrar el uso del trabajo %s: %v", job.ID, err)
+	}
+}
+
+// Report suma el uso de los días entre from y to (inclusive), por día o por mes; printer filtra
+// una impresora
+func (u *UsageStore) Report(from, to time.Time, group, printer string) (UsageReport, error) {
+	report := UsageReport{
+		From:      from.Format(usageDateLayout),
+		To:        to.Format(usageDateLayout),
+		Group:     group,
+		Rows:      []UsageRow{},
+		ByPrinter: map[string]UsageCounters{},
+	}
+	rows := map[[2]string]*UsageRow{}
+	err := u.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucketUsage).Cursor()
+		// Las claves empiezan por la fecha, por lo que quedan ordenadas cronológicamente
+		for k, v := c.Seek([]byte(report.From)); k != nil && string(k[:len(usageDateLayout)]) <= report.To; k, v = c.Next() {
+			day, name, _ := strings.Cut(string(k), "|")
+			if printer != "" && name != printer {
+				continue
+			}
+			var counters UsageCounters
+			if err := json.Unmarshal(v, &counters); err != nil {
+				return err
+			}
+			period := day
+			if group == UsageGroupMonth {
+				period = day[:7]
+			}
+			row, ok := rows[[2]string{period, name}]
+			if !ok {
+				row = &UsageRow{Period: period, Printer: name}
+				rows[[2]string{period, name}] = row
+			}
+			row.add(counters)
+			byPrinter := report.ByPrinter[name]
+			byPrinter.add(counters)
+			report.ByPrinter[name] = byPrinter
+			report.Total.add(counters)
+		}
+		return nil
+	})
+	for _, row := range rows {
+		report.Rows = append(report.Rows, *row)
+	}
+	sort.Slice(report.Rows, func(i, j int) bool {
+		a, b := report.Rows[i], report.Rows[j]
+		return a.Period < b.Period || (a.Period == b.Period && a.Printer < b.Printer)
+	})
+	return report, err
+}
+
+// parseUsageRange obtiene el período de from y to (AAAA-MM-DD); por defecto, el mes en curso
+func parseUsageRange(r *http.Request) (time.Time, time.Time, error) {
+	now := time.Now()
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local)
+	to := now
+	for name, dst := range map[string]*time.Time{"from": &from, "to": &to} {
+		if v := r.URL.Query().Get(name); v != "" {
+			t, err := time.ParseInLocation(usageDateLayout, v, time.Local)
+			if err != nil {
+				return from, to, fmt.Errorf("fecha inválida en '%s' (use AAAA-MM-DD): %s", name, v)
+			}
+			*dst = t
+		}
+	}
+	if to.Before(from) {
+		return from, to, fmt.Errorf("'to' (%s) es anterior a 'from' (%s)", to.Format(usageDateLayout), from.Format(usageDateLayout))
+	}
+	return from, to, nil
+}
+
+// ReportHandler devuelve el uso por impresora (GET /reports/usage?from=&to=) en JSON o, con
+// format=csv o Accept: text/csv, como planilla
+func (u *UsageStore) ReportHandler(w http.ResponseWriter, r *http.Request) {
+	logger := u.Logger.ForRequest(r)
+	logger.Info("Received request: /reports/usage")
+
+	if r.Method != http.MethodGet {
+		WriteErrorJSON(w, http.StatusMethodNotAllowed, "Método HTTP no permitido", nil)
+		return
+	}
+	from, to, err := parseUsageRange(r)
+	if err != nil {
+		WriteErrorJSON(w, http.StatusBadRequest, "Período inválido", err)
+		return
+	}
+	q := r.URL.Query()
+	group := strings.ToLower(q.Get("group"))
+	switch group {
+	case "":
+		group = UsageGroupDay
+	case UsageGroupDay, UsageGroupMonth:
+	default:
+		WriteErrorJSON(w, http.StatusBadRequest, "Agrupación inválida", fmt.Errorf("group debe ser day o month: %s", group))
+		return
+	}
+	report, err := u.Report(from, to, group, q.Get("printer"))
+	if err != nil {
+		WriteErrorJSON(w, http.StatusInternalServerError, "Error al consultar el uso", err)
+		return
+	}
+
+	if q.Get("format") != "csv" && !strings.Contains(r.Header.Get("Accept"), "text/csv") {
+		WriteJSON(w, http.StatusOK, report)
+		return
+	}
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="uso-%s-%s.csv"`, report.From, report.To))
+	out := csv.NewWriter(w)
+	out.Write([]string{"period", "printer", "jobs", "pages", "failed"})
+	for _, row := range report.Rows {
+		out.Write([]string{row.Period, row.Printer, strconv.Itoa(row.Jobs), strconv.Itoa(row.Pages), strconv.Itoa(row.Failed)})
+	}
+	out.Flush()
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+)
+
+// ============================
+// Validación Previa de Impresión
+// ============================
+
+// PrintValidation es el resultado de verificar una impresión sin realizarla. Si alguna verificación
+// falla, Valid es false y ErrorCode/Error indican la primera que no se cumplió.
+type PrintValidation struct {
+	Valid     bool   `json:"valid"`
+	ErrorCode string `json:"error_code,omitempty"`
+	Error     string `json:"error,omitempty"`
+	Printer   string `json:"printer"`
+	Group     string `json:"group,omitempty"`
+	SizeBytes int64  `json:"size_bytes,omitempty"`
+	*PDFInspection
+	Warnings []string `json:"warnings"`
+}
+
+// ValidateDocument verifica que la impresora exista y obtiene el documento para hacer las mismas
+// comprobaciones que antes de imprimirlo (cabecera PDF, tamaño máximo y estructura), sin enviarlo
+// a la impresora. En las impresoras térmicas advierte además del largo de papel que consumiría.
+func (d DefaultPrinterService) ValidateDocument(ctx context.Context, fileURL, data, printerName string) (*PrintValidation, error) {
+	v := &PrintValidation{Printer: printerName, Warnings: []string{}}
+	name, err := d.resolvePrinter(printerName)
+	if err != nil {
+		return v, err
+	}
+
+	filePath, err := d.FetchDocument(ctx, fileURL, data)
+	if err != nil {
+		return v, err
+	}
+	defer func() {
+		if err := os.Remove(filePath); err != nil {
+			d.Logger.Errorf("Error al eliminar archivo temporal: %v", err)
+		}
+	}()
+
+	if err := CheckPDFFile(filePath, d.MaxDocumentBytes); err != nil {
+		return v, err
+	}
+	if stat, err := os.Stat(filePath); err == nil {
+		v.SizeBytes = stat.Size()
+	}
+	info, err := InspectPDF(filePath)
+	if err != nil {
+		return v, err
+	}
+	v.PDFInspection = info
+
+	profile := d.profile(name)
+	if profile.Type == PrinterTypeThermal {
+		roll := float64(profile.WidthMM)
+		v.Warnings = paperWarnings(info, roll, thermalLengthMM(info, roll))
+	} else {
+		v.Warnings = paperWarnings(info, 0, 0)
+	}
+	return v, nil
+}
+
+// ValidatePrintRequest es el cuerpo de POST /validate-print: los mismos campos que /print
+type ValidatePrintRequest struct {
+	URL     string `json:"url"`
+	Data    string `json:"data"`
+	Printer string `json:"printer"`
+	PrintOptions
+}
+
+// ValidatePrintHandler hace todas las verificaciones de una impresión real (impresora existente y
+// en línea, URL accesible, PDF válido y dentro del tamaño permitido) sin imprimir, para que el ERP
+// valide el documento antes de asignarle el número fiscal
+func (h Handlers) ValidatePrintHandler(w http.ResponseWriter, r *http.Request) {
+	h.Logger = h.Logger.ForRequest(r)
+	h.Logger.Info("Received request: /validate-print")
+
+	if r.Method != http.MethodPost {
+		h.Logger.Warnf("Método HTTP no permitido: %s", r.Method)
+		WriteErrorJSON(w, http.StatusMethodNotAllowed, "Método HTTP no permitido", nil)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, h.MaxUploadBytes*4/3+4096)
+
+	var req ValidatePrintRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.Logger.Warnf("Error al decodificar JSON: %v", err)
+		WriteErrorJSON(w, http.StatusBadRequest, "Solicitud JSON inválida", err)
+		return
+	}
+
+	if (req.URL == "") == (req.Data == "") {
+		h.Logger.Warn("Se debe especificar url o data")
+		WriteErrorJSON(w, http.StatusBadRequest, "Especifique uno de los campos url o data", nil)
+		return
+	}
+
+	printer, err := h.defaultPrinter(req.Printer)
+	if err != nil {
+		h.Logger.Warnf("Impresora no especificada: %v", err)
+		WriteErrorJSON(w, http.StatusBadRequest, "URL o impresora no especificados", err)
+		return
+	}
+
+	opts := req.PrintOptions
+	if err := opts.Normalize(); err != nil {
+		h.Logger.Warnf("Opciones de impresión inválidas: %v", err)
+		WriteErrorJSON(w, http.StatusBadRequest, "Opciones de impresión inválidas", err)
+		return
+	}
+
+	printer, group := h.groupPrinter(printer)
+	ctx, cancel := downloadContext(r, opts.DownloadTimeout)
+	defer cancel()
+	v, err := h.Service.ValidateDocument(ctx, req.URL, req.Data, printer)
+	v.Group = group
+	if err == nil {
+		err = h.validatePrinterReady(v)
+	}
+	if err != nil {
+		h.Logger.Warnf("Validación de impresión en '%s' rechazada: %v", printer, err)
+		v.ErrorCode = errorCode(0, err)
+		v.Error = err.Error()
+	} else {
+		v.Valid = true
+		h.Logger.Infof("Validación de impresión en '%s' correcta: %d páginas", printer, v.PageCount)
+	}
+	WriteJSON(w, http.StatusOK, v)
+}
+
+// validatePrinterReady aplica la verificación de estado configurada (STATUS_CHECK_MODE) y exige que
+// la impresora esté en línea; los demás problemas del spooler se informan como advertencias
+func (h Handlers) validatePrinterReady(v *PrintValidation) error {
+	warnings, err := h.Preflight.Check(v.Printer)
+	v.Warnings = append(v.Warnings, warnings...)
+	if err != nil {
+		return err
+	}
+
+	status, err := h.Service.PrinterStatus(v.Printer)
+	if err != nil {
+		h.Logger.Warnf("No se pudo consultar el estado de '%s': %v", v.Printer, err)
+		v.Warnings = append(v.Warnings, "no se pudo consultar el estado de la impresora")
+		return nil
+	}
+	if !status.Online {
+		return &PrinterNotReadyError{Printer: v.Printer, Problems: status.Problems, PaperOut: status.PaperOut}
+	}
+	if !status.Ready {
+		v.Warnings = append(v.Warnings, status.Problems...)
+	}
+	return nil
+}
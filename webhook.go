@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// ============================
+// Webhooks de Estado de Trabajos
+// ============================
+
+// WebhookPayload es el cuerpo enviado al webhook cuando un trabajo termina
+type WebhookPayload struct {
+	Event string `json:"event"`
+	*PrintJob
+}
+
+// WebhookNotifier envía el resultado de los trabajos a la URL indicada en la solicitud
+// o, en su defecto, a la URL global configurada.
+type WebhookNotifier struct {
+	DefaultURL string
+	Secret     string
+	Retries    int
+	// Policy restringe las URL que llegan en las solicitudes, como las descargas; Client las
+	// valida también al conectar. DefaultClient envía a WEBHOOK_URL, que define el administrador.
+	Policy        DownloadPolicy
+	Client        *http.Client
+	DefaultClient *http.Client
+	Logger        *Logger
+}
+
+// NewWebhookNotifier crea un notificador con los valores de la configuración
+func NewWebhookNotifier(cfg Config, logger *Logger) (*WebhookNotifier, error) {
+	timeout := time.Duration(cfg.WebhookTimeout) * time.Second
+	policy := DownloadPolicy{AllowedHosts: cfg.DownloadAllowedHosts, BlockPrivate: cfg.DownloadBlockPrivate}
+	client, err := policy.Client(timeout, cfg.Outbound)
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if err := cfg.Outbound.apply(transport); err != nil {
+		return nil, err
+	}
+	return &WebhookNotifier{
+		DefaultURL:    cfg.WebhookURL,
+		Secret:        cfg.WebhookSecret,
+		Retries:       cfg.WebhookRetries,
+		Policy:        policy,
+		Client:        client,
+		DefaultClient: &http.Client{Timeout: timeout, Transport: transport},
+		Logger:        logger,
+	}, nil
+}
+
+// ValidateWebhookURL verifica que la URL del webhook sea http o https
+func ValidateWebhookURL(rawURL string) error {
+	if rawURL == "" {
+		return nil
+	}
+	parsed, err := url.ParseRequestURI(rawURL)
+	if err != nil {
+		return fmt.Errorf("URL de webhook inválida: %w", err)
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return fmt.Errorf("esquema de URL de webhook no soportado: %s", parsed.Scheme)
+	}
+	return nil
+}
+
+// CheckURL valida la URL de webhook de una solicitud con la política de descargas, para que el
+// agente no se use para enviar solicitudes a direcciones internas
+func (n *WebhookNotifier) CheckURL(rawURL string) error {
+	if err := ValidateWebhookURL(rawURL); err != nil || rawURL == "" || n == nil {
+		return err
+	}
+	parsed, _ := url.Parse(rawURL)
+	return n.Policy.CheckURL(parsed)
+}
+
+// Notify envía el resultado del trabajo en segundo plano para no demorar la respuesta al ERP
+func (n *WebhookNotifier) Notify(job *PrintJob) {
+	target, client := job.WebhookURL, n.Client
+	if target == "" {
+		target, client = n.DefaultURL, n.DefaultClient
+	}
+	if target == "" {
+		return
+	}
+	// Los trabajos programados o en carpetas pueden venir de antes de cambiar la política
+	if client == n.Client {
+		if err := n.CheckURL(target); err != nil {
+			n.Logger.Errorf("Webhook del trabajo %s no enviado: %v", job.ID, err)
+			return
+		}
+	}
+
+	payload := WebhookPayload{Event: "job." + job.Status, PrintJob: job}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		n.Logger.Errorf("Error al codificar webhook del trabajo %s: %v", job.ID, err)
+		return
+	}
+
+	go func() {
+		defer recoverCrash()
+		n.deliver(client, target, job.ID, body)
+	}()
+}
+
+// deliver realiza el POST al webhook reintentando con espera exponencial
+func (n *WebhookNotifier) deliver(client *http.Client, target, jobID string, body []byte) {
+	delay := time.Second
+	for attempt := 0; attempt <= n.Retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(delay)
+			delay *= 2
+		}
+		err := n.post(client, target, body)
+		if err == nil {
+			n.Logger.Infof("Webhook del trabajo %s entregado a %s", jobID, target)
+			return
+		}
+		n.Logger.Warnf("Error al entregar webhook del trabajo %s (intento %d): %v", jobID, attempt+1, err)
+	}
+	n.Logger.Errorf("No se pudo entregar el webhook del trabajo %s a %s", jobID, target)
+}
+
+func (n *WebhookNotifier) post(client *http.Client, target string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("User-Agent", "PrinterMatiasERP")
+	if n.Secret != "" {
+		// Firma HMAC para que el ERP pueda verificar que el webhook proviene del agente
+		mac := hmac.New(sha256.New, []byte(n.Secret))
+		mac.Write(body)
+		req.Header.Set("X-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("el webhook retornó estado %d", resp.StatusCode)
+	}
+	return nil
+}
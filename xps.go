@@ -0,0 +1,97 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// ============================
+// Documentos XPS
+// ============================
+
+// ErrXPSUnsupported indica que el backend no puede imprimir documentos XPS (CUPS)
+var ErrXPSUnsupported = errors.New("los documentos XPS solo se imprimen con las impresoras de Windows")
+
+// XPSPrinter envía documentos XPS (.xps u OpenXPS .oxps) a la impresora sin convertirlos a PDF
+type XPSPrinter interface {
+	PrintXPS(filePath, printer string, opts PrintOptions) error
+}
+
+// DetectXPSDocument informa si el archivo es un documento XPS u OpenXPS: un zip cuya relación
+// raíz (_rels/.rels) apunta a una secuencia de documentos fijos. Se reconoce por el contenido porque
+// los documentos de Office también son zip.
+func DetectXPSDocument(filePath string) bool {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	head := make([]byte, 4)
+	if _, err := io.ReadFull(f, head); err != nil || !bytes.Equal(head, []byte("PK\x03\x04")) {
+		return false
+	}
+
+	r, err := zip.OpenReader(filePath)
+	if err != nil {
+		return false
+	}
+	defer r.Close()
+	for _, file := range r.File {
+		if !strings.EqualFold(file.Name, "_rels/.rels") {
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return false
+		}
+		rels, _ := io.ReadAll(io.LimitReader(rc, 64<<10))
+		rc.Close()
+		// http://schemas.microsoft.com/xps/2005/06/fixedrepresentation (XPS) o
+		// http://schemas.openxps.org/oxps/v1.0/fixedrepresentation (OpenXPS)
+		return bytes.Contains(rels, []byte("/fixedrepresentation"))
+	}
+	return false
+}
+
+// printXPS envía el documento XPS tal cual: las opciones que requieren modificar el PDF (sello,
+// páginas, motor) no se aplican
+func (d DefaultPrinterService) printXPS(filePath, printerName string, opts PrintOptions) error {
+	if d.XPS == nil {
+		return permanent(ErrXPSUnsupported)
+	}
+	if info, err := os.Stat(filePath); err != nil {
+		return err
+	} else if d.MaxDocumentBytes > 0 && info.Size() > d.MaxDocumentBytes {
+		return fmt.Errorf("%w: el archivo tiene %d bytes y el máximo es %d", ErrInvalidDocument, info.Size(), d.MaxDocumentBytes)
+	}
+	if opts.Stamp != "" || opts.StampImage != "" || opts.CopyLabel != "" || opts.Pages != "" || opts.Engine != "" {
+		d.Logger.Warnf("El documento XPS se envía sin procesar a '%s'; se ignoran sello, copy_label, páginas y motor", printerName)
+	}
+	if opts.Copies == 0 {
+		opts.Copies = d.profile(printerName).Copies
+	}
+	d.Logger.Infof("Enviando el documento XPS a '%s'", printerName)
+	if err := d.XPS.PrintXPS(filePath, printerName, opts); err != nil {
+		return fmt.Errorf("error al imprimir el documento XPS: %w", err)
+	}
+	noteSpooledDocument(toolRun{JobID: opts.JobID, Printer: printerName, Document: filePath})
+	return nil
+}
+
+// SerializedXPSPrinter imprime el documento XPS cuando la impresora queda libre
+type SerializedXPSPrinter struct {
+	Next  XPSPrinter
+	Locks *PrinterLocks
+}
+
+// PrintXPS imprime el documento cuando la impresora queda libre
+func (s SerializedXPSPrinter) PrintXPS(filePath, printer string, opts PrintOptions) error {
+	return s.Locks.Do(printer, func() error {
+		return s.Next.PrintXPS(filePath, printer, opts)
+	})
+}
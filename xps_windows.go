@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// ============================
+// API de Impresión XPS (xpsprint.dll)
+// ============================
+
+var (
+	modXPSPrint          = windows.NewLazySystemDLL("xpsprint.dll")
+	procStartXpsPrintJob = modXPSPrint.NewProc("StartXpsPrintJob")
+)
+
+// Métodos de las interfaces COM, por su posición en la tabla virtual
+const (
+	xpsStreamWrite           = 4 // ISequentialStream::Write
+	xpsStreamClose           = 5 // IXpsPrintJobStream::Close
+	xpsJobCancel             = 3 // IXpsPrintJob::Cancel
+	xpsJobGetJobStatus       = 4 // IXpsPrintJob::GetJobStatus
+	xpsJobCompleted          = 1 // XPS_JOB_COMPLETED
+	xpsWaitSliceMilliseconds = 500
+)
+
+// xpsJobStatus refleja la estructura XPS_JOB_STATUS
+type xpsJobStatus struct {
+	JobID            uint32
+	CurrentDocument  int32
+	CurrentPage      int32
+	CurrentPageTotal int32
+	Completion       int32
+	JobStatus        int32
+}
+
+// WindowsXPSPrinter envía los documentos XPS con StartXpsPrintJob. El spooler los entrega tal cual
+// a los controladores XPSDrv y los convierte para los controladores GDI, sin pasar por un motor PDF.
+type WindowsXPSPrinter struct{}
+
+// PrintXPS imprime el documento una vez por copia
+func (WindowsXPSPrinter) PrintXPS(filePath, printer string, opts PrintOptions) error {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("error al leer el documento: %w", err)
+	}
+	// COM se inicializa por hilo: el trabajo completo debe correr en el mismo hilo del sistema
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	if err := windows.CoInitializeEx(0, windows.COINIT_MULTITHREADED); err != nil && err != syscall.Errno(windows.S_FALSE) {
+		return fmt.Errorf("CoInitializeEx falló: %w", err)
+	}
+	defer windows.CoUninitialize()
+
+	for copy := 0; copy < max(opts.Copies, 1); copy++ {
+		// El nombre del archivo identifica el documento en la cola (p. ej. para cancelarlo)
+		if err := printXPSJob(printer, filepath.Base(filePath), data, opts.JobID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// printXPSJob envía el documento en un trabajo del spooler y espera a que termine de procesarse
+func printXPSJob(printer, docName string, data []byte, jobID string) error {
+	printerPtr, err := windows.UTF16PtrFromString(printer)
+	if err != nil {
+		return err
+	}
+	docNamePtr, err := windows.UTF16PtrFromString(docName)
+	if err != nil {
+		return err
+	}
+	completion, err := windows.CreateEvent(nil, 1, 0, nil)
+	if err != nil {
+		return fmt.Errorf("error al crear el evento del trabajo XPS: %w", err)
+	}
+	defer windows.CloseHandle(completion)
+
+	var job, stream *comObject
+	hr, _, _ := procStartXpsPrintJob.Call(uintptr(unsafe.Pointer(printerPtr)), uintptr(unsafe.Pointer(docNamePtr)), 0, 0,
+		uintptr(completion), 0, 0, uintptr(unsafe.Pointer(&job)), uintptr(unsafe.Pointer(&stream)), 0)
+	if int32(hr) < 0 {
+		return fmt.Errorf("StartXpsPrintJob falló para '%s': 0x%08X", printer, uint32(hr))
+	}
+	defer job.release()
+	defer stream.release()
+
+	if err := writeXPSStream(stream, data); err != nil {
+		job.call(xpsJobCancel)
+		return err
+	}
+	if hr := stream.call(xpsStreamClose); int32(hr) < 0 {
+		job.call(xpsJobCancel)
+		return fmt.Errorf("error al cerrar el documento XPS: 0x%08X", uint32(hr))
+	}
+
+	// El spooler procesa el documento en segundo plano; se espera para informar el resultado real
+	for {
+		event, err := windows.WaitForSingleObject(completion, xpsWaitSliceMilliseconds)
+		if err != nil {
+			return fmt.Errorf("error al esperar el trabajo XPS: %w", err)
+		}
+		if event == windows.WAIT_OBJECT_0 {
+			break
+		}
+		if isJobCanceled(jobID) {
+			job.call(xpsJobCancel)
+			return ErrJobCanceled
+		}
+	}
+	var status xpsJobStatus
+	if hr := job.call(xpsJobGetJobStatus, uintptr(unsafe.Pointer(&status))); int32(hr) < 0 {
+		return fmt.Errorf("error al consultar el trabajo XPS: 0x%08X", uint32(hr))
+	}
+	if status.Completion != xpsJobCompleted {
+		return fmt.Errorf("el spooler no pudo imprimir el documento XPS: 0x%08X", uint32(status.JobStatus))
+	}
+	return nil
+}
+
+// writeXPSStream escribe el documento en el flujo del trabajo
+func writeXPSStream(stream *comObject, data []byte) error {
+	for len(data) > 0 {
+		chunk := data[:min(len(data), 1<<20)]
+		var written uint32
+		hr := stream.call(xpsStreamWrite, uintptr(unsafe.Pointer(&chunk[0])), uintptr(len(chunk)), uintptr(unsafe.Pointer(&written)))
+		if int32(hr) < 0 {
+			return fmt.Errorf("error al enviar el documento XPS: 0x%08X", uint32(hr))
+		}
+		if written == 0 {
+			return io.ErrShortWrite
+		}
+		data = data[written:]
+	}
+	return nil
+}